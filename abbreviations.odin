@@ -0,0 +1,137 @@
+package main
+
+import "core:encoding/json"
+import "core:log"
+import "core:os"
+import "core:strings"
+import "core:unicode"
+import "core:unicode/utf8"
+import editor "editor"
+
+ABBREVIATIONS_FILE :: "abbreviations.json" // in the user config dir, see user_config_path
+
+// A word that is replaced as soon as a character that cannot be part of a
+// word is typed after it, or enter is pressed.  User abbreviations are read
+// from abbreviations.json, a project's from [[abbreviations]] tables in its
+// workspace config file, which win.  abbreviations.json is a list:
+//
+//	[{"trigger": "teh", "expansion": "the"},
+//	 {"trigger": "fnn", "expansion": "$0 :: proc() {\n\t\n}", "language": "odin"}]
+//
+// Lines after the first keep the indentation of the trigger's line, and each
+// leading tab becomes one indent level.  $0 marks where the cursor goes; an
+// expansion with it swallows the character that triggered it.
+Abbreviation :: struct {
+	trigger:   string,
+	expansion: string,
+	language:  string, // a language id, or empty for every language
+}
+
+load_abbreviations :: proc(state: ^Editor_State) {
+	path, ok := user_config_path(ABBREVIATIONS_FILE)
+	if !ok {
+		return
+	}
+	data, err := os.read_entire_file_from_path(path, context.temp_allocator)
+	if err != nil {
+		return
+	}
+	if uerr := json.unmarshal(data, &state.abbreviations); uerr != nil {
+		log.warnf("abbreviations: %s: %v", path, uerr)
+	}
+}
+
+destroy_abbreviations :: proc(state: ^Editor_State) {
+	for a in state.abbreviations {
+		destroy_abbreviation(a)
+	}
+	delete(state.abbreviations)
+}
+
+destroy_abbreviation :: proc(a: Abbreviation) {
+	delete(a.trigger)
+	delete(a.expansion)
+	delete(a.language)
+}
+
+// Expands the abbreviation before the cursor when typed ends a word.
+// Returns true when the expansion placed the cursor itself, so typed must
+// not be inserted.
+expand_abbreviation :: proc(state: ^Editor_State, typed: rune) -> bool {
+	if is_word_rune(typed) {
+		return false
+	}
+	if has_selection(state) || !config_bool(state, "abbreviations.enabled") {
+		return false
+	}
+	line := editor.get_line(&state.buffer, state.cursor_data.line, context.temp_allocator)
+	before := line[:min(state.cursor_data.col, len(line))]
+	word := len(before)
+	for word > 0 {
+		r, size := utf8.decode_last_rune_in_string(before[:word])
+		if !is_word_rune(r) {
+			break
+		}
+		word -= size
+	}
+	if word == len(before) {
+		return false
+	}
+	a, found := find_abbreviation(state, before[word:])
+	if !found {
+		return false
+	}
+
+	style := indent_style(state)
+	indent := before[:editor.leading_whitespace(before)]
+	b := strings.builder_make(context.temp_allocator)
+	for l, i in strings.split(a.expansion, "\n", context.temp_allocator) {
+		l := l
+		if i > 0 {
+			strings.write_byte(&b, '\n')
+			strings.write_string(&b, indent)
+			for strings.has_prefix(l, "\t") {
+				strings.write_string(&b, editor.indent_string(style, style.width))
+				l = l[1:]
+			}
+		}
+		strings.write_string(&b, l)
+	}
+	text := strings.to_string(b)
+	mark := strings.index(text, "$0")
+	if mark >= 0 {
+		text = strings.concatenate({text[:mark], text[mark + 2:]}, context.temp_allocator)
+	}
+
+	start := state.cursor_pos - (len(before) - word)
+	editor.replace_bytes(&state.buffer, start, state.cursor_pos - start, transmute([]u8)text)
+	state.cursor_pos = start + (mark if mark >= 0 else len(text))
+	sync_cursor(state)
+	set_preferred_col(state)
+	return mark >= 0
+}
+
+@(private = "file")
+is_word_rune :: proc(r: rune) -> bool {
+	return r == '_' || unicode.is_letter(r) || unicode.is_digit(r)
+}
+
+// The project's abbreviation for word before the user's, and one for the
+// buffer's language before one for every language.
+@(private = "file")
+find_abbreviation :: proc(state: ^Editor_State, word: string) -> (Abbreviation, bool) {
+	lists := [2][]Abbreviation{state.project.abbreviations[:], state.abbreviations[:]}
+	for list in lists {
+		for a in list {
+			if a.trigger == word && a.language == state.language {
+				return a, true
+			}
+		}
+		for a in list {
+			if a.trigger == word && a.language == "" {
+				return a, true
+			}
+		}
+	}
+	return {}, false
+}
@@ -0,0 +1,129 @@
+package main
+
+import "core:fmt"
+import "core:os"
+import "core:path/filepath"
+import "core:slice"
+import "core:strconv"
+import "core:strings"
+import "core:time"
+
+// How the previous contents of a file are kept when a save overwrites it.
+Backup_Mode :: enum {
+	None,
+	Simple, // file~, replaced on every save
+	Numbered, // file.~1~, file.~2~, ...
+	Timestamped, // file.~20260116-143005~ (UTC)
+}
+
+@(private = "file")
+Backup_File :: struct {
+	path: string,
+	key:  i64, // the number or the timestamp digits; higher is newer
+}
+
+parse_backup_mode :: proc(s: string) -> (mode: Backup_Mode, ok: bool) {
+	switch s {
+	case "", "none":
+		return .None, true
+	case "simple":
+		return .Simple, true
+	case "numbered":
+		return .Numbered, true
+	case "timestamped":
+		return .Timestamped, true
+	}
+	return .None, false
+}
+
+// Copies the file at full (an absolute path) to a backup as backup.mode
+// says, before a save replaces it.  Older backups beyond backup.keep are
+// removed.
+backup_file :: proc(state: ^Editor_State, full: string) -> os.Error {
+	mode, _ := parse_backup_mode(config_value(state, "backup.mode").(string) or_else "")
+	if mode == .None || !os.exists(full) {
+		return nil
+	}
+	data := os.read_entire_file_from_path(full, context.temp_allocator) or_return
+	dir, name := backup_location(state, full)
+	_ = os.make_directory_all(dir)
+
+	target: string
+	switch mode {
+	case .None:
+		return nil
+	case .Simple:
+		target = fmt.tprintf("%s~", name)
+	case .Numbered:
+		backups := list_backups(dir, name, .Numbered)
+		last := backups[len(backups) - 1].key if len(backups) > 0 else 0
+		target = fmt.tprintf("%s.~%d~", name, last + 1)
+	case .Timestamped:
+		now := time.now()
+		y, mon, d := time.date(now)
+		h, mi, s := time.clock(now)
+		target = fmt.tprintf("%s.~%04d%02d%02d-%02d%02d%02d~", name, y, int(mon), d, h, mi, s)
+	}
+
+	path := filepath.join({dir, target}, context.temp_allocator)
+	write_file_atomic(path, data) or_return
+	copy_file_metadata(full, path)
+
+	if keep := config_int(state, "backup.keep"); keep > 0 && mode != .Simple {
+		backups := list_backups(dir, name, mode)
+		for i := 0; i < len(backups) - keep; i += 1 {
+			os.remove(backups[i].path)
+		}
+	}
+	return nil
+}
+
+// Directory and base name for the backups of full.  With a backup.dir the
+// name is the workspace relative path with separators turned into '%', so
+// files of the same name in different directories do not collide.
+@(private = "file")
+backup_location :: proc(state: ^Editor_State, full: string) -> (dir, name: string) {
+	backup_dir := config_value(state, "backup.dir").(string) or_else ""
+	if backup_dir == "" {
+		return filepath.dir(full, context.temp_allocator), filepath.base(full)
+	}
+	dir = workspace_path(state, backup_dir)
+	rel, err := filepath.rel(state.workspace_root, full, context.temp_allocator)
+	if err != .None || strings.has_prefix(rel, "..") {
+		rel = full
+	}
+	name, _ = strings.replace_all(rel, "/", "%", context.temp_allocator)
+	name, _ = strings.replace_all(name, "\\", "%", context.temp_allocator)
+	name = strings.trim_left(name, "%")
+	return
+}
+
+// Numbered or timestamped backups of name in dir, oldest first.
+@(private = "file")
+list_backups :: proc(dir, name: string, mode: Backup_Mode) -> []Backup_File {
+	backups := make([dynamic]Backup_File, context.temp_allocator)
+	entries, err := os.read_all_directory_by_path(dir, context.temp_allocator)
+	if err != nil {
+		return nil
+	}
+	prefix := fmt.tprintf("%s.~", name)
+	for fi in entries {
+		if fi.type != .Regular ||
+		   !strings.has_prefix(fi.name, prefix) ||
+		   !strings.has_suffix(fi.name, "~") {
+			continue
+		}
+		stamp := fi.name[len(prefix):len(fi.name) - 1]
+		if strings.contains_rune(stamp, '-') != (mode == .Timestamped) {
+			continue
+		}
+		digits, _ := strings.remove_all(stamp, "-", context.temp_allocator)
+		if key, ok := strconv.parse_i64_of_base(digits, 10); ok {
+			append(&backups, Backup_File{fi.fullpath, key})
+		}
+	}
+	slice.sort_by(backups[:], proc(a, b: Backup_File) -> bool {
+		return a.key < b.key
+	})
+	return backups[:]
+}
@@ -0,0 +1,276 @@
+package main
+
+import "core:fmt"
+import "core:os"
+import "core:strings"
+import "core:text/regex"
+import editor "editor"
+
+// One line of a batch script.  Scripts are ex-style, a command a line:
+//
+//	s/pattern/replacement/[g]  substitute on each line, every match with g
+//	g/pattern/d                delete the lines matching pattern
+//	v/pattern/d                delete the lines not matching it
+//	format                     run the language's usual formatter
+//	!command                   pipe the text through a shell command
+//
+// Any character may stand for the slashes.  Blank lines and lines starting
+// with # are skipped.
+Batch_Command :: struct {
+	kind:        Batch_Command_Kind,
+	re:          regex.Regular_Expression,
+	replacement: string,
+	global:      bool,
+	command:     string, // Filter
+	line:        int, // in the script or among the --batch-command lines
+}
+
+Batch_Command_Kind :: enum u8 {
+	Substitute,
+	Delete_Matching,
+	Delete_Other,
+	Format,
+	Filter,
+}
+
+// rune --batch=SCRIPT [--batch-command=LINE]... files...: applies the script,
+// then the --batch-command lines, to each file without opening a window, and
+// saves the files that changed.  "-" reads stdin and prints the result to
+// stdout, for pipelines.  Returns the exit code: 1 when the script has an
+// error, which edits nothing, or a file could not be edited, which leaves the
+// other files edited.
+run_batch :: proc(script_path: string, extra: []string, files: []string) -> int {
+	script := make([dynamic]string, context.temp_allocator)
+	if script_path != "" {
+		data, err := os.read_entire_file_from_path(script_path, context.temp_allocator)
+		if err != nil {
+			fmt.eprintfln("rune: %s: %v", script_path, err)
+			return 1
+		}
+		text := string(data)
+		for line in strings.split_lines_iterator(&text) {
+			append(&script, line)
+		}
+	}
+	from_file := len(script)
+	append(&script, ..extra)
+	commands, ok := parse_batch_script(script[:], script_path, from_file)
+	defer destroy_batch_commands(commands)
+	if !ok {
+		return 1
+	}
+
+	capture := regex.preallocate_capture()
+	defer regex.destroy(capture)
+	status := 0
+	for path in files {
+		data: []u8
+		err: os.Error
+		if path == "-" {
+			data, err = os.read_entire_file_from_file(os.stdin, context.temp_allocator)
+		} else {
+			data, err = os.read_entire_file_from_path(path, context.temp_allocator)
+		}
+		if err != nil {
+			fmt.eprintfln("rune: %s: %v", path, err)
+			status = 1
+			continue
+		}
+		text := string(data)
+		edited, applied := apply_batch_commands(commands[:], path, text, &capture)
+		if !applied {
+			status = 1
+			continue
+		}
+		if path == "-" {
+			os.write_string(os.stdout, edited)
+			continue
+		}
+		if edited == text {
+			continue
+		}
+		if werr := write_file_atomic(path, transmute([]u8)edited); werr != nil {
+			fmt.eprintfln("rune: %s: %v", path, werr)
+			status = 1
+			continue
+		}
+		fmt.eprintfln("rune: %s: changed", path)
+	}
+	return status
+}
+
+// Parses every line, reporting each bad one as name:line for the first
+// from_file lines and as --batch-command:n for the others.
+@(private = "file")
+parse_batch_script :: proc(
+	lines: []string,
+	name: string,
+	from_file: int,
+) -> (
+	[dynamic]Batch_Command,
+	bool,
+) {
+	commands := make([dynamic]Batch_Command, context.temp_allocator)
+	ok := true
+	for raw, i in lines {
+		line := strings.trim_space(raw)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		c := Batch_Command {
+			line = i + 1,
+		}
+		source := name
+		if i >= from_file {
+			source, c.line = "--batch-command", i - from_file + 1
+		}
+		problem: string
+		switch {
+		case line == "format":
+			c.kind = .Format
+		case line[0] == '!':
+			c.kind = .Filter
+			c.command = strings.trim_space(line[1:])
+			if c.command == "" {
+				problem = "! needs a command"
+			}
+		case line[0] == 's' && len(line) > 1:
+			parts := strings.split(line[2:], line[1:2], context.temp_allocator)
+			if len(parts) != 3 || (parts[2] != "" && parts[2] != "g") {
+				problem = "expected s/pattern/replacement/ or s/pattern/replacement/g"
+				break
+			}
+			c.kind = .Substitute
+			c.replacement = parts[1]
+			c.global = parts[2] == "g"
+			problem = compile_batch_pattern(&c, parts[0])
+		case (line[0] == 'g' || line[0] == 'v') && len(line) > 1:
+			parts := strings.split(line[2:], line[1:2], context.temp_allocator)
+			if len(parts) != 2 || parts[1] != "d" {
+				problem = fmt.tprintf("expected %c/pattern/d", line[0])
+				break
+			}
+			c.kind = .Delete_Matching if line[0] == 'g' else .Delete_Other
+			problem = compile_batch_pattern(&c, parts[0])
+		case:
+			problem = fmt.tprintf("unknown command %q", line)
+		}
+		if problem != "" {
+			fmt.eprintfln("rune: %s:%d: %s", source, c.line, problem)
+			ok = false
+			continue
+		}
+		append(&commands, c)
+	}
+	return commands, ok
+}
+
+@(private = "file")
+compile_batch_pattern :: proc(c: ^Batch_Command, pattern: string) -> string {
+	re, err := regex.create(pattern, {})
+	if err != nil {
+		return fmt.tprintf("bad pattern %q: %v", pattern, err)
+	}
+	c.re = re
+	return ""
+}
+
+@(private = "file")
+destroy_batch_commands :: proc(commands: [dynamic]Batch_Command) {
+	for c in commands {
+		if c.kind == .Substitute || c.kind == .Delete_Matching || c.kind == .Delete_Other {
+			regex.destroy(c.re)
+		}
+	}
+}
+
+// The text after the commands, or false, having said why, when one failed.
+// Temp allocated.
+@(private = "file")
+apply_batch_commands :: proc(
+	commands: []Batch_Command,
+	path, text: string,
+	capture: ^regex.Capture,
+) -> (
+	result: string,
+	ok: bool,
+) {
+	text := text
+	for c in commands {
+		switch c.kind {
+		case .Substitute, .Delete_Matching, .Delete_Other:
+			text = edit_batch_lines(c, text, capture)
+		case .Format:
+			command := default_formatter(editor.detect_language_by_path(path).id)
+			if command == "" {
+				fmt.eprintfln("rune: %s: no formatter for this language", path)
+				return "", false
+			}
+			command = fill_placeholder(command, "{file}", path)
+			text = run_batch_filter(command, path, text) or_return
+		case .Filter:
+			text = run_batch_filter(c.command, path, text) or_return
+		}
+	}
+	return text, true
+}
+
+// Substitutes on or deletes lines.  Line breaks, "\r\n" ones included, are
+// kept out of reach of the pattern.
+@(private = "file")
+edit_batch_lines :: proc(c: Batch_Command, text: string, capture: ^regex.Capture) -> string {
+	b := strings.builder_make(0, len(text), context.temp_allocator)
+	rest := text
+	for line in strings.split_after_iterator(&rest, "\n") {
+		content := strings.trim_suffix(strings.trim_suffix(line, "\n"), "\r")
+		ending := line[len(content):]
+		switch c.kind {
+		case .Substitute:
+			limit := -1 if c.global else 1
+			replaced, _ := editor.regex_replace_all(
+				c.re,
+				content,
+				c.replacement,
+				capture,
+				context.temp_allocator,
+				limit,
+			)
+			strings.write_string(&b, replaced)
+			strings.write_string(&b, ending)
+		case .Delete_Matching, .Delete_Other:
+			_, matched := regex.match(c.re, content, capture)
+			if matched != (c.kind == .Delete_Matching) {
+				strings.write_string(&b, line)
+			}
+		case .Format, .Filter:
+		}
+	}
+	return strings.to_string(b)
+}
+
+// Runs command through the shell with text on stdin and returns its stdout.
+@(private = "file")
+run_batch_filter :: proc(command, path, text: string) -> (string, bool) {
+	input, err := os.create_temp_file("", "rune-batch-*")
+	if err != nil {
+		fmt.eprintfln("rune: %s: cannot write the input of %s: %v", path, command, err)
+		return "", false
+	}
+	defer {
+		name := strings.clone(os.name(input), context.temp_allocator)
+		os.close(input)
+		os.remove(name)
+	}
+	os.write_string(input, text)
+	os.seek(input, 0, .Start)
+	desc := os.Process_Desc {
+		command = shell_command(command),
+		stdin   = input,
+	}
+	state, stdout, stderr, perr := os.process_exec(desc, context.temp_allocator)
+	if perr != nil || !state.success {
+		fmt.eprintfln("rune: %s: %s failed: %s", path, command, strings.trim_space(string(stderr)))
+		return "", false
+	}
+	return string(stdout), true
+}
@@ -0,0 +1,294 @@
+package main
+
+import "core:fmt"
+import "core:os"
+import "core:path/filepath"
+import "core:strings"
+import "core:time"
+import editor "editor"
+
+// While annotations are on, an edited buffer is blamed again at most this
+// often.
+BLAME_INTERVAL :: 1 * time.Second
+
+// What to do once an up to date blame of the active document is ready.
+Blame_Request :: enum {
+	File, // list the whole file in the panel
+	Commit, // show the message of the cursor line's commit
+}
+
+Blame_State :: struct {
+	job:        ^editor.Git_Blame, // the latest blame, running or done
+	doc_id:     int, // document and undo version job blames
+	version:    int,
+	started:    time.Tick,
+	inline:     bool, // annotate the cursor line
+	requests:   bit_set[Blame_Request],
+	shown:      ^editor.Git_Blame, // blame and line of the current annotation
+	shown_line: int,
+	show:       ^editor.Git_Command, // `git show` for a commit message
+}
+
+destroy_blame :: proc(state: ^Editor_State) {
+	b := &state.blame
+	if b.job != nil {
+		editor.destroy_git_blame(b.job)
+	}
+	if b.show != nil {
+		editor.destroy_git_command(b.show)
+	}
+}
+
+// Turns the annotation of the cursor line with its last commit on or off.
+toggle_inline_blame :: proc(state: ^Editor_State) {
+	b := &state.blame
+	b.inline = !b.inline
+	if !b.inline {
+		clear_blame_annotation(state)
+		set_message(state, "Blame annotations off")
+		return
+	}
+	if blame_unavailable(state) {
+		b.inline = false
+		return
+	}
+	set_message(state, "Blame annotations on")
+}
+
+// Lists every line of the file with the commit that last changed it.
+blame_file :: proc(state: ^Editor_State) {
+	if !blame_unavailable(state) {
+		state.blame.requests += {.File}
+	}
+}
+
+// Shows the full message of the commit that last changed the cursor line.
+show_blame_commit :: proc(state: ^Editor_State) {
+	if !blame_unavailable(state) {
+		state.blame.requests += {.Commit}
+	}
+}
+
+// Starts blames as needed and acts on finished ones.  Called every frame.
+update_blame :: proc(state: ^Editor_State) {
+	b := &state.blame
+	if b.show != nil && editor.is_git_command_finished(b.show) {
+		show_commit_message(state)
+	}
+	if !b.inline && b.requests == {} {
+		return
+	}
+	if state.path == "" || state.preview || state.disk.view != .Text || .Blame in state.degraded {
+		b.requests = {}
+		clear_blame_annotation(state)
+		return
+	}
+
+	stale := b.job == nil || b.doc_id != state.doc_id || b.version != state.undo.version
+	running := b.job != nil && !editor.is_git_blame_finished(b.job)
+	if stale && !running && (b.requests != {} || time.tick_since(b.started) >= BLAME_INTERVAL) {
+		start_blame(state)
+		running = true
+	}
+	if stale || running {
+		clear_blame_annotation(state)
+		return
+	}
+
+	job := b.job
+	if !job.ok {
+		if b.requests != {} || b.inline {
+			set_message(state, "%s is not committed to git", document_title(state.path))
+		}
+		b.requests = {}
+		b.inline = false
+		clear_blame_annotation(state)
+		return
+	}
+	if .File in b.requests {
+		list_file_blame(state, job)
+	}
+	if .Commit in b.requests {
+		if c, ok := blame_commit_at(job, state.cursor_data.line); !ok {
+			set_message(state, "No blame for this line")
+		} else if editor.is_uncommitted(c) {
+			set_message(state, "Not committed yet")
+		} else {
+			start_show_commit(state, c.hash)
+		}
+	}
+	b.requests = {}
+
+	line := state.cursor_data.line
+	if b.inline && (b.shown != job || b.shown_line != line) {
+		annotation := ""
+		if c, ok := blame_commit_at(job, line); ok {
+			annotation = format_blame(c)
+		}
+		editor.set_virtual_text(state.virtual_text, {{line = line, text = annotation}})
+		b.shown = job
+		b.shown_line = line
+	}
+}
+
+@(private = "file")
+blame_unavailable :: proc(state: ^Editor_State) -> bool {
+	switch {
+	case state.path == "":
+		set_message(state, "Blame needs a file")
+	case state.preview || state.disk.view != .Text:
+		set_message(state, "Blame needs the text view of the whole file")
+	case:
+		return false
+	}
+	return true
+}
+
+// Blames the buffer as it is now, through a scratch copy in the cache
+// directory.
+@(private = "file")
+start_blame :: proc(state: ^Editor_State) {
+	b := &state.blame
+	cache, err := os.user_cache_dir(context.temp_allocator)
+	if err != nil {
+		set_message(state, "No cache directory for blame: %v", err)
+		b.requests = {}
+		b.inline = false
+		return
+	}
+	dir := filepath.join({cache, "rune"}, context.temp_allocator)
+	_ = os.make_directory_all(dir)
+	name := fmt.tprintf("blame-%d.tmp", os.get_pid())
+	scratch := filepath.join({dir, name}, context.temp_allocator)
+
+	text := editor.get_text(&state.buffer, context.temp_allocator)
+	data, ok := buffer_to_file_data(&state.disk, text)
+	if !ok {
+		data = transmute([]u8)text
+	}
+	if b.job != nil {
+		editor.destroy_git_blame(b.job)
+	}
+	b.job = editor.start_git_blame(workspace_path(state, state.path), scratch, data)
+	b.doc_id = state.doc_id
+	b.version = state.undo.version
+	b.started = time.tick_now()
+}
+
+@(private = "file")
+clear_blame_annotation :: proc(state: ^Editor_State) {
+	b := &state.blame
+	if b.shown != nil {
+		editor.set_virtual_text(state.virtual_text, {})
+		b.shown = nil
+	}
+}
+
+@(private = "file")
+blame_commit_at :: proc(job: ^editor.Git_Blame, line: int) -> (editor.Blame_Commit, bool) {
+	if line < 0 || line >= len(job.lines) {
+		return {}, false
+	}
+	return job.commits[job.lines[line]], true
+}
+
+// "1a2b3c4d Ann Author, 3 days ago - Fix the frobnicator", temp allocated.
+@(private = "file")
+format_blame :: proc(c: editor.Blame_Commit) -> string {
+	if editor.is_uncommitted(c) {
+		return "Not committed yet"
+	}
+	return fmt.tprintf("%s %s, %s - %s", c.hash[:8], c.author, format_age(c.time), c.summary)
+}
+
+// How long ago the unix time t was, e.g. "5 minutes ago".  Temp allocated.
+format_age :: proc(t: i64) -> string {
+	MINUTE :: 60
+	HOUR :: 60 * MINUTE
+	DAY :: 24 * HOUR
+	seconds := time.time_to_unix(time.now()) - t
+	n: i64
+	unit: string
+	switch {
+	case seconds < MINUTE:
+		return "just now"
+	case seconds < HOUR:
+		n, unit = seconds / MINUTE, "minute"
+	case seconds < DAY:
+		n, unit = seconds / HOUR, "hour"
+	case seconds < 30 * DAY:
+		n, unit = seconds / DAY, "day"
+	case seconds < 365 * DAY:
+		n, unit = seconds / (30 * DAY), "month"
+	case:
+		n, unit = seconds / (365 * DAY), "year"
+	}
+	return fmt.tprintf("%d %s%s ago", n, unit, "" if n == 1 else "s")
+}
+
+// Fills the panel with the file's lines, each run of lines from the same
+// commit headed by its hash, author and age.
+@(private = "file")
+list_file_blame :: proc(state: ^Editor_State, job: ^editor.Git_Blame) {
+	release_panel(state)
+	panel := state.panel_data
+	editor.panel_clear(panel)
+	editor.panel_set_title(panel, fmt.tprintf("Blame: %s", document_title(state.path)))
+	last := -1
+	for ci, line in job.lines {
+		c := job.commits[ci]
+		who := ""
+		if ci != last && editor.is_uncommitted(c) {
+			who = "Not committed yet"
+		} else if ci != last {
+			author := c.author[:min(len(c.author), 16)]
+			who = fmt.tprintf("%s %-16s %s", c.hash[:8], author, format_age(c.time))
+		}
+		last = ci
+		text := editor.get_line(&state.buffer, line, context.temp_allocator)
+		item := editor.Panel_Item {
+			text = fmt.tprintf("%-42s %6d: %s", who, line + 1, text),
+			path = state.path,
+			line = line,
+			data = ci,
+		}
+		editor.panel_add_item(panel, item)
+	}
+	show_panel(state)
+}
+
+@(private = "file")
+start_show_commit :: proc(state: ^Editor_State, hash: string) {
+	b := &state.blame
+	if b.show != nil {
+		editor.destroy_git_command(b.show)
+	}
+	dir := filepath.dir(workspace_path(state, state.path), context.temp_allocator)
+	format := "--format=commit %H%nAuthor: %an <%ae>%nDate:   %ad%n%n%B"
+	b.show = editor.start_git_command(dir, {"show", "-s", format, hash})
+}
+
+@(private = "file")
+show_commit_message :: proc(state: ^Editor_State) {
+	b := &state.blame
+	job := b.show
+	b.show = nil
+	defer editor.destroy_git_command(job)
+	if !job.ok {
+		set_message(state, "git show %s failed", job.args[len(job.args) - 1][:8])
+		return
+	}
+	release_panel(state)
+	panel := state.panel_data
+	editor.panel_clear(panel)
+	editor.panel_set_title(panel, fmt.tprintf("Commit %s", job.args[len(job.args) - 1][:8]))
+	output := strings.trim_right_space(job.output)
+	header := true
+	for line in strings.split_lines_iterator(&output) {
+		if line == "" {
+			header = false
+		}
+		editor.panel_add_item(panel, {text = line, style = .Header if header else .Normal})
+	}
+	show_panel(state)
+}
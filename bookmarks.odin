@@ -0,0 +1,268 @@
+package main
+
+import "core:encoding/json"
+import "core:fmt"
+import "core:log"
+import "core:os"
+import "core:path/filepath"
+import "core:slice"
+import "core:strings"
+import editor "editor"
+
+BOOKMARKS_FILE :: "bookmarks.json"
+BOOKMARK_COLOR :: [4]f32{0.95, 0.75, 0.30, 1.0}
+
+// A bookmarked line.  Bookmarks are kept per workspace, sorted by path and
+// line, and saved on every change.
+Bookmark :: struct {
+	path: string, // relative to the workspace root when inside it
+	line: int, // 0 based
+	name: string, // optional, for named marks
+}
+
+load_bookmarks :: proc(state: ^Editor_State) {
+	state.bookmarks = make([dynamic]Bookmark)
+	path, ok := workspace_state_path(state, BOOKMARKS_FILE)
+	if !ok {
+		return
+	}
+	data, err := os.read_entire_file_from_path(path, context.temp_allocator)
+	if err != nil {
+		return
+	}
+	if uerr := json.unmarshal(data, &state.bookmarks); uerr != nil {
+		log.warnf("bookmarks: %s: %v", path, uerr)
+	}
+	sort_bookmarks(state)
+	refresh_bookmark_marks(state)
+}
+
+save_bookmarks :: proc(state: ^Editor_State) {
+	path, ok := workspace_state_path(state, BOOKMARKS_FILE)
+	if !ok {
+		return
+	}
+	data, merr := json.marshal(state.bookmarks[:], {pretty = true}, context.temp_allocator)
+	if merr != nil {
+		return
+	}
+	_ = os.make_directory_all(filepath.dir(path, context.temp_allocator))
+	if err := write_file_atomic(path, data); err != nil {
+		log.warnf("bookmarks: %s: %v", path, err)
+	}
+}
+
+destroy_bookmarks :: proc(state: ^Editor_State) {
+	for b in state.bookmarks {
+		delete(b.path)
+		delete(b.name)
+	}
+	delete(state.bookmarks)
+}
+
+// The open file as stored in bookmarks, or "" for an unnamed buffer.
+// Temp allocated.
+bookmark_path :: proc(state: ^Editor_State) -> string {
+	if state.path == "" {
+		return ""
+	}
+	full := workspace_path(state, state.path)
+	rel, err := filepath.rel(state.workspace_root, full, context.temp_allocator)
+	if err != .None || strings.has_prefix(rel, "..") {
+		return full
+	}
+	return rel
+}
+
+// Adds or removes a bookmark on the cursor line.
+toggle_bookmark :: proc(state: ^Editor_State) {
+	path := bookmark_path(state)
+	if path == "" {
+		set_message(state, "Bookmarks need a file")
+		return
+	}
+	line := state.cursor_data.line
+	if i := find_bookmark(state, path, line); i >= 0 {
+		remove_bookmark(state, i)
+		set_message(state, "Bookmark removed")
+	} else {
+		append(&state.bookmarks, Bookmark{path = strings.clone(path), line = line})
+		set_message(state, "Bookmark added")
+	}
+	bookmarks_changed(state)
+}
+
+// Prompts for a name and puts that mark on the cursor line, moving it there
+// if it was set elsewhere.
+set_named_bookmark :: proc(state: ^Editor_State) {
+	if bookmark_path(state) == "" {
+		set_message(state, "Bookmarks need a file")
+		return
+	}
+	open_prompt(state, "Mark name:", proc(state: ^Editor_State, name: string) {
+		name := strings.trim_space(name)
+		if name == "" {
+			return
+		}
+		if i := find_named_bookmark(state, name); i >= 0 {
+			remove_bookmark(state, i)
+		}
+		path := bookmark_path(state)
+		line := state.cursor_data.line
+		if i := find_bookmark(state, path, line); i >= 0 {
+			delete(state.bookmarks[i].name)
+			state.bookmarks[i].name = strings.clone(name)
+		} else {
+			append(
+				&state.bookmarks,
+				Bookmark{path = strings.clone(path), line = line, name = strings.clone(name)},
+			)
+		}
+		set_message(state, "Mark %q set", name)
+		bookmarks_changed(state)
+	})
+}
+
+// Prompts for a mark name and jumps to it.
+goto_named_bookmark :: proc(state: ^Editor_State) {
+	open_prompt(state, "Go to mark:", proc(state: ^Editor_State, name: string) {
+		i := find_named_bookmark(state, strings.trim_space(name))
+		if i < 0 {
+			set_message(state, "No mark named %q", name)
+			return
+		}
+		b := state.bookmarks[i]
+		jump_to_location(state, b.path, b.line, 0)
+	})
+}
+
+// Jumps to the next bookmark after the cursor, across files, wrapping around.
+next_bookmark :: proc(state: ^Editor_State) {
+	step_bookmark(state, 1)
+}
+
+prev_bookmark :: proc(state: ^Editor_State) {
+	step_bookmark(state, -1)
+}
+
+// Lists every bookmark of the workspace in the panel.
+list_bookmarks :: proc(state: ^Editor_State) {
+	release_panel(state)
+	panel := state.panel_data
+	editor.panel_clear(panel)
+	editor.panel_set_title(panel, fmt.tprintf("Bookmarks (%d)", len(state.bookmarks)))
+
+	current := bookmark_path(state)
+	lines: []string
+	last_path := ""
+	for b in state.bookmarks {
+		if b.path != last_path {
+			editor.panel_add_item(panel, {text = b.path, style = .Header})
+			last_path = b.path
+			lines = bookmark_file_lines(state, b.path, b.path == current)
+		}
+		text := strings.trim_space(lines[b.line]) if b.line < len(lines) else ""
+		label := fmt.tprintf("'%s ", b.name) if b.name != "" else ""
+		item_text := fmt.tprintf("%6d: %s%s", b.line + 1, label, text)
+		editor.panel_add_item(panel, {text = item_text, path = b.path, line = b.line})
+	}
+	show_panel(state)
+}
+
+// Puts the bookmarks of the open file in the gutter.
+refresh_bookmark_marks :: proc(state: ^Editor_State) {
+	path := bookmark_path(state)
+	marks := make([dynamic]editor.Gutter_Mark, context.temp_allocator)
+	for b in state.bookmarks {
+		if path != "" && b.path == path {
+			append(&marks, editor.Gutter_Mark{line = b.line, color = BOOKMARK_COLOR})
+		}
+	}
+	editor.set_gutter_marks(state.gutter_data, .Bookmark, marks[:])
+}
+
+@(private = "file")
+bookmarks_changed :: proc(state: ^Editor_State) {
+	sort_bookmarks(state)
+	save_bookmarks(state)
+	refresh_bookmark_marks(state)
+}
+
+@(private = "file")
+sort_bookmarks :: proc(state: ^Editor_State) {
+	slice.sort_by(state.bookmarks[:], proc(a, b: Bookmark) -> bool {
+		if a.path != b.path {
+			return a.path < b.path
+		}
+		return a.line < b.line
+	})
+}
+
+@(private = "file")
+find_bookmark :: proc(state: ^Editor_State, path: string, line: int) -> int {
+	for b, i in state.bookmarks {
+		if b.path == path && b.line == line {
+			return i
+		}
+	}
+	return -1
+}
+
+@(private = "file")
+find_named_bookmark :: proc(state: ^Editor_State, name: string) -> int {
+	for b, i in state.bookmarks {
+		if name != "" && b.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+@(private = "file")
+remove_bookmark :: proc(state: ^Editor_State, i: int) {
+	delete(state.bookmarks[i].path)
+	delete(state.bookmarks[i].name)
+	ordered_remove(&state.bookmarks, i)
+}
+
+@(private = "file")
+step_bookmark :: proc(state: ^Editor_State, dir: int) {
+	n := len(state.bookmarks)
+	if n == 0 {
+		set_message(state, "No bookmarks")
+		return
+	}
+	path := bookmark_path(state)
+	line := state.cursor_data.line
+	target := 0 if dir > 0 else n - 1 // wrap around
+	if dir > 0 {
+		for b, i in state.bookmarks {
+			if b.path > path || (b.path == path && b.line > line) {
+				target = i
+				break
+			}
+		}
+	} else {
+		#reverse for b, i in state.bookmarks {
+			if b.path < path || (b.path == path && b.line < line) {
+				target = i
+				break
+			}
+		}
+	}
+	b := state.bookmarks[target]
+	jump_to_location(state, b.path, b.line, 0)
+}
+
+// Lines of a bookmarked file for the listing, from the buffer when it is open.
+@(private = "file")
+bookmark_file_lines :: proc(state: ^Editor_State, path: string, is_open: bool) -> []string {
+	if is_open {
+		return editor.get_lines(&state.buffer, context.temp_allocator)
+	}
+	data, err := os.read_entire_file_from_path(workspace_path(state, path), context.temp_allocator)
+	if err != nil {
+		return nil
+	}
+	return strings.split_lines(string(data), context.temp_allocator)
+}
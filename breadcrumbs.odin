@@ -0,0 +1,130 @@
+package main
+
+import "core:strings"
+import "core:time"
+import editor "editor"
+
+// The breadcrumbs bar (editor.Breadcrumb_Bar_Data) along the top of the
+// text, by editor.breadcrumbs: the symbols around the cursor, outermost
+// first.  They come from a rune.document_symbol provider when a plugin adds
+// one, else from the buffer's index (editor.symbol_scopes), once typing
+// pauses; clicking one goes to it.
+Breadcrumb_State :: struct {
+	shown:      bool,
+	normal_top: f32, // where the text starts without the bar
+	scopes:     [dynamic]editor.Scoped_Symbol, // names owned
+	asked:      [2]int, // doc_id and undo version of the scopes
+	line:       int, // the cursor line of the crumbs, -1 to set them again
+}
+
+destroy_breadcrumbs :: proc(state: ^Editor_State) {
+	clear_scopes(&state.breadcrumbs)
+	delete(state.breadcrumbs.scopes)
+}
+
+// Shows or hides the bar and keeps its crumbs on the cursor.  Called every
+// frame.
+update_breadcrumbs :: proc(state: ^Editor_State) {
+	b := &state.breadcrumbs
+	shown :=
+		config_bool(state, "editor.breadcrumbs") &&
+		!state.zen.active &&
+		.Breadcrumbs not_in state.degraded &&
+		state.disk.view == .Text
+	if shown != b.shown {
+		if shown {
+			b.normal_top = state.cursor_data.padding[1]
+		}
+		b.shown = shown
+		b.line = -1
+		editor.set_layer_enabled(&state.compositor, "breadcrumbs", shown)
+		set_text_top(state, b.normal_top + state.line_height if shown else b.normal_top)
+		scroll_to_cursor(state)
+		request_redraw(state)
+	}
+	if !shown {
+		return
+	}
+	if b.asked[0] != state.doc_id {
+		// Another document's symbols would not do even for a moment.
+		clear_scopes(b)
+		b.asked = {state.doc_id, -1}
+		b.line = -1
+	}
+	quiet := time.tick_since(state.frames.last_input) >= INPUT_QUIET
+	if b.asked[1] != state.undo.version && (b.asked[1] < 0 || quiet) && collect_scopes(state) {
+		b.asked[1] = state.undo.version
+		b.line = -1
+	}
+	if b.line == state.cursor_data.line {
+		return
+	}
+	b.line = state.cursor_data.line
+	crumbs := make([dynamic]editor.Breadcrumb, context.temp_allocator)
+	for s in editor.enclosing_symbols(b.scopes[:], b.line) {
+		append(&crumbs, editor.Breadcrumb{label = s.name, line = s.line, col = s.col})
+	}
+	editor.set_breadcrumbs(state.breadcrumb_data, crumbs[:])
+	request_redraw(state)
+}
+
+// A click on the bar at x: goes to the symbol clicked.
+click_breadcrumb :: proc(state: ^Editor_State, x: f32) {
+	d := state.breadcrumb_data
+	i := editor.breadcrumb_at(d, x)
+	if i < 0 {
+		return
+	}
+	goto_line_col(state, d.crumbs[i].line, d.crumbs[i].col)
+}
+
+// Finds the buffer's symbols again; false when its index is not ready yet.
+@(private = "file")
+collect_scopes :: proc(state: ^Editor_State) -> bool {
+	b := &state.breadcrumbs
+	text := editor.get_text(&state.buffer, context.temp_allocator)
+	if len(state.plugins.symbolers) > 0 {
+		reply, encoding := plugin_document_symbols(state)
+		if scopes, ok := editor.document_symbol_scopes(text, reply, encoding); ok && reply != "" {
+			set_scopes(b, scopes)
+			return true
+		}
+	}
+	index := usable_index(state, state.doc_id, &state.undo, len(text))
+	if index == nil {
+		return false
+	}
+	set_scopes(b, editor.symbol_scopes(text, index.symbols))
+	return true
+}
+
+@(private = "file")
+set_scopes :: proc(b: ^Breadcrumb_State, scopes: []editor.Scoped_Symbol) {
+	clear_scopes(b)
+	for s in scopes {
+		kept := s
+		kept.name = strings.clone(s.name)
+		append(&b.scopes, kept)
+	}
+}
+
+@(private = "file")
+clear_scopes :: proc(b: ^Breadcrumb_State) {
+	for s in b.scopes {
+		delete(s.name)
+	}
+	clear(&b.scopes)
+}
+
+// Moves the top edge of the text in every layer that draws on its grid.
+@(private = "file")
+set_text_top :: proc(state: ^Editor_State, top: f32) {
+	state.text_data.padding[1] = top
+	state.selection_data.padding[1] = top
+	state.virtual_text.padding[1] = top
+	state.cursor_data.padding[1] = top
+	state.ruler_data.padding[1] = top
+	state.peer_cursor_data.padding[1] = top
+	state.completion_data.padding[1] = top
+	state.gutter_data.padding_top = top
+}
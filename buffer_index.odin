@@ -0,0 +1,231 @@
+package main
+
+import "core:fmt"
+import "core:mem"
+import "core:time"
+import editor "editor"
+
+// A buffer is indexed again at most this often while it changes.
+INDEX_INTERVAL :: 500 * time.Millisecond
+INDEX_MAX_RUNNING :: 2
+// Smaller buffers are scanned when their index is behind; larger ones use
+// the index they have, which is at most an edit or two out of date.
+INDEX_SCAN_SIZE :: 256 * mem.Kilobyte
+
+// The open buffers' words and symbols, indexed in the background (see
+// editor.Buffer_Index) so completion and symbol.goto do not scan the text.
+Index_State :: struct {
+	docs:    map[int]Document_Index, // by Document.id
+	listing: bool, // the panel lists symbols
+}
+
+Document_Index :: struct {
+	ready:       ^editor.Buffer_Index, // nil until the first one finishes
+	version:     int, // undo version ready was built from
+	job:         ^editor.Buffer_Index,
+	job_version: int,
+	started:     time.Tick,
+	evicted:     bool, // dropped for memory; rebuilt once the buffer is shown
+}
+
+destroy_indexes :: proc(state: ^Editor_State) {
+	for _, &d in state.index.docs {
+		destroy_document_index(&d)
+	}
+	delete(state.index.docs)
+}
+
+// Collects finished indexes, forgets closed buffers and starts indexing the
+// buffers that changed, the active one first.  Called every frame.
+update_indexes :: proc(state: ^Editor_State) {
+	running := 0
+	for _, &d in state.index.docs {
+		if d.job == nil {
+			continue
+		}
+		if !editor.is_buffer_index_finished(d.job) {
+			running += 1
+			continue
+		}
+		if d.ready != nil {
+			editor.destroy_buffer_index(d.ready)
+		}
+		editor.finish_buffer_index(d.job)
+		d.ready, d.version = d.job, d.job_version
+		d.job = nil
+	}
+
+	open := make(map[int]bool, allocator = context.temp_allocator)
+	open[state.doc_id] = true
+	if state.disk.view == .Text {
+		start_index(state, state.doc_id, &state.buffer, &state.undo, &running)
+	}
+	for &doc, i in state.documents {
+		if i != state.active {
+			open[doc.id] = true
+			if doc.disk.view == .Text {
+				start_index(state, doc.id, &doc.buffer, &doc.undo, &running)
+			}
+		}
+	}
+	closed := make([dynamic]int, context.temp_allocator)
+	for id, &d in state.index.docs {
+		if id not_in open {
+			destroy_document_index(&d)
+			append(&closed, id)
+		}
+	}
+	for id in closed {
+		delete_key(&state.index.docs, id)
+	}
+}
+
+// The index to use for document id's words and symbols: one built from its
+// text as it is, or for a large buffer the latest one.  Nil to scan instead.
+usable_index :: proc(
+	state: ^Editor_State,
+	id: int,
+	h: ^editor.Undo_History,
+	size: int,
+) -> ^editor.Buffer_Index {
+	d, ok := state.index.docs[id]
+	if !ok || d.ready == nil {
+		return nil
+	}
+	if d.version == h.version || size >= INDEX_SCAN_SIZE {
+		return d.ready
+	}
+	return nil
+}
+
+// symbol.goto: lists the active buffer's definitions whose names start with
+// what is typed; enter jumps to the first, or to the one picked in the panel.
+goto_symbol :: proc(state: ^Editor_State) {
+	open_prompt(
+		state,
+		"Go to symbol:",
+		on_submit = proc(state: ^Editor_State, text: string) {
+			item := editor.panel_selected_item(state.panel_data)
+			if !state.index.listing || item == nil {
+				return
+			}
+			record_jump(state)
+			goto_line_col(state, item.line, item.col)
+		},
+		on_change = list_symbols,
+	)
+	list_symbols(state, "")
+}
+
+// Enter in the symbol list.
+goto_symbol_entry :: proc(state: ^Editor_State) {
+	item := editor.panel_selected_item(state.panel_data)
+	if item == nil {
+		return
+	}
+	record_jump(state)
+	goto_line_col(state, item.line, item.col)
+	state.mode = "editor"
+}
+
+@(private = "file")
+list_symbols :: proc(state: ^Editor_State, prefix: string) {
+	index := current_index(state)
+	clear_replace(state)
+	stop_project_search(state)
+	release_panel(state)
+	state.index.listing = true
+	panel := state.panel_data
+	editor.panel_clear(panel)
+	symbols := editor.index_symbols_with_prefix(index, prefix)
+	title := fmt.tprintf("Symbols in %s (%d)", document_title(state.path), len(symbols))
+	editor.panel_set_title(panel, title)
+	for s in symbols {
+		text := fmt.tprintf("%-32s %-8v %d", s.name, s.kind, s.line + 1)
+		editor.panel_add_item(panel, {text = text, line = s.line, col = s.col})
+	}
+	show_panel(state)
+}
+
+// The active buffer's index, built now when there is none yet.
+@(private = "file")
+current_index :: proc(state: ^Editor_State) -> ^editor.Buffer_Index {
+	size := editor.current_length(&state.buffer)
+	if index := usable_index(state, state.doc_id, &state.undo, size); index != nil {
+		return index
+	}
+	d := document_index(state, state.doc_id)
+	if d.job == nil || d.job_version != state.undo.version {
+		if d.job != nil {
+			editor.destroy_buffer_index(d.job)
+		}
+		text := editor.get_text(&state.buffer, context.temp_allocator)
+		d.job = editor.start_buffer_index(text)
+		d.job_version = state.undo.version
+		d.started = time.tick_now()
+	}
+	editor.finish_buffer_index(d.job)
+	if d.ready != nil {
+		editor.destroy_buffer_index(d.ready)
+	}
+	d.ready, d.version = d.job, d.job_version
+	d.job = nil
+	return d.ready
+}
+
+// Starts indexing a buffer whose index is behind, unless one is running for
+// it, it changed too recently or enough are running already.
+@(private = "file")
+start_index :: proc(
+	state: ^Editor_State,
+	id: int,
+	gb: ^editor.Gap_Buffer,
+	h: ^editor.Undo_History,
+	running: ^int,
+) {
+	d := document_index(state, id)
+	if id == state.doc_id {
+		d.evicted = false
+	}
+	if d.evicted || d.job != nil || (d.ready != nil && d.version == h.version) {
+		return
+	}
+	if running^ >= INDEX_MAX_RUNNING {
+		return
+	}
+	if time.tick_since(d.started) < INDEX_INTERVAL {
+		return
+	}
+	d.job = editor.start_buffer_index(editor.get_text(gb, context.temp_allocator))
+	d.job_version = h.version
+	d.started = time.tick_now()
+	running^ += 1
+}
+
+// Frees a background buffer's index until it is shown again; its words are
+// scanned meanwhile.
+evict_index :: proc(state: ^Editor_State, id: int) {
+	if id in state.index.docs {
+		d := &state.index.docs[id]
+		destroy_document_index(d)
+		d^ = {evicted = true}
+	}
+}
+
+@(private = "file")
+document_index :: proc(state: ^Editor_State, id: int) -> ^Document_Index {
+	if id not_in state.index.docs {
+		state.index.docs[id] = {}
+	}
+	return &state.index.docs[id]
+}
+
+@(private = "file")
+destroy_document_index :: proc(d: ^Document_Index) {
+	if d.job != nil {
+		editor.destroy_buffer_index(d.job)
+	}
+	if d.ready != nil {
+		editor.destroy_buffer_index(d.ready)
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import "core:encoding/base64"
+import "core:fmt"
+import "core:mem"
+import "core:os"
+import "core:strings"
+import editor "editor"
+import "vendor:glfw"
+
+// Something copied or cut, kept so it can be pasted again after later copies.
+Clipboard_Entry :: struct {
+	text:     string,
+	linewise: bool, // whole lines copied without a selection; pasted above the cursor line
+}
+
+Clipboard_State :: struct {
+	window:  glfw.WindowHandle, // GLFW talks to the system clipboard through it
+	history: [dynamic]Clipboard_Entry, // oldest first
+	active:  bool, // the panel lists the history
+}
+
+init_clipboard :: proc(state: ^Editor_State, window: glfw.WindowHandle) {
+	state.clipboard.window = window
+}
+
+destroy_clipboard :: proc(state: ^Editor_State) {
+	for e in state.clipboard.history {
+		delete(e.text)
+	}
+	delete(state.clipboard.history)
+}
+
+// edit.copy: copies the selection, or the cursor line without one.
+copy_selection :: proc(state: ^Editor_State) {
+	start, end, linewise := copy_range(state)
+	text := copied_text(state, start, end, linewise)
+	if !copy_to_register(state, text, linewise, cut = false) {
+		set_clipboard(state, text, linewise)
+		set_message(state, "Copied %s", describe_text(text))
+	}
+}
+
+// edit.cut: copies like edit.copy and deletes what it copied.
+cut_selection :: proc(state: ^Editor_State) {
+	start, end, linewise := copy_range(state)
+	text := copied_text(state, start, end, linewise)
+	if start == end {
+		return
+	}
+	if !copy_to_register(state, text, linewise, cut = true) {
+		set_clipboard(state, text, linewise)
+	}
+	if linewise && end == editor.current_length(&state.buffer) && start > 0 {
+		start -= 1 // the last line takes the line break before it along
+	}
+	editor.delete_bytes_range(&state.buffer, start, end - start)
+	state.cursor_pos = start
+	state.selection_anchor = -1
+	sync_cursor(state)
+	set_preferred_col(state)
+}
+
+// edit.paste: inserts the system clipboard, or the register picked before,
+// replacing the selection.  Lines copied without a selection go above the
+// cursor line.
+paste_clipboard :: proc(state: ^Editor_State) {
+	if paste_register(state) {
+		return
+	}
+	text := get_clipboard(state)
+	if text == "" {
+		set_message(state, "The clipboard is empty")
+		return
+	}
+	history := state.clipboard.history[:]
+	linewise := false
+	if len(history) > 0 && history[len(history) - 1].text == text {
+		linewise = history[len(history) - 1].linewise
+	} else if !is_large_paste(state, text) {
+		add_clipboard_history(state, text, false) // copied in another program
+	}
+	paste_text(state, text, linewise)
+	if is_large_paste(state, text) {
+		set_message(state, "Pasted %s as it is", describe_text(text))
+	}
+}
+
+// prompt.paste: types the first line of the clipboard into the prompt.
+paste_into_prompt :: proc(state: ^Editor_State) {
+	line, _, _ := strings.partition(get_clipboard(state), "\n")
+	strings.write_string(&state.prompt.input, line)
+	prompt_changed(state)
+}
+
+// edit.paste_history: lists what was copied, newest first; enter pastes the
+// selected entry and makes it the clipboard again.
+show_clipboard_history :: proc(state: ^Editor_State) {
+	if len(state.clipboard.history) == 0 {
+		set_message(state, "Nothing was copied yet")
+		return
+	}
+	clear_replace(state)
+	stop_project_search(state)
+	release_panel(state)
+	state.clipboard.active = true
+	panel := state.panel_data
+	editor.panel_clear(panel)
+	editor.panel_set_title(panel, "Clipboard history; enter pastes")
+	#reverse for e, i in state.clipboard.history {
+		editor.panel_add_item(panel, {text = summarize_text(e.text), line = -1, data = i})
+	}
+	show_panel(state)
+}
+
+// Enter in the clipboard history.
+paste_history_entry :: proc(state: ^Editor_State) {
+	item := editor.panel_selected_item(state.panel_data)
+	if item == nil || item.data >= len(state.clipboard.history) {
+		return
+	}
+	state.clipboard.active = false
+	hide_panel(state)
+	e := state.clipboard.history[item.data]
+	text := strings.clone(e.text, context.temp_allocator)
+	set_clipboard(state, text, e.linewise)
+	if is_read_only(state) {
+		set_message(state, "%s is read-only; copied the entry instead", document_title(state.path))
+		return
+	}
+	paste_text(state, text, e.linewise)
+}
+
+// Puts text on the system clipboard and into the history.  When
+// clipboard.osc52 is on, it also goes to the terminal the editor was started
+// from as an OSC 52 sequence, so copies reach the local machine over ssh.
+set_clipboard :: proc(state: ^Editor_State, text: string, linewise: bool) {
+	glfw.SetClipboardString(
+		state.clipboard.window,
+		strings.clone_to_cstring(text, context.temp_allocator),
+	)
+	add_clipboard_history(state, text, linewise)
+	osc52, set := config_value(state, "clipboard.osc52").(bool)
+	if !set {
+		osc52 = os.get_env("SSH_TTY", context.temp_allocator) != ""
+	}
+	if osc52 {
+		encoded := base64.encode(transmute([]u8)text, allocator = context.temp_allocator)
+		os.write_string(os.stdout, fmt.tprintf("\x1b]52;c;%s\x07", encoded))
+	}
+}
+
+// The system clipboard's text with LF line breaks.  Temp allocated.
+get_clipboard :: proc(state: ^Editor_State) -> string {
+	text := glfw.GetClipboardString(state.clipboard.window)
+	return editor.normalize_line_endings(text, context.temp_allocator)
+}
+
+// Records a copy as the newest entry; an equal older one moves up instead of
+// repeating.  clipboard.history caps the entries kept.
+@(private = "file")
+add_clipboard_history :: proc(state: ^Editor_State, text: string, linewise: bool) {
+	h := &state.clipboard
+	for e, i in h.history {
+		if e.text == text {
+			delete(e.text)
+			ordered_remove(&h.history, i)
+			break
+		}
+	}
+	append(&h.history, Clipboard_Entry{text = strings.clone(text), linewise = linewise})
+	for len(h.history) > config_int(state, "clipboard.history") {
+		delete(h.history[0].text)
+		ordered_remove(&h.history, 0)
+	}
+	if state.clipboard.active && state.panel_data.visible {
+		show_clipboard_history(state)
+	}
+}
+
+// Inserts text at the cursor, or above the cursor line when it is linewise
+// and nothing is selected.  By editor.paste_reindent a block of lines moves
+// to the indentation where it lands; a paste past editor.large_paste goes in
+// as it is, in one insertion.
+paste_text :: proc(state: ^Editor_State, text: string, linewise: bool) {
+	text := text
+	reindent := config_bool(state, "editor.paste_reindent") && !is_large_paste(state, text)
+	if !linewise || has_selection(state) {
+		delete_selection(state)
+		if reindent {
+			text = reindent_paste(state, text, false)
+		}
+		insert_bytes_at_cursor(state, transmute([]u8)text)
+		return
+	}
+	if reindent {
+		text = reindent_paste(state, text, true)
+	}
+	start := editor.line_col_to_logical_pos(&state.buffer, state.cursor_data.line, 0)
+	editor.move_gap(&state.buffer, start)
+	editor.insert_bytes(&state.buffer, transmute([]u8)text)
+	state.cursor_pos += len(text)
+	sync_cursor(state)
+	set_preferred_col(state)
+}
+
+// Whether text is past editor.large_paste, too large to reindent or keep in
+// the clipboard history.
+is_large_paste :: proc(state: ^Editor_State, text: string) -> bool {
+	return len(text) > config_int(state, "editor.large_paste") * mem.Kilobyte
+}
+
+// text indented for where it lands.  Linewise, above the cursor line, its
+// first non-blank line takes the indentation edit.newline would give it
+// there.  At a cursor in the indentation its first line takes the cursor's
+// column; after text the first line ends the cursor's and the others line up
+// with the cursor line.  The lines keep their indentation relative to one
+// another.  Temp allocated.
+@(private = "file")
+reindent_paste :: proc(state: ^Editor_State, text: string, linewise: bool) -> string {
+	if !strings.contains_rune(strings.trim_right(text, "\n"), '\n') {
+		return text // a single line
+	}
+	style := indent_style(state)
+	lines := strings.split(text, "\n", context.temp_allocator)
+	current := editor.get_line(&state.buffer, state.cursor_data.line, context.temp_allocator)
+	before := current[:min(state.cursor_data.col, len(current))]
+	first, least := -1, max(int)
+	for line, i in lines {
+		if strings.trim_space(line) == "" {
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+		if i > 0 {
+			least = min(least, editor.indent_columns(line, style.width))
+		}
+	}
+	switch {
+	case first < 0:
+		return text
+	case linewise:
+		lang := editor.find_language(state.language)
+		content := strings.trim_left(lines[first], " \t")
+		to := editor.compute_indent(&state.buffer, lang, state.cursor_data.line, content, style)
+		by := to - editor.indent_columns(lines[first], style.width)
+		return editor.shift_block_indent(text, 0, by, style)
+	case strings.trim_space(before) == "":
+		by := state.cursor_data.visual_col - editor.indent_columns(lines[first], style.width)
+		shifted := editor.shift_block_indent(text, 0, by, style)
+		return strings.trim_left(shifted, " \t") // the cursor's indentation is there already
+	case least < max(int):
+		by := editor.indent_columns(current, style.width) - least
+		return editor.shift_block_indent(text, 1, by, style)
+	}
+	return text
+}
+
+// The selection, or the cursor line with its line break.
+@(private = "file")
+copy_range :: proc(state: ^Editor_State) -> (start, end: int, linewise: bool) {
+	if has_selection(state) {
+		start, end = selection_range(state)
+		return start, end, false
+	}
+	line := state.cursor_data.line
+	start = editor.line_col_to_logical_pos(&state.buffer, line, 0)
+	end = editor.current_length(&state.buffer)
+	if line + 1 < editor.get_line_count(&state.buffer) {
+		end = editor.line_col_to_logical_pos(&state.buffer, line + 1, 0)
+	}
+	return start, end, true
+}
+
+// Temp allocated.
+@(private = "file")
+copied_text :: proc(state: ^Editor_State, start, end: int, linewise: bool) -> string {
+	text := editor.get_text_segment(&state.buffer, start, end - start, context.temp_allocator)
+	if linewise && !strings.has_suffix(text, "\n") {
+		text = strings.concatenate({text, "\n"}, context.temp_allocator)
+	}
+	return text
+}
+
+// The first line of text, and how long it is when there is more.  Temp
+// allocated.
+summarize_text :: proc(text: string) -> string {
+	first, _, rest := strings.partition(strings.trim_left_space(text), "\n")
+	first = strings.trim_right_space(first)
+	if strings.trim_space(rest) == "" {
+		return first
+	}
+	return fmt.tprintf("%s  (%s)", first, describe_text(text))
+}
+
+// "n lines" or "n characters".  Temp allocated.
+describe_text :: proc(text: string) -> string {
+	lines := strings.count(strings.trim_right(text, "\n"), "\n") + 1
+	if lines > 1 {
+		return fmt.tprintf("%d lines", lines)
+	}
+	return fmt.tprintf("%d characters", strings.rune_count(text))
+}
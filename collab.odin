@@ -0,0 +1,724 @@
+package main
+
+import "core:encoding/hex"
+import "core:fmt"
+import "core:net"
+import "core:os"
+import "core:strconv"
+import "core:strings"
+import editor "editor"
+
+COLLAB_PROTOCOL :: "RUNE-COLLAB/1"
+COLLAB_HOST_PEER :: 1
+COLLAB_MAX_LINE :: 64 * 1024 * 1024 // a snapshot run may be a whole file, hex encoded
+
+// Caret colors of the other people, by peer id.
+PEER_COLORS := [?][4]f32 {
+	{0.90, 0.45, 0.45, 1.0},
+	{0.45, 0.80, 0.50, 1.0},
+	{0.95, 0.75, 0.35, 1.0},
+	{0.70, 0.55, 0.95, 1.0},
+	{0.40, 0.80, 0.90, 1.0},
+	{0.95, 0.55, 0.80, 1.0},
+}
+
+// collab.host shares the active buffer over TCP; others connect to it with
+// collab.join and the host relays everything between them.  The buffer is
+// kept as an editor.Crdt_Text, so edits made at the same time on different
+// machines merge the same way everywhere.  Messages are lines:
+//
+//	hello RUNE-COLLAB/1 <name>        guest: the first line
+//	welcome <peer> <title>            host: the guest's id, then a snapshot
+//	run <id> <0|1 deleted> <hex>      host: a run of the snapshot
+//	ready                             host: the snapshot is complete
+//	ins <id> <origin id> <hex>        an insert, see editor.Crdt_Op
+//	del <id> <count>                  a delete
+//	cur <peer> <anchor> <head> <name> where someone's selection is
+//	bye <peer>                        host: a guest left
+//	error <reason>                    the connection closes after it
+//
+// Ids are written <clock>.<peer>.
+Collab_Role :: enum u8 {
+	None,
+	Host,
+	Guest,
+}
+
+// A connection: to a guest for the host, to the host for a guest.
+Collab_Link :: struct {
+	socket: net.TCP_Socket,
+	peer:   u32, // the other end's id; 0 for a guest that has not said hello
+	name:   string,
+	outbox: [dynamic]u8, // not yet sent
+	inbox:  [dynamic]u8, // received, up to an incomplete line
+	closed: bool,
+}
+
+// Someone else's selection, as the ids of the bytes before its ends so it
+// stays on the same text while anyone edits.
+Collab_Cursor :: struct {
+	name:   string,
+	anchor: editor.Crdt_Id,
+	head:   editor.Crdt_Id,
+}
+
+Collab_State :: struct {
+	role:      Collab_Role,
+	listener:  net.TCP_Socket, // Host
+	links:     [dynamic]Collab_Link,
+	text:      editor.Crdt_Text, // the shared buffer's
+	title:     string, // the shared buffer's name
+	ready:     bool, // the shared buffer exists; a guest waits for the snapshot
+	doc_id:    int, // the shared document
+	version:   int, // its undo version when text last matched it
+	next_peer: u32, // Host: the id of the next guest
+	cursors:   map[u32]Collab_Cursor, // everyone else's
+	sent:      [2]int, // the anchor and cursor last announced
+	shown_doc: int, // the document the peer cursors were laid out for
+	dirty:     bool, // the peer cursors need laying out again
+}
+
+// collab.host: shares the active buffer on collab.port.  Guests are not
+// asked for anything, so only this machine can join unless collab.address
+// says otherwise.
+host_collab :: proc(state: ^Editor_State) {
+	c := &state.collab
+	if c.role != .None {
+		set_message(state, "Already in a session; collab.leave first")
+		return
+	}
+	if is_read_only(state) {
+		set_message(state, "%s is read-only", document_title(state.path))
+		return
+	}
+	port := config_int(state, "collab.port")
+	address_text := config_value(state, "collab.address").(string) or_else ""
+	address := net.parse_address(address_text)
+	if address == nil {
+		set_message(state, "collab.address %q is not an IP address", address_text)
+		return
+	}
+	listener, err := net.listen_tcp({address = address, port = port})
+	if err != nil {
+		set_message(state, "Cannot listen on %s port %d: %v", address_text, port, err)
+		return
+	}
+	net.set_blocking(listener, false)
+	c.role = .Host
+	c.listener = listener
+	c.next_peer = COLLAB_HOST_PEER + 1
+	text := editor.get_text(&state.buffer, context.temp_allocator)
+	c.text = editor.init_crdt_text(COLLAB_HOST_PEER, text)
+	c.title = strings.clone(document_title(state.path))
+	start_sharing(state)
+	set_message(state, "Sharing %s on %s port %d", c.title, address_text, port)
+}
+
+// collab.join: connects to a host and opens its buffer once it arrives.
+join_collab :: proc(state: ^Editor_State) {
+	if state.collab.role != .None {
+		set_message(state, "Already in a session; collab.leave first")
+		return
+	}
+	open_prompt(state, "Join (host[:port]):", proc(state: ^Editor_State, address: string) {
+		address := strings.trim_space(address)
+		if address == "" {
+			return
+		}
+		if !strings.contains_rune(address, ':') {
+			port := config_int(state, "collab.port")
+			address = fmt.tprintf("%s:%d", address, port)
+		}
+		socket, err := net.dial_tcp_from_hostname_and_port_string(address)
+		if err != nil {
+			set_message(state, "Cannot connect to %s: %v", address, err)
+			return
+		}
+		net.set_blocking(socket, false)
+		c := &state.collab
+		c.role = .Guest
+		append(&c.links, Collab_Link{socket = socket, peer = COLLAB_HOST_PEER})
+		send_line(&c.links[0], "hello %s %s", COLLAB_PROTOCOL, collab_name(state))
+		set_message(state, "Joining %s...", address)
+	})
+}
+
+// collab.leave: stops sharing, or leaves the host's session.  The buffer
+// stays open.
+leave_collab :: proc(state: ^Editor_State) {
+	if state.collab.role == .None {
+		set_message(state, "Not in a session")
+		return
+	}
+	end_collab(state)
+	set_message(state, "Left the session")
+}
+
+destroy_collab :: proc(state: ^Editor_State) {
+	if state.collab.role != .None {
+		end_collab(state)
+	}
+	delete(state.collab.links)
+	delete(state.collab.cursors)
+}
+
+// Called every frame: takes in new guests, sends the edits made to the
+// shared buffer and where the cursor is, and applies everyone else's.
+poll_collab :: proc(state: ^Editor_State) {
+	c := &state.collab
+	if c.role == .None {
+		return
+	}
+	if c.role == .Host {
+		accept_guests(state)
+	}
+	if c.ready && !share_local_edits(state) {
+		end_collab(state)
+		set_message(state, "The shared buffer was closed; left the session")
+		return
+	}
+	for i := 0; i < len(c.links); i += 1 {
+		receive_lines(state, i)
+		if c.role == .None {
+			return
+		}
+	}
+	if c.ready {
+		share_cursor(state)
+	}
+	for &l in c.links {
+		flush_link(&l)
+	}
+	drop_closed_links(state)
+	if c.role != .None && (c.dirty || c.shown_doc != state.doc_id) {
+		layout_peer_cursors(state)
+	}
+}
+
+// Closes every connection and forgets the session.
+@(private = "file")
+end_collab :: proc(state: ^Editor_State) {
+	c := &state.collab
+	for &l in c.links {
+		flush_link(&l) // a last error or bye, best effort
+		close_link(&l)
+	}
+	clear(&c.links)
+	if c.role == .Host {
+		net.close(c.listener)
+	}
+	for _, cursor in c.cursors {
+		delete(cursor.name)
+	}
+	clear(&c.cursors)
+	editor.destroy_crdt_text(&c.text)
+	delete(c.title)
+	links, cursors := c.links, c.cursors
+	c^ = {}
+	c.links, c.cursors = links, cursors
+	editor.clear_peer_cursors(state.peer_cursor_data)
+}
+
+// Makes the active document the shared one.
+@(private = "file")
+start_sharing :: proc(state: ^Editor_State) {
+	c := &state.collab
+	c.ready = true
+	c.doc_id = state.doc_id
+	c.version = state.undo.version
+	c.sent = {-2, -2}
+	c.dirty = true
+}
+
+@(private = "file")
+collab_name :: proc(state: ^Editor_State) -> string {
+	if name := config_value(state, "collab.name").(string) or_else ""; name != "" {
+		return name
+	}
+	if user := os.get_env("USER", context.temp_allocator); user != "" {
+		return user
+	}
+	return "guest"
+}
+
+@(private = "file")
+accept_guests :: proc(state: ^Editor_State) {
+	c := &state.collab
+	for {
+		client, _, err := net.accept_tcp(c.listener)
+		if err != .None {
+			return // none waiting
+		}
+		net.set_blocking(client, false)
+		append(&c.links, Collab_Link{socket = client})
+	}
+}
+
+// The shared document's buffer and history, and its slot when it is parked.
+@(private = "file")
+shared_buffer :: proc(
+	state: ^Editor_State,
+) -> (
+	buffer: ^editor.Gap_Buffer,
+	undo: ^editor.Undo_History,
+	doc: ^Document,
+	ok: bool,
+) {
+	if state.doc_id == state.collab.doc_id {
+		return &state.buffer, &state.undo, nil, true
+	}
+	for &d in state.documents {
+		if d.id == state.collab.doc_id {
+			return &d.buffer, &d.undo, &d, true
+		}
+	}
+	return nil, nil, nil, false
+}
+
+// Sends the changes made to the shared buffer since the last call.  Any
+// change is compared as a whole, so undo, redo and every command count.
+// Returns false once the buffer is closed.
+@(private = "file")
+share_local_edits :: proc(state: ^Editor_State) -> bool {
+	c := &state.collab
+	buffer, undo, _, ok := shared_buffer(state)
+	if !ok {
+		return false
+	}
+	if undo.version == c.version {
+		return true
+	}
+	c.version = undo.version
+	old := editor.crdt_string(&c.text)
+	new := editor.get_text(buffer, context.temp_allocator)
+	start, old_end, new_end := changed_span(old, new)
+	ops := make([dynamic]editor.Crdt_Op, context.temp_allocator)
+	editor.crdt_delete(&c.text, start, old_end - start, &ops)
+	editor.crdt_insert(&c.text, start, new[start:new_end], &ops)
+	for op in ops {
+		broadcast(state, op_line(op), 0)
+	}
+	c.dirty = true
+	return true
+}
+
+// Announces where the cursor is when it moved in the shared buffer.
+@(private = "file")
+share_cursor :: proc(state: ^Editor_State) {
+	c := &state.collab
+	now := [2]int{state.selection_anchor, state.cursor_pos}
+	if state.doc_id != c.doc_id || now == c.sent {
+		return
+	}
+	c.sent = now
+	anchor := state.selection_anchor if state.selection_anchor >= 0 else state.cursor_pos
+	line := fmt.tprintf(
+		"cur %d %s %s %s",
+		c.text.peer,
+		id_text(editor.crdt_id_before(&c.text, anchor)),
+		id_text(editor.crdt_id_before(&c.text, state.cursor_pos)),
+		collab_name(state),
+	)
+	broadcast(state, line, 0)
+}
+
+// Brings the shared buffer up to date after others' edits, keeping the
+// cursor and selection on the same text.  Their edits join the undo history,
+// so undoing one undoes it for everyone.
+@(private = "file")
+sync_shared_buffer :: proc(state: ^Editor_State) {
+	c := &state.collab
+	buffer, undo, doc, ok := shared_buffer(state)
+	if !ok {
+		return
+	}
+	old := editor.get_text(buffer, context.temp_allocator)
+	new := editor.crdt_string(&c.text)
+	start, old_end, new_end := changed_span(old, new)
+	if old_end == start && new_end == start {
+		return
+	}
+	cursor, anchor := &state.cursor_pos, &state.selection_anchor
+	if doc != nil {
+		cursor, anchor = &doc.cursor_pos, &doc.selection_anchor
+	}
+	attached := buffer.undo
+	buffer.undo = undo // a parked buffer has its history detached
+	editor.replace_bytes(buffer, start, old_end - start, transmute([]u8)new[start:new_end])
+	buffer.undo = attached
+	c.version = undo.version
+
+	// Positions in the replaced text move to its end.
+	shift := proc(pos, old_end, new_end: int) -> int {
+		if pos >= old_end {
+			return pos + new_end - old_end
+		}
+		return min(pos, new_end)
+	}
+	cursor^ = shift(cursor^, old_end, new_end)
+	if anchor^ >= 0 {
+		anchor^ = shift(anchor^, old_end, new_end)
+	}
+	if doc == nil {
+		sync_cursor(state)
+	}
+	c.dirty = true
+}
+
+@(private = "file")
+receive_lines :: proc(state: ^Editor_State, i: int) {
+	c := &state.collab
+	buf: [4096]u8
+	for len(c.links[i].inbox) < COLLAB_MAX_LINE {
+		n, err := net.recv_tcp(c.links[i].socket, buf[:])
+		if err == .Would_Block {
+			break
+		}
+		if err != .None || n == 0 {
+			c.links[i].closed = true
+			break
+		}
+		append(&c.links[i].inbox, ..buf[:n])
+	}
+	for c.role != .None && i < len(c.links) {
+		l := &c.links[i]
+		end := -1
+		for b, k in l.inbox {
+			if b == '\n' {
+				end = k
+				break
+			}
+		}
+		if end < 0 {
+			if len(l.inbox) >= COLLAB_MAX_LINE {
+				send_line(l, "error a line is longer than %d bytes", COLLAB_MAX_LINE)
+				l.closed = true
+				clear(&l.inbox)
+			}
+			return
+		}
+		line := strings.clone(string(l.inbox[:end]), context.temp_allocator)
+		remove_range(&l.inbox, 0, end + 1)
+		handle_line(state, i, line)
+	}
+}
+
+@(private = "file")
+handle_line :: proc(state: ^Editor_State, from: int, line: string) {
+	c := &state.collab
+	l := &c.links[from]
+	fields := strings.fields(line, context.temp_allocator)
+	if len(fields) == 0 || (c.role == .Host && l.peer == 0 && fields[0] != "hello") {
+		return
+	}
+	switch fields[0] {
+	case "hello":
+		if c.role != .Host || l.peer != 0 || len(fields) < 2 {
+			return
+		}
+		if fields[1] != COLLAB_PROTOCOL {
+			send_line(l, "error %s is not spoken here, only %s", fields[1], COLLAB_PROTOCOL)
+			l.closed = true
+			return
+		}
+		welcome_guest(state, from, strings.join(fields[2:], " ", context.temp_allocator))
+	case "welcome":
+		peer, ok := parse_peer(fields[1] if len(fields) > 1 else "")
+		if c.role != .Guest || !ok || c.text.peer != 0 {
+			return
+		}
+		c.text = editor.init_crdt_text(peer, "")
+		c.title = strings.join(fields[2:], " ")
+	case "run":
+		if c.role != .Guest || c.ready || len(fields) < 4 {
+			return
+		}
+		id, id_ok := parse_id(fields[1])
+		text, text_ok := decode_text(fields[3])
+		if id_ok && text_ok {
+			editor.crdt_load_run(&c.text, {id = id, deleted = fields[2] == "1", text = text})
+		}
+	case "ready":
+		if c.role == .Guest && !c.ready && c.text.peer != 0 {
+			open_shared_buffer(state)
+		}
+	case "ins", "del":
+		op, ok := parse_op(fields)
+		if !ok || !c.ready {
+			return
+		}
+		if c.role == .Host && op.kind == .Insert && op.id.peer != l.peer {
+			return // a guest inserts only under its own id
+		}
+		editor.crdt_apply(&c.text, op)
+		sync_shared_buffer(state)
+		broadcast(state, line, l.peer)
+	case "cur":
+		if len(fields) < 4 {
+			return
+		}
+		peer, peer_ok := parse_peer(fields[1])
+		anchor, anchor_ok := parse_id(fields[2])
+		head, head_ok := parse_id(fields[3])
+		if !peer_ok || !anchor_ok || !head_ok || peer == c.text.peer {
+			return
+		}
+		if c.role == .Host && peer != l.peer {
+			return // a guest speaks only for itself
+		}
+		name := strings.join(fields[4:], " ", context.temp_allocator)
+		if old, found := c.cursors[peer]; found {
+			delete(old.name)
+		}
+		c.cursors[peer] = {strings.clone(name), anchor, head}
+		c.dirty = true
+		broadcast(state, line, l.peer)
+	case "bye":
+		if c.role != .Guest {
+			return
+		}
+		if peer, ok := parse_peer(fields[1] if len(fields) > 1 else ""); ok {
+			forget_cursor(state, peer)
+		}
+	case "error":
+		if c.role != .Guest {
+			return
+		}
+		reason := strings.join(fields[1:], " ", context.temp_allocator)
+		end_collab(state)
+		set_message(state, "Session ended: %s", reason)
+	}
+}
+
+// Gives a guest its id and the whole buffer, and tells it where everyone is.
+@(private = "file")
+welcome_guest :: proc(state: ^Editor_State, from: int, name: string) {
+	c := &state.collab
+	l := &c.links[from]
+	l.peer = c.next_peer
+	l.name = strings.clone(name)
+	c.next_peer += 1
+	send_line(l, "welcome %d %s", l.peer, c.title)
+	for run in editor.crdt_runs(&c.text) {
+		send_line(l, "run %s %d %s", id_text(run.id), int(run.deleted), encode_text(run.text))
+	}
+	send_line(l, "ready")
+	for peer, cursor in c.cursors {
+		line := fmt.tprintf(
+			"cur %d %s %s %s",
+			peer,
+			id_text(cursor.anchor),
+			id_text(cursor.head),
+			cursor.name,
+		)
+		send_line(l, "%s", line)
+	}
+	c.sent = {-2, -2} // and where the host is
+	set_message(state, "%s joined", name)
+}
+
+// Opens the host's buffer in a new document once its snapshot arrived.
+@(private = "file")
+open_shared_buffer :: proc(state: ^Editor_State) {
+	c := &state.collab
+	new_document(state)
+	text := editor.crdt_string(&c.text)
+	editor.insert_bytes(&state.buffer, transmute([]u8)text)
+	editor.clear_undo_history(&state.undo)
+	state.cursor_pos = 0
+	sync_cursor(state)
+	start_sharing(state)
+	set_message(state, "Joined %s", c.title)
+}
+
+@(private = "file")
+forget_cursor :: proc(state: ^Editor_State, peer: u32) {
+	c := &state.collab
+	if cursor, found := c.cursors[peer]; found {
+		delete(cursor.name)
+		delete_key(&c.cursors, peer)
+		c.dirty = true
+	}
+}
+
+@(private = "file")
+drop_closed_links :: proc(state: ^Editor_State) {
+	c := &state.collab
+	for i := len(c.links) - 1; i >= 0; i -= 1 {
+		l := &c.links[i]
+		if !l.closed {
+			continue
+		}
+		if c.role == .Guest {
+			end_collab(state)
+			set_message(state, "The host ended the session")
+			return
+		}
+		peer, name := l.peer, strings.clone(l.name, context.temp_allocator)
+		close_link(l)
+		ordered_remove(&c.links, i)
+		if peer != 0 {
+			forget_cursor(state, peer)
+			broadcast(state, fmt.tprintf("bye %d", peer), 0)
+			set_message(state, "%s left", name)
+		}
+	}
+}
+
+// Fills the peer cursor layer while the shared buffer is shown.
+@(private = "file")
+layout_peer_cursors :: proc(state: ^Editor_State) {
+	c := &state.collab
+	c.dirty = false
+	c.shown_doc = state.doc_id
+	d := state.peer_cursor_data
+	editor.clear_peer_cursors(d)
+	if !c.ready || state.doc_id != c.doc_id {
+		return
+	}
+	tab := state.layer_ctx.tab_size
+	for peer, cursor in c.cursors {
+		anchor := editor.crdt_position(&c.text, cursor.anchor)
+		head := editor.crdt_position(&c.text, cursor.head)
+		sl, sc := editor.logical_pos_to_line_col(&state.buffer, min(anchor, head))
+		el, ec := editor.logical_pos_to_line_col(&state.buffer, max(anchor, head))
+		hl, hc := editor.logical_pos_to_line_col(&state.buffer, head)
+		editor.add_peer_cursor(
+			d,
+			{
+				name = cursor.name,
+				color = PEER_COLORS[int(peer - 1) % len(PEER_COLORS)],
+				line = hl,
+				col = editor.get_visual_col(&state.buffer, hl, hc, tab),
+				selection = {
+					start_line = sl,
+					start_col = editor.get_visual_col(&state.buffer, sl, sc, tab),
+					end_line = el,
+					end_col = editor.get_visual_col(&state.buffer, el, ec, tab),
+				},
+			},
+		)
+	}
+}
+
+// Sends line to every welcomed link but the one of peer except.
+@(private = "file")
+broadcast :: proc(state: ^Editor_State, line: string, except: u32) {
+	for &l in state.collab.links {
+		if l.peer != 0 && l.peer != except {
+			send_line(&l, "%s", line)
+		}
+	}
+}
+
+@(private = "file")
+send_line :: proc(l: ^Collab_Link, format: string, args: ..any) {
+	append(&l.outbox, fmt.tprintf(format, ..args))
+	append(&l.outbox, '\n')
+}
+
+@(private = "file")
+flush_link :: proc(l: ^Collab_Link) {
+	if len(l.outbox) == 0 || l.closed {
+		return
+	}
+	n, err := net.send_tcp(l.socket, l.outbox[:])
+	remove_range(&l.outbox, 0, n)
+	if err != .None && err != .Would_Block {
+		l.closed = true
+	}
+}
+
+@(private = "file")
+close_link :: proc(l: ^Collab_Link) {
+	net.close(l.socket)
+	delete(l.outbox)
+	delete(l.inbox)
+	delete(l.name)
+}
+
+// The byte range replaced between old and new: everything but their common
+// start and end.
+@(private = "file")
+changed_span :: proc(old, new: string) -> (start, old_end, new_end: int) {
+	for start < len(old) && start < len(new) && old[start] == new[start] {
+		start += 1
+	}
+	old_end, new_end = len(old), len(new)
+	for old_end > start && new_end > start && old[old_end - 1] == new[new_end - 1] {
+		old_end -= 1
+		new_end -= 1
+	}
+	return
+}
+
+@(private = "file")
+op_line :: proc(op: editor.Crdt_Op) -> string {
+	switch op.kind {
+	case .Insert:
+		return fmt.tprintf(
+			"ins %s %s %s",
+			id_text(op.id),
+			id_text(op.origin),
+			encode_text(op.text),
+		)
+	case .Delete:
+		return fmt.tprintf("del %s %d", id_text(op.id), op.count)
+	}
+	return ""
+}
+
+@(private = "file")
+parse_op :: proc(fields: []string) -> (op: editor.Crdt_Op, ok: bool) {
+	if len(fields) < 3 {
+		return
+	}
+	op.id = parse_id(fields[1]) or_return
+	if fields[0] == "ins" {
+		if len(fields) < 4 {
+			return
+		}
+		op.kind = .Insert
+		op.origin = parse_id(fields[2]) or_return
+		op.text = decode_text(fields[3]) or_return
+		return op, op.text != ""
+	}
+	count := strconv.parse_int(fields[2], 10) or_return
+	op.kind = .Delete
+	op.count = count
+	return op, count > 0
+}
+
+@(private = "file")
+id_text :: proc(id: editor.Crdt_Id) -> string {
+	return fmt.tprintf("%d.%d", id.clock, id.peer)
+}
+
+@(private = "file")
+parse_id :: proc(s: string) -> (id: editor.Crdt_Id, ok: bool) {
+	clock_text, _, peer_text := strings.partition(s, ".")
+	clock := strconv.parse_uint(clock_text, 10) or_return
+	peer := strconv.parse_uint(peer_text, 10) or_return
+	return {u32(clock), u32(peer)}, true
+}
+
+// A peer id: a number that fits a u32 and is not 0, which no peer has.
+@(private = "file")
+parse_peer :: proc(s: string) -> (peer: u32, ok: bool) {
+	n := strconv.parse_uint(s, 10) or_return
+	if n == 0 || n > uint(max(u32)) {
+		return 0, false
+	}
+	return u32(n), true
+}
+
+@(private = "file")
+encode_text :: proc(text: string) -> string {
+	return string(hex.encode(transmute([]u8)text, context.temp_allocator))
+}
+
+@(private = "file")
+decode_text :: proc(s: string) -> (string, bool) {
+	bytes, ok := hex.decode(transmute([]u8)s, context.temp_allocator)
+	return string(bytes), ok
+}
@@ -0,0 +1,197 @@
+package main
+
+import "core:fmt"
+import "core:time"
+import editor "editor"
+
+// Languages whose color literals get a swatch.
+COLOR_SWATCH_LANGUAGES := [?]string{"css", "html", "toml", "yaml", "json"}
+
+// Swatches under the #RRGGBB, rgb() and hsl() literals of style sheets and
+// config files, and color.edit to pick a new color for one: the swatch shows
+// the color typed as it is typed, and enter writes it back in the literal's
+// own format.  In any language, a rune.document_color provider can report
+// colors however they are written, which get swatches too; color.edit asks
+// it how to write the one picked.
+Color_State :: struct {
+	shown:    bool, // the swatch layer is enabled
+	editing:  editor.Color_Literal, // the literal color.edit rewrites
+	line:     int, // and its line
+	reported: bool, // editing is a reported color, not a literal
+	asked:    [2]int, // doc_id and undo version the reported colors are for
+	provider: Color_Provider, // that reported them
+	encoding: editor.Position_Encoding, // of its positions
+}
+
+// Shows the swatches in the languages that have them, and asks for the
+// reported colors once typing pauses.  Called every frame.
+update_color_swatches :: proc(state: ^Editor_State) {
+	cs := &state.colors
+	d := state.swatch_data
+	enabled := config_bool(state, "editor.color_swatches")
+	literals := false
+	for id in COLOR_SWATCH_LANGUAGES {
+		if id == state.language {
+			literals = enabled
+		}
+	}
+	if cs.asked[0] != state.doc_id && len(d.reported) > 0 {
+		editor.set_reported_colors(d, nil) // another document's
+		request_redraw(state)
+	}
+	key := [2]int{state.doc_id, state.undo.version}
+	if enabled && len(state.plugins.colorers) > 0 && key != cs.asked &&
+	   time.tick_since(state.frames.last_input) >= INPUT_QUIET {
+		cs.asked = key
+		reply, provider, encoding := plugin_document_colors(state)
+		text := editor.get_text(&state.buffer, context.temp_allocator)
+		colors, ok := editor.parse_document_colors(text, reply, encoding)
+		if reply != "" && !ok {
+			set_message(state, "The color provider answered with something other than JSON")
+		}
+		editor.set_reported_colors(d, colors)
+		cs.provider, cs.encoding = provider, encoding
+		request_redraw(state)
+	}
+	if !enabled && len(d.reported) > 0 {
+		editor.set_reported_colors(d, nil)
+		cs.asked = {}
+	}
+	shown := literals || len(d.reported) > 0
+	d.literals = literals
+	if shown != cs.shown {
+		cs.shown = shown
+		editor.set_layer_enabled(&state.compositor, "color_swatches", shown)
+		request_redraw(state)
+	}
+}
+
+// color.edit: prompts for a new color for the literal at the cursor.
+edit_color :: proc(state: ^Editor_State) {
+	gb := &state.buffer
+	line, col := editor.logical_pos_to_line_col(gb, state.cursor_pos)
+	text := editor.get_line(gb, line, context.temp_allocator)
+	lit, ok := editor.color_literal_at(text, col)
+	initial := text[lit.start:lit.end] if ok else ""
+	state.colors.reported = false
+	if !ok || !state.swatch_data.literals {
+		reported, found := reported_color_at(state, line, col)
+		if found {
+			lit = {reported.start, reported.end, reported.color, .Hex}
+			initial = editor.format_color(reported.color, .Hex)
+			state.colors.reported = true
+		} else if !ok {
+			set_message(state, "No color at the cursor")
+			return
+		}
+	}
+	state.colors.editing = lit
+	state.colors.line = line
+	open_prompt(
+		state,
+		"Color (#hex, rgb() or hsl()):",
+		on_submit = proc(state: ^Editor_State, text: string) {
+			stop_color_preview(state)
+			picked, ok := editor.parse_color(text)
+			if !ok {
+				set_message(state, "Not a color: %s", text)
+				return
+			}
+			if is_read_only(state) {
+				set_message(state, "%s is read-only", document_title(state.path))
+				return
+			}
+			if state.colors.reported {
+				write_reported_color(state, picked.color)
+				return
+			}
+			lit := state.colors.editing
+			gb := &state.buffer
+			start := editor.line_col_to_logical_pos(gb, state.colors.line, lit.start)
+			written := editor.format_color(picked.color, lit.format)
+			editor.begin_undo_group(&state.undo, state.cursor_pos)
+			editor.replace_bytes(gb, start, lit.end - lit.start, transmute([]u8)written)
+			state.selection_anchor = -1
+			state.cursor_pos = start
+			sync_cursor(state)
+			set_preferred_col(state)
+			editor.end_undo_group(&state.undo, state.cursor_pos)
+		},
+		on_change = proc(state: ^Editor_State, text: string) {
+			picked, ok := editor.parse_color(text)
+			d := state.swatch_data
+			d.previewing = ok
+			d.preview = picked.color
+			d.preview_line = state.colors.line
+			d.preview_col = state.colors.editing.start
+			request_redraw(state)
+		},
+		on_cancel = stop_color_preview,
+		initial = initial,
+	)
+}
+
+// The reported color whose text holds col on line.
+@(private = "file")
+reported_color_at :: proc(state: ^Editor_State, line, col: int) -> (editor.Reported_Color, bool) {
+	for r in state.swatch_data.reported {
+		if r.line == line && col >= r.start && col <= r.end {
+			return r, true
+		}
+	}
+	return {}, false
+}
+
+// Rewrites the reported color being edited as color, the way its provider
+// presents it, or as #RRGGBB when it cannot.
+@(private = "file")
+write_reported_color :: proc(state: ^Editor_State, color: [4]f32) {
+	cs := &state.colors
+	gb := &state.buffer
+	line := editor.get_line(gb, cs.line, context.temp_allocator)
+	if cs.editing.end > len(line) {
+		set_message(state, "The color changed while it was edited")
+		return
+	}
+	start := editor.Lsp_Position{cs.line, editor.lsp_character(line, cs.editing.start, cs.encoding)}
+	end := editor.Lsp_Position{cs.line, editor.lsp_character(line, cs.editing.end, cs.encoding)}
+	params := fmt.tprintf(
+		`{"color":{"red":%g,"green":%g,"blue":%g,"alpha":%g},` +
+		`"range":{"start":{"line":%d,"character":%d},"end":{"line":%d,"character":%d}}}`,
+		color.r, color.g, color.b, color.a,
+		start.line, start.character, end.line, end.character,
+	)
+	label, lsp_edits, has_edit, ok := editor.parse_color_presentation(
+		plugin_color_presentation(state, cs.provider, params),
+	)
+	if !ok {
+		label = editor.format_color(color, .Hex)
+	}
+	all := make([dynamic]editor.Lsp_Text_Edit, context.temp_allocator)
+	if !has_edit {
+		append(&all, editor.Lsp_Text_Edit{start = start, end = end, new_text = label})
+	}
+	append(&all, ..lsp_edits)
+	text := editor.get_text(gb, context.temp_allocator)
+	edits, err := editor.lsp_byte_edits(text, all[:], cs.encoding)
+	if err != "" {
+		set_message(state, "Cannot write the color: %s", err)
+		return
+	}
+	at := editor.line_col_to_logical_pos(gb, cs.line, cs.editing.start)
+	editor.begin_undo_group(&state.undo, state.cursor_pos)
+	#reverse for e in edits {
+		editor.replace_bytes(gb, e.pos, e.count, transmute([]u8)e.text)
+	}
+	state.selection_anchor = -1
+	state.cursor_pos = editor.map_position(edits, at)
+	editor.end_undo_group(&state.undo, state.cursor_pos)
+	sync_cursor(state)
+	set_preferred_col(state)
+}
+
+@(private = "file")
+stop_color_preview :: proc(state: ^Editor_State) {
+	state.swatch_data.previewing = false
+	request_redraw(state)
+}
@@ -0,0 +1,127 @@
+package main
+
+import "core:log"
+import "core:os"
+import "core:strconv"
+import "core:strings"
+import editor "editor"
+import "vendor:glfw"
+
+// A file named on the command line and where to put the cursor in it.
+Open_Target :: struct {
+	path: string, // "-" reads stdin into an unnamed buffer
+	line: int, // 1 based; 0 keeps the cursor where the file had it
+	col:  int, // 1 based; 0 is the start of the line
+}
+
+// The targets named by the file arguments: "file:20" or "file:20:5" (the
+// line may be written "+20"), or "+20" / "+20:5" before a file as in vi.  A
+// suffix is only a position when the whole argument names no file.  Temp
+// allocated.
+parse_open_targets :: proc(args: []string) -> []Open_Target {
+	targets := make([dynamic]Open_Target, context.temp_allocator)
+	line, col := 0, 0
+	for arg in args {
+		if strings.has_prefix(arg, "+") {
+			if l, c, ok := parse_position(arg[1:]); ok {
+				line, col = l, c
+				continue
+			}
+		}
+		t := Open_Target {
+			path = arg,
+			line = line,
+			col  = col,
+		}
+		line, col = 0, 0
+		if arg != "-" && !os.exists(arg) {
+			// path:line:col, path:line
+			nums: [2]int
+			n := 0
+			for n < 2 {
+				i := strings.last_index_byte(t.path, ':')
+				if i <= 0 {
+					break
+				}
+				v, ok := strconv.parse_int(strings.trim_prefix(t.path[i + 1:], "+"), 10)
+				if !ok || v < 1 {
+					break
+				}
+				nums[n] = v
+				n += 1
+				t.path = t.path[:i]
+			}
+			switch n {
+			case 1:
+				t.line, t.col = nums[0], 0
+			case 2:
+				t.line, t.col = nums[1], nums[0]
+			}
+		}
+		append(&targets, t)
+	}
+	return targets[:]
+}
+
+// Opens a target and moves to its position.
+open_target :: proc(state: ^Editor_State, t: Open_Target) -> bool {
+	if t.path == "-" {
+		if !open_stdin(state) {
+			return false
+		}
+	} else if !open_file(state, t.path) {
+		return false
+	}
+	if t.line > 0 {
+		goto_line_col(state, t.line - 1, max(t.col - 1, 0))
+	}
+	return true
+}
+
+// With --wait, closes the window once the buffers it opened are all closed,
+// so rune can be $GIT_EDITOR.  Called every frame.
+check_wait :: proc(state: ^Editor_State) {
+	if len(state.wait_docs) == 0 {
+		return
+	}
+	for id in state.wait_docs {
+		if is_document_open(state, id) {
+			return
+		}
+	}
+	clear(&state.wait_docs)
+	glfw.SetWindowShouldClose(state.window, true)
+}
+
+// Reads stdin into a new unnamed buffer, as in `git diff | rune -`.
+@(private = "file")
+open_stdin :: proc(state: ^Editor_State) -> bool {
+	data, err := os.read_entire_file_from_file(os.stdin, context.temp_allocator)
+	if err != nil {
+		log.warnf("stdin: %v", err)
+		return false
+	}
+	if !is_blank_document(state) {
+		new_document(state)
+	}
+	text := editor.normalize_line_endings(string(data), context.temp_allocator)
+	gb := &state.buffer
+	gb.undo = nil
+	editor.replace_bytes(gb, 0, editor.current_length(gb), transmute([]u8)text)
+	gb.undo = &state.undo
+	state.cursor_pos = 0
+	sync_cursor(state)
+	set_message(state, "Read %d bytes from stdin", len(data))
+	return true
+}
+
+// "20", "20:5"; the line is at least 1.
+@(private = "file")
+parse_position :: proc(s: string) -> (line, col: int, ok: bool) {
+	line_text, _, col_text := strings.partition(s, ":")
+	line = strconv.parse_int(line_text, 10) or_return
+	if col_text != "" {
+		col = strconv.parse_int(col_text, 10) or_return
+	}
+	return line, col, line >= 1
+}
@@ -0,0 +1,779 @@
+package main
+
+import "core:c"
+import "core:log"
+import "core:time"
+import editor "editor"
+
+Command_Proc :: #type proc(state: ^Editor_State)
+
+Command_Kind :: enum u8 {
+	Action, // runs as is
+	Edit, // changes the buffer; refused when it is read-only
+	Move, // cursor motion; drops the selection first
+	Select, // cursor motion; extends the selection
+}
+
+Command :: struct {
+	name:        string,
+	description: string,
+	action:      Command_Proc,
+	kind:        Command_Kind,
+	script:      c.int, // Lua function of a plugin's command instead of action, see plugins.odin
+}
+
+// Built-in bindings; user keymaps are layered on top of these.
+DEFAULT_BINDINGS := [?]editor.Key_Binding_Entry {
+	{keys = "backspace", command = "edit.delete_backward"},
+	{keys = "delete", command = "edit.delete_forward"},
+	{keys = "enter", command = "edit.newline"},
+	{keys = "kpenter", command = "edit.newline"},
+	{keys = "tab", command = "edit.tab"},
+	{keys = "shift+tab", command = "edit.dedent"},
+	{keys = "ctrl+]", command = "edit.indent"},
+	{keys = "ctrl+[", command = "edit.dedent"},
+	{keys = "ctrl+shift+i", command = "edit.reindent"},
+	{keys = "left", command = "cursor.left"},
+	{keys = "right", command = "cursor.right"},
+	{keys = "up", command = "cursor.up"},
+	{keys = "down", command = "cursor.down"},
+	{keys = "home", command = "cursor.line_start"},
+	{keys = "end", command = "cursor.line_end"},
+	{keys = "ctrl+left", command = "cursor.word_left"},
+	{keys = "ctrl+right", command = "cursor.word_right"},
+	{keys = "ctrl+home", command = "cursor.file_start"},
+	{keys = "ctrl+end", command = "cursor.file_end"},
+	{keys = "shift+left", command = "select.left"},
+	{keys = "shift+right", command = "select.right"},
+	{keys = "shift+up", command = "select.up"},
+	{keys = "shift+down", command = "select.down"},
+	{keys = "shift+home", command = "select.line_start"},
+	{keys = "shift+end", command = "select.line_end"},
+	{keys = "ctrl+shift+left", command = "select.word_left"},
+	{keys = "ctrl+shift+right", command = "select.word_right"},
+	{keys = "ctrl+shift+home", command = "select.file_start"},
+	{keys = "ctrl+shift+end", command = "select.file_end"},
+	{keys = "pagedown", command = "cursor.page_down"},
+	{keys = "pageup", command = "cursor.page_up"},
+	{keys = "shift+pagedown", command = "select.page_down"},
+	{keys = "shift+pageup", command = "select.page_up"},
+	{keys = "alt+pagedown", command = "cursor.half_page_down"},
+	{keys = "alt+pageup", command = "cursor.half_page_up"},
+	{keys = "ctrl+down", command = "cursor.paragraph_next"},
+	{keys = "ctrl+up", command = "cursor.paragraph_prev"},
+	{keys = "ctrl+shift+down", command = "select.paragraph_next"},
+	{keys = "ctrl+shift+up", command = "select.paragraph_prev"},
+	{keys = "alt+e", command = "cursor.sentence_next"},
+	{keys = "alt+a", command = "cursor.sentence_prev"},
+	{keys = "alt+q", command = "prose.reflow"},
+	{keys = "shift+alt+right", command = "select.expand"},
+	{keys = "shift+alt+left", command = "select.shrink"},
+	{keys = "ctrl+z", command = "edit.undo"},
+	{keys = "ctrl+shift+z", command = "edit.redo"},
+	{keys = "ctrl+y", command = "edit.redo"},
+	{keys = "ctrl+c", command = "edit.copy"},
+	{keys = "ctrl+x", command = "edit.cut"},
+	{keys = "ctrl+v", command = "edit.paste"},
+	{keys = "ctrl+shift+v", command = "edit.paste_history"},
+	{keys = "ctrl+insert", command = "edit.copy"},
+	{keys = "shift+delete", command = "edit.cut"},
+	{keys = "shift+insert", command = "edit.paste"},
+	{keys = "ctrl+k '", command = "register.select"},
+	{keys = "ctrl+space", command = "completion.trigger"},
+	{keys = "down", command = "completion.next", mode = "completion"},
+	{keys = "up", command = "completion.prev", mode = "completion"},
+	{keys = "enter", command = "completion.accept", mode = "completion"},
+	{keys = "kpenter", command = "completion.accept", mode = "completion"},
+	{keys = "tab", command = "completion.accept", mode = "completion"},
+	{keys = "escape", command = "completion.close", mode = "completion"},
+	{keys = "ctrl+k shift+'", command = "register.list"},
+	{keys = "ctrl+s", command = "file.save"},
+	{keys = "ctrl+tab", command = "buffer.next"},
+	{keys = "ctrl+shift+tab", command = "buffer.prev"},
+	{keys = "ctrl+w", command = "buffer.close"},
+	{keys = "ctrl+k l", command = "buffer.list"},
+	{keys = "ctrl+k shift+p", command = "project.open"},
+	{keys = "ctrl+k ctrl+r", command = "file.recent"},
+	{keys = "ctrl+k ctrl+n", command = "scratch.new"},
+	{keys = "ctrl+k shift+l", command = "language.select"},
+	{keys = "ctrl+shift+o", command = "symbol.goto"},
+	{keys = "f12", command = "symbol.definition"},
+	{keys = "alt+f12", command = "symbol.peek_definition"},
+	{keys = "shift+f12", command = "symbol.peek_references"},
+	{keys = "ctrl+t", command = "symbol.search"},
+	{keys = "ctrl+g", command = "goto.line"},
+	{keys = "ctrl+o", command = "jump.back"},
+	{keys = "ctrl+i", command = "jump.forward"},
+	{keys = "alt+left", command = "jump.back"},
+	{keys = "alt+right", command = "jump.forward"},
+	{keys = "ctrl+alt+right", command = "inline.accept_word"},
+	{keys = "ctrl+alt+down", command = "inline.accept_line"},
+	{keys = "ctrl+f2", command = "bookmark.toggle"},
+	{keys = "f2", command = "bookmark.next"},
+	{keys = "shift+f2", command = "bookmark.prev"},
+	{keys = "ctrl+k b", command = "bookmark.list"},
+	{keys = "ctrl+k m", command = "bookmark.set_named"},
+	{keys = "ctrl+k g", command = "bookmark.goto_named"},
+	{keys = "ctrl+/", command = "comment.toggle_line"},
+	{keys = "shift+alt+a", command = "comment.toggle_block"},
+	{keys = "escape", command = "editor.cancel"},
+	{keys = "ctrl+shift+f", command = "search.project"},
+	{keys = "ctrl+shift+h", command = "search.replace"},
+	{keys = "ctrl+k n", command = "git.next_change"},
+	{keys = "ctrl+k p", command = "git.prev_change"},
+	{keys = "ctrl+k r", command = "git.revert_change"},
+	{keys = "ctrl+k a", command = "git.blame_line"},
+	{keys = "ctrl+k shift+a", command = "git.blame_file"},
+	{keys = "ctrl+k c", command = "git.show_commit"},
+	{keys = "ctrl+k s", command = "git.status"},
+	{keys = "ctrl+k shift+c", command = "git.commit"},
+	{keys = "ctrl+enter", command = "git.finish_commit", language = "gitcommit"},
+	{keys = "ctrl+k d", command = "diff.head"},
+	{keys = "ctrl+k shift+d", command = "diff.saved"},
+	{keys = "ctrl+k ctrl+h", command = "history.list"},
+	{keys = "ctrl+shift+b", command = "task.build"},
+	{keys = "ctrl+shift+m", command = "diagnostics.list"},
+	{keys = "f8", command = "quickfix.next"},
+	{keys = "shift+f8", command = "quickfix.previous"},
+	{keys = "ctrl+k q", command = "quickfix.list"},
+	{keys = "ctrl+k t", command = "task.run"},
+	{keys = "ctrl+k shift+\\", command = "edit.filter"},
+	{keys = "shift+alt+f", command = "edit.format"},
+	{keys = "shift+alt+o", command = "edit.organize_imports"},
+	{keys = "ctrl+k e", command = "test.explorer"},
+	{keys = "ctrl+k shift+t", command = "test.run_nearest"},
+	{keys = "ctrl+k shift+r", command = "test.rerun"},
+	{keys = "ctrl+k o", command = "config.set"},
+	{keys = "ctrl+k h", command = "view.toggle_hex"},
+	{keys = "ctrl+k z", command = "view.zen"},
+	{keys = "ctrl+k x", command = "todo.toggle_checkbox"},
+	{keys = "ctrl+k ctrl+d", command = "todo.cycle"},
+	{keys = "ctrl+k ctrl+o", command = "todo.list"},
+	{keys = "ctrl+k ctrl+c", command = "color.edit"},
+	{keys = "backspace", command = "hex.delete_backward", language = "hexdump"},
+	{keys = "delete", command = "hex.delete_forward", language = "hexdump"},
+	{keys = "ctrl+f", command = "hex.search", language = "hexdump"},
+	{keys = "ctrl+k v", command = "view.csv", language = "csv"},
+	{keys = "ctrl+k v", command = "view.csv", language = "tsv"},
+	{keys = "ctrl+k v", command = "view.log", language = "log"},
+	{keys = "ctrl+=", command = "image.zoom_in", language = "hexdump"},
+	{keys = "ctrl+-", command = "image.zoom_out", language = "hexdump"},
+	{keys = "ctrl+0", command = "image.zoom_fit", language = "hexdump"},
+	{keys = "ctrl+1", command = "image.zoom_actual", language = "hexdump"},
+	{keys = "ctrl+k shift+h", command = "view.toggle_image", language = "hexdump"},
+	{keys = "f1", command = "help.topic"},
+	{keys = "ctrl+shift+u", command = "unicode.insert"},
+	{keys = "ctrl+k ctrl+k", command = "unicode.digraph"},
+	{keys = "ctrl+k i", command = "unicode.describe"},
+	{keys = "enter", command = "help.follow", language = "help"},
+	{keys = "tab", command = "table.next_cell", language = "markdown"},
+	{keys = "shift+tab", command = "table.prev_cell", language = "markdown"},
+	{keys = "ctrl+k ctrl+t a", command = "table.align", language = "markdown"},
+	{keys = "ctrl+k ctrl+t r", command = "table.row_add", language = "markdown"},
+	{keys = "ctrl+k ctrl+t shift+r", command = "table.row_delete", language = "markdown"},
+	{keys = "ctrl+k ctrl+t c", command = "table.column_add", language = "markdown"},
+	{keys = "ctrl+k ctrl+t shift+c", command = "table.column_delete", language = "markdown"},
+	{keys = "ctrl+k ctrl+t v", command = "table.from_csv", language = "markdown"},
+	{keys = "ctrl+k ctrl+j f", command = "json.format", language = "json"},
+	{keys = "ctrl+k ctrl+j m", command = "json.minify", language = "json"},
+	{keys = "ctrl+k ctrl+j v", command = "json.validate", language = "json"},
+	{keys = "ctrl+k ctrl+j p", command = "json.copy_path", language = "json"},
+	{keys = "ctrl+k ctrl+j p", command = "json.copy_path", language = "yaml"},
+	{keys = "backspace", command = "help.back", language = "help"},
+	{keys = "ctrl+j", command = "panel.toggle"},
+	{keys = "enter", command = "prompt.submit", mode = "prompt"},
+	{keys = "kpenter", command = "prompt.submit", mode = "prompt"},
+	{keys = "escape", command = "prompt.cancel", mode = "prompt"},
+	{keys = "backspace", command = "prompt.delete_backward", mode = "prompt"},
+	{keys = "ctrl+v", command = "prompt.paste", mode = "prompt"},
+	{keys = "tab", command = "prompt.complete", mode = "prompt"},
+	{keys = "down", command = "diff.scroll_down", mode = "diff"},
+	{keys = "up", command = "diff.scroll_up", mode = "diff"},
+	{keys = "pagedown", command = "diff.page_down", mode = "diff"},
+	{keys = "pageup", command = "diff.page_up", mode = "diff"},
+	{keys = "n", command = "diff.next_hunk", mode = "diff"},
+	{keys = "p", command = "diff.prev_hunk", mode = "diff"},
+	{keys = "escape", command = "diff.close", mode = "diff"},
+	{keys = "up", command = "csv.up", mode = "csv"},
+	{keys = "down", command = "csv.down", mode = "csv"},
+	{keys = "left", command = "csv.left", mode = "csv"},
+	{keys = "right", command = "csv.right", mode = "csv"},
+	{keys = "tab", command = "csv.next_cell", mode = "csv"},
+	{keys = "shift+tab", command = "csv.prev_cell", mode = "csv"},
+	{keys = "pageup", command = "csv.page_up", mode = "csv"},
+	{keys = "pagedown", command = "csv.page_down", mode = "csv"},
+	{keys = "ctrl+home", command = "csv.first_row", mode = "csv"},
+	{keys = "ctrl+end", command = "csv.last_row", mode = "csv"},
+	{keys = "s", command = "csv.sort", mode = "csv"},
+	{keys = "h", command = "csv.toggle_header", mode = "csv"},
+	{keys = "enter", command = "csv.edit", mode = "csv"},
+	{keys = "escape", command = "csv.close", mode = "csv"},
+	{keys = "up", command = "log.up", mode = "log"},
+	{keys = "down", command = "log.down", mode = "log"},
+	{keys = "left", command = "log.scroll_left", mode = "log"},
+	{keys = "right", command = "log.scroll_right", mode = "log"},
+	{keys = "pageup", command = "log.page_up", mode = "log"},
+	{keys = "pagedown", command = "log.page_down", mode = "log"},
+	{keys = "ctrl+home", command = "log.first_line", mode = "log"},
+	{keys = "ctrl+end", command = "log.follow", mode = "log"},
+	{keys = "f", command = "log.follow", mode = "log"},
+	{keys = "/", command = "log.filter", mode = "log"},
+	{keys = "n", command = "log.next_problem", mode = "log"},
+	{keys = "p", command = "log.prev_problem", mode = "log"},
+	{keys = "enter", command = "log.open_line", mode = "log"},
+	{keys = "escape", command = "log.close", mode = "log"},
+	{keys = "ctrl+k ctrl+i", command = "docs.hover"},
+	{keys = "ctrl+k shift+i", command = "docs.focus"},
+	{keys = "up", command = "docs.up", mode = "docs"},
+	{keys = "down", command = "docs.down", mode = "docs"},
+	{keys = "pageup", command = "docs.page_up", mode = "docs"},
+	{keys = "pagedown", command = "docs.page_down", mode = "docs"},
+	{keys = "tab", command = "docs.next_link", mode = "docs"},
+	{keys = "shift+tab", command = "docs.prev_link", mode = "docs"},
+	{keys = "enter", command = "docs.follow", mode = "docs"},
+	{keys = "p", command = "docs.pin", mode = "docs"},
+	{keys = "q", command = "docs.close", mode = "docs"},
+	{keys = "escape", command = "docs.unfocus", mode = "docs"},
+	{keys = "left", command = "peek.left", mode = "peek"},
+	{keys = "right", command = "peek.right", mode = "peek"},
+	{keys = "up", command = "peek.up", mode = "peek"},
+	{keys = "down", command = "peek.down", mode = "peek"},
+	{keys = "home", command = "peek.line_start", mode = "peek"},
+	{keys = "end", command = "peek.line_end", mode = "peek"},
+	{keys = "backspace", command = "peek.delete_backward", mode = "peek"},
+	{keys = "delete", command = "peek.delete_forward", mode = "peek"},
+	{keys = "enter", command = "peek.newline", mode = "peek"},
+	{keys = "kpenter", command = "peek.newline", mode = "peek"},
+	{keys = "tab", command = "peek.tab", mode = "peek"},
+	{keys = "ctrl+v", command = "peek.paste", mode = "peek"},
+	{keys = "ctrl+z", command = "peek.undo", mode = "peek"},
+	{keys = "ctrl+shift+z", command = "peek.redo", mode = "peek"},
+	{keys = "ctrl+y", command = "peek.redo", mode = "peek"},
+	{keys = "ctrl+s", command = "peek.save", mode = "peek"},
+	{keys = "alt+down", command = "peek.next", mode = "peek"},
+	{keys = "alt+up", command = "peek.previous", mode = "peek"},
+	{keys = "ctrl+enter", command = "peek.open", mode = "peek"},
+	{keys = "escape", command = "peek.close", mode = "peek"},
+	{keys = "down", command = "panel.next", mode = "panel"},
+	{keys = "up", command = "panel.prev", mode = "panel"},
+	{keys = "pagedown", command = "panel.page_down", mode = "panel"},
+	{keys = "pageup", command = "panel.page_up", mode = "panel"},
+	{keys = "enter", command = "panel.accept", mode = "panel"},
+	{keys = "escape", command = "panel.close", mode = "panel"},
+	{keys = "space", command = "panel.toggle_item", mode = "panel"},
+	{keys = "r", command = "test.run_selected", mode = "panel"},
+	{keys = "ctrl+enter", command = "replace.apply", mode = "panel"},
+}
+
+register_command :: proc(
+	state: ^Editor_State,
+	name, description: string,
+	action: Command_Proc,
+	kind := Command_Kind.Action,
+) {
+	state.commands[name] = Command {
+		name        = name,
+		description = description,
+		action      = action,
+		kind        = kind,
+	}
+}
+
+// Registers a cursor motion twice: once as a plain move and once as a
+// selection-extending variant.
+register_motion :: proc(
+	state: ^Editor_State,
+	move_name, select_name, description: string,
+	action: Command_Proc,
+) {
+	register_command(state, move_name, description, action, .Move)
+	register_command(state, select_name, description, action, .Select)
+}
+
+// Registers a command that changes the buffer, so that read-only documents
+// refuse it.
+register_edit :: proc(state: ^Editor_State, name, description: string, action: Command_Proc) {
+	register_command(state, name, description, action, .Edit)
+}
+
+// Runs a command by name.  Whatever it changes in the buffer is one undo step.
+run_command :: proc(state: ^Editor_State, name: string) -> bool {
+	cmd, ok := state.commands[name]
+	if !ok {
+		log.warn("Unknown command:", name)
+		return false
+	}
+	remember_command(state, name)
+	defer record_command_metric(state, name, time.tick_now())
+	editor.begin_undo_group(&state.undo, state.cursor_pos)
+	defer editor.end_undo_group(&state.undo, state.cursor_pos)
+	switch cmd.kind {
+	case .Action:
+		if cmd.script != 0 {
+			run_plugin_command(state, cmd.script)
+		} else {
+			cmd.action(state)
+		}
+	case .Edit:
+		if is_read_only(state) {
+			set_message(state, "%s is read-only", document_title(state.path))
+			return true
+		}
+		cmd.action(state)
+	case .Move:
+		clear_selection(state)
+		cmd.action(state)
+	case .Select:
+		begin_selection(state)
+		cmd.action(state)
+	}
+	return true
+}
+
+register_builtin_commands :: proc(state: ^Editor_State) {
+	register_edit(state, "edit.delete_backward", "Delete before the cursor", delete_before_cursor)
+	register_edit(state, "edit.delete_forward", "Delete after the cursor", delete_after_cursor)
+	register_edit(state, "edit.newline", "Insert an indented line break", insert_newline)
+	register_edit(state, "edit.tab", "Insert a tab or indent the selection", insert_tab)
+	register_edit(state, "edit.indent", "Indent the selected lines", proc(state: ^Editor_State) {
+		shift_selected_lines(state, 1)
+	})
+	register_edit(state, "edit.dedent", "Dedent the selected lines", proc(state: ^Editor_State) {
+		if !jump_snippet_stop(state, -1) {
+			shift_selected_lines(state, -1)
+		}
+	})
+	register_edit(state, "edit.reindent", "Re-indent the selected lines", reindent_selection)
+	register_edit(state, "edit.undo", "Undo the last change", undo_edit)
+	register_edit(state, "edit.redo", "Redo the last undone change", redo_edit)
+	register_command(state, "edit.copy", "Copy the selection or line", copy_selection)
+	register_edit(state, "edit.cut", "Cut the selection or line", cut_selection)
+	register_edit(state, "edit.paste", "Paste from the clipboard", paste_clipboard)
+	register_edit(state, "edit.paste_history", "Paste an earlier copy", show_clipboard_history)
+	register_command(state, "completion.trigger", "Complete the word", trigger_completion)
+	register_command(state, "snippet.exit", "Forget the snippet's tab stops", end_snippet)
+	register_edit(state, "inline.accept", "Accept the suggestion", proc(state: ^Editor_State) {
+		if !accept_inline_completion(state) {
+			set_message(state, "No suggestion to accept")
+		}
+	})
+	register_edit(state, "inline.accept_word", "Accept the next suggested word", accept_inline_word)
+	register_edit(state, "inline.accept_line", "Accept the suggestion's line", accept_inline_line)
+	register_command(state, "inline.dismiss", "Drop the suggestion", dismiss_inline_completion)
+	register_command(state, "completion.next", "Select the next completion", completion_next)
+	register_command(state, "completion.prev", "Select the previous completion", completion_prev)
+	register_command(state, "completion.accept", "Insert the completion", accept_completion)
+	register_command(state, "completion.close", "Close the completions", close_completion)
+	register_command(state, "register.select", "Use a register for the next copy", select_register)
+	register_edit(state, "register.list", "Show the registers to paste one", show_registers)
+	register_motion(state, "cursor.left", "select.left", "One character left", move_cursor_left)
+	register_motion(state, "cursor.right", "select.right", "One character right", move_cursor_right)
+	register_motion(state, "cursor.up", "select.up", "One line up", move_cursor_up)
+	register_motion(state, "cursor.down", "select.down", "One line down", move_cursor_down)
+	register_motion(state, "cursor.line_start", "select.line_start", "Line start", move_cursor_home)
+	register_motion(state, "cursor.line_end", "select.line_end", "Line end", move_cursor_end)
+	register_motion(
+		state,
+		"cursor.word_left",
+		"select.word_left",
+		"Word left",
+		move_cursor_word_left,
+	)
+	register_motion(
+		state,
+		"cursor.word_right",
+		"select.word_right",
+		"Word right",
+		move_cursor_word_right,
+	)
+	register_motion(
+		state,
+		"cursor.subword_left",
+		"select.subword_left",
+		"camelCase or snake_case part left",
+		move_cursor_subword_left,
+	)
+	register_motion(
+		state,
+		"cursor.subword_right",
+		"select.subword_right",
+		"camelCase or snake_case part right",
+		move_cursor_subword_right,
+	)
+	register_motion(state, "cursor.file_start", "select.file_start", "Top", move_cursor_file_start)
+	register_motion(state, "cursor.file_end", "select.file_end", "Bottom", move_cursor_file_end)
+	register_motion(state, "cursor.page_down", "select.page_down", "Page down", scroll_page_down)
+	register_motion(state, "cursor.page_up", "select.page_up", "Page up", scroll_page_up)
+	register_motion(
+		state,
+		"cursor.half_page_down",
+		"select.half_page_down",
+		"Half a page down",
+		scroll_half_page_down,
+	)
+	register_motion(
+		state,
+		"cursor.half_page_up",
+		"select.half_page_up",
+		"Half a page up",
+		scroll_half_page_up,
+	)
+	register_motion(
+		state,
+		"cursor.paragraph_next",
+		"select.paragraph_next",
+		"To the blank line after the paragraph",
+		move_paragraph_next,
+	)
+	register_motion(
+		state,
+		"cursor.paragraph_prev",
+		"select.paragraph_prev",
+		"To the blank line before the paragraph",
+		move_paragraph_prev,
+	)
+	register_motion(
+		state,
+		"cursor.sentence_next",
+		"select.sentence_next",
+		"To the start of the next sentence",
+		move_sentence_next,
+	)
+	register_motion(
+		state,
+		"cursor.sentence_prev",
+		"select.sentence_prev",
+		"To the start of the sentence",
+		move_sentence_prev,
+	)
+	register_edit(state, "prose.reflow", "Fill the paragraph to the fill column", reflow_paragraph)
+	register_edit(state, "todo.toggle_checkbox", "Check or uncheck the list item", toggle_checkbox)
+	register_edit(state, "todo.cycle", "Next TODO state of the line", cycle_todo_state)
+	register_command(state, "todo.list", "List the project's TODO comments", list_project_todos)
+	register_edit(state, "color.edit", "Pick a new color for the literal", edit_color)
+	register_edit(state, "table.align", "Line up the columns of the table", align_table)
+	register_edit(state, "table.next_cell", "Next table cell, or a tab", table_next_cell)
+	register_edit(state, "table.prev_cell", "Previous table cell, or dedent", table_prev_cell)
+	register_edit(state, "table.row_add", "Add a table row below", table_add_row)
+	register_edit(state, "table.row_delete", "Delete the table row", table_delete_row)
+	register_edit(state, "table.column_add", "Add a table column after this one", table_add_column)
+	register_edit(state, "table.column_delete", "Delete the table column", table_delete_column)
+	register_edit(state, "table.from_csv", "Make a table of the selected CSV", table_from_csv)
+	register_command(state, "select.expand", "Select the enclosing code", expand_selection)
+	register_command(state, "select.shrink", "Undo the last selection growth", shrink_selection)
+	register_command(state, "buffer.next", "Switch to the next open buffer", next_document)
+	register_command(state, "buffer.prev", "Switch to the previous open buffer", prev_document)
+	register_command(state, "file.save", "Save the buffer to its file", save_document)
+	register_command(
+		state,
+		"file.save_with_encoding",
+		"Save the file in a chosen encoding",
+		save_with_encoding,
+	)
+	register_command(
+		state,
+		"file.reopen_with_encoding",
+		"Read the file again in a chosen encoding",
+		reopen_with_encoding,
+	)
+	register_edit(
+		state,
+		"file.line_endings_lf",
+		"Save with LF line endings",
+		proc(state: ^Editor_State) {
+			set_line_ending(state, .LF)
+		},
+	)
+	register_edit(
+		state,
+		"file.line_endings_crlf",
+		"Save with CRLF line endings",
+		proc(state: ^Editor_State) {
+			set_line_ending(state, .CRLF)
+		},
+	)
+	register_edit(
+		state,
+		"file.line_endings_cr",
+		"Save with CR line endings",
+		proc(state: ^Editor_State) {
+			set_line_ending(state, .CR)
+		},
+	)
+	register_command(state, "buffer.close", "Close the current buffer", close_buffer)
+	register_command(state, "buffer.list", "List the open buffers", list_documents)
+	register_command(state, "goto.line", "Go to a line and column", goto_line)
+	register_command(state, "jump.back", "Back to the previous jump location", jump_back)
+	register_command(state, "jump.forward", "Forward in the jump list", jump_forward)
+	register_command(state, "bookmark.toggle", "Toggle a bookmark on the line", toggle_bookmark)
+	register_command(state, "bookmark.next", "Jump to the next bookmark", next_bookmark)
+	register_command(state, "bookmark.prev", "Jump to the previous bookmark", prev_bookmark)
+	register_command(state, "bookmark.list", "List the workspace bookmarks", list_bookmarks)
+	register_command(state, "bookmark.set_named", "Set a named mark on the line", set_named_bookmark)
+	register_command(state, "bookmark.goto_named", "Jump to a named mark", goto_named_bookmark)
+	register_edit(state, "comment.toggle_line", "Toggle line comments", toggle_line_comment)
+	register_edit(state, "comment.toggle_block", "Toggle a block comment", toggle_block_comment)
+	register_command(state, "prompt.submit", "Accept the prompt input", prompt_submit)
+	register_command(state, "prompt.cancel", "Close the prompt", prompt_cancel)
+	register_command(state, "prompt.delete_backward", "Delete in the prompt", prompt_delete_backward)
+	register_command(state, "prompt.paste", "Paste into the prompt", paste_into_prompt)
+	register_command(state, "prompt.complete", "Complete a word in the prompt", prompt_complete)
+	register_command(state, "panel.toggle", "Focus or hide the bottom panel", toggle_panel)
+	register_command(state, "panel.close", "Hide the bottom panel", hide_panel)
+	register_command(state, "panel.next", "Select the next panel item", panel_next)
+	register_command(state, "panel.prev", "Select the previous panel item", panel_prev)
+	register_command(state, "panel.page_down", "Page down in the panel", panel_page_down)
+	register_command(state, "panel.page_up", "Page up in the panel", panel_page_up)
+	register_command(state, "panel.accept", "Jump to the selected panel item", panel_accept)
+	register_command(state, "panel.toggle_item", "Check or uncheck an item", panel_toggle_item)
+	register_command(state, "search.project", "Search the workspace with a regex", search_project)
+	register_command(state, "search.replace", "Replace a regex in the workspace", search_replace)
+	register_command(state, "git.next_change", "Next uncommitted change", next_git_change)
+	register_command(state, "git.prev_change", "Previous uncommitted change", prev_git_change)
+	register_edit(state, "git.revert_change", "Revert the change on the line", revert_git_change)
+	register_command(state, "git.blame_line", "Toggle blame annotations", toggle_inline_blame)
+	register_command(state, "git.blame_file", "Blame every line of the file", blame_file)
+	register_command(state, "git.show_commit", "Show the cursor line's commit", show_blame_commit)
+	register_command(state, "git.status", "List changed files to stage", show_git_status)
+	register_command(state, "git.commit", "Write a commit message", start_git_commit)
+	register_command(state, "git.finish_commit", "Commit with this message", finish_git_commit)
+	register_command(state, "git.push", "Push the current branch", git_push)
+	register_command(state, "git.pull", "Pull the current branch", git_pull)
+	register_command(state, "diff.head", "Compare the buffer with HEAD", diff_with_head)
+	register_command(state, "diff.saved", "Compare the buffer with the saved file", diff_with_saved)
+	register_command(state, "diff.files", "Compare two files", diff_files)
+	register_command(state, "diff.close", "Close the diff view", close_diff_view)
+	register_command(state, "diff.next_hunk", "Next change in the diff", diff_next_hunk)
+	register_command(state, "diff.prev_hunk", "Previous change in the diff", diff_prev_hunk)
+	register_command(state, "diff.scroll_down", "Scroll the diff down", diff_scroll_down)
+	register_command(state, "diff.scroll_up", "Scroll the diff up", diff_scroll_up)
+	register_command(state, "diff.page_down", "Page down in the diff", diff_page_down)
+	register_command(state, "diff.page_up", "Page up in the diff", diff_page_up)
+	register_command(state, "history.list", "Diff against a saved version", show_file_history)
+	register_edit(state, "history.restore", "Restore a saved version", restore_file_history)
+	register_command(state, "task.run", "Run a project task", run_task_prompt)
+	register_command(state, "task.build", "Run the build task", run_build_task)
+	register_command(state, "task.test", "Run the test task", run_test_task)
+	register_command(state, "task.lint", "Run the lint task", run_lint_task)
+	register_command(state, "task.cancel", "Stop the running task", cancel_running_task)
+	register_edit(state, "edit.filter", "Pipe the selection through a command", filter_selection)
+	register_command(state, "filter.cancel", "Stop the running filter", cancel_filter)
+	register_edit(state, "edit.format", "Format the buffer with its formatter", format_document)
+	register_edit(state, "edit.organize_imports", "Sort and prune the imports", organize_imports)
+	register_command(state, "lint.run", "Check the file with its linters", lint_document)
+	register_command(state, "diagnostics.list", "List reported problems", show_diagnostics)
+	register_command(state, "quickfix.next", "Go to the quickfix list's next entry", quickfix_next)
+	register_command(
+		state,
+		"quickfix.previous",
+		"Go to the quickfix list's previous entry",
+		quickfix_previous,
+	)
+	register_command(state, "quickfix.list", "List the quickfix entries", show_quickfix)
+	register_command(state, "quickfix.older", "Walk the previous quickfix list", quickfix_older)
+	register_command(state, "quickfix.newer", "Walk the next quickfix list", quickfix_newer)
+	register_command(
+		state,
+		"quickfix.replace",
+		"Replace a regex on every quickfix entry's line",
+		quickfix_replace,
+	)
+	register_command(state, "quickfix.do", "Run a command at every quickfix entry", quickfix_do)
+	register_command(state, "lsp.install", "Install a language server", install_server_prompt)
+	register_command(state, "lsp.check_updates", "Check for server updates", check_server_updates)
+	register_command(
+		state,
+		"lsp.check_connection",
+		"Try to reach a server",
+		check_server_connection,
+	)
+	register_command(state, "go.gc_details", "Show the compiler's decisions", show_gc_details)
+	register_command(state, "go.vulncheck", "Check for reachable vulnerabilities", run_vulncheck)
+	register_command(state, "go.list_imports", "List the file's imports", list_go_imports)
+	register_edit(state, "go.add_import", "Import a package", add_go_import)
+	register_edit(state, "go.remove_import", "Remove an import", remove_go_import)
+	register_command(state, "test.explorer", "List the tests of the workspace", show_test_explorer)
+	register_command(state, "test.run_nearest", "Run the test at the cursor", run_nearest_test)
+	register_command(state, "test.run_file", "Run the tests of the file", run_file_tests)
+	register_command(state, "test.rerun", "Run the last tests again", rerun_tests)
+	register_command(state, "test.run_selected", "Run the selected tests", run_selected_test)
+	register_command(state, "config.set", "Override an option until exit", set_config_option)
+	register_command(state, "config.settings", "Browse and edit the options", show_settings)
+	register_command(state, "view.toggle_hex", "Switch between text and hex view", toggle_hex_view)
+	register_command(state, "view.zen", "Toggle distraction-free writing", toggle_zen_mode)
+	register_command(state, "view.csv", "Show delimited values as a table", open_csv_view)
+	register_edit(state, "json.format", "Indent the JSON in the buffer", format_json_document)
+	register_edit(state, "json.minify", "Put the JSON on one line", minify_json_document)
+	register_command(state, "json.validate", "Validate the JSON and schema", validate_json_document)
+	register_command(state, "json.copy_path", "Copy the path of the cursor", copy_json_path)
+	register_command(state, "csv.up", "Move to the cell above", csv_up)
+	register_command(state, "csv.down", "Move to the cell below", csv_down)
+	register_command(state, "csv.left", "Move to the cell on the left", csv_left)
+	register_command(state, "csv.right", "Move to the cell on the right", csv_right)
+	register_command(state, "csv.next_cell", "Move to the next cell", csv_next_cell)
+	register_command(state, "csv.prev_cell", "Move to the previous cell", csv_prev_cell)
+	register_command(state, "csv.page_up", "Move a page of rows up", csv_page_up)
+	register_command(state, "csv.page_down", "Move a page of rows down", csv_page_down)
+	register_command(state, "csv.first_row", "Move to the first row", csv_first_row)
+	register_command(state, "csv.last_row", "Move to the last row", csv_last_row)
+	register_command(state, "csv.sort", "Sort the rows by the column", sort_csv_column)
+	register_command(state, "csv.toggle_header", "Pin the first row or not", toggle_csv_header)
+	register_command(state, "csv.edit", "Edit the cell in the buffer", edit_csv_cell)
+	register_command(state, "csv.close", "Close the CSV view", close_csv_view)
+	register_command(state, "view.log", "Show the buffer as a log", open_log_view)
+	register_command(state, "log.up", "Move to the line above", log_up)
+	register_command(state, "log.down", "Move to the line below", log_down)
+	register_command(state, "log.scroll_left", "Scroll the lines left", log_scroll_left)
+	register_command(state, "log.scroll_right", "Scroll the lines right", log_scroll_right)
+	register_command(state, "log.page_up", "Move a page of lines up", log_page_up)
+	register_command(state, "log.page_down", "Move a page of lines down", log_page_down)
+	register_command(state, "log.first_line", "Move to the first line", log_first_line)
+	register_command(state, "log.follow", "Follow the end of the log", follow_log)
+	register_command(state, "log.filter", "Fold lines by a regex", filter_log_prompt)
+	register_command(state, "log.next_problem", "Next warning or error", log_next_problem)
+	register_command(state, "log.prev_problem", "Previous warning or error", log_prev_problem)
+	register_command(state, "log.open_line", "Unfold, or edit the line", open_log_line)
+	register_command(state, "log.close", "Close the log view", close_log_view)
+	register_command(state, "docs.hover", "Show the documentation at the cursor", show_docs)
+	register_command(state, "docs.pin", "Pin the documentation shown", pin_docs)
+	register_command(state, "docs.focus", "Scroll the documentation with keys", focus_docs)
+	register_command(state, "docs.unfocus", "Give the keys back to the editor", unfocus_docs)
+	register_command(state, "docs.close", "Hide the documentation", close_docs)
+	register_command(state, "docs.follow", "Follow the picked link", follow_docs_link)
+	register_command(state, "docs.next_link", "Pick the next link", docs_next_link)
+	register_command(state, "docs.prev_link", "Pick the previous link", docs_prev_link)
+	register_command(state, "docs.up", "Scroll up a line", docs_up)
+	register_command(state, "docs.down", "Scroll down a line", docs_down)
+	register_command(state, "docs.page_up", "Scroll up a page", docs_page_up)
+	register_command(state, "docs.page_down", "Scroll down a page", docs_page_down)
+	register_command(state, "hex.delete_backward", "Delete the previous byte", hex_delete_backward)
+	register_command(state, "hex.delete_forward", "Delete the current byte", hex_delete_forward)
+	register_command(state, "hex.search", "Find a byte pattern", hex_search)
+	register_command(state, "image.zoom_in", "Show the image larger", zoom_image_in)
+	register_command(state, "image.zoom_out", "Show the image smaller", zoom_image_out)
+	register_command(state, "image.zoom_fit", "Fit the image in the window", zoom_image_to_fit)
+	register_command(state, "image.zoom_actual", "Show the image at 100%", zoom_image_to_actual)
+	register_command(state, "image.info", "Show the image's format and size", show_image_info)
+	register_command(state, "view.toggle_image", "Show the image or its bytes", toggle_image_view)
+	register_command(state, "replace.apply", "Apply the checked replacements", apply_replacements)
+	register_command(state, "project.open", "Switch to a recent project", show_recent_projects)
+	register_command(state, "file.save_as_root", "Save the file with root's rights", save_as_root)
+	register_command(state, "file.recent", "Open a recently used file", show_recent_files)
+	register_command(state, "scratch.new", "Open an untitled scratch buffer", new_scratch_buffer)
+	register_command(state, "language.select", "Set the buffer's language", show_languages)
+	register_command(state, "language.detect", "Guess the language again", detect_buffer_language)
+	register_command(state, "symbol.goto", "Jump to a definition in the buffer", goto_symbol)
+	register_command(state, "symbol.definition", "Go to the name's definition", goto_definition)
+	register_command(state, "symbol.search", "Search the workspace's symbols", search_tags)
+	register_command(state, "symbol.moniker", "Show the name's moniker", show_moniker)
+	register_command(
+		state,
+		"symbol.peek_definition",
+		"Peek at the name's definition",
+		peek_definition,
+	)
+	register_command(
+		state,
+		"symbol.peek_references",
+		"Peek at the name's references",
+		peek_references,
+	)
+	register_command(state, "peek.left", "Move the peek's caret left", peek_left)
+	register_command(state, "peek.right", "Move the peek's caret right", peek_right)
+	register_command(state, "peek.up", "Move the peek's caret up", peek_up)
+	register_command(state, "peek.down", "Move the peek's caret down", peek_down)
+	register_command(
+		state,
+		"peek.line_start",
+		"Move the peek's caret to the line start",
+		peek_line_start,
+	)
+	register_command(state, "peek.line_end", "Move the peek's caret to the line end", peek_line_end)
+	register_command(
+		state,
+		"peek.delete_backward",
+		"Delete before the peek's caret",
+		peek_delete_backward,
+	)
+	register_command(
+		state,
+		"peek.delete_forward",
+		"Delete after the peek's caret",
+		peek_delete_forward,
+	)
+	register_command(state, "peek.newline", "Break the line in the peek", peek_newline)
+	register_command(state, "peek.tab", "Indent at the peek's caret", peek_tab)
+	register_command(state, "peek.paste", "Paste into the peek", peek_paste)
+	register_command(state, "peek.undo", "Undo the last change to the peeked file", peek_undo)
+	register_command(state, "peek.redo", "Redo a change to the peeked file", peek_redo)
+	register_command(state, "peek.save", "Save the peeked file", save_peek)
+	register_command(state, "peek.next", "Peek at the next place", peek_next)
+	register_command(state, "peek.previous", "Peek at the previous place", peek_previous)
+	register_command(state, "peek.open", "Go to the place peeked at", goto_peek)
+	register_command(state, "peek.close", "Close the peek", close_peek)
+	register_command(state, "notebook.next_cell", "Go to the next notebook cell", goto_next_cell)
+	register_command(
+		state,
+		"notebook.previous_cell",
+		"Go to the previous notebook cell",
+		goto_previous_cell,
+	)
+	register_edit(state, "notebook.insert_cell", "Start a cell after this", insert_notebook_cell)
+	register_command(state, "notebook.outputs", "List the cell's outputs", show_notebook_outputs)
+	register_command(state, "tags.generate", "Write the workspace's tags with ctags", generate_tags)
+	register_command(state, "memory.show", "List the memory each buffer holds", show_memory)
+	register_command(state, "metrics.show", "Open a buffer with timing metrics", show_metrics)
+	register_command(state, "log.level", "Change which messages are logged", set_log_level)
+	register_command(state, "log.open", "Open this session's log file", open_log)
+	register_command(state, "help.topic", "Read about a command, option or key", show_help)
+	register_command(state, "help.follow", "Open the help topic under the cursor", follow_help_link)
+	register_command(state, "help.back", "Go back to the previous help topic", help_back)
+	register_command(state, "unicode.insert", "Insert a character by its name", show_unicode_picker)
+	register_command(state, "unicode.digraph", "Insert the character of a digraph", insert_digraph)
+	register_command(
+		state,
+		"unicode.describe",
+		"Show the code point and name of the character at the cursor",
+		describe_character,
+	)
+	register_command(state, "project.open_folder", "Open a folder's project", open_project_prompt)
+	register_command(state, "workspace.add_folder", "Add a folder to search", add_folder_prompt)
+	register_command(
+		state,
+		"workspace.remove_folder",
+		"Remove a folder from the workspace",
+		show_workspace_folders,
+	)
+	register_command(
+		state,
+		"workspace.trust",
+		"Let the workspace config run its commands",
+		trust_workspace,
+	)
+	register_command(
+		state,
+		"workspace.untrust",
+		"Stop the workspace config running commands",
+		untrust_workspace,
+	)
+	register_command(state, "collab.host", "Share the buffer with collaborators", host_collab)
+	register_command(state, "collab.join", "Join a shared buffer", join_collab)
+	register_command(state, "collab.leave", "Stop sharing or leave the session", leave_collab)
+	register_command(state, "editor.cancel", "Cancel keys and selection", proc(state: ^Editor_State) {
+		editor.cancel_pending_keys(&state.keymap)
+		cancel_register(state)
+		end_snippet(state)
+		dismiss_inline_completion(state)
+		clear_selection(state)
+	})
+}
+
+load_keymaps :: proc(state: ^Editor_State) {
+	for b in DEFAULT_BINDINGS {
+		editor.bind_key(&state.keymap, b.keys, b.command, b.mode, b.language)
+	}
+
+	if path, ok := user_config_path("keymap.json"); ok {
+		editor.load_keymap_file(&state.keymap, path)
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import "core:os"
+import "core:path/filepath"
+import "core:slice"
+import "core:strings"
+import editor "editor"
+
+COMPLETION_MAX_ITEMS :: 50
+
+// What brought the completion popup up to date.
+Completion_Trigger :: enum u8 {
+	Refresh, // any key while it is open
+	Typed, // a typed character; opens it once the word is long enough
+	Manual, // completion.trigger; opens it for any word
+}
+
+// The popup under the cursor.  While it is open the keymap runs in
+// "completion" mode, so up, down, enter, tab and escape drive the popup and
+// everything else edits as usual.
+Completion_State :: struct {
+	active: bool,
+	start:  int, // byte position of the word or file name being completed
+}
+
+// completion.trigger: lists completions of the word before the cursor.
+trigger_completion :: proc(state: ^Editor_State) {
+	refresh_completion(state, .Manual)
+	if !state.completion.active {
+		set_message(state, "No completions")
+	}
+}
+
+// Called after every key.  Completes words of the open buffers, or file and
+// directory names after a "/" in a path.
+refresh_completion :: proc(state: ^Editor_State, trigger: Completion_Trigger) {
+	c := &state.completion
+	if trigger == .Refresh && !c.active {
+		return
+	}
+	auto := config_bool(state, "completion.auto") && .Completion not_in state.degraded
+	if trigger == .Typed && !c.active && !auto {
+		return
+	}
+	if (state.mode != "editor" && state.mode != "completion") || has_selection(state) {
+		close_completion(state)
+		return
+	}
+
+	start, dir, prefix, path := completion_prefix(state)
+	if prefix == "" && !path && trigger != .Manual {
+		close_completion(state)
+		return
+	}
+	if trigger == .Typed && !c.active {
+		// Only a word of min_chars or a path with a directory pops it up, so
+		// typing "//" or dividing does not.
+		short := len(prefix) < config_int(state, "completion.min_chars")
+		if (path && strings.trim(dir, "/") == "" && prefix == "") || (!path && short) {
+			return
+		}
+	}
+
+	items: []editor.Completion_Item
+	if path {
+		items = path_completions(state, dir, prefix)
+	} else {
+		items = word_completions(state, start, prefix)
+	}
+	if len(items) == 0 {
+		close_completion(state)
+		return
+	}
+	c.active = true
+	c.start = start
+	popup := state.completion_data
+	editor.set_completion_items(popup, items)
+	popup.line = state.cursor_data.line
+	line_start := editor.line_col_to_logical_pos(&state.buffer, popup.line, 0)
+	popup.visual_col = editor.get_visual_col(
+		&state.buffer,
+		popup.line,
+		start - line_start,
+		state.layer_ctx.tab_size,
+	)
+	popup.visible = true
+	if state.mode == "editor" {
+		state.mode = "completion"
+	}
+}
+
+close_completion :: proc(state: ^Editor_State) {
+	state.completion.active = false
+	state.completion_data.visible = false
+	editor.completion_clear(state.completion_data)
+	if state.mode == "completion" {
+		state.mode = "editor"
+	}
+}
+
+completion_next :: proc(state: ^Editor_State) {
+	editor.completion_move_selection(state.completion_data, 1)
+}
+
+completion_prev :: proc(state: ^Editor_State) {
+	editor.completion_move_selection(state.completion_data, -1)
+}
+
+// completion.accept: replaces the word before the cursor with the selected
+// completion.  A directory stays open to complete the names inside it.
+accept_completion :: proc(state: ^Editor_State) {
+	popup := state.completion_data
+	c := &state.completion
+	if !c.active || popup.selected >= len(popup.items) {
+		close_completion(state)
+		return
+	}
+	label := strings.clone(popup.items[popup.selected].label, context.temp_allocator)
+	source := popup.items[popup.selected].source
+	start := c.start
+	close_completion(state)
+	if is_read_only(state) {
+		set_message(state, "%s is read-only", document_title(state.path))
+		return
+	}
+	editor.replace_bytes(
+		&state.buffer,
+		start,
+		state.cursor_pos - start,
+		transmute([]u8)label,
+	)
+	state.cursor_pos = start + len(label)
+	sync_cursor(state)
+	set_preferred_col(state)
+	if strings.has_suffix(label, "/") {
+		refresh_completion(state, .Manual)
+	}
+	if source == .Word {
+		complete_call(state, label)
+	}
+}
+
+// prompt.complete: completes the word at the end of the input from the words
+// of the open buffers, e.g. a search pattern.
+prompt_complete :: proc(state: ^Editor_State) {
+	text := prompt_text(state)
+	start := len(text)
+	for start > 0 && is_identifier_byte(text[start - 1]) {
+		start -= 1
+	}
+	prefix := text[start:]
+	if prefix == "" {
+		return
+	}
+	words := buffer_words(state, prefix, -1)
+	if len(words) == 0 {
+		return
+	}
+	// The typed part keeps its case; only the rest is added.
+	strings.write_string(&state.prompt.input, words[0][len(prefix):])
+	prompt_changed(state)
+}
+
+// The text being completed before the cursor: a word, or the last name of a
+// path with the directory before it.
+@(private = "file")
+completion_prefix :: proc(state: ^Editor_State) -> (start: int, dir, prefix: string, path: bool) {
+	line := editor.get_line(&state.buffer, state.cursor_data.line, context.temp_allocator)
+	before := line[:min(state.cursor_data.col, len(line))]
+	line_start := state.cursor_pos - len(before)
+
+	token := len(before)
+	for token > 0 && is_path_byte(before[token - 1]) {
+		token -= 1
+	}
+	if slash := strings.last_index_byte(before[token:], '/'); slash >= 0 {
+		name := token + slash + 1
+		return line_start + name, before[token:name], before[name:], true
+	}
+	word := len(before)
+	for word > 0 && is_identifier_byte(before[word - 1]) {
+		word -= 1
+	}
+	return line_start + word, "", before[word:], false
+}
+
+@(private = "file")
+word_completions :: proc(
+	state: ^Editor_State,
+	start: int,
+	prefix: string,
+) -> []editor.Completion_Item {
+	words := buffer_words(state, prefix, start)
+	items := make([dynamic]editor.Completion_Item, context.temp_allocator)
+	for word in words[:min(len(words), COMPLETION_MAX_ITEMS)] {
+		append(&items, editor.Completion_Item{label = word, source = .Word})
+	}
+	return items[:]
+}
+
+// The words of the open buffers starting with prefix, those of the active
+// buffer closest to the cursor first.  The word at skip, the one being
+// typed, is left out.  Indexed buffers are looked up instead of scanned, see
+// buffer_index.odin.  Temp allocated.
+buffer_words :: proc(state: ^Editor_State, prefix: string, skip: int) -> []string {
+	ranks := make(map[string]int, allocator = context.temp_allocator)
+	size := editor.current_length(&state.buffer)
+	if index := usable_index(state, state.doc_id, &state.undo, size); index != nil {
+		editor.index_collect_words(&ranks, index, prefix, state.cursor_pos, skip, 0)
+	} else {
+		text := editor.get_text(&state.buffer, context.temp_allocator)
+		editor.collect_words(&ranks, text, prefix, state.cursor_pos, skip, 0)
+	}
+	for &d, i in state.documents {
+		if i == state.active {
+			continue
+		}
+		// Words of other buffers rank after every word of this one.
+		other_size := editor.current_length(&d.buffer)
+		if index := usable_index(state, d.id, &d.undo, other_size); index != nil {
+			editor.index_collect_words(&ranks, index, prefix, 0, -1, size + 1)
+		} else {
+			other := editor.get_text(&d.buffer, context.temp_allocator)
+			editor.collect_words(&ranks, other, prefix, 0, -1, size + 1)
+		}
+	}
+	return editor.rank_words(ranks, prefix)
+}
+
+// Names in dir starting with prefix; a relative dir is taken from the
+// buffer's directory.  Directories end in "/".
+@(private = "file")
+path_completions :: proc(state: ^Editor_State, dir, prefix: string) -> []editor.Completion_Item {
+	base := state.workspace_root
+	if state.path != "" {
+		base = filepath.dir(workspace_path(state, state.path), context.temp_allocator)
+	}
+	full := dir
+	if strings.has_prefix(dir, "~/") {
+		home := os.get_env("HOME", context.temp_allocator)
+		full = filepath.join({home, dir[2:]}, context.temp_allocator)
+	} else if !filepath.is_abs(dir) {
+		full = filepath.join({base, dir}, context.temp_allocator)
+	}
+	entries, err := os.read_all_directory_by_path(full, context.temp_allocator)
+	if err != nil {
+		return nil
+	}
+	slice.sort_by(entries, proc(a, b: os.File_Info) -> bool {
+		return a.name < b.name
+	})
+	items := make([dynamic]editor.Completion_Item, context.temp_allocator)
+	for e in entries {
+		if !strings.has_prefix(e.name, prefix) || e.name == prefix {
+			continue
+		}
+		if strings.has_prefix(e.name, ".") && !strings.has_prefix(prefix, ".") {
+			continue // hidden unless asked for
+		}
+		item := editor.Completion_Item {
+			label  = e.name,
+			detail = "file",
+			source = .Path,
+		}
+		if e.type == .Directory {
+			item.label = strings.concatenate({e.name, "/"}, context.temp_allocator)
+			item.detail = "dir"
+		}
+		append(&items, item)
+		if len(items) == COMPLETION_MAX_ITEMS {
+			break
+		}
+	}
+	return items[:]
+}
+
+is_identifier_byte :: proc(b: u8) -> bool {
+	switch b {
+	case 'a' ..= 'z', 'A' ..= 'Z', '0' ..= '9', '_':
+		return true
+	}
+	return false
+}
+
+@(private = "file")
+is_path_byte :: proc(b: u8) -> bool {
+	switch b {
+	case '/', '.', '-', '~', '+', '@':
+		return true
+	}
+	return is_identifier_byte(b)
+}
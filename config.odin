@@ -0,0 +1,1147 @@
+package main
+
+import "core:fmt"
+import "core:log"
+import "core:os"
+import "core:path/filepath"
+import "core:slice"
+import "core:strings"
+import "core:time"
+import editor "editor"
+
+CONFIG_FILE :: "config.toml" // in the user config dir, see user_config_path
+WORKSPACE_CONFIG_FILE :: ".rune/config.toml"
+CONFIG_CHECK_INTERVAL :: time.Second
+
+Config_Value :: union {
+	bool,
+	int,
+	string,
+	[]int,
+}
+
+Config_Kind :: enum u8 {
+	Bool,
+	Int,
+	String,
+	Int_List, // every element within min and max
+}
+
+// An option the config files may set.
+Config_Option :: struct {
+	key:       string,
+	kind:      Config_Kind,
+	default:   Config_Value, // nil when something else decides, e.g. the language
+	min, max:  int, // range of an Int or the elements of an Int_List
+	choices:   []string, // allowed values of a String, any when empty
+	help:      string,
+	trusted:   bool, // runs a command: the workspace file sets it only once trusted
+	user_only: bool, // the workspace file cannot set it, trusted or not
+}
+
+CONFIG_OPTIONS := [?]Config_Option {
+	{
+		key = "editor.tab_size",
+		kind = .Int,
+		default = 4,
+		min = 1,
+		max = 16,
+		help = "columns per tab stop and indent level",
+	},
+	{
+		key = "editor.insert_spaces",
+		kind = .Bool,
+		help = "indent with spaces instead of tabs; unset follows the language",
+	},
+	{
+		key = "editor.rulers",
+		kind = .Int_List,
+		min = 1,
+		max = 1000,
+		help = "columns to draw a vertical line at, e.g. [80, 100]",
+	},
+	{
+		key = "editor.ruler_style",
+		kind = .String,
+		default = "line",
+		choices = {"line", "band"},
+		help = "a line after each ruler column, or the column itself shaded",
+	},
+	{
+		key = "editor.highlight_line",
+		kind = .Bool,
+		default = true,
+		help = "shade the line the cursor is on",
+	},
+	{
+		key = "editor.highlight_column",
+		kind = .Bool,
+		default = false,
+		help = "shade the column the cursor is in, down the window",
+	},
+	{
+		key = "editor.smart_home",
+		kind = .Bool,
+		default = true,
+		help = "home goes to the first non-blank, then column 0; end likewise from the last one",
+	},
+	{
+		key = "editor.word_chars",
+		kind = .String,
+		help = "characters words hold besides letters, digits and _; unset follows the language",
+	},
+	{
+		key = "editor.subword_motion",
+		kind = .Bool,
+		default = false,
+		help = "word motions stop at camelCase humps and snake_case parts too",
+	},
+	{
+		key = "editor.paste_reindent",
+		kind = .Bool,
+		default = true,
+		help = "move a pasted block of lines to the indentation where it lands",
+	},
+	{
+		key = "editor.large_paste",
+		kind = .Int,
+		default = 1024,
+		min = 1,
+		max = 1 << 20,
+		help = "KB from which a paste goes in as it is, unindented and not kept in the history",
+	},
+	{
+		key = "editor.color_swatches",
+		kind = .Bool,
+		default = true,
+		help = "underline color literals in CSS, HTML and config files, and colors plugins report",
+	},
+	{
+		key = "editor.breadcrumbs",
+		kind = .Bool,
+		default = true,
+		help = "show the symbols around the cursor, package to function, in a bar above the text",
+	},
+	{
+		key = "editor.line_numbers",
+		kind = .String,
+		default = "absolute",
+		choices = {"absolute", "relative", "hybrid"},
+		help = "relative counts from the cursor's line; hybrid shows that line's own number",
+	},
+	{
+		key = "editor.scroll_margin",
+		kind = .Int,
+		default = 0,
+		min = 0,
+		max = 50,
+		help = "lines kept visible above and below the cursor",
+	},
+	{
+		key = "editor.smooth_scroll",
+		kind = .Bool,
+		default = false,
+		help = "glide to a new scroll position instead of jumping",
+	},
+	{
+		key = "prose.fill_column",
+		kind = .Int,
+		default = 80,
+		min = 10,
+		max = 1000,
+		help = "width prose.reflow and prose.hard_wrap fill Markdown and text lines to",
+	},
+	{
+		key = "prose.hard_wrap",
+		kind = .Bool,
+		default = false,
+		help = "break Markdown and text lines while typing past prose.fill_column",
+	},
+	{
+		key = "table.auto_align",
+		kind = .Bool,
+		default = true,
+		help = "line up a Markdown table's columns after each character typed in it",
+	},
+	{
+		key = "csv.header",
+		kind = .Bool,
+		default = true,
+		help = "view.csv pins the first row as a header and leaves it out of sorting",
+	},
+	{
+		key = "json.schema",
+		kind = .String,
+		default = "",
+		help = "JSON Schema file json.validate checks JSON buffers against, else their \"$schema\"",
+	},
+	{
+		key = "json.breadcrumbs",
+		kind = .Bool,
+		default = true,
+		help = "show the JSONPath or YAML path of the cursor in the status line",
+	},
+	{
+		key = "file.root_helper",
+		kind = .String,
+		default = "",
+		help = "command file.save_as_root runs cp through, pkexec or sudo -A when empty",
+		user_only = true,
+	},
+	{
+		key = "backup.mode",
+		kind = .String,
+		default = "none",
+		choices = {"none", "simple", "numbered", "timestamped"},
+		help = "copy a file before a save replaces it: file~, file.~1~ or file.~<UTC time>~",
+	},
+	{
+		key = "backup.dir",
+		kind = .String,
+		default = "",
+		help = "directory for the backups, relative to the workspace root; empty is the file's",
+	},
+	{
+		key = "backup.keep",
+		kind = .Int,
+		default = 0,
+		min = 0,
+		max = 10000,
+		help = "numbered or timestamped backups kept per file; 0 keeps all",
+	},
+	{
+		key = "history.enabled",
+		kind = .Bool,
+		default = true,
+		help = "keep a copy of every save for history.list and history.restore",
+	},
+	{
+		key = "history.keep",
+		kind = .Int,
+		default = 100,
+		min = 1,
+		max = 10000,
+		help = "saved versions kept per file, the oldest removed first",
+	},
+	{
+		key = "docs.width",
+		kind = .Int,
+		default = 60,
+		min = 30,
+		max = 200,
+		help = "columns of the documentation pane, at most 3/5 of the window",
+	},
+	{
+		key = "docs.completion",
+		kind = .Bool,
+		default = true,
+		help = "show the highlighted completion's documentation while the popup is open",
+	},
+	{
+		key = "peek.height",
+		kind = .Int,
+		default = 10,
+		min = 3,
+		max = 40,
+		help = "lines of the file a peek at a definition or reference shows",
+	},
+	{
+		key = "tags.file",
+		kind = .String,
+		default = "tags",
+		help = "the workspace's ctags file, relative to its root",
+	},
+	{
+		key = "tags.command",
+		kind = .String,
+		default = "ctags -R --fields=+n",
+		help = "command tags.generate runs in the workspace root, given -f and the file",
+		trusted = true,
+	},
+	{
+		key = "tags.auto",
+		kind = .Bool,
+		default = true,
+		help = "write the tags file again after each save when there is one",
+		trusted = true,
+	},
+	{
+		key = "index.file",
+		kind = .String,
+		default = "dump.lsif",
+		help = "the workspace's LSIF dump, relative to its root",
+	},
+	{
+		key = "index.external",
+		kind = .String,
+		default = "",
+		help = "LSIF dumps of other repositories, separated by ';', for imported names",
+	},
+	{
+		key = "lsp.transport",
+		kind = .String,
+		default = "stdio",
+		choices = {"stdio", "tcp", "pipe"},
+		help = "how to reach a language server; set one server's in a [server.<name>] table",
+	},
+	{
+		key = "lsp.address",
+		kind = .String,
+		default = "",
+		help = "host:port for tcp, or the unix socket or named pipe for pipe",
+	},
+	{
+		key = "lsp.initialization_options",
+		kind = .String,
+		default = "",
+		help = "JSON a server is initialized with, set in its [server.<name>] table",
+	},
+	{
+		key = "todo.states",
+		kind = .String,
+		default = "TODO DONE",
+		help = "the words todo.cycle steps through, in order, such as \"TODO DOING DONE\"",
+	},
+	{
+		key = "zen.width",
+		kind = .Int,
+		default = 80,
+		min = 20,
+		max = 400,
+		help = "columns of text view.zen centers in the window",
+	},
+	{
+		key = "zen.dim_paragraphs",
+		kind = .Bool,
+		default = false,
+		help = "in view.zen, dim every paragraph but the cursor's",
+	},
+	{
+		key = "git.change_marks",
+		kind = .Bool,
+		default = true,
+		help = "mark uncommitted changes in the gutter",
+	},
+	{
+		key = "format.command",
+		kind = .String,
+		help = "formats stdin to stdout, {file} is the path; unset uses the language's usual one",
+		trusted = true,
+	},
+	{
+		key = "format.on_save",
+		kind = .Bool,
+		default = false,
+		help = "run the formatter before saving",
+		trusted = true,
+	},
+	{
+		key = "imports.command",
+		kind = .String,
+		help = "organizes the imports of stdin to stdout; unset uses the language's usual tool",
+		trusted = true,
+	},
+	{
+		key = "imports.on_save",
+		kind = .Bool,
+		default = false,
+		help = "organize the imports before saving, and before format.on_save",
+		trusted = true,
+	},
+	{
+		key = "format.on_type",
+		kind = .Bool,
+		default = true,
+		help = "reformat what was typed after the trigger characters of plugins' formatters",
+	},
+	{
+		key = "abbreviations.enabled",
+		kind = .Bool,
+		default = true,
+		help = "expand abbreviations after a word, see abbreviations.json",
+	},
+	{
+		key = "completion.auto",
+		kind = .Bool,
+		default = true,
+		help = "show completions while typing, not only on completion.trigger",
+	},
+	{
+		key = "completion.min_chars",
+		kind = .Int,
+		default = 3,
+		min = 1,
+		max = 20,
+		help = "characters of a word typed before completions show",
+	},
+	{
+		key = "completion.call_placeholders",
+		kind = .Bool,
+		default = false,
+		help = "completing a function adds its call, parameters as tab stops",
+	},
+	{
+		key = "completion.inline",
+		kind = .Bool,
+		default = true,
+		help = "show plugins' suggestions after the cursor as ghost text",
+	},
+	{
+		key = "clipboard.history",
+		kind = .Int,
+		default = 20,
+		min = 1,
+		max = 1000,
+		help = "copies kept for edit.paste_history",
+	},
+	{
+		key = "clipboard.osc52",
+		kind = .Bool,
+		help = "also copy to the terminal with OSC 52; unset does over ssh",
+	},
+	{
+		key = "collab.port",
+		kind = .Int,
+		default = 7878,
+		min = 1,
+		max = 65535,
+		help = "TCP port collab.host listens on and collab.join tries first",
+	},
+	{
+		key = "collab.address",
+		kind = .String,
+		default = "127.0.0.1",
+		help = "address collab.host listens on; 0.0.0.0 lets any machine join, unasked",
+		user_only = true,
+	},
+	{
+		key = "collab.name",
+		kind = .String,
+		help = "the name others see over your cursor; unset uses $USER",
+	},
+	{
+		key = "keys.which_key",
+		kind = .Bool,
+		default = true,
+		help = "after a prefix key, list the keys that may follow and what they run",
+	},
+	{
+		key = "keys.which_key_delay",
+		kind = .Int,
+		default = 400,
+		min = 0,
+		max = 10_000,
+		help = "milliseconds a prefix key waits before the list shows",
+	},
+	{
+		key = "unicode.data_file",
+		kind = .String,
+		help = "UnicodeData.txt with the character names; unset looks in /usr/share",
+	},
+	{
+		key = "large_file.size",
+		kind = .Int,
+		default = 8,
+		min = 1,
+		max = 4096,
+		help = "MB from which git marks, blame, completion while typing and paths are off",
+	},
+	{
+		key = "large_file.huge_size",
+		kind = .Int,
+		default = 32,
+		min = 1,
+		max = 4096,
+		help = "MB from which linting and formatting on save, recovery and history are off too",
+	},
+	{
+		key = "memory.budget",
+		kind = .Int,
+		default = 1024,
+		min = 64,
+		max = 1 << 20,
+		help = "MB the buffers may hold before background ones drop indexes and undo history",
+	},
+	{
+		key = "metrics.port",
+		kind = .Int,
+		min = 0,
+		max = 65535,
+		help = "serve the metrics.show report on 127.0.0.1 at this port; 0 is off",
+	},
+	{
+		key = "search.max_matches",
+		kind = .Int,
+		default = SEARCH_MAX_MATCHES,
+		min = 1,
+		max = 1_000_000,
+		help = "project search stops after this many matches",
+	},
+}
+
+// Where a value comes from, weakest first.  Within the files, the most
+// specific table wins for the active buffer: a [files."<glob>"] table that
+// matches its path, then its [language.<id>] table, then the top level; a
+// workspace table beats a user table of the same kind.  [server.<name>]
+// tables hold a language server's settings, see server_config_value.
+Config_Scope :: enum u8 {
+	Default,
+	User,
+	Workspace,
+	Override, // config.set, until the editor exits
+}
+
+Config_Setting :: struct {
+	value: Config_Value,
+	line:  int, // in the layer's file, 0 for an override
+}
+
+// The settings of one scope, by key; language tables as
+// "language.<id>.<key>", glob tables as "files.<glob>.<key>" and server
+// tables as "server.<name>.<key>".
+Config_Layer :: struct {
+	path:     string, // the file, empty for overrides
+	settings: map[string]Config_Setting,
+	globs:    [dynamic]string, // of the files tables, in file order
+	mtime:    time.Time, // of the file as last read, zero when missing
+}
+
+Config_State :: struct {
+	layers:     [Config_Scope]Config_Layer, // Default stays empty
+	last_check: time.Tick,
+	active:     bool, // the panel lists the settings
+	editing:    int, // index into CONFIG_OPTIONS of the option being edited
+}
+
+// Reads the user and workspace config files and applies them.
+load_config :: proc(state: ^Editor_State) {
+	c := &state.config
+	if path, ok := user_config_path(CONFIG_FILE); ok {
+		c.layers[.User].path = strings.clone(path)
+	}
+	c.layers[.Workspace].path = strings.clone(workspace_path(state, WORKSPACE_CONFIG_FILE))
+	problems := 0
+	for scope in ([]Config_Scope{.User, .Workspace}) {
+		problems += read_config_layer(state, scope)
+	}
+	c.last_check = time.tick_now()
+	if problems > 0 {
+		set_message(state, "Config has %d problems, see log.open", problems)
+	}
+	apply_config(state)
+}
+
+// Reads the config of a new workspace in place of the old one's.
+reload_workspace_config :: proc(state: ^Editor_State) {
+	if read_workspace_config(state) > 0 {
+		set_message(state, "Config has problems, see log.open")
+	}
+	apply_config(state)
+}
+
+// Reads the workspace's config file into its layer without applying it.
+// Returns the number of problems.
+read_workspace_config :: proc(state: ^Editor_State) -> (problems: int) {
+	layer := &state.config.layers[.Workspace]
+	delete(layer.path)
+	layer.path = strings.clone(workspace_path(state, WORKSPACE_CONFIG_FILE))
+	return read_config_layer(state, .Workspace)
+}
+
+destroy_config :: proc(state: ^Editor_State) {
+	for &layer in state.config.layers {
+		clear_config_layer(&layer)
+		delete(layer.settings)
+		delete(layer.globs)
+		delete(layer.path)
+	}
+}
+
+// Reloads a config file that changed on disk.  Called every frame.
+check_config_changes :: proc(state: ^Editor_State) {
+	c := &state.config
+	if time.tick_since(c.last_check) < CONFIG_CHECK_INTERVAL {
+		return
+	}
+	c.last_check = time.tick_now()
+	for scope in ([]Config_Scope{.User, .Workspace}) {
+		layer := &c.layers[scope]
+		if layer.path == "" {
+			continue
+		}
+		mtime: time.Time
+		if fi, err := os.stat(layer.path, context.temp_allocator); err == nil {
+			mtime = fi.modification_time
+		}
+		if mtime == layer.mtime {
+			continue
+		}
+		if problems := read_config_layer(state, scope); problems > 0 {
+			set_message(state, "Reloaded %s: %d problems, see log.open", layer.path, problems)
+		} else {
+			set_message(state, "Reloaded %s", layer.path)
+		}
+		apply_config(state)
+	}
+}
+
+// The value of key for the active buffer, see Config_Scope.
+config_value :: proc(state: ^Editor_State, key: string) -> Config_Value {
+	value, _, _ := resolve_config(state, key)
+	return value
+}
+
+config_int :: proc(state: ^Editor_State, key: string) -> int {
+	return config_value(state, key).(int) or_else 0
+}
+
+config_bool :: proc(state: ^Editor_State, key: string) -> bool {
+	return config_value(state, key).(bool) or_else false
+}
+
+// The value of key for the language server name: its [server.<name>] table
+// in the workspace or user file, else the value for the active buffer.
+server_config_value :: proc(state: ^Editor_State, name, key: string) -> Config_Value {
+	server_key := fmt.tprintf("server.%s.%s", name, key)
+	for sc in ([]Config_Scope{.Workspace, .User}) {
+		if s, ok := state.config.layers[sc].settings[server_key]; ok {
+			return s.value
+		}
+	}
+	return config_value(state, key)
+}
+
+// Makes the settings that live elsewhere follow the config, e.g. after a
+// reload or a switch to a buffer of another language.
+apply_config :: proc(state: ^Editor_State) {
+	state.layer_ctx.tab_size = config_int(state, "editor.tab_size")
+	editor.set_rulers(state.ruler_data, config_value(state, "editor.rulers").([]int) or_else nil)
+	ruler_style := config_value(state, "editor.ruler_style").(string) or_else ""
+	state.ruler_data.band = ruler_style == "band"
+	state.cursor_line_data.line = config_bool(state, "editor.highlight_line")
+	state.cursor_line_data.column = config_bool(state, "editor.highlight_column")
+	switch config_value(state, "editor.line_numbers").(string) or_else "" {
+	case "relative":
+		state.gutter_data.mode = .Relative
+	case "hybrid":
+		state.gutter_data.mode = .Hybrid
+	case:
+		state.gutter_data.mode = .Absolute
+	}
+}
+
+// Switches the buffer to another language, which may change its options.
+set_language :: proc(state: ^Editor_State, id: string) {
+	state.language = id
+	apply_config(state)
+}
+
+// Prompts for `key=value` and overrides the config with it until the editor
+// exits; `key=` drops the override and a bare Bool key turns it on.  The
+// key may be shortened to its last parts, e.g. `tab_size=2`, when only one
+// option ends that way.
+set_config_option :: proc(state: ^Editor_State) {
+	open_prompt(state, "Set (key=value):", proc(state: ^Editor_State, input: string) {
+		name, _, text := strings.partition(strings.trim_space(input), "=")
+		name = strings.trim_space(name)
+		text = strings.trim_space(text)
+		if name == "" {
+			return
+		}
+		option := find_config_option(name)
+		if option == nil {
+			matches := make([dynamic]string, context.temp_allocator)
+			for &o in CONFIG_OPTIONS {
+				if strings.has_suffix(o.key, name) && o.key[len(o.key) - len(name) - 1] == '.' {
+					option = &o
+					append(&matches, o.key)
+				}
+			}
+			if len(matches) > 1 {
+				keys := strings.join(matches[:], ", ", context.temp_allocator)
+				set_message(state, "%s could be %s; give the whole key", name, keys)
+				return
+			}
+		}
+		if option == nil {
+			set_message(state, "%s", unknown_option_message(name))
+			return
+		}
+
+		overrides := &state.config.layers[.Override].settings
+		if text == "" && strings.contains(input, "=") {
+			drop_override(state, option.key)
+			apply_config(state)
+			value := format_config_value(config_value(state, option.key))
+			set_message(state, "%s is %s again", option.key, value)
+			return
+		}
+		raw: editor.Toml_Value = true
+		if text != "" {
+			ok: bool
+			if raw, ok = editor.parse_toml_value(text); !ok {
+				raw = text // a bare word for a String
+			}
+		}
+		value, problem := convert_config_value(option, raw)
+		if problem != "" {
+			set_message(state, "%s", problem)
+			return
+		}
+		drop_override(state, option.key)
+		overrides[strings.clone(option.key)] = {value = clone_config_value(value)}
+		apply_config(state)
+		set_message(state, "%s = %s until the editor exits", option.key, format_config_value(value))
+	})
+}
+
+// Lists every option with its description, its value for the active buffer
+// and where that comes from.  Enter edits the selected option and saves it
+// to the user config file.
+show_settings :: proc(state: ^Editor_State) {
+	clear_replace(state)
+	stop_project_search(state)
+	release_panel(state)
+	state.config.active = true
+	list_settings(state)
+	show_panel(state)
+}
+
+// Enter in the settings panel: asks for the selected option's new value.
+edit_setting :: proc(state: ^Editor_State) {
+	item := editor.panel_selected_item(state.panel_data)
+	if item == nil || item.data >= len(CONFIG_OPTIONS) {
+		return
+	}
+	state.config.editing = item.data
+	option := &CONFIG_OPTIONS[item.data]
+	initial := ""
+	if s, ok := state.config.layers[.User].settings[option.key]; ok {
+		initial = config_literal(s.value)
+	}
+	editor.panel_set_title(
+		state.panel_data,
+		fmt.tprintf("%s: %s.  Empty removes it from the user config.", option.key, option.help),
+	)
+	open_prompt(state, "Value:", proc(state: ^Editor_State, text: string) {
+		option := &CONFIG_OPTIONS[state.config.editing]
+		text := strings.trim_space(text)
+		literal := ""
+		if text != "" {
+			raw, ok := editor.parse_toml_value(text)
+			if !ok {
+				raw = text // a bare word for a String
+			}
+			value, problem := convert_config_value(option, raw)
+			if problem != "" {
+				set_message(state, "%s", problem)
+				list_settings(state)
+				return
+			}
+			literal = config_literal(value)
+		}
+		if !write_user_setting(state, option.key, literal) {
+			list_settings(state)
+			return
+		}
+		read_config_layer(state, .User)
+		apply_config(state)
+		list_settings(state)
+		if literal == "" {
+			set_message(state, "Removed %s from %s", option.key, state.config.layers[.User].path)
+		} else {
+			set_message(state, "Saved %s = %s", option.key, literal)
+		}
+	}, initial = initial)
+}
+
+@(private = "file")
+list_settings :: proc(state: ^Editor_State) {
+	panel := state.panel_data
+	selected := panel.selected
+	editor.panel_clear(panel)
+	editor.panel_set_title(panel, "Settings; enter edits, saving to the user config")
+	for option, i in CONFIG_OPTIONS {
+		_, scope, _ := resolve_config(state, option.key)
+		source := "default"
+		switch scope {
+		case .Default:
+		case .User:
+			source = "user"
+		case .Workspace:
+			source = "workspace"
+		case .Override:
+			source = "set"
+		}
+		// Only the key line has a path, which makes it the one to select.
+		header := editor.Panel_Item {
+			text  = option.key,
+			path  = option.key,
+			style = .Header,
+			data  = i,
+		}
+		editor.panel_add_item(panel, header)
+		value := format_config_value(config_value(state, option.key))
+		item := editor.Panel_Item {
+			text = fmt.tprintf("    %s  (%s)  %s", value, source, option.help),
+			data = i,
+		}
+		editor.panel_add_item(panel, item)
+	}
+	panel.selected = clamp(selected, 0, max(len(panel.items) - 1, 0))
+}
+
+// Sets key to literal at the top level of the user config file, or removes
+// it there when literal is empty, keeping the rest of the file as it is.
+@(private = "file")
+write_user_setting :: proc(state: ^Editor_State, key, literal: string) -> bool {
+	path := state.config.layers[.User].path
+	if path == "" {
+		set_message(state, "There is no user config directory")
+		return false
+	}
+	data, _ := os.read_entire_file_from_path(path, context.temp_allocator)
+	lines := make([dynamic]string, context.temp_allocator)
+	append(&lines, ..strings.split(string(data), "\n", context.temp_allocator))
+
+	if s, ok := state.config.layers[.User].settings[key]; ok {
+		i := s.line - 1
+		name, _, _ := strings.partition(lines[i], "=")
+		if !strings.has_suffix(key, strings.trim_space(name)) {
+			set_message(state, "Change %s on line %d of %s by hand", key, s.line, path)
+			return false
+		}
+		if literal == "" {
+			ordered_remove(&lines, i)
+		} else {
+			lines[i] = fmt.tprintf("%s= %s", name, literal)
+		}
+	} else if literal != "" {
+		// Above the first [table], where a dotted key means what it says.
+		at := len(lines)
+		for line, i in lines {
+			if strings.has_prefix(strings.trim_space(line), "[") {
+				at = i
+				break
+			}
+		}
+		if at == len(lines) && at > 0 && lines[at - 1] == "" {
+			at -= 1 // before the final newline
+		}
+		inject_at(&lines, at, fmt.tprintf("%s = %s", key, literal))
+	} else {
+		return true
+	}
+
+	text := strings.join(lines[:], "\n", context.temp_allocator)
+	if !strings.has_suffix(text, "\n") {
+		text = strings.concatenate({text, "\n"}, context.temp_allocator)
+	}
+	_ = os.make_directory_all(filepath.dir(path, context.temp_allocator))
+	if err := write_file_atomic(path, transmute([]u8)text); err != nil {
+		set_message(state, "Cannot write %s: %v", path, err)
+		return false
+	}
+	return true
+}
+
+// value as TOML.  Temp allocated.
+@(private = "file")
+config_literal :: proc(value: Config_Value) -> string {
+	if s, ok := value.(string); ok {
+		return fmt.tprintf("%q", s)
+	}
+	return fmt.tprint(value)
+}
+
+// The value of key for the active buffer, the scope it comes from and its
+// line in that scope's file.
+@(private = "file")
+resolve_config :: proc(
+	state: ^Editor_State,
+	key: string,
+) -> (
+	value: Config_Value,
+	scope: Config_Scope,
+	line: int,
+) {
+	layers := &state.config.layers
+	if s, ok := layers[.Override].settings[key]; ok {
+		return s.value, .Override, s.line
+	}
+	keys := make([dynamic]string, context.temp_allocator)
+	if path := bookmark_path(state); path != "" {
+		for sc in ([]Config_Scope{.Workspace, .User}) {
+			#reverse for glob in layers[sc].globs {
+				if config_glob_matches(glob, path) {
+					append(&keys, fmt.tprintf("files.%s.%s", glob, key))
+				}
+			}
+		}
+	}
+	append(&keys, fmt.tprintf("language.%s.%s", state.language, key), key)
+	for k in keys {
+		for sc in ([]Config_Scope{.Workspace, .User}) {
+			if s, ok := layers[sc].settings[k]; ok {
+				return s.value, sc, s.line
+			}
+		}
+	}
+	if option := find_config_option(key); option != nil {
+		return option.default, .Default, 0
+	}
+	return nil, .Default, 0
+}
+
+// A glob without a slash matches the file name, one with a slash the path
+// relative to the workspace root.
+@(private = "file")
+config_glob_matches :: proc(glob, path: string) -> bool {
+	name := path if strings.contains_rune(glob, '/') else filepath.base(path)
+	matched, _ := filepath.match(glob, name)
+	return matched
+}
+
+// Reads the file of scope into its layer, logging every problem.  A missing
+// file is an empty layer; a file that does not parse keeps nothing of it.
+// An untrusted workspace cannot set the options that run commands, and no
+// workspace those that are user_only.  Returns the number of problems.
+@(private = "file")
+read_config_layer :: proc(state: ^Editor_State, scope: Config_Scope) -> (problems: int) {
+	layer := &state.config.layers[scope]
+	clear_config_layer(layer)
+	layer.mtime = {}
+	if layer.path == "" {
+		return 0
+	}
+	if fi, err := os.stat(layer.path, context.temp_allocator); err == nil {
+		layer.mtime = fi.modification_time
+	}
+	data, err := os.read_entire_file_from_path(layer.path, context.temp_allocator)
+	if err != nil {
+		return 0
+	}
+	entries, perr, ok := editor.parse_toml(string(data))
+	if !ok {
+		log.warnf("config: %s:%d: %s", layer.path, perr.line, perr.message)
+		return 1
+	}
+	for e in entries {
+		key := e.key
+		table, _, _ := strings.partition(key, ".")
+		if scope == .Workspace && slice.contains(PROJECT_TABLES[:], table) {
+			continue // see load_project_settings
+		}
+		if strings.has_prefix(key, "files.") {
+			// The glob may hold dots itself, so find the option at the end.
+			glob := ""
+			for option in CONFIG_OPTIONS {
+				end := len(key) - len(option.key) - 1
+				if end > len("files.") && key[end] == '.' && key[end + 1:] == option.key {
+					glob = key[len("files."):end]
+					key = option.key
+					break
+				}
+			}
+			if glob == "" {
+				log.warnf("config: %s:%d: %s", layer.path, e.line, unknown_option_message(key))
+				problems += 1
+				continue
+			}
+			if !slice.contains(layer.globs[:], glob) {
+				append(&layer.globs, strings.clone(glob))
+			}
+		} else if strings.has_prefix(key, "language.") {
+			id, _, rest := strings.partition(key[len("language."):], ".")
+			if editor.find_language(id) == nil {
+				log.warnf("config: %s:%d: unknown language %q", layer.path, e.line, id)
+				problems += 1
+				continue
+			}
+			key = rest
+		} else if strings.has_prefix(key, "server.") {
+			name, _, rest := strings.partition(key[len("server."):], ".")
+			if name == "" || rest == "" {
+				log.warnf("config: %s:%d: %s", layer.path, e.line, unknown_option_message(key))
+				problems += 1
+				continue
+			}
+			key = rest
+		}
+		option := find_config_option(key)
+		if option == nil {
+			log.warnf("config: %s:%d: %s", layer.path, e.line, unknown_option_message(key))
+			problems += 1
+			continue
+		}
+		if scope == .Workspace && option.user_only {
+			log.warnf("config: %s:%d: only the user config can set %s", layer.path, e.line, e.key)
+			problems += 1
+			continue
+		}
+		if scope == .Workspace && option.trusted && !workspace_trusted(state) {
+			log.warnf(
+				"config: %s:%d: %s waits until the workspace is trusted, see workspace.trust",
+				layer.path,
+				e.line,
+				e.key,
+			)
+			problems += 1
+			continue
+		}
+		value, problem := convert_config_value(option, e.value)
+		if problem != "" {
+			log.warnf("config: %s:%d: %s", layer.path, e.line, problem)
+			problems += 1
+			continue
+		}
+		layer.settings[strings.clone(e.key)] = {clone_config_value(value), e.line}
+	}
+	return problems
+}
+
+// Checks raw against the option's schema.  Returns a message for the user
+// when it does not fit.  The value may borrow from raw.
+@(private = "file")
+convert_config_value :: proc(
+	option: ^Config_Option,
+	raw: editor.Toml_Value,
+) -> (
+	value: Config_Value,
+	problem: string,
+) {
+	switch option.kind {
+	case .Bool:
+		if b, ok := raw.(bool); ok {
+			return b, ""
+		}
+		return nil, fmt.tprintf("%s must be true or false, not %v", option.key, raw)
+	case .Int:
+		n, ok := raw.(i64)
+		if !ok || int(n) < option.min || int(n) > option.max {
+			return nil, fmt.tprintf(
+				"%s must be a whole number from %d to %d, not %v",
+				option.key,
+				option.min,
+				option.max,
+				raw,
+			)
+		}
+		return int(n), ""
+	case .Int_List:
+		items, is_list := raw.([]editor.Toml_Value)
+		if !is_list {
+			return nil, fmt.tprintf("%s must be a list like [80, 100], not %v", option.key, raw)
+		}
+		list := make([]int, len(items), context.temp_allocator)
+		for item, i in items {
+			n, ok := item.(i64)
+			if !ok || int(n) < option.min || int(n) > option.max {
+				return nil, fmt.tprintf(
+					"%s must be a list of whole numbers from %d to %d, not %v",
+					option.key,
+					option.min,
+					option.max,
+					raw,
+				)
+			}
+			list[i] = int(n)
+		}
+		return list, ""
+	case .String:
+		s, ok := raw.(string)
+		if !ok {
+			return nil, fmt.tprintf("%s must be a string in quotes, not %v", option.key, raw)
+		}
+		if len(option.choices) == 0 {
+			return s, ""
+		}
+		for choice in option.choices {
+			if s == choice {
+				return s, ""
+			}
+		}
+		return nil, fmt.tprintf(
+			"%s must be one of %s, not %q",
+			option.key,
+			strings.join(option.choices, ", ", context.temp_allocator),
+			s,
+		)
+	}
+	return nil, ""
+}
+
+@(private = "file")
+unknown_option_message :: proc(key: string) -> string {
+	best, best_distance := "", 4 // farther than this is no suggestion
+	for option in CONFIG_OPTIONS {
+		d := strings.levenshtein_distance(key, option.key, context.temp_allocator)
+		if d < best_distance {
+			best, best_distance = option.key, d
+		}
+	}
+	if best != "" {
+		return fmt.tprintf("unknown option %q, did you mean %q?", key, best)
+	}
+	return fmt.tprintf("unknown option %q", key)
+}
+
+find_config_option :: proc(key: string) -> ^Config_Option {
+	for &option in CONFIG_OPTIONS {
+		if option.key == key {
+			return &option
+		}
+	}
+	return nil
+}
+
+format_config_value :: proc(value: Config_Value) -> string {
+	if value == nil {
+		return "unset"
+	}
+	return fmt.tprint(value)
+}
+
+@(private = "file")
+drop_override :: proc(state: ^Editor_State, key: string) {
+	overrides := &state.config.layers[.Override].settings
+	if key in overrides {
+		old_key, old := delete_key(overrides, key)
+		delete(old_key)
+		destroy_config_value(old.value)
+	}
+}
+
+@(private = "file")
+clone_config_value :: proc(value: Config_Value) -> Config_Value {
+	#partial switch v in value {
+	case string:
+		return strings.clone(v)
+	case []int:
+		return slice.clone(v)
+	}
+	return value
+}
+
+@(private = "file")
+destroy_config_value :: proc(value: Config_Value) {
+	#partial switch v in value {
+	case string:
+		delete(v)
+	case []int:
+		delete(v)
+	}
+}
+
+@(private = "file")
+clear_config_layer :: proc(layer: ^Config_Layer) {
+	for key, s in layer.settings {
+		delete(key)
+		destroy_config_value(s.value)
+	}
+	clear(&layer.settings)
+	for glob in layer.globs {
+		delete(glob)
+	}
+	clear(&layer.globs)
+}
@@ -0,0 +1,192 @@
+package main
+
+import "base:runtime"
+import "core:c/libc"
+import "core:debug/trace"
+import "core:fmt"
+import "core:os"
+import "core:path/filepath"
+import "core:strings"
+import "core:time"
+import editor "editor"
+
+// Commands kept for the crash report, the latest last.
+CRASH_RECENT_COMMANDS :: 32
+CRASH_MAX_FRAMES :: 64
+
+// When the editor panics or an assertion fails, the modified buffers are
+// snapshotted for offer_recovery and a report goes to <user cache
+// dir>/rune/crashes: where it happened, the commands that led there and the
+// open buffers, without their text.  A fatal signal (a failed bounds check
+// traps, on any thread) only gets a line on stderr, since its handler may do
+// nothing that is not async-signal-safe; the periodic snapshots are what
+// recovery has then.  There is no terminal to restore; the window goes away
+// with the process.
+Crash_State :: struct {
+	dir:      string,
+	recent:   [CRASH_RECENT_COMMANDS]string, // ring, count % CRASH_RECENT_COMMANDS is next
+	count:    int,
+	crashing: bool, // a report is being written; a second crash just dies
+}
+
+// assertion_failure_proc cannot be handed the state.
+@(private = "file")
+crash_target: ^Editor_State
+
+init_crash_handler :: proc(state: ^Editor_State) {
+	cache, err := os.user_cache_dir(context.temp_allocator)
+	if err == nil {
+		state.crash.dir = filepath.join({cache, "rune", "crashes"})
+	}
+	crash_target = state
+	signals := [?]libc.int{libc.SIGSEGV, libc.SIGILL, libc.SIGFPE, libc.SIGABRT}
+	for sig in signals {
+		libc.signal(sig, crash_signal_handler)
+	}
+}
+
+destroy_crash_handler :: proc(state: ^Editor_State) {
+	crash_target = nil
+	for name in state.crash.recent {
+		delete(name)
+	}
+	delete(state.crash.dir)
+}
+
+// The context for the GLFW and plugin callbacks, which log and report their
+// panics like the main loop.
+callback_context :: proc "contextless" () -> runtime.Context {
+	c := runtime.default_context()
+	c.assertion_failure_proc = crash_assertion_failure
+	c.logger = session_logger()
+	return c
+}
+
+// Remembers a command for the report.  Called by run_command.
+remember_command :: proc(state: ^Editor_State, name: string) {
+	c := &state.crash
+	slot := &c.recent[c.count % CRASH_RECENT_COMMANDS]
+	delete(slot^)
+	slot^ = strings.clone(name)
+	c.count += 1
+}
+
+// context.assertion_failure_proc: panic, assert and unreachable end here.
+crash_assertion_failure :: proc(prefix, message: string, loc: runtime.Source_Code_Location) -> ! {
+	reason := fmt.tprintf("%s: %s", prefix, message) if message != "" else prefix
+	handle_crash(reason, loc)
+	runtime.default_assertion_failure_proc(prefix, message, loc)
+}
+
+// Writes a message made beforehand, as a literal, and no more: the state
+// may be half updated and the allocator's lock held.
+@(private = "file")
+crash_signal_handler :: proc "c" (sig: libc.int) {
+	message: string
+	switch sig {
+	case libc.SIGSEGV:
+		message = "rune: crashed with SIGSEGV, invalid memory access\n"
+	case libc.SIGILL:
+		message = "rune: crashed with SIGILL, a trap such as a failed bounds check\n"
+	case libc.SIGFPE:
+		message = "rune: crashed with SIGFPE, arithmetic error\n"
+	case:
+		message = "rune: crashed with SIGABRT\n"
+	}
+	write_crash_message(message)
+	// Die of the signal as if there were no handler.
+	libc.signal(sig, libc.SIG_DFL)
+	libc.raise(sig)
+}
+
+// Saves what can be saved and writes the report, once.
+@(private = "file")
+handle_crash :: proc(reason: string, loc: runtime.Source_Code_Location) {
+	state := crash_target
+	if state == nil || state.crash.crashing {
+		return
+	}
+	state.crash.crashing = true
+	saved := snapshot_all_documents(state)
+	report := crash_report(state, reason, loc, saved)
+	fmt.eprintln(report)
+	if state.crash.dir == "" {
+		return
+	}
+	_ = os.make_directory_all(state.crash.dir)
+	name := fmt.tprintf("crash-%d-%d.txt", time.to_unix_seconds(time.now()), os.get_pid())
+	path := filepath.join({state.crash.dir, name}, context.temp_allocator)
+	if err := write_file_atomic(path, transmute([]u8)report); err != nil {
+		fmt.eprintln("crash: failed to write the report:", err)
+		return
+	}
+	fmt.eprintln("rune: crashed; the report is in", path)
+}
+
+// The report's text: no buffer contents, so it can be attached to an issue.
+@(private = "file")
+crash_report :: proc(
+	state: ^Editor_State,
+	reason: string,
+	loc: runtime.Source_Code_Location,
+	saved: int,
+) -> string {
+	b := strings.builder_make(context.temp_allocator)
+	fmt.sbprintf(&b, "Rune crashed: %s\n", reason)
+	if loc.file_path != "" {
+		fmt.sbprintf(
+			&b,
+			"at %s(%d:%d) in %s\n",
+			loc.file_path,
+			loc.line,
+			loc.column,
+			loc.procedure,
+		)
+	}
+	fmt.sbprintf(&b, "%v %v, odin %s\n", ODIN_OS, ODIN_ARCH, ODIN_VERSION)
+	fmt.sbprintf(&b, "up %.0f s\n", time.duration_seconds(time.tick_since(state.startup.began)))
+
+	fmt.sbprintf(&b, "\nstack\n")
+	tc: trace.Context
+	if trace.init(&tc) {
+		buf: [CRASH_MAX_FRAMES]trace.Frame
+		for f in trace.frames(&tc, 1, buf[:]) {
+			fl := trace.resolve(&tc, f, context.temp_allocator)
+			if fl.procedure == "" {
+				fmt.sbprintf(&b, "  %p\n", rawptr(uintptr(f)))
+				continue
+			}
+			fmt.sbprintf(&b, "  %s  %s(%d)\n", fl.procedure, fl.file_path, fl.line)
+		}
+		trace.destroy(&tc)
+	} else {
+		fmt.sbprintf(&b, "  unavailable\n")
+	}
+
+	c := &state.crash
+	fmt.sbprintf(&b, "\nrecent commands, the latest last\n")
+	for i in max(c.count - CRASH_RECENT_COMMANDS, 0) ..< c.count {
+		fmt.sbprintf(&b, "  %s\n", c.recent[i % CRASH_RECENT_COMMANDS])
+	}
+
+	fmt.sbprintf(&b, "\nbuffers, * is the active one\n")
+	for &d, i in state.documents {
+		path, language := d.path, d.language
+		gb, h := &d.buffer, &d.undo
+		if i == state.active {
+			path, language = state.path, state.language
+			gb, h = &state.buffer, &state.undo
+		}
+		fmt.sbprintf(
+			&b,
+			"  %s %-32s %-12s %10s%s\n",
+			"*" if i == state.active else " ",
+			document_title(path),
+			language,
+			format_size(editor.current_length(gb)),
+			", modified" if editor.is_modified(h) else "",
+		)
+	}
+	fmt.sbprintf(&b, "\n%d modified buffers were saved for recovery on the next start\n", saved)
+	return strings.to_string(b)
+}
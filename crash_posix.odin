@@ -0,0 +1,10 @@
+#+build !windows
+package main
+
+import "core:sys/posix"
+
+// Writes message to stderr with a bare write(2), which is safe in a signal
+// handler.
+write_crash_message :: proc "contextless" (message: string) {
+	posix.write(posix.STDERR_FILENO, raw_data(message), uint(len(message)))
+}
@@ -0,0 +1,9 @@
+package main
+
+import "core:sys/windows"
+
+// Writes message to stderr straight through WriteFile, without the C runtime.
+write_crash_message :: proc "contextless" (message: string) {
+	stderr := windows.GetStdHandle(windows.STD_ERROR_HANDLE)
+	windows.WriteFile(stderr, raw_data(message), windows.DWORD(len(message)), nil, nil)
+}
@@ -0,0 +1,143 @@
+package main
+
+import editor "editor"
+
+// State of the CSV view: view.csv covers the editor with the buffer's fields
+// lined up in columns, and the keymap switches to "csv" mode until it is
+// closed.  The buffer is only read, so saving still writes its bytes as they
+// were, whatever order the rows are shown in.
+Csv_View_State :: struct {
+	prev_mode: string,
+}
+
+// view.csv: shows the buffer as comma, semicolon or tab separated values,
+// the cursor on the cell it is in.
+open_csv_view :: proc(state: ^Editor_State) {
+	if state.disk.view != .Text {
+		set_message(state, "The CSV view needs the text view of a file")
+		return
+	}
+	text := editor.get_text(&state.buffer, context.temp_allocator)
+	sep := editor.detect_csv_separator(text)
+	if state.language == "tsv" {
+		sep = '\t'
+	}
+	d := state.csv_data
+	if !d.visible {
+		state.csv_view.prev_mode = "panel" if state.mode == "panel" else "editor"
+	}
+	header := config_bool(state, "csv.header")
+	editor.set_csv_view(d, document_title(state.path), text, sep, header, state.cursor_pos)
+	state.mode = "csv"
+	set_message(
+		state,
+		"%d rows of %d columns; s sorts by a column, enter edits a cell, escape closes",
+		len(d.rows),
+		len(d.widths),
+	)
+}
+
+// csv.close: back to the buffer as it was.
+close_csv_view :: proc(state: ^Editor_State) {
+	editor.clear_csv_view(state.csv_data)
+	state.mode = state.csv_view.prev_mode
+	request_redraw(state)
+}
+
+// csv.edit: closes the view with the cursor at the start of the cell's value.
+edit_csv_cell :: proc(state: ^Editor_State) {
+	d := state.csv_data
+	cell, ok := editor.csv_view_cell(d)
+	if !ok {
+		set_message(state, "The row has no such cell")
+		return
+	}
+	pos := cell.start
+	if pos < cell.end && d.text[pos] == '"' {
+		pos += 1
+	}
+	close_csv_view(state)
+	state.selection_anchor = -1
+	state.cursor_pos = min(pos, editor.current_length(&state.buffer))
+	sync_cursor(state)
+	set_preferred_col(state)
+}
+
+// csv.sort: sorts the rows by the cursor's column, then the other way round,
+// then puts them back in the file's order.
+sort_csv_column :: proc(state: ^Editor_State) {
+	d := state.csv_data
+	switch {
+	case d.sort_col != d.col:
+		editor.sort_csv_view(d, d.col, false)
+	case !d.descending:
+		editor.sort_csv_view(d, d.col, true)
+	case:
+		editor.sort_csv_view(d, -1, false)
+		set_message(state, "Rows in the file's order")
+		return
+	}
+	order := "descending" if d.descending else "ascending"
+	set_message(state, "Sorted by %s, %s", editor.csv_column_name(d, d.col), order)
+}
+
+// csv.toggle_header: whether the first row is a header, pinned at the top and
+// kept out of sorting, or a record like the others.
+toggle_csv_header :: proc(state: ^Editor_State) {
+	d := state.csv_data
+	d.header = !d.header
+	editor.sort_csv_view(d, d.sort_col, d.descending)
+	set_message(state, "The first row is %s", "the header" if d.header else "a record")
+}
+
+csv_up :: proc(state: ^Editor_State) {
+	editor.csv_view_move(state.csv_data, -1, 0)
+}
+
+csv_down :: proc(state: ^Editor_State) {
+	editor.csv_view_move(state.csv_data, 1, 0)
+}
+
+csv_left :: proc(state: ^Editor_State) {
+	editor.csv_view_move(state.csv_data, 0, -1)
+}
+
+csv_right :: proc(state: ^Editor_State) {
+	editor.csv_view_move(state.csv_data, 0, 1)
+}
+
+csv_page_up :: proc(state: ^Editor_State) {
+	editor.csv_view_move(state.csv_data, -state.csv_data.page_rows, 0)
+}
+
+csv_page_down :: proc(state: ^Editor_State) {
+	editor.csv_view_move(state.csv_data, state.csv_data.page_rows, 0)
+}
+
+csv_first_row :: proc(state: ^Editor_State) {
+	editor.csv_view_move(state.csv_data, -len(state.csv_data.order), 0)
+}
+
+csv_last_row :: proc(state: ^Editor_State) {
+	editor.csv_view_move(state.csv_data, len(state.csv_data.order), 0)
+}
+
+// csv.next_cell: the next cell, on to the start of the next row after the last.
+csv_next_cell :: proc(state: ^Editor_State) {
+	d := state.csv_data
+	if d.col + 1 < len(d.widths) {
+		editor.csv_view_move(d, 0, 1)
+	} else if d.row + 1 < len(d.order) {
+		editor.csv_view_move(d, 1, -d.col)
+	}
+}
+
+// csv.prev_cell: the previous cell, back to the end of the previous row.
+csv_prev_cell :: proc(state: ^Editor_State) {
+	d := state.csv_data
+	if d.col > 0 {
+		editor.csv_view_move(d, 0, -1)
+	} else if d.row > 0 {
+		editor.csv_view_move(d, -1, len(d.widths))
+	}
+}
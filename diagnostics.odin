@@ -0,0 +1,202 @@
+package main
+
+import "core:fmt"
+import "core:slice"
+import "core:strings"
+import editor "editor"
+
+DIAGNOSTIC_ERROR_COLOR :: [4]f32{0.90, 0.35, 0.35, 1.0}
+DIAGNOSTIC_WARNING_COLOR :: [4]f32{0.90, 0.75, 0.30, 1.0}
+DIAGNOSTIC_INFO_COLOR :: [4]f32{0.45, 0.65, 0.90, 1.0}
+
+// In the order of the language server protocol.
+Diagnostic_Severity :: enum u8 {
+	Error,
+	Warning,
+	Info,
+	Hint,
+}
+
+DIAGNOSTIC_SEVERITY_NAMES := [Diagnostic_Severity]string {
+	.Error   = "error",
+	.Warning = "warning",
+	.Info    = "info",
+	.Hint    = "hint",
+}
+
+// A problem a tool reported in a file.  Every reporter, a task's compiler or
+// a linter, publishes into the same list, which feeds the gutter and the
+// diagnostics panel.
+Diagnostic :: struct {
+	path:     string, // absolute
+	line:     int, // 0 based
+	col:      int,
+	severity: Diagnostic_Severity,
+	message:  string,
+	source:   string, // who reported it: "task" or a linter's name
+}
+
+Diagnostics_State :: struct {
+	items:  [dynamic]Diagnostic, // in the order they were reported
+	active: bool, // the panel lists them
+}
+
+destroy_diagnostics :: proc(state: ^Editor_State) {
+	for d in state.diagnostics.items {
+		destroy_diagnostic(d)
+	}
+	delete(state.diagnostics.items)
+}
+
+// Replaces what source reported for path, or for every file when path is
+// empty, with diags, and shows the result.  diags are copied.
+publish_diagnostics :: proc(state: ^Editor_State, source, path: string, diags: []Diagnostic) {
+	x := &state.diagnostics
+	kept := 0
+	for d in x.items {
+		if d.source == source && (path == "" || d.path == path) {
+			destroy_diagnostic(d)
+		} else {
+			x.items[kept] = d
+			kept += 1
+		}
+	}
+	resize(&x.items, kept)
+	for d in diags {
+		add_diagnostic(state, d)
+	}
+	refresh_diagnostics(state)
+}
+
+// Appends a copy of d without showing it yet; call refresh_diagnostics
+// after a batch.
+add_diagnostic :: proc(state: ^Editor_State, d: Diagnostic) {
+	d := d
+	d.path = strings.clone(d.path)
+	d.message = strings.clone(d.message)
+	d.source = strings.clone(d.source)
+	append(&state.diagnostics.items, d)
+}
+
+count_diagnostics :: proc(state: ^Editor_State, source: string) -> (n: int) {
+	for d in state.diagnostics.items {
+		if d.source == source {
+			n += 1
+		}
+	}
+	return n
+}
+
+// Puts the diagnostics into the gutter and, while it lists them, the panel.
+refresh_diagnostics :: proc(state: ^Editor_State) {
+	refresh_diagnostic_marks(state)
+	quickfix_diagnostics(state)
+	if state.diagnostics.active {
+		list_diagnostics(state)
+	}
+}
+
+// Marks the lines of the active document that have diagnostics.
+refresh_diagnostic_marks :: proc(state: ^Editor_State) {
+	marks := make([dynamic]editor.Gutter_Mark, context.temp_allocator)
+	if state.path != "" {
+		full := workspace_path(state, state.path)
+		for d in state.diagnostics.items {
+			if d.path != full {
+				continue
+			}
+			color := DIAGNOSTIC_INFO_COLOR
+			switch d.severity {
+			case .Error:
+				color = DIAGNOSTIC_ERROR_COLOR
+			case .Warning:
+				color = DIAGNOSTIC_WARNING_COLOR
+			case .Info, .Hint:
+			}
+			append(&marks, editor.Gutter_Mark{line = d.line, color = color})
+		}
+	}
+	editor.set_gutter_marks(state.gutter_data, .Diagnostic, marks[:])
+}
+
+// Lists every diagnostic in the panel under a header per file.
+show_diagnostics :: proc(state: ^Editor_State) {
+	clear_replace(state)
+	stop_project_search(state)
+	release_panel(state)
+	state.diagnostics.active = true
+	quickfix_diagnostics(state, make_current = true)
+	list_diagnostics(state)
+	show_panel(state)
+}
+
+@(private = "file")
+list_diagnostics :: proc(state: ^Editor_State) {
+	sorted := slice.clone(state.diagnostics.items[:], context.temp_allocator)
+	slice.sort_by(sorted, proc(a, b: Diagnostic) -> bool {
+		if a.path != b.path {
+			return a.path < b.path
+		}
+		if a.line != b.line {
+			return a.line < b.line
+		}
+		return a.severity < b.severity
+	})
+
+	panel := state.panel_data
+	selected := panel.selected
+	editor.panel_clear(panel)
+	counts: [Diagnostic_Severity]int
+	for d, i in sorted {
+		counts[d.severity] += 1
+		if i == 0 || sorted[i - 1].path != d.path {
+			header := editor.Panel_Item {
+				text  = display_path(state, d.path),
+				path  = d.path,
+				line  = -1,
+				style = .Header,
+			}
+			editor.panel_add_item(panel, header)
+		}
+		style := editor.Panel_Item_Style.Normal
+		switch d.severity {
+		case .Error:
+			style = .Removed
+		case .Warning:
+		case .Info, .Hint:
+			style = .Dim
+		}
+		item := editor.Panel_Item {
+			text = fmt.tprintf(
+				"  %d:%d  %s  %s  (%s)",
+				d.line + 1,
+				d.col + 1,
+				DIAGNOSTIC_SEVERITY_NAMES[d.severity],
+				d.message,
+				d.source,
+			),
+			path = d.path,
+			line = d.line,
+			col = d.col,
+			style = style,
+		}
+		editor.panel_add_item(panel, item)
+	}
+	panel.selected = clamp(selected, 0, max(len(panel.items) - 1, 0))
+	editor.panel_set_title(
+		panel,
+		fmt.tprintf(
+			"Diagnostics: %d errors, %d warnings, %d other",
+			counts[.Error],
+			counts[.Warning],
+			counts[.Info] + counts[.Hint],
+		),
+	)
+}
+
+@(private = "file")
+destroy_diagnostic :: proc(d: Diagnostic) {
+	delete(d.path)
+	delete(d.message)
+	delete(d.source)
+}
@@ -0,0 +1,140 @@
+package main
+
+import "core:fmt"
+import "core:os"
+import "core:strings"
+import editor "editor"
+
+// State of the side-by-side diff view between prompts.
+Diff_State :: struct {
+	first_path: string, // left file of diff.files while the second is asked for
+	prev_mode:  string,
+}
+
+destroy_diff :: proc(state: ^Editor_State) {
+	delete(state.diff.first_path)
+}
+
+// Shows the buffer against its file as last committed.
+diff_with_head :: proc(state: ^Editor_State) {
+	if state.path == "" || state.disk.view != .Text {
+		set_message(state, "Diffing against HEAD needs the text view of a file")
+		return
+	}
+	full := workspace_path(state, state.path)
+	head, ok := editor.git_head_text(full, state.disk.encoding, context.temp_allocator)
+	if !ok {
+		set_message(state, "%s is not committed to git", document_title(state.path))
+		return
+	}
+	title := document_title(state.path)
+	open_diff_view(
+		state,
+		fmt.tprintf("HEAD: %s", title),
+		fmt.tprintf("Working tree: %s", title),
+		head,
+		editor.get_text(&state.buffer, context.temp_allocator),
+	)
+}
+
+// Shows the buffer against the file as last read or written.
+diff_with_saved :: proc(state: ^Editor_State) {
+	if state.path == "" {
+		set_message(state, "The buffer has never been saved")
+		return
+	}
+	title := document_title(state.path)
+	open_diff_view(
+		state,
+		fmt.tprintf("Saved: %s", title),
+		fmt.tprintf("Buffer: %s", title),
+		state.disk.text,
+		editor.get_text(&state.buffer, context.temp_allocator),
+	)
+}
+
+// Prompts for two files and shows them side by side.
+diff_files :: proc(state: ^Editor_State) {
+	open_prompt(state, "Diff file:", proc(state: ^Editor_State, first: string) {
+		first := strings.trim_space(first)
+		if first == "" {
+			return
+		}
+		delete(state.diff.first_path)
+		state.diff.first_path = strings.clone(first)
+		open_prompt(state, "Against:", proc(state: ^Editor_State, second: string) {
+			second := strings.trim_space(second)
+			first := state.diff.first_path
+			old, ok := read_text_file(state, first)
+			if !ok {
+				return
+			}
+			new: string
+			if new, ok = read_text_file(state, second); !ok {
+				return
+			}
+			open_diff_view(state, first, second, old, new)
+		})
+	})
+}
+
+close_diff_view :: proc(state: ^Editor_State) {
+	editor.clear_diff_view(state.diff_data)
+	state.mode = state.diff.prev_mode
+}
+
+diff_next_hunk :: proc(state: ^Editor_State) {
+	if !editor.diff_view_step_hunk(state.diff_data, 1) {
+		set_message(state, "No more changes")
+	}
+}
+
+diff_prev_hunk :: proc(state: ^Editor_State) {
+	if !editor.diff_view_step_hunk(state.diff_data, -1) {
+		set_message(state, "No earlier changes")
+	}
+}
+
+diff_scroll_down :: proc(state: ^Editor_State) {
+	editor.diff_view_scroll(state.diff_data, 1)
+}
+
+diff_scroll_up :: proc(state: ^Editor_State) {
+	editor.diff_view_scroll(state.diff_data, -1)
+}
+
+diff_page_down :: proc(state: ^Editor_State) {
+	editor.diff_view_scroll(state.diff_data, state.diff_data.page_rows)
+}
+
+diff_page_up :: proc(state: ^Editor_State) {
+	editor.diff_view_scroll(state.diff_data, -state.diff_data.page_rows)
+}
+
+// Covers the editor with old against new; the keymap switches to "diff" mode
+// until the view is closed.
+open_diff_view :: proc(state: ^Editor_State, old_title, new_title, old, new: string) {
+	d := state.diff_data
+	if !d.visible {
+		state.diff.prev_mode = "panel" if state.mode == "panel" else "editor"
+	}
+	editor.set_diff_view(d, old_title, new_title, old, new)
+	state.mode = "diff"
+	if len(d.hunks) == 0 {
+		set_message(state, "No differences")
+	} else {
+		set_message(state, "%d changes; n/p to step, escape to close", len(d.hunks))
+	}
+}
+
+// Reads a file for diffing as buffer text.  Temp allocated.
+@(private = "file")
+read_text_file :: proc(state: ^Editor_State, path: string) -> (string, bool) {
+	data, err := os.read_entire_file_from_path(workspace_path(state, path), context.temp_allocator)
+	if err != nil {
+		set_message(state, "Cannot read %s: %v", path, err)
+		return "", false
+	}
+	text, _ := editor.decode_file(data, context.temp_allocator)
+	return editor.normalize_line_endings(text, context.temp_allocator), true
+}
@@ -0,0 +1,299 @@
+package main
+
+import "core:fmt"
+import "core:os"
+import "core:path/filepath"
+import "core:strconv"
+import "core:strings"
+import editor "editor"
+
+// The documentation pane (editor.Docs_View_Data): docs.hover shows the
+// definition and comment of the name at the cursor, found in the buffer's
+// index or the tags file, and follows the cursor to other names until
+// pinned.  While the completion popup is open it shows the highlighted
+// word's documentation instead, looked up only once the word is highlighted.
+// docs.focus gives the pane the keyboard, in "docs" mode, to scroll it and
+// follow its links.
+Docs_State :: struct {
+	prev_mode:  string,
+	name:       string, // the name shown, or last looked up
+	completion: bool, // shown for the completion popup
+	opener:     ^editor.Task_Run, // the browser started for a link
+}
+
+destroy_docs :: proc(state: ^Editor_State) {
+	ds := &state.docs
+	if ds.opener != nil {
+		editor.destroy_task(ds.opener)
+	}
+	delete(ds.name)
+}
+
+// docs.hover: shows the documentation of the name at the cursor, or hides
+// the pane when it shows already and is not pinned.
+show_docs :: proc(state: ^Editor_State) {
+	d := state.docs_data
+	if d.visible && !d.pinned && !state.docs.completion {
+		close_docs(state)
+		return
+	}
+	name := word_at_cursor(state)
+	if name == "" {
+		set_message(state, "No name at the cursor")
+		return
+	}
+	d.pinned = false
+	state.docs.completion = false
+	if !show_docs_for(state, name) {
+		set_message(state, "No documentation for %s", name)
+	}
+}
+
+// docs.pin: keeps the pane's documentation as the cursor moves, or lets it
+// follow the cursor again.
+pin_docs :: proc(state: ^Editor_State) {
+	d := state.docs_data
+	if !d.visible {
+		set_message(state, "No documentation shown; docs.hover shows some")
+		return
+	}
+	d.pinned = !d.pinned
+	state.docs.completion = false
+	request_redraw(state)
+}
+
+// docs.focus: gives the pane the keyboard to scroll it and follow links,
+// showing the name at the cursor first when it is hidden.
+focus_docs :: proc(state: ^Editor_State) {
+	d := state.docs_data
+	if !d.visible {
+		show_docs(state)
+		if !d.visible {
+			return
+		}
+	}
+	close_completion(state)
+	if state.mode != "docs" {
+		state.docs.prev_mode = "panel" if state.mode == "panel" else "editor"
+	}
+	state.docs.completion = false
+	d.focused = true
+	state.mode = "docs"
+	set_message(state, "tab picks a link, enter follows it, p pins, escape returns")
+}
+
+// docs.unfocus: gives the keyboard back, leaving the pane shown.
+unfocus_docs :: proc(state: ^Editor_State) {
+	state.docs_data.focused = false
+	if state.mode == "docs" {
+		state.mode = state.docs.prev_mode
+	}
+	request_redraw(state)
+}
+
+// docs.close: hides the pane.
+close_docs :: proc(state: ^Editor_State) {
+	unfocus_docs(state)
+	editor.clear_docs_view(state.docs_data)
+	state.docs.completion = false
+	delete(state.docs.name)
+	state.docs.name = ""
+}
+
+// docs.follow: opens the selected link, the definition in the editor or an
+// address in the browser.
+follow_docs_link :: proc(state: ^Editor_State) {
+	target, location, ok := editor.docs_view_link(state.docs_data)
+	if !ok {
+		set_message(state, "No link picked; tab picks one")
+		return
+	}
+	target = strings.clone(target, context.temp_allocator)
+	if location {
+		colon := strings.last_index_byte(target, ':')
+		line, _ := strconv.parse_int(target[colon + 1:], 10)
+		unfocus_docs(state)
+		jump_to_location(state, target[:colon], line - 1, 0)
+		return
+	}
+	ds := &state.docs
+	if ds.opener != nil {
+		editor.destroy_task(ds.opener)
+		ds.opener = nil
+	}
+	run, err := editor.start_task(open_url_command(target), state.workspace_root)
+	if err != nil {
+		set_message(state, "Cannot open %s: %v", target, err)
+		return
+	}
+	ds.opener = run
+	set_message(state, "Opening %s", target)
+}
+
+docs_next_link :: proc(state: ^Editor_State) {
+	if !editor.docs_view_next_link(state.docs_data, false) {
+		set_message(state, "No links")
+	}
+}
+
+docs_prev_link :: proc(state: ^Editor_State) {
+	if !editor.docs_view_next_link(state.docs_data, true) {
+		set_message(state, "No links")
+	}
+}
+
+docs_up :: proc(state: ^Editor_State) {
+	editor.docs_view_scroll(state.docs_data, -1)
+}
+
+docs_down :: proc(state: ^Editor_State) {
+	editor.docs_view_scroll(state.docs_data, 1)
+}
+
+docs_page_up :: proc(state: ^Editor_State) {
+	editor.docs_view_scroll(state.docs_data, -state.docs_data.page_rows)
+}
+
+docs_page_down :: proc(state: ^Editor_State) {
+	editor.docs_view_scroll(state.docs_data, state.docs_data.page_rows)
+}
+
+// Keeps the pane on the highlighted completion, or on the name at the
+// cursor while it is shown and not pinned.  Called every frame.
+update_docs :: proc(state: ^Editor_State) {
+	d := state.docs_data
+	ds := &state.docs
+	d.width_cols = config_int(state, "docs.width")
+	if ds.opener != nil && editor.is_task_finished(ds.opener) {
+		editor.destroy_task(ds.opener)
+		ds.opener = nil
+	}
+	if d.pinned || state.mode == "docs" {
+		return
+	}
+	popup := state.completion_data
+	if state.completion.active && config_bool(state, "docs.completion") {
+		if popup.selected >= len(popup.items) || popup.items[popup.selected].source != .Word {
+			hide_completion_docs(state)
+			return
+		}
+		label := popup.items[popup.selected].label
+		if label == ds.name {
+			return
+		}
+		// A pane docs.hover opened stays open after the popup closes.
+		hover := d.visible && !ds.completion
+		if show_docs_for(state, label) {
+			ds.completion = !hover
+		} else {
+			hide_completion_docs(state)
+			set_docs_name(state, label)
+		}
+		return
+	}
+	if ds.completion {
+		hide_completion_docs(state)
+		return
+	}
+	if !d.visible || state.mode != "editor" {
+		return
+	}
+	if name := word_at_cursor(state); name != "" && name != ds.name {
+		if !show_docs_for(state, name) {
+			set_docs_name(state, name) // so it is looked up once
+		}
+	}
+}
+
+@(private = "file")
+hide_completion_docs :: proc(state: ^Editor_State) {
+	if state.docs.completion {
+		editor.clear_docs_view(state.docs_data)
+		state.docs.completion = false
+		delete(state.docs.name)
+		state.docs.name = ""
+		request_redraw(state)
+	}
+}
+
+// Looks name up and shows what was found.  False when it is defined nowhere
+// the buffer index or tags file know of.
+@(private = "file")
+show_docs_for :: proc(state: ^Editor_State, name: string) -> bool {
+	name := strings.clone(name, context.temp_allocator)
+	path, line, ok := find_definition(state, name)
+	if !ok || line < 0 {
+		return false
+	}
+	text, lang, read := definition_source(state, path)
+	if !read {
+		return false
+	}
+	code, comment := editor.definition_docs(text, line, lang)
+	shown := path
+	rel, err := filepath.rel(state.workspace_root, path, context.temp_allocator)
+	if err == .None && !strings.has_prefix(rel, "..") {
+		shown = rel
+	}
+	location := fmt.tprintf("%s:%d", shown, line + 1)
+	editor.set_docs_view(state.docs_data, name, location, code, comment)
+	set_docs_name(state, name)
+	request_redraw(state)
+	return true
+}
+
+// Where name is defined: in the active buffer by its index, else by the tags
+// file.  line is -1 when a tag's pattern is not found in its file.
+find_definition :: proc(state: ^Editor_State, name: string) -> (path: string, line: int, ok: bool) {
+	size := editor.current_length(&state.buffer)
+	if index := usable_index(state, state.doc_id, &state.undo, size); index != nil {
+		for s in editor.index_symbols_with_prefix(index, name) {
+			if s.name == name && state.path != "" {
+				return workspace_path(state, state.path), s.line, true
+			}
+		}
+	}
+	for tag in tag_definitions(state, name) {
+		path = tag_path(state, tag)
+		if tag.line >= 0 {
+			return path, tag.line, true
+		}
+		if text, _, read := definition_source(state, path); read {
+			return path, editor.find_tag_pattern(text, tag.pattern), true
+		}
+	}
+	return "", -1, false
+}
+
+// The text of the file at path, from its buffer when it is open, and its
+// language.  Temp allocated.
+definition_source :: proc(
+	state: ^Editor_State,
+	path: string,
+) -> (
+	text: string,
+	lang: ^editor.Language,
+	ok: bool,
+) {
+	if is_open_file(state, path) {
+		text = editor.get_text(&state.buffer, context.temp_allocator)
+		return text, editor.find_language(state.language), true
+	}
+	for &doc, i in state.documents {
+		if i != state.active && doc.path != "" && workspace_path(state, doc.path) == path {
+			text = editor.get_text(&doc.buffer, context.temp_allocator)
+			return text, editor.find_language(doc.language), true
+		}
+	}
+	data, err := os.read_entire_file_from_path(path, context.temp_allocator)
+	if err != nil {
+		return "", nil, false
+	}
+	return string(data), editor.detect_language(path, string(data)), true
+}
+
+@(private = "file")
+set_docs_name :: proc(state: ^Editor_State, name: string) {
+	delete(state.docs.name)
+	state.docs.name = strings.clone(name)
+}
@@ -0,0 +1,265 @@
+package main
+
+import "core:fmt"
+import "core:path/filepath"
+import "core:time"
+import editor "editor"
+
+// How a document's buffer represents its file.
+Document_View :: enum {
+	Text, // decoded text, see Disk_State.encoding
+	Hex, // a hex dump of the bytes, see editor.format_hex_dump
+	Notebook, // a Jupyter notebook's cells, see editor.notebook_text
+}
+
+// An open buffer.  The active document lives directly in Editor_State
+// (buffer, undo, path, cursor_pos, ...) so the layers can point at it; the
+// others are parked here and swapped in by switch_document.  The active slot
+// is left zeroed while its contents are in Editor_State.
+Document :: struct {
+	id:               int, // unique for the run
+	path:             string,
+	buffer:           editor.Gap_Buffer,
+	undo:             editor.Undo_History,
+	disk:             Disk_State,
+	language:         string,
+	preview:          bool, // only the start of a huge file was loaded
+	scratch:          bool,
+	cursor_pos:       int,
+	selection_anchor: int,
+	scroll_y:         f32,
+	parked:           time.Tick, // when it stopped being the active one
+}
+
+// Sets up the first, unnamed document.  Call after the layers exist.
+init_documents :: proc(state: ^Editor_State) {
+	state.documents = make([dynamic]Document)
+	append(&state.documents, Document{})
+	state.active = 0
+	load_blank_document(state)
+}
+
+destroy_documents :: proc(state: ^Editor_State) {
+	destroy_active_document(state)
+	for &d in state.documents {
+		destroy_document(&d)
+	}
+	delete(state.documents)
+}
+
+// Parks the active document and makes a new, empty one active.
+new_document :: proc(state: ^Editor_State) {
+	park_active_document(state)
+	append(&state.documents, Document{})
+	state.active = len(state.documents) - 1
+	load_blank_document(state)
+}
+
+// Index of the parked document holding `full` (an absolute path), or -1.
+find_document :: proc(state: ^Editor_State, full: string) -> int {
+	for d, i in state.documents {
+		if i != state.active && d.path != "" && workspace_path(state, d.path) == full {
+			return i
+		}
+	}
+	return -1
+}
+
+// Whether the document with id (a Document.id) is still open.
+is_document_open :: proc(state: ^Editor_State, id: int) -> bool {
+	if id == state.doc_id {
+		return true
+	}
+	for d, i in state.documents {
+		if i != state.active && d.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+switch_document :: proc(state: ^Editor_State, i: int) {
+	if i == state.active || i < 0 || i >= len(state.documents) {
+		return
+	}
+	park_active_document(state)
+	activate_document(state, i)
+}
+
+next_document :: proc(state: ^Editor_State) {
+	switch_document(state, (state.active + 1) % len(state.documents))
+}
+
+prev_document :: proc(state: ^Editor_State) {
+	n := len(state.documents)
+	switch_document(state, (state.active + n - 1) % n)
+}
+
+// Closes the active document and shows its neighbour, or a blank buffer when
+// it was the last one.
+close_document :: proc(state: ^Editor_State) {
+	discard_recovery_snapshot(state, state.doc_id)
+	destroy_active_document(state)
+	ordered_remove(&state.documents, state.active)
+	if len(state.documents) == 0 {
+		append(&state.documents, Document{})
+		state.active = 0
+		load_blank_document(state)
+		return
+	}
+	activate_document(state, min(state.active, len(state.documents) - 1))
+}
+
+// Closes every document, leaving one blank buffer.  Unsaved edits are lost.
+close_all_documents :: proc(state: ^Editor_State) {
+	for &d, i in state.documents {
+		if i != state.active {
+			discard_recovery_snapshot(state, d.id)
+			destroy_document(&d)
+		}
+	}
+	discard_recovery_snapshot(state, state.doc_id)
+	destroy_active_document(state)
+	clear(&state.documents)
+	append(&state.documents, Document{})
+	state.active = 0
+	load_blank_document(state)
+}
+
+// ctrl+w: closes the active document, asking first when it has unsaved edits
+// or is a scratch buffer with text, which is gone once closed.
+close_buffer :: proc(state: ^Editor_State) {
+	unsaved := state.scratch && state.path == "" && editor.current_length(&state.buffer) > 0
+	if editor.is_modified(&state.undo) || unsaved {
+		confirm(state, "Buffer has unsaved edits; close it anyway? (y/n)", close_document)
+		return
+	}
+	close_document(state)
+}
+
+// Lists the open documents in the panel.
+list_documents :: proc(state: ^Editor_State) {
+	release_panel(state)
+	panel := state.panel_data
+	editor.panel_clear(panel)
+	editor.panel_set_title(panel, fmt.tprintf("Open buffers (%d)", len(state.documents)))
+	for &d, i in state.documents {
+		path := state.path if i == state.active else d.path
+		marker := "*" if i == state.active else " "
+		modified := editor.is_modified(&state.undo if i == state.active else &d.undo)
+		text := fmt.tprintf("%s %s%s", marker, document_title(path), " +" if modified else "")
+		editor.panel_add_item(panel, {text = text, path = path, line = -1})
+	}
+	show_panel(state)
+}
+
+// Reports whether the buffer text cannot be edited directly: hex dumps are
+// only changed byte by byte through the hex commands, and previews of huge
+// files and help pages not at all.
+is_read_only :: proc(state: ^Editor_State) -> bool {
+	return state.preview || state.disk.view == .Hex || state.language == editor.HELP_LANGUAGE_ID
+}
+
+// Reports whether the active document is the untouched blank buffer, which
+// opening a file or a page reuses instead of adding a buffer beside it.
+is_blank_document :: proc(state: ^Editor_State) -> bool {
+	if state.path != "" || state.scratch || editor.can_undo(&state.undo) {
+		return false
+	}
+	return state.language != editor.HELP_LANGUAGE_ID
+}
+
+// File name shown for a document path.
+document_title :: proc(path: string) -> string {
+	return filepath.base(path) if path != "" else "untitled"
+}
+
+// Moves the live document out of Editor_State into its slot.
+@(private = "file")
+park_active_document :: proc(state: ^Editor_State) {
+	editor.close_undo_groups(&state.undo, state.cursor_pos)
+	d := &state.documents[state.active]
+	d.id = state.doc_id
+	d.path = state.path
+	d.buffer = state.buffer
+	d.buffer.undo = nil // reattached on activation; the slot may move
+	d.undo = state.undo
+	d.disk = state.disk
+	d.language = state.language
+	d.preview = state.preview
+	d.scratch = state.scratch
+	d.cursor_pos = state.cursor_pos
+	d.selection_anchor = state.selection_anchor
+	d.scroll_y = state.scroll_target
+	d.parked = time.tick_now()
+}
+
+// Moves slot i into Editor_State.  The live document must already be parked
+// or destroyed.
+@(private = "file")
+activate_document :: proc(state: ^Editor_State, i: int) {
+	d := &state.documents[i]
+	state.active = i
+	state.doc_id = d.id
+	state.path = d.path
+	state.buffer = d.buffer
+	state.undo = d.undo
+	state.buffer.undo = &state.undo
+	state.disk = d.disk
+	state.language = d.language
+	state.preview = d.preview
+	state.scratch = d.scratch
+	state.cursor_pos = d.cursor_pos
+	state.selection_anchor = d.selection_anchor
+	jump_scroll(state, d.scroll_y)
+	d^ = {}
+	document_shown(state)
+}
+
+@(private = "file")
+load_blank_document :: proc(state: ^Editor_State) {
+	state.next_doc_id += 1
+	state.doc_id = state.next_doc_id
+	state.path = ""
+	state.buffer = editor.init_gap_buffer()
+	state.undo = editor.init_undo_history()
+	state.buffer.undo = &state.undo
+	state.disk = {}
+	state.language = editor.PLAIN_TEXT_LANGUAGE_ID
+	state.preview = false
+	state.scratch = false
+	state.cursor_pos = 0
+	state.selection_anchor = -1
+	jump_scroll(state, 0)
+	document_shown(state)
+}
+
+@(private = "file")
+document_shown :: proc(state: ^Editor_State) {
+	apply_config(state)
+	sync_cursor(state)
+	set_preferred_col(state)
+	refresh_bookmark_marks(state)
+	refresh_diagnostic_marks(state)
+	refresh_test_marks(state)
+	fire_plugin_event(state, .Enter, state.path)
+}
+
+@(private = "file")
+destroy_active_document :: proc(state: ^Editor_State) {
+	editor.destroy_gap_buffer(&state.buffer)
+	editor.destroy_undo_history(&state.undo)
+	delete(state.disk.text)
+	delete(state.disk.source)
+	delete(state.path)
+	state.path = ""
+}
+
+@(private = "file")
+destroy_document :: proc(d: ^Document) {
+	editor.destroy_gap_buffer(&d.buffer)
+	editor.destroy_undo_history(&d.undo)
+	delete(d.disk.text)
+	delete(d.disk.source)
+	delete(d.path)
+}
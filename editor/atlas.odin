@@ -1,6 +1,6 @@
 package editor
 
-import "core:fmt"
+import "core:log"
 import "core:mem"
 
 ATLAS_SIZE :: 1024
@@ -194,7 +194,7 @@ shelf_pack :: proc(atlas: ^Glyph_Atlas, w, h: u32) -> (region: Atlas_Region, ok:
 	}
 
 	if y_start + h > atlas.height {
-		fmt.eprintln("Atlas full!")
+		log.warn("atlas: full")
 		return region, false
 	}
 
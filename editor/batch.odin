@@ -190,6 +190,15 @@ push_rect :: proc(br: ^Batch_Renderer, x, y, w, h: f32, color: [4]f32) {
 	)
 }
 
+// Push the whole of the Image pipeline's texture stretched over a rect.
+push_image :: proc(br: ^Batch_Renderer, x, y, w, h: f32) {
+	push_quad(
+		br,
+		Quad{min = {x, y}, max = {x + w, y + h}, uv_min = {0, 0}, uv_max = {1, 1}, color = 1},
+		.Image,
+	)
+}
+
 push_glyph :: proc(br: ^Batch_Renderer, x, y: f32, info: Glyph_Info, color: [4]f32) {
 	push_quad(
 		br,
@@ -231,7 +240,7 @@ flush_batch :: proc(
 		vk.CmdBindPipeline(cmd_buf, .GRAPHICS, pipe.pipeline)
 		vk.CmdPushConstants(cmd_buf, pipe.layout, {.VERTEX}, 0, size_of(Push_Constants), &push)
 
-		if dc.pipeline_kind == .Text {
+		if dc.pipeline_kind == .Text || dc.pipeline_kind == .Image {
 			ds := pipe.descriptor_sets[ctx.frame_index]
 			vk.CmdBindDescriptorSets(cmd_buf, .GRAPHICS, pipe.layout, 0, 1, &ds, 0, nil)
 		}
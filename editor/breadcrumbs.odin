@@ -0,0 +1,247 @@
+package editor
+
+import "core:encoding/json"
+import "core:mem"
+import "core:slice"
+import "core:strings"
+
+BREADCRUMB_SEPARATOR :: "  ›  "
+
+// A symbol and the lines it spans, for the breadcrumbs.
+Scoped_Symbol :: struct {
+	name:     string,
+	line:     int, // 0 based, of its name
+	col:      int, // byte column of its name
+	end_line: int, // its last line
+}
+
+// One step of the trail: a symbol around the cursor.
+Breadcrumb :: struct {
+	label:  string,
+	line:   int,
+	col:    int,
+	x0, x1: f32, // where it was last drawn
+}
+
+// The bar along the top of the text with the symbols around the cursor,
+// outermost first: package › type › function.
+Breadcrumb_Bar_Data :: struct {
+	font:        ^Font_Handle,
+	line_height: f32,
+	padding_x:   f32,
+	fg_color:    [4]f32,
+	dim_color:   [4]f32, // the separators and the outer crumbs
+	bg_color:    [4]f32,
+	crumbs:      [dynamic]Breadcrumb,
+	allocator:   mem.Allocator,
+}
+
+make_breadcrumb_layer :: proc(
+	font: ^Font_Handle,
+	line_height: f32,
+	padding_x: f32,
+	fg_color, dim_color, bg_color: [4]f32,
+	allocator: mem.Allocator = context.allocator,
+) -> Layer {
+	data := new(Breadcrumb_Bar_Data, allocator)
+	data.font = font
+	data.line_height = line_height
+	data.padding_x = padding_x
+	data.fg_color = fg_color
+	data.dim_color = dim_color
+	data.bg_color = bg_color
+	data.crumbs = make([dynamic]Breadcrumb, allocator)
+	data.allocator = allocator
+
+	return Layer {
+		kind = .Overlay,
+		z_index = 110,
+		enabled = false,
+		name = "breadcrumbs",
+		user_data = data,
+		draw = proc(
+			layer: ^Layer,
+			br: ^Batch_Renderer,
+			atlas: ^Glyph_Atlas,
+			lctx: ^Layer_Context,
+		) {
+			d := cast(^Breadcrumb_Bar_Data)layer.user_data
+			push_rect(br, 0, 0, lctx.viewport[0], d.line_height, d.bg_color)
+			x := d.padding_x
+			for &c, i in d.crumbs {
+				if i > 0 {
+					x = push_text(br, atlas, d.font, x, 0, BREADCRUMB_SEPARATOR, d.dim_color)
+				}
+				color := d.fg_color if i == len(d.crumbs) - 1 else d.dim_color
+				c.x0 = x
+				x = push_text(br, atlas, d.font, x, 0, c.label, color)
+				c.x1 = x
+			}
+		},
+		on_destroy = proc(layer: ^Layer) {
+			d := cast(^Breadcrumb_Bar_Data)layer.user_data
+			clear_breadcrumbs(d)
+			delete(d.crumbs)
+		},
+	}
+}
+
+// Replaces the trail with a copy of crumbs.
+set_breadcrumbs :: proc(d: ^Breadcrumb_Bar_Data, crumbs: []Breadcrumb) {
+	clear_breadcrumbs(d)
+	for c in crumbs {
+		kept := c
+		kept.label = strings.clone(c.label, d.allocator)
+		append(&d.crumbs, kept)
+	}
+}
+
+// The index of the crumb drawn at x, or -1.
+breadcrumb_at :: proc(d: ^Breadcrumb_Bar_Data, x: f32) -> int {
+	for c, i in d.crumbs {
+		if x >= c.x0 && x < c.x1 {
+			return i
+		}
+	}
+	return -1
+}
+
+// The spans of a buffer's symbols, found without parsing the language: a
+// symbol's lines go on while they are indented deeper than its own or open
+// a block at its depth, and end with one that closes it there, e.g. "}" or
+// "end".  A package clause at the top spans the whole buffer.  The names
+// borrow from symbols and text.  Temp allocated.
+symbol_scopes :: proc(text: string, symbols: []Buffer_Symbol) -> []Scoped_Symbol {
+	lines := strings.split_lines(text, context.temp_allocator)
+	found := make([dynamic]Scoped_Symbol, context.temp_allocator)
+	for line, i in lines {
+		trimmed := strings.trim_space(line)
+		if trimmed == "" || strings.has_prefix(trimmed, "//") || strings.has_prefix(trimmed, "#") {
+			continue
+		}
+		if strings.has_prefix(trimmed, "package ") {
+			pkg := strings.trim_right(strings.trim_space(trimmed[len("package "):]), ";")
+			append(&found, Scoped_Symbol{pkg, i, strings.index(line, pkg), len(lines) - 1})
+		}
+		break
+	}
+	for s in symbols {
+		if s.line >= len(lines) {
+			continue
+		}
+		depth := indent_width(lines[s.line])
+		end := s.line
+		scan: for j in s.line + 1 ..< len(lines) {
+			trimmed := strings.trim_space(lines[j])
+			if trimmed == "" {
+				continue
+			}
+			if indent_width(lines[j]) > depth {
+				end = j
+				continue
+			}
+			switch {
+			case trimmed[0] == '{' || trimmed[0] == ')':
+				end = j // e.g. a brace on a line of its own, or ") {"
+			case trimmed[0] == '}' || trimmed[0] == ']' || trimmed == "end":
+				end = j
+				break scan
+			case:
+				break scan
+			}
+		}
+		append(&found, Scoped_Symbol{s.name, s.line, s.col, end})
+	}
+	return found[:]
+}
+
+// The symbols of a textDocument/documentSymbol answer, either nested
+// DocumentSymbols or flat SymbolInformation, with their children after
+// them.  ok is false when reply is not JSON.  Temp allocated.
+document_symbol_scopes :: proc(
+	text: string,
+	reply: string,
+	encoding := Position_Encoding.Utf16,
+) -> (
+	scopes: []Scoped_Symbol,
+	ok: bool,
+) {
+	value, err := json.parse_string(reply, allocator = context.temp_allocator)
+	if err != nil {
+		return nil, false
+	}
+	found := make([dynamic]Scoped_Symbol, context.temp_allocator)
+	add_document_symbols(&found, text, json_array(value), encoding)
+	return found[:], true
+}
+
+// The symbols whose spans hold line, outermost first.  Temp allocated.
+enclosing_symbols :: proc(scopes: []Scoped_Symbol, line: int) -> []Scoped_Symbol {
+	found := make([dynamic]Scoped_Symbol, context.temp_allocator)
+	for s in scopes {
+		if s.line <= line && line <= s.end_line {
+			append(&found, s)
+		}
+	}
+	// Outer spans start earlier, or as early and end later.
+	slice.sort_by(found[:], proc(a, b: Scoped_Symbol) -> bool {
+		if a.line != b.line {
+			return a.line < b.line
+		}
+		return a.end_line > b.end_line
+	})
+	return found[:]
+}
+
+@(private = "file")
+add_document_symbols :: proc(
+	found: ^[dynamic]Scoped_Symbol,
+	text: string,
+	symbols: []json.Value,
+	encoding: Position_Encoding,
+) {
+	for s in symbols {
+		r := json_field(s, "range")
+		if r == nil {
+			r = json_field(json_field(s, "location"), "range") // SymbolInformation
+		}
+		at := json_field(s, "selectionRange")
+		if at == nil {
+			at = r
+		}
+		start := json_field(at, "start")
+		pos := Lsp_Position{json_int(start, "line"), json_int(start, "character")}
+		col := 0
+		if offset, ok := lsp_offset(text, pos, encoding); ok {
+			col = offset - (strings.last_index_byte(text[:offset], '\n') + 1)
+		}
+		end_line := json_int(json_field(r, "end"), "line")
+		append(found, Scoped_Symbol{json_string(s, "name"), pos.line, col, max(end_line, pos.line)})
+		add_document_symbols(found, text, json_array(json_field(s, "children")), encoding)
+	}
+}
+
+@(private = "file")
+clear_breadcrumbs :: proc(d: ^Breadcrumb_Bar_Data) {
+	for c in d.crumbs {
+		delete(c.label, d.allocator)
+	}
+	clear(&d.crumbs)
+}
+
+// Columns of a line's leading whitespace, a tab counting as 4.
+@(private = "file")
+indent_width :: proc(line: string) -> int {
+	width := 0
+	for c in transmute([]u8)line {
+		switch c {
+		case ' ':
+			width += 1
+		case '\t':
+			width += 4
+		case:
+			return width
+		}
+	}
+	return width
+}
@@ -0,0 +1,304 @@
+package editor
+
+import "core:mem"
+import "core:slice"
+import "core:strings"
+import "core:sync"
+import "core:thread"
+
+// A buffer's words and symbols, sorted so that looking up a prefix is a
+// binary search however large the buffer.  Built on a background thread from
+// a copy of the text, which the words point into.
+Buffer_Index :: struct {
+	text:      string,
+	words:     []Indexed_Word, // by name, ignoring case
+	positions: []int, // every word's occurrences, see Indexed_Word
+	symbols:   []Buffer_Symbol, // by name, ignoring case
+	finished:  bool, // atomic
+	thread:    ^thread.Thread,
+	allocator: mem.Allocator,
+}
+
+Indexed_Word :: struct {
+	word:  string,
+	first: int, // its occurrences are positions[first:][:count], ascending
+	count: int,
+}
+
+Symbol_Kind :: enum u8 {
+	Function,
+	Type,
+	Constant,
+}
+
+// A definition found the way ctags does, by the keyword before a name (fn,
+// def, struct, ...) or the name before "::", without parsing the language.
+Buffer_Symbol :: struct {
+	name: string,
+	kind: Symbol_Kind,
+	line: int, // 0 based
+	col:  int, // byte column of the name
+}
+
+@(private = "file")
+SYMBOL_KEYWORDS := [?]struct {
+	keyword: string,
+	kind:    Symbol_Kind,
+} {
+	{"fn", .Function},
+	{"func", .Function},
+	{"function", .Function},
+	{"def", .Function},
+	{"proc", .Function},
+	{"class", .Type},
+	{"struct", .Type},
+	{"enum", .Type},
+	{"union", .Type},
+	{"trait", .Type},
+	{"interface", .Type},
+	{"type", .Type},
+	{"define", .Constant}, // #define
+}
+
+// Starts indexing text in the background.  Poll with
+// is_buffer_index_finished; finish_buffer_index waits instead.
+start_buffer_index :: proc(
+	text: string,
+	allocator: mem.Allocator = context.allocator,
+) -> ^Buffer_Index {
+	index := new(Buffer_Index, allocator)
+	index.text = strings.clone(text, allocator)
+	index.allocator = allocator
+	index.thread = thread.create_and_start_with_poly_data(index, run_buffer_index)
+	return index
+}
+
+is_buffer_index_finished :: proc(index: ^Buffer_Index) -> bool {
+	return sync.atomic_load(&index.finished)
+}
+
+// Waits for the index to be built.
+finish_buffer_index :: proc(index: ^Buffer_Index) {
+	if index.thread != nil {
+		thread.join(index.thread)
+		thread.destroy(index.thread)
+		index.thread = nil
+	}
+}
+
+// Waits for the index and frees it.
+destroy_buffer_index :: proc(index: ^Buffer_Index) {
+	finish_buffer_index(index)
+	allocator := index.allocator
+	delete(index.text, allocator)
+	delete(index.words, allocator)
+	delete(index.positions, allocator)
+	delete(index.symbols, allocator)
+	free(index, allocator)
+}
+
+// Bytes held by a finished index.
+buffer_index_size :: proc(index: ^Buffer_Index) -> int {
+	if !is_buffer_index_finished(index) {
+		return len(index.text)
+	}
+	return len(index.text) +
+		len(index.words) * size_of(Indexed_Word) +
+		len(index.positions) * size_of(int) +
+		len(index.symbols) * size_of(Buffer_Symbol)
+}
+
+// The words starting with prefix, ignoring case.
+index_words_with_prefix :: proc(index: ^Buffer_Index, prefix: string) -> []Indexed_Word {
+	return with_prefix(index.words, prefix, proc(w: Indexed_Word) -> string {return w.word})
+}
+
+// The symbols whose names start with prefix, ignoring case.
+index_symbols_with_prefix :: proc(index: ^Buffer_Index, prefix: string) -> []Buffer_Symbol {
+	return with_prefix(index.symbols, prefix, proc(s: Buffer_Symbol) -> string {return s.name})
+}
+
+// What collect_words does for the indexed text: adds the words starting
+// with prefix, other than prefix itself and the occurrence at skip, at base
+// plus their distance from near.
+index_collect_words :: proc(
+	ranks: ^map[string]int,
+	index: ^Buffer_Index,
+	prefix: string,
+	near, skip, base: int,
+) {
+	for w in index_words_with_prefix(index, prefix) {
+		if len(w.word) <= len(prefix) {
+			continue
+		}
+		at := index.positions[w.first:][:w.count]
+		// The occurrences either side of near are the closest.
+		i, _ := slice.binary_search(at, near)
+		distance := -1
+		for j in max(i - 1, 0) ..< min(i + 2, len(at)) {
+			start := at[j]
+			if start <= skip && skip <= start + len(w.word) {
+				continue
+			}
+			if d := abs(start - near); distance < 0 || d < distance {
+				distance = d
+			}
+		}
+		if distance < 0 {
+			continue
+		}
+		if old, ok := ranks[w.word]; !ok || base + distance < old {
+			ranks[w.word] = base + distance
+		}
+	}
+}
+
+@(private = "file")
+run_buffer_index :: proc(index: ^Buffer_Index) {
+	text := index.text
+	occurrences := make(map[string][dynamic]int, allocator = context.temp_allocator)
+	symbols := make([dynamic]Buffer_Symbol, index.allocator)
+	line, line_start := 0, 0
+	prev, prev_end := "", 0 // the word before on the line, for "keyword name"
+	func_end := -1 // after a func on the line, for a Go method's receiver
+	for i := 0; i < len(text); {
+		b := text[i]
+		if b == '\n' {
+			line += 1
+			line_start = i + 1
+			prev, func_end = "", -1
+		}
+		if !is_word_byte(b) {
+			i += 1
+			continue
+		}
+		start := i
+		for i < len(text) && is_word_byte(text[i]) {
+			i += 1
+		}
+		word := text[start:i]
+		if word[0] < '0' || word[0] > '9' {
+			if word not_in occurrences {
+				occurrences[word] = make([dynamic]int, context.temp_allocator)
+			}
+			append(&occurrences[word], start)
+			kind, ok := symbol_kind(text, prev, prev_end, start, i)
+			if !ok && func_end >= 0 {
+				receiver := strings.trim_space(text[func_end:start])
+				ok = strings.has_prefix(receiver, "(") && strings.has_suffix(receiver, ")")
+				kind = .Function
+			}
+			if ok {
+				append(&symbols, Buffer_Symbol{word, kind, line, start - line_start})
+				func_end = -1
+			}
+		}
+		if word == "func" {
+			func_end = i
+		}
+		prev, prev_end = word, i
+	}
+
+	words := make([]Indexed_Word, len(occurrences), index.allocator)
+	n := 0
+	for word in occurrences {
+		words[n] = {word = word}
+		n += 1
+	}
+	slice.sort_by(words, proc(a, b: Indexed_Word) -> bool {
+		return compare_folded(a.word, b.word) == .Less
+	})
+	total := 0
+	for &w in words {
+		w.first = total
+		w.count = len(occurrences[w.word])
+		total += w.count
+	}
+	positions := make([]int, total, index.allocator)
+	for w in words {
+		copy(positions[w.first:], occurrences[w.word][:])
+	}
+	slice.sort_by(symbols[:], proc(a, b: Buffer_Symbol) -> bool {
+		if order := compare_folded(a.name, b.name); order != .Equal {
+			return order == .Less
+		}
+		return a.line < b.line
+	})
+
+	index.words = words
+	index.positions = positions
+	index.symbols = symbols[:]
+	free_all(context.temp_allocator)
+	sync.atomic_store(&index.finished, true)
+}
+
+// Whether the word at start..end is a definition: the name right after a
+// keyword such as fn or struct, or first on its line before " :: " as in
+// Odin, which C++'s std::name is not.
+@(private = "file")
+symbol_kind :: proc(text, prev: string, prev_end, start, end: int) -> (Symbol_Kind, bool) {
+	for k in SYMBOL_KEYWORDS {
+		if prev == k.keyword && strings.trim_space(text[prev_end:start]) == "" {
+			return k.kind, true
+		}
+	}
+	if prev == "" && strings.has_prefix(text[end:], " ::") {
+		after := strings.trim_left(text[end + 3:], " \t")
+		switch {
+		case strings.has_prefix(after, "proc"):
+			return .Function, true
+		case strings.has_prefix(after, "struct"), strings.has_prefix(after, "enum"),
+		     strings.has_prefix(after, "union"), strings.has_prefix(after, "distinct"):
+			return .Type, true
+		}
+		return .Constant, true
+	}
+	return {}, false
+}
+
+// The run of items, sorted by name ignoring case, whose names start with
+// prefix.  Found by binary search, as the names with a prefix sort together.
+@(private)
+with_prefix :: proc(items: []$T, prefix: string, name: proc(item: T) -> string) -> []T {
+	lo, hi := 0, len(items)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if compare_folded(name(items[mid]), prefix) == .Less {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	end := lo
+	for end < len(items) && has_prefix_folded(name(items[end]), prefix) {
+		end += 1
+	}
+	return items[lo:end]
+}
+
+@(private = "file")
+has_prefix_folded :: proc(s, prefix: string) -> bool {
+	return len(s) >= len(prefix) && compare_folded(s[:len(prefix)], prefix) == .Equal
+}
+
+@(private)
+compare_folded :: proc(a, b: string) -> slice.Ordering {
+	for i in 0 ..< min(len(a), len(b)) {
+		x, y := fold_byte(a[i]), fold_byte(b[i])
+		if x != y {
+			return .Less if x < y else .Greater
+		}
+	}
+	switch {
+	case len(a) < len(b):
+		return .Less
+	case len(a) > len(b):
+		return .Greater
+	}
+	return .Equal
+}
+
+@(private = "file")
+fold_byte :: proc(b: u8) -> u8 {
+	return b + 32 if b >= 'A' && b <= 'Z' else b
+}
@@ -0,0 +1,454 @@
+package editor
+
+import "core:encoding/json"
+import "core:fmt"
+import "core:math"
+import "core:mem"
+import "core:strconv"
+import "core:strings"
+
+// Lines longer than this are not looked at for color literals.
+COLOR_MAX_LINE :: 2000
+
+// How a literal is written, kept when it is rewritten.
+Color_Format :: enum u8 {
+	Hex, // #rgb, #rgba, #rrggbb or #rrggbbaa
+	Rgb, // rgb(255, 0, 0) or rgba(255, 0, 0, 0.5)
+	Hsl, // hsl(0, 100%, 50%) or hsla(...)
+}
+
+Color_Literal :: struct {
+	start, end: int, // byte columns in the line
+	color:      [4]f32, // straight RGBA, 0 to 1
+	format:     Color_Format,
+}
+
+// A color a language server found, however it is written, e.g. as
+// Color(0.2, 0.4, 1.0) in code.
+Reported_Color :: struct {
+	line:       int,
+	start, end: int, // byte columns of its text in the line
+	color:      [4]f32,
+}
+
+// The color literals of a line, left to right.  Temp allocated.
+find_color_literals :: proc(line: string) -> []Color_Literal {
+	found := make([dynamic]Color_Literal, context.temp_allocator)
+	if len(line) > COLOR_MAX_LINE {
+		return found[:]
+	}
+	for i := 0; i < len(line); i += 1 {
+		if i > 0 && is_color_word_byte(line[i - 1]) {
+			continue
+		}
+		if lit, ok := parse_color_at(line, i); ok {
+			append(&found, lit)
+			i = lit.end - 1
+		}
+	}
+	return found[:]
+}
+
+// The literal covering byte column col of line.
+color_literal_at :: proc(line: string, col: int) -> (Color_Literal, bool) {
+	for lit in find_color_literals(line) {
+		if lit.start <= col && col <= lit.end {
+			return lit, true
+		}
+	}
+	return {}, false
+}
+
+// Reads text as one color literal of any format.
+parse_color :: proc(text: string) -> (Color_Literal, bool) {
+	s := strings.trim_space(text)
+	lit, ok := parse_color_at(s, 0)
+	if !ok || lit.end != len(s) {
+		return {}, false
+	}
+	return lit, true
+}
+
+// Writes color the way format does, with the alpha only when it is not 1.
+// Temp allocated.
+format_color :: proc(color: [4]f32, format: Color_Format) -> string {
+	byte_of :: proc(v: f32) -> int {
+		return int(math.round(clamp(v, 0, 1) * 255))
+	}
+	r, g, b := byte_of(color.r), byte_of(color.g), byte_of(color.b)
+	opaque := color.a >= 1
+	alpha := strings.trim_right(strings.trim_right(fmt.tprintf("%.2f", color.a), "0"), ".")
+	switch format {
+	case .Hex:
+		if opaque {
+			return fmt.tprintf("#%02x%02x%02x", r, g, b)
+		}
+		return fmt.tprintf("#%02x%02x%02x%02x", r, g, b, byte_of(color.a))
+	case .Rgb:
+		if opaque {
+			return fmt.tprintf("rgb(%d, %d, %d)", r, g, b)
+		}
+		return fmt.tprintf("rgba(%d, %d, %d, %s)", r, g, b, alpha)
+	case .Hsl:
+		h, s, l := rgb_to_hsl(color.rgb)
+		hi, si, li := int(math.round(h)) % 360, int(math.round(s * 100)), int(math.round(l * 100))
+		if opaque {
+			return fmt.tprintf("hsl(%d, %d%%, %d%%)", hi, si, li)
+		}
+		return fmt.tprintf("hsla(%d, %d%%, %d%%, %s)", hi, si, li, alpha)
+	}
+	return ""
+}
+
+// The colors of a textDocument/documentColor answer, ColorInformation
+// items, that text has on one line each.  ok is false when reply is not
+// JSON.  Temp allocated.
+parse_document_colors :: proc(
+	text: string,
+	reply: string,
+	encoding := Position_Encoding.Utf16,
+) -> (
+	colors: []Reported_Color,
+	ok: bool,
+) {
+	value, err := json.parse_string(reply, allocator = context.temp_allocator)
+	if err != nil {
+		return nil, false
+	}
+	found := make([dynamic]Reported_Color, context.temp_allocator)
+	for info in json_array(value) {
+		r := json_field(info, "range")
+		start, end := json_field(r, "start"), json_field(r, "end")
+		from := Lsp_Position{json_int(start, "line"), json_int(start, "character")}
+		to := Lsp_Position{json_int(end, "line"), json_int(end, "character")}
+		a, a_ok := lsp_offset(text, from, encoding)
+		b, b_ok := lsp_offset(text, to, encoding)
+		if !a_ok || !b_ok || from.line != to.line || b < a {
+			continue
+		}
+		line_start := strings.last_index_byte(text[:a], '\n') + 1
+		c := json_field(info, "color")
+		color := [4]f32 {
+			json_number(c, "red"),
+			json_number(c, "green"),
+			json_number(c, "blue"),
+			json_number(c, "alpha"),
+		}
+		append(&found, Reported_Color{from.line, a - line_start, b - line_start, color})
+	}
+	return found[:], true
+}
+
+// The first of a textDocument/colorPresentation answer: its label, which
+// replaces the color's text unless it has a textEdit of its own, and its
+// edits, the textEdit first when has_edit.  ok is false when reply is not
+// JSON or has none.  Temp allocated.
+parse_color_presentation :: proc(
+	reply: string,
+) -> (
+	label: string,
+	edits: []Lsp_Text_Edit,
+	has_edit: bool,
+	ok: bool,
+) {
+	value, err := json.parse_string(reply, allocator = context.temp_allocator)
+	list := json_array(value)
+	if err != nil || len(list) == 0 {
+		return "", nil, false, false
+	}
+	first := list[0]
+	all := make([dynamic]Lsp_Text_Edit, context.temp_allocator)
+	if edit := json_field(first, "textEdit"); edit != nil {
+		one := make(json.Array, 0, 1, context.temp_allocator)
+		append(&one, edit)
+		if parsed, parsed_ok := parse_lsp_text_edits(one, context.temp_allocator); parsed_ok {
+			append(&all, ..parsed)
+			has_edit = true
+		}
+	}
+	additional := json_field(first, "additionalTextEdits")
+	more, _ := parse_lsp_text_edits(additional, context.temp_allocator)
+	append(&all, ..more)
+	return json_string(first, "label"), all[:], has_edit, true
+}
+
+// Hue in degrees, saturation and lightness from 0 to 1.
+rgb_to_hsl :: proc(c: [3]f32) -> (h, s, l: f32) {
+	hi := max(c.r, c.g, c.b)
+	lo := min(c.r, c.g, c.b)
+	l = (hi + lo) / 2
+	d := hi - lo
+	if d == 0 {
+		return 0, 0, l
+	}
+	s = d / (1 - abs(2 * l - 1))
+	if hi == c.r {
+		h = 60 * math.mod((c.g - c.b) / d, 6)
+	} else if hi == c.g {
+		h = 60 * ((c.b - c.r) / d + 2)
+	} else {
+		h = 60 * ((c.r - c.g) / d + 4)
+	}
+	if h < 0 {
+		h += 360
+	}
+	return h, s, l
+}
+
+hsl_to_rgb :: proc(h, s, l: f32) -> [3]f32 {
+	c := (1 - abs(2 * l - 1)) * s
+	hp := math.mod(math.mod(h, 360) + 360, 360) / 60
+	x := c * (1 - abs(math.mod(hp, 2) - 1))
+	rgb: [3]f32
+	switch {
+	case hp < 1:
+		rgb = {c, x, 0}
+	case hp < 2:
+		rgb = {x, c, 0}
+	case hp < 3:
+		rgb = {0, c, x}
+	case hp < 4:
+		rgb = {0, x, c}
+	case hp < 5:
+		rgb = {x, 0, c}
+	case:
+		rgb = {c, 0, x}
+	}
+	m := l - c / 2
+	return {rgb[0] + m, rgb[1] + m, rgb[2] + m}
+}
+
+// A swatch under the color literals of the lines on screen, and under the
+// colors a server reported: a band of the color below the color's text.
+// preview, while set, shows instead the color being picked for the one
+// starting at preview_line and preview_col.
+Color_Swatch_Layer_Data :: struct {
+	buffer:       ^Gap_Buffer,
+	cursor:       ^Cursor_Layer_Data, // for its grid: padding, line height, cell width
+	literals:     bool, // look for literals in the text
+	reported:     [dynamic]Reported_Color,
+	previewing:   bool,
+	preview_line: int,
+	preview_col:  int,
+	preview:      [4]f32,
+}
+
+// Replaces the reported colors with a copy of colors.
+set_reported_colors :: proc(d: ^Color_Swatch_Layer_Data, colors: []Reported_Color) {
+	clear(&d.reported)
+	append(&d.reported, ..colors)
+}
+
+make_color_swatch_layer :: proc(
+	buffer: ^Gap_Buffer,
+	cursor: ^Cursor_Layer_Data,
+	allocator: mem.Allocator = context.allocator,
+) -> Layer {
+	data := new(Color_Swatch_Layer_Data, allocator)
+	data.buffer = buffer
+	data.cursor = cursor
+	data.reported = make([dynamic]Reported_Color, allocator)
+
+	return Layer {
+		kind = .Decorations,
+		z_index = -40,
+		enabled = false,
+		name = "color_swatches",
+		user_data = data,
+		draw = proc(
+			layer: ^Layer,
+			br: ^Batch_Renderer,
+			atlas: ^Glyph_Atlas,
+			lctx: ^Layer_Context,
+		) {
+			d := cast(^Color_Swatch_Layer_Data)layer.user_data
+			c := d.cursor
+			first := max(int((lctx.scroll_y - c.padding[1]) / c.line_height), 0)
+			last := min(
+				int((lctx.scroll_y + lctx.viewport[1]) / c.line_height) + 1,
+				get_line_count(d.buffer) - 1,
+			)
+			if d.literals {
+				for line in first ..= last {
+					text := get_line(d.buffer, line, context.temp_allocator)
+					for lit in find_color_literals(text) {
+						draw_swatch(d, br, lctx, text, line, lit.start, lit.end, lit.color)
+					}
+				}
+			}
+			for r in d.reported {
+				if r.line < first || r.line > last {
+					continue
+				}
+				text := get_line(d.buffer, r.line, context.temp_allocator)
+				if r.end <= len(text) {
+					draw_swatch(d, br, lctx, text, r.line, r.start, r.end, r.color)
+				}
+			}
+		},
+		on_destroy = proc(layer: ^Layer) {
+			d := cast(^Color_Swatch_Layer_Data)layer.user_data
+			delete(d.reported)
+		},
+	}
+}
+
+// The band under the bytes start to end of line, text.
+@(private = "file")
+draw_swatch :: proc(
+	d: ^Color_Swatch_Layer_Data,
+	br: ^Batch_Renderer,
+	lctx: ^Layer_Context,
+	text: string,
+	line, start, end: int,
+	color: [4]f32,
+) {
+	c := d.cursor
+	color := color
+	if d.previewing && line == d.preview_line && start == d.preview_col {
+		color = d.preview
+	}
+	color.a = max(color.a, 0.15) // a clear color still shows where it is
+	band := max(c.line_height * 0.15, 2)
+	y := c.padding[1] + f32(line + 1) * c.line_height - lctx.scroll_y - band
+	from := text_columns(text[:start], lctx.tab_size)
+	to := text_columns(text[:end], lctx.tab_size)
+	x := c.padding[0] + f32(from) * c.char_width - lctx.scroll_x
+	push_rect(br, x, y, f32(to - from) * c.char_width, band, color)
+}
+
+@(private = "file")
+json_number :: proc(v: json.Value, key: string) -> f32 {
+	#partial switch n in json_field(v, key) {
+	case json.Integer:
+		return f32(n)
+	case json.Float:
+		return f32(n)
+	}
+	return 0
+}
+
+@(private = "file")
+parse_color_at :: proc(line: string, i: int) -> (lit: Color_Literal, ok: bool) {
+	rest := line[i:]
+	lit.start = i
+	if strings.has_prefix(rest, "#") {
+		n := 1
+		for n < len(rest) && n <= 8 && is_hex_digit(rest[n]) {
+			n += 1
+		}
+		digits := rest[1:n]
+		if n < len(rest) && is_color_word_byte(rest[n]) {
+			return {}, false
+		}
+		switch len(digits) {
+		case 3, 4:
+			for k in 0 ..< len(digits) {
+				v := hex_value(digits[k])
+				lit.color[k] = f32(v * 17) / 255
+			}
+		case 6, 8:
+			for k in 0 ..< len(digits) / 2 {
+				v := hex_value(digits[2 * k]) * 16 + hex_value(digits[2 * k + 1])
+				lit.color[k] = f32(v) / 255
+			}
+		case:
+			return {}, false
+		}
+		if len(digits) == 3 || len(digits) == 6 {
+			lit.color.a = 1
+		}
+		lit.end = i + n
+		lit.format = .Hex
+		return lit, true
+	}
+
+	lower := strings.to_lower(rest[:min(len(rest), 5)], context.temp_allocator)
+	name_len := 0
+	switch {
+	case strings.has_prefix(lower, "rgba(") || strings.has_prefix(lower, "hsla("):
+		name_len = 5
+	case strings.has_prefix(lower, "rgb(") || strings.has_prefix(lower, "hsl("):
+		name_len = 4
+	case:
+		return {}, false
+	}
+	close := strings.index_byte(rest, ')')
+	if close < 0 {
+		return {}, false
+	}
+	args := strings.fields_proc(rest[name_len:close], proc(r: rune) -> bool {
+		return r == ',' || r == ' ' || r == '/' || r == '\t'
+	}, context.temp_allocator)
+	if len(args) != 3 && len(args) != 4 {
+		return {}, false
+	}
+	is_hsl := lower[0] == 'h'
+	values: [4]f32
+	values[3] = 1
+	for arg, k in args {
+		v, percent, vok := parse_color_number(arg)
+		if !vok {
+			return {}, false
+		}
+		switch {
+		case k == 3:
+			values[k] = v / 100 if percent else v
+		case is_hsl && k == 0:
+			values[k] = v
+		case is_hsl:
+			values[k] = v / 100
+		case:
+			values[k] = v / 100 if percent else v / 255
+		}
+	}
+	rgb := values.rgb
+	lit.format = .Rgb
+	if is_hsl {
+		rgb = hsl_to_rgb(values[0], clamp(values[1], 0, 1), clamp(values[2], 0, 1))
+		lit.format = .Hsl
+	}
+	for k in 0 ..< 3 {
+		lit.color[k] = clamp(rgb[k], 0, 1)
+	}
+	lit.color.a = clamp(values[3], 0, 1)
+	lit.end = i + close + 1
+	return lit, true
+}
+
+// A number with an optional % or deg after it.
+@(private = "file")
+parse_color_number :: proc(s: string) -> (v: f32, percent, ok: bool) {
+	s := s
+	if strings.has_suffix(s, "%") {
+		s, percent = s[:len(s) - 1], true
+	}
+	s = strings.trim_suffix(s, "deg")
+	f: f64
+	f, ok = strconv.parse_f64(s)
+	return f32(f), percent, ok
+}
+
+@(private = "file")
+is_hex_digit :: proc(c: u8) -> bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+@(private = "file")
+hex_value :: proc(c: u8) -> int {
+	switch c {
+	case '0' ..= '9':
+		return int(c - '0')
+	case 'a' ..= 'f':
+		return int(c - 'a') + 10
+	case 'A' ..= 'F':
+		return int(c - 'A') + 10
+	}
+	return 0
+}
+
+@(private = "file")
+is_color_word_byte :: proc(c: u8) -> bool {
+	lower := c | 0x20
+	return c == '_' || c == '-' || (c >= '0' && c <= '9') || (lower >= 'a' && lower <= 'z')
+}
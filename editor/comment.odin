@@ -0,0 +1,206 @@
+package editor
+
+import "core:slice"
+import "core:strings"
+
+// An inclusive range of lines touched by one cursor or selection.
+Line_Range :: struct {
+	first: int,
+	last:  int,
+}
+
+// Replaces `count` bytes at `pos` and shifts every position in `positions`
+// (cursors, selection anchors) so they stay on the same text.
+edit_tracking_positions :: proc(
+	gb: ^Gap_Buffer,
+	pos: int,
+	count: int,
+	text: string,
+	positions: []int,
+) {
+	replace_bytes(gb, pos, count, transmute([]u8)text)
+	delta := len(text) - count
+	for &p in positions {
+		if p >= pos + count {
+			p += delta
+		} else if p > pos {
+			p = pos
+		}
+	}
+}
+
+// Returns the byte length of the leading spaces and tabs of s.
+leading_whitespace :: proc(s: string) -> int {
+	i := 0
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i += 1
+	}
+	return i
+}
+
+@(private = "file")
+merge_line_ranges :: proc(ranges: []Line_Range) -> []Line_Range {
+	sorted := slice.clone(ranges, context.temp_allocator)
+	slice.sort_by(sorted, proc(a, b: Line_Range) -> bool {return a.first < b.first})
+
+	merged := make([dynamic]Line_Range, 0, len(sorted), context.temp_allocator)
+	for r in sorted {
+		if len(merged) > 0 && r.first <= merged[len(merged) - 1].last + 1 {
+			last := &merged[len(merged) - 1]
+			last.last = max(last.last, r.last)
+		} else {
+			append(&merged, r)
+		}
+	}
+	return merged[:]
+}
+
+// Comments or uncomments every line in `ranges`.  If every non-blank line is
+// already commented the markers are removed, otherwise a marker is inserted at
+// the smallest indentation of each range so the block keeps its shape.
+// Languages without line comments fall back to wrapping each line in a block
+// comment.  Returns false when the language has no comment syntax at all.
+toggle_line_comments :: proc(
+	gb: ^Gap_Buffer,
+	lang: ^Language,
+	ranges: []Line_Range,
+	positions: []int,
+) -> bool {
+	if lang == nil {
+		return false
+	}
+	open, close := lang.line_comment, ""
+	if open == "" {
+		open, close = lang.block_comment[0], lang.block_comment[1]
+	}
+	if open == "" {
+		return false
+	}
+
+	merged := merge_line_ranges(ranges)
+
+	all_commented := true
+	any_content := false
+	for r in merged {
+		for ln in r.first ..= r.last {
+			line := get_line(gb, ln, context.temp_allocator)
+			body := line[leading_whitespace(line):]
+			if body == "" {
+				continue
+			}
+			any_content = true
+			if !strings.has_prefix(body, open) ||
+			   (close != "" && !strings.has_suffix(strings.trim_right_space(body), close)) {
+				all_commented = false
+			}
+		}
+	}
+	if !any_content {
+		return true
+	}
+
+	// Walk bottom-up so earlier line offsets stay valid while editing.
+	#reverse for r in merged {
+		indent := max(int)
+		for ln in r.first ..= r.last {
+			line := get_line(gb, ln, context.temp_allocator)
+			ws := leading_whitespace(line)
+			if ws < len(line) {
+				indent = min(indent, ws)
+			}
+		}
+
+		for ln := r.last; ln >= r.first; ln -= 1 {
+			line := get_line(gb, ln, context.temp_allocator)
+			ws := leading_whitespace(line)
+			if ws == len(line) {
+				continue
+			}
+			start := line_col_to_logical_pos(gb, ln, 0)
+			if all_commented {
+				uncomment_line(gb, start, line, ws, open, close, positions)
+			} else {
+				if close != "" {
+					end := start + len(strings.trim_right_space(line))
+					suffix := strings.concatenate({" ", close}, context.temp_allocator)
+					edit_tracking_positions(gb, end, 0, suffix, positions)
+				}
+				prefix := strings.concatenate({open, " "}, context.temp_allocator)
+				edit_tracking_positions(gb, start + min(indent, ws), 0, prefix, positions)
+			}
+		}
+	}
+	return true
+}
+
+@(private = "file")
+uncomment_line :: proc(
+	gb: ^Gap_Buffer,
+	start: int,
+	line: string,
+	ws: int,
+	open, close: string,
+	positions: []int,
+) {
+	if close != "" {
+		trimmed := strings.trim_right_space(line)
+		close_at := len(trimmed) - len(close)
+		if close_at > 0 && line[close_at - 1] == ' ' {
+			close_at -= 1
+		}
+		edit_tracking_positions(gb, start + close_at, len(trimmed) - close_at, "", positions)
+	}
+	n := len(open)
+	if ws + n < len(line) && line[ws + n] == ' ' {
+		n += 1
+	}
+	edit_tracking_positions(gb, start + ws, n, "", positions)
+}
+
+// Wraps the byte range [start, end) in a block comment, or unwraps it if the
+// (whitespace trimmed) range is already a single block comment.
+toggle_block_comment :: proc(
+	gb: ^Gap_Buffer,
+	lang: ^Language,
+	start, end: int,
+	positions: []int,
+) -> bool {
+	if lang == nil || lang.block_comment[0] == "" {
+		return false
+	}
+	open, close := lang.block_comment[0], lang.block_comment[1]
+
+	text := get_text_segment(gb, start, end - start, context.temp_allocator)
+	lead := leading_whitespace(text)
+	body := strings.trim_right_space(text[lead:])
+
+	if len(body) >= len(open) + len(close) &&
+	   strings.has_prefix(body, open) &&
+	   strings.has_suffix(body, close) {
+		body_start := start + lead
+		body_end := body_start + len(body)
+
+		close_pos := body_end - len(close)
+		close_len := len(close)
+		if close_pos > body_start + len(open) && char_at(gb, close_pos - 1) == ' ' {
+			close_pos -= 1
+			close_len += 1
+		}
+		edit_tracking_positions(gb, close_pos, close_len, "", positions)
+
+		open_len := len(open)
+		if body_start + open_len < close_pos && char_at(gb, body_start + open_len) == ' ' {
+			open_len += 1
+		}
+		edit_tracking_positions(gb, body_start, open_len, "", positions)
+		return true
+	}
+
+	body_start := start + lead
+	body_end := body_start + len(body)
+	suffix := strings.concatenate({" ", close}, context.temp_allocator)
+	prefix := strings.concatenate({open, " "}, context.temp_allocator)
+	edit_tracking_positions(gb, body_end, 0, suffix, positions)
+	edit_tracking_positions(gb, body_start, 0, prefix, positions)
+	return true
+}
@@ -0,0 +1,213 @@
+package editor
+
+import "core:mem"
+import "core:slice"
+import "core:strings"
+
+// Where a completion comes from.  Sources are merged into one list; a
+// language server's items will rank above the fallbacks.
+Completion_Source :: enum u8 {
+	Word, // a word of an open buffer
+	Path, // a file or directory name
+}
+
+Completion_Item :: struct {
+	label:  string, // inserted in place of the typed prefix
+	detail: string, // shown dimmed after the label
+	source: Completion_Source,
+}
+
+// A popup under the cursor listing completions, with a movable selection.
+Completion_Layer_Data :: struct {
+	font:         ^Font_Handle,
+	items:        [dynamic]Completion_Item,
+	selected:     int,
+	scroll:       int,
+	max_rows:     int,
+	visible:      bool,
+	line:         int, // anchor: the completed word's line
+	visual_col:   int, // and where it starts
+	line_height:  f32,
+	char_width:   f32,
+	padding:      [2]f32,
+	fg_color:     [4]f32,
+	dim_color:    [4]f32,
+	bg_color:     [4]f32,
+	select_color: [4]f32,
+	allocator:    mem.Allocator,
+}
+
+make_completion_layer :: proc(
+	font: ^Font_Handle,
+	line_height: f32,
+	char_width: f32,
+	padding: [2]f32,
+	max_rows: int,
+	allocator: mem.Allocator = context.allocator,
+) -> Layer {
+	data := new(Completion_Layer_Data, allocator)
+	data.font = font
+	data.items = make([dynamic]Completion_Item, allocator)
+	data.max_rows = max_rows
+	data.line_height = line_height
+	data.char_width = char_width
+	data.padding = padding
+	data.fg_color = {0.85, 0.85, 0.82, 1.0}
+	data.dim_color = {0.50, 0.50, 0.55, 1.0}
+	data.bg_color = {0.16, 0.16, 0.19, 1.0}
+	data.select_color = {0.22, 0.26, 0.34, 1.0}
+	data.allocator = allocator
+
+	return Layer {
+		kind = .Overlay,
+		z_index = 140,
+		enabled = true,
+		name = "completion",
+		user_data = data,
+		draw = proc(
+			layer: ^Layer,
+			br: ^Batch_Renderer,
+			atlas: ^Glyph_Atlas,
+			lctx: ^Layer_Context,
+		) {
+			d := cast(^Completion_Layer_Data)layer.user_data
+			if !d.visible || len(d.items) == 0 {
+				return
+			}
+			last := min(len(d.items), d.scroll + d.max_rows)
+			label_w, detail_w: f32
+			for item in d.items[d.scroll:last] {
+				label_w = max(label_w, measure_text(atlas, d.font, item.label))
+				detail_w = max(detail_w, measure_text(atlas, d.font, item.detail))
+			}
+			w := label_w + detail_w + 3 * d.char_width
+			h := f32(last - d.scroll) * d.line_height
+			x := d.padding[0] + f32(d.visual_col) * d.char_width - lctx.scroll_x - d.char_width
+			y := d.padding[1] + f32(d.line + 1) * d.line_height - lctx.scroll_y
+			if y + h > lctx.viewport[1] - d.line_height {
+				y -= h + d.line_height // no room below the line, so above it
+			}
+			x = clamp(x, 0, max(lctx.viewport[0] - w, 0))
+
+			push_rect(br, x, y, w, h, d.bg_color)
+			for i in d.scroll ..< last {
+				item := d.items[i]
+				if i == d.selected {
+					push_rect(br, x, y, w, d.line_height, d.select_color)
+				}
+				push_text(br, atlas, d.font, x + d.char_width, y, item.label, d.fg_color)
+				dx := x + label_w + 2 * d.char_width
+				push_text(br, atlas, d.font, dx, y, item.detail, d.dim_color)
+				y += d.line_height
+			}
+		},
+		on_destroy = proc(layer: ^Layer) {
+			d := cast(^Completion_Layer_Data)layer.user_data
+			completion_clear(d)
+			delete(d.items)
+		},
+	}
+}
+
+completion_clear :: proc(d: ^Completion_Layer_Data) {
+	for item in d.items {
+		delete(item.label, d.allocator)
+		delete(item.detail, d.allocator)
+	}
+	clear(&d.items)
+	d.selected = 0
+	d.scroll = 0
+}
+
+// Replaces the items with copies of items, keeping the selected label when
+// it is still listed.
+set_completion_items :: proc(d: ^Completion_Layer_Data, items: []Completion_Item) {
+	selected := ""
+	if d.selected < len(d.items) {
+		selected = strings.clone(d.items[d.selected].label, context.temp_allocator)
+	}
+	completion_clear(d)
+	for item, i in items {
+		owned := item
+		owned.label = strings.clone(item.label, d.allocator)
+		owned.detail = strings.clone(item.detail, d.allocator)
+		append(&d.items, owned)
+		if item.label == selected {
+			d.selected = i
+		}
+	}
+	completion_move_selection(d, 0)
+}
+
+// Moves the selection by delta, wrapping around the ends.
+completion_move_selection :: proc(d: ^Completion_Layer_Data, delta: int) {
+	n := len(d.items)
+	if n == 0 {
+		return
+	}
+	d.selected = ((d.selected + delta) % n + n) % n
+	if d.selected < d.scroll {
+		d.scroll = d.selected
+	} else if d.selected >= d.scroll + d.max_rows {
+		d.scroll = d.selected - d.max_rows + 1
+	}
+}
+
+// The words of text that start with prefix, ignoring case, other than
+// prefix itself and the word at skip (the one being typed; -1 for none).
+// Each word maps to base plus its distance from near, so closer words rank
+// first; words already in ranks keep their smaller distance.
+collect_words :: proc(ranks: ^map[string]int, text, prefix: string, near, skip, base: int) {
+	for i := 0; i < len(text); {
+		if !is_word_byte(text[i]) {
+			i += 1
+			continue
+		}
+		start := i
+		for i < len(text) && is_word_byte(text[i]) {
+			i += 1
+		}
+		word := text[start:i]
+		if start <= skip && skip <= i {
+			continue
+		}
+		if len(word) <= len(prefix) || (word[0] >= '0' && word[0] <= '9') {
+			continue
+		}
+		if !strings.equal_fold(word[:len(prefix)], prefix) {
+			continue
+		}
+		distance := base + abs(start - near)
+		if old, ok := ranks[word]; !ok || distance < old {
+			ranks[word] = distance
+		}
+	}
+}
+
+// The words of ranks, closest first and then in order, with exact case
+// prefix matches ahead of the others.  Temp allocated.
+rank_words :: proc(ranks: map[string]int, prefix: string) -> []string {
+	Ranked :: struct {
+		word:     string,
+		distance: int,
+		folded:   bool, // matches the prefix only when ignoring case
+	}
+	list := make([dynamic]Ranked, 0, len(ranks), context.temp_allocator)
+	for word, distance in ranks {
+		append(&list, Ranked{word, distance, !strings.has_prefix(word, prefix)})
+	}
+	slice.sort_by(list[:], proc(a, b: Ranked) -> bool {
+		if a.folded != b.folded {
+			return !a.folded
+		}
+		if a.distance != b.distance {
+			return a.distance < b.distance
+		}
+		return a.word < b.word
+	})
+	words := make([]string, len(list), context.temp_allocator)
+	for r, i in list {
+		words[i] = r.word
+	}
+	return words
+}
@@ -0,0 +1,298 @@
+package editor
+
+import "core:mem"
+import "core:strings"
+
+// A replicated text for collaborative editing (RGA).  Every byte ever
+// inserted keeps a unique id, and a tombstone once deleted, so edits made on
+// different peers at the same time merge to the same text in any order.  Ids
+// are Lamport timestamps; an insert names the byte it goes after, and bytes
+// inserted after the same one are ordered newest first.
+Crdt_Id :: struct {
+	clock: u32,
+	peer:  u32,
+}
+
+CRDT_START :: Crdt_Id{} // the origin of an insert at the very start
+
+Crdt_Element :: struct {
+	id:      Crdt_Id,
+	deleted: bool,
+	value:   u8,
+}
+
+Crdt_Text :: struct {
+	elements:  [dynamic]Crdt_Element, // in text order, tombstones included
+	peer:      u32, // this peer's id, never 0
+	clock:     u32, // the largest clock seen
+	allocator: mem.Allocator,
+}
+
+Crdt_Op_Kind :: enum u8 {
+	Insert,
+	Delete,
+}
+
+// A change to send to the other peers.  An insert puts text after origin,
+// its bytes taking consecutive clocks from id; a delete removes the count
+// bytes of id's peer with consecutive clocks from id.
+Crdt_Op :: struct {
+	kind:   Crdt_Op_Kind,
+	id:     Crdt_Id,
+	origin: Crdt_Id,
+	text:   string, // Insert
+	count:  int, // Delete
+}
+
+// Starts a text holding text, as typed by peer.
+init_crdt_text :: proc(
+	peer: u32,
+	text: string,
+	allocator: mem.Allocator = context.allocator,
+) -> Crdt_Text {
+	t := Crdt_Text {
+		elements  = make([dynamic]Crdt_Element, 0, len(text), allocator),
+		peer      = peer,
+		allocator = allocator,
+	}
+	for i in 0 ..< len(text) {
+		t.clock += 1
+		append(&t.elements, Crdt_Element{id = {t.clock, peer}, value = text[i]})
+	}
+	return t
+}
+
+destroy_crdt_text :: proc(t: ^Crdt_Text) {
+	delete(t.elements)
+	t^ = {}
+}
+
+// The visible text.  Temp allocated.
+crdt_string :: proc(t: ^Crdt_Text) -> string {
+	b := strings.builder_make(0, len(t.elements), context.temp_allocator)
+	for e in t.elements {
+		if !e.deleted {
+			strings.write_byte(&b, e.value)
+		}
+	}
+	return strings.to_string(b)
+}
+
+// Whether a was made after b, which puts it first among bytes inserted after
+// the same one.
+crdt_newer :: proc(a, b: Crdt_Id) -> bool {
+	if a.clock != b.clock {
+		return a.clock > b.clock
+	}
+	return a.peer > b.peer
+}
+
+// Inserts text at the visible position pos and appends the op to ops.
+crdt_insert :: proc(t: ^Crdt_Text, pos: int, text: string, ops: ^[dynamic]Crdt_Op) {
+	if text == "" {
+		return
+	}
+	origin := CRDT_START
+	at := 0
+	if pos > 0 {
+		i := crdt_visible_index(t, pos - 1)
+		origin = t.elements[i].id
+		at = i + 1
+	}
+	op := Crdt_Op {
+		kind   = .Insert,
+		id     = {t.clock + 1, t.peer},
+		origin = origin,
+		text   = text,
+	}
+	integrate_insert(t, op, at)
+	append(ops, op)
+}
+
+// Deletes count bytes at the visible position pos and appends the ops to
+// ops, one per run of consecutive ids.
+crdt_delete :: proc(t: ^Crdt_Text, pos, count: int, ops: ^[dynamic]Crdt_Op) {
+	if count <= 0 {
+		return
+	}
+	i := crdt_visible_index(t, pos)
+	for left := count; left > 0 && i < len(t.elements); i += 1 {
+		e := &t.elements[i]
+		if e.deleted {
+			continue
+		}
+		e.deleted = true
+		left -= 1
+		if n := len(ops); n > 0 {
+			last := &ops[n - 1]
+			next := Crdt_Id{last.id.clock + u32(last.count), last.id.peer}
+			if last.kind == .Delete && next == e.id {
+				last.count += 1
+				continue
+			}
+		}
+		append(ops, Crdt_Op{kind = .Delete, id = e.id, count = 1})
+	}
+}
+
+// Applies another peer's op.  Ops it already has are skipped, so a peer may
+// hear of one twice.
+crdt_apply :: proc(t: ^Crdt_Text, op: Crdt_Op) {
+	switch op.kind {
+	case .Insert:
+		if crdt_find(t, op.id) >= 0 {
+			return
+		}
+		at := 0
+		if op.origin != CRDT_START {
+			origin := crdt_find(t, op.origin)
+			if origin < 0 {
+				return // never happens over an ordered connection
+			}
+			at = origin + 1
+		}
+		// Past the bytes inserted after the same origin by newer ops, and
+		// everything inserted after those, which is newer still.
+		for at < len(t.elements) && crdt_newer(t.elements[at].id, op.id) {
+			at += 1
+		}
+		integrate_insert(t, op, at)
+	case .Delete:
+		i := crdt_find(t, op.id)
+		for k in 0 ..< op.count {
+			id := Crdt_Id{op.id.clock + u32(k), op.id.peer}
+			// The run is usually contiguous, tombstones aside.
+			for i >= 0 && i < len(t.elements) && t.elements[i].id != id {
+				i += 1
+			}
+			if i < 0 || i >= len(t.elements) {
+				i = crdt_find(t, id)
+			}
+			if i >= 0 {
+				t.elements[i].deleted = true
+			}
+		}
+		t.clock = max(t.clock, op.id.clock + u32(op.count) - 1)
+	}
+}
+
+// The visible position just after the byte id, which is where a cursor placed
+// after it is now, even when the byte is deleted.  CRDT_START is 0.
+crdt_position :: proc(t: ^Crdt_Text, id: Crdt_Id) -> int {
+	if id == CRDT_START {
+		return 0
+	}
+	pos := 0
+	for e in t.elements {
+		if !e.deleted {
+			pos += 1
+		}
+		if e.id == id {
+			return pos
+		}
+	}
+	return 0
+}
+
+// The id of the byte before the visible position pos, for a cursor that
+// should stay put as others edit.
+crdt_id_before :: proc(t: ^Crdt_Text, pos: int) -> Crdt_Id {
+	if pos <= 0 {
+		return CRDT_START
+	}
+	i := crdt_visible_index(t, pos - 1)
+	if i >= len(t.elements) {
+		return CRDT_START if len(t.elements) == 0 else t.elements[len(t.elements) - 1].id
+	}
+	return t.elements[i].id
+}
+
+// Elements with consecutive ids and the same state, the unit a snapshot is
+// sent in.
+Crdt_Run :: struct {
+	id:      Crdt_Id, // of the first byte
+	deleted: bool,
+	text:    string,
+}
+
+// The whole text, tombstones included, for a peer that joins.  Temp
+// allocated.
+crdt_runs :: proc(t: ^Crdt_Text) -> []Crdt_Run {
+	runs := make([dynamic]Crdt_Run, context.temp_allocator)
+	start := 0
+	for e, i in t.elements {
+		if i > start {
+			first := t.elements[start]
+			next := Crdt_Id{first.id.clock + u32(i - start), first.id.peer}
+			if e.id == next && e.deleted == first.deleted {
+				continue
+			}
+			append(&runs, snapshot_run(t, start, i))
+		}
+		start = i
+	}
+	if start < len(t.elements) {
+		append(&runs, snapshot_run(t, start, len(t.elements)))
+	}
+	return runs[:]
+}
+
+// Appends a run of another peer's snapshot.
+crdt_load_run :: proc(t: ^Crdt_Text, run: Crdt_Run) {
+	for i in 0 ..< len(run.text) {
+		id := Crdt_Id{run.id.clock + u32(i), run.id.peer}
+		append(&t.elements, Crdt_Element{id = id, deleted = run.deleted, value = run.text[i]})
+		t.clock = max(t.clock, id.clock)
+	}
+}
+
+// Index of the element with id, or -1.
+crdt_find :: proc(t: ^Crdt_Text, id: Crdt_Id) -> int {
+	for e, i in t.elements {
+		if e.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Index of the pos'th visible element, or len(elements) past the end.
+@(private = "file")
+crdt_visible_index :: proc(t: ^Crdt_Text, pos: int) -> int {
+	seen := 0
+	for e, i in t.elements {
+		if e.deleted {
+			continue
+		}
+		if seen == pos {
+			return i
+		}
+		seen += 1
+	}
+	return len(t.elements)
+}
+
+// Puts op's bytes at index at.  Each byte goes after the one before it, so a
+// run stays together however it is merged.
+@(private = "file")
+integrate_insert :: proc(t: ^Crdt_Text, op: Crdt_Op, at: int) {
+	run := make([]Crdt_Element, len(op.text), context.temp_allocator)
+	for i in 0 ..< len(op.text) {
+		run[i] = {
+			id    = {op.id.clock + u32(i), op.id.peer},
+			value = op.text[i],
+		}
+	}
+	inject_at(&t.elements, at, ..run)
+	t.clock = max(t.clock, op.id.clock + u32(len(op.text)) - 1)
+}
+
+@(private = "file")
+snapshot_run :: proc(t: ^Crdt_Text, start, end: int) -> Crdt_Run {
+	bytes := make([]u8, end - start, context.temp_allocator)
+	for e, i in t.elements[start:end] {
+		bytes[i] = e.value
+	}
+	first := t.elements[start]
+	return {id = first.id, deleted = first.deleted, text = string(bytes)}
+}
@@ -0,0 +1,426 @@
+package editor
+
+import "core:fmt"
+import "core:mem"
+import "core:slice"
+import "core:strconv"
+import "core:strings"
+import "core:unicode/utf8"
+
+// What delimited text is split at, see detect_csv_separator.
+CSV_SEPARATORS :: ",;\t"
+
+// Widest a column of the CSV view gets, in cells; longer values are cut.
+CSV_MAX_COLUMN_WIDTH :: 40
+
+// Cells between two columns of the CSV view.
+CSV_COLUMN_GAP :: 2
+
+// Cells of the record numbers left of the columns.
+CSV_ROW_NUMBER_WIDTH :: 6
+
+// A field of delimited text as the byte range it takes, quotes included.
+Csv_Cell :: struct {
+	start, end: int,
+}
+
+// The one of CSV_SEPARATORS that the first line of text has most of.
+detect_csv_separator :: proc(text: string) -> u8 {
+	first := text
+	if nl := strings.index_byte(text, '\n'); nl >= 0 {
+		first = text[:nl]
+	}
+	sep, most := u8(','), -1
+	for i in 0 ..< len(CSV_SEPARATORS) {
+		if n := strings.count(first, CSV_SEPARATORS[i:i + 1]); n > most {
+			sep, most = CSV_SEPARATORS[i], n
+		}
+	}
+	return sep
+}
+
+// Splits delimited text into records of fields.  Quoted fields may hold the
+// separator, line breaks and doubled quotes.
+parse_csv :: proc(
+	text: string,
+	sep: u8,
+	allocator := context.temp_allocator,
+) -> [dynamic][dynamic]Csv_Cell {
+	rows := make([dynamic][dynamic]Csv_Cell, allocator)
+	row := make([dynamic]Csv_Cell, allocator)
+	start := 0
+	quoted := false
+	for i in 0 ..< len(text) {
+		c := text[i]
+		if c == '"' {
+			quoted = !quoted // a doubled quote turns it off and on again
+		} else if !quoted && (c == sep || c == '\n') {
+			append(&row, Csv_Cell{start, i})
+			start = i + 1
+			if c == '\n' {
+				append(&rows, row)
+				row = make([dynamic]Csv_Cell, allocator)
+			}
+		}
+	}
+	if start < len(text) || len(row) > 0 {
+		append(&row, Csv_Cell{start, len(text)})
+		append(&rows, row)
+	} else {
+		delete(row)
+	}
+	return rows
+}
+
+// The value of a field of text: its quotes taken off and doubled quotes made
+// single.  A slice of text when it has no quotes, else allocated.
+csv_cell_text :: proc(text: string, cell: Csv_Cell, allocator := context.temp_allocator) -> string {
+	raw := text[cell.start:cell.end]
+	if strings.index_byte(raw, '"') < 0 && strings.index_byte(raw, '\r') < 0 {
+		return raw
+	}
+	b := strings.builder_make(allocator)
+	quoted := false
+	for i := 0; i < len(raw); i += 1 {
+		c := raw[i]
+		switch {
+		case quoted && c == '"' && i + 1 < len(raw) && raw[i + 1] == '"':
+			strings.write_byte(&b, '"')
+			i += 1
+		case c == '"':
+			quoted = !quoted
+		case quoted || c != '\r':
+			strings.write_byte(&b, c)
+		}
+	}
+	return strings.to_string(b)
+}
+
+// Delimited text shown as a grid covering the editor: columns lined up,
+// the header row pinned under the title, and the rows below it in the file's
+// order or sorted by a column.  The text is only read.
+Csv_View_Data :: struct {
+	font:          ^Font_Handle,
+	visible:       bool,
+	line_height:   f32,
+	bottom_margin: f32, // space reserved below the view (status line)
+	title:         string,
+	text:          string, // the cells are ranges of it
+	rows:          [dynamic][dynamic]Csv_Cell,
+	header:        bool, // rows[0] is pinned and left out of sorting
+	order:         [dynamic]int, // the rows under the header, as shown
+	widths:        [dynamic]int, // of the columns, in cells
+	row:           int, // the cursor cell, an index into order
+	col:           int,
+	scroll:        int, // first entry of order shown
+	scroll_col:    int, // first column shown
+	sort_col:      int, // -1 for the file's order
+	descending:    bool,
+	page_rows:     int, // rows that fit, as of the last draw
+	page_width:    f32, // and the width and cell width then
+	cell_width:    f32,
+	fg_color:      [4]f32,
+	dim_color:     [4]f32,
+	bg_color:      [4]f32,
+	title_color:   [4]f32,
+	header_color:  [4]f32,
+	cursor_color:  [4]f32,
+	allocator:     mem.Allocator,
+}
+
+make_csv_view_layer :: proc(
+	font: ^Font_Handle,
+	line_height: f32,
+	bottom_margin: f32,
+	allocator: mem.Allocator = context.allocator,
+) -> Layer {
+	data := new(Csv_View_Data, allocator)
+	data.font = font
+	data.line_height = line_height
+	data.bottom_margin = bottom_margin
+	data.order = make([dynamic]int, allocator)
+	data.widths = make([dynamic]int, allocator)
+	data.sort_col = -1
+	data.page_rows = 1
+	data.fg_color = {0.92, 0.91, 0.88, 1.0}
+	data.dim_color = {0.45, 0.45, 0.50, 1.0}
+	data.bg_color = {0.12, 0.12, 0.14, 1.0}
+	data.title_color = {0.16, 0.16, 0.19, 1.0}
+	data.header_color = {0.20, 0.22, 0.28, 1.0}
+	data.cursor_color = {0.25, 0.35, 0.55, 0.8}
+	data.allocator = allocator
+
+	return Layer {
+		kind = .Overlay,
+		z_index = 140,
+		enabled = true,
+		name = "csv_view",
+		user_data = data,
+		draw = proc(
+			layer: ^Layer,
+			br: ^Batch_Renderer,
+			atlas: ^Glyph_Atlas,
+			lctx: ^Layer_Context,
+		) {
+			d := cast(^Csv_View_Data)layer.user_data
+			if !d.visible {
+				return
+			}
+			w := lctx.viewport[0]
+			h := lctx.viewport[1] - d.bottom_margin
+			lh := d.line_height
+			d.cell_width = get_glyph(atlas, d.font, ' ').advance_x
+			d.page_width = w
+			push_rect(br, 0, 0, w, h, d.bg_color)
+			push_rect(br, 0, 0, w, lh, d.title_color)
+			push_text(br, atlas, d.font, 8, 0, d.title, d.fg_color)
+			if d.row < len(d.order) {
+				at := fmt.tprintf("row %d, %s", d.order[d.row] + 1, csv_column_name(d, d.col))
+				at_x := w - 8 - measure_text(atlas, d.font, at)
+				push_text(br, atlas, d.font, at_x, 0, at, d.dim_color)
+			}
+
+			y := lh
+			if d.header && len(d.rows) > 0 {
+				push_rect(br, 0, y, w, lh, d.header_color)
+				draw_csv_row(d, br, atlas, 0, y, -1)
+				y += lh
+			}
+			d.page_rows = max(int((h - y) / lh), 1)
+			for i in 0 ..< d.page_rows {
+				k := d.scroll + i
+				if k >= len(d.order) {
+					break
+				}
+				draw_csv_row(d, br, atlas, d.order[k], y, d.col if k == d.row else -1)
+				y += lh
+			}
+		},
+		on_destroy = proc(layer: ^Layer) {
+			d := cast(^Csv_View_Data)layer.user_data
+			clear_csv_view(d)
+			delete(d.rows)
+			delete(d.order)
+			delete(d.widths)
+		},
+	}
+}
+
+// Shows text split at sep, with the cursor on the cell that holds byte pos.
+set_csv_view :: proc(d: ^Csv_View_Data, title, text: string, sep: u8, header: bool, pos: int) {
+	clear_csv_view(d)
+	d.title = strings.clone(title, d.allocator)
+	d.text = strings.clone(text, d.allocator)
+	delete(d.rows)
+	d.rows = parse_csv(d.text, sep, d.allocator)
+	for row in d.rows {
+		for cell, c in row {
+			if c >= len(d.widths) {
+				append(&d.widths, 1)
+			}
+			value := csv_cell_text(d.text, cell, context.temp_allocator)
+			d.widths[c] = min(max(d.widths[c], csv_text_width(value)), CSV_MAX_COLUMN_WIDTH)
+		}
+	}
+	d.header = header
+	sort_csv_view(d, -1, false)
+	for k in 0 ..< len(d.order) {
+		row := d.rows[d.order[k]]
+		for cell, c in row {
+			if cell.start <= pos && pos <= cell.end {
+				d.row, d.col = k, c
+			}
+		}
+	}
+	csv_view_move(d, 0, 0)
+	d.visible = true
+}
+
+clear_csv_view :: proc(d: ^Csv_View_Data) {
+	for row in d.rows {
+		delete(row)
+	}
+	clear(&d.rows)
+	clear(&d.order)
+	clear(&d.widths)
+	delete(d.title, d.allocator)
+	delete(d.text, d.allocator)
+	d.title = ""
+	d.text = ""
+	d.row, d.col = 0, 0
+	d.scroll, d.scroll_col = 0, 0
+	d.sort_col = -1
+	d.descending = false
+	d.visible = false
+}
+
+// Orders the rows under the header by column col, numbers by value before
+// the rest by text; col -1 is the file's order.  Rows that tie stay in the
+// file's order, and the cursor stays on its row.
+sort_csv_view :: proc(d: ^Csv_View_Data, col: int, descending: bool) {
+	current := d.order[d.row] if d.row < len(d.order) else -1
+	first := 1 if d.header else 0
+	items := make([dynamic]Csv_Sort_Item, context.temp_allocator)
+	for r in first ..< len(d.rows) {
+		item := Csv_Sort_Item{row = r, descending = descending}
+		if col >= 0 && col < len(d.rows[r]) {
+			item.text = csv_cell_text(d.text, d.rows[r][col], context.temp_allocator)
+			item.number, item.is_number = strconv.parse_f64(strings.trim_space(item.text))
+		}
+		append(&items, item)
+	}
+	if col >= 0 {
+		slice.stable_sort_by(items[:], proc(a, b: Csv_Sort_Item) -> bool {
+			return csv_sort_less(b, a) if a.descending else csv_sort_less(a, b)
+		})
+	}
+	clear(&d.order)
+	for item, k in items {
+		append(&d.order, item.row)
+		if item.row == current {
+			d.row = k
+		}
+	}
+	d.sort_col = col
+	d.descending = descending
+	csv_view_move(d, 0, 0)
+}
+
+// Moves the cursor by rows and columns, keeping it on screen.
+csv_view_move :: proc(d: ^Csv_View_Data, rows, cols: int) {
+	d.row = clamp(d.row + rows, 0, max(len(d.order) - 1, 0))
+	d.col = clamp(d.col + cols, 0, max(len(d.widths) - 1, 0))
+	if d.row < d.scroll {
+		d.scroll = d.row
+	} else if d.row >= d.scroll + d.page_rows {
+		d.scroll = d.row - d.page_rows + 1
+	}
+	if d.col < d.scroll_col {
+		d.scroll_col = d.col
+	}
+	for d.page_width > 0 && d.scroll_col < d.col && csv_column_x(d, d.col + 1) > d.page_width {
+		d.scroll_col += 1
+	}
+}
+
+// The byte range of the cursor cell in the text, or false past a short row.
+csv_view_cell :: proc(d: ^Csv_View_Data) -> (Csv_Cell, bool) {
+	if d.row >= len(d.order) {
+		return {}, false
+	}
+	row := d.rows[d.order[d.row]]
+	if d.col >= len(row) {
+		return {}, false
+	}
+	return row[d.col], true
+}
+
+// The value at the top of column col, or its number.  Temp allocated.
+csv_column_name :: proc(d: ^Csv_View_Data, col: int) -> string {
+	if d.header && len(d.rows) > 0 && col < len(d.rows[0]) {
+		if name := csv_cell_text(d.text, d.rows[0][col]); name != "" {
+			return name
+		}
+	}
+	buf: [16]u8
+	return strings.concatenate({"column ", fmt_int_buf(buf[:], col + 1)}, context.temp_allocator)
+}
+
+@(private = "file")
+Csv_Sort_Item :: struct {
+	row:        int,
+	number:     f64,
+	is_number:  bool,
+	text:       string,
+	descending: bool,
+}
+
+@(private = "file")
+csv_sort_less :: proc(a, b: Csv_Sort_Item) -> bool {
+	if a.is_number != b.is_number {
+		return a.is_number
+	}
+	if a.is_number {
+		return a.number < b.number
+	}
+	return a.text < b.text
+}
+
+// Where column col starts on screen, columns before scroll_col being off it.
+@(private = "file")
+csv_column_x :: proc(d: ^Csv_View_Data, col: int) -> f32 {
+	x: f32 = 8 + f32(CSV_ROW_NUMBER_WIDTH + CSV_COLUMN_GAP) * d.cell_width
+	for c in d.scroll_col ..< min(col, len(d.widths)) {
+		x += f32(d.widths[c] + CSV_COLUMN_GAP) * d.cell_width
+	}
+	return x
+}
+
+// Draws record r at y, with the cursor on column cursor unless it is -1.
+// Numbers are right aligned in their column.
+@(private = "file")
+draw_csv_row :: proc(
+	d: ^Csv_View_Data,
+	br: ^Batch_Renderer,
+	atlas: ^Glyph_Atlas,
+	r: int,
+	y: f32,
+	cursor: int,
+) {
+	cw := d.cell_width
+	if r > 0 || !d.header {
+		buf: [16]u8
+		num := fmt_int_buf(buf[:], r + 1)
+		num_x := 8 + f32(CSV_ROW_NUMBER_WIDTH - len(num)) * cw
+		push_text(br, atlas, d.font, num_x, y, num, d.dim_color)
+	}
+	row := d.rows[r]
+	for c in d.scroll_col ..< len(d.widths) {
+		x := csv_column_x(d, c)
+		if x >= d.page_width {
+			break
+		}
+		width := d.widths[c]
+		if c == cursor {
+			span := f32(width + CSV_COLUMN_GAP) * cw
+			push_rect(br, x - cw, y, span, d.line_height, d.cursor_color)
+		}
+		if c >= len(row) {
+			continue
+		}
+		value := csv_cell_text(d.text, row[c])
+		if _, ok := strconv.parse_f64(strings.trim_space(value)); ok {
+			value = strings.trim_space(value)
+			x += f32(width - min(csv_text_width(value), width)) * cw
+		}
+		col := 0
+		for i := 0; i < len(value); {
+			next := next_grapheme(value, i)
+			cluster := value[i:next]
+			i = next
+			cells := csv_text_width(cluster)
+			if col + cells > width {
+				break
+			}
+			ch, _ := utf8.decode_rune_in_string(cluster)
+			info := get_glyph(atlas, d.font, ch)
+			if info.size[0] > 0 && ch != '\t' && ch != '\n' {
+				push_glyph(br, x + f32(col) * cw, y + d.font.ascent, info, d.fg_color)
+			}
+			col += cells
+		}
+	}
+}
+
+// Cells s takes in the grid, a tab or line break as one blank.
+@(private = "file")
+csv_text_width :: proc(s: string) -> int {
+	width := 0
+	for i := 0; i < len(s); {
+		next := next_grapheme(s, i)
+		cluster := s[i:next]
+		width += 1 if cluster == "\t" || cluster == "\n" else grapheme_width(cluster)
+		i = next
+	}
+	return width
+}
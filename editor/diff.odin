@@ -0,0 +1,219 @@
+package editor
+
+import "core:mem"
+import "core:strings"
+
+// Past this many differing lines the diff stops looking for a minimal edit
+// and reports the rest as one replaced block.
+DIFF_MAX_EDITS :: 1000
+
+// Lines old[old_start:][:old_count] were replaced by new[new_start:][:new_count].
+// A hunk with old_count 0 only adds lines, one with new_count 0 only removes.
+Diff_Hunk :: struct {
+	old_start: int,
+	old_count: int,
+	new_start: int,
+	new_count: int,
+}
+
+Diff_Hunk_Kind :: enum {
+	Added,
+	Removed,
+	Modified,
+}
+
+hunk_kind :: proc(h: Diff_Hunk) -> Diff_Hunk_Kind {
+	switch {
+	case h.old_count == 0:
+		return .Added
+	case h.new_count == 0:
+		return .Removed
+	}
+	return .Modified
+}
+
+// Compares two lists of lines with Myers' algorithm and returns the changed
+// blocks in order.
+diff_lines :: proc(
+	old, new: []string,
+	allocator: mem.Allocator = context.allocator,
+) -> []Diff_Hunk {
+	// Common leading and trailing lines never take part in the search.
+	prefix := 0
+	for prefix < len(old) && prefix < len(new) && old[prefix] == new[prefix] {
+		prefix += 1
+	}
+	suffix := 0
+	for suffix < len(old) - prefix &&
+	    suffix < len(new) - prefix &&
+	    old[len(old) - 1 - suffix] == new[len(new) - 1 - suffix] {
+		suffix += 1
+	}
+	a := old[prefix:len(old) - suffix]
+	b := new[prefix:len(new) - suffix]
+
+	removed := make([]bool, len(a), context.temp_allocator)
+	added := make([]bool, len(b), context.temp_allocator)
+	if !mark_changes(a, b, removed, added) {
+		for &r in removed do r = true
+		for &r in added do r = true
+	}
+
+	hunks := make([dynamic]Diff_Hunk, allocator)
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		if i < len(a) && j < len(b) && !removed[i] && !added[j] {
+			i += 1
+			j += 1
+			continue
+		}
+		h := Diff_Hunk {
+			old_start = prefix + i,
+			new_start = prefix + j,
+		}
+		for i < len(a) && removed[i] {
+			i += 1
+			h.old_count += 1
+		}
+		for j < len(b) && added[j] {
+			j += 1
+			h.new_count += 1
+		}
+		append(&hunks, h)
+	}
+	return hunks[:]
+}
+
+// Replaces count bytes at pos with text.
+Text_Edit :: struct {
+	pos:   int,
+	count: int,
+	text:  string,
+}
+
+// The edits, in order, that turn old into new a block of lines at a time.
+// Applied back to front they leave unchanged lines alone, and with them the
+// positions map_position keeps track of.  The texts borrow from new.
+diff_text :: proc(old, new: string, allocator: mem.Allocator = context.allocator) -> []Text_Edit {
+	// Lines keep their line break, so joining a block gives its exact text.
+	old_lines := strings.split_after(old, "\n", context.temp_allocator)
+	new_lines := strings.split_after(new, "\n", context.temp_allocator)
+	old_starts := make([]int, len(old_lines) + 1, context.temp_allocator)
+	for line, i in old_lines {
+		old_starts[i + 1] = old_starts[i] + len(line)
+	}
+	new_starts := make([]int, len(new_lines) + 1, context.temp_allocator)
+	for line, i in new_lines {
+		new_starts[i + 1] = new_starts[i] + len(line)
+	}
+
+	hunks := diff_lines(old_lines, new_lines, context.temp_allocator)
+	edits := make([]Text_Edit, len(hunks), allocator)
+	for h, i in hunks {
+		pos := old_starts[h.old_start]
+		start := new_starts[h.new_start]
+		edits[i] = Text_Edit {
+			pos   = pos,
+			count = old_starts[h.old_start + h.old_count] - pos,
+			text  = new[start:new_starts[h.new_start + h.new_count]],
+		}
+	}
+	return edits
+}
+
+// Where a position in the old text ends up once edits, as diff_text returns
+// them, are applied.  A position in a replaced block keeps its offset into
+// the block as far as the new text reaches.
+map_position :: proc(edits: []Text_Edit, pos: int) -> int {
+	shift := 0
+	for e in edits {
+		if pos < e.pos {
+			break
+		}
+		if pos >= e.pos + e.count {
+			shift += len(e.text) - e.count
+			continue
+		}
+		return e.pos + shift + min(pos - e.pos, len(e.text))
+	}
+	return pos + shift
+}
+
+// Finds a shortest edit script from a to b and flags the removed and added
+// lines.  Returns false when it needs more than DIFF_MAX_EDITS edits.
+@(private = "file")
+mark_changes :: proc(a, b: []string, removed, added: []bool) -> bool {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		for &r in removed do r = true
+		for &r in added do r = true
+		return true
+	}
+	limit := min(n + m, DIFF_MAX_EDITS)
+	offset := limit + 1
+	v := make([]int, 2 * limit + 3, context.temp_allocator)
+	// trace[d] holds v[-d-1 ..= d+1] as it was before round d.
+	trace := make([dynamic][]int, context.temp_allocator)
+
+	found := false
+	search: for d in 0 ..= limit {
+		append(&trace, clone_slice(v[offset - d - 1:offset + d + 2]))
+		for k := -d; k <= d; k += 2 {
+			x: int
+			if k == -d || (k != d && v[offset + k - 1] < v[offset + k + 1]) {
+				x = v[offset + k + 1] // down: a line of b was added
+			} else {
+				x = v[offset + k - 1] + 1 // right: a line of a was removed
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x += 1
+				y += 1
+			}
+			v[offset + k] = x
+			if x >= n && y >= m {
+				found = true
+				break search
+			}
+		}
+	}
+	if !found {
+		return false
+	}
+
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d -= 1 {
+		tv := trace[d]
+		k := x - y
+		prev_k := k - 1
+		if k == -d || (k != d && at(tv, d, k - 1) < at(tv, d, k + 1)) {
+			prev_k = k + 1
+		}
+		prev_x := at(tv, d, prev_k)
+		prev_y := prev_x - prev_k
+		for x > prev_x && y > prev_y {
+			x -= 1
+			y -= 1
+		}
+		if x == prev_x {
+			added[prev_y] = true
+		} else {
+			removed[prev_x] = true
+		}
+		x, y = prev_x, prev_y
+	}
+	return true
+}
+
+// v[k] in the copy trace[d].
+@(private = "file")
+at :: proc(tv: []int, d, k: int) -> int {
+	return tv[k + d + 1]
+}
+
+@(private = "file")
+clone_slice :: proc(s: []int) -> []int {
+	c := make([]int, len(s), context.temp_allocator)
+	copy(c, s)
+	return c
+}
@@ -0,0 +1,307 @@
+package editor
+
+import "core:mem"
+import "core:strings"
+import "core:unicode/utf8"
+
+Diff_Row_Kind :: enum u8 {
+	Same,
+	Removed, // only on the left
+	Added, // only on the right
+	Modified, // changed line, shown on both sides
+}
+
+// One row of the side-by-side view.  A side without a line shows a filler so
+// that the two panes stay aligned and scroll together.
+Diff_Row :: struct {
+	left:  int, // line index, -1 for a filler
+	right: int,
+	kind:  Diff_Row_Kind,
+}
+
+// Two texts side by side, old on the left and new on the right, covering the
+// editor while shown.  Both panes share the row list and thus the scroll.
+Diff_View_Data :: struct {
+	font:          ^Font_Handle,
+	visible:       bool,
+	line_height:   f32,
+	bottom_margin: f32, // space reserved below the view (status line)
+	titles:        [2]string,
+	texts:         [2]string, // left and right
+	lines:         [2][]string, // slices of texts
+	rows:          [dynamic]Diff_Row,
+	hunks:         [dynamic]int, // first row of every changed block
+	scroll:        int, // first visible row
+	page_rows:     int, // rows that fit, as of the last draw
+	tab_size:      int,
+	fg_color:      [4]f32,
+	dim_color:     [4]f32,
+	bg_color:      [4]f32,
+	title_color:   [4]f32,
+	removed_color: [4]f32, // line backgrounds
+	added_color:   [4]f32,
+	filler_color:  [4]f32,
+	removed_span:  [4]f32, // the changed part of a modified line
+	added_span:    [4]f32,
+	allocator:     mem.Allocator,
+}
+
+make_diff_view_layer :: proc(
+	font: ^Font_Handle,
+	line_height: f32,
+	bottom_margin: f32,
+	allocator: mem.Allocator = context.allocator,
+) -> Layer {
+	data := new(Diff_View_Data, allocator)
+	data.font = font
+	data.line_height = line_height
+	data.bottom_margin = bottom_margin
+	data.rows = make([dynamic]Diff_Row, allocator)
+	data.hunks = make([dynamic]int, allocator)
+	data.page_rows = 1
+	data.tab_size = 4
+	data.fg_color = {0.92, 0.91, 0.88, 1.0}
+	data.dim_color = {0.45, 0.45, 0.50, 1.0}
+	data.bg_color = {0.12, 0.12, 0.14, 1.0}
+	data.title_color = {0.16, 0.16, 0.19, 1.0}
+	data.removed_color = {0.35, 0.12, 0.12, 0.6}
+	data.added_color = {0.12, 0.32, 0.15, 0.6}
+	data.filler_color = {0.16, 0.16, 0.18, 1.0}
+	data.removed_span = {0.65, 0.20, 0.20, 0.7}
+	data.added_span = {0.20, 0.55, 0.25, 0.7}
+	data.allocator = allocator
+
+	return Layer {
+		kind = .Overlay,
+		z_index = 140,
+		enabled = true,
+		name = "diff_view",
+		user_data = data,
+		draw = proc(
+			layer: ^Layer,
+			br: ^Batch_Renderer,
+			atlas: ^Glyph_Atlas,
+			lctx: ^Layer_Context,
+		) {
+			d := cast(^Diff_View_Data)layer.user_data
+			if !d.visible {
+				return
+			}
+			d.tab_size = lctx.tab_size
+			w := lctx.viewport[0]
+			h := lctx.viewport[1] - d.bottom_margin
+			half := w / 2
+			lh := d.line_height
+			push_rect(br, 0, 0, w, h, d.bg_color)
+			push_rect(br, 0, 0, w, lh, d.title_color)
+			push_text(br, atlas, d.font, 8, 0, d.titles[0], d.fg_color)
+			push_text(br, atlas, d.font, half + 8, 0, d.titles[1], d.fg_color)
+
+			d.page_rows = max(int((h - lh) / lh), 1)
+			for i in 0 ..< d.page_rows {
+				r := d.scroll + i
+				if r >= len(d.rows) {
+					break
+				}
+				row := d.rows[r]
+				y := lh * f32(i + 1)
+				spans := diff_row_spans(d, row)
+				draw_diff_side(d, br, atlas, 0, half, y, 0, row.left, row.kind, spans[0])
+				draw_diff_side(d, br, atlas, half, half, y, 1, row.right, row.kind, spans[1])
+			}
+			push_rect(br, half - 1, 0, 2, h, d.dim_color)
+		},
+		on_destroy = proc(layer: ^Layer) {
+			d := cast(^Diff_View_Data)layer.user_data
+			clear_diff_view(d)
+			delete(d.rows)
+			delete(d.hunks)
+		},
+	}
+}
+
+// Shows old (left) against new (right), line by line.
+set_diff_view :: proc(d: ^Diff_View_Data, old_title, new_title, old, new: string) {
+	clear_diff_view(d)
+	d.titles = {strings.clone(old_title, d.allocator), strings.clone(new_title, d.allocator)}
+	d.texts = {strings.clone(old, d.allocator), strings.clone(new, d.allocator)}
+	d.lines = {
+		strings.split(d.texts[0], "\n", d.allocator),
+		strings.split(d.texts[1], "\n", d.allocator),
+	}
+
+	hunks := diff_lines(d.lines[0], d.lines[1], context.temp_allocator)
+	li, ri := 0, 0
+	for h in hunks {
+		for li < h.old_start {
+			append(&d.rows, Diff_Row{li, ri, .Same})
+			li += 1
+			ri += 1
+		}
+		append(&d.hunks, len(d.rows))
+		paired := min(h.old_count, h.new_count)
+		for k in 0 ..< max(h.old_count, h.new_count) {
+			switch {
+			case k < paired:
+				append(&d.rows, Diff_Row{li, ri, .Modified})
+				li += 1
+				ri += 1
+			case k < h.old_count:
+				append(&d.rows, Diff_Row{li, -1, .Removed})
+				li += 1
+			case:
+				append(&d.rows, Diff_Row{-1, ri, .Added})
+				ri += 1
+			}
+		}
+	}
+	for li < len(d.lines[0]) && ri < len(d.lines[1]) {
+		append(&d.rows, Diff_Row{li, ri, .Same})
+		li += 1
+		ri += 1
+	}
+	d.scroll = 0
+	d.visible = true
+}
+
+clear_diff_view :: proc(d: ^Diff_View_Data) {
+	for side in 0 ..< 2 {
+		delete(d.lines[side], d.allocator)
+		delete(d.texts[side], d.allocator)
+		delete(d.titles[side], d.allocator)
+	}
+	d.lines = {}
+	d.texts = {}
+	d.titles = {}
+	clear(&d.rows)
+	clear(&d.hunks)
+	d.visible = false
+}
+
+// Scrolls by delta rows, keeping the last row on screen.
+diff_view_scroll :: proc(d: ^Diff_View_Data, delta: int) {
+	d.scroll = clamp(d.scroll + delta, 0, max(len(d.rows) - d.page_rows, 0))
+}
+
+// Scrolls the next (dir > 0) or previous changed block to the top.  Returns
+// false when there is none in that direction.
+diff_view_step_hunk :: proc(d: ^Diff_View_Data, dir: int) -> bool {
+	if dir > 0 {
+		for r in d.hunks {
+			if r > d.scroll {
+				d.scroll = r
+				return true
+			}
+		}
+	} else {
+		#reverse for r in d.hunks {
+			if r < d.scroll {
+				d.scroll = r
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Byte ranges that differ within a modified row, left and right: whatever
+// is left between the common prefix and suffix.
+@(private = "file")
+diff_row_spans :: proc(d: ^Diff_View_Data, row: Diff_Row) -> [2][2]int {
+	if row.kind != .Modified {
+		return {}
+	}
+	a, b := d.lines[0][row.left], d.lines[1][row.right]
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix += 1
+	}
+	for prefix > 0 && prefix < len(a) && !utf8.rune_start(a[prefix]) {
+		prefix -= 1
+	}
+	suffix := 0
+	for suffix < len(a) - prefix &&
+	    suffix < len(b) - prefix &&
+	    a[len(a) - 1 - suffix] == b[len(b) - 1 - suffix] {
+		suffix += 1
+	}
+	for suffix > 0 && !utf8.rune_start(a[len(a) - suffix]) {
+		suffix -= 1
+	}
+	return {{prefix, len(a) - suffix}, {prefix, len(b) - suffix}}
+}
+
+// Draws one side of a row: background, line number and text on the cell
+// grid, clipped to the pane.
+@(private = "file")
+draw_diff_side :: proc(
+	d: ^Diff_View_Data,
+	br: ^Batch_Renderer,
+	atlas: ^Glyph_Atlas,
+	x, width, y: f32,
+	side, line: int,
+	kind: Diff_Row_Kind,
+	span: [2]int,
+) {
+	lh := d.line_height
+	if line < 0 {
+		push_rect(br, x, y, width, lh, d.filler_color)
+		return
+	}
+	if kind != .Same {
+		push_rect(br, x, y, width, lh, d.removed_color if side == 0 else d.added_color)
+	}
+
+	cell_w := get_glyph(atlas, d.font, ' ').advance_x
+	buf: [16]u8
+	num := fmt_int_buf(buf[:], line + 1)
+	num_x := x + f32(6 - len(num)) * cell_w
+	push_text(br, atlas, d.font, num_x, y, num, d.dim_color)
+
+	text_x := x + 8 * cell_w
+	max_x := x + width - cell_w
+	s := d.lines[side][line]
+	if span[1] > span[0] {
+		x0 := text_x + f32(grid_col(s, span[0], d.tab_size)) * cell_w
+		x1 := text_x + f32(grid_col(s, span[1], d.tab_size)) * cell_w
+		color := d.removed_span if side == 0 else d.added_span
+		push_rect(br, x0, y, min(x1, max_x) - x0, lh, color)
+	}
+
+	col := 0
+	for i := 0; i < len(s); {
+		next := next_grapheme(s, i)
+		cluster := s[i:next]
+		i = next
+		if cluster == "\t" {
+			col = (col / max(d.tab_size, 1) + 1) * max(d.tab_size, 1)
+			continue
+		}
+		pen_x := text_x + f32(col) * cell_w
+		if pen_x >= max_x {
+			break
+		}
+		r, _ := utf8.decode_rune_in_string(cluster)
+		info := get_glyph(atlas, d.font, r)
+		if info.size[0] > 0 {
+			push_glyph(br, pen_x, y + d.font.ascent, info, d.fg_color)
+		}
+		col += grapheme_width(cluster)
+	}
+}
+
+// Cell column of byte offset i in s.
+@(private = "file")
+grid_col :: proc(s: string, i: int, tab_size: int) -> int {
+	col := 0
+	for j := 0; j < len(s) && j < i; {
+		next := next_grapheme(s, j)
+		if s[j:next] == "\t" {
+			col = (col / max(tab_size, 1) + 1) * max(tab_size, 1)
+		} else {
+			col += grapheme_width(s[j:next])
+		}
+		j = next
+	}
+	return col
+}
@@ -0,0 +1,244 @@
+package editor
+
+// Two characters that stand for one that is hard to type, as in RFC 1345 and
+// Vim: "e'" is é, "a*" is α and "->" is →.  The second character is mostly a
+// mark for the accent: ! grave, ' acute, > circumflex, ? tilde, : diaeresis,
+// * Greek.  The names double as the fallback for unicode_name.
+Digraph :: struct {
+	keys: string,
+	r:    rune,
+	name: string,
+}
+
+DIGRAPHS := [?]Digraph {
+	{"a!", 0x00e0, "LATIN SMALL LETTER A WITH GRAVE"},
+	{"a'", 0x00e1, "LATIN SMALL LETTER A WITH ACUTE"},
+	{"a>", 0x00e2, "LATIN SMALL LETTER A WITH CIRCUMFLEX"},
+	{"a?", 0x00e3, "LATIN SMALL LETTER A WITH TILDE"},
+	{"a:", 0x00e4, "LATIN SMALL LETTER A WITH DIAERESIS"},
+	{"e!", 0x00e8, "LATIN SMALL LETTER E WITH GRAVE"},
+	{"e'", 0x00e9, "LATIN SMALL LETTER E WITH ACUTE"},
+	{"e>", 0x00ea, "LATIN SMALL LETTER E WITH CIRCUMFLEX"},
+	{"e?", 0x1ebd, "LATIN SMALL LETTER E WITH TILDE"},
+	{"e:", 0x00eb, "LATIN SMALL LETTER E WITH DIAERESIS"},
+	{"i!", 0x00ec, "LATIN SMALL LETTER I WITH GRAVE"},
+	{"i'", 0x00ed, "LATIN SMALL LETTER I WITH ACUTE"},
+	{"i>", 0x00ee, "LATIN SMALL LETTER I WITH CIRCUMFLEX"},
+	{"i?", 0x0129, "LATIN SMALL LETTER I WITH TILDE"},
+	{"i:", 0x00ef, "LATIN SMALL LETTER I WITH DIAERESIS"},
+	{"o!", 0x00f2, "LATIN SMALL LETTER O WITH GRAVE"},
+	{"o'", 0x00f3, "LATIN SMALL LETTER O WITH ACUTE"},
+	{"o>", 0x00f4, "LATIN SMALL LETTER O WITH CIRCUMFLEX"},
+	{"o?", 0x00f5, "LATIN SMALL LETTER O WITH TILDE"},
+	{"o:", 0x00f6, "LATIN SMALL LETTER O WITH DIAERESIS"},
+	{"u!", 0x00f9, "LATIN SMALL LETTER U WITH GRAVE"},
+	{"u'", 0x00fa, "LATIN SMALL LETTER U WITH ACUTE"},
+	{"u>", 0x00fb, "LATIN SMALL LETTER U WITH CIRCUMFLEX"},
+	{"u?", 0x0169, "LATIN SMALL LETTER U WITH TILDE"},
+	{"u:", 0x00fc, "LATIN SMALL LETTER U WITH DIAERESIS"},
+	{"A!", 0x00c0, "LATIN CAPITAL LETTER A WITH GRAVE"},
+	{"A'", 0x00c1, "LATIN CAPITAL LETTER A WITH ACUTE"},
+	{"A>", 0x00c2, "LATIN CAPITAL LETTER A WITH CIRCUMFLEX"},
+	{"A?", 0x00c3, "LATIN CAPITAL LETTER A WITH TILDE"},
+	{"A:", 0x00c4, "LATIN CAPITAL LETTER A WITH DIAERESIS"},
+	{"E!", 0x00c8, "LATIN CAPITAL LETTER E WITH GRAVE"},
+	{"E'", 0x00c9, "LATIN CAPITAL LETTER E WITH ACUTE"},
+	{"E>", 0x00ca, "LATIN CAPITAL LETTER E WITH CIRCUMFLEX"},
+	{"E?", 0x1ebc, "LATIN CAPITAL LETTER E WITH TILDE"},
+	{"E:", 0x00cb, "LATIN CAPITAL LETTER E WITH DIAERESIS"},
+	{"I!", 0x00cc, "LATIN CAPITAL LETTER I WITH GRAVE"},
+	{"I'", 0x00cd, "LATIN CAPITAL LETTER I WITH ACUTE"},
+	{"I>", 0x00ce, "LATIN CAPITAL LETTER I WITH CIRCUMFLEX"},
+	{"I?", 0x0128, "LATIN CAPITAL LETTER I WITH TILDE"},
+	{"I:", 0x00cf, "LATIN CAPITAL LETTER I WITH DIAERESIS"},
+	{"O!", 0x00d2, "LATIN CAPITAL LETTER O WITH GRAVE"},
+	{"O'", 0x00d3, "LATIN CAPITAL LETTER O WITH ACUTE"},
+	{"O>", 0x00d4, "LATIN CAPITAL LETTER O WITH CIRCUMFLEX"},
+	{"O?", 0x00d5, "LATIN CAPITAL LETTER O WITH TILDE"},
+	{"O:", 0x00d6, "LATIN CAPITAL LETTER O WITH DIAERESIS"},
+	{"U!", 0x00d9, "LATIN CAPITAL LETTER U WITH GRAVE"},
+	{"U'", 0x00da, "LATIN CAPITAL LETTER U WITH ACUTE"},
+	{"U>", 0x00db, "LATIN CAPITAL LETTER U WITH CIRCUMFLEX"},
+	{"U?", 0x0168, "LATIN CAPITAL LETTER U WITH TILDE"},
+	{"U:", 0x00dc, "LATIN CAPITAL LETTER U WITH DIAERESIS"},
+	{"n?", 0x00f1, "LATIN SMALL LETTER N WITH TILDE"},
+	{"N?", 0x00d1, "LATIN CAPITAL LETTER N WITH TILDE"},
+	{"y'", 0x00fd, "LATIN SMALL LETTER Y WITH ACUTE"},
+	{"Y'", 0x00dd, "LATIN CAPITAL LETTER Y WITH ACUTE"},
+	{"y:", 0x00ff, "LATIN SMALL LETTER Y WITH DIAERESIS"},
+	{"c,", 0x00e7, "LATIN SMALL LETTER C WITH CEDILLA"},
+	{"C,", 0x00c7, "LATIN CAPITAL LETTER C WITH CEDILLA"},
+	{"aa", 0x00e5, "LATIN SMALL LETTER A WITH RING ABOVE"},
+	{"AA", 0x00c5, "LATIN CAPITAL LETTER A WITH RING ABOVE"},
+	{"ae", 0x00e6, "LATIN SMALL LETTER AE"},
+	{"AE", 0x00c6, "LATIN CAPITAL LETTER AE"},
+	{"o/", 0x00f8, "LATIN SMALL LETTER O WITH STROKE"},
+	{"O/", 0x00d8, "LATIN CAPITAL LETTER O WITH STROKE"},
+	{"ss", 0x00df, "LATIN SMALL LETTER SHARP S"},
+	{"oe", 0x0153, "LATIN SMALL LIGATURE OE"},
+	{"OE", 0x0152, "LATIN CAPITAL LIGATURE OE"},
+	{"d/", 0x0111, "LATIN SMALL LETTER D WITH STROKE"},
+	{"D/", 0x0110, "LATIN CAPITAL LETTER D WITH STROKE"},
+	{"s<", 0x0161, "LATIN SMALL LETTER S WITH CARON"},
+	{"S<", 0x0160, "LATIN CAPITAL LETTER S WITH CARON"},
+	{"z<", 0x017e, "LATIN SMALL LETTER Z WITH CARON"},
+	{"Z<", 0x017d, "LATIN CAPITAL LETTER Z WITH CARON"},
+	{"c<", 0x010d, "LATIN SMALL LETTER C WITH CARON"},
+	{"C<", 0x010c, "LATIN CAPITAL LETTER C WITH CARON"},
+	{"l/", 0x0142, "LATIN SMALL LETTER L WITH STROKE"},
+	{"L/", 0x0141, "LATIN CAPITAL LETTER L WITH STROKE"},
+	{"th", 0x00fe, "LATIN SMALL LETTER THORN"},
+	{"TH", 0x00de, "LATIN CAPITAL LETTER THORN"},
+	{"dh", 0x00f0, "LATIN SMALL LETTER ETH"},
+	{"DH", 0x00d0, "LATIN CAPITAL LETTER ETH"},
+	{"a*", 0x03b1, "GREEK SMALL LETTER ALPHA"},
+	{"A*", 0x0391, "GREEK CAPITAL LETTER ALPHA"},
+	{"b*", 0x03b2, "GREEK SMALL LETTER BETA"},
+	{"B*", 0x0392, "GREEK CAPITAL LETTER BETA"},
+	{"g*", 0x03b3, "GREEK SMALL LETTER GAMMA"},
+	{"G*", 0x0393, "GREEK CAPITAL LETTER GAMMA"},
+	{"d*", 0x03b4, "GREEK SMALL LETTER DELTA"},
+	{"D*", 0x0394, "GREEK CAPITAL LETTER DELTA"},
+	{"e*", 0x03b5, "GREEK SMALL LETTER EPSILON"},
+	{"E*", 0x0395, "GREEK CAPITAL LETTER EPSILON"},
+	{"z*", 0x03b6, "GREEK SMALL LETTER ZETA"},
+	{"Z*", 0x0396, "GREEK CAPITAL LETTER ZETA"},
+	{"y*", 0x03b7, "GREEK SMALL LETTER ETA"},
+	{"Y*", 0x0397, "GREEK CAPITAL LETTER ETA"},
+	{"h*", 0x03b8, "GREEK SMALL LETTER THETA"},
+	{"H*", 0x0398, "GREEK CAPITAL LETTER THETA"},
+	{"i*", 0x03b9, "GREEK SMALL LETTER IOTA"},
+	{"I*", 0x0399, "GREEK CAPITAL LETTER IOTA"},
+	{"k*", 0x03ba, "GREEK SMALL LETTER KAPPA"},
+	{"K*", 0x039a, "GREEK CAPITAL LETTER KAPPA"},
+	{"l*", 0x03bb, "GREEK SMALL LETTER LAMDA"},
+	{"L*", 0x039b, "GREEK CAPITAL LETTER LAMDA"},
+	{"m*", 0x03bc, "GREEK SMALL LETTER MU"},
+	{"M*", 0x039c, "GREEK CAPITAL LETTER MU"},
+	{"n*", 0x03bd, "GREEK SMALL LETTER NU"},
+	{"N*", 0x039d, "GREEK CAPITAL LETTER NU"},
+	{"c*", 0x03be, "GREEK SMALL LETTER XI"},
+	{"C*", 0x039e, "GREEK CAPITAL LETTER XI"},
+	{"o*", 0x03bf, "GREEK SMALL LETTER OMICRON"},
+	{"O*", 0x039f, "GREEK CAPITAL LETTER OMICRON"},
+	{"p*", 0x03c0, "GREEK SMALL LETTER PI"},
+	{"P*", 0x03a0, "GREEK CAPITAL LETTER PI"},
+	{"r*", 0x03c1, "GREEK SMALL LETTER RHO"},
+	{"R*", 0x03a1, "GREEK CAPITAL LETTER RHO"},
+	{"s*", 0x03c3, "GREEK SMALL LETTER SIGMA"},
+	{"S*", 0x03a3, "GREEK CAPITAL LETTER SIGMA"},
+	{"*s", 0x03c2, "GREEK SMALL LETTER FINAL SIGMA"},
+	{"t*", 0x03c4, "GREEK SMALL LETTER TAU"},
+	{"T*", 0x03a4, "GREEK CAPITAL LETTER TAU"},
+	{"u*", 0x03c5, "GREEK SMALL LETTER UPSILON"},
+	{"U*", 0x03a5, "GREEK CAPITAL LETTER UPSILON"},
+	{"f*", 0x03c6, "GREEK SMALL LETTER PHI"},
+	{"F*", 0x03a6, "GREEK CAPITAL LETTER PHI"},
+	{"x*", 0x03c7, "GREEK SMALL LETTER CHI"},
+	{"X*", 0x03a7, "GREEK CAPITAL LETTER CHI"},
+	{"q*", 0x03c8, "GREEK SMALL LETTER PSI"},
+	{"Q*", 0x03a8, "GREEK CAPITAL LETTER PSI"},
+	{"w*", 0x03c9, "GREEK SMALL LETTER OMEGA"},
+	{"W*", 0x03a9, "GREEK CAPITAL LETTER OMEGA"},
+	{"NS", 0x00a0, "NO-BREAK SPACE"},
+	{"!I", 0x00a1, "INVERTED EXCLAMATION MARK"},
+	{"Ct", 0x00a2, "CENT SIGN"},
+	{"Pd", 0x00a3, "POUND SIGN"},
+	{"Eu", 0x20ac, "EURO SIGN"},
+	{"Ye", 0x00a5, "YEN SIGN"},
+	{"SE", 0x00a7, "SECTION SIGN"},
+	{"Co", 0x00a9, "COPYRIGHT SIGN"},
+	{"Rg", 0x00ae, "REGISTERED SIGN"},
+	{"TM", 0x2122, "TRADE MARK SIGN"},
+	{"<<", 0x00ab, "LEFT-POINTING DOUBLE ANGLE QUOTATION MARK"},
+	{">>", 0x00bb, "RIGHT-POINTING DOUBLE ANGLE QUOTATION MARK"},
+	{"NO", 0x00ac, "NOT SIGN"},
+	{"DG", 0x00b0, "DEGREE SIGN"},
+	{"+-", 0x00b1, "PLUS-MINUS SIGN"},
+	{"1S", 0x00b9, "SUPERSCRIPT ONE"},
+	{"2S", 0x00b2, "SUPERSCRIPT TWO"},
+	{"3S", 0x00b3, "SUPERSCRIPT THREE"},
+	{"My", 0x00b5, "MICRO SIGN"},
+	{"PI", 0x00b6, "PILCROW SIGN"},
+	{".M", 0x00b7, "MIDDLE DOT"},
+	{"14", 0x00bc, "VULGAR FRACTION ONE QUARTER"},
+	{"12", 0x00bd, "VULGAR FRACTION ONE HALF"},
+	{"34", 0x00be, "VULGAR FRACTION THREE QUARTERS"},
+	{"?I", 0x00bf, "INVERTED QUESTION MARK"},
+	{"*X", 0x00d7, "MULTIPLICATION SIGN"},
+	{"-:", 0x00f7, "DIVISION SIGN"},
+	{"-N", 0x2013, "EN DASH"},
+	{"-M", 0x2014, "EM DASH"},
+	{"'6", 0x2018, "LEFT SINGLE QUOTATION MARK"},
+	{"'9", 0x2019, "RIGHT SINGLE QUOTATION MARK"},
+	{"\"6", 0x201c, "LEFT DOUBLE QUOTATION MARK"},
+	{"\"9", 0x201d, "RIGHT DOUBLE QUOTATION MARK"},
+	{",.", 0x2026, "HORIZONTAL ELLIPSIS"},
+	{"/-", 0x2020, "DAGGER"},
+	{"/=", 0x2021, "DOUBLE DAGGER"},
+	{"oo", 0x2022, "BULLET"},
+	{"%0", 0x2030, "PER MILLE SIGN"},
+	{"<-", 0x2190, "LEFTWARDS ARROW"},
+	{"->", 0x2192, "RIGHTWARDS ARROW"},
+	{"-!", 0x2191, "UPWARDS ARROW"},
+	{"-v", 0x2193, "DOWNWARDS ARROW"},
+	{"<>", 0x2194, "LEFT RIGHT ARROW"},
+	{"=>", 0x21d2, "RIGHTWARDS DOUBLE ARROW"},
+	{"==", 0x21d4, "LEFT RIGHT DOUBLE ARROW"},
+	{"FA", 0x2200, "FOR ALL"},
+	{"dP", 0x2202, "PARTIAL DIFFERENTIAL"},
+	{"TE", 0x2203, "THERE EXISTS"},
+	{"/0", 0x2205, "EMPTY SET"},
+	{"DE", 0x2206, "INCREMENT"},
+	{"NB", 0x2207, "NABLA"},
+	{"(-", 0x2208, "ELEMENT OF"},
+	{"*P", 0x220f, "N-ARY PRODUCT"},
+	{"+Z", 0x2211, "N-ARY SUMMATION"},
+	{"RT", 0x221a, "SQUARE ROOT"},
+	{"00", 0x221e, "INFINITY"},
+	{"AN", 0x2227, "LOGICAL AND"},
+	{"OR", 0x2228, "LOGICAL OR"},
+	{"(U", 0x2229, "INTERSECTION"},
+	{")U", 0x222a, "UNION"},
+	{"In", 0x222b, "INTEGRAL"},
+	{"!=", 0x2260, "NOT EQUAL TO"},
+	{"=<", 0x2264, "LESS-THAN OR EQUAL TO"},
+	{">=", 0x2265, "GREATER-THAN OR EQUAL TO"},
+	{"?2", 0x2248, "ALMOST EQUAL TO"},
+	{"=3", 0x2261, "IDENTICAL TO"},
+	{"(C", 0x2282, "SUBSET OF"},
+	{")C", 0x2283, "SUPERSET OF"},
+	{"OK", 0x2713, "CHECK MARK"},
+	{"XX", 0x2717, "BALLOT X"},
+	{"0u", 0x263a, "WHITE SMILING FACE"},
+	{"cH", 0x2665, "BLACK HEART SUIT"},
+}
+
+// The character a digraph stands for; the keys may come in either order.
+find_digraph :: proc(keys: string) -> (rune, bool) {
+	if len(keys) != 2 {
+		return 0, false
+	}
+	swapped := [2]u8{keys[1], keys[0]}
+	for d in DIGRAPHS {
+		if d.keys == keys {
+			return d.r, true
+		}
+	}
+	for d in DIGRAPHS {
+		if d.keys == string(swapped[:]) {
+			return d.r, true
+		}
+	}
+	return 0, false
+}
+
+// The digraph for r, or "" when it has none.
+digraph_of :: proc(r: rune) -> string {
+	for d in DIGRAPHS {
+		if d.r == r {
+			return d.keys
+		}
+	}
+	return ""
+}
@@ -0,0 +1,466 @@
+package editor
+
+import "core:mem"
+import "core:strings"
+import "core:unicode/utf8"
+
+// Documentation for a name in a pane down the right of the editor: where it
+// is defined, its definition as code and the comment that goes with it, the
+// comment wrapped to the pane's width.  The location and any web addresses
+// are links, picked in turn and followed by the caller.
+Docs_View_Data :: struct {
+	font:          ^Font_Handle,
+	visible:       bool,
+	pinned:        bool, // keeps its documentation as the cursor moves
+	focused:       bool, // has the keyboard, so the selected link shows
+	line_height:   f32,
+	bottom_margin: f32, // space reserved below the pane (status line)
+	width_cols:    int, // wanted width in cells; at most 3/5 of the window
+	title:         string,
+	location:      string, // "path:line" of the definition, "" for none
+	code:          string,
+	body:          string,
+	rows:          [dynamic]Docs_Row,
+	links:         [dynamic]Docs_Link,
+	link:          int, // selected, -1 for none
+	scroll:        int, // first row shown
+	page_rows:     int, // rows that fit, as of the last draw
+	wrap_cols:     int, // cells the rows were wrapped to
+	left:          f32, // x of the pane's left edge, as of the last draw
+	fg_color:      [4]f32,
+	dim_color:     [4]f32,
+	bg_color:      [4]f32,
+	title_color:   [4]f32,
+	code_color:    [4]f32,
+	link_color:    [4]f32,
+	select_color:  [4]f32,
+	allocator:     mem.Allocator,
+}
+
+// Lines of a definition shown at most, as its brackets run on.
+DOCS_CODE_LINES :: 8
+
+Docs_Row_Kind :: enum u8 {
+	Location,
+	Code,
+	Text,
+}
+
+// A row of the pane: a slice of location, code or body.
+Docs_Row :: struct {
+	text: string,
+	kind: Docs_Row_Kind,
+}
+
+// The bytes start..end of a row's text; the location row is a link as a
+// whole.
+Docs_Link :: struct {
+	row:   int,
+	start: int,
+	end:   int,
+}
+
+make_docs_view_layer :: proc(
+	font: ^Font_Handle,
+	line_height: f32,
+	bottom_margin: f32,
+	allocator: mem.Allocator = context.allocator,
+) -> Layer {
+	data := new(Docs_View_Data, allocator)
+	data.font = font
+	data.line_height = line_height
+	data.bottom_margin = bottom_margin
+	data.width_cols = 60
+	data.rows = make([dynamic]Docs_Row, allocator)
+	data.links = make([dynamic]Docs_Link, allocator)
+	data.link = -1
+	data.page_rows = 1
+	data.fg_color = {0.85, 0.85, 0.82, 1.0}
+	data.dim_color = {0.50, 0.50, 0.55, 1.0}
+	data.bg_color = {0.13, 0.13, 0.16, 1.0}
+	data.title_color = {0.18, 0.18, 0.22, 1.0}
+	data.code_color = {0.70, 0.80, 0.95, 1.0}
+	data.link_color = {0.45, 0.70, 0.95, 1.0}
+	data.select_color = {0.25, 0.35, 0.55, 0.6}
+	data.allocator = allocator
+
+	return Layer {
+		kind = .Overlay,
+		z_index = 120,
+		enabled = true,
+		name = "docs_view",
+		user_data = data,
+		draw = proc(
+			layer: ^Layer,
+			br: ^Batch_Renderer,
+			atlas: ^Glyph_Atlas,
+			lctx: ^Layer_Context,
+		) {
+			d := cast(^Docs_View_Data)layer.user_data
+			if !d.visible {
+				return
+			}
+			cw := get_glyph(atlas, d.font, ' ').advance_x
+			lh := d.line_height
+			w := min(f32(d.width_cols) * cw + 16, lctx.viewport[0] * 3 / 5)
+			h := lctx.viewport[1] - d.bottom_margin
+			x0 := lctx.viewport[0] - w
+			d.left = x0
+			if cols := max(int((w - 16) / cw), 10); cols != d.wrap_cols {
+				layout_docs(d, cols)
+			}
+			push_rect(br, x0, 0, w, h, d.bg_color)
+			push_rect(br, x0, 0, 1, h, d.dim_color)
+			push_rect(br, x0, 0, w, lh, d.title_color)
+			push_text(br, atlas, d.font, x0 + 8, 0, d.title, d.fg_color)
+			if d.pinned {
+				status_x := x0 + w - 8 - measure_text(atlas, d.font, "pinned")
+				push_text(br, atlas, d.font, status_x, 0, "pinned", d.dim_color)
+			}
+
+			d.page_rows = max(int((h - lh) / lh), 1)
+			d.scroll = clamp(d.scroll, 0, max(len(d.rows) - d.page_rows, 0))
+			y := lh
+			for k in d.scroll ..< min(d.scroll + d.page_rows, len(d.rows)) {
+				draw_docs_row(d, br, atlas, k, x0 + 8, y)
+				y += lh
+			}
+		},
+		on_destroy = proc(layer: ^Layer) {
+			d := cast(^Docs_View_Data)layer.user_data
+			clear_docs_view(d)
+			delete(d.rows)
+			delete(d.links)
+		},
+	}
+}
+
+// Shows documentation; location, code and body may each be "".
+set_docs_view :: proc(d: ^Docs_View_Data, title, location, code, body: string) {
+	pinned := d.pinned
+	clear_docs_view(d)
+	d.title = strings.clone(title, d.allocator)
+	d.location = strings.clone(location, d.allocator)
+	d.code = strings.clone(strings.trim_right(code, "\n"), d.allocator)
+	d.body = strings.clone(strings.trim_right(body, "\n"), d.allocator)
+	layout_docs(d, d.wrap_cols if d.wrap_cols > 0 else d.width_cols)
+	d.pinned = pinned
+	d.visible = true
+}
+
+clear_docs_view :: proc(d: ^Docs_View_Data) {
+	delete(d.title, d.allocator)
+	delete(d.location, d.allocator)
+	delete(d.code, d.allocator)
+	delete(d.body, d.allocator)
+	d.title, d.location, d.code, d.body = "", "", "", ""
+	clear(&d.rows)
+	clear(&d.links)
+	d.link = -1
+	d.scroll = 0
+	d.visible = false
+	d.pinned = false
+	d.focused = false
+}
+
+docs_view_scroll :: proc(d: ^Docs_View_Data, delta: int) {
+	d.scroll = clamp(d.scroll + delta, 0, max(len(d.rows) - d.page_rows, 0))
+}
+
+// Selects the next link, or the previous one, wrapping around, and scrolls
+// to it.  False when there are none.
+docs_view_next_link :: proc(d: ^Docs_View_Data, backwards: bool) -> bool {
+	n := len(d.links)
+	if n == 0 {
+		return false
+	}
+	switch {
+	case d.link < 0:
+		d.link = n - 1 if backwards else 0
+	case backwards:
+		d.link = (d.link + n - 1) % n
+	case:
+		d.link = (d.link + 1) % n
+	}
+	row := d.links[d.link].row
+	if row < d.scroll {
+		d.scroll = row
+	} else if row >= d.scroll + d.page_rows {
+		d.scroll = row - d.page_rows + 1
+	}
+	return true
+}
+
+// The selected link's target: the definition's "path:line", or an address.
+docs_view_link :: proc(d: ^Docs_View_Data) -> (target: string, location: bool, ok: bool) {
+	if d.link < 0 || d.link >= len(d.links) {
+		return "", false, false
+	}
+	l := d.links[d.link]
+	row := d.rows[l.row]
+	return row.text[l.start:l.end], row.kind == .Location, true
+}
+
+// The definition on line of text and the comment documenting it: the line
+// comments or the block comment right above it, past any attributes, or
+// else a docstring right below it, as in Python.  lang may be nil.  Temp
+// allocated.
+definition_docs :: proc(text: string, line: int, lang: ^Language) -> (code, comment: string) {
+	lines := strings.split_lines(text, context.temp_allocator)
+	if line < 0 || line >= len(lines) {
+		return "", ""
+	}
+	end, depth := line, 0
+	for end < len(lines) && end - line < DOCS_CODE_LINES {
+		for c in lines[end] {
+			switch c {
+			case '(', '[':
+				depth += 1
+			case ')', ']':
+				depth -= 1
+			}
+		}
+		end += 1
+		if depth <= 0 {
+			break
+		}
+	}
+	indent := lines[line][:len(lines[line]) - len(strings.trim_left(lines[line], " \t"))]
+	b := strings.builder_make(context.temp_allocator)
+	for l, i in lines[line:end] {
+		if i > 0 {
+			strings.write_byte(&b, '\n')
+		}
+		strings.write_string(&b, strings.trim_prefix(strings.trim_right_space(l), indent))
+	}
+	code = strings.to_string(b)
+
+	above := line
+	for above > 0 && is_attribute_line(lines[above - 1]) {
+		above -= 1
+	}
+	line_comment, block_comment := "", [2]string{}
+	if lang != nil {
+		line_comment, block_comment = lang.line_comment, lang.block_comment
+	}
+	comment = line_comments_above(lines, above, line_comment)
+	if comment == "" {
+		comment = block_comment_above(lines, above, block_comment)
+	}
+	if comment == "" && end < len(lines) {
+		comment = docstring_at(lines, end)
+	}
+	return code, comment
+}
+
+@(private = "file")
+is_attribute_line :: proc(line: string) -> bool {
+	t := strings.trim_left(line, " \t")
+	return strings.has_prefix(t, "@") || strings.has_prefix(t, "#[")
+}
+
+@(private = "file")
+line_comments_above :: proc(lines: []string, line: int, marker: string) -> string {
+	if marker == "" {
+		return ""
+	}
+	first := line
+	for first > 0 && strings.has_prefix(strings.trim_left(lines[first - 1], " \t"), marker) {
+		first -= 1
+	}
+	b := strings.builder_make(context.temp_allocator)
+	for l in lines[first:line] {
+		t := strings.trim_prefix(strings.trim_left(l, " \t"), marker)
+		// Doc comments double the marker's last character: ///, //!, ##, --.
+		t = strings.trim_left(t, marker[len(marker) - 1:])
+		t = strings.trim_left(t, "!")
+		strings.write_string(&b, strings.trim_prefix(strings.trim_right_space(t), " "))
+		strings.write_byte(&b, '\n')
+	}
+	return strings.trim(strings.to_string(b), "\n")
+}
+
+@(private = "file")
+block_comment_above :: proc(lines: []string, line: int, block: [2]string) -> string {
+	if block[0] == "" || line == 0 {
+		return ""
+	}
+	if !strings.has_suffix(strings.trim_space(lines[line - 1]), block[1]) {
+		return ""
+	}
+	first := line - 1
+	for first > 0 && !strings.contains(lines[first], block[0]) {
+		first -= 1
+	}
+	if !strings.contains(lines[first], block[0]) {
+		return ""
+	}
+	b := strings.builder_make(context.temp_allocator)
+	for l in lines[first:line] {
+		t := strings.trim_space(l)
+		if open := strings.index(t, block[0]); open >= 0 {
+			t = strings.trim_left(t[open + len(block[0]):], "*!")
+		}
+		t = strings.trim_suffix(t, block[1])
+		t = strings.trim_prefix(t, "*")
+		strings.write_string(&b, strings.trim_space(t))
+		strings.write_byte(&b, '\n')
+	}
+	return strings.trim(strings.to_string(b), "\n")
+}
+
+@(private = "file")
+docstring_at :: proc(lines: []string, line: int) -> string {
+	t := strings.trim_space(lines[line])
+	quote := ""
+	if strings.has_prefix(t, `"""`) {
+		quote = `"""`
+	} else if strings.has_prefix(t, "'''") {
+		quote = "'''"
+	}
+	if quote == "" {
+		return ""
+	}
+	b := strings.builder_make(context.temp_allocator)
+	t = t[len(quote):]
+	for i := line; i < len(lines); i += 1 {
+		if i > line {
+			t = strings.trim_space(lines[i])
+		}
+		if close := strings.index(t, quote); close >= 0 {
+			strings.write_string(&b, t[:close])
+			break
+		}
+		strings.write_string(&b, t)
+		strings.write_byte(&b, '\n')
+	}
+	return strings.trim(strings.to_string(b), "\n")
+}
+
+// Splits the documentation into rows of at most cols cells: the body is
+// wrapped at spaces, the code cut off, and the links found again.
+@(private = "file")
+layout_docs :: proc(d: ^Docs_View_Data, cols: int) {
+	clear(&d.rows)
+	clear(&d.links)
+	d.link = -1
+	d.wrap_cols = cols
+	if d.location != "" {
+		append(&d.rows, Docs_Row{d.location, .Location})
+		append(&d.links, Docs_Link{0, 0, len(d.location)})
+	}
+	if d.code != "" {
+		if len(d.rows) > 0 {
+			append(&d.rows, Docs_Row{"", .Text})
+		}
+		for line in strings.split_lines(d.code, context.temp_allocator) {
+			append(&d.rows, Docs_Row{cut_to_cells(line, cols), .Code})
+		}
+	}
+	if d.body == "" {
+		return
+	}
+	if len(d.rows) > 0 {
+		append(&d.rows, Docs_Row{"", .Text})
+	}
+	rest := d.body
+	for line in strings.split_lines_iterator(&rest) {
+		line_rest := line
+		for {
+			row := wrap_docs_line(line_rest, cols)
+			add_docs_links(d, len(d.rows), row)
+			append(&d.rows, Docs_Row{row, .Text})
+			line_rest = strings.trim_left(line_rest[len(row):], " ")
+			if line_rest == "" {
+				break
+			}
+		}
+	}
+}
+
+// As much of line as fits in cols cells, broken after a space when one is
+// in reach.
+@(private = "file")
+wrap_docs_line :: proc(line: string, cols: int) -> string {
+	head := cut_to_cells(line, cols)
+	if len(head) == len(line) {
+		return line
+	}
+	if space := strings.last_index_byte(head, ' '); space > 0 {
+		return head[:space]
+	}
+	return head
+}
+
+@(private = "file")
+cut_to_cells :: proc(s: string, cols: int) -> string {
+	n := 0
+	for _, i in s {
+		if n == cols {
+			return s[:i]
+		}
+		n += 1
+	}
+	return s
+}
+
+// Adds the web addresses in a body row as links.
+@(private = "file")
+add_docs_links :: proc(d: ^Docs_View_Data, row: int, text: string) {
+	for i := 0; i < len(text); {
+		rest := text[i:]
+		if !strings.has_prefix(rest, "https://") && !strings.has_prefix(rest, "http://") {
+			_, size := utf8.decode_rune_in_string(rest)
+			i += size
+			continue
+		}
+		end := i
+		for end < len(text) && !strings.contains_rune(" \t\"'<>()`", rune(text[end])) {
+			end += 1
+		}
+		for end > i && strings.contains_rune(".,;:!?", rune(text[end - 1])) {
+			end -= 1
+		}
+		append(&d.links, Docs_Link{row, i, end})
+		i = max(end, i + 1)
+	}
+}
+
+@(private = "file")
+draw_docs_row :: proc(
+	d: ^Docs_View_Data,
+	br: ^Batch_Renderer,
+	atlas: ^Glyph_Atlas,
+	k: int,
+	x, y: f32,
+) {
+	row := d.rows[k]
+	color := d.fg_color
+	switch row.kind {
+	case .Location:
+		color = d.link_color
+	case .Code:
+		color = d.code_color
+	case .Text:
+	}
+	done := 0
+	x := x
+	for l, i in d.links {
+		if l.row != k {
+			continue
+		}
+		before := row.text[done:l.start]
+		push_text(br, atlas, d.font, x, y, before, color)
+		x += measure_text(atlas, d.font, before)
+		link := row.text[l.start:l.end]
+		lw := measure_text(atlas, d.font, link)
+		if d.focused && i == d.link {
+			push_rect(br, x, y, lw, d.line_height, d.select_color)
+		}
+		push_text(br, atlas, d.font, x, y, link, d.link_color)
+		push_rect(br, x, y + d.line_height - 2, lw, 1, d.link_color)
+		x += lw
+		done = l.end
+	}
+	push_text(br, atlas, d.font, x, y, row.text[done:], color)
+}
@@ -0,0 +1,375 @@
+package editor
+
+import "core:strings"
+import "core:unicode/utf16"
+import "core:unicode/utf8"
+
+// Encodings files are read and written in.  Buffers always hold UTF-8; files
+// are converted when loaded and saved.
+Encoding :: enum {
+	UTF8,
+	UTF8_BOM,
+	UTF16_LE,
+	UTF16_BE,
+	Latin1,
+	Shift_JIS,
+}
+
+ENCODING_NAMES := [Encoding]string {
+	.UTF8      = "UTF-8",
+	.UTF8_BOM  = "UTF-8 BOM",
+	.UTF16_LE  = "UTF-16 LE",
+	.UTF16_BE  = "UTF-16 BE",
+	.Latin1    = "Latin-1",
+	.Shift_JIS = "Shift-JIS",
+}
+
+@(private = "file")
+BOM_UTF8 :: "\xef\xbb\xbf"
+
+// Detection only looks at the start of large files.
+@(private = "file")
+DETECT_SAMPLE :: 64 * 1024
+
+// Guesses the encoding of file contents.  A byte order mark decides.  Without
+// one, NUL bytes in every other position mean UTF-16, then valid UTF-8 is
+// UTF-8 and bytes that parse as Shift-JIS with at least one double-byte
+// character are Shift-JIS.  Anything else is Latin-1, which accepts any byte.
+detect_encoding :: proc(data: []u8) -> Encoding {
+	switch {
+	case strings.has_prefix(string(data), BOM_UTF8):
+		return .UTF8_BOM
+	case len(data) >= 2 && data[0] == 0xff && data[1] == 0xfe:
+		return .UTF16_LE
+	case len(data) >= 2 && data[0] == 0xfe && data[1] == 0xff:
+		return .UTF16_BE
+	}
+	sample := data[:min(len(data), DETECT_SAMPLE)]
+	if enc, ok := detect_utf16(sample); ok {
+		return enc
+	}
+	if utf8.valid_string(string(data)) {
+		return .UTF8
+	}
+	if looks_like_shift_jis(sample) {
+		return .Shift_JIS
+	}
+	return .Latin1
+}
+
+// Reports whether data looks like a binary file rather than text: it has NUL
+// bytes near the start, as git guesses, and is not UTF-16.  Opening a file,
+// the project search and the todo scan all go by it.
+is_binary :: proc(data: []u8) -> bool {
+	sample := data[:min(len(data), DETECT_SAMPLE)]
+	if enc := detect_encoding(sample); enc == .UTF16_LE || enc == .UTF16_BE {
+		return false
+	}
+	for b in sample {
+		if b == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Detects the encoding of data and converts it to UTF-8.  When the guess
+// turns out wrong further into the file, the text is read as Latin-1.
+decode_file :: proc(
+	data: []u8,
+	allocator := context.allocator,
+) -> (
+	text: string,
+	encoding: Encoding,
+) {
+	encoding = detect_encoding(data)
+	ok: bool
+	if text, ok = decode_text(data, encoding, allocator); ok {
+		return
+	}
+	text, _ = decode_text(data, .Latin1, allocator)
+	return text, .Latin1
+}
+
+// Converts data in the given encoding to UTF-8.  Fails on bytes that are not
+// valid in it.
+decode_text :: proc(
+	data: []u8,
+	encoding: Encoding,
+	allocator := context.allocator,
+) -> (
+	text: string,
+	ok: bool,
+) {
+	switch encoding {
+	case .UTF8, .UTF8_BOM:
+		s := strings.trim_prefix(string(data), BOM_UTF8)
+		if !utf8.valid_string(s) {
+			return "", false
+		}
+		return strings.clone(s, allocator), true
+	case .UTF16_LE, .UTF16_BE:
+		return decode_utf16(data, encoding == .UTF16_BE, allocator)
+	case .Latin1:
+		b := strings.builder_make(0, len(data), allocator)
+		for c in data {
+			strings.write_rune(&b, rune(c))
+		}
+		return strings.to_string(b), true
+	case .Shift_JIS:
+		return decode_shift_jis(data, allocator)
+	}
+	return "", false
+}
+
+// Converts UTF-8 text to the given encoding.  Fails when the text has
+// characters the encoding cannot represent.  UTF-16 is written with a byte
+// order mark.
+encode_text :: proc(
+	text: string,
+	encoding: Encoding,
+	allocator := context.allocator,
+) -> (
+	data: []u8,
+	ok: bool,
+) {
+	out := make([dynamic]u8, 0, len(text), allocator)
+	switch encoding {
+	case .UTF8:
+		append(&out, text)
+	case .UTF8_BOM:
+		append(&out, BOM_UTF8)
+		append(&out, text)
+	case .UTF16_LE, .UTF16_BE:
+		be := encoding == .UTF16_BE
+		append_utf16_unit(&out, 0xfeff, be)
+		for r in text {
+			if r < 0x10000 {
+				append_utf16_unit(&out, u16(r), be)
+			} else {
+				r1, r2 := utf16.encode_surrogate_pair(r)
+				append_utf16_unit(&out, u16(r1), be)
+				append_utf16_unit(&out, u16(r2), be)
+			}
+		}
+	case .Latin1:
+		for r in text {
+			if r > 0xff {
+				delete(out)
+				return nil, false
+			}
+			append(&out, u8(r))
+		}
+	case .Shift_JIS:
+		if !encode_shift_jis(&out, text) {
+			delete(out)
+			return nil, false
+		}
+	}
+	return out[:], true
+}
+
+// Parses an encoding name as typed by the user: case, spaces, '-' and '_' are
+// ignored, and common aliases such as "sjis" or "iso-8859-1" are accepted.
+parse_encoding :: proc(name: string) -> (Encoding, bool) {
+	key := strings.to_lower(name, context.temp_allocator)
+	for sep in ([]string{"-", "_", " "}) {
+		key, _ = strings.remove_all(key, sep, context.temp_allocator)
+	}
+	switch key {
+	case "utf8":
+		return .UTF8, true
+	case "utf8bom":
+		return .UTF8_BOM, true
+	case "utf16", "utf16le":
+		return .UTF16_LE, true
+	case "utf16be":
+		return .UTF16_BE, true
+	case "latin1", "iso88591":
+		return .Latin1, true
+	case "shiftjis", "sjis", "cp932":
+		return .Shift_JIS, true
+	}
+	return .UTF8, false
+}
+
+@(private = "file")
+detect_utf16 :: proc(sample: []u8) -> (Encoding, bool) {
+	pairs := len(sample) / 2
+	if pairs < 2 {
+		return .UTF8, false
+	}
+	even, odd := 0, 0
+	for i in 0 ..< pairs {
+		if sample[2 * i] == 0 {
+			even += 1
+		}
+		if sample[2 * i + 1] == 0 {
+			odd += 1
+		}
+	}
+	// ASCII in UTF-16 LE puts the zero high byte second.
+	switch {
+	case odd > pairs / 4 && even * 10 < odd:
+		return .UTF16_LE, true
+	case even > pairs / 4 && odd * 10 < even:
+		return .UTF16_BE, true
+	}
+	return .UTF8, false
+}
+
+@(private = "file")
+decode_utf16 :: proc(data: []u8, be: bool, allocator := context.allocator) -> (string, bool) {
+	if len(data) % 2 != 0 {
+		return "", false
+	}
+	b := strings.builder_make(0, len(data), allocator)
+	pending: rune = -1 // high surrogate waiting for its pair
+	for i := 0; i < len(data); i += 2 {
+		hi, lo := data[i + 1], data[i]
+		if be {
+			hi, lo = lo, hi
+		}
+		r := rune(u16(hi) << 8 | u16(lo))
+		if i == 0 && r == 0xfeff {
+			continue
+		}
+		switch {
+		case pending >= 0:
+			if r < 0xdc00 || r > 0xdfff {
+				strings.builder_destroy(&b)
+				return "", false
+			}
+			strings.write_rune(&b, utf16.decode_surrogate_pair(pending, r))
+			pending = -1
+		case r >= 0xd800 && r <= 0xdbff:
+			pending = r
+		case utf16.is_surrogate(r):
+			strings.builder_destroy(&b)
+			return "", false
+		case:
+			strings.write_rune(&b, r)
+		}
+	}
+	if pending >= 0 {
+		strings.builder_destroy(&b)
+		return "", false
+	}
+	return strings.to_string(b), true
+}
+
+@(private = "file")
+append_utf16_unit :: proc(out: ^[dynamic]u8, u: u16, be: bool) {
+	if be {
+		append(out, u8(u >> 8), u8(u))
+	} else {
+		append(out, u8(u), u8(u >> 8))
+	}
+}
+
+@(private = "file")
+is_sjis_lead :: proc(b: u8) -> bool {
+	return (b >= 0x81 && b <= 0x9f) || (b >= 0xe0 && b <= 0xfc)
+}
+
+// Half-width katakana take a single byte.
+@(private = "file")
+is_sjis_kana :: proc(b: u8) -> bool {
+	return b >= 0xa1 && b <= 0xdf
+}
+
+// Index into SJIS_TABLE of a double-byte code, or -1.
+@(private = "file")
+sjis_table_index :: proc(lead, trail: u8) -> int {
+	if !is_sjis_lead(lead) || trail < 0x40 || trail > 0xfc || trail == 0x7f {
+		return -1
+	}
+	row := int(lead) - 0x81 if lead <= 0x9f else int(lead) - 0xe0 + 31
+	return row * 189 + int(trail) - 0x40
+}
+
+@(private = "file")
+sjis_lookup :: proc(lead, trail: u8) -> rune {
+	i := sjis_table_index(lead, trail)
+	return rune(SJIS_TABLE[i]) if i >= 0 else 0
+}
+
+@(private = "file")
+looks_like_shift_jis :: proc(sample: []u8) -> bool {
+	doubles := 0
+	for i := 0; i < len(sample); {
+		b := sample[i]
+		switch {
+		case b < 0x80 || is_sjis_kana(b):
+			i += 1
+		case is_sjis_lead(b):
+			if i + 1 == len(sample) {
+				return doubles > 0 // cut off by the end of the sample
+			}
+			if sjis_lookup(b, sample[i + 1]) == 0 {
+				return false
+			}
+			doubles += 1
+			i += 2
+		case:
+			return false
+		}
+	}
+	return doubles > 0
+}
+
+@(private = "file")
+decode_shift_jis :: proc(data: []u8, allocator := context.allocator) -> (string, bool) {
+	b := strings.builder_make(0, len(data) * 3 / 2, allocator)
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c < 0x80:
+			strings.write_byte(&b, c)
+			i += 1
+		case is_sjis_kana(c):
+			strings.write_rune(&b, 0xff61 + rune(c - 0xa1))
+			i += 1
+		case i + 1 < len(data) && sjis_lookup(c, data[i + 1]) != 0:
+			strings.write_rune(&b, sjis_lookup(c, data[i + 1]))
+			i += 2
+		case:
+			strings.builder_destroy(&b)
+			return "", false
+		}
+	}
+	return strings.to_string(b), true
+}
+
+@(private = "file")
+encode_shift_jis :: proc(out: ^[dynamic]u8, text: string) -> bool {
+	codes: map[rune]u16 // built on the first character outside ASCII
+	codes.allocator = context.temp_allocator
+	for r in text {
+		switch {
+		case r < 0x80:
+			append(out, u8(r))
+			continue
+		case r >= 0xff61 && r <= 0xff9f:
+			append(out, u8(r - 0xff61 + 0xa1))
+			continue
+		}
+		if len(codes) == 0 {
+			reserve(&codes, len(SJIS_TABLE))
+			for u, i in SJIS_TABLE {
+				// Some characters have two codes; the first is the standard one.
+				if u != 0 && rune(u) not_in codes {
+					lead := i / 189
+					lead = lead + 0x81 if lead < 31 else lead - 31 + 0xe0
+					codes[rune(u)] = u16(lead) << 8 | u16(i % 189 + 0x40)
+				}
+			}
+		}
+		code, ok := codes[r]
+		if !ok {
+			return false
+		}
+		append(out, u8(code >> 8), u8(code))
+	}
+	return true
+}
@@ -15,6 +15,7 @@ Gap_Buffer :: struct {
 	line_starts: [dynamic]int,
 	allocator:   mem.Allocator,
 	lines_dirty: bool,
+	undo:        ^Undo_History, // records edits when set
 }
 
 @(private = "file")
@@ -34,6 +35,11 @@ current_length :: proc(gb: ^Gap_Buffer) -> int {
 	return gb.capacity - gap_size(gb)
 }
 
+// Bytes held by the buffer, gap and line table included.
+gap_buffer_size :: proc(gb: ^Gap_Buffer) -> int {
+	return gb.capacity + cap(gb.line_starts) * size_of(int)
+}
+
 /// Read byte at logical position directly from the buffer.
 /// No Allocation.
 char_at :: #force_inline proc(gb: ^Gap_Buffer, logical_pos: int) -> u8 {
@@ -134,12 +140,27 @@ insert_bytes :: proc(gb: ^Gap_Buffer, data: []u8, allocator: mem.Allocator = con
 		return
 	}
 
+	record_edit(gb.undo, gb.gap_start, "", string(data))
 	ensure_gap_size(gb, len(data), allocator)
 	copy(gb.buffer[gb.gap_start:], data)
 	gb.gap_start += len(data)
 	gb.lines_dirty = true
 }
 
+// Replaces `count` bytes at `pos` with `data`.  Either side may be empty, so
+// this covers plain inserts and deletes too.
+replace_bytes :: proc(
+	gb: ^Gap_Buffer,
+	pos: int,
+	count: int,
+	data: []u8,
+	allocator: mem.Allocator = context.allocator,
+) {
+	delete_bytes_range(gb, pos, count)
+	move_gap(gb, pos)
+	insert_bytes(gb, data, allocator)
+}
+
 insert_line_start :: proc(gb: ^Gap_Buffer, pos: int) {
 	// Find where to insert this line start
 	insert_idx := len(gb.line_starts)
@@ -179,6 +200,10 @@ delete_bytes_range :: proc(gb: ^Gap_Buffer, start: int, count: int) {
 		return
 	}
 
+	if gb.undo != nil {
+		removed := get_text_segment(gb, start, actual_count, context.temp_allocator)
+		record_edit(gb.undo, start, removed, "")
+	}
 	move_gap(gb, start)
 	gb.gap_end += min(actual_count, gb.capacity - gb.gap_end)
 	gb.lines_dirty = true
@@ -379,7 +404,9 @@ line_col_to_logical_pos :: proc(gb: ^Gap_Buffer, target_line: int, target_col: i
 }
 
 // Returns the visual column for a given byte column on a line, expanding tabs
-// to the next tab-stop grid position.  Every non-tab codepoint counts as 1.
+// to the next tab-stop grid position.  Each grapheme cluster takes its display
+// width (see grapheme_width): wide CJK characters and emoji count as 2,
+// combining marks add nothing.
 get_visual_col :: proc(
 	gb: ^Gap_Buffer,
 	line_num: int,
@@ -387,39 +414,31 @@ get_visual_col :: proc(
 	tab_size: int,
 	allocator: mem.Allocator = context.allocator,
 ) -> int {
-	ts := max(tab_size, 1)
 	line_str := get_line(gb, line_num, allocator)
 	defer delete(line_str, allocator)
+	return line_visual_col(line_str, byte_col, tab_size)
+}
 
+// get_visual_col for a line already in hand.
+line_visual_col :: proc(line_str: string, byte_col: int, tab_size: int) -> int {
+	ts := max(tab_size, 1)
 	visual := 0
 	i := 0
 	for i < len(line_str) && i < byte_col {
-		b := line_str[i]
-		if b == '\t' {
+		next := next_grapheme(line_str, i)
+		if line_str[i] == '\t' {
 			visual = (visual / ts + 1) * ts
-			i += 1
 		} else {
-			char_size := 1
-			if b >= 0xC0 {
-				switch {
-				case b < 0xE0:
-					char_size = 2
-				case b < 0xF0:
-					char_size = 3
-				case:
-					char_size = 4
-				}
-			}
-			i += char_size
-			visual += 1
+			visual += grapheme_width(line_str[i:next])
 		}
+		i = next
 	}
 	return visual
 }
 
 // Returns the byte column whose visual position is <= target_visual and is as
-// close to it as possible.  When target_visual falls inside a tab the cursor
-// snaps to the start of that tab (i.e. the tab's byte position is returned).
+// close to it as possible.  When target_visual falls inside a tab or a wide
+// character the cursor snaps to its start.
 visual_col_to_byte_col :: proc(
 	gb: ^Gap_Buffer,
 	line_num: int,
@@ -427,42 +446,63 @@ visual_col_to_byte_col :: proc(
 	tab_size: int,
 	allocator: mem.Allocator = context.allocator,
 ) -> int {
-	ts := max(tab_size, 1)
 	line_str := get_line(gb, line_num, allocator)
 	defer delete(line_str, allocator)
+	return line_byte_col(line_str, target_visual, tab_size)
+}
 
+// visual_col_to_byte_col for a line already in hand.
+line_byte_col :: proc(line_str: string, target_visual: int, tab_size: int) -> int {
+	ts := max(tab_size, 1)
 	visual := 0
 	i := 0
 	for i < len(line_str) {
 		if visual >= target_visual {break}
-		b := line_str[i]
-		if b == '\t' {
-			next_stop := (visual / ts + 1) * ts
-			if next_stop > target_visual {
-				// target falls inside this tab – snap to its start
-				break
-			}
-			visual = next_stop
-			i += 1
+		next := next_grapheme(line_str, i)
+		next_visual: int
+		if line_str[i] == '\t' {
+			next_visual = (visual / ts + 1) * ts
 		} else {
-			char_size := 1
-			if b >= 0xC0 {
-				switch {
-				case b < 0xE0:
-					char_size = 2
-				case b < 0xF0:
-					char_size = 3
-				case:
-					char_size = 4
-				}
-			}
-			visual += 1
-			i += char_size
+			next_visual = visual + grapheme_width(line_str[i:next])
+		}
+		if next_visual > target_visual {
+			// target falls inside this character – snap to its start
+			break
 		}
+		visual = next_visual
+		i = next
 	}
 	return i
 }
 
+// Position after the grapheme cluster at pos.  A line break counts as one
+// character.
+next_grapheme_pos :: proc(gb: ^Gap_Buffer, pos: int) -> int {
+	total := current_length(gb)
+	if pos >= total {
+		return total
+	}
+	line, col := logical_pos_to_line_col(gb, pos)
+	line_str := get_line(gb, line, context.temp_allocator)
+	if col >= len(line_str) {
+		return pos + 1
+	}
+	return pos - col + next_grapheme(line_str, col)
+}
+
+// Position of the grapheme cluster before pos.
+prev_grapheme_pos :: proc(gb: ^Gap_Buffer, pos: int) -> int {
+	if pos <= 0 {
+		return 0
+	}
+	line, col := logical_pos_to_line_col(gb, pos)
+	if col == 0 {
+		return pos - 1
+	}
+	line_str := get_line(gb, line, context.temp_allocator)
+	return pos - col + prev_grapheme(line_str, col)
+}
+
 logical_pos_to_line_col :: proc(gb: ^Gap_Buffer, pos: int) -> (line: int, col: int) {
 	_ensure_lines(gb)
 	clamped := clamp(pos, 0, current_length(gb))
@@ -473,7 +513,7 @@ logical_pos_to_line_col :: proc(gb: ^Gap_Buffer, pos: int) -> (line: int, col: i
 
 gap_buffer_clear :: proc(gb: ^Gap_Buffer) {
 	gb.gap_start = 0
-	gb.gap_end = 0
+	gb.gap_end = gb.capacity
 	clear(&gb.line_starts)
 	append(&gb.line_starts, 0)
 	gb.lines_dirty = true
@@ -0,0 +1,110 @@
+package editor
+
+import "core:fmt"
+import "core:mem"
+import "core:strings"
+
+// A suggestion for what follows the cursor, drawn dim after it as though it
+// were typed: its first line, and how many lines more it has.
+Ghost_Text_Layer_Data :: struct {
+	cursor:    ^Cursor_Layer_Data,
+	font:      ^Font_Handle,
+	color:     [4]f32,
+	text:      string, // the whole suggestion, "" for none
+	allocator: mem.Allocator,
+}
+
+// How much of a suggestion an accept takes.
+Ghost_Accept :: enum u8 {
+	Word, // the next word, with the blanks before it
+	Line, // up to and with the next line break
+	All,
+}
+
+set_ghost_text :: proc(d: ^Ghost_Text_Layer_Data, text: string) {
+	delete(d.text, d.allocator)
+	d.text = strings.clone(text, d.allocator) if text != "" else ""
+}
+
+make_ghost_text_layer :: proc(
+	cursor: ^Cursor_Layer_Data,
+	font: ^Font_Handle,
+	color: [4]f32,
+	allocator: mem.Allocator = context.allocator,
+) -> Layer {
+	data := new(Ghost_Text_Layer_Data, allocator)
+	data.cursor = cursor
+	data.font = font
+	data.color = color
+	data.allocator = allocator
+
+	return Layer {
+		kind = .Decorations,
+		z_index = 6,
+		enabled = true,
+		name = "ghost_text",
+		user_data = data,
+		draw = proc(
+			layer: ^Layer,
+			br: ^Batch_Renderer,
+			atlas: ^Glyph_Atlas,
+			lctx: ^Layer_Context,
+		) {
+			d := cast(^Ghost_Text_Layer_Data)layer.user_data
+			if d.text == "" {
+				return
+			}
+			c := d.cursor
+			first, _, _ := strings.partition(d.text, "\n")
+			shown := strings.expand_tabs(first, lctx.tab_size, context.temp_allocator)
+			if more := strings.count(d.text, "\n"); more > 0 {
+				shown = fmt.tprintf("%s  (+%d lines)", shown, more)
+			}
+			x := c.padding[0] + f32(c.visual_col) * c.char_width - lctx.scroll_x
+			y := c.padding[1] + f32(c.line) * c.line_height - lctx.scroll_y
+			push_text(br, atlas, d.font, x, y, shown, d.color)
+		},
+		on_destroy = proc(layer: ^Layer) {
+			d := cast(^Ghost_Text_Layer_Data)layer.user_data
+			delete(d.text, d.allocator)
+		},
+	}
+}
+
+// The bytes of text an accept of unit takes from its start.
+ghost_accept_length :: proc(text: string, unit: Ghost_Accept) -> int {
+	switch unit {
+	case .All:
+		return len(text)
+	case .Line:
+		if i := strings.index_byte(text, '\n'); i >= 0 {
+			return i + 1
+		}
+		return len(text)
+	case .Word:
+		i := 0
+		for i < len(text) && strings.is_space(rune(text[i])) {
+			i += 1
+		}
+		if i < len(text) && is_ghost_word_byte(text[i]) {
+			for i < len(text) && is_ghost_word_byte(text[i]) {
+				i += 1
+			}
+		} else if i < len(text) {
+			i += 1 // a punctuation mark on its own
+		}
+		return i
+	}
+	return len(text)
+}
+
+// Letters, digits and underscores, counting every byte of a character that
+// is not ASCII as a letter.
+@(private = "file")
+is_ghost_word_byte :: proc(c: u8) -> bool {
+	switch c {
+	case 'a' ..= 'z', 'A' ..= 'Z', '0' ..= '9', '_', 0x80 ..= 0xff:
+		return true
+	}
+	return false
+}
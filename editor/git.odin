@@ -0,0 +1,331 @@
+package editor
+
+import "core:mem"
+import "core:os"
+import "core:path/filepath"
+import "core:strconv"
+import "core:strings"
+import "core:sync"
+import "core:thread"
+
+// Runs git in dir and returns what it printed on stdout.  Fails when git is
+// missing or exits with an error.
+run_git :: proc(
+	dir: string,
+	args: []string,
+	allocator: mem.Allocator = context.allocator,
+) -> (
+	out: string,
+	ok: bool,
+) {
+	errors: string
+	out, errors, ok = exec_git(dir, args, allocator)
+	delete(errors, allocator)
+	if !ok {
+		delete(out, allocator)
+		return "", false
+	}
+	return out, true
+}
+
+// The committed contents of the file at path (absolute) as UTF-8 with LF line
+// breaks, read in encoding like the buffer was.  Fails when the file is not
+// in HEAD or is not in a repository.
+git_head_text :: proc(
+	path: string,
+	encoding: Encoding,
+	allocator: mem.Allocator = context.allocator,
+) -> (
+	text: string,
+	ok: bool,
+) {
+	dir, base := filepath.split(path)
+	spec := strings.concatenate({"HEAD:./", base}, context.temp_allocator)
+	raw := run_git(dir, {"show", spec}, context.temp_allocator) or_return
+	decoded, decoded_ok := decode_text(transmute([]u8)raw, encoding, context.temp_allocator)
+	if !decoded_ok {
+		decoded, _ = decode_file(transmute([]u8)raw, context.temp_allocator)
+	}
+	return normalize_line_endings(decoded, allocator), true
+}
+
+// Diffs a buffer against the committed version of its file on a background
+// thread.  Poll with is_git_diff_finished, then read head, tracked and hunks.
+Git_Diff :: struct {
+	path:      string,
+	text:      string, // snapshot of the buffer
+	encoding:  Encoding,
+	head:      string, // committed text; fetched by the job unless given
+	have_head: bool,
+	tracked:   bool, // result: the file is in HEAD
+	hunks:     []Diff_Hunk, // result, from head to text
+	finished:  bool, // atomic
+	thread:    ^thread.Thread,
+	allocator: mem.Allocator,
+}
+
+// Starts diffing text against HEAD.  Pass the committed text from an earlier
+// run as head to skip asking git again.
+start_git_diff :: proc(
+	path, text: string,
+	encoding: Encoding,
+	head: string = "",
+	have_head := false,
+	allocator: mem.Allocator = context.allocator,
+) -> ^Git_Diff {
+	job := new(Git_Diff, allocator)
+	job.path = strings.clone(path, allocator)
+	job.text = strings.clone(text, allocator)
+	job.encoding = encoding
+	job.have_head = have_head
+	job.tracked = have_head
+	if have_head {
+		job.head = strings.clone(head, allocator)
+	}
+	job.allocator = allocator
+	job.thread = thread.create_and_start_with_poly_data(job, run_git_diff)
+	return job
+}
+
+is_git_diff_finished :: proc(job: ^Git_Diff) -> bool {
+	return sync.atomic_load(&job.finished)
+}
+
+// Waits for the job and frees it.
+destroy_git_diff :: proc(job: ^Git_Diff) {
+	thread.join(job.thread)
+	thread.destroy(job.thread)
+	allocator := job.allocator
+	delete(job.path, allocator)
+	delete(job.text, allocator)
+	delete(job.head, allocator)
+	delete(job.hunks, allocator)
+	free(job, allocator)
+}
+
+@(private = "file")
+run_git_diff :: proc(job: ^Git_Diff) {
+	if !job.have_head {
+		job.head, job.tracked = git_head_text(job.path, job.encoding, job.allocator)
+		job.have_head = true
+	}
+	if job.tracked {
+		old := strings.split(job.head, "\n", context.temp_allocator)
+		new := strings.split(job.text, "\n", context.temp_allocator)
+		job.hunks = diff_lines(old, new, job.allocator)
+	}
+	free_all(context.temp_allocator)
+	sync.atomic_store(&job.finished, true)
+}
+
+// A git command run on a background thread.  Poll with
+// is_git_command_finished, then read ok, output and errors.
+Git_Command :: struct {
+	dir:       string,
+	args:      []string,
+	output:    string, // stdout
+	errors:    string, // stderr
+	ok:        bool,
+	finished:  bool, // atomic
+	thread:    ^thread.Thread,
+	allocator: mem.Allocator,
+}
+
+start_git_command :: proc(
+	dir: string,
+	args: []string,
+	allocator: mem.Allocator = context.allocator,
+) -> ^Git_Command {
+	job := new(Git_Command, allocator)
+	job.dir = strings.clone(dir, allocator)
+	job.args = make([]string, len(args), allocator)
+	for a, i in args {
+		job.args[i] = strings.clone(a, allocator)
+	}
+	job.allocator = allocator
+	job.thread = thread.create_and_start_with_poly_data(job, proc(job: ^Git_Command) {
+		job.output, job.errors, job.ok = exec_git(job.dir, job.args, job.allocator)
+		free_all(context.temp_allocator)
+		sync.atomic_store(&job.finished, true)
+	})
+	return job
+}
+
+is_git_command_finished :: proc(job: ^Git_Command) -> bool {
+	return sync.atomic_load(&job.finished)
+}
+
+// Waits for the command and frees it.
+destroy_git_command :: proc(job: ^Git_Command) {
+	thread.join(job.thread)
+	thread.destroy(job.thread)
+	allocator := job.allocator
+	for a in job.args {
+		delete(a, allocator)
+	}
+	delete(job.args, allocator)
+	delete(job.dir, allocator)
+	delete(job.output, allocator)
+	delete(job.errors, allocator)
+	free(job, allocator)
+}
+
+@(private = "file")
+exec_git :: proc(
+	dir: string,
+	args: []string,
+	allocator: mem.Allocator,
+) -> (
+	out, errors: string,
+	ok: bool,
+) {
+	command := make([dynamic]string, 0, len(args) + 1, context.temp_allocator)
+	append(&command, "git")
+	append(&command, ..args)
+	desc := os.Process_Desc {
+		working_dir = dir,
+		command     = command[:],
+	}
+	state, stdout, stderr, err := os.process_exec(desc, allocator)
+	if err != nil {
+		delete(stdout, allocator)
+		delete(stderr, allocator)
+		return "", "", false
+	}
+	return string(stdout), string(stderr), state.success
+}
+
+// The commit a line was last changed in.
+Blame_Commit :: struct {
+	hash:    string,
+	author:  string,
+	time:    i64, // author time, unix seconds
+	summary: string, // first line of the message
+}
+
+// Blame of a buffer snapshot, computed on a background thread.  Poll with
+// is_git_blame_finished, then read ok, commits and lines.
+Git_Blame :: struct {
+	path:      string,
+	contents:  string, // file holding the snapshot, passed to --contents
+	ok:        bool,
+	commits:   [dynamic]Blame_Commit,
+	lines:     [dynamic]int, // index into commits for every line
+	finished:  bool, // atomic
+	thread:    ^thread.Thread,
+	allocator: mem.Allocator,
+}
+
+// Starts blaming the file at path (absolute) as it reads in data, the
+// unsaved contents.  data is written to the scratch file contents first so
+// that uncommitted lines show up as such and the rest keep their numbers.
+start_git_blame :: proc(
+	path, contents: string,
+	data: []u8,
+	allocator: mem.Allocator = context.allocator,
+) -> ^Git_Blame {
+	job := new(Git_Blame, allocator)
+	job.path = strings.clone(path, allocator)
+	job.contents = strings.clone(contents, allocator)
+	job.commits = make([dynamic]Blame_Commit, allocator)
+	job.lines = make([dynamic]int, allocator)
+	job.allocator = allocator
+	if err := os.write_entire_file(contents, data); err != nil {
+		sync.atomic_store(&job.finished, true)
+		return job
+	}
+	job.thread = thread.create_and_start_with_poly_data(job, run_git_blame)
+	return job
+}
+
+is_git_blame_finished :: proc(job: ^Git_Blame) -> bool {
+	return sync.atomic_load(&job.finished)
+}
+
+// Waits for the blame and frees it.
+destroy_git_blame :: proc(job: ^Git_Blame) {
+	if job.thread != nil {
+		thread.join(job.thread)
+		thread.destroy(job.thread)
+	}
+	allocator := job.allocator
+	for c in job.commits {
+		delete(c.hash, allocator)
+		delete(c.author, allocator)
+		delete(c.summary, allocator)
+	}
+	delete(job.commits)
+	delete(job.lines)
+	delete(job.path, allocator)
+	delete(job.contents, allocator)
+	free(job, allocator)
+}
+
+// Reports whether c stands for lines that are not committed yet.
+is_uncommitted :: proc(c: Blame_Commit) -> bool {
+	return strings.trim_right(c.hash, "0") == ""
+}
+
+@(private = "file")
+run_git_blame :: proc(job: ^Git_Blame) {
+	defer sync.atomic_store(&job.finished, true)
+	defer free_all(context.temp_allocator)
+	dir, base := filepath.split(job.path)
+	out, ok := run_git(
+		dir,
+		{"blame", "--porcelain", "--contents", job.contents, "--", base},
+		context.temp_allocator,
+	)
+	os.remove(job.contents)
+	if ok {
+		parse_blame_porcelain(job, out)
+		job.ok = true
+	}
+}
+
+// Reads `git blame --porcelain`: every line starts with a header naming its
+// commit and line numbers, followed by the commit's details the first time
+// that commit appears, and then the line itself after a tab.
+@(private = "file")
+parse_blame_porcelain :: proc(job: ^Git_Blame, out: string) {
+	index := make(map[string]int, context.temp_allocator)
+	current := -1
+	out := out
+	expect_header := true
+	for line in strings.split_lines_iterator(&out) {
+		if expect_header {
+			fields := strings.fields(line, context.temp_allocator)
+			if len(fields) < 3 {
+				continue
+			}
+			final := strconv.atoi(fields[2]) - 1
+			i, seen := index[fields[0]]
+			if !seen {
+				i = len(job.commits)
+				append(&job.commits, Blame_Commit{hash = strings.clone(fields[0], job.allocator)})
+				index[fields[0]] = i
+			}
+			current = i
+			if final >= len(job.lines) {
+				resize(&job.lines, final + 1)
+			}
+			job.lines[final] = i
+			expect_header = false
+			continue
+		}
+		if strings.has_prefix(line, "\t") {
+			expect_header = true
+			continue
+		}
+		key, _, value := strings.partition(line, " ")
+		c := &job.commits[current]
+		switch key {
+		case "author":
+			c.author = strings.clone(value, job.allocator)
+		case "author-time":
+			c.time = i64(strconv.atoi(value))
+		case "summary":
+			c.summary = strings.clone(value, job.allocator)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package editor
+
+import "core:strings"
+
+// An import of a Go file, as the file writes it.
+Go_Import :: struct {
+	name:       string, // the local name, "" for the package's own
+	path:       string,
+	start, end: int, // bytes of its line, the line break included
+	grouped:    bool, // inside an import ( ... ) block
+}
+
+// The imports of a Go file, read a line at a time from the import
+// declarations after its package clause.  Temp allocated.
+go_imports :: proc(text: string) -> []Go_Import {
+	found := make([dynamic]Go_Import, context.temp_allocator)
+	in_block := false
+	seen_package := false
+	pos := 0
+	for pos < len(text) {
+		end := strings.index_byte(text[pos:], '\n')
+		end = len(text) if end < 0 else pos + end + 1
+		line := strings.trim_space(text[pos:end])
+		start := pos
+		pos = end
+		switch {
+		case line == "" || strings.has_prefix(line, "//"):
+		case in_block:
+			if strings.has_prefix(line, ")") {
+				in_block = false
+			} else if imp, ok := parse_import_spec(line); ok {
+				imp.start, imp.end, imp.grouped = start, end, true
+				append(&found, imp)
+			}
+		case strings.has_prefix(line, "package "):
+			seen_package = true
+		case !seen_package:
+		case strings.has_prefix(line, "import"):
+			spec := strings.trim_space(line[len("import"):])
+			if strings.has_prefix(spec, "(") {
+				in_block = !strings.has_suffix(spec, ")")
+			} else if imp, ok := parse_import_spec(spec); ok {
+				imp.start, imp.end = start, end
+				append(&found, imp)
+			}
+		case:
+			return found[:] // the declarations after the imports
+		}
+	}
+	return found[:]
+}
+
+// The edit that imports path in text, or false when it does already.  It
+// goes into the import block in path order, else after the last import, else
+// after the package clause.  Temp allocated.
+go_add_import :: proc(text, path: string) -> (edit: Text_Edit, ok: bool) {
+	imports := go_imports(text)
+	spec := strings.concatenate({"\"", path, "\""}, context.temp_allocator)
+	last := -1
+	for imp, i in imports {
+		if imp.path == path {
+			return {}, false
+		}
+		if imp.grouped {
+			last = i
+		}
+	}
+	if last >= 0 {
+		at := imports[last].end
+		for imp in imports {
+			if imp.grouped && imp.path > path {
+				at = imp.start
+				break
+			}
+		}
+		return {at, 0, strings.concatenate({"\t", spec, "\n"}, context.temp_allocator)}, true
+	}
+	if len(imports) > 0 {
+		at := imports[len(imports) - 1].end
+		return {at, 0, strings.concatenate({"import ", spec, "\n"}, context.temp_allocator)}, true
+	}
+	pkg := strings.index(text, "package ")
+	if pkg < 0 {
+		return {}, false
+	}
+	at := strings.index_byte(text[pkg:], '\n')
+	at = len(text) if at < 0 else pkg + at + 1
+	return {at, 0, strings.concatenate({"\nimport ", spec, "\n"}, context.temp_allocator)}, true
+}
+
+// The edit that drops the import of path from text, or false when it has
+// none.
+go_remove_import :: proc(text, path: string) -> (edit: Text_Edit, ok: bool) {
+	for imp in go_imports(text) {
+		if imp.path == path {
+			return {imp.start, imp.end - imp.start, ""}, true
+		}
+	}
+	return {}, false
+}
+
+// `"fmt"`, `f "fmt"`, `_ "embed"` or `. "math"`, with a comment after it or
+// not.
+@(private = "file")
+parse_import_spec :: proc(spec: string) -> (imp: Go_Import, ok: bool) {
+	open := strings.index_byte(spec, '"')
+	if open < 0 {
+		return {}, false
+	}
+	close := strings.index_byte(spec[open + 1:], '"')
+	if close < 0 {
+		return {}, false
+	}
+	imp.name = strings.trim_space(spec[:open])
+	imp.path = spec[open + 1:open + 1 + close]
+	return imp, true
+}
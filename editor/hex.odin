@@ -0,0 +1,166 @@
+package editor
+
+import "core:strings"
+
+HEX_BYTES_PER_LINE :: 16
+
+@(private = "file")
+HEX_DIGITS := "0123456789abcdef"
+
+// Columns of a dump line; see format_hex_dump.
+HEX_COLUMN_START :: 10
+HEX_ASCII_START :: 61
+
+// The parts of a dump line.
+Hex_Column :: enum {
+	Offset,
+	Hex,
+	Ascii,
+}
+
+// Formats data as a hex dump, one line per 16 bytes: the offset, the bytes in
+// two groups of eight, and the printable ASCII characters.  Offsets start at
+// base, for rewriting the tail of a dump.
+//
+//     00000000  7f 45 4c 46 02 01 01 00  00 00 00 00 00 00 00 00  |.ELF............|
+format_hex_dump :: proc(data: []u8, base := 0, allocator := context.allocator) -> string {
+	lines := (len(data) + HEX_BYTES_PER_LINE - 1) / HEX_BYTES_PER_LINE
+	b := strings.builder_make(0, lines * 80, allocator)
+	for offset := 0; offset < len(data); offset += HEX_BYTES_PER_LINE {
+		if offset > 0 {
+			strings.write_byte(&b, '\n')
+		}
+		row := data[offset:min(offset + HEX_BYTES_PER_LINE, len(data))]
+		write_hex(&b, u64(base + offset), 8)
+		strings.write_string(&b, "  ")
+		for i in 0 ..< HEX_BYTES_PER_LINE {
+			if i == 8 {
+				strings.write_byte(&b, ' ')
+			}
+			if i < len(row) {
+				write_hex(&b, u64(row[i]), 2)
+				strings.write_byte(&b, ' ')
+			} else {
+				strings.write_string(&b, "   ")
+			}
+		}
+		strings.write_string(&b, " |")
+		for c in row {
+			strings.write_byte(&b, c if c >= 0x20 && c < 0x7f else '.')
+		}
+		strings.write_byte(&b, '|')
+	}
+	return strings.to_string(b)
+}
+
+// Parses the bytes back out of a dump made by format_hex_dump.  Only the hex
+// column is read; the offsets and the ASCII column are ignored.
+parse_hex_dump :: proc(text: string, allocator := context.allocator) -> []u8 {
+	data := make([dynamic]u8, 0, len(text) / 4, allocator)
+	text := text
+	for line in strings.split_lines_iterator(&text) {
+		row, n := parse_hex_row(line)
+		append(&data, ..row[:n])
+	}
+	return data[:]
+}
+
+// The bytes in the hex column of one dump line: row[:n].
+parse_hex_row :: proc(line: string) -> (row: [HEX_BYTES_PER_LINE]u8, n: int) {
+	for i in 0 ..< HEX_BYTES_PER_LINE {
+		col := hex_byte_col(i, .Hex)
+		if col + 2 > len(line) {
+			break
+		}
+		hi, hok := hex_digit_value(line[col])
+		lo, lok := hex_digit_value(line[col + 1])
+		if !hok || !lok {
+			break
+		}
+		row[n] = u8(hi << 4 | lo)
+		n += 1
+	}
+	return
+}
+
+// Parses a search pattern such as "de ad be ef" or "DEADBEEF".
+parse_hex_pattern :: proc(s: string, allocator := context.allocator) -> (pattern: []u8, ok: bool) {
+	digits := make([dynamic]u8, 0, len(s), context.temp_allocator)
+	for i in 0 ..< len(s) {
+		switch s[i] {
+		case ' ', '\t', ',':
+			continue
+		}
+		v := hex_digit_value(s[i]) or_return
+		append(&digits, u8(v))
+	}
+	if len(digits) == 0 || len(digits) % 2 != 0 {
+		return nil, false
+	}
+	pattern = make([]u8, len(digits) / 2, allocator)
+	for &b, i in pattern {
+		b = digits[2 * i] << 4 | digits[2 * i + 1]
+	}
+	return pattern, true
+}
+
+// Where column col of a dump line falls: the part, the byte of the row and,
+// in the hex column, which digit of it (0 for the high one).  The gaps between
+// bytes belong to the next byte.
+hex_locate :: proc(col: int) -> (column: Hex_Column, index: int, nibble: int) {
+	switch {
+	case col < HEX_COLUMN_START:
+		return .Offset, 0, 0
+	case col < HEX_ASCII_START - 2:
+		rel := col - HEX_COLUMN_START
+		if rel >= 3 * 8 {
+			rel = max(rel - 1, 3 * 8) // the gap between the two groups
+		}
+		index = rel / 3
+		switch rel % 3 {
+		case 0:
+			nibble = 0
+		case 1:
+			nibble = 1
+		case:
+			index += 1
+		}
+		if index >= HEX_BYTES_PER_LINE {
+			return .Hex, HEX_BYTES_PER_LINE - 1, 1
+		}
+		return .Hex, index, nibble
+	}
+	return .Ascii, clamp(col - HEX_ASCII_START, 0, HEX_BYTES_PER_LINE - 1), 0
+}
+
+// Column of byte index of a row in the hex column (its high digit) or in the
+// ASCII column.
+hex_byte_col :: proc(index: int, column: Hex_Column) -> int {
+	switch column {
+	case .Hex:
+		return HEX_COLUMN_START + 3 * index + (1 if index >= 8 else 0)
+	case .Ascii:
+		return HEX_ASCII_START + index
+	case .Offset:
+	}
+	return 0
+}
+
+hex_digit_value :: proc(c: u8) -> (int, bool) {
+	switch c {
+	case '0' ..= '9':
+		return int(c - '0'), true
+	case 'a' ..= 'f':
+		return int(c - 'a' + 10), true
+	case 'A' ..= 'F':
+		return int(c - 'A' + 10), true
+	}
+	return 0, false
+}
+
+@(private = "file")
+write_hex :: proc(b: ^strings.Builder, v: u64, digits: int) {
+	for i := digits - 1; i >= 0; i -= 1 {
+		strings.write_byte(b, HEX_DIGITS[(v >> uint(4 * i)) & 0xf])
+	}
+}
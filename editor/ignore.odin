@@ -0,0 +1,184 @@
+package editor
+
+import "core:mem"
+import "core:os"
+import "core:path/filepath"
+import "core:strings"
+
+// One line of a .gitignore file.
+Ignore_Pattern :: struct {
+	base:     string, // directory of the .gitignore, relative to the walk root
+	glob:     string,
+	negate:   bool,
+	dir_only: bool,
+	anchored: bool, // contains a '/', so it matches against the path from base
+}
+
+Ignore_Rules :: struct {
+	patterns:  [dynamic]Ignore_Pattern,
+	allocator: mem.Allocator,
+}
+
+init_ignore_rules :: proc(allocator: mem.Allocator = context.allocator) -> Ignore_Rules {
+	return Ignore_Rules{patterns = make([dynamic]Ignore_Pattern, allocator), allocator = allocator}
+}
+
+destroy_ignore_rules :: proc(rules: ^Ignore_Rules) {
+	for p in rules.patterns {
+		delete(p.base, rules.allocator)
+		delete(p.glob, rules.allocator)
+	}
+	delete(rules.patterns)
+}
+
+// Reads <dir>/.gitignore if present.  `rel_dir` is dir relative to the walk
+// root ("" for the root itself).
+load_gitignore :: proc(rules: ^Ignore_Rules, dir, rel_dir: string) {
+	path := filepath.join({dir, ".gitignore"}, context.temp_allocator)
+	data, err := os.read_entire_file_from_path(path, context.temp_allocator)
+	if err != nil {
+		return
+	}
+
+	for raw in strings.split_lines(string(data), context.temp_allocator) {
+		line := strings.trim_right_space(raw)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		p := Ignore_Pattern{}
+		if line[0] == '!' {
+			p.negate = true
+			line = line[1:]
+		}
+		if strings.has_suffix(line, "/") {
+			p.dir_only = true
+			line = line[:len(line) - 1]
+		}
+		if strings.contains_rune(line, '/') {
+			p.anchored = true
+			line = strings.trim_left(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		p.base = strings.clone(rel_dir, rules.allocator)
+		p.glob = strings.clone(line, rules.allocator)
+		append(&rules.patterns, p)
+	}
+}
+
+// Reports whether `rel_path` (slash separated, relative to the walk root) is
+// ignored.  Later patterns override earlier ones, as in git.
+is_ignored :: proc(rules: ^Ignore_Rules, rel_path: string, is_dir: bool) -> bool {
+	ignored := false
+	name := rel_path[strings.last_index_byte(rel_path, '/') + 1:]
+	for p in rules.patterns {
+		if p.dir_only && !is_dir {
+			continue
+		}
+		sub := rel_path
+		if p.base != "" {
+			under_base :=
+				len(rel_path) > len(p.base) &&
+				strings.has_prefix(rel_path, p.base) &&
+				rel_path[len(p.base)] == '/'
+			if !under_base {
+				continue
+			}
+			sub = rel_path[len(p.base) + 1:]
+		}
+		matched := glob_match(p.glob, sub) if p.anchored else glob_match(p.glob, name)
+		if matched {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+// Shell style matching with gitignore's extensions: '*' and '?' stay within a
+// path segment, '**' crosses segments and [...] matches a byte class.
+glob_match :: proc(pattern, s: string) -> bool {
+	p, i := 0, 0
+	star_p, star_i := -1, 0 // backtrack point for the last '*' or '**'
+	star_any := false
+
+	for i < len(s) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				star_any = p + 1 < len(pattern) && pattern[p + 1] == '*'
+				p += 2 if star_any else 1
+				if star_any && p < len(pattern) && pattern[p] == '/' {
+					// "**/" also matches zero directories.
+					if glob_match(pattern[p + 1:], s[i:]) {
+						return true
+					}
+				}
+				star_p, star_i = p, i
+				continue
+			case '?':
+				if s[i] != '/' {
+					p += 1
+					i += 1
+					continue
+				}
+			case '[':
+				if end, ok := match_class(pattern, p, s[i]); ok {
+					p = end
+					i += 1
+					continue
+				}
+			case:
+				if pattern[p] == s[i] {
+					p += 1
+					i += 1
+					continue
+				}
+			}
+		}
+		// Mismatch: let the last star swallow one more byte.
+		if star_p >= 0 && (star_any || s[star_i] != '/') {
+			star_i += 1
+			i = star_i
+			p = star_p
+			continue
+		}
+		return false
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p += 1
+	}
+	return p == len(pattern)
+}
+
+// Matches byte c against the class starting at pattern[start] == '['.
+// Returns the index after the closing ']'.
+@(private = "file")
+match_class :: proc(pattern: string, start: int, c: u8) -> (end: int, ok: bool) {
+	i := start + 1
+	negate := false
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		negate = true
+		i += 1
+	}
+	matched := false
+	first := true
+	for i < len(pattern) && (first || pattern[i] != ']') {
+		first = false
+		lo := pattern[i]
+		hi := lo
+		if i + 2 < len(pattern) && pattern[i + 1] == '-' && pattern[i + 2] != ']' {
+			hi = pattern[i + 2]
+			i += 2
+		}
+		if c >= lo && c <= hi {
+			matched = true
+		}
+		i += 1
+	}
+	if i >= len(pattern) {
+		return start, false
+	}
+	return i + 1, matched != negate
+}
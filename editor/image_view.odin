@@ -0,0 +1,207 @@
+package editor
+
+import "core:image"
+import "core:image/bmp"
+import "core:image/jpeg"
+import "core:image/netpbm"
+import "core:image/png"
+import "core:image/qoi"
+import "core:image/tga"
+import "core:mem"
+import "core:strings"
+import vk "vendor:vulkan"
+
+// The loaders register themselves with core:image when imported.
+_ :: bmp
+_ :: jpeg
+_ :: netpbm
+_ :: png
+_ :: qoi
+_ :: tga
+
+// Images wider or taller than this are not shown; it is the smallest limit
+// Vulkan devices commonly have for a texture side.
+IMAGE_MAX_SIDE :: 16384
+
+IMAGE_ZOOM_MIN :: 0.05
+IMAGE_ZOOM_MAX :: 32
+
+Image_Info :: struct {
+	width, height: int,
+	channels:      int, // in the file: 1 gray, 2 gray and alpha, 3 RGB, 4 RGBA
+	depth:         int, // bits a channel in the file
+	format:        image.Which_File_Type, // told by the file's first bytes
+}
+
+// Decodes a PNG, JPEG, BMP, QOI, TGA or Netpbm file into straight RGBA, 8 bits
+// a channel.  info.format is set for the files core:image knows of even when
+// it cannot decode them.
+decode_image :: proc(
+	data: []u8,
+	allocator: mem.Allocator = context.allocator,
+) -> (
+	pixels: []u8,
+	info: Image_Info,
+	ok: bool,
+) {
+	info.format = image.which(data)
+	img, err := image.load_from_bytes(data)
+	if err != nil {
+		return nil, info, false
+	}
+	defer image.destroy(img)
+	info.width, info.height = img.width, img.height
+	info.channels, info.depth = img.channels, img.depth
+	if img.width > IMAGE_MAX_SIDE || img.height > IMAGE_MAX_SIDE {
+		return nil, info, false
+	}
+	if img.depth != 8 && img.depth != 16 {
+		return nil, info, false
+	}
+
+	src := img.pixels.buf[:]
+	wide := mem.slice_data_cast([]u16, src) // native endian at 16 bits
+	sample :: proc(src: []u8, wide: []u16, depth, i: int) -> u8 {
+		return u8(wide[i] >> 8) if depth == 16 else src[i]
+	}
+	c := img.channels
+	n := img.width * img.height
+	pixels = make([]u8, n * 4, allocator)
+	for i in 0 ..< n {
+		out := pixels[i * 4:][:4]
+		switch c {
+		case 1, 2:
+			v := sample(src, wide, img.depth, i * c)
+			out[0], out[1], out[2] = v, v, v
+		case:
+			for k in 0 ..< 3 {
+				out[k] = sample(src, wide, img.depth, i * c + k)
+			}
+		}
+		out[3] = 255
+		if c == 2 || c == 4 {
+			out[3] = sample(src, wide, img.depth, i * c + c - 1)
+		}
+	}
+	return pixels, info, true
+}
+
+// An image shown over the text instead of its bytes: centered in the window,
+// shrunk to fit unless zoomed, with a caption line under it.  It scrolls with
+// the buffer, which pans an image zoomed past the window.
+Image_Layer_Data :: struct {
+	texture:       GPU_Image, // the Image pipeline samples it
+	loaded:        bool, // texture holds an image
+	visible:       bool,
+	width:         f32, // of the image, in pixels
+	height:        f32,
+	zoom:          f32, // screen pixels per image pixel; 0 fits the window
+	caption:       strings.Builder,
+	font:          ^Font_Handle,
+	line_height:   f32,
+	bottom_margin: f32, // left to the status line
+	fg_color:      [4]f32,
+	bg_color:      [4]f32,
+	frame_color:   [4]f32, // behind the image, where it is transparent
+}
+
+make_image_layer :: proc(
+	font: ^Font_Handle,
+	line_height: f32,
+	bottom_margin: f32,
+	allocator: mem.Allocator = context.allocator,
+) -> Layer {
+	data := new(Image_Layer_Data, allocator)
+	data.font = font
+	data.line_height = line_height
+	data.bottom_margin = bottom_margin
+	data.caption = strings.builder_make(allocator)
+	data.fg_color = {0.75, 0.75, 0.78, 1.0}
+	data.bg_color = {0.12, 0.12, 0.14, 1.0}
+	data.frame_color = {0.30, 0.30, 0.33, 1.0}
+
+	return Layer {
+		kind = .Overlay,
+		z_index = 130,
+		enabled = true,
+		name = "image_view",
+		user_data = data,
+		draw = proc(
+			layer: ^Layer,
+			br: ^Batch_Renderer,
+			atlas: ^Glyph_Atlas,
+			lctx: ^Layer_Context,
+		) {
+			d := cast(^Image_Layer_Data)layer.user_data
+			if !d.visible || !d.loaded {
+				return
+			}
+			w := lctx.viewport[0]
+			h := lctx.viewport[1] - d.bottom_margin
+			push_rect(br, 0, 0, w, h, d.bg_color)
+
+			area := h - d.line_height
+			scale := image_scale(d, {w, area})
+			iw, ih := d.width * scale, d.height * scale
+			x := (w - iw) / 2 - lctx.scroll_x
+			y := max((area - ih) / 2, 0) - lctx.scroll_y
+			push_rect(br, x, y, iw, ih, d.frame_color)
+			push_image(br, x, y, iw, ih)
+
+			push_rect(br, 0, area, w, d.line_height, d.bg_color)
+			caption := strings.to_string(d.caption)
+			cx := max((w - measure_text(atlas, d.font, caption)) / 2, 8)
+			push_text(br, atlas, d.font, cx, area, caption, d.fg_color)
+		},
+		on_destroy = proc(layer: ^Layer) {
+			d := cast(^Image_Layer_Data)layer.user_data
+			strings.builder_destroy(&d.caption)
+		},
+	}
+}
+
+// Screen pixels per image pixel: the zoom, or with none the largest scale up
+// to 1 at which the image fits in area.
+image_scale :: proc(d: ^Image_Layer_Data, area: [2]f32) -> f32 {
+	if d.zoom > 0 {
+		return d.zoom
+	}
+	if d.width <= 0 || d.height <= 0 {
+		return 1
+	}
+	return min(area[0] / d.width, area[1] / d.height, 1)
+}
+
+// Uploads width by height RGBA pixels as the image to show, replacing the one
+// before, and points the Image pipeline at it.
+set_image_texture :: proc(
+	ctx: ^Render_Context,
+	br: ^Batch_Renderer,
+	d: ^Image_Layer_Data,
+	pixels: []u8,
+	width, height: int,
+) -> bool {
+	release_image_texture(ctx, d)
+	img, ok := create_gpu_image(ctx, u32(width), u32(height), .R8G8B8A8_SRGB)
+	if !ok {
+		destroy_gpu_image(ctx, &img)
+		return false
+	}
+	upload_image_data(ctx, &img, pixels, 0, 0, u32(width), u32(height), 4)
+	update_descriptor_set(ctx, &br.pipelines[.Image], &img)
+	d.texture = img
+	d.loaded = true
+	d.width, d.height = f32(width), f32(height)
+	return true
+}
+
+// Frees the image's texture once no frame in flight samples it.
+release_image_texture :: proc(ctx: ^Render_Context, d: ^Image_Layer_Data) {
+	if !d.loaded {
+		return
+	}
+	vk.DeviceWaitIdle(ctx.device)
+	destroy_gpu_image(ctx, &d.texture)
+	d.texture = {}
+	d.loaded = false
+}
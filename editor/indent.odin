@@ -0,0 +1,237 @@
+package editor
+
+import "core:strings"
+
+Indent_Style :: struct {
+	use_spaces: bool,
+	width:      int, // columns per indent level, also the tab width
+}
+
+// Builds the whitespace for `columns` visual columns in the given style.
+// Allocated with the temp allocator.
+indent_string :: proc(style: Indent_Style, columns: int) -> string {
+	if columns <= 0 {
+		return ""
+	}
+	b := strings.builder_make(context.temp_allocator)
+	width := max(style.width, 1)
+	if style.use_spaces {
+		for _ in 0 ..< columns {
+			strings.write_byte(&b, ' ')
+		}
+	} else {
+		for _ in 0 ..< columns / width {
+			strings.write_byte(&b, '\t')
+		}
+		for _ in 0 ..< columns % width {
+			strings.write_byte(&b, ' ')
+		}
+	}
+	return strings.to_string(b)
+}
+
+// Visual width of the leading whitespace of a line.
+indent_columns :: proc(line: string, tab_size: int) -> int {
+	ts := max(tab_size, 1)
+	cols := 0
+	for i in 0 ..< len(line) {
+		switch line[i] {
+		case ' ':
+			cols += 1
+		case '\t':
+			cols = (cols / ts + 1) * ts
+		case:
+			return cols
+		}
+	}
+	return cols
+}
+
+@(private)
+is_word_byte :: proc(b: u8) -> bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') || b == '_'
+}
+
+@(private = "file")
+starts_with_token :: proc(s, tok: string) -> bool {
+	if !strings.has_prefix(s, tok) {
+		return false
+	}
+	if is_word_byte(tok[len(tok) - 1]) && len(s) > len(tok) && is_word_byte(s[len(tok)]) {
+		return false
+	}
+	return true
+}
+
+@(private = "file")
+ends_with_token :: proc(s, tok: string) -> bool {
+	if !strings.has_suffix(s, tok) {
+		return false
+	}
+	before := len(s) - len(tok) - 1
+	if is_word_byte(tok[0]) && before >= 0 && is_word_byte(s[before]) {
+		return false
+	}
+	return true
+}
+
+// Returns the code part of a line: indentation and any trailing line comment
+// stripped.  The comment check is lexical and ignores string literals.
+@(private = "file")
+code_of_line :: proc(line: string, lang: ^Language) -> string {
+	code := strings.trim_space(line)
+	if lang.line_comment != "" {
+		if i := strings.index(code, lang.line_comment); i >= 0 {
+			code = strings.trim_right_space(code[:i])
+		}
+	}
+	return code
+}
+
+@(private = "file")
+matches_any_prefix :: proc(code: string, tokens: []string) -> bool {
+	for tok in tokens {
+		if starts_with_token(code, tok) {
+			return true
+		}
+	}
+	return false
+}
+
+@(private = "file")
+matches_any_suffix :: proc(code: string, tokens: []string) -> bool {
+	for tok in tokens {
+		if ends_with_token(code, tok) {
+			return true
+		}
+	}
+	return false
+}
+
+// Computes the indentation, in visual columns, that `line_num` should have
+// based on the nearest non-blank line above it and the language rules.
+// `content` is the text the line will hold (after its indentation).
+compute_indent :: proc(
+	gb: ^Gap_Buffer,
+	lang: ^Language,
+	line_num: int,
+	content: string,
+	style: Indent_Style,
+) -> int {
+	prev := line_num - 1
+	prev_line: string
+	for prev >= 0 {
+		prev_line = get_line(gb, prev, context.temp_allocator)
+		if strings.trim_space(prev_line) != "" {
+			break
+		}
+		prev -= 1
+	}
+	if prev < 0 {
+		return 0
+	}
+
+	cols := indent_columns(prev_line, style.width)
+	if lang == nil {
+		return cols
+	}
+
+	rules := &lang.indent
+	prev_code := code_of_line(prev_line, lang)
+	if matches_any_suffix(prev_code, rules.indent_after) {
+		cols += style.width
+	} else if matches_any_prefix(prev_code, rules.dedent_after) {
+		cols -= style.width
+	}
+
+	if matches_any_prefix(code_of_line(content, lang), rules.dedent_on) {
+		cols -= style.width
+	}
+	return max(cols, 0)
+}
+
+// Replaces the leading whitespace of a line with `columns` of indentation.
+set_line_indent :: proc(
+	gb: ^Gap_Buffer,
+	line_num: int,
+	columns: int,
+	style: Indent_Style,
+	positions: []int,
+) {
+	line := get_line(gb, line_num, context.temp_allocator)
+	ws := leading_whitespace(line)
+	want := indent_string(style, columns)
+	if line[:ws] == want {
+		return
+	}
+	start := line_col_to_logical_pos(gb, line_num, 0)
+	edit_tracking_positions(gb, start, ws, want, positions)
+}
+
+// Re-indents every line in [first, last] top-down so each line builds on the
+// already corrected line above it.  Blank lines are emptied.
+reindent_lines :: proc(
+	gb: ^Gap_Buffer,
+	lang: ^Language,
+	first, last: int,
+	style: Indent_Style,
+	positions: []int,
+) {
+	for ln in first ..= last {
+		line := get_line(gb, ln, context.temp_allocator)
+		content := line[leading_whitespace(line):]
+		cols := 0
+		if content != "" {
+			cols = compute_indent(gb, lang, ln, content, style)
+		}
+		set_line_indent(gb, ln, cols, style, positions)
+	}
+}
+
+// Shifts the lines of text from the first-th on by `by` columns, keeping
+// their indentation relative to one another, and rewrites it in style; blank
+// ones are emptied.  For pasting a block where it lands.  Temp allocated.
+shift_block_indent :: proc(text: string, first, by: int, style: Indent_Style) -> string {
+	b := strings.builder_make(context.temp_allocator)
+	lines := strings.split(text, "\n", context.temp_allocator)
+	for line, i in lines {
+		if i > 0 {
+			strings.write_byte(&b, '\n')
+		}
+		switch {
+		case i < first:
+			strings.write_string(&b, line)
+		case strings.trim_space(line) != "":
+			cols := max(indent_columns(line, style.width) + by, 0)
+			strings.write_string(&b, indent_string(style, cols))
+			strings.write_string(&b, line[leading_whitespace(line):])
+		}
+	}
+	return strings.to_string(b)
+}
+
+// Shifts every non-blank line in [first, last] by `levels` indent levels
+// (negative to dedent), snapping to the indent grid.
+shift_lines :: proc(
+	gb: ^Gap_Buffer,
+	first, last: int,
+	levels: int,
+	style: Indent_Style,
+	positions: []int,
+) {
+	width := max(style.width, 1)
+	for ln in first ..= last {
+		line := get_line(gb, ln, context.temp_allocator)
+		if strings.trim_space(line) == "" {
+			continue
+		}
+		cols := indent_columns(line, width)
+		target: int
+		if levels > 0 {
+			target = (cols / width + levels) * width
+		} else {
+			target = max(((cols + width - 1) / width + levels) * width, 0)
+		}
+		set_line_indent(gb, ln, target, style, positions)
+	}
+}
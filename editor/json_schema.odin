@@ -0,0 +1,433 @@
+package editor
+
+import "core:encoding/json"
+import "core:fmt"
+import "core:math"
+import "core:strconv"
+import "core:strings"
+import "core:text/regex"
+import "core:unicode/utf8"
+
+// Deepest chain of $refs followed; a schema that refers to itself goes no
+// further.
+JSON_SCHEMA_MAX_DEPTH :: 64
+
+Json_Schema_Problem :: struct {
+	pos:     int, // byte offset of the value or key it is about
+	message: string,
+}
+
+// Checks the value parsed from text against schema, a JSON Schema as read by
+// core:encoding/json.  Understood: type, enum, const, properties,
+// patternProperties, additionalProperties, required, min/maxProperties,
+// items, prefixItems, additionalItems, min/maxItems, uniqueItems, minimum,
+// maximum, exclusiveMinimum, exclusiveMaximum, multipleOf, min/maxLength,
+// pattern, allOf, anyOf, oneOf, not, if/then/else and $refs within the
+// schema.  Other keywords, format among them, are ignored.  Temp allocated.
+validate_json_schema :: proc(
+	text: string,
+	root: Json_Node,
+	schema: json.Value,
+) -> []Json_Schema_Problem {
+	v := Schema_Validator {
+		text     = text,
+		root     = schema,
+		problems = make([dynamic]Json_Schema_Problem, context.temp_allocator),
+	}
+	check_schema(&v, root, schema, 0)
+	return v.problems[:]
+}
+
+@(private = "file")
+Schema_Validator :: struct {
+	text:     string,
+	root:     json.Value,
+	problems: [dynamic]Json_Schema_Problem,
+}
+
+@(private = "file")
+schema_problem :: proc(v: ^Schema_Validator, pos: int, format: string, args: ..any) {
+	append(&v.problems, Json_Schema_Problem{pos, fmt.tprintf(format, ..args)})
+}
+
+// Whether node matches schema, without recording why not.
+@(private = "file")
+matches_schema :: proc(
+	v: ^Schema_Validator,
+	node: Json_Node,
+	schema: json.Value,
+	depth: int,
+) -> bool {
+	before := len(v.problems)
+	check_schema(v, node, schema, depth)
+	matched := len(v.problems) == before
+	resize(&v.problems, before)
+	return matched
+}
+
+@(private = "file")
+check_schema :: proc(v: ^Schema_Validator, node: Json_Node, schema: json.Value, depth: int) {
+	if allowed, ok := schema.(json.Boolean); ok {
+		if !allowed {
+			schema_problem(v, node.start, "No value is allowed here")
+		}
+		return
+	}
+	s, ok := schema.(json.Object)
+	if !ok || depth > JSON_SCHEMA_MAX_DEPTH {
+		return
+	}
+	if ref, is_ref := s["$ref"].(json.String); is_ref {
+		if target, found := resolve_schema_ref(v.root, ref); found {
+			check_schema(v, node, target, depth + 1)
+		}
+	}
+	if t, has := s["type"]; has && !check_schema_type(v, node, t) {
+		return // the rest would only repeat the mismatch
+	}
+	if options, has := s["enum"].(json.Array); has {
+		found := false
+		for option in options {
+			found = found || json_node_equals(v.text, node, option)
+		}
+		if !found {
+			schema_problem(v, node.start, "Must be one of %s", schema_list(options[:]))
+		}
+	}
+	if value, has := s["const"]; has && !json_node_equals(v.text, node, value) {
+		schema_problem(v, node.start, "Must be %s", schema_text(value))
+	}
+
+	switch node.kind {
+	case .Object:
+		check_schema_object(v, node, s, depth)
+	case .Array:
+		check_schema_array(v, node, s, depth)
+	case .String:
+		value := json_node_string(v.text, node)
+		n := utf8.rune_count(value)
+		if least, has := schema_number(s, "minLength"); has && f64(n) < least {
+			schema_problem(v, node.start, "Shorter than %v characters", least)
+		}
+		if most, has := schema_number(s, "maxLength"); has && f64(n) > most {
+			schema_problem(v, node.start, "Longer than %v characters", most)
+		}
+		pattern, has_pattern := s["pattern"].(json.String)
+		if has_pattern && !schema_pattern_matches(pattern, value) {
+			schema_problem(v, node.start, "Does not match %s", pattern)
+		}
+	case .Number:
+		check_schema_number(v, node, s)
+	case .Null, .Bool:
+	}
+
+	if all, has := s["allOf"].(json.Array); has {
+		for sub in all {
+			check_schema(v, node, sub, depth + 1)
+		}
+	}
+	if choices, has := s["anyOf"].(json.Array); has {
+		matched := false
+		for sub in choices {
+			matched = matched || matches_schema(v, node, sub, depth + 1)
+		}
+		if !matched {
+			schema_problem(v, node.start, "Matches none of the anyOf schemas")
+		}
+	}
+	if one, has := s["oneOf"].(json.Array); has {
+		n := 0
+		for sub in one {
+			if matches_schema(v, node, sub, depth + 1) {
+				n += 1
+			}
+		}
+		if n != 1 {
+			schema_problem(v, node.start, "Matches %d of the oneOf schemas instead of one", n)
+		}
+	}
+	if negated, has := s["not"]; has && matches_schema(v, node, negated, depth + 1) {
+		schema_problem(v, node.start, "Matches a schema it must not")
+	}
+	if cond, has := s["if"]; has {
+		branch := "then" if matches_schema(v, node, cond, depth + 1) else "else"
+		if sub, has_branch := s[branch]; has_branch {
+			check_schema(v, node, sub, depth + 1)
+		}
+	}
+}
+
+// Checks "type", a name or a list of them.  Returns false on a mismatch.
+@(private = "file")
+check_schema_type :: proc(v: ^Schema_Validator, node: Json_Node, t: json.Value) -> bool {
+	names: []json.Value
+	one := [1]json.Value{t}
+	if list, is_list := t.(json.Array); is_list {
+		names = list[:]
+	} else {
+		names = one[:]
+	}
+	for name in names {
+		if json_node_has_type(v.text, node, name.(json.String) or_else "") {
+			return true
+		}
+	}
+	kind := JSON_KIND_NAMES[node.kind]
+	schema_problem(v, node.start, "Expected %s, not %s", schema_list(names), kind)
+	return false
+}
+
+@(private = "file")
+check_schema_object :: proc(v: ^Schema_Validator, node: Json_Node, s: json.Object, depth: int) {
+	properties, _ := s["properties"].(json.Object)
+	patterns, _ := s["patternProperties"].(json.Object)
+	extra, has_extra := s["additionalProperties"]
+	for member in node.children {
+		key := json_node_key(v.text, member)
+		known := false
+		if sub, has := properties[key]; has {
+			check_schema(v, member, sub, depth + 1)
+			known = true
+		}
+		for pattern, sub in patterns {
+			if schema_pattern_matches(pattern, key) {
+				check_schema(v, member, sub, depth + 1)
+				known = true
+			}
+		}
+		if known || !has_extra {
+			continue
+		}
+		if allowed, is_bool := extra.(json.Boolean); is_bool && !allowed {
+			schema_problem(v, member.key_start, "Property %s is not allowed", member.key)
+		} else {
+			check_schema(v, member, extra, depth + 1)
+		}
+	}
+	if required, has := s["required"].(json.Array); has {
+		for name in required {
+			key := name.(json.String) or_else ""
+			found := false
+			for member in node.children {
+				found = found || json_node_key(v.text, member) == key
+			}
+			if !found {
+				schema_problem(v, node.start, "Missing property \"%s\"", key)
+			}
+		}
+	}
+	n := f64(len(node.children))
+	if least, has := schema_number(s, "minProperties"); has && n < least {
+		schema_problem(v, node.start, "Fewer than %v properties", least)
+	}
+	if most, has := schema_number(s, "maxProperties"); has && n > most {
+		schema_problem(v, node.start, "More than %v properties", most)
+	}
+}
+
+@(private = "file")
+check_schema_array :: proc(v: ^Schema_Validator, node: Json_Node, s: json.Object, depth: int) {
+	// Before 2020-12 "items" could be a list for the leading items; after,
+	// that is "prefixItems" and "items" is for the rest.
+	prefix, has_prefix := s["prefixItems"].(json.Array)
+	rest, has_rest := s["items"]
+	if tuple, is_tuple := rest.(json.Array); is_tuple && !has_prefix {
+		prefix, has_prefix = tuple, true
+		rest, has_rest = s["additionalItems"]
+	}
+	for item, i in node.children {
+		switch {
+		case has_prefix && i < len(prefix):
+			check_schema(v, item, prefix[i], depth + 1)
+		case has_rest:
+			check_schema(v, item, rest, depth + 1)
+		}
+	}
+	n := f64(len(node.children))
+	if least, has := schema_number(s, "minItems"); has && n < least {
+		schema_problem(v, node.start, "Fewer than %v items", least)
+	}
+	if most, has := schema_number(s, "maxItems"); has && n > most {
+		schema_problem(v, node.start, "More than %v items", most)
+	}
+	if unique, has := s["uniqueItems"].(json.Boolean); has && unique {
+		seen := make(map[string]bool, allocator = context.temp_allocator)
+		for item in node.children {
+			text := format_json_tree(v.text, item, "")
+			if text in seen {
+				schema_problem(v, item.start, "Repeats an earlier item")
+			}
+			seen[text] = true
+		}
+	}
+}
+
+@(private = "file")
+check_schema_number :: proc(v: ^Schema_Validator, node: Json_Node, s: json.Object) {
+	x, _ := strconv.parse_f64(v.text[node.start:node.end])
+	if least, has := schema_number(s, "minimum"); has && x < least {
+		schema_problem(v, node.start, "Less than %v", least)
+	}
+	if most, has := schema_number(s, "maximum"); has && x > most {
+		schema_problem(v, node.start, "More than %v", most)
+	}
+	if least, has := schema_number(s, "exclusiveMinimum"); has && x <= least {
+		schema_problem(v, node.start, "Not more than %v", least)
+	}
+	if most, has := schema_number(s, "exclusiveMaximum"); has && x >= most {
+		schema_problem(v, node.start, "Not less than %v", most)
+	}
+	if step, has := schema_number(s, "multipleOf"); has && step > 0 {
+		q := x / step
+		if abs(q - math.round(q)) > 1e-9 {
+			schema_problem(v, node.start, "Not a multiple of %v", step)
+		}
+	}
+}
+
+@(private = "file")
+JSON_KIND_NAMES := [Json_Kind]string {
+	.Null   = "null",
+	.Bool   = "boolean",
+	.Number = "number",
+	.String = "string",
+	.Array  = "array",
+	.Object = "object",
+}
+
+@(private = "file")
+json_node_has_type :: proc(text: string, node: Json_Node, name: string) -> bool {
+	if name == "integer" {
+		if node.kind != .Number {
+			return false
+		}
+		x, _ := strconv.parse_f64(text[node.start:node.end])
+		return x == math.trunc(x)
+	}
+	return name == JSON_KIND_NAMES[node.kind]
+}
+
+// Whether node holds the same value as value; numbers compare by value.
+@(private = "file")
+json_node_equals :: proc(text: string, node: Json_Node, value: json.Value) -> bool {
+	switch node.kind {
+	case .Null:
+		_, ok := value.(json.Null)
+		return ok
+	case .Bool:
+		b, ok := value.(json.Boolean)
+		return ok && b == (text[node.start] == 't')
+	case .Number:
+		x, _ := strconv.parse_f64(text[node.start:node.end])
+		#partial switch n in value {
+		case json.Integer:
+			return x == f64(n)
+		case json.Float:
+			return x == f64(n)
+		}
+		return false
+	case .String:
+		s, ok := value.(json.String)
+		return ok && json_node_string(text, node) == s
+	case .Array:
+		items, ok := value.(json.Array)
+		if !ok || len(items) != len(node.children) {
+			return false
+		}
+		for item, i in node.children {
+			if !json_node_equals(text, item, items[i]) {
+				return false
+			}
+		}
+		return true
+	case .Object:
+		members, ok := value.(json.Object)
+		if !ok || len(members) != len(node.children) {
+			return false
+		}
+		for member in node.children {
+			other, has := members[json_node_key(text, member)]
+			if !has || !json_node_equals(text, member, other) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Follows a "#/definitions/name" style reference within the schema.
+@(private = "file")
+resolve_schema_ref :: proc(root: json.Value, ref: string) -> (json.Value, bool) {
+	if !strings.has_prefix(ref, "#") {
+		return nil, false // other documents are not loaded
+	}
+	at := root
+	for part in strings.split(strings.trim_prefix(ref[1:], "/"), "/", context.temp_allocator) {
+		if part == "" {
+			continue
+		}
+		name, _ := strings.replace_all(part, "~1", "/", context.temp_allocator)
+		name, _ = strings.replace_all(name, "~0", "~", context.temp_allocator)
+		#partial switch container in at {
+		case json.Object:
+			next, has := container[name]
+			if !has {
+				return nil, false
+			}
+			at = next
+		case json.Array:
+			i, ok := strconv.parse_int(name)
+			if !ok || i < 0 || i >= len(container) {
+				return nil, false
+			}
+			at = container[i]
+		case:
+			return nil, false
+		}
+	}
+	return at, true
+}
+
+@(private = "file")
+schema_number :: proc(s: json.Object, key: string) -> (f64, bool) {
+	#partial switch n in s[key] {
+	case json.Integer:
+		return f64(n), true
+	case json.Float:
+		return f64(n), true
+	}
+	return 0, false
+}
+
+// A pattern that does not compile matches everything, as the schema is at
+// fault rather than the value.
+@(private = "file")
+schema_pattern_matches :: proc(pattern, s: string) -> bool {
+	re, err := regex.create(pattern, {}, context.temp_allocator)
+	if err != nil {
+		return true
+	}
+	capture := regex.preallocate_capture(context.temp_allocator)
+	_, matched := regex.match(re, s, &capture)
+	return matched
+}
+
+@(private = "file")
+schema_text :: proc(value: json.Value) -> string {
+	data, err := json.marshal(value, allocator = context.temp_allocator)
+	return string(data) if err == nil else "?"
+}
+
+@(private = "file")
+schema_list :: proc(values: []json.Value) -> string {
+	parts := make([dynamic]string, context.temp_allocator)
+	for value in values {
+		if s, is_string := value.(json.String); is_string {
+			append(&parts, s)
+		} else {
+			append(&parts, schema_text(value))
+		}
+	}
+	return strings.join(parts[:], ", ", context.temp_allocator)
+}
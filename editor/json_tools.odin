@@ -0,0 +1,520 @@
+package editor
+
+import "core:encoding/json"
+import "core:mem"
+import "core:strings"
+
+// Deepest nesting parse_json_tree follows.
+JSON_MAX_DEPTH :: 512
+
+Json_Kind :: enum u8 {
+	Null,
+	Bool,
+	Number,
+	String,
+	Array,
+	Object,
+}
+
+// A JSON value and where it is in the text it was parsed from, so that
+// formatting keeps numbers and strings byte for byte and problems found in it
+// point at their place.
+Json_Node :: struct {
+	kind:       Json_Kind,
+	start, end: int, // byte range of the value
+	key:        string, // of a member of an object, as written, quotes and all
+	key_start:  int,
+	children:   []Json_Node, // the items of an array or members of an object
+}
+
+Json_Syntax_Error :: struct {
+	pos:     int,
+	message: string,
+}
+
+// Parses text as one JSON value, comments and trailing commas not allowed.
+parse_json_tree :: proc(
+	text: string,
+	allocator: mem.Allocator = context.temp_allocator,
+) -> (
+	root: Json_Node,
+	err: Json_Syntax_Error,
+	ok: bool,
+) {
+	p := Json_Parser {
+		text      = text,
+		allocator = allocator,
+	}
+	root, ok = parse_json_value(&p, 0)
+	if ok {
+		skip_json_space(&p)
+		if p.pos < len(text) {
+			ok = json_fail(&p, "Text after the end of the value")
+		}
+	}
+	return root, p.err, ok
+}
+
+// Writes the tree back out with indent per level, or on one line without
+// blanks when indent is "".  Numbers, strings and keys stay as written.
+format_json_tree :: proc(
+	text: string,
+	root: Json_Node,
+	indent: string,
+	allocator: mem.Allocator = context.temp_allocator,
+) -> string {
+	b := strings.builder_make(allocator)
+	write_json_node(&b, text, root, indent, 0)
+	if indent != "" {
+		strings.write_byte(&b, '\n')
+	}
+	return strings.to_string(b)
+}
+
+// The value of a string node.  A slice of text when it has no escapes, else
+// temp allocated.
+json_node_string :: proc(text: string, node: Json_Node) -> string {
+	raw := text[node.start:node.end]
+	if strings.index_byte(raw, '\\') < 0 {
+		return raw[1:len(raw) - 1]
+	}
+	v, _ := json.parse_string(raw, allocator = context.temp_allocator)
+	return v.(json.String) or_else ""
+}
+
+// The key of a member node, unquoted like json_node_string.
+json_node_key :: proc(text: string, node: Json_Node) -> string {
+	key := node
+	key.start, key.end = node.key_start, node.key_start + len(node.key)
+	return json_node_string(text, key)
+}
+
+// The path of the value at byte pos, as "$.store.book[0].title", from the text
+// before it alone: it need not be valid JSON, so the path follows typing.
+// Keys that are not identifiers are written ["like this"].  Temp allocated.
+json_path_at :: proc(text: string, pos: int) -> string {
+	Frame :: struct {
+		array:   bool,
+		index:   int,
+		key:     string, // as written, quotes and all; "" before the first
+		has_key: bool,
+	}
+	stack := make([dynamic]Frame, context.temp_allocator)
+	expect_key := false
+	for i := 0; i < min(pos, len(text)); {
+		c := text[i]
+		i += 1
+		switch c {
+		case '{':
+			append(&stack, Frame{})
+			expect_key = true
+		case '[':
+			append(&stack, Frame{array = true})
+			expect_key = false
+		case '}', ']':
+			if len(stack) > 0 {
+				pop(&stack)
+			}
+			expect_key = false
+		case ',':
+			if len(stack) > 0 && stack[len(stack) - 1].array {
+				stack[len(stack) - 1].index += 1
+			} else if len(stack) > 0 {
+				stack[len(stack) - 1].has_key = false
+				expect_key = true
+			}
+		case ':':
+			expect_key = false
+		case '"':
+			start := i - 1
+			for i < len(text) && text[i] != '"' && text[i] != '\n' {
+				i += 2 if text[i] == '\\' else 1
+			}
+			i = min(i + 1, len(text))
+			if expect_key && len(stack) > 0 && !stack[len(stack) - 1].array {
+				top := &stack[len(stack) - 1]
+				top.key, top.has_key = text[start:i], true
+				expect_key = false
+			}
+		}
+	}
+	b := strings.builder_make(context.temp_allocator)
+	strings.write_byte(&b, '$')
+	for f in stack {
+		switch {
+		case f.array:
+			strings.write_byte(&b, '[')
+			strings.write_int(&b, f.index)
+			strings.write_byte(&b, ']')
+		case f.has_key:
+			write_path_key(&b, strings.trim_suffix(strings.trim_prefix(f.key, "\""), "\""))
+		}
+	}
+	return strings.to_string(b)
+}
+
+// The path of line in YAML text, as "spec.containers[0].image", from the keys
+// and list items that the lines above it nest it in.  Flow collections
+// ({...} and [...]) and multi-line strings are taken for plain values.
+// Temp allocated.
+yaml_path_at :: proc(lines: []string, line: int) -> string {
+	if line < 0 || line >= len(lines) {
+		return ""
+	}
+	found := make([dynamic]Yaml_Node, context.temp_allocator)
+	nodes := yaml_line_nodes(lines[line], line)
+	#reverse for n in nodes {
+		append(&found, n)
+	}
+	limit := max(int)
+	equal_key := false // a list item may sit level with its parent key
+	if len(nodes) > 0 {
+		limit, equal_key = nodes[0].indent, nodes[0].item
+	} else if text := strings.trim_left(lines[line], " "); text != "" {
+		limit = len(lines[line]) - len(text)
+	}
+	for i := line - 1; i >= 0 && (limit > 0 || equal_key); i -= 1 {
+		#reverse for n in yaml_line_nodes(lines[i], i) {
+			if n.indent < limit || (equal_key && n.indent == limit && !n.item) {
+				append(&found, n)
+				limit, equal_key = n.indent, n.item
+			}
+		}
+	}
+	b := strings.builder_make(context.temp_allocator)
+	#reverse for n in found {
+		if n.item {
+			strings.write_byte(&b, '[')
+			strings.write_int(&b, yaml_item_index(lines, n))
+			strings.write_byte(&b, ']')
+		} else {
+			write_path_key(&b, n.key)
+		}
+	}
+	return strings.trim_prefix(strings.to_string(b), ".")
+}
+
+// A key or list item of a YAML line: "- name: x" has an item and, two
+// columns in, a key.
+@(private = "file")
+Yaml_Node :: struct {
+	indent: int,
+	item:   bool,
+	key:    string,
+	line:   int,
+}
+
+@(private = "file")
+yaml_line_nodes :: proc(text: string, line: int) -> []Yaml_Node {
+	nodes := make([dynamic]Yaml_Node, context.temp_allocator)
+	indent := 0
+	for indent < len(text) && text[indent] == ' ' {
+		indent += 1
+	}
+	rest := text[indent:]
+	for strings.has_prefix(rest, "- ") || rest == "-" {
+		append(&nodes, Yaml_Node{indent = indent, item = true, line = line})
+		skip := 1
+		for skip < len(rest) && rest[skip] == ' ' {
+			skip += 1
+		}
+		indent += skip
+		rest = rest[skip:]
+	}
+	if rest == "" || rest[0] == '#' || rest[0] == '{' || rest[0] == '[' || rest == "---" {
+		return nodes[:]
+	}
+	quote: u8 = 0
+	if rest[0] == '"' || rest[0] == '\'' {
+		quote = rest[0]
+	}
+	for i := 1 if quote != 0 else 0; i < len(rest); i += 1 {
+		c := rest[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		if c == ':' && (i + 1 == len(rest) || rest[i + 1] == ' ') {
+			key := strings.trim_space(rest[:i])
+			if len(key) >= 2 && (key[0] == '"' || key[0] == '\'') && key[len(key) - 1] == key[0] {
+				key = key[1:len(key) - 1]
+			}
+			append(&nodes, Yaml_Node{indent = indent, key = key, line = line})
+			break
+		}
+	}
+	return nodes[:]
+}
+
+// How many items of its list come before item n: the items level with it on
+// the lines above, up to a line less indented or a key level with it.
+@(private = "file")
+yaml_item_index :: proc(lines: []string, n: Yaml_Node) -> int {
+	index := 0
+	for i := n.line - 1; i >= 0; i -= 1 {
+		nodes := yaml_line_nodes(lines[i], i)
+		if len(nodes) == 0 {
+			continue
+		}
+		if nodes[0].indent < n.indent {
+			break
+		}
+		level := false
+		for m in nodes {
+			if m.indent == n.indent {
+				level = true
+				if !m.item {
+					return index
+				}
+			}
+		}
+		if level {
+			index += 1
+		}
+	}
+	return index
+}
+
+@(private = "file")
+write_path_key :: proc(b: ^strings.Builder, key: string) {
+	plain := key != ""
+	for c, i in key {
+		letter := c == '_' || c == '$' || (c | 0x20 >= 'a' && c | 0x20 <= 'z')
+		if !letter && !(i > 0 && c >= '0' && c <= '9') {
+			plain = false
+		}
+	}
+	if plain {
+		strings.write_byte(b, '.')
+		strings.write_string(b, key)
+	} else {
+		strings.write_string(b, "[\"")
+		strings.write_string(b, key)
+		strings.write_string(b, "\"]")
+	}
+}
+
+@(private = "file")
+Json_Parser :: struct {
+	text:      string,
+	pos:       int,
+	err:       Json_Syntax_Error,
+	allocator: mem.Allocator,
+}
+
+@(private = "file")
+json_fail :: proc(p: ^Json_Parser, message: string) -> bool {
+	p.err = {p.pos, message}
+	return false
+}
+
+@(private = "file")
+skip_json_space :: proc(p: ^Json_Parser) {
+	for p.pos < len(p.text) {
+		switch p.text[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos += 1
+		case:
+			return
+		}
+	}
+}
+
+@(private = "file")
+parse_json_value :: proc(p: ^Json_Parser, depth: int) -> (node: Json_Node, ok: bool) {
+	skip_json_space(p)
+	if depth > JSON_MAX_DEPTH {
+		return {}, json_fail(p, "Nested too deep")
+	}
+	if p.pos >= len(p.text) {
+		return {}, json_fail(p, "Expected a value")
+	}
+	node.start = p.pos
+	rest := p.text[p.pos:]
+	switch rest[0] {
+	case '{', '[':
+		node.kind = .Object if rest[0] == '{' else .Array
+		close: u8 = '}' if node.kind == .Object else ']'
+		children := make([dynamic]Json_Node, p.allocator)
+		p.pos += 1
+		skip_json_space(p)
+		more := p.pos >= len(p.text) || p.text[p.pos] != close
+		if !more {
+			p.pos += 1
+		}
+		for more {
+			child: Json_Node
+			if node.kind == .Object {
+				skip_json_space(p)
+				if p.pos >= len(p.text) || p.text[p.pos] != '"' {
+					return {}, json_fail(p, "Expected a key in quotes")
+				}
+				key_start := p.pos
+				scan_json_string(p) or_return
+				key := p.text[key_start:p.pos]
+				skip_json_space(p)
+				if p.pos >= len(p.text) || p.text[p.pos] != ':' {
+					return {}, json_fail(p, "Expected ':' after the key")
+				}
+				p.pos += 1
+				child = parse_json_value(p, depth + 1) or_return
+				child.key, child.key_start = key, key_start
+			} else {
+				child = parse_json_value(p, depth + 1) or_return
+			}
+			append(&children, child)
+			skip_json_space(p)
+			switch {
+			case p.pos < len(p.text) && p.text[p.pos] == ',':
+				p.pos += 1
+			case p.pos < len(p.text) && p.text[p.pos] == close:
+				p.pos += 1
+				more = false
+			case node.kind == .Object:
+				return {}, json_fail(p, "Expected ',' or '}'")
+			case:
+				return {}, json_fail(p, "Expected ',' or ']'")
+			}
+		}
+		node.children = children[:]
+	case '"':
+		node.kind = .String
+		scan_json_string(p) or_return
+	case '-', '0' ..= '9':
+		node.kind = .Number
+		scan_json_number(p) or_return
+	case '/':
+		return {}, json_fail(p, "JSON has no comments")
+	case:
+		switch {
+		case strings.has_prefix(rest, "true"):
+			node.kind = .Bool
+			p.pos += 4
+		case strings.has_prefix(rest, "false"):
+			node.kind = .Bool
+			p.pos += 5
+		case strings.has_prefix(rest, "null"):
+			node.kind = .Null
+			p.pos += 4
+		case:
+			return {}, json_fail(p, "Expected a value")
+		}
+		if p.pos < len(p.text) && is_json_word_byte(p.text[p.pos]) {
+			return {}, json_fail(p, "Expected a value")
+		}
+	}
+	node.end = p.pos
+	return node, true
+}
+
+@(private = "file")
+scan_json_string :: proc(p: ^Json_Parser) -> bool {
+	p.pos += 1
+	for p.pos < len(p.text) {
+		c := p.text[p.pos]
+		switch {
+		case c == '"':
+			p.pos += 1
+			return true
+		case c == '\\':
+			escape := p.text[p.pos + 1] if p.pos + 1 < len(p.text) else 0
+			if strings.index_byte("\"\\/bfnrtu", escape) < 0 {
+				return json_fail(p, "Unknown escape in a string")
+			}
+			p.pos += 2
+		case c < 0x20:
+			return json_fail(p, "Line break or control character in a string")
+		case:
+			p.pos += 1
+		}
+	}
+	return json_fail(p, "String without its closing quote")
+}
+
+@(private = "file")
+scan_json_number :: proc(p: ^Json_Parser) -> bool {
+	digits :: proc(p: ^Json_Parser) -> int {
+		n := 0
+		for p.pos < len(p.text) && p.text[p.pos] >= '0' && p.text[p.pos] <= '9' {
+			p.pos += 1
+			n += 1
+		}
+		return n
+	}
+	if p.text[p.pos] == '-' {
+		p.pos += 1
+	}
+	lead := p.pos
+	if digits(p) == 0 || (p.text[lead] == '0' && p.pos - lead > 1) {
+		return json_fail(p, "Malformed number")
+	}
+	if p.pos < len(p.text) && p.text[p.pos] == '.' {
+		p.pos += 1
+		if digits(p) == 0 {
+			return json_fail(p, "Malformed number")
+		}
+	}
+	if p.pos < len(p.text) && (p.text[p.pos] == 'e' || p.text[p.pos] == 'E') {
+		p.pos += 1
+		if p.pos < len(p.text) && (p.text[p.pos] == '+' || p.text[p.pos] == '-') {
+			p.pos += 1
+		}
+		if digits(p) == 0 {
+			return json_fail(p, "Malformed number")
+		}
+	}
+	if p.pos < len(p.text) && is_json_word_byte(p.text[p.pos]) {
+		return json_fail(p, "Malformed number")
+	}
+	return true
+}
+
+@(private = "file")
+is_json_word_byte :: proc(c: u8) -> bool {
+	lower := c | 0x20
+	return c == '_' || c == '.' || (c >= '0' && c <= '9') || (lower >= 'a' && lower <= 'z')
+}
+
+@(private = "file")
+write_json_node :: proc(
+	b: ^strings.Builder,
+	text: string,
+	node: Json_Node,
+	indent: string,
+	level: int,
+) {
+	if node.kind != .Array && node.kind != .Object {
+		strings.write_string(b, text[node.start:node.end])
+		return
+	}
+	open, close := "[", "]"
+	if node.kind == .Object {
+		open, close = "{", "}"
+	}
+	strings.write_string(b, open)
+	for child, i in node.children {
+		if i > 0 {
+			strings.write_byte(b, ',')
+		}
+		if indent != "" {
+			strings.write_byte(b, '\n')
+			for _ in 0 ..= level {
+				strings.write_string(b, indent)
+			}
+		}
+		if node.kind == .Object {
+			strings.write_string(b, child.key)
+			strings.write_string(b, ": " if indent != "" else ":")
+		}
+		write_json_node(b, text, child, indent, level + 1)
+	}
+	if indent != "" && len(node.children) > 0 {
+		strings.write_byte(b, '\n')
+		for _ in 0 ..< level {
+			strings.write_string(b, indent)
+		}
+	}
+	strings.write_string(b, close)
+}
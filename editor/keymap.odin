@@ -0,0 +1,445 @@
+package editor
+
+import "core:encoding/json"
+import "core:fmt"
+import "core:log"
+import "core:mem"
+import "core:os"
+import "core:strings"
+import "vendor:glfw"
+
+MAX_KEY_SEQUENCE :: 4
+
+// Only these modifiers take part in matching; lock-key bits are ignored.
+KEY_MOD_MASK :: glfw.MOD_SHIFT | glfw.MOD_CONTROL | glfw.MOD_ALT | glfw.MOD_SUPER
+
+Key_Chord :: struct {
+	key:  i32, // glfw key code
+	mods: i32, // glfw MOD_* bits, masked with KEY_MOD_MASK
+}
+
+Key_Sequence :: struct {
+	chords: [MAX_KEY_SEQUENCE]Key_Chord,
+	len:    int,
+}
+
+Key_Binding :: struct {
+	sequence: Key_Sequence,
+	command:  string, // empty string unbinds the sequence
+	mode:     string, // empty matches every mode
+	language: string, // empty matches every language
+}
+
+Keymap :: struct {
+	bindings:  [dynamic]Key_Binding,
+	pending:   Key_Sequence,
+	allocator: mem.Allocator,
+}
+
+Key_Result :: enum u8 {
+	Unbound,
+	Pending,
+	Matched,
+}
+
+// A key that may follow the pending ones.
+Key_Continuation :: struct {
+	chord:   Key_Chord,
+	command: string, // run by the chord, empty when it only leads on
+	more:    int, // bindings longer still that the chord leads to
+}
+
+// One entry of a keymap file:
+// [{"keys": "ctrl+k ctrl+c", "command": "comment.toggle", "mode": "editor", "language": "odin"}]
+Key_Binding_Entry :: struct {
+	keys:     string,
+	command:  string,
+	mode:     string,
+	language: string,
+}
+
+@(private = "file")
+Key_Name :: struct {
+	name: string,
+	key:  i32,
+}
+
+@(private = "file")
+KEY_NAMES := [?]Key_Name {
+	{"space", glfw.KEY_SPACE},
+	{"escape", glfw.KEY_ESCAPE},
+	{"esc", glfw.KEY_ESCAPE},
+	{"enter", glfw.KEY_ENTER},
+	{"return", glfw.KEY_ENTER},
+	{"tab", glfw.KEY_TAB},
+	{"backspace", glfw.KEY_BACKSPACE},
+	{"insert", glfw.KEY_INSERT},
+	{"delete", glfw.KEY_DELETE},
+	{"del", glfw.KEY_DELETE},
+	{"right", glfw.KEY_RIGHT},
+	{"left", glfw.KEY_LEFT},
+	{"down", glfw.KEY_DOWN},
+	{"up", glfw.KEY_UP},
+	{"pageup", glfw.KEY_PAGE_UP},
+	{"pagedown", glfw.KEY_PAGE_DOWN},
+	{"home", glfw.KEY_HOME},
+	{"end", glfw.KEY_END},
+	{"kpenter", glfw.KEY_KP_ENTER},
+	{"f1", glfw.KEY_F1},
+	{"f2", glfw.KEY_F2},
+	{"f3", glfw.KEY_F3},
+	{"f4", glfw.KEY_F4},
+	{"f5", glfw.KEY_F5},
+	{"f6", glfw.KEY_F6},
+	{"f7", glfw.KEY_F7},
+	{"f8", glfw.KEY_F8},
+	{"f9", glfw.KEY_F9},
+	{"f10", glfw.KEY_F10},
+	{"f11", glfw.KEY_F11},
+	{"f12", glfw.KEY_F12},
+}
+
+init_keymap :: proc(allocator: mem.Allocator = context.allocator) -> Keymap {
+	return Keymap{bindings = make([dynamic]Key_Binding, allocator), allocator = allocator}
+}
+
+destroy_keymap :: proc(km: ^Keymap) {
+	for b in km.bindings {
+		delete(b.command, km.allocator)
+		delete(b.mode, km.allocator)
+		delete(b.language, km.allocator)
+	}
+	delete(km.bindings)
+}
+
+// Returns true for keys that only ever act as modifiers.
+is_modifier_key :: proc(key: i32) -> bool {
+	switch key {
+	case glfw.KEY_LEFT_SHIFT,
+	     glfw.KEY_RIGHT_SHIFT,
+	     glfw.KEY_LEFT_CONTROL,
+	     glfw.KEY_RIGHT_CONTROL,
+	     glfw.KEY_LEFT_ALT,
+	     glfw.KEY_RIGHT_ALT,
+	     glfw.KEY_LEFT_SUPER,
+	     glfw.KEY_RIGHT_SUPER:
+		return true
+	}
+	return false
+}
+
+// Parses a single chord such as "ctrl+shift+k" or "f5".
+parse_key_chord :: proc(s: string) -> (chord: Key_Chord, ok: bool) {
+	parts := strings.split(s, "+", context.temp_allocator)
+	if len(parts) == 0 {
+		return chord, false
+	}
+
+	for part, i in parts {
+		name := strings.to_lower(strings.trim_space(part), context.temp_allocator)
+		if i < len(parts) - 1 {
+			switch name {
+			case "ctrl", "control":
+				chord.mods |= glfw.MOD_CONTROL
+			case "shift":
+				chord.mods |= glfw.MOD_SHIFT
+			case "alt", "opt", "option":
+				chord.mods |= glfw.MOD_ALT
+			case "super", "cmd", "win":
+				chord.mods |= glfw.MOD_SUPER
+			case:
+				return chord, false
+			}
+			continue
+		}
+
+		// A trailing empty part means the key itself was '+', e.g. "ctrl++".
+		if name == "" {
+			if i == 0 {
+				return chord, false
+			}
+			name = "="
+			chord.mods |= glfw.MOD_SHIFT
+		}
+
+		chord.key, ok = key_from_name(name)
+		if !ok {
+			return chord, false
+		}
+	}
+
+	return chord, true
+}
+
+// Parses a space separated list of chords: "ctrl+k ctrl+c".
+parse_key_sequence :: proc(s: string) -> (seq: Key_Sequence, ok: bool) {
+	for field in strings.fields(s, context.temp_allocator) {
+		if seq.len >= MAX_KEY_SEQUENCE {
+			return seq, false
+		}
+		seq.chords[seq.len] = parse_key_chord(field) or_return
+		seq.len += 1
+	}
+	return seq, seq.len > 0
+}
+
+key_from_name :: proc(name: string) -> (key: i32, ok: bool) {
+	for kn in KEY_NAMES {
+		if kn.name == name {
+			return kn.key, true
+		}
+	}
+
+	if len(name) != 1 {
+		return 0, false
+	}
+
+	// glfw uses the US-layout ASCII code for every printable key.
+	c := name[0]
+	switch c {
+	case 'a' ..= 'z':
+		return i32(c - 'a' + 'A'), true
+	case '0' ..= '9', '\'', ',', '-', '.', '/', ';', '=', '[', '\\', ']', '`':
+		return i32(c), true
+	}
+	return 0, false
+}
+
+key_to_name :: proc(key: i32) -> string {
+	for kn in KEY_NAMES {
+		if kn.key == key {
+			return kn.name
+		}
+	}
+	if key >= 'A' && key <= 'Z' {
+		return fmt.tprintf("%c", rune(key - 'A' + 'a'))
+	}
+	if key > 32 && key <= 126 {
+		return fmt.tprintf("%c", rune(key))
+	}
+	return fmt.tprintf("key%d", key)
+}
+
+// Formats a chord back into the same syntax that parse_key_chord accepts.
+// The result is allocated with the temp allocator.
+format_key_chord :: proc(chord: Key_Chord) -> string {
+	b := strings.builder_make(context.temp_allocator)
+	if chord.mods & glfw.MOD_CONTROL != 0 do strings.write_string(&b, "ctrl+")
+	if chord.mods & glfw.MOD_ALT != 0 do strings.write_string(&b, "alt+")
+	if chord.mods & glfw.MOD_SUPER != 0 do strings.write_string(&b, "super+")
+	if chord.mods & glfw.MOD_SHIFT != 0 do strings.write_string(&b, "shift+")
+	strings.write_string(&b, key_to_name(chord.key))
+	return strings.to_string(b)
+}
+
+format_key_sequence :: proc(seq: Key_Sequence) -> string {
+	b := strings.builder_make(context.temp_allocator)
+	for i in 0 ..< seq.len {
+		if i > 0 {
+			strings.write_byte(&b, ' ')
+		}
+		strings.write_string(&b, format_key_chord(seq.chords[i]))
+	}
+	return strings.to_string(b)
+}
+
+// Adds a binding.  Later bindings take precedence over earlier ones, so user
+// maps are loaded after the defaults.
+bind_key :: proc(km: ^Keymap, keys, command: string, mode := "", language := "") -> bool {
+	seq, ok := parse_key_sequence(keys)
+	if !ok {
+		return false
+	}
+	append(
+		&km.bindings,
+		Key_Binding {
+			sequence = seq,
+			command = strings.clone(command, km.allocator),
+			mode = strings.clone(mode, km.allocator),
+			language = strings.clone(language, km.allocator),
+		},
+	)
+	return true
+}
+
+// Loads a JSON array of Key_Binding_Entry.  A missing file is not an error.
+load_keymap_file :: proc(km: ^Keymap, path: string) -> bool {
+	data, err := os.read_entire_file_from_path(path, context.temp_allocator)
+	if err != nil {
+		return !os.exists(path)
+	}
+
+	entries: []Key_Binding_Entry
+	if uerr := json.unmarshal(data, &entries, allocator = context.temp_allocator); uerr != nil {
+		log.warnf("keymap: %s: %v", path, uerr)
+		return false
+	}
+
+	ok := true
+	for e in entries {
+		if !bind_key(km, e.keys, e.command, e.mode, e.language) {
+			log.warnf("keymap: %s: invalid key sequence %q", path, e.keys)
+			ok = false
+		}
+	}
+	return ok
+}
+
+@(private = "file")
+sequence_has_prefix :: proc(seq, prefix: Key_Sequence) -> bool {
+	if prefix.len > seq.len {
+		return false
+	}
+	for i in 0 ..< prefix.len {
+		if seq.chords[i] != prefix.chords[i] {
+			return false
+		}
+	}
+	return true
+}
+
+@(private = "file")
+binding_applies :: proc(b: Key_Binding, mode, language: string) -> bool {
+	return (b.mode == "" || b.mode == mode) && (b.language == "" || b.language == language)
+}
+
+// Looks up the command bound to seq.  Language specific bindings win over
+// generic ones; within the same specificity the most recent binding wins.
+lookup_binding :: proc(
+	km: ^Keymap,
+	seq: Key_Sequence,
+	mode, language: string,
+) -> (
+	command: string,
+	found: bool,
+) {
+	best_score := -1
+	#reverse for b in km.bindings {
+		if b.sequence != seq || !binding_applies(b, mode, language) {
+			continue
+		}
+		score := 0
+		if b.language != "" do score += 2
+		if b.mode != "" do score += 1
+		if score > best_score {
+			best_score = score
+			command = b.command
+		}
+	}
+	return command, best_score >= 0 && command != ""
+}
+
+// The bindings that run command, leaving out the ones a later binding of the
+// same keys, mode and language replaced or unbound.  Temp allocated.
+bindings_for_command :: proc(km: ^Keymap, command: string) -> []Key_Binding {
+	found := make([dynamic]Key_Binding, context.temp_allocator)
+	for b in km.bindings {
+		if b.command != command {
+			continue
+		}
+		if bound, ok := lookup_binding(km, b.sequence, b.mode, b.language); ok && bound == command {
+			append(&found, b)
+		}
+	}
+	return found[:]
+}
+
+// Returns true if some binding in scope is strictly longer than seq and starts
+// with it.
+has_continuation :: proc(km: ^Keymap, seq: Key_Sequence, mode, language: string) -> bool {
+	for b in km.bindings {
+		if b.sequence.len > seq.len &&
+		   b.command != "" &&
+		   binding_applies(b, mode, language) &&
+		   sequence_has_prefix(b.sequence, seq) {
+			return true
+		}
+	}
+	return false
+}
+
+// The keys that may follow km.pending in scope, in binding order.  Temp
+// allocated.
+pending_continuations :: proc(km: ^Keymap, mode, language: string) -> []Key_Continuation {
+	found := make([dynamic]Key_Continuation, context.temp_allocator)
+	n := km.pending.len
+	if n == 0 || n >= MAX_KEY_SEQUENCE {
+		return found[:]
+	}
+	for b in km.bindings {
+		if b.sequence.len <= n ||
+		   b.command == "" ||
+		   !binding_applies(b, mode, language) ||
+		   !sequence_has_prefix(b.sequence, km.pending) {
+			continue
+		}
+		seen := false
+		for c in found {
+			if c.chord == b.sequence.chords[n] {
+				seen = true
+			}
+		}
+		if seen {
+			continue
+		}
+		next := km.pending
+		next.chords[n] = b.sequence.chords[n]
+		next.len = n + 1
+		k := Key_Continuation {
+			chord = next.chords[n],
+		}
+		if command, ok := lookup_binding(km, next, mode, language); ok {
+			k.command = command
+		}
+		for other in km.bindings {
+			if other.sequence.len > next.len &&
+			   other.command != "" &&
+			   binding_applies(other, mode, language) &&
+			   sequence_has_prefix(other.sequence, next) {
+				k.more += 1
+			}
+		}
+		if k.command != "" || k.more > 0 {
+			append(&found, k)
+		}
+	}
+	return found[:]
+}
+
+// Feeds one chord into the keymap.  When the chord extends a longer binding the
+// result is .Pending and the caller should show km.pending to the user.
+feed_key :: proc(
+	km: ^Keymap,
+	chord: Key_Chord,
+	mode, language: string,
+) -> (
+	result: Key_Result,
+	command: string,
+) {
+	if km.pending.len >= MAX_KEY_SEQUENCE {
+		km.pending = {}
+	}
+
+	km.pending.chords[km.pending.len] = chord
+	km.pending.len += 1
+
+	if has_continuation(km, km.pending, mode, language) {
+		return .Pending, ""
+	}
+
+	seq := km.pending
+	km.pending = {}
+
+	if cmd, found := lookup_binding(km, seq, mode, language); found {
+		return .Matched, cmd
+	}
+	return .Unbound, ""
+}
+
+cancel_pending_keys :: proc(km: ^Keymap) {
+	km.pending = {}
+}
+
+has_pending_keys :: proc(km: ^Keymap) -> bool {
+	return km.pending.len > 0
+}
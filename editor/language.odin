@@ -0,0 +1,474 @@
+package editor
+
+import "core:path/filepath"
+import "core:strings"
+
+Language :: struct {
+	id:            string,
+	name:          string,
+	extensions:    []string, // lower case, including the dot
+	filenames:     []string, // exact base names such as "Makefile"
+	line_comment:  string, // empty when the language only has block comments
+	block_comment: [2]string, // open / close, empty when unsupported
+	indent:        Indent_Rules,
+	prose:         bool, // sentences and paragraphs: lists continue and lines fill, see prose.odin
+	word_chars:    string, // besides letters, digits and _, for word motions
+}
+
+// Token based indentation rules.  Alphabetic tokens only match whole words.
+Indent_Rules :: struct {
+	indent_after: []string, // a line ending with one of these indents the next line
+	dedent_on:    []string, // a line starting with one of these is dedented itself
+	dedent_after: []string, // a line starting with one of these dedents the next line
+	use_spaces:   bool, // indent with spaces instead of tabs
+}
+
+LANGUAGES := [?]Language {
+	{
+		id = "odin",
+		name = "Odin",
+		extensions = {".odin"},
+		line_comment = "//",
+		block_comment = {"/*", "*/"},
+		indent = {indent_after = {"{", "(", "["}, dedent_on = {"}", ")", "]"}},
+	},
+	{
+		id = "go",
+		name = "Go",
+		extensions = {".go"},
+		line_comment = "//",
+		block_comment = {"/*", "*/"},
+		indent = {indent_after = {"{", "(", "["}, dedent_on = {"}", ")", "]"}},
+	},
+	{
+		id = "rust",
+		name = "Rust",
+		extensions = {".rs"},
+		line_comment = "//",
+		block_comment = {"/*", "*/"},
+		indent = {indent_after = {"{", "(", "["}, dedent_on = {"}", ")", "]"}, use_spaces = true},
+	},
+	{
+		id = "c",
+		name = "C",
+		extensions = {".c", ".h"},
+		line_comment = "//",
+		block_comment = {"/*", "*/"},
+		indent = {indent_after = {"{", "(", "["}, dedent_on = {"}", ")", "]"}},
+	},
+	{
+		id = "cpp",
+		name = "C++",
+		extensions = {".cpp", ".cc", ".cxx", ".hpp", ".hh", ".hxx"},
+		line_comment = "//",
+		block_comment = {"/*", "*/"},
+		indent = {indent_after = {"{", "(", "["}, dedent_on = {"}", ")", "]"}},
+	},
+	{
+		id = "glsl",
+		name = "GLSL",
+		extensions = {".glsl", ".vert", ".frag", ".comp"},
+		line_comment = "//",
+		block_comment = {"/*", "*/"},
+		indent = {indent_after = {"{", "(", "["}, dedent_on = {"}", ")", "]"}},
+	},
+	{
+		id = "javascript",
+		name = "JavaScript",
+		extensions = {".js", ".mjs", ".cjs", ".jsx"},
+		line_comment = "//",
+		word_chars = "$",
+		block_comment = {"/*", "*/"},
+		indent = {indent_after = {"{", "(", "["}, dedent_on = {"}", ")", "]"}, use_spaces = true},
+	},
+	{
+		id = "typescript",
+		name = "TypeScript",
+		extensions = {".ts", ".tsx"},
+		line_comment = "//",
+		word_chars = "$",
+		block_comment = {"/*", "*/"},
+		indent = {indent_after = {"{", "(", "["}, dedent_on = {"}", ")", "]"}, use_spaces = true},
+	},
+	{
+		id = "python",
+		name = "Python",
+		extensions = {".py", ".pyw"},
+		line_comment = "#",
+		block_comment = {`"""`, `"""`},
+		indent = {
+			indent_after = {":", "(", "[", "{"},
+			dedent_on = {")", "]", "},
+			dedent_after = {"return", "pass", "break", "continue", "raise"},
+			use_spaces = true,
+		},
+	},
+	{
+		id = "shellscript",
+		name = "Shell",
+		extensions = {".sh", ".bash", ".zsh"},
+		line_comment = "#",
+		indent = {
+			indent_after = {"then", "do", "else", "{"},
+			dedent_on = {"fi", "done", "else", "elif", "esac", "},
+		},
+	},
+	{
+		id = "lua",
+		name = "Lua",
+		extensions = {".lua"},
+		line_comment = "--",
+		block_comment = {"--[[", "]]"},
+		indent = {
+			indent_after = {"then", "do", "else", "repeat", "{", "("},
+			dedent_on = {"end", "else", "elseif", "until", "},
+		},
+	},
+	{
+		id = "toml",
+		name = "TOML",
+		extensions = {".toml"},
+		line_comment = "#",
+		indent = {indent_after = {"[", "{"}, dedent_on = {"]", "}"}},
+	},
+	{
+		id = "yaml",
+		name = "YAML",
+		extensions = {".yaml", ".yml"},
+		line_comment = "#",
+		indent = {indent_after = {":", "-"}, use_spaces = true},
+	},
+	{
+		id = "json",
+		name = "JSON",
+		extensions = {".json"},
+		indent = {indent_after = {"{", "(", "["}, dedent_on = {"}", ")", "]"}, use_spaces = true},
+	},
+	{
+		id = "css",
+		name = "CSS",
+		extensions = {".css"},
+		block_comment = {"/*", "*/"},
+		word_chars = "-",
+		indent = {indent_after = {"{", "(", "["}, dedent_on = {"}", ")", "]"}},
+	},
+	{
+		id = "html",
+		name = "HTML",
+		extensions = {".html", ".htm", ".xml", ".svg"},
+		block_comment = {"<!--", "-->"},
+		word_chars = "-",
+		indent = {use_spaces = true},
+	},
+	{
+		id = "markdown",
+		name = "Markdown",
+		extensions = {".md", ".markdown"},
+		block_comment = {"<!--", "-->"},
+		indent = {use_spaces = true},
+		prose = true,
+	},
+	{
+		id = "makefile",
+		name = "Makefile",
+		extensions = {".mk"},
+		filenames = {"Makefile", "makefile", "GNUmakefile"},
+		line_comment = "#",
+	},
+	{
+		id = "org",
+		name = "Org",
+		extensions = {".org"},
+		line_comment = "#",
+		indent = {use_spaces = true},
+		prose = true,
+	},
+	{id = "csv", name = "CSV", extensions = {".csv"}},
+	{id = "tsv", name = "TSV", extensions = {".tsv", ".tab"}},
+	{id = "log", name = "Log", extensions = {".log"}},
+	{id = "plaintext", name = "Plain Text", extensions = {".txt"}, prose = true},
+	{
+		id = "gitcommit",
+		name = "Git Commit Message",
+		filenames = {"COMMIT_EDITMSG"},
+		line_comment = "#",
+		prose = true,
+	},
+	// Buffers in hex view; never detected from a path.
+	{id = "hexdump", name = "Hex Dump"},
+	// Pages of help.topic; never detected from a path either.
+	{id = "help", name = "Help"},
+}
+
+PLAIN_TEXT_LANGUAGE_ID :: "plaintext"
+HEX_DUMP_LANGUAGE_ID :: "hexdump"
+GIT_COMMIT_LANGUAGE_ID :: "gitcommit"
+HELP_LANGUAGE_ID :: "help"
+
+find_language :: proc(id: string) -> ^Language {
+	for &lang in LANGUAGES {
+		if lang.id == id {
+			return &lang
+		}
+	}
+	return nil
+}
+
+// Picks a language from the file name or extension.  Falls back to plain text.
+detect_language_by_path :: proc(path: string) -> ^Language {
+	if lang := language_for_path(path); lang != nil {
+		return lang
+	}
+	return find_language(PLAIN_TEXT_LANGUAGE_ID)
+}
+
+// Picks a language for a file from, in order: a modeline in its text, its
+// name or extension, its #! line, then what the text looks like.  Falls back
+// to plain text.
+detect_language :: proc(path, text: string) -> ^Language {
+	if lang := language_from_modeline(text); lang != nil {
+		return lang
+	}
+	if lang := language_for_path(path); lang != nil {
+		return lang
+	}
+	if lang := language_from_shebang(text); lang != nil {
+		return lang
+	}
+	if lang := language_from_content(text); lang != nil {
+		return lang
+	}
+	return find_language(PLAIN_TEXT_LANGUAGE_ID)
+}
+
+@(private = "file")
+language_for_path :: proc(path: string) -> ^Language {
+	base := filepath.base(path)
+	ext := strings.to_lower(filepath.ext(path), context.temp_allocator)
+
+	for &lang in LANGUAGES {
+		for name in lang.filenames {
+			if name == base {
+				return &lang
+			}
+		}
+	}
+	if ext != "" {
+		for &lang in LANGUAGES {
+			for e in lang.extensions {
+				if e == ext {
+					return &lang
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Lines at the start and at the end of a file searched for a modeline, as vim
+// does by default.
+MODELINE_LINES :: 5
+
+// Names modelines and #! lines use for languages besides their ids, names and
+// extensions.
+@(private = "file")
+LANGUAGE_ALIASES := [?][2]string {
+	{"c++", "cpp"},
+	{"sh", "shellscript"},
+	{"bash", "shellscript"},
+	{"zsh", "shellscript"},
+	{"dash", "shellscript"},
+	{"ksh", "shellscript"},
+	{"ash", "shellscript"},
+	{"node", "javascript"},
+	{"nodejs", "javascript"},
+	{"deno", "javascript"},
+	{"bun", "javascript"},
+	{"ts-node", "typescript"},
+	{"luajit", "lua"},
+	{"make", "makefile"},
+	{"gmake", "makefile"},
+	{"xml", "html"},
+	{"text", "plaintext"},
+	{"conf", "plaintext"},
+	{"js", "javascript"},
+	{"ts", "typescript"},
+}
+
+// The language a name given by a modeline or #! line stands for: a language
+// id, name, extension without its dot or one of LANGUAGE_ALIASES, in any case.
+// Versions are ignored, so "python3.12" is Python.
+language_from_name :: proc(name: string) -> ^Language {
+	name := strings.to_lower(strings.trim_space(name), context.temp_allocator)
+	for name != "" && (name[len(name) - 1] == '.' || is_ascii_digit(name[len(name) - 1])) {
+		name = name[:len(name) - 1]
+	}
+	if name == "" {
+		return nil
+	}
+	for alias in LANGUAGE_ALIASES {
+		if alias[0] == name {
+			return find_language(alias[1])
+		}
+	}
+	for &lang in LANGUAGES {
+		if lang.id == name || strings.equal_fold(lang.name, name) {
+			return &lang
+		}
+		for e in lang.extensions {
+			if e[1:] == name {
+				return &lang
+			}
+		}
+	}
+	return nil
+}
+
+// The language an Emacs or vim modeline sets:
+//
+//	# -*- mode: python; coding: utf-8 -*-    or  -*- python -*-
+//	# vim: set ft=python ts=4:               or  vi: filetype=python
+//
+// Emacs only looks at the first line, or the second after a #! line; vim at
+// MODELINE_LINES lines at either end.
+@(private = "file")
+language_from_modeline :: proc(text: string) -> ^Language {
+	lines := strings.split_n(text[:min(len(text), 4096)], "\n", 3, context.temp_allocator)
+	for line, i in lines {
+		if i == 2 || (i == 1 && !strings.has_prefix(lines[0], "#!")) {
+			break
+		}
+		if lang := language_from_emacs_modeline(line); lang != nil {
+			return lang
+		}
+	}
+	head := strings.split_lines_n(
+		text[:min(len(text), 8192)],
+		MODELINE_LINES + 1,
+		context.temp_allocator,
+	)
+	for line, i in head {
+		if i < MODELINE_LINES {
+			if lang := language_from_vim_modeline(line); lang != nil {
+				return lang
+			}
+		}
+	}
+	tail := text[max(len(text) - 8192, 0):]
+	tail_lines := strings.split_lines(strings.trim_right(tail, "\n"), context.temp_allocator)
+	for line in tail_lines[max(len(tail_lines) - MODELINE_LINES, 0):] {
+		if lang := language_from_vim_modeline(line); lang != nil {
+			return lang
+		}
+	}
+	return nil
+}
+
+@(private = "file")
+language_from_emacs_modeline :: proc(line: string) -> ^Language {
+	start := strings.index(line, "-*-")
+	if start < 0 {
+		return nil
+	}
+	rest := line[start + 3:]
+	end := strings.index(rest, "-*-")
+	if end < 0 {
+		return nil
+	}
+	vars := strings.trim_space(rest[:end])
+	if !strings.contains_rune(vars, ':') {
+		return language_from_name(vars) // -*- python -*-
+	}
+	for part in strings.split(vars, ";", context.temp_allocator) {
+		key, _, value := strings.partition(part, ":")
+		if strings.equal_fold(strings.trim_space(key), "mode") {
+			return language_from_name(value)
+		}
+	}
+	return nil
+}
+
+@(private = "file")
+language_from_vim_modeline :: proc(line: string) -> ^Language {
+	rest: string
+	for marker in ([]string{"vim:", "vi:", "ex:"}) {
+		if at := strings.index(line, marker); at >= 0 {
+			// "vi:" must start a word, or "navi:" would count.
+			if at == 0 || line[at - 1] == ' ' || line[at - 1] == '\t' {
+				rest = line[at + len(marker):]
+				break
+			}
+		}
+	}
+	if rest == "" {
+		return nil
+	}
+	rest = strings.trim_space(rest)
+	if strings.has_prefix(rest, "set ") || strings.has_prefix(rest, "se ") {
+		_, _, rest = strings.partition(rest, " ")
+		rest, _, _ = strings.partition(rest, ":") // set form ends at a colon
+	}
+	for field in strings.fields(strings.trim_space(rest), context.temp_allocator) {
+		for option in strings.split(field, ":", context.temp_allocator) {
+			key, _, value := strings.partition(option, "=")
+			if key == "ft" || key == "filetype" || key == "syntax" || key == "syn" {
+				return language_from_name(value)
+			}
+		}
+	}
+	return nil
+}
+
+// The language of the interpreter a #! line runs, through env or not:
+// "#!/usr/bin/env -S python3 -u" is Python.
+@(private = "file")
+language_from_shebang :: proc(text: string) -> ^Language {
+	if !strings.has_prefix(text, "#!") {
+		return nil
+	}
+	line, _, _ := strings.partition(text[2:min(len(text), 512)], "\n")
+	words := strings.fields(line, context.temp_allocator)
+	for len(words) > 0 {
+		program := filepath.base(words[0])
+		words = words[1:]
+		if program == "env" || strings.has_prefix(program, "-") {
+			continue
+		}
+		return language_from_name(program)
+	}
+	return nil
+}
+
+// What the start of text looks like: an XML or HTML document, JSON, or a
+// YAML document.
+@(private = "file")
+language_from_content :: proc(text: string) -> ^Language {
+	head := strings.trim_left_space(text[:min(len(text), 4096)])
+	lower := strings.to_lower(head[:min(len(head), 16)], context.temp_allocator)
+	for prefix in ([]string{"<?xml", "<!doctype html", "<html"}) {
+		if strings.has_prefix(lower, prefix) {
+			return find_language("html")
+		}
+	}
+	if strings.has_prefix(head, "{") {
+		// An object's first key, or an empty object.
+		rest := strings.trim_left_space(head[1:])
+		if strings.has_prefix(rest, "\"") || strings.has_prefix(rest, "}") {
+			return find_language("json")
+		}
+	}
+	if strings.has_prefix(head, "[") && len(text) <= 1024 * 1024 {
+		if _, _, ok := parse_json_tree(text); ok {
+			return find_language("json")
+		}
+	}
+	if strings.has_prefix(text, "%YAML") || strings.has_prefix(text, "---\n") {
+		return find_language("yaml")
+	}
+	return nil
+}
+
+@(private = "file")
+is_ascii_digit :: proc(c: u8) -> bool {
+	return c >= '0' && c <= '9'
+}
@@ -2,7 +2,7 @@ package editor
 
 import "core:mem"
 import "core:sort"
-import "core:unicode/utf8"
+import "core:strings"
 
 Layer_Kind :: enum u8 {
 	Custom,
@@ -168,6 +168,9 @@ Text_Layer_Data :: struct {
 	buffer:      ^Gap_Buffer,
 	font:        ^Font_Handle,
 	text_color:  [4]f32,
+	dim_color:   [4]f32,
+	dim:         bool, // draw the lines outside focus in dim_color
+	focus:       [2]int, // first and last line drawn in text_color
 	line_height: f32,
 	padding:     [2]f32,
 }
@@ -184,6 +187,7 @@ make_text_layer :: proc(
 	data.buffer = buffer
 	data.font = font
 	data.text_color = text_color
+	data.dim_color = text_color
 	data.line_height = line_height
 	data.padding = padding
 
@@ -215,28 +219,41 @@ make_text_layer :: proc(
 
 				line_str := get_line(d.buffer, line_idx)
 				defer delete(line_str)
+				color := d.text_color
+				if d.dim && (line_idx < d.focus[0] || line_idx > d.focus[1]) {
+					color = d.dim_color
+				}
 
-				pen_x := d.padding[0] - lctx.scroll_x
+				// Characters sit on a grid of space-wide cells, the same grid
+				// the cursor and selections use; wide ones take two cells.
+				cell_w := get_glyph(atlas, d.font, ' ').advance_x
+				line_x := d.padding[0] - lctx.scroll_x
 				visual_col := 0
 				i := 0
 				for i < len(line_str) {
-					r, size := utf8.decode_rune_in_string(line_str[i:])
-					i += size
-
-					if r == '\t' {
-						space_w := get_glyph(atlas, d.font, ' ').advance_x
-						next_stop := (visual_col / lctx.tab_size + 1) * lctx.tab_size
-						pen_x += f32(next_stop - visual_col) * space_w
-						visual_col = next_stop
+					next := next_grapheme(line_str, i)
+					cluster := line_str[i:next]
+					i = next
+
+					if cluster == "\t" {
+						visual_col = (visual_col / lctx.tab_size + 1) * lctx.tab_size
 						continue
 					}
 
-					info := get_glyph(atlas, d.font, r)
-					if info.size[0] > 0 {
-						push_glyph(br, pen_x, pen_y + d.font.ascent, info, d.text_color)
+					// The base and its combining marks; the marks have no
+					// advance of their own and are positioned by the font.
+					pen_x := line_x + f32(visual_col) * cell_w
+					for r in cluster {
+						if r == 0x200d {
+							break // joined emoji: only the first part is drawn
+						}
+						info := get_glyph(atlas, d.font, r)
+						if info.size[0] > 0 {
+							push_glyph(br, pen_x, pen_y + d.font.ascent, info, color)
+						}
+						pen_x += info.advance_x
 					}
-					pen_x += info.advance_x
-					visual_col += 1
+					visual_col += grapheme_width(cluster)
 				}
 
 				pen_y += d.line_height
@@ -245,6 +262,95 @@ make_text_layer :: proc(
 	}
 }
 
+// Text drawn after the end of a line without being part of the buffer, e.g.
+// a blame annotation.
+Virtual_Text :: struct {
+	line: int,
+	text: string,
+}
+
+Virtual_Text_Layer_Data :: struct {
+	buffer:      ^Gap_Buffer,
+	font:        ^Font_Handle,
+	color:       [4]f32,
+	line_height: f32,
+	padding:     [2]f32,
+	items:       [dynamic]Virtual_Text,
+	allocator:   mem.Allocator,
+}
+
+// Replaces the virtual text with a copy of items.
+set_virtual_text :: proc(d: ^Virtual_Text_Layer_Data, items: []Virtual_Text) {
+	for item in d.items {
+		delete(item.text, d.allocator)
+	}
+	clear(&d.items)
+	for item in items {
+		append(&d.items, Virtual_Text{item.line, strings.clone(item.text, d.allocator)})
+	}
+}
+
+make_virtual_text_layer :: proc(
+	buffer: ^Gap_Buffer,
+	font: ^Font_Handle,
+	color: [4]f32,
+	line_height: f32,
+	padding: [2]f32,
+	allocator: mem.Allocator = context.allocator,
+) -> Layer {
+	data := new(Virtual_Text_Layer_Data, allocator)
+	data.buffer = buffer
+	data.font = font
+	data.color = color
+	data.line_height = line_height
+	data.padding = padding
+	data.items = make([dynamic]Virtual_Text, allocator)
+	data.allocator = allocator
+
+	return Layer {
+		kind = .Decorations,
+		z_index = 5,
+		enabled = true,
+		name = "virtual_text",
+		user_data = data,
+		draw = proc(
+			layer: ^Layer,
+			br: ^Batch_Renderer,
+			atlas: ^Glyph_Atlas,
+			lctx: ^Layer_Context,
+		) {
+			d := cast(^Virtual_Text_Layer_Data)layer.user_data
+			cell_w := get_glyph(atlas, d.font, ' ').advance_x
+			for item in d.items {
+				if item.line >= get_line_count(d.buffer) {
+					continue
+				}
+				y := d.padding[1] + f32(item.line) * d.line_height - lctx.scroll_y
+				if y + d.line_height < 0 || y > lctx.viewport[1] {
+					continue
+				}
+				end := get_line_length(d.buffer, item.line)
+				col := get_visual_col(
+					d.buffer,
+					item.line,
+					end,
+					lctx.tab_size,
+					context.temp_allocator,
+				)
+				x := d.padding[0] - lctx.scroll_x + f32(col + 4) * cell_w
+				push_text(br, atlas, d.font, x, y, item.text, d.color)
+			}
+		},
+		on_destroy = proc(layer: ^Layer) {
+			d := cast(^Virtual_Text_Layer_Data)layer.user_data
+			for item in d.items {
+				delete(item.text, d.allocator)
+			}
+			delete(d.items)
+		},
+	}
+}
+
 Selection :: struct {
 	start_line: int,
 	start_col:  int,
@@ -313,6 +419,64 @@ make_selection_layer :: proc(
 	}
 }
 
+// Thin vertical lines at text columns, e.g. at a line length limit.
+Ruler_Layer_Data :: struct {
+	columns:    [dynamic]int,
+	band:       bool, // shade the column's cells, as Vim's colorcolumn, instead of a line after it
+	color:      [4]f32,
+	char_width: f32,
+	padding:    [2]f32,
+}
+
+make_ruler_layer :: proc(
+	char_width: f32,
+	padding: [2]f32,
+	color: [4]f32,
+	allocator: mem.Allocator = context.allocator,
+) -> Layer {
+	data := new(Ruler_Layer_Data, allocator)
+	data.columns = make([dynamic]int, allocator)
+	data.color = color
+	data.char_width = char_width
+	data.padding = padding
+
+	return Layer {
+		kind = .Background,
+		z_index = -50,
+		enabled = true,
+		name = "rulers",
+		user_data = data,
+		draw = proc(
+			layer: ^Layer,
+			br: ^Batch_Renderer,
+			atlas: ^Glyph_Atlas,
+			lctx: ^Layer_Context,
+		) {
+			d := cast(^Ruler_Layer_Data)layer.user_data
+			for col in d.columns {
+				x := d.padding[0] + f32(col) * d.char_width - lctx.scroll_x
+				w: f32 = 1
+				if d.band {
+					x -= d.char_width
+					w = d.char_width
+				}
+				if x >= d.padding[0] && x < lctx.viewport[0] {
+					push_rect(br, x, 0, w, lctx.viewport[1], d.color)
+				}
+			}
+		},
+		on_destroy = proc(layer: ^Layer) {
+			d := cast(^Ruler_Layer_Data)layer.user_data
+			delete(d.columns)
+		},
+	}
+}
+
+set_rulers :: proc(d: ^Ruler_Layer_Data, columns: []int) {
+	clear(&d.columns)
+	append(&d.columns, ..columns)
+}
+
 Cursor_Layer_Data :: struct {
 	line:        int,
 	col:         int,
@@ -359,14 +523,109 @@ make_cursor_layer :: proc(
 	}
 }
 
+// Shades the cursor's line across the window and, when column is set, its
+// column down the window, under the text and the rulers.
+Cursor_Line_Layer_Data :: struct {
+	cursor:       ^Cursor_Layer_Data,
+	line:         bool,
+	column:       bool,
+	line_color:   [4]f32,
+	column_color: [4]f32,
+}
+
+make_cursor_line_layer :: proc(
+	cursor: ^Cursor_Layer_Data,
+	line_color: [4]f32,
+	column_color: [4]f32,
+	allocator: mem.Allocator = context.allocator,
+) -> Layer {
+	data := new(Cursor_Line_Layer_Data, allocator)
+	data.cursor = cursor
+	data.line_color = line_color
+	data.column_color = column_color
+
+	return Layer {
+		kind = .Background,
+		z_index = -60,
+		enabled = true,
+		name = "cursor_line",
+		user_data = data,
+		draw = proc(
+			layer: ^Layer,
+			br: ^Batch_Renderer,
+			atlas: ^Glyph_Atlas,
+			lctx: ^Layer_Context,
+		) {
+			d := cast(^Cursor_Line_Layer_Data)layer.user_data
+			c := d.cursor
+			if d.line {
+				y := c.padding[1] + f32(c.line) * c.line_height - lctx.scroll_y
+				push_rect(br, 0, y, lctx.viewport[0], c.line_height, d.line_color)
+			}
+			if d.column {
+				x := c.padding[0] + f32(c.visual_col) * c.char_width - lctx.scroll_x
+				if x >= c.padding[0] {
+					push_rect(br, x, 0, c.char_width, lctx.viewport[1], d.column_color)
+				}
+			}
+		},
+	}
+}
+
+// Sources of gutter marks.  Each has its own column at the left edge of the
+// gutter and replaces only its own marks.
+Gutter_Lane :: enum {
+	Bookmark,
+	Change, // lines changed since the last commit
+	Diagnostic, // problems reported by a task
+	Test, // test results
+}
+
+// A colored bar at the left edge of the gutter, e.g. for a bookmark.
+Gutter_Mark :: struct {
+	line:    int,
+	color:   [4]f32,
+	lane:    Gutter_Lane,
+	between: bool, // a notch on the line's top edge instead of a bar, e.g. for deleted lines
+}
+
+// How the gutter numbers lines: relative numbers count from the cursor's line
+// for counted motions; hybrid ones show the cursor's own line absolute.
+Line_Number_Mode :: enum u8 {
+	Absolute,
+	Relative,
+	Hybrid,
+}
+
 Line_Number_Layer_Data :: struct {
-	buffer:      ^Gap_Buffer,
-	font:        ^Font_Handle,
-	fg_color:    [4]f32,
-	bg_color:    [4]f32,
-	gutter_w:    f32,
-	line_height: f32,
-	padding_top: f32,
+	buffer:        ^Gap_Buffer,
+	font:          ^Font_Handle,
+	mode:          Line_Number_Mode,
+	cursor:        ^Cursor_Layer_Data, // for the cursor's line; nil numbers every line alike
+	fg_color:      [4]f32,
+	current_color: [4]f32, // the cursor's line number
+	bg_color:      [4]f32,
+	gutter_w:      f32,
+	line_height:   f32,
+	padding_top:   f32,
+	marks:         [dynamic]Gutter_Mark,
+}
+
+// Replaces the marks of lane with a copy of marks.
+set_gutter_marks :: proc(d: ^Line_Number_Layer_Data, lane: Gutter_Lane, marks: []Gutter_Mark) {
+	kept := 0
+	for m in d.marks {
+		if m.lane != lane {
+			d.marks[kept] = m
+			kept += 1
+		}
+	}
+	resize(&d.marks, kept)
+	for m in marks {
+		m := m
+		m.lane = lane
+		append(&d.marks, m)
+	}
 }
 
 make_line_number_layer :: proc(
@@ -383,10 +642,12 @@ make_line_number_layer :: proc(
 	data.buffer = buffer
 	data.font = font
 	data.fg_color = fg_color
+	data.current_color = fg_color
 	data.bg_color = bg_color
 	data.gutter_w = gutter_w
 	data.line_height = line_height
 	data.padding_top = padding_top
+	data.marks = make([dynamic]Gutter_Mark, allocator)
 
 	return Layer {
 		kind = .Overlay,
@@ -404,8 +665,22 @@ make_line_number_layer :: proc(
 
 			push_rect(br, 0, 0, d.gutter_w, lctx.viewport[1], d.bg_color)
 
+			for m in d.marks {
+				y := d.padding_top - lctx.scroll_y + f32(m.line) * d.line_height
+				x := 2 + f32(m.lane) * 6
+				if y + d.line_height < 0 || y > lctx.viewport[1] {
+					continue
+				}
+				if m.between {
+					push_rect(br, x, y - 2, 6, 4, m.color)
+				} else {
+					push_rect(br, x, y + 2, 4, d.line_height - 4, m.color)
+				}
+			}
+
 			line_count := get_line_count(d.buffer)
 			pen_y := d.padding_top - lctx.scroll_y
+			current := d.cursor.line if d.cursor != nil else -1
 
 			for ln in 0 ..< line_count {
 				if pen_y + d.line_height < 0 {
@@ -417,18 +692,27 @@ make_line_number_layer :: proc(
 				}
 
 				num := ln + 1
+				relative := d.mode == .Relative || (d.mode == .Hybrid && ln != current)
+				if relative && current >= 0 {
+					num = abs(ln - current)
+				}
+				color := d.current_color if ln == current else d.fg_color
 				buf: [16]u8
 				s := fmt_int_buf(buf[:], num)
 
 				pen_x := d.gutter_w - f32(len(s)) * get_glyph(atlas, d.font, '0').advance_x
 				for r in s {
 					info := get_glyph(atlas, d.font, r)
-					push_glyph(br, pen_x, pen_y + d.font.ascent, info, d.fg_color)
+					push_glyph(br, pen_x, pen_y + d.font.ascent, info, color)
 					pen_x += info.advance_x
 				}
 				pen_y += d.line_height
 			}
 		},
+		on_destroy = proc(layer: ^Layer) {
+			d := cast(^Line_Number_Layer_Data)layer.user_data
+			delete(d.marks)
+		},
 	}
 }
 
@@ -456,7 +740,7 @@ decode_rune_at :: proc(s: string, i: int) -> (r: rune, size: int) {
 	return 0xFFFD, 1
 }
 
-@(private = "file")
+@(private)
 fmt_int_buf :: proc(buf: []u8, n: int) -> string {
 	if n == 0 {
 		buf[len(buf) - 1] = '0'
@@ -471,3 +755,96 @@ fmt_int_buf :: proc(buf: []u8, n: int) -> string {
 	}
 	return string(buf[i:])
 }
+
+// Pushes a single line of text with its line box top at y.  Returns the pen x
+// position after the last glyph.
+push_text :: proc(
+	br: ^Batch_Renderer,
+	atlas: ^Glyph_Atlas,
+	font: ^Font_Handle,
+	x, y: f32,
+	s: string,
+	color: [4]f32,
+) -> f32 {
+	pen_x := x
+	for r in s {
+		info := get_glyph(atlas, font, r)
+		if info.size[0] > 0 {
+			push_glyph(br, pen_x, y + font.ascent, info, color)
+		}
+		pen_x += info.advance_x
+	}
+	return pen_x
+}
+
+measure_text :: proc(atlas: ^Glyph_Atlas, font: ^Font_Handle, s: string) -> f32 {
+	w: f32
+	for r in s {
+		w += get_glyph(atlas, font, r).advance_x
+	}
+	return w
+}
+
+Status_Line_Data :: struct {
+	font:        ^Font_Handle,
+	fg_color:    [4]f32,
+	bg_color:    [4]f32,
+	line_height: f32,
+	padding_x:   f32,
+	left:        strings.Builder,
+	right:       strings.Builder,
+}
+
+make_status_line_layer :: proc(
+	font: ^Font_Handle,
+	line_height: f32,
+	padding_x: f32,
+	fg_color: [4]f32,
+	bg_color: [4]f32,
+	allocator: mem.Allocator = context.allocator,
+) -> Layer {
+	data := new(Status_Line_Data, allocator)
+	data.font = font
+	data.fg_color = fg_color
+	data.bg_color = bg_color
+	data.line_height = line_height
+	data.padding_x = padding_x
+	data.left = strings.builder_make(allocator)
+	data.right = strings.builder_make(allocator)
+
+	return Layer {
+		kind = .Overlay,
+		z_index = 200,
+		enabled = true,
+		name = "status_line",
+		user_data = data,
+		draw = proc(
+			layer: ^Layer,
+			br: ^Batch_Renderer,
+			atlas: ^Glyph_Atlas,
+			lctx: ^Layer_Context,
+		) {
+			d := cast(^Status_Line_Data)layer.user_data
+			y := lctx.viewport[1] - d.line_height
+			push_rect(br, 0, y, lctx.viewport[0], d.line_height, d.bg_color)
+
+			push_text(br, atlas, d.font, d.padding_x, y, strings.to_string(d.left), d.fg_color)
+
+			right := strings.to_string(d.right)
+			rx := lctx.viewport[0] - d.padding_x - measure_text(atlas, d.font, right)
+			push_text(br, atlas, d.font, rx, y, right, d.fg_color)
+		},
+		on_destroy = proc(layer: ^Layer) {
+			d := cast(^Status_Line_Data)layer.user_data
+			strings.builder_destroy(&d.left)
+			strings.builder_destroy(&d.right)
+		},
+	}
+}
+
+set_status_line :: proc(d: ^Status_Line_Data, left, right: string) {
+	strings.builder_reset(&d.left)
+	strings.write_string(&d.left, left)
+	strings.builder_reset(&d.right)
+	strings.write_string(&d.right, right)
+}
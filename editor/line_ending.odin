@@ -0,0 +1,82 @@
+package editor
+
+import "core:strings"
+
+// Buffers always use "\n"; the file's own line ending is restored on save.
+Line_Ending :: enum {
+	LF,
+	CRLF,
+	CR,
+}
+
+LINE_ENDING_NAMES := [Line_Ending]string {
+	.LF   = "LF",
+	.CRLF = "CRLF",
+	.CR   = "CR",
+}
+
+// The most common line ending in text.  Text without line breaks counts as
+// LF.
+detect_line_ending :: proc(text: string) -> Line_Ending {
+	counts: [Line_Ending]int
+	for i := 0; i < len(text); i += 1 {
+		switch text[i] {
+		case '\n':
+			counts[.LF] += 1
+		case '\r':
+			if i + 1 < len(text) && text[i + 1] == '\n' {
+				counts[.CRLF] += 1
+				i += 1
+			} else {
+				counts[.CR] += 1
+			}
+		}
+	}
+	best := Line_Ending.LF
+	for n, e in counts {
+		if n > counts[best] {
+			best = e
+		}
+	}
+	return best
+}
+
+// Converts every CRLF and lone CR in text to LF.  Returns text itself when it
+// has none.
+normalize_line_endings :: proc(text: string, allocator := context.allocator) -> string {
+	if !strings.contains_rune(text, '\r') {
+		return text
+	}
+	b := strings.builder_make(0, len(text), allocator)
+	for i := 0; i < len(text); i += 1 {
+		if text[i] != '\r' {
+			strings.write_byte(&b, text[i])
+			continue
+		}
+		strings.write_byte(&b, '\n')
+		if i + 1 < len(text) && text[i + 1] == '\n' {
+			i += 1
+		}
+	}
+	return strings.to_string(b)
+}
+
+// Converts the LF line endings of buffer text to ending.  Returns text itself
+// for LF.
+convert_line_endings :: proc(
+	text: string,
+	ending: Line_Ending,
+	allocator := context.allocator,
+) -> string {
+	switch ending {
+	case .LF:
+		return text
+	case .CRLF:
+		s, _ := strings.replace_all(text, "\n", "\r\n", allocator)
+		return s
+	case .CR:
+		s, _ := strings.replace_all(text, "\n", "\r", allocator)
+		return s
+	}
+	return text
+}
@@ -0,0 +1,145 @@
+package editor
+
+import "core:encoding/json"
+import "core:fmt"
+import "core:mem"
+import "core:strings"
+
+Lint_Level :: enum u8 {
+	Error,
+	Warning,
+	Info,
+}
+
+// A problem a linter reported.
+Lint_Problem :: struct {
+	file:    string, // as the linter wrote it
+	line:    int, // 1 based, 0 when unknown
+	col:     int, // 1 based, 0 when unknown
+	message: string,
+	level:   Lint_Level,
+}
+
+// The JSON reports parse_lint_json reads, by the linter that writes them.
+LINT_JSON_PARSERS := [?]string{"eslint", "ruff", "shellcheck"}
+
+// Reads the JSON report of a linter run with the matching option:
+//
+//	eslint      eslint --format json
+//	ruff        ruff check --output-format json
+//	shellcheck  shellcheck --format json, or json1
+//
+// Text before the report, e.g. a warning on stderr, is skipped.  Everything
+// is allocated with allocator, so parse into an arena or the temp allocator.
+parse_lint_json :: proc(
+	parser, output: string,
+	allocator: mem.Allocator = context.temp_allocator,
+) -> (
+	problems: []Lint_Problem,
+	ok: bool,
+) {
+	start := strings.index_any(output, "[{")
+	if start < 0 {
+		return nil, strings.trim_space(output) == "" // nothing to report
+	}
+	value, err := json.parse_string(output[start:], allocator = allocator)
+	if err != nil {
+		return nil, false
+	}
+	list := make([dynamic]Lint_Problem, allocator)
+	switch parser {
+	case "eslint":
+		for file in json_array(value) {
+			path := json_string(file, "filePath")
+			for m in json_array(json_field(file, "messages")) {
+				message := json_string(m, "message")
+				p := Lint_Problem {
+					file    = path,
+					line    = json_int(m, "line"),
+					col     = json_int(m, "column"),
+					message = with_rule(message, json_string(m, "ruleId"), allocator),
+					level   = .Error if json_int(m, "severity") >= 2 else .Warning,
+				}
+				append(&list, p)
+			}
+		}
+	case "ruff":
+		for m in json_array(value) {
+			location := json_field(m, "location")
+			p := Lint_Problem {
+				file    = json_string(m, "filename"),
+				line    = json_int(location, "row"),
+				col     = json_int(location, "column"),
+				message = with_rule(json_string(m, "message"), json_string(m, "code"), allocator),
+				level   = .Warning,
+			}
+			append(&list, p)
+		}
+	case "shellcheck":
+		comments := json_array(value)
+		if _, is_object := value.(json.Object); is_object {
+			comments = json_array(json_field(value, "comments")) // json1
+		}
+		for m in comments {
+			level := Lint_Level.Info
+			switch json_string(m, "level") {
+			case "error":
+				level = .Error
+			case "warning":
+				level = .Warning
+			}
+			code := fmt.aprintf("SC%d", json_int(m, "code"), allocator = allocator)
+			p := Lint_Problem {
+				file    = json_string(m, "file"),
+				line    = json_int(m, "line"),
+				col     = json_int(m, "column"),
+				message = with_rule(json_string(m, "message"), code, allocator),
+				level   = level,
+			}
+			append(&list, p)
+		}
+	case:
+		return nil, false
+	}
+	return list[:], true
+}
+
+@(private = "file")
+with_rule :: proc(message, rule: string, allocator: mem.Allocator) -> string {
+	if rule == "" {
+		return message
+	}
+	return strings.concatenate({message, " [", rule, "]"}, allocator)
+}
+
+@(private)
+json_field :: proc(v: json.Value, key: string) -> json.Value {
+	if object, ok := v.(json.Object); ok {
+		return object[key]
+	}
+	return nil
+}
+
+@(private)
+json_array :: proc(v: json.Value) -> []json.Value {
+	if array, ok := v.(json.Array); ok {
+		return array[:]
+	}
+	return nil
+}
+
+@(private)
+json_string :: proc(v: json.Value, key: string) -> string {
+	return json_field(v, key).(json.String) or_else ""
+}
+
+@(private)
+json_int :: proc(v: json.Value, key: string) -> int {
+	#partial switch n in json_field(v, key) {
+	case json.Integer:
+		return int(n)
+	case json.Float:
+		return int(n)
+	}
+	return 0
+}
@@ -0,0 +1,563 @@
+package editor
+
+import "core:mem"
+import "core:strings"
+import "core:text/regex"
+import "core:unicode/utf8"
+
+// Cells of the line numbers left of the log lines.
+LOG_LINE_NUMBER_WIDTH :: 7
+
+// How far into a line its level is looked for, past the timestamp.
+LOG_LEVEL_SEARCH :: 80
+
+Log_Level :: enum u8 {
+	None,
+	Trace,
+	Debug,
+	Info,
+	Warning,
+	Error,
+	Fatal,
+}
+
+@(private = "file")
+LOG_LEVEL_WORDS := [?]struct {
+	word:  string,
+	level: Log_Level,
+} {
+	{"TRACE", .Trace},
+	{"DEBUG", .Debug},
+	{"DBG", .Debug},
+	{"INFO", .Info},
+	{"NOTICE", .Info},
+	{"WARN", .Warning},
+	{"WARNING", .Warning},
+	{"ERROR", .Error},
+	{"ERR", .Error},
+	{"FATAL", .Fatal},
+	{"CRITICAL", .Fatal},
+	{"CRIT", .Fatal},
+	{"PANIC", .Fatal},
+}
+
+// The severity a log line states and the byte range of the word stating it.
+// The word is one of LOG_LEVEL_WORDS: in capitals anywhere near the start,
+// or in any case when it reads like a field: "[warn]", "error:", "level=info".
+log_line_level :: proc(line: string) -> (level: Log_Level, start, end: int) {
+	limit := min(len(line), log_timestamp_len(line) + LOG_LEVEL_SEARCH)
+	for i := 0; i < limit; {
+		if !is_log_word_byte(line[i]) {
+			i += 1
+			continue
+		}
+		j := i
+		for j < len(line) && is_log_word_byte(line[j]) {
+			j += 1
+		}
+		word := line[i:j]
+		for w in LOG_LEVEL_WORDS {
+			if !strings.equal_fold(word, w.word) {
+				continue
+			}
+			before := line[i - 1] if i > 0 else ' '
+			after := line[j] if j < len(line) else ' '
+			field := before == '[' || before == '=' || after == ':' || after == ']'
+			if word == w.word || field {
+				return w.level, i, j
+			}
+		}
+		i = j
+	}
+	return .None, 0, 0
+}
+
+// Bytes of the timestamp a log line starts with, brackets included: ISO 8601
+// and the like ("2024-05-01 12:00:03,512", "[12:00:03.5]") or syslog's
+// "May  1 12:00:03".  0 when it has none.
+log_timestamp_len :: proc(line: string) -> int {
+	start := 1 if strings.has_prefix(line, "[") else 0
+	i := start
+	if i + 3 <= len(line) && is_log_month(line[i:i + 3]) {
+		i += 3
+		for i < len(line) && line[i] == ' ' {
+			i += 1
+		}
+	}
+	colons, dashes := 0, 0
+	for i < len(line) {
+		c := line[i]
+		if c == ' ' || c == 'T' {
+			// Between the date and the time, so only once and before a digit.
+			if colons > 0 || i + 1 >= len(line) || !is_digit(line[i + 1]) {
+				break
+			}
+		} else if c == ':' {
+			colons += 1
+		} else if c == '-' {
+			dashes += 1
+		} else if c == 'Z' {
+			i += 1
+			break
+		} else if !is_digit(c) && !strings.contains_rune(".,/+", rune(c)) {
+			break
+		}
+		i += 1
+	}
+	for i > start && strings.contains_rune("-:.,/+", rune(line[i - 1])) {
+		i -= 1
+	}
+	switch {
+	case colons < 2 && dashes < 2:
+		return 0
+	case start == 0:
+		return i
+	case i < len(line) && line[i] == ']':
+		return i + 1
+	}
+	return 0
+}
+
+// A log file shown over the editor: each line with its number, timestamp
+// and level in their colours, lines left out by the filter folded into one
+// row, and the view following the end of the file as it grows.
+Log_View_Data :: struct {
+	font:          ^Font_Handle,
+	visible:       bool,
+	line_height:   f32,
+	bottom_margin: f32, // space reserved below the view (status line)
+	title:         string,
+	text:          strings.Builder, // what was read so far
+	starts:        [dynamic]int, // of the lines in text
+	shown:         [dynamic]bool, // per line: kept by the filter or unfolded
+	rows:          [dynamic]Log_Row,
+	filter:        string, // a regex; lines not matching it are folded
+	invert:        bool, // fold the lines matching filter instead
+	re:            regex.Regular_Expression,
+	has_re:        bool,
+	row:           int, // the cursor row
+	scroll:        int, // first row shown
+	scroll_col:    int, // cells scrolled off the left
+	follow:        bool, // stay on the last line as lines are added
+	page_rows:     int, // rows that fit, as of the last draw
+	fg_color:      [4]f32,
+	dim_color:     [4]f32,
+	bg_color:      [4]f32,
+	title_color:   [4]f32,
+	cursor_color:  [4]f32,
+	time_color:    [4]f32,
+	level_colors:  [Log_Level][4]f32,
+	allocator:     mem.Allocator,
+}
+
+// A line of the log, or a run of count folded lines from line.
+Log_Row :: struct {
+	line:  int,
+	count: int, // 0 for a line
+}
+
+make_log_view_layer :: proc(
+	font: ^Font_Handle,
+	line_height: f32,
+	bottom_margin: f32,
+	allocator: mem.Allocator = context.allocator,
+) -> Layer {
+	data := new(Log_View_Data, allocator)
+	data.font = font
+	data.line_height = line_height
+	data.bottom_margin = bottom_margin
+	data.text = strings.builder_make(allocator)
+	data.starts = make([dynamic]int, allocator)
+	data.shown = make([dynamic]bool, allocator)
+	data.rows = make([dynamic]Log_Row, allocator)
+	data.page_rows = 1
+	data.fg_color = {0.92, 0.91, 0.88, 1.0}
+	data.dim_color = {0.45, 0.45, 0.50, 1.0}
+	data.bg_color = {0.12, 0.12, 0.14, 1.0}
+	data.title_color = {0.16, 0.16, 0.19, 1.0}
+	data.cursor_color = {0.25, 0.35, 0.55, 0.5}
+	data.time_color = {0.45, 0.70, 0.72, 1.0}
+	data.level_colors = {
+		.None    = data.fg_color,
+		.Trace   = {0.50, 0.50, 0.55, 1.0},
+		.Debug   = {0.60, 0.60, 0.68, 1.0},
+		.Info    = {0.45, 0.75, 0.45, 1.0},
+		.Warning = {0.95, 0.75, 0.30, 1.0},
+		.Error   = {0.95, 0.40, 0.40, 1.0},
+		.Fatal   = {1.00, 0.30, 0.70, 1.0},
+	}
+	data.allocator = allocator
+
+	return Layer {
+		kind = .Overlay,
+		z_index = 140,
+		enabled = true,
+		name = "log_view",
+		user_data = data,
+		draw = proc(
+			layer: ^Layer,
+			br: ^Batch_Renderer,
+			atlas: ^Glyph_Atlas,
+			lctx: ^Layer_Context,
+		) {
+			d := cast(^Log_View_Data)layer.user_data
+			if !d.visible {
+				return
+			}
+			w := lctx.viewport[0]
+			h := lctx.viewport[1] - d.bottom_margin
+			lh := d.line_height
+			push_rect(br, 0, 0, w, h, d.bg_color)
+			push_rect(br, 0, 0, w, lh, d.title_color)
+			push_text(br, atlas, d.font, 8, 0, d.title, d.fg_color)
+			status := log_view_status(d)
+			status_x := w - 8 - measure_text(atlas, d.font, status)
+			push_text(br, atlas, d.font, status_x, 0, status, d.dim_color)
+
+			d.page_rows = max(int((h - lh) / lh), 1)
+			d.scroll = min(d.scroll, max(len(d.rows) - d.page_rows, 0))
+			y := lh
+			for k in d.scroll ..< min(d.scroll + d.page_rows, len(d.rows)) {
+				if k == d.row {
+					push_rect(br, 0, y, w, lh, d.cursor_color)
+				}
+				draw_log_row(d, br, atlas, lctx, d.rows[k], y)
+				y += lh
+			}
+		},
+		on_destroy = proc(layer: ^Layer) {
+			d := cast(^Log_View_Data)layer.user_data
+			clear_log_view(d)
+			strings.builder_destroy(&d.text)
+			delete(d.starts)
+			delete(d.shown)
+			delete(d.rows)
+		},
+	}
+}
+
+// Shows text, with the cursor on the line holding byte pos.
+set_log_view :: proc(d: ^Log_View_Data, title, text: string, pos: int) {
+	// Copied first, as they may be the view's own.
+	title := strings.clone(title, context.temp_allocator)
+	filter, invert := strings.clone(d.filter, context.temp_allocator), d.invert
+	clear_log_view(d)
+	d.title = strings.clone(title, d.allocator)
+	append_log_text(d, text)
+	set_log_filter(d, filter, invert)
+	line := 0
+	for line + 1 < len(d.starts) && d.starts[line + 1] <= pos {
+		line += 1
+	}
+	log_view_go_to_line(d, line)
+	d.visible = true
+}
+
+clear_log_view :: proc(d: ^Log_View_Data) {
+	strings.builder_reset(&d.text)
+	clear(&d.starts)
+	clear(&d.shown)
+	clear(&d.rows)
+	delete(d.title, d.allocator)
+	d.title = ""
+	set_log_filter(d, "", false)
+	d.row, d.scroll, d.scroll_col = 0, 0, 0
+	d.follow = false
+	d.visible = false
+}
+
+// Adds text to the end of the log, as when the file grows; a line left
+// unfinished before is finished by it.  Following, the cursor moves to the
+// new last line.
+append_log_text :: proc(d: ^Log_View_Data, text: string) {
+	if text == "" {
+		return
+	}
+	// The last line may have been unfinished, so it is split again.
+	start := 0
+	from := max(len(d.starts) - 1, 0)
+	if len(d.starts) > 0 {
+		start = d.starts[from]
+		resize(&d.starts, from)
+		resize(&d.shown, from)
+	}
+	strings.write_string(&d.text, text)
+	s := strings.to_string(d.text)
+	for start < len(s) {
+		append(&d.starts, start)
+		nl := strings.index_byte(s[start:], '\n')
+		if nl < 0 {
+			break
+		}
+		start += nl + 1
+	}
+	for i in from ..< len(d.starts) {
+		append(&d.shown, log_filter_keeps(d, log_line_text(d, i)))
+	}
+	rebuild_log_rows(d)
+	if d.follow {
+		log_view_move(d, len(d.rows))
+	}
+}
+
+// Folds the lines that do not match filter, or that do when invert is set.
+// An empty filter shows every line.  Returns false when filter is not a
+// regex, leaving the lines as they were.
+set_log_filter :: proc(d: ^Log_View_Data, filter: string, invert: bool) -> bool {
+	re: regex.Regular_Expression
+	if filter != "" {
+		err: regex.Error
+		re, err = regex.create(filter, {}, d.allocator)
+		if err != nil {
+			return false
+		}
+	}
+	if d.has_re {
+		regex.destroy(d.re, d.allocator)
+	}
+	delete(d.filter, d.allocator)
+	d.filter = strings.clone(filter, d.allocator)
+	d.invert = invert
+	d.re, d.has_re = re, filter != ""
+	line := log_view_line(d)
+	for i in 0 ..< len(d.starts) {
+		d.shown[i] = log_filter_keeps(d, log_line_text(d, i))
+	}
+	rebuild_log_rows(d)
+	log_view_go_to_line(d, line)
+	return true
+}
+
+// Shows the lines of the fold at the cursor.  Returns false when the cursor
+// is on a line.
+unfold_log_row :: proc(d: ^Log_View_Data) -> bool {
+	if d.row >= len(d.rows) || d.rows[d.row].count == 0 {
+		return false
+	}
+	r := d.rows[d.row]
+	for i in r.line ..< r.line + r.count {
+		d.shown[i] = true
+	}
+	rebuild_log_rows(d)
+	log_view_go_to_line(d, r.line)
+	return true
+}
+
+// Moves the cursor by rows, keeping it on screen.  Reaching the last row
+// starts following the end of the log, leaving it stops.
+log_view_move :: proc(d: ^Log_View_Data, rows: int) {
+	d.row = clamp(d.row + rows, 0, max(len(d.rows) - 1, 0))
+	if d.row < d.scroll {
+		d.scroll = d.row
+	} else if d.row >= d.scroll + d.page_rows {
+		d.scroll = d.row - d.page_rows + 1
+	}
+	d.follow = d.row == len(d.rows) - 1
+}
+
+// Scrolls the lines sideways by cols cells.
+log_view_scroll :: proc(d: ^Log_View_Data, cols: int) {
+	d.scroll_col = max(d.scroll_col + cols, 0)
+}
+
+// The line at the cursor: the first of a fold.
+log_view_line :: proc(d: ^Log_View_Data) -> int {
+	if d.row >= len(d.rows) {
+		return 0
+	}
+	return d.rows[d.row].line
+}
+
+// Byte offset of line in the text shown.
+log_view_line_start :: proc(d: ^Log_View_Data, line: int) -> int {
+	return d.starts[line] if line < len(d.starts) else 0
+}
+
+// The row holding line, folded or not.
+log_view_go_to_line :: proc(d: ^Log_View_Data, line: int) {
+	target := 0
+	for r, k in d.rows {
+		if r.line > line {
+			break
+		}
+		target = k
+	}
+	log_view_move(d, target - d.row)
+}
+
+// Moves to the next shown line, after the cursor or before it when back,
+// that is at least a warning.  Returns false when there is none.
+log_view_next_problem :: proc(d: ^Log_View_Data, back: bool) -> bool {
+	step := -1 if back else 1
+	for k := d.row + step; k >= 0 && k < len(d.rows); k += step {
+		r := d.rows[k]
+		if r.count > 0 {
+			continue
+		}
+		if level, _, _ := log_line_level(log_line_text(d, r.line)); level >= .Warning {
+			log_view_move(d, k - d.row)
+			return true
+		}
+	}
+	return false
+}
+
+// Lines shown and lines in all.
+log_view_counts :: proc(d: ^Log_View_Data) -> (shown, total: int) {
+	for s in d.shown {
+		if s {
+			shown += 1
+		}
+	}
+	return shown, len(d.starts)
+}
+
+// The text of line without its line break.
+log_line_text :: proc(d: ^Log_View_Data, line: int) -> string {
+	s := strings.to_string(d.text)
+	return strings.trim_right(s[d.starts[line]:log_line_end(d, line)], "\r")
+}
+
+@(private = "file")
+log_line_end :: proc(d: ^Log_View_Data, line: int) -> int {
+	s := strings.to_string(d.text)
+	if line + 1 < len(d.starts) {
+		return d.starts[line + 1] - 1
+	}
+	if strings.has_suffix(s, "\n") {
+		return len(s) - 1
+	}
+	return len(s)
+}
+
+@(private = "file")
+log_filter_keeps :: proc(d: ^Log_View_Data, line: string) -> bool {
+	if !d.has_re {
+		return true
+	}
+	capture := regex.preallocate_capture(context.temp_allocator)
+	_, matched := regex.match(d.re, line, &capture)
+	return matched != d.invert
+}
+
+@(private = "file")
+rebuild_log_rows :: proc(d: ^Log_View_Data) {
+	clear(&d.rows)
+	for shown, i in d.shown {
+		switch {
+		case shown:
+			append(&d.rows, Log_Row{line = i})
+		case len(d.rows) > 0 && d.rows[len(d.rows) - 1].count > 0:
+			d.rows[len(d.rows) - 1].count += 1
+		case:
+			append(&d.rows, Log_Row{line = i, count = 1})
+		}
+	}
+	d.row = min(d.row, max(len(d.rows) - 1, 0))
+}
+
+// "following, filter: timeout (12 of 3400 lines)"
+@(private = "file")
+log_view_status :: proc(d: ^Log_View_Data) -> string {
+	b := strings.builder_make(context.temp_allocator)
+	if d.follow {
+		strings.write_string(&b, "following  ")
+	}
+	shown, total := log_view_counts(d)
+	if d.filter != "" {
+		strings.write_string(&b, "hiding: " if d.invert else "filter: ")
+		strings.write_string(&b, d.filter)
+		strings.write_string(&b, "  ")
+	}
+	buf: [16]u8
+	if shown < total {
+		strings.write_string(&b, fmt_int_buf(buf[:], shown))
+		strings.write_string(&b, " of ")
+	}
+	strings.write_string(&b, fmt_int_buf(buf[:], total))
+	strings.write_string(&b, " lines")
+	return strings.to_string(b)
+}
+
+@(private = "file")
+draw_log_row :: proc(
+	d: ^Log_View_Data,
+	br: ^Batch_Renderer,
+	atlas: ^Glyph_Atlas,
+	lctx: ^Layer_Context,
+	r: Log_Row,
+	y: f32,
+) {
+	cw := get_glyph(atlas, d.font, ' ').advance_x
+	x0 := 8 + f32(LOG_LINE_NUMBER_WIDTH + 1) * cw
+	if r.count > 0 {
+		buf: [16]u8
+		hidden := strings.concatenate(
+			{"··· ", fmt_int_buf(buf[:], r.count), " lines folded"},
+			context.temp_allocator,
+		)
+		push_text(br, atlas, d.font, x0, y, hidden, d.dim_color)
+		return
+	}
+	buf: [16]u8
+	num := fmt_int_buf(buf[:], r.line + 1)
+	num_x := 8 + f32(LOG_LINE_NUMBER_WIDTH - len(num)) * cw
+	push_text(br, atlas, d.font, num_x, y, num, d.dim_color)
+
+	line := log_line_text(d, r.line)
+	stamp := log_timestamp_len(line)
+	level, level_start, level_end := log_line_level(line)
+	col := 0
+	for i := 0; i < len(line); {
+		next := next_grapheme(line, i)
+		cluster := line[i:next]
+		color := d.fg_color
+		switch {
+		case i < stamp:
+			color = d.time_color
+		case i >= level_start && i < level_end:
+			color = d.level_colors[level]
+		case level >= .Error:
+			color = d.level_colors[level]
+		}
+		i = next
+		if cluster == "\t" {
+			col = (col / lctx.tab_size + 1) * lctx.tab_size
+			continue
+		}
+		x := x0 + f32(col - d.scroll_col) * cw
+		col += grapheme_width(cluster)
+		if x < x0 {
+			continue
+		}
+		if x >= lctx.viewport[0] {
+			break
+		}
+		ch, _ := utf8.decode_rune_in_string(cluster)
+		info := get_glyph(atlas, d.font, ch)
+		if info.size[0] > 0 {
+			push_glyph(br, x, y + d.font.ascent, info, color)
+		}
+	}
+}
+
+@(private = "file")
+is_log_word_byte :: proc(c: u8) -> bool {
+	return (c | 0x20 >= 'a' && c | 0x20 <= 'z') || c == '_'
+}
+
+@(private = "file")
+is_log_month :: proc(s: string) -> bool {
+	MONTHS :: "JanFebMarAprMayJunJulAugSepOctNovDec"
+	for i := 0; i < len(MONTHS); i += 3 {
+		if s == MONTHS[i:i + 3] {
+			return true
+		}
+	}
+	return false
+}
+
+@(private = "file")
+is_digit :: proc(c: u8) -> bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,382 @@
+package editor
+
+import "core:encoding/json"
+import "core:mem"
+import "core:mem/virtual"
+import "core:slice"
+import "core:strconv"
+import "core:strings"
+
+// An LSIF dump, the precomputed answers of a language server that indexers
+// such as lsif-node write, one JSON vertex or edge a line.  Only what
+// navigation needs is kept: each document's ranges with where their
+// definitions and references are and their monikers, the names that link a symbol to the
+// same symbol in another repository's dump.
+Lsif_Index :: struct {
+	encoding:  Position_Encoding, // of the positions, from metaData
+	root:      string, // the indexed project's directory, "" when not given
+	documents: []Lsif_Document, // sorted by path
+	exports:   []Lsif_Export, // sorted by identifier
+	allocator: mem.Allocator,
+}
+
+Lsif_Document :: struct {
+	path:   string,
+	ranges: []Lsif_Range, // sorted by start
+}
+
+Lsif_Range :: struct {
+	start:       Lsp_Position,
+	end:         Lsp_Position,
+	definitions: []Lsif_Location,
+	references:  []Lsif_Location, // the definitions among them
+	moniker:     Lsif_Moniker, // identifier "" when it has none
+}
+
+Lsif_Location :: struct {
+	path:  string,
+	start: Lsp_Position,
+}
+
+// What textDocument/moniker answers: a symbol's name in a scheme, e.g.
+// "gomod" or "npm", and whether it is exported from or imported into the
+// project.
+Lsif_Moniker :: struct {
+	scheme:     string,
+	identifier: string,
+	kind:       string, // "import", "export" or "local"
+}
+
+// A definition the dump exports, for other dumps' imports to find.
+Lsif_Export :: struct {
+	moniker:  Lsif_Moniker,
+	location: Lsif_Location,
+}
+
+// The graph as read, by vertex id, before it is boiled down to an index.
+@(private = "file")
+Lsif_Graph :: struct {
+	uris:       map[int]string, // documents
+	ranges:     map[int][2]Lsp_Position,
+	range_doc:  map[int]int, // by contains edges
+	next:       map[int]int, // range or result set to result set
+	definition: map[int]int, // to definition result
+	references: map[int]int, // to reference result
+	items:      map[int][dynamic]int, // result to ranges
+	moniker_of: map[int]int,
+	monikers:   map[int]Lsif_Moniker,
+	encoding:   Position_Encoding,
+	root:       string,
+	allocator:  mem.Allocator,
+}
+
+// Reads a dump, a JSON value a line or one JSON array of them.  Vertices
+// and edges of kinds not needed, or not understood, are skipped.
+parse_lsif :: proc(data: string, allocator := context.allocator) -> ^Lsif_Index {
+	// The graph lives in one arena and each line's JSON in another, emptied
+	// after the line.
+	graph_arena, line_arena: virtual.Arena
+	_ = virtual.arena_init_growing(&graph_arena)
+	defer virtual.arena_destroy(&graph_arena)
+	_ = virtual.arena_init_growing(&line_arena)
+	defer virtual.arena_destroy(&line_arena)
+	ga := virtual.arena_allocator(&graph_arena)
+	la := virtual.arena_allocator(&line_arena)
+	g := Lsif_Graph {
+		uris       = make(map[int]string, allocator = ga),
+		ranges     = make(map[int][2]Lsp_Position, allocator = ga),
+		range_doc  = make(map[int]int, allocator = ga),
+		next       = make(map[int]int, allocator = ga),
+		definition = make(map[int]int, allocator = ga),
+		references = make(map[int]int, allocator = ga),
+		items      = make(map[int][dynamic]int, allocator = ga),
+		moniker_of = make(map[int]int, allocator = ga),
+		monikers   = make(map[int]Lsif_Moniker, allocator = ga),
+		encoding   = .Utf16,
+		allocator  = ga,
+	}
+
+	rest := strings.trim_space(data)
+	if strings.has_prefix(rest, "[") {
+		if value, err := json.parse_string(rest, allocator = la); err == nil {
+			for v in json_array(value) {
+				add_lsif_entry(&g, v)
+			}
+		}
+		rest = ""
+	}
+	for line in strings.split_lines_iterator(&rest) {
+		if value, err := json.parse_string(line, allocator = la); err == nil {
+			add_lsif_entry(&g, value)
+		}
+		virtual.arena_free_all(&line_arena)
+	}
+
+	index := new(Lsif_Index, allocator)
+	index.encoding = g.encoding
+	index.root = strings.clone(g.root, allocator)
+	index.allocator = allocator
+	by_doc := make(map[int][dynamic]Lsif_Range, allocator = ga)
+	exports := make([dynamic]Lsif_Export, allocator)
+	for id, r in g.ranges {
+		doc, in_doc := g.range_doc[id]
+		if !in_doc || doc not_in g.uris {
+			continue
+		}
+		entry := Lsif_Range {
+			start = r[0],
+			end   = r[1],
+		}
+		if m, has := follow_lsif(&g, &g.moniker_of, id); has {
+			entry.moniker = clone_moniker(g.monikers[m], allocator)
+		}
+		if result, has := follow_lsif(&g, &g.references, id); has {
+			entry.references = lsif_locations(&g, result, allocator)
+		}
+		if result, has := follow_lsif(&g, &g.definition, id); has {
+			entry.definitions = lsif_locations(&g, result, allocator)
+			if entry.moniker.kind == "export" {
+				for loc in entry.definitions {
+					export := Lsif_Export{clone_moniker(entry.moniker, allocator), loc}
+					export.location.path = strings.clone(loc.path, allocator)
+					append(&exports, export)
+				}
+			}
+		}
+		list := by_doc[doc] or_else make([dynamic]Lsif_Range, ga)
+		append(&list, entry)
+		by_doc[doc] = list
+	}
+	documents := make([dynamic]Lsif_Document, allocator)
+	for doc, list in by_doc {
+		ranges := slice.clone(list[:], allocator)
+		slice.sort_by(ranges, proc(a, b: Lsif_Range) -> bool {
+			return position_less(a.start, b.start)
+		})
+		append(&documents, Lsif_Document{lsif_path(g.uris[doc], allocator), ranges})
+	}
+	slice.sort_by(documents[:], proc(a, b: Lsif_Document) -> bool {
+		return a.path < b.path
+	})
+	slice.sort_by(exports[:], proc(a, b: Lsif_Export) -> bool {
+		return a.moniker.identifier < b.moniker.identifier
+	})
+	index.documents = documents[:]
+	index.exports = exports[:]
+	return index
+}
+
+destroy_lsif :: proc(index: ^Lsif_Index) {
+	a := index.allocator
+	for d in index.documents {
+		for r in d.ranges {
+			for loc in r.definitions {
+				delete(loc.path, a)
+			}
+			delete(r.definitions, a)
+			for loc in r.references {
+				delete(loc.path, a)
+			}
+			delete(r.references, a)
+			destroy_moniker(r.moniker, a)
+		}
+		delete(d.ranges, a)
+		delete(d.path, a)
+	}
+	for e in index.exports {
+		delete(e.location.path, a)
+		destroy_moniker(e.moniker, a)
+	}
+	delete(index.documents, a)
+	delete(index.exports, a)
+	delete(index.root, a)
+	free(index, a)
+}
+
+// The innermost range of the document at path that holds pos, which counts
+// characters in the index's encoding.
+lsif_range_at :: proc(
+	index: ^Lsif_Index,
+	path: string,
+	pos: Lsp_Position,
+) -> (
+	found: Lsif_Range,
+	ok: bool,
+) {
+	docs := index.documents
+	lo, hi := 0, len(docs)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if docs[mid].path < path {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	if lo == len(docs) || docs[lo].path != path {
+		return {}, false
+	}
+	for r in docs[lo].ranges {
+		if position_less(pos, r.start) {
+			break
+		}
+		if !position_less(r.end, pos) {
+			found, ok = r, true // one starting later lies inside it
+		}
+	}
+	return found, ok
+}
+
+// Where the dump defines what moniker names, when it exports it.  Temp
+// allocated.
+lsif_exported :: proc(index: ^Lsif_Index, moniker: Lsif_Moniker) -> []Lsif_Location {
+	found := make([dynamic]Lsif_Location, context.temp_allocator)
+	exports := index.exports
+	lo, hi := 0, len(exports)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if exports[mid].moniker.identifier < moniker.identifier {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	for e in exports[lo:] {
+		if e.moniker.identifier != moniker.identifier {
+			break
+		}
+		if e.moniker.scheme == moniker.scheme {
+			append(&found, e.location)
+		}
+	}
+	return found[:]
+}
+
+@(private = "file")
+add_lsif_entry :: proc(g: ^Lsif_Graph, v: json.Value) {
+	id := lsif_id(json_field(v, "id"))
+	switch json_string(v, "type") {
+	case "vertex":
+		switch json_string(v, "label") {
+		case "metaData":
+			g.encoding = negotiated_position_encoding(json_string(v, "positionEncoding"))
+			g.root = uri_path(json_string(v, "projectRoot"), g.allocator)
+		case "document":
+			g.uris[id] = strings.clone(json_string(v, "uri"), g.allocator)
+		case "range":
+			g.ranges[id] = {lsif_position(v, "start"), lsif_position(v, "end")}
+		case "moniker":
+			g.monikers[id] = Lsif_Moniker {
+				scheme     = strings.clone(json_string(v, "scheme"), g.allocator),
+				identifier = strings.clone(json_string(v, "identifier"), g.allocator),
+				kind       = strings.clone(json_string(v, "kind"), g.allocator),
+			}
+		}
+	case "edge":
+		out := lsif_id(json_field(v, "outV"))
+		switch json_string(v, "label") {
+		case "contains":
+			for in_v in json_array(json_field(v, "inVs")) {
+				g.range_doc[lsif_id(in_v)] = out
+			}
+		case "next":
+			g.next[out] = lsif_id(json_field(v, "inV"))
+		case "textDocument/definition":
+			g.definition[out] = lsif_id(json_field(v, "inV"))
+		case "textDocument/references":
+			g.references[out] = lsif_id(json_field(v, "inV"))
+		case "moniker":
+			g.moniker_of[out] = lsif_id(json_field(v, "inV"))
+		case "item":
+			list := g.items[out] or_else make([dynamic]int, g.allocator)
+			for in_v in json_array(json_field(v, "inVs")) {
+				append(&list, lsif_id(in_v))
+			}
+			g.items[out] = list
+		}
+	}
+}
+
+// What table holds for id, or for the result sets its next edges lead to.
+@(private = "file")
+follow_lsif :: proc(g: ^Lsif_Graph, table: ^map[int]int, id: int) -> (found: int, ok: bool) {
+	at := id
+	for _ in 0 ..< 16 {
+		if found, ok = table[at]; ok {
+			return found, true
+		}
+		if at, ok = g.next[at]; !ok {
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+// The ranges a definition or reference result's item edges lead to, where
+// they are.
+@(private = "file")
+lsif_locations :: proc(g: ^Lsif_Graph, result: int, allocator: mem.Allocator) -> []Lsif_Location {
+	found := make([dynamic]Lsif_Location, allocator)
+	for target in g.items[result] {
+		if target_doc, ok := g.range_doc[target]; ok && target_doc in g.uris {
+			path := lsif_path(g.uris[target_doc], allocator)
+			append(&found, Lsif_Location{path, g.ranges[target][0]})
+		}
+	}
+	return found[:]
+}
+
+@(private = "file")
+position_less :: proc(a, b: Lsp_Position) -> bool {
+	return a.line < b.line || (a.line == b.line && a.character < b.character)
+}
+
+// Ids are numbers, or strings of them.
+@(private = "file")
+lsif_id :: proc(v: json.Value) -> int {
+	#partial switch id in v {
+	case json.Integer:
+		return int(id)
+	case json.Float:
+		return int(id)
+	case json.String:
+		if n, ok := strconv.parse_int(id, 10); ok {
+			return n
+		}
+	}
+	return -1
+}
+
+@(private = "file")
+lsif_position :: proc(v: json.Value, key: string) -> Lsp_Position {
+	p := json_field(v, key)
+	return {json_int(p, "line"), json_int(p, "character")}
+}
+
+// A document's path from its URI; a URI that is not a file's is kept.
+@(private = "file")
+lsif_path :: proc(uri: string, allocator: mem.Allocator) -> string {
+	if path := uri_path(uri, allocator); path != "" {
+		return path
+	}
+	return strings.clone(uri, allocator)
+}
+
+@(private = "file")
+clone_moniker :: proc(m: Lsif_Moniker, allocator: mem.Allocator) -> Lsif_Moniker {
+	if m.identifier == "" {
+		return {}
+	}
+	return {
+		strings.clone(m.scheme, allocator),
+		strings.clone(m.identifier, allocator),
+		strings.clone(m.kind, allocator),
+	}
+}
+
+@(private = "file")
+destroy_moniker :: proc(m: Lsif_Moniker, allocator: mem.Allocator) {
+	delete(m.scheme, allocator)
+	delete(m.identifier, allocator)
+	delete(m.kind, allocator)
+}
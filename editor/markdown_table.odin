@@ -0,0 +1,263 @@
+package editor
+
+import "core:strings"
+
+Table_Align :: enum {
+	None,
+	Left,
+	Center,
+	Right,
+}
+
+// A Markdown pipe table as cells.  The delimiter row ("| --- | :-: |") is a
+// row like the others; delimiter is its index, or -1 when the table has none.
+Markdown_Table :: struct {
+	indent:    string, // what the lines start with before their first pipe
+	rows:      [dynamic][dynamic]string, // trimmed cells, still escaped
+	delimiter: int,
+}
+
+// Columns are at least this wide, so that the delimiter row fits "---".
+TABLE_MIN_WIDTH :: 3
+
+// A line that starts, past its indentation, with a pipe.
+is_table_line :: proc(line: string) -> bool {
+	return strings.has_prefix(strings.trim_left(line, " \t"), "|")
+}
+
+// Splits a row into its trimmed cells.  The outer pipes are optional and "\|"
+// does not split.
+split_table_row :: proc(line: string, allocator := context.temp_allocator) -> [dynamic]string {
+	cells := make([dynamic]string, allocator)
+	s := strings.trim_space(line)
+	s = strings.trim_prefix(s, "|")
+	if len(s) > 0 && s[len(s) - 1] == '|' && !is_escaped(s, len(s) - 1) {
+		s = s[:len(s) - 1]
+	}
+	start := 0
+	for i in 0 ..< len(s) {
+		if s[i] == '|' && !is_escaped(s, i) {
+			append(&cells, strings.trim_space(s[start:i]))
+			start = i + 1
+		}
+	}
+	append(&cells, strings.trim_space(s[start:]))
+	return cells
+}
+
+// Reads the table made of lines, which is_table_line all accept.  The
+// delimiter row is only taken as one in second place, as Markdown does.
+parse_table :: proc(lines: []string, allocator := context.temp_allocator) -> Markdown_Table {
+	t := Markdown_Table {
+		rows      = make([dynamic][dynamic]string, allocator),
+		delimiter = -1,
+	}
+	if len(lines) > 0 {
+		first := lines[0]
+		t.indent = first[:len(first) - len(strings.trim_left(first, " \t"))]
+	}
+	for line in lines {
+		append(&t.rows, split_table_row(line, allocator))
+	}
+	if len(t.rows) > 1 && is_delimiter_row(t.rows[1][:]) {
+		t.delimiter = 1
+	}
+	return t
+}
+
+// Cells like "---", ":--", "--:" or ":-:".
+is_delimiter_row :: proc(cells: []string) -> bool {
+	for c in cells {
+		dashes := strings.trim_suffix(strings.trim_prefix(c, ":"), ":")
+		if dashes == "" || strings.trim_left(dashes, "-") != "" {
+			return false
+		}
+	}
+	return len(cells) > 0
+}
+
+table_column_align :: proc(t: ^Markdown_Table, column: int) -> Table_Align {
+	if t.delimiter < 0 || column >= len(t.rows[t.delimiter]) {
+		return .None
+	}
+	c := t.rows[t.delimiter][column]
+	left, right := strings.has_prefix(c, ":"), strings.has_suffix(c, ":") && len(c) > 1
+	switch {
+	case left && right:
+		return .Center
+	case left:
+		return .Left
+	case right:
+		return .Right
+	}
+	return .None
+}
+
+// The column count: the widest row's.
+table_columns :: proc(t: ^Markdown_Table) -> int {
+	n := 0
+	for row in t.rows {
+		n = max(n, len(row))
+	}
+	return n
+}
+
+// Adds a delimiter row under the header when the table has none.
+ensure_table_delimiter :: proc(t: ^Markdown_Table) {
+	if t.delimiter >= 0 || len(t.rows) == 0 {
+		return
+	}
+	row := make([dynamic]string, table_columns(t), t.rows.allocator)
+	for &c in row {
+		c = "---"
+	}
+	inject_at(&t.rows, 1, row)
+	t.delimiter = 1
+}
+
+// Lays the table out with every column padded to its widest cell and the
+// delimiter row redrawn to match, one string per row.
+format_table :: proc(
+	t: ^Markdown_Table,
+	tab_size: int,
+	allocator := context.temp_allocator,
+) -> []string {
+	columns := max(table_columns(t), 1)
+	widths := make([]int, columns, context.temp_allocator)
+	for &w in widths {
+		w = TABLE_MIN_WIDTH
+	}
+	for row, r in t.rows {
+		if r == t.delimiter {
+			continue
+		}
+		for c, i in row {
+			widths[i] = max(widths[i], text_columns(c, tab_size))
+		}
+	}
+
+	lines := make([]string, len(t.rows), allocator)
+	for row, r in t.rows {
+		b := strings.builder_make(allocator)
+		strings.write_string(&b, t.indent)
+		strings.write_byte(&b, '|')
+		for i in 0 ..< columns {
+			w := widths[i]
+			align := table_column_align(t, i)
+			strings.write_byte(&b, ' ')
+			if r == t.delimiter {
+				left := align == .Left || align == .Center
+				right := align == .Right || align == .Center
+				strings.write_string(&b, ":" if left else "-")
+				for _ in 0 ..< w - 2 {
+					strings.write_byte(&b, '-')
+				}
+				strings.write_string(&b, ":" if right else "-")
+			} else {
+				cell := row[i] if i < len(row) else ""
+				pad := w - text_columns(cell, tab_size)
+				before := 0
+				#partial switch align {
+				case .Right:
+					before = pad
+				case .Center:
+					before = pad / 2
+				}
+				for _ in 0 ..< before {
+					strings.write_byte(&b, ' ')
+				}
+				strings.write_string(&b, cell)
+				for _ in 0 ..< pad - before {
+					strings.write_byte(&b, ' ')
+				}
+			}
+			strings.write_string(&b, " |")
+		}
+		lines[r] = strings.to_string(b)
+	}
+	return lines
+}
+
+// The cell a byte column of a row is in, and how far into the cell's trimmed
+// text; a column in the padding counts as the nearest end of the text.
+table_cell_at :: proc(line: string, col: int) -> (cell, offset: int) {
+	start := next_pipe(line, 0) + 1
+	if start > len(line) || col < start {
+		return 0, 0
+	}
+	end := next_pipe(line, start)
+	for col > end && end < len(line) {
+		cell += 1
+		start = end + 1
+		end = next_pipe(line, start)
+	}
+	raw := line[start:end]
+	lead := len(raw) - len(strings.trim_left_space(raw))
+	text := strings.trim_space(raw)
+	return cell, clamp(col - start - lead, 0, len(text))
+}
+
+// The byte column of offset into the trimmed text of a cell of a row laid out
+// by format_table.
+table_cell_pos :: proc(line: string, cell, offset: int) -> int {
+	start := next_pipe(line, 0) + 1
+	if start > len(line) {
+		return len(line)
+	}
+	end := next_pipe(line, start)
+	for _ in 0 ..< cell {
+		if end >= len(line) {
+			break
+		}
+		start = end + 1
+		end = next_pipe(line, start)
+	}
+	raw := line[start:end]
+	text := strings.trim_space(raw)
+	if text == "" {
+		return min(start + 1, end)
+	}
+	lead := len(raw) - len(strings.trim_left_space(raw))
+	return start + lead + min(offset, len(text))
+}
+
+// Reads comma, semicolon or tab separated values, whichever the first line
+// has most of, with "quoted" fields.  Pipes in fields are escaped.
+csv_to_table :: proc(text: string, allocator := context.temp_allocator) -> Markdown_Table {
+	t := Markdown_Table {
+		rows      = make([dynamic][dynamic]string, allocator),
+		delimiter = -1,
+	}
+	for record in parse_csv(text, detect_csv_separator(text)) {
+		row := make([dynamic]string, allocator)
+		for cell in record {
+			s := strings.trim_space(csv_cell_text(text, cell))
+			s, _ = strings.replace_all(s, "|", "\\|", context.temp_allocator)
+			s, _ = strings.replace_all(s, "\n", " ", context.temp_allocator)
+			append(&row, strings.clone(s, allocator))
+		}
+		append(&t.rows, row)
+	}
+	ensure_table_delimiter(&t)
+	return t
+}
+
+@(private = "file")
+is_escaped :: proc(s: string, i: int) -> bool {
+	n := 0
+	for j := i - 1; j >= 0 && s[j] == '\\'; j -= 1 {
+		n += 1
+	}
+	return n % 2 == 1
+}
+
+// The index of the first unescaped pipe of s from from on, or len(s).
+@(private = "file")
+next_pipe :: proc(s: string, from: int) -> int {
+	for i in from ..< len(s) {
+		if s[i] == '|' && !is_escaped(s, i) {
+			return i
+		}
+	}
+	return len(s)
+}
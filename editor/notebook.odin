@@ -0,0 +1,407 @@
+package editor
+
+import "core:encoding/json"
+import "core:fmt"
+import "core:math/rand"
+import "core:strconv"
+import "core:strings"
+
+// Jupyter notebooks (.ipynb) are edited as text, each cell a marker line
+// and its source, the way jupytext's percent format writes them:
+//
+//     # %% id=4f2a91c0
+//     print("hi")
+//
+//     # %% [markdown] id=9c1e07b3
+//     # A heading
+//
+// The marker gives the kind of a cell that is not code, the language of a
+// code cell that is not the notebook's, and the id that ties the cell to the
+// file's, so that saving keeps its outputs and metadata, which the text
+// leaves out.  A marker typed without an id starts a new cell.
+NOTEBOOK_MARKER :: "# %%"
+
+Notebook :: struct {
+	language: string, // the kernel's, "" when the file does not say
+	cells:    []Notebook_Cell,
+}
+
+Notebook_Cell :: struct {
+	kind:     string, // "code", "markdown" or "raw"
+	id:       string, // "" for a cell the file does not have yet
+	language: string, // a code cell's, when not the notebook's
+	source:   string,
+	count:    int, // execution count, -1 when not run
+	outputs:  []Notebook_Output,
+	line:     int, // of its marker in the text, -1 when it has none
+}
+
+// An execution result as text: a stream's, a result's text/plain, an error's
+// name and value, or the MIME type of one with no text, as "(image/png)".
+Notebook_Output :: struct {
+	kind: string, // output_type: "stream", "execute_result", "display_data" or "error"
+	text: string,
+}
+
+// Reads a notebook file.  ok is false when data is not one.  Temp
+// allocated.
+parse_notebook :: proc(data: string) -> (nb: Notebook, ok: bool) {
+	root, _, parsed := parse_json_tree(data)
+	if !parsed || root.kind != .Object {
+		return {}, false
+	}
+	list, has := notebook_node(data, root, "cells")
+	if !has || list.kind != .Array {
+		return {}, false
+	}
+	nb.language = notebook_string(data, root, "metadata", "kernelspec", "language")
+	if nb.language == "" {
+		nb.language = notebook_string(data, root, "metadata", "language_info", "name")
+	}
+	cells := make([dynamic]Notebook_Cell, context.temp_allocator)
+	for node, i in list.children {
+		cell := Notebook_Cell {
+			kind   = notebook_string(data, node, "cell_type"),
+			id     = notebook_cell_id(data, node, i),
+			source = notebook_lines(data, node, "source"),
+			count  = -1,
+			line   = -1,
+		}
+		lang := notebook_string(data, node, "metadata", "vscode", "languageId")
+		if lang != nb.language {
+			cell.language = lang
+		}
+		if n, found := notebook_node(data, node, "execution_count"); found && n.kind == .Number {
+			cell.count = strconv.parse_int(data[n.start:n.end]) or_else -1
+		}
+		outputs := make([dynamic]Notebook_Output, context.temp_allocator)
+		if results, found := notebook_node(data, node, "outputs"); found {
+			for out in results.children {
+				append(&outputs, read_notebook_output(data, out))
+			}
+		}
+		cell.outputs = outputs[:]
+		append(&cells, cell)
+	}
+	nb.cells = cells[:]
+	return nb, true
+}
+
+// The buffer text of a notebook.  Temp allocated.
+notebook_text :: proc(nb: Notebook) -> string {
+	b := strings.builder_make(context.temp_allocator)
+	for cell, i in nb.cells {
+		if i > 0 {
+			strings.write_byte(&b, '\n')
+		}
+		strings.write_string(&b, NOTEBOOK_MARKER)
+		if cell.kind != "code" && cell.kind != "" {
+			fmt.sbprintf(&b, " [%s]", cell.kind)
+		}
+		if cell.language != "" {
+			fmt.sbprintf(&b, " lang=%s", cell.language)
+		}
+		if cell.id != "" {
+			fmt.sbprintf(&b, " id=%s", cell.id)
+		}
+		strings.write_byte(&b, '\n')
+		strings.write_string(&b, cell.source)
+		strings.write_byte(&b, '\n')
+	}
+	return strings.to_string(b)
+}
+
+// The cells of a notebook's buffer text, with their marker lines.  Text
+// before the first marker, when there is any, is a code cell of its own.
+// Temp allocated.
+parse_notebook_text :: proc(text: string) -> []Notebook_Cell {
+	starts := make([dynamic]int, context.temp_allocator) // of the marker lines
+	lines := make([dynamic]int, context.temp_allocator)
+	line := 0
+	for pos := 0; pos < len(text); line += 1 {
+		if strings.has_prefix(text[pos:], NOTEBOOK_MARKER) {
+			append(&starts, pos)
+			append(&lines, line)
+		}
+		next := strings.index_byte(text[pos:], '\n')
+		if next < 0 {
+			break
+		}
+		pos += next + 1
+	}
+
+	cells := make([dynamic]Notebook_Cell, context.temp_allocator)
+	first := starts[0] if len(starts) > 0 else len(text)
+	if strings.trim_space(text[:first]) != "" {
+		cell := Notebook_Cell {
+			kind   = "code",
+			source = notebook_cell_source(text[:first]),
+			count  = -1,
+			line   = -1,
+		}
+		append(&cells, cell)
+	}
+	for start, i in starts {
+		end := starts[i + 1] if i + 1 < len(starts) else len(text)
+		marker, _, body := strings.partition(text[start:end], "\n")
+		cell := Notebook_Cell {
+			kind   = "code",
+			source = notebook_cell_source(body),
+			count  = -1,
+			line   = lines[i],
+		}
+		for field in strings.fields(marker[len(NOTEBOOK_MARKER):], context.temp_allocator) {
+			switch {
+			case field == "[markdown]":
+				cell.kind = "markdown"
+			case field == "[raw]":
+				cell.kind = "raw"
+			case strings.has_prefix(field, "lang="):
+				cell.language = field[len("lang="):]
+			case strings.has_prefix(field, "id="):
+				cell.id = field[len("id="):]
+			}
+		}
+		append(&cells, cell)
+	}
+	return cells[:]
+}
+
+// The notebook file a buffer's text saves as.  Cells take what original,
+// the file as it was, has for their ids besides the source: outputs,
+// execution counts, metadata and attachments, and so does the notebook.  A
+// new notebook is written when original is not one.  Temp allocated.
+notebook_json :: proc(original, text: string) -> string {
+	cells := parse_notebook_text(text)
+	b := strings.builder_make(context.temp_allocator)
+	root, _, parsed := parse_json_tree(original)
+	list: Json_Node
+	has := false
+	if parsed && root.kind == .Object {
+		list, has = notebook_node(original, root, "cells")
+	}
+	if !has || list.kind != .Array {
+		strings.write_string(&b, "{\n \"cells\": ")
+		write_notebook_cells(&b, "", {}, cells)
+		strings.write_string(&b, ",\n \"metadata\": {},\n")
+		strings.write_string(&b, " \"nbformat\": 4,\n \"nbformat_minor\": 5\n}\n")
+		return strings.to_string(b)
+	}
+	strings.write_string(&b, original[:list.start])
+	write_notebook_cells(&b, original, list.children, cells)
+	strings.write_string(&b, original[list.end:])
+	return strings.to_string(b)
+}
+
+// What the cell's outputs show in one line: its execution count and the
+// first line of output, with how many more there are.  Temp allocated.
+notebook_output_summary :: proc(cell: Notebook_Cell) -> string {
+	if cell.count < 0 && len(cell.outputs) == 0 {
+		return ""
+	}
+	b := strings.builder_make(context.temp_allocator)
+	if cell.count >= 0 {
+		fmt.sbprintf(&b, "[%d]", cell.count)
+	}
+	lines := 0
+	for out in cell.outputs {
+		text := strings.trim_right(out.text, "\n")
+		if lines == 0 && text != "" {
+			first, _, _ := strings.partition(text, "\n")
+			fmt.sbprintf(&b, " %s", first)
+		}
+		lines += strings.count(text, "\n") + 1
+	}
+	if lines > 1 {
+		fmt.sbprintf(&b, "  (+%d lines)", lines - 1)
+	}
+	return strings.trim_space(strings.to_string(b))
+}
+
+// A random id for a new cell, as nbformat 4.5 gives each.  Temp allocated.
+new_notebook_cell_id :: proc() -> string {
+	return fmt.tprintf("%08x", rand.uint32())
+}
+
+@(private = "file")
+write_notebook_cells :: proc(
+	b: ^strings.Builder,
+	original: string,
+	old: []Json_Node,
+	cells: []Notebook_Cell,
+) {
+	if len(cells) == 0 {
+		strings.write_string(b, "[]")
+		return
+	}
+	by_id := make(map[string]Json_Node, context.temp_allocator)
+	for node, i in old {
+		by_id[notebook_cell_id(original, node, i)] = node
+	}
+	used := make(map[string]bool, context.temp_allocator)
+	strings.write_string(b, "[\n")
+	for cell, i in cells {
+		if i > 0 {
+			strings.write_string(b, ",\n")
+		}
+		strings.write_string(b, "  ")
+		node, found := by_id[cell.id]
+		// A copied cell keeps its marker's id; only the first keeps the cell.
+		if found && !used[cell.id] && notebook_string(original, node, "cell_type") == cell.kind {
+			if source, ok := notebook_node(original, node, "source"); ok {
+				used[cell.id] = true
+				strings.write_string(b, original[node.start:source.start])
+				if notebook_lines(original, node, "source") == cell.source {
+					strings.write_string(b, original[source.start:source.end])
+				} else {
+					write_notebook_source(b, cell.source)
+				}
+				strings.write_string(b, original[source.end:node.end])
+				continue
+			}
+		}
+		id := cell.id
+		if id == "" || used[id] {
+			id = new_notebook_cell_id()
+		}
+		used[id] = true
+		write_new_notebook_cell(b, cell, id)
+	}
+	strings.write_string(b, "\n ]")
+}
+
+// A cell as nbformat writes it, one space of indent a level.
+@(private = "file")
+write_new_notebook_cell :: proc(b: ^strings.Builder, cell: Notebook_Cell, id: string) {
+	code := cell.kind == "code"
+	fmt.sbprintf(b, "{\n   \"cell_type\": %s,\n", quote_notebook_string(cell.kind))
+	if code {
+		strings.write_string(b, "   \"execution_count\": null,\n")
+	}
+	fmt.sbprintf(b, "   \"id\": %s,\n", quote_notebook_string(id))
+	if cell.language != "" {
+		language := quote_notebook_string(cell.language)
+		strings.write_string(b, "   \"metadata\": {\n    \"vscode\": {\n")
+		fmt.sbprintf(b, "     \"languageId\": %s\n    }\n   },\n", language)
+	} else {
+		strings.write_string(b, "   \"metadata\": {},\n")
+	}
+	if code {
+		strings.write_string(b, "   \"outputs\": [],\n")
+	}
+	strings.write_string(b, "   \"source\": ")
+	write_notebook_source(b, cell.source)
+	strings.write_string(b, "\n  }")
+}
+
+// A source as a list of its lines, each with its line break.
+@(private = "file")
+write_notebook_source :: proc(b: ^strings.Builder, source: string) {
+	if source == "" {
+		strings.write_string(b, "[]")
+		return
+	}
+	strings.write_string(b, "[\n")
+	rest := source
+	for rest != "" {
+		line := rest
+		if i := strings.index_byte(rest, '\n'); i >= 0 {
+			line = rest[:i + 1]
+		}
+		rest = rest[len(line):]
+		strings.write_string(b, "    ")
+		strings.write_string(b, quote_notebook_string(line))
+		strings.write_string(b, ",\n" if rest != "" else "\n")
+	}
+	strings.write_string(b, "   ]")
+}
+
+@(private = "file")
+read_notebook_output :: proc(text: string, node: Json_Node) -> Notebook_Output {
+	out := Notebook_Output {
+		kind = notebook_string(text, node, "output_type"),
+	}
+	switch out.kind {
+	case "stream":
+		out.text = notebook_lines(text, node, "text")
+	case "error":
+		name := notebook_string(text, node, "ename")
+		out.text = fmt.tprintf("%s: %s", name, notebook_string(text, node, "evalue"))
+	case:
+		data, _ := notebook_node(text, node, "data")
+		out.text = notebook_lines(text, data, "text/plain")
+		if out.text == "" && len(data.children) > 0 {
+			out.text = fmt.tprintf("(%s)", json_node_key(text, data.children[0]))
+		}
+	}
+	return out
+}
+
+// A cell's id, or for a notebook from before cells had them (nbformat 4.4)
+// its place, as "cell-3".  Temp allocated.
+@(private = "file")
+notebook_cell_id :: proc(text: string, node: Json_Node, index: int) -> string {
+	if id := notebook_string(text, node, "id"); id != "" {
+		return id
+	}
+	return fmt.tprintf("cell-%d", index)
+}
+
+// A cell's source from the text between its marker and the next, without
+// the line break ending it and the blank line that parts it from the next.
+@(private = "file")
+notebook_cell_source :: proc(body: string) -> string {
+	source := strings.trim_suffix(body, "\n")
+	return strings.trim_suffix(source, "\n")
+}
+
+// The member at the path of keys below node.
+@(private = "file")
+notebook_node :: proc(text: string, node: Json_Node, keys: ..string) -> (Json_Node, bool) {
+	at := node
+	next: for key in keys {
+		if at.kind != .Object {
+			return {}, false
+		}
+		for child in at.children {
+			if json_node_key(text, child) == key {
+				at = child
+				continue next
+			}
+		}
+		return {}, false
+	}
+	return at, true
+}
+
+// The string at the path, "" when there is none.
+@(private = "file")
+notebook_string :: proc(text: string, node: Json_Node, keys: ..string) -> string {
+	if n, ok := notebook_node(text, node, ..keys); ok && n.kind == .String {
+		return json_node_string(text, n)
+	}
+	return ""
+}
+
+// The text at the path, which nbformat writes as a string or a list of its
+// lines.  Temp allocated.
+@(private = "file")
+notebook_lines :: proc(text: string, node: Json_Node, keys: ..string) -> string {
+	n, ok := notebook_node(text, node, ..keys)
+	if !ok || n.kind == .String {
+		return notebook_string(text, node, ..keys)
+	}
+	b := strings.builder_make(context.temp_allocator)
+	for item in n.children {
+		if item.kind == .String {
+			strings.write_string(&b, json_node_string(text, item))
+		}
+	}
+	return strings.to_string(b)
+}
+
+@(private = "file")
+quote_notebook_string :: proc(s: string) -> string {
+	data, err := json.marshal(s, allocator = context.temp_allocator)
+	return string(data) if err == nil else "\"\""
+}
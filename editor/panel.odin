@@ -0,0 +1,223 @@
+package editor
+
+import "core:mem"
+import "core:strings"
+
+Panel_Item_Style :: enum u8 {
+	Normal,
+	Header,
+	Dim,
+	Removed, // diff previews
+	Added,
+}
+
+Panel_Check :: enum u8 {
+	None, // no checkbox
+	Checked,
+	Unchecked,
+}
+
+Panel_Item :: struct {
+	text:  string,
+	path:  string, // navigation target; empty for headers and context lines
+	line:  int,
+	col:   int,
+	style: Panel_Item_Style,
+	check: Panel_Check,
+	data:  int, // owner defined, e.g. an index into the owner's own records
+}
+
+// A bottom panel listing items, one per row, with a movable selection.
+// Used for search results and other location lists.
+Panel_Layer_Data :: struct {
+	font:          ^Font_Handle,
+	title:         strings.Builder,
+	items:         [dynamic]Panel_Item,
+	selected:      int,
+	scroll:        int, // first visible item
+	max_rows:      int,
+	visible:       bool,
+	line_height:   f32,
+	bottom_margin: f32, // space reserved below the panel (status line)
+	fg_color:      [4]f32,
+	dim_color:     [4]f32,
+	header_color:  [4]f32,
+	removed_color: [4]f32,
+	added_color:   [4]f32,
+	bg_color:      [4]f32,
+	select_color:  [4]f32,
+	allocator:     mem.Allocator,
+}
+
+make_panel_layer :: proc(
+	font: ^Font_Handle,
+	line_height: f32,
+	bottom_margin: f32,
+	max_rows: int,
+	allocator: mem.Allocator = context.allocator,
+) -> Layer {
+	data := new(Panel_Layer_Data, allocator)
+	data.font = font
+	data.line_height = line_height
+	data.bottom_margin = bottom_margin
+	data.max_rows = max_rows
+	data.title = strings.builder_make(allocator)
+	data.items = make([dynamic]Panel_Item, allocator)
+	data.fg_color = {0.85, 0.85, 0.82, 1.0}
+	data.dim_color = {0.50, 0.50, 0.55, 1.0}
+	data.header_color = {0.55, 0.75, 0.95, 1.0}
+	data.removed_color = {0.90, 0.45, 0.45, 1.0}
+	data.added_color = {0.50, 0.82, 0.50, 1.0}
+	data.bg_color = {0.10, 0.10, 0.12, 1.0}
+	data.select_color = {0.22, 0.26, 0.34, 1.0}
+	data.allocator = allocator
+
+	return Layer {
+		kind = .Overlay,
+		z_index = 150,
+		enabled = true,
+		name = "panel",
+		user_data = data,
+		draw = proc(
+			layer: ^Layer,
+			br: ^Batch_Renderer,
+			atlas: ^Glyph_Atlas,
+			lctx: ^Layer_Context,
+		) {
+			d := cast(^Panel_Layer_Data)layer.user_data
+			if !d.visible {
+				return
+			}
+
+			h := panel_height(d)
+			y := lctx.viewport[1] - d.bottom_margin - h
+			push_rect(br, 0, y, lctx.viewport[0], h, d.bg_color)
+
+			push_text(br, atlas, d.font, 8, y, strings.to_string(d.title), d.header_color)
+			y += d.line_height
+
+			last := min(len(d.items), d.scroll + panel_rows(d))
+			for i in d.scroll ..< last {
+				item := d.items[i]
+				if i == d.selected {
+					push_rect(br, 0, y, lctx.viewport[0], d.line_height, d.select_color)
+				}
+				color := d.fg_color
+				switch item.style {
+				case .Normal:
+				case .Header:
+					color = d.header_color
+				case .Dim:
+					color = d.dim_color
+				case .Removed:
+					color = d.removed_color
+				case .Added:
+					color = d.added_color
+				}
+				x: f32 = 8
+				switch item.check {
+				case .None:
+				case .Checked:
+					x = push_text(br, atlas, d.font, x, y, "[x] ", d.fg_color)
+				case .Unchecked:
+					x = push_text(br, atlas, d.font, x, y, "[ ] ", d.dim_color)
+				}
+				push_text(br, atlas, d.font, x, y, item.text, color)
+				y += d.line_height
+			}
+		},
+		on_destroy = proc(layer: ^Layer) {
+			d := cast(^Panel_Layer_Data)layer.user_data
+			panel_clear(d)
+			delete(d.items)
+			strings.builder_destroy(&d.title)
+		},
+	}
+}
+
+// Number of item rows currently shown (the title row excluded).
+panel_rows :: proc(d: ^Panel_Layer_Data) -> int {
+	return clamp(len(d.items), 1, max(d.max_rows, 1))
+}
+
+// Total pixel height of the panel, or 0 when hidden.
+panel_height :: proc(d: ^Panel_Layer_Data) -> f32 {
+	if !d.visible {
+		return 0
+	}
+	return f32(panel_rows(d) + 1) * d.line_height
+}
+
+panel_clear :: proc(d: ^Panel_Layer_Data) {
+	for item in d.items {
+		delete(item.text, d.allocator)
+		delete(item.path, d.allocator)
+	}
+	clear(&d.items)
+	d.selected = 0
+	d.scroll = 0
+}
+
+panel_set_title :: proc(d: ^Panel_Layer_Data, title: string) {
+	strings.builder_reset(&d.title)
+	strings.write_string(&d.title, title)
+}
+
+// Appends a copy of item; the panel owns the strings.
+panel_add_item :: proc(d: ^Panel_Layer_Data, item: Panel_Item) {
+	owned := item
+	owned.text = strings.clone(item.text, d.allocator)
+	owned.path = strings.clone(item.path, d.allocator)
+	append(&d.items, owned)
+
+	// Land the initial selection on the first navigable item.
+	if d.items[d.selected].path == "" && owned.path != "" {
+		d.selected = len(d.items) - 1
+	}
+}
+
+// Moves the selection by delta, skipping items that cannot be navigated to.
+panel_move_selection :: proc(d: ^Panel_Layer_Data, delta: int) {
+	if len(d.items) == 0 || delta == 0 {
+		return
+	}
+	step := 1 if delta > 0 else -1
+	remaining := abs(delta)
+	i := d.selected
+	for remaining > 0 {
+		next := i + step
+		for next >= 0 && next < len(d.items) && d.items[next].path == "" {
+			next += step
+		}
+		if next < 0 || next >= len(d.items) {
+			break
+		}
+		i = next
+		remaining -= 1
+	}
+	d.selected = i
+
+	rows := panel_rows(d)
+	if d.selected < d.scroll {
+		d.scroll = d.selected
+	} else if d.selected >= d.scroll + rows {
+		d.scroll = d.selected - rows + 1
+	}
+}
+
+// Flips the checkbox of the selected item, if it has one.
+panel_toggle_selected :: proc(d: ^Panel_Layer_Data) -> bool {
+	item := panel_selected_item(d)
+	if item == nil || item.check == .None {
+		return false
+	}
+	item.check = .Unchecked if item.check == .Checked else .Checked
+	return true
+}
+
+panel_selected_item :: proc(d: ^Panel_Layer_Data) -> ^Panel_Item {
+	if d.selected < 0 || d.selected >= len(d.items) {
+		return nil
+	}
+	return &d.items[d.selected]
+}
@@ -0,0 +1,191 @@
+package editor
+
+import "core:mem"
+import "core:strings"
+
+// Number cells before each line of a peek, for its line number.
+PEEK_NUMBER_COLS :: 6
+
+// A few lines of a file in a box under a line of the text: where a name is
+// defined or used, looked at without leaving the buffer.  While focused the
+// box has a caret of its own, for the caller to edit the file through.
+Peek_View_Data :: struct {
+	font:         ^Font_Handle,
+	cursor:       ^Cursor_Layer_Data, // the text's grid, which places the box
+	visible:      bool,
+	focused:      bool,
+	line_height:  f32,
+	anchor:       int, // the text line the box opens under
+	rows:         int, // lines of the file shown
+	title:        string,
+	lines:        [dynamic]string, // the file's, from first_line on
+	first_line:   int,
+	target:       int, // the line peeked at, highlighted
+	caret_line:   int,
+	caret_col:    int, // visual
+	fg_color:     [4]f32,
+	dim_color:    [4]f32,
+	bg_color:     [4]f32,
+	title_color:  [4]f32,
+	target_color: [4]f32,
+	caret_color:  [4]f32,
+	allocator:    mem.Allocator,
+}
+
+make_peek_view_layer :: proc(
+	font: ^Font_Handle,
+	cursor: ^Cursor_Layer_Data,
+	line_height: f32,
+	allocator: mem.Allocator = context.allocator,
+) -> Layer {
+	data := new(Peek_View_Data, allocator)
+	data.font = font
+	data.cursor = cursor
+	data.line_height = line_height
+	data.rows = 10
+	data.lines = make([dynamic]string, allocator)
+	data.fg_color = {0.85, 0.85, 0.82, 1.0}
+	data.dim_color = {0.45, 0.45, 0.50, 1.0}
+	data.bg_color = {0.10, 0.11, 0.14, 1.0}
+	data.title_color = {0.18, 0.18, 0.22, 1.0}
+	data.target_color = {0.25, 0.35, 0.55, 0.35}
+	data.caret_color = {0.90, 0.90, 0.90, 1.0}
+	data.allocator = allocator
+
+	return Layer {
+		kind = .Overlay,
+		z_index = 105,
+		enabled = true,
+		name = "peek_view",
+		user_data = data,
+		draw = proc(
+			layer: ^Layer,
+			br: ^Batch_Renderer,
+			atlas: ^Glyph_Atlas,
+			lctx: ^Layer_Context,
+		) {
+			d := cast(^Peek_View_Data)layer.user_data
+			if !d.visible {
+				return
+			}
+			lh := d.line_height
+			cw := get_glyph(atlas, d.font, ' ').advance_x
+			h := f32(d.rows + 1) * lh
+			x0 := d.cursor.padding[0] - 4
+			w := lctx.viewport[0] - x0 - 8
+			y0 := d.cursor.padding[1] + f32(d.anchor + 1) * lh - lctx.scroll_y
+			if y0 + h > lctx.viewport[1] - lh {
+				// Above the line when it does not fit below.
+				y0 = max(y0 - lh - h, 0)
+			}
+			push_rect(br, x0, y0, w, h, d.bg_color)
+			push_rect(br, x0, y0, w, lh, d.title_color)
+			push_rect(br, x0, y0 + h - 1, w, 1, d.dim_color)
+			push_text(br, atlas, d.font, x0 + 8, y0, d.title, d.fg_color)
+
+			text_x := x0 + 4 + PEEK_NUMBER_COLS * cw
+			max_cols := int((x0 + w - text_x) / cw)
+			buf: [20]u8
+			for line, k in d.lines {
+				y := y0 + f32(k + 1) * lh
+				n := d.first_line + k
+				if n == d.target {
+					push_rect(br, x0, y, w, lh, d.target_color)
+				}
+				number := fmt_int_buf(buf[:], n + 1)
+				number_x := text_x - f32(len(number) + 1) * cw
+				push_text(br, atlas, d.font, number_x, y, number, d.dim_color)
+				draw_peek_line(br, atlas, d, text_x, y, cw, line, lctx.tab_size, max_cols)
+			}
+			k := d.caret_line - d.first_line
+			if d.focused && k >= 0 && k < len(d.lines) {
+				x := text_x + f32(d.caret_col) * cw
+				push_rect(br, x, y0 + f32(k + 1) * lh, 2, lh, d.caret_color)
+			}
+		},
+		on_destroy = proc(layer: ^Layer) {
+			d := cast(^Peek_View_Data)layer.user_data
+			clear_peek_view(d)
+			delete(d.lines)
+		},
+	}
+}
+
+// Shows lines of a file, the first of them being first_line; target is
+// highlighted.  The caret is left as it was.
+set_peek_view :: proc(d: ^Peek_View_Data, title: string, lines: []string, first_line, target: int) {
+	focused := d.focused
+	clear_peek_view(d)
+	d.title = strings.clone(title, d.allocator)
+	for line in lines {
+		append(&d.lines, strings.clone(line, d.allocator))
+	}
+	d.first_line = first_line
+	d.target = target
+	d.focused = focused
+	d.visible = true
+}
+
+clear_peek_view :: proc(d: ^Peek_View_Data) {
+	delete(d.title, d.allocator)
+	d.title = ""
+	for line in d.lines {
+		delete(line, d.allocator)
+	}
+	clear(&d.lines)
+	d.visible = false
+	d.focused = false
+}
+
+// The byte offsets of word in text where it stands as a word of its own,
+// not part of a longer identifier.  Temp allocated.
+find_whole_word :: proc(text, word: string) -> []int {
+	found := make([dynamic]int, context.temp_allocator)
+	if word == "" {
+		return found[:]
+	}
+	at := 0
+	for {
+		i := strings.index(text[at:], word)
+		if i < 0 {
+			break
+		}
+		start := at + i
+		end := start + len(word)
+		before := start > 0 && is_word_byte(text[start - 1])
+		after := end < len(text) && is_word_byte(text[end])
+		if !before && !after {
+			append(&found, start)
+		}
+		at = end
+	}
+	return found[:]
+}
+
+// Draws a line on the text's grid, tabs to the next stop, as far as max_cols
+// cells.
+@(private = "file")
+draw_peek_line :: proc(
+	br: ^Batch_Renderer,
+	atlas: ^Glyph_Atlas,
+	d: ^Peek_View_Data,
+	x, y, cw: f32,
+	line: string,
+	tab_size: int,
+	max_cols: int,
+) {
+	ts := max(tab_size, 1)
+	col := 0
+	i := 0
+	for i < len(line) && col < max_cols {
+		next := next_grapheme(line, i)
+		cluster := line[i:next]
+		i = next
+		if cluster == "\t" {
+			col = (col / ts + 1) * ts
+			continue
+		}
+		push_text(br, atlas, d.font, x + f32(col) * cw, y, cluster, d.fg_color)
+		col += grapheme_width(cluster)
+	}
+}
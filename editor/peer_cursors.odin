@@ -0,0 +1,104 @@
+package editor
+
+import "core:mem"
+import "core:strings"
+
+// Another collaborator's caret and selection, in visual columns.
+Peer_Cursor :: struct {
+	name:      string,
+	color:     [4]f32,
+	line:      int, // the caret
+	col:       int,
+	selection: Selection, // empty when start and end are equal
+}
+
+// The carets and selections of the other people editing the buffer, each in
+// their own color with their name over the caret.
+Peer_Cursor_Layer_Data :: struct {
+	font:        ^Font_Handle,
+	cursors:     [dynamic]Peer_Cursor,
+	line_height: f32,
+	char_width:  f32,
+	padding:     [2]f32,
+	label_color: [4]f32,
+	allocator:   mem.Allocator,
+}
+
+make_peer_cursor_layer :: proc(
+	font: ^Font_Handle,
+	line_height: f32,
+	char_width: f32,
+	padding: [2]f32,
+	allocator: mem.Allocator = context.allocator,
+) -> Layer {
+	data := new(Peer_Cursor_Layer_Data, allocator)
+	data.font = font
+	data.cursors = make([dynamic]Peer_Cursor, allocator)
+	data.line_height = line_height
+	data.char_width = char_width
+	data.padding = padding
+	data.label_color = {0.10, 0.10, 0.12, 1.0}
+	data.allocator = allocator
+
+	return Layer {
+		kind = .Cursor,
+		z_index = 9,
+		enabled = true,
+		name = "peer_cursors",
+		user_data = data,
+		draw = proc(
+			layer: ^Layer,
+			br: ^Batch_Renderer,
+			atlas: ^Glyph_Atlas,
+			lctx: ^Layer_Context,
+		) {
+			d := cast(^Peer_Cursor_Layer_Data)layer.user_data
+			for c in d.cursors {
+				sel := c.selection
+				if sel.start_line != sel.end_line || sel.start_col != sel.end_col {
+					shade := c.color
+					shade.a = 0.25
+					for ln in sel.start_line ..= sel.end_line {
+						x0 := d.padding[0] - lctx.scroll_x
+						x1 := lctx.viewport[0]
+						if ln == sel.start_line {
+							x0 += f32(sel.start_col) * d.char_width
+						}
+						if ln == sel.end_line {
+							x1 = d.padding[0] + f32(sel.end_col) * d.char_width - lctx.scroll_x
+						}
+						y := d.padding[1] + f32(ln) * d.line_height - lctx.scroll_y
+						push_rect(br, x0, y, x1 - x0, d.line_height, shade)
+					}
+				}
+
+				x := d.padding[0] + f32(c.col) * d.char_width - lctx.scroll_x
+				y := d.padding[1] + f32(c.line) * d.line_height - lctx.scroll_y
+				push_rect(br, x, y, 2, d.line_height, c.color)
+				// The name sits on the line above, or below on the first one.
+				w := measure_text(atlas, d.font, c.name) + d.char_width
+				label_y := y - d.line_height if y >= d.line_height else y + d.line_height
+				push_rect(br, x, label_y, w, d.line_height, c.color)
+				push_text(br, atlas, d.font, x + d.char_width / 2, label_y, c.name, d.label_color)
+			}
+		},
+		on_destroy = proc(layer: ^Layer) {
+			d := cast(^Peer_Cursor_Layer_Data)layer.user_data
+			clear_peer_cursors(d)
+			delete(d.cursors)
+		},
+	}
+}
+
+clear_peer_cursors :: proc(d: ^Peer_Cursor_Layer_Data) {
+	for c in d.cursors {
+		delete(c.name, d.allocator)
+	}
+	clear(&d.cursors)
+}
+
+add_peer_cursor :: proc(d: ^Peer_Cursor_Layer_Data, c: Peer_Cursor) {
+	owned := c
+	owned.name = strings.clone(c.name, d.allocator)
+	append(&d.cursors, owned)
+}
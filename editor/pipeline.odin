@@ -1,12 +1,13 @@
 package editor
 
-import "core:fmt"
+import "core:log"
 import "core:os"
 import vk "vendor:vulkan"
 
 Pipeline_Kind :: enum u8 {
 	Text,
 	Solid,
+	Image, // an RGBA texture, the image being previewed
 }
 
 Pipeline :: struct {
@@ -37,32 +38,35 @@ create_pipeline :: proc(ctx: ^Render_Context, kind: Pipeline_Kind) -> (pipe: Pip
 	case .Solid:
 		vert_path = "./editor/shaders/solid.vert.spv"
 		frag_path = "./editor/shaders/solid.frag.spv"
+	case .Image:
+		vert_path = "./editor/shaders/text.vert.spv"
+		frag_path = "./editor/shaders/image.frag.spv"
 	}
 
 	vert_code, vert_ok := os.read_entire_file_from_path(vert_path, ctx.allocator)
 	if vert_ok != nil {
-		fmt.eprintfln("Failed to load vertex shader: %s", vert_path)
+		log.errorf("Failed to load vertex shader: %s", vert_path)
 		return pipe, false
 	}
 	defer delete(vert_code, ctx.allocator)
 
 	frag_code, frag_ok := os.read_entire_file_from_path(frag_path, ctx.allocator)
 	if frag_ok != nil {
-		fmt.eprintfln("Failed to load fragment shader: %s", vert_path)
+		log.errorf("Failed to load fragment shader: %s", vert_path)
 		return pipe, false
 	}
 	defer delete(frag_code, ctx.allocator)
 
 	vert_module, v_ok := create_shader_module(ctx, vert_code)
 	if !v_ok {
-		fmt.eprintln("Failed to create vertex shader module")
+		log.error("Failed to create vertex shader module")
 		return pipe, false
 	}
 	defer vk.DestroyShaderModule(ctx.device, vert_module, nil)
 
 	frag_module, f_ok := create_shader_module(ctx, frag_code)
 	if !f_ok {
-		fmt.eprintln("Failed to create fragment shader module")
+		log.error("Failed to create fragment shader module")
 		return pipe, false
 	}
 	defer vk.DestroyShaderModule(ctx.device, frag_module, nil)
@@ -182,7 +186,7 @@ create_pipeline :: proc(ctx: ^Render_Context, kind: Pipeline_Kind) -> (pipe: Pip
 	}
 
 	// descriptor sets
-	if kind == .Text {
+	if kind == .Text || kind == .Image {
 		sampler_binding := vk.DescriptorSetLayoutBinding {
 			binding         = 0,
 			descriptorType  = .COMBINED_IMAGE_SAMPLER,
@@ -239,7 +243,7 @@ create_pipeline :: proc(ctx: ^Render_Context, kind: Pipeline_Kind) -> (pipe: Pip
 
 	set_layout_count: u32 = 0
 	p_set_layouts: ^vk.DescriptorSetLayout = nil
-	if kind == .Text {
+	if kind == .Text || kind == .Image {
 		set_layout_count = 1
 		p_set_layouts = &pipe.set_layout
 	}
@@ -280,11 +284,11 @@ create_pipeline :: proc(ctx: ^Render_Context, kind: Pipeline_Kind) -> (pipe: Pip
 	return pipe, true
 }
 
-update_descriptor_set :: proc(ctx: ^Render_Context, pipe: ^Pipeline, atlas_image: ^GPU_Image) {
+update_descriptor_set :: proc(ctx: ^Render_Context, pipe: ^Pipeline, image: ^GPU_Image) {
 	for i in 0 ..< MAX_FRAMES_IN_FLIGHT {
 		image_info := vk.DescriptorImageInfo {
-			sampler     = atlas_image.sampler,
-			imageView   = atlas_image.view,
+			sampler     = image.sampler,
+			imageView   = image.view,
 			imageLayout = .SHADER_READ_ONLY_OPTIMAL,
 		}
 
@@ -330,7 +334,7 @@ create_shader_module :: proc(
 
 	res := vk.CreateShaderModule(ctx.device, &info, nil, &module)
 	if res != .SUCCESS {
-		fmt.eprintln("Vulkan Error: Failed to create shader module: %v", res)
+		log.errorf("Vulkan Error: Failed to create shader module: %v", res)
 		return {}, false
 	}
 
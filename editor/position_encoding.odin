@@ -0,0 +1,140 @@
+package editor
+
+import "core:strings"
+import "core:unicode/utf8"
+
+// What the character of an LSP position counts.  The protocol's default is
+// UTF-16 code units, which differ from the buffer's bytes after the first
+// non-ASCII character; since 3.17 the client offers encodings at initialize
+// and the server picks one as its positionEncoding.
+Position_Encoding :: enum u8 {
+	Utf16,
+	Utf8,
+	Utf32,
+}
+
+POSITION_ENCODING_NAMES := [Position_Encoding]string {
+	.Utf16 = "utf-16",
+	.Utf8  = "utf-8",
+	.Utf32 = "utf-32",
+}
+
+// general.positionEncodings, most wanted first: UTF-8 counts bytes as the
+// buffer does, so nothing needs converting.
+CLIENT_POSITION_ENCODINGS := [?]Position_Encoding{.Utf8, .Utf32, .Utf16}
+
+// A line and a character in it, both from 0.
+Lsp_Position :: struct {
+	line:      int,
+	character: int,
+}
+
+// The encoding a server chose by its capabilities' positionEncoding:
+// UTF-16 when it names none, or one the client did not offer.
+negotiated_position_encoding :: proc(name: string) -> Position_Encoding {
+	for e in CLIENT_POSITION_ENCODINGS {
+		if POSITION_ENCODING_NAMES[e] == name {
+			return e
+		}
+	}
+	return .Utf16
+}
+
+// Reads a positionEncoding name, e.g. from a plugin.  ok is false for one
+// that is not known.
+parse_position_encoding :: proc(name: string) -> (encoding: Position_Encoding, ok: bool) {
+	for n, e in POSITION_ENCODING_NAMES {
+		if n == name {
+			return e, true
+		}
+	}
+	return .Utf16, false
+}
+
+// The byte offset of a position in text, whose lines end in "\n" or "\r\n".
+// A character past the end of its line means the line's end, as the protocol
+// says; ok is false for a line past the end of the text.
+lsp_offset :: proc(
+	text: string,
+	p: Lsp_Position,
+	encoding := Position_Encoding.Utf16,
+) -> (
+	offset: int,
+	ok: bool,
+) {
+	if p.line < 0 || p.character < 0 {
+		return 0, false
+	}
+	start := 0
+	for _ in 0 ..< p.line {
+		next := strings.index_byte(text[start:], '\n')
+		if next < 0 {
+			return 0, false
+		}
+		start += next + 1
+	}
+	return start + lsp_column(lsp_line(text[start:]), p.character, encoding), true
+}
+
+// The position of a byte offset in text, for the wire.  An offset inside a
+// character counts as that character's start.
+lsp_position :: proc(
+	text: string,
+	offset: int,
+	encoding := Position_Encoding.Utf16,
+) -> Lsp_Position {
+	offset := clamp(offset, 0, len(text))
+	start := strings.last_index_byte(text[:offset], '\n') + 1
+	line := strings.count(text[:start], "\n")
+	return {line, lsp_character(lsp_line(text[start:]), offset - start, encoding)}
+}
+
+// The bytes of line before the character-th character in encoding, stopping
+// at its end.
+lsp_column :: proc(line: string, character: int, encoding: Position_Encoding) -> int {
+	if encoding == .Utf8 {
+		// A column inside a character moves back to its start.
+		col := min(character, len(line))
+		for col > 0 && col < len(line) && !utf8.rune_start(line[col]) {
+			col -= 1
+		}
+		return col
+	}
+	units, i := 0, 0
+	for i < len(line) && units < character {
+		r, size := utf8.decode_rune_in_string(line[i:])
+		units += 2 if encoding == .Utf16 && r >= 0x10000 else 1
+		i += size
+	}
+	return i
+}
+
+// How many characters in encoding the first col bytes of line hold.
+lsp_character :: proc(line: string, col: int, encoding: Position_Encoding) -> int {
+	col := min(col, len(line))
+	for col > 0 && col < len(line) && !utf8.rune_start(line[col]) {
+		col -= 1
+	}
+	switch encoding {
+	case .Utf8:
+		return col
+	case .Utf32:
+		return utf8.rune_count_in_string(line[:col])
+	case .Utf16:
+		units := 0
+		for r in line[:col] {
+			units += 2 if r >= 0x10000 else 1
+		}
+		return units
+	}
+	return col
+}
+
+// The line text starts with, without its line break.
+@(private = "file")
+lsp_line :: proc(text: string) -> string {
+	if end := strings.index_byte(text, '\n'); end >= 0 {
+		return strings.trim_suffix(text[:end], "\r")
+	}
+	return text
+}
@@ -0,0 +1,156 @@
+package editor
+
+import "core:strconv"
+import "core:strings"
+
+// The part of a prose line before its words: indentation, blockquote marks
+// and a list marker, as in "  > 3. " or "- [x] ".
+Prose_Prefix :: struct {
+	text:    string, // as written, a slice of the line
+	next:    string, // starts the line of the next item: "  > 4. ", "- [ ] "
+	hanging: string, // starts a wrapped line of the same item: "  >    "
+	list:    bool, // text ends with a list marker
+}
+
+// Splits the prefix off a line of Markdown or plain text.  Allocates next and
+// hanging when they differ from text.
+parse_prose_prefix :: proc(line: string, allocator := context.temp_allocator) -> Prose_Prefix {
+	i := 0
+	for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+		i += 1
+	}
+	for i < len(line) && line[i] == '>' {
+		i += 1
+		for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+			i += 1
+		}
+	}
+	quote := line[:i]
+	p := Prose_Prefix {
+		text    = quote,
+		next    = quote,
+		hanging = quote,
+	}
+
+	rest := line[i:]
+	marker, next_marker: string
+	if len(rest) >= 2 && strings.index_byte("-*+", rest[0]) >= 0 && rest[1] == ' ' {
+		marker, next_marker = rest[:2], rest[:2]
+	} else {
+		digits := 0
+		for digits < len(rest) && rest[digits] >= '0' && rest[digits] <= '9' {
+			digits += 1
+		}
+		if digits == 0 || digits > 9 || digits + 1 >= len(rest) || rest[digits + 1] != ' ' {
+			return p
+		}
+		if rest[digits] != '.' && rest[digits] != ')' {
+			return p
+		}
+		n, _ := strconv.parse_int(rest[:digits], 10)
+		buf: [16]u8
+		marker = rest[:digits + 2]
+		number := strconv.itoa(buf[:], n + 1)
+		next_marker = strings.concatenate({number, rest[digits:][:2]}, allocator)
+	}
+	// A task list item; the next one starts unchecked.
+	after := rest[len(marker):]
+	if len(after) >= 4 && after[0] == '[' && after[2] == ']' && after[3] == ' ' {
+		if strings.index_byte(" xX", after[1]) >= 0 {
+			marker = rest[:len(marker) + 4]
+			next_marker = strings.concatenate({next_marker, "[ ] "}, allocator)
+		}
+	}
+	p.list = true
+	p.text = line[:i + len(marker)]
+	p.next = strings.concatenate({quote, next_marker}, allocator)
+	p.hanging = strings.concatenate({quote, strings.repeat(" ", len(marker), allocator)}, allocator)
+	return p
+}
+
+// Columns s takes on screen, tabs expanded from column 0.
+text_columns :: proc(s: string, tab_size: int) -> int {
+	ts := max(tab_size, 1)
+	cols := 0
+	for i := 0; i < len(s); {
+		next := next_grapheme(s, i)
+		if s[i] == '\t' {
+			cols = (cols / ts + 1) * ts
+		} else {
+			cols += grapheme_width(s[i:next])
+		}
+		i = next
+	}
+	return cols
+}
+
+// Fills words into lines of at most width columns, the first starting with
+// first and the others with hanging.  A word wider than that gets a line of
+// its own.
+fill_words :: proc(
+	words: []string,
+	first, hanging: string,
+	width, tab_size: int,
+	allocator := context.temp_allocator,
+) -> string {
+	b := strings.builder_make(allocator)
+	strings.write_string(&b, first)
+	col := text_columns(first, tab_size)
+	line_empty := true
+	for w in words {
+		w_cols := text_columns(w, tab_size)
+		if !line_empty && col + 1 + w_cols > width {
+			strings.write_byte(&b, '\n')
+			strings.write_string(&b, hanging)
+			col = text_columns(hanging, tab_size)
+			line_empty = true
+		}
+		if !line_empty {
+			strings.write_byte(&b, ' ')
+			col += 1
+		}
+		strings.write_string(&b, w)
+		col += w_cols
+		line_empty = false
+	}
+	return strings.to_string(b)
+}
+
+// Where the sentences of text start.  A sentence ends at '.', '!' or '?'
+// followed, past closing quotes and brackets, by white space, and at a blank
+// line.  Temp allocated.
+sentence_starts :: proc(text: string) -> []int {
+	starts := make([dynamic]int, context.temp_allocator)
+	between := true // the next non-blank starts a sentence
+	for i := 0; i < len(text); i += 1 {
+		c := text[i]
+		if between {
+			if !strings.is_space(rune(c)) {
+				append(&starts, i)
+				between = false
+			}
+			continue
+		}
+		switch c {
+		case '.', '!', '?':
+			j := i + 1
+			for j < len(text) && strings.index_byte("\"')]*_", text[j]) >= 0 {
+				j += 1
+			}
+			if j >= len(text) || strings.is_space(rune(text[j])) {
+				between = true
+				i = j - 1
+			}
+		case '\n':
+			j := i + 1
+			for j < len(text) && (text[j] == ' ' || text[j] == '\t' || text[j] == '\r') {
+				j += 1
+			}
+			if j < len(text) && text[j] == '\n' {
+				between = true
+				i = j
+			}
+		}
+	}
+	return starts[:]
+}
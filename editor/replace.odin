@@ -0,0 +1,73 @@
+package editor
+
+import "core:mem"
+import "core:strings"
+import "core:text/regex"
+
+// Replaces every match of re in line with template, or the first limit
+// matches when limit is not negative.  In the template $0-$9 expand to
+// capture groups and $$ is a literal '$'.  Returns the new line and the
+// number of substitutions.
+regex_replace_all :: proc(
+	re: regex.Regular_Expression,
+	line, template: string,
+	capture: ^regex.Capture,
+	allocator: mem.Allocator = context.allocator,
+	limit := -1,
+) -> (
+	result: string,
+	count: int,
+) {
+	b := strings.builder_make(allocator)
+	offset := 0
+	for offset <= len(line) && count != limit {
+		rest := line[offset:]
+		groups, ok := regex.match(re, rest, capture)
+		if !ok {
+			break
+		}
+		start, end := capture.pos[0][0], capture.pos[0][1]
+		strings.write_string(&b, rest[:start])
+		expand_template(&b, template, rest, capture.pos[:groups])
+		count += 1
+
+		if end > start {
+			offset += end
+		} else {
+			// An empty match keeps the next byte and moves past it.
+			if start < len(rest) {
+				strings.write_byte(&b, rest[start])
+			}
+			offset += start + 1
+		}
+	}
+	if offset < len(line) {
+		strings.write_string(&b, line[offset:])
+	}
+	return strings.to_string(b), count
+}
+
+@(private = "file")
+expand_template :: proc(b: ^strings.Builder, template, subject: string, groups: [][2]int) {
+	for i := 0; i < len(template); i += 1 {
+		c := template[i]
+		if c != '$' || i + 1 >= len(template) {
+			strings.write_byte(b, c)
+			continue
+		}
+		next := template[i + 1]
+		switch {
+		case next == '$':
+			strings.write_byte(b, '$')
+			i += 1
+		case next >= '0' && next <= '9':
+			n := int(next - '0')
+			if n < len(groups) && groups[n][0] >= 0 {
+				strings.write_string(b, subject[groups[n][0]:groups[n][1]])
+			}
+			i += 1
+		case:
+			strings.write_byte(b, c)
+		}
+	}
+}
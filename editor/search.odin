@@ -0,0 +1,284 @@
+package editor
+
+import "core:mem"
+import "core:os"
+import "core:path/filepath"
+import "core:strings"
+import "core:sync"
+import "core:text/regex"
+import "core:thread"
+
+SEARCH_MAX_FILE_SIZE :: 8 * 1024 * 1024
+
+Search_Options :: struct {
+	pattern:          string,
+	root:             string,
+	extra_roots:      []string, // searched too; paths under them are absolute
+	case_insensitive: bool,
+	context_lines:    int,
+	max_matches:      int, // stop after this many matches, 0 for no limit
+	workers:          int,
+}
+
+Search_Match :: struct {
+	line:   int, // 0 based
+	col:    int, // byte column of the match start
+	length: int,
+	text:   string,
+	before: []string, // up to context_lines lines preceding the match
+	after:  []string,
+}
+
+// All matches of one file.  Files are published whole so results arrive
+// already grouped.
+Search_File_Result :: struct {
+	path:    string, // relative to the search root, slash separated; absolute under an extra root
+	matches: []Search_Match,
+}
+
+Project_Search :: struct {
+	options:     Search_Options,
+	re:          regex.Regular_Expression,
+	ignore:      Ignore_Rules,
+	files:       [dynamic]string,
+	next_file:   int, // atomic index into files
+	mutex:       sync.Mutex,
+	results:     [dynamic]Search_File_Result, // guarded by mutex
+	match_count: int, // atomic
+	cancelled:   bool, // atomic
+	finished:    bool, // atomic, set once every worker is done
+	walker:      ^thread.Thread,
+	allocator:   mem.Allocator,
+}
+
+// Compiles the pattern and starts the search in the background.  Poll
+// results with take_search_results.
+start_project_search :: proc(
+	options: Search_Options,
+	allocator: mem.Allocator = context.allocator,
+) -> (
+	search: ^Project_Search,
+	err: regex.Error,
+) {
+	flags: regex.Flags
+	if options.case_insensitive {
+		flags += {.Case_Insensitive}
+	}
+	re := regex.create(options.pattern, flags, allocator) or_return
+
+	search = new(Project_Search, allocator)
+	search.options = options
+	search.options.pattern = strings.clone(options.pattern, allocator)
+	search.options.root = strings.clone(options.root, allocator)
+	search.options.extra_roots = make([]string, len(options.extra_roots), allocator)
+	for root, i in options.extra_roots {
+		search.options.extra_roots[i] = strings.clone(root, allocator)
+	}
+	if search.options.workers <= 0 {
+		search.options.workers = 4
+	}
+	search.re = re
+	search.ignore = init_ignore_rules(allocator)
+	search.files = make([dynamic]string, allocator)
+	search.results = make([dynamic]Search_File_Result, allocator)
+	search.allocator = allocator
+
+	search.walker = thread.create_and_start_with_poly_data(search, run_search)
+	return search, nil
+}
+
+cancel_project_search :: proc(search: ^Project_Search) {
+	sync.atomic_store(&search.cancelled, true)
+}
+
+is_search_finished :: proc(search: ^Project_Search) -> bool {
+	return sync.atomic_load(&search.finished)
+}
+
+// Moves every result published since the last call into `out`.  The caller
+// owns the moved results and frees them with destroy_search_file_result.
+take_search_results :: proc(search: ^Project_Search, out: ^[dynamic]Search_File_Result) {
+	sync.guard(&search.mutex)
+	append(out, ..search.results[:])
+	clear(&search.results)
+}
+
+destroy_search_file_result :: proc(r: Search_File_Result, allocator: mem.Allocator) {
+	for m in r.matches {
+		delete(m.text, allocator)
+		for s in m.before do delete(s, allocator)
+		for s in m.after do delete(s, allocator)
+		delete(m.before, allocator)
+		delete(m.after, allocator)
+	}
+	delete(r.matches, allocator)
+	delete(r.path, allocator)
+}
+
+// Cancels (if still running), waits for the workers and frees everything.
+destroy_project_search :: proc(search: ^Project_Search) {
+	cancel_project_search(search)
+	thread.join(search.walker)
+	thread.destroy(search.walker)
+
+	allocator := search.allocator
+	for r in search.results {
+		destroy_search_file_result(r, allocator)
+	}
+	delete(search.results)
+	for f in search.files {
+		delete(f, allocator)
+	}
+	delete(search.files)
+	destroy_ignore_rules(&search.ignore)
+	regex.destroy(search.re, allocator)
+	delete(search.options.pattern, allocator)
+	delete(search.options.root, allocator)
+	for root in search.options.extra_roots {
+		delete(root, allocator)
+	}
+	delete(search.options.extra_roots, allocator)
+	free(search, allocator)
+}
+
+@(private = "file")
+run_search :: proc(search: ^Project_Search) {
+	ignore, cancelled := &search.ignore, &search.cancelled
+	walk_directory(ignore, cancelled, search.options.root, "", search, add_search_file)
+	for root in search.options.extra_roots {
+		walk_directory(ignore, cancelled, root, root, search, add_search_file)
+	}
+
+	workers := make([]^thread.Thread, search.options.workers, context.temp_allocator)
+	for &w in workers {
+		w = thread.create_and_start_with_poly_data(search, search_worker)
+	}
+	for w in workers {
+		thread.join(w)
+		thread.destroy(w)
+	}
+	free_all(context.temp_allocator)
+	sync.atomic_store(&search.finished, true)
+}
+
+// Walks dir, which rel_dir names relative to the root, and calls visit with
+// the relative path of every regular file up to SEARCH_MAX_FILE_SIZE that
+// ignore does not rule out.  .git is skipped; the walk stops once cancelled.
+@(private)
+walk_directory :: proc(
+	ignore: ^Ignore_Rules,
+	cancelled: ^bool,
+	dir, rel_dir: string,
+	data: ^$T,
+	visit: proc(data: ^T, rel: string),
+) {
+	if sync.atomic_load(cancelled) {
+		return
+	}
+	load_gitignore(ignore, dir, rel_dir)
+
+	entries, err := os.read_all_directory_by_path(dir, context.allocator)
+	if err != nil {
+		return
+	}
+	defer os.file_info_slice_delete(entries, context.allocator)
+
+	for fi in entries {
+		if fi.name == ".git" {
+			continue
+		}
+		rel := fi.name
+		if rel_dir != "" {
+			rel = strings.concatenate({rel_dir, "/", fi.name}, context.temp_allocator)
+		}
+		is_dir := fi.type == .Directory
+		if is_ignored(ignore, rel, is_dir) {
+			continue
+		}
+		if is_dir {
+			walk_directory(ignore, cancelled, fi.fullpath, rel, data, visit)
+		} else if fi.type == .Regular && fi.size <= SEARCH_MAX_FILE_SIZE {
+			visit(data, rel)
+		}
+	}
+}
+
+@(private = "file")
+add_search_file :: proc(search: ^Project_Search, rel: string) {
+	append(&search.files, strings.clone(rel, search.allocator))
+}
+
+@(private = "file")
+search_worker :: proc(search: ^Project_Search) {
+	capture := regex.preallocate_capture()
+	defer regex.destroy(capture)
+
+	for !sync.atomic_load(&search.cancelled) {
+		idx := sync.atomic_add(&search.next_file, 1)
+		if idx >= len(search.files) {
+			break
+		}
+		search_file(search, search.files[idx], &capture)
+		free_all(context.temp_allocator)
+	}
+}
+
+@(private = "file")
+search_file :: proc(search: ^Project_Search, rel: string, capture: ^regex.Capture) {
+	full := rel
+	if !filepath.is_abs(rel) {
+		full = filepath.join({search.options.root, rel}, context.temp_allocator)
+	}
+	data, err := os.read_entire_file_from_path(full, context.temp_allocator)
+	if err != nil {
+		return
+	}
+	if is_binary(data) {
+		return
+	}
+
+	lines := strings.split_lines(string(data), context.temp_allocator)
+	matches := make([dynamic]Search_Match, search.allocator)
+	ctx_lines := search.options.context_lines
+	limit := search.options.max_matches
+
+	for line, ln in lines {
+		if _, ok := regex.match(search.re, line, capture); !ok {
+			continue
+		}
+		if limit > 0 && sync.atomic_add(&search.match_count, 1) >= limit {
+			sync.atomic_store(&search.cancelled, true)
+			break
+		}
+
+		m := Search_Match {
+			line   = ln,
+			col    = capture.pos[0][0],
+			length = capture.pos[0][1] - capture.pos[0][0],
+			text   = strings.clone(strings.trim_right(line, "\r"), search.allocator),
+		}
+		first := max(ln - ctx_lines, 0)
+		m.before = make([]string, ln - first, search.allocator)
+		for i in first ..< ln {
+			m.before[i - first] = strings.clone(strings.trim_right(lines[i], "\r"), search.allocator)
+		}
+		last := min(ln + ctx_lines, len(lines) - 1)
+		m.after = make([]string, last - ln, search.allocator)
+		for i in ln + 1 ..= last {
+			m.after[i - ln - 1] = strings.clone(strings.trim_right(lines[i], "\r"), search.allocator)
+		}
+		append(&matches, m)
+	}
+
+	if len(matches) == 0 {
+		delete(matches)
+		return
+	}
+
+	result := Search_File_Result {
+		path    = strings.clone(rel, search.allocator),
+		matches = matches[:],
+	}
+	sync.guard(&search.mutex)
+	append(&search.results, result)
+}
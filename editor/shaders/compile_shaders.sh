@@ -4,4 +4,5 @@ glslangValidator -V text.vert.glsl -o text.vert.spv
 glslangValidator -V text.frag.glsl -o text.frag.spv
 glslangValidator -V solid.vert.glsl -o solid.vert.spv
 glslangValidator -V solid.frag.glsl -o solid.frag.spv
+glslangValidator -V image.frag.glsl -o image.frag.spv
 echo "Shaders COmpiled"
@@ -0,0 +1,12 @@
+#version 450
+
+layout(set = 0, binding = 0) uniform sampler2D image_sampler;
+
+layout (location = 0) in vec2 frag_uv;
+layout (location = 1) in vec4 frag_color;
+
+layout(location = 0) out vec4 out_color;
+
+void main() {
+    out_color = texture(image_sampler, frag_uv) * frag_color;
+}
@@ -0,0 +1,143 @@
+package editor
+
+import "core:slice"
+import "core:strconv"
+import "core:strings"
+
+// Reads a snippet in the syntax language servers use: $1 or ${1:default}
+// are tab stops, visited in number order, and $0 is where the cursor ends,
+// after the last of them when there is none.  \$, \} and \\ stand for
+// themselves.  Returns the text to insert and the stops' byte ranges in it,
+// in the order they are visited.  Temp allocated.
+parse_snippet :: proc(snippet: string) -> (text: string, stops: [][2]int) {
+	Stop :: struct {
+		number: int,
+		start:  int,
+		end:    int,
+	}
+	b := strings.builder_make(context.temp_allocator)
+	found := make([dynamic]Stop, context.temp_allocator)
+	open := make([dynamic]Stop, context.temp_allocator) // ${n: not yet closed
+	for i := 0; i < len(snippet); i += 1 {
+		c := snippet[i]
+		next := snippet[i + 1] if i + 1 < len(snippet) else 0
+		switch {
+		case c == '\\' && strings.contains_rune("$}\\", rune(next)):
+			i += 1
+			strings.write_byte(&b, snippet[i])
+		case c == '}' && len(open) > 0:
+			stop := pop(&open)
+			stop.end = strings.builder_len(b)
+			append(&found, stop)
+		case c == '$' && is_stop_digit(next):
+			j := i + 1
+			for j < len(snippet) && is_stop_digit(snippet[j]) {
+				j += 1
+			}
+			n, _ := strconv.parse_int(snippet[i + 1:j], 10)
+			at := strings.builder_len(b)
+			append(&found, Stop{n, at, at})
+			i = j - 1
+		case strings.has_prefix(snippet[i:], "${"):
+			j := i + 2
+			for j < len(snippet) && is_stop_digit(snippet[j]) {
+				j += 1
+			}
+			if j == i + 2 || j >= len(snippet) || (snippet[j] != ':' && snippet[j] != '}') {
+				strings.write_byte(&b, c)
+				continue
+			}
+			n, _ := strconv.parse_int(snippet[i + 2:j], 10)
+			at := strings.builder_len(b)
+			if snippet[j] == '}' {
+				append(&found, Stop{n, at, at})
+			} else {
+				append(&open, Stop{n, at, at})
+			}
+			i = j
+		case:
+			strings.write_byte(&b, c)
+		}
+	}
+	text = strings.to_string(b)
+	slice.stable_sort_by(found[:], proc(a, b: Stop) -> bool {
+		// $0 goes last.
+		return a.number != 0 && (b.number == 0 || a.number < b.number)
+	})
+	result := make([dynamic][2]int, context.temp_allocator)
+	for s in found {
+		append(&result, [2]int{s.start, s.end})
+	}
+	if len(found) == 0 || found[len(found) - 1].number != 0 {
+		append(&result, [2]int{len(text), len(text)})
+	}
+	return text, result[:]
+}
+
+@(private = "file")
+is_stop_digit :: proc(c: u8) -> bool {
+	return c >= '0' && c <= '9'
+}
+
+// Escapes text so a snippet inserts it as it is.  Temp allocated.
+escape_snippet :: proc(text: string) -> string {
+	b := strings.builder_make(context.temp_allocator)
+	for i in 0 ..< len(text) {
+		if strings.contains_rune("$}\\", rune(text[i])) {
+			strings.write_byte(&b, '\\')
+		}
+		strings.write_byte(&b, text[i])
+	}
+	return strings.to_string(b)
+}
+
+// The parameters of name's definition, as a call's placeholders: what is
+// between the brackets after the name, split at the commas outside nested
+// brackets, without default values or a receiver such as self.  ok is
+// false when no "(" follows the name closely.  Temp allocated.
+call_parameters :: proc(definition, name: string) -> (params: []string, ok: bool) {
+	at := strings.index(definition, name)
+	if at < 0 {
+		return nil, false
+	}
+	rest := definition[at + len(name):]
+	open := strings.index_byte(rest, '(')
+	// Only a short run like " :: proc", " = function" or "<T>" comes between.
+	if open < 0 || open > 40 || strings.contains_any(rest[:open], ";{})") {
+		return nil, false
+	}
+	list := make([dynamic]string, context.temp_allocator)
+	depth, start := 0, open + 1
+	for i := open + 1; i < len(rest); i += 1 {
+		switch rest[i] {
+		case '(', '[', '{':
+			depth += 1
+		case ')', ']', '}':
+			if depth == 0 {
+				add_call_parameter(&list, rest[start:i])
+				return list[:], true
+			}
+			depth -= 1
+		case ',':
+			if depth == 0 {
+				add_call_parameter(&list, rest[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return nil, false
+}
+
+@(private = "file")
+add_call_parameter :: proc(list: ^[dynamic]string, param: string) {
+	p := strings.trim_space(param)
+	if eq := strings.index_byte(p, '='); eq > 0 {
+		p = strings.trim_right(p[:eq], " \t:")
+	}
+	p, _ = strings.replace_all(p, "\n", " ", context.temp_allocator)
+	switch p {
+	case "", "self", "&self", "&mut self", "mut self", "cls", "void", "/", "*":
+		return
+	}
+	append(list, p)
+}
@@ -0,0 +1,158 @@
+package editor
+
+import "core:mem"
+import "core:slice"
+import "core:strconv"
+import "core:strings"
+
+// A ctags file, as written by ctags -R or kept up to date by gutentags:
+// one tag a line, "name<tab>file<tab>address;"<tab>fields", sorted here by
+// name ignoring case so that looking up a prefix is a binary search.  The
+// tags point into data.
+Tags_File :: struct {
+	data:      string,
+	tags:      []Tag,
+	allocator: mem.Allocator,
+}
+
+Tag :: struct {
+	name:    string,
+	path:    string, // relative to the tags file's directory unless absolute
+	line:    int, // 0 based, -1 when only the pattern is known
+	pattern: string, // as written between the slashes, "" for a line number
+	kind:    string, // ctags' letter or name, such as "f" or "function"
+}
+
+// Reads the tags in data, which the result keeps.
+parse_tags :: proc(data: string, allocator := context.allocator) -> ^Tags_File {
+	file := new(Tags_File, allocator)
+	file.data = data
+	file.allocator = allocator
+	tags := make([dynamic]Tag, allocator)
+	rest := data
+	for line in strings.split_lines_iterator(&rest) {
+		if strings.has_prefix(line, "!_TAG_") {
+			continue
+		}
+		if tag, ok := parse_tag_line(strings.trim_right(line, "\r")); ok {
+			append(&tags, tag)
+		}
+	}
+	slice.sort_by(tags[:], proc(a, b: Tag) -> bool {
+		if order := compare_folded(a.name, b.name); order != .Equal {
+			return order == .Less
+		}
+		return a.path < b.path
+	})
+	file.tags = tags[:]
+	return file
+}
+
+destroy_tags :: proc(file: ^Tags_File) {
+	delete(file.tags, file.allocator)
+	delete(file.data, file.allocator)
+	free(file, file.allocator)
+}
+
+// The tags whose names start with prefix, ignoring case.
+tags_with_prefix :: proc(file: ^Tags_File, prefix: string) -> []Tag {
+	return with_prefix(file.tags, prefix, proc(t: Tag) -> string {return t.name})
+}
+
+// The tags named name exactly.  Temp allocated.
+find_tags :: proc(file: ^Tags_File, name: string) -> []Tag {
+	found := make([dynamic]Tag, context.temp_allocator)
+	for t in tags_with_prefix(file, name) {
+		if t.name == name {
+			append(&found, t)
+		}
+	}
+	return found[:]
+}
+
+// The line of text a tag's pattern finds, 0 based, or -1.  Patterns match at
+// the start of a line, and the whole line when they end in $.
+find_tag_pattern :: proc(text, pattern: string) -> int {
+	want := pattern
+	whole := false
+	if strings.has_prefix(want, "^") {
+		want = want[1:]
+	}
+	if strings.has_suffix(want, "$") && !strings.has_suffix(want, "\\$") {
+		want = want[:len(want) - 1]
+		whole = true
+	}
+	b := strings.builder_make(context.temp_allocator)
+	for i := 0; i < len(want); i += 1 {
+		if want[i] == '\\' && i + 1 < len(want) {
+			i += 1
+		}
+		strings.write_byte(&b, want[i])
+	}
+	want = strings.to_string(b)
+	n := 0
+	rest := text
+	for line in strings.split_lines_iterator(&rest) {
+		line_text := strings.trim_right(line, "\r")
+		if line_text == want || (!whole && strings.has_prefix(line_text, want)) {
+			return n
+		}
+		n += 1
+	}
+	return -1
+}
+
+// One line of a tags file: the name and file, the address, a line number or
+// a /pattern/ (?pattern? searching back), then after ;" the extension fields,
+// a bare kind letter or key:value pairs such as line:12.
+@(private = "file")
+parse_tag_line :: proc(line: string) -> (tag: Tag, ok: bool) {
+	name, _, rest := strings.partition(line, "\t")
+	path, _, address := strings.partition(rest, "\t")
+	if name == "" || path == "" || address == "" {
+		return {}, false
+	}
+	tag = {name = name, path = path, line = -1}
+	fields := ""
+	if address[0] == '/' || address[0] == '?' {
+		end := 1
+		for end < len(address) && address[end] != address[0] {
+			if address[end] == '\\' {
+				end += 1
+			}
+			end += 1
+		}
+		if end >= len(address) {
+			return {}, false
+		}
+		tag.pattern = address[1:end]
+		fields = address[end + 1:]
+	} else {
+		digits := 0
+		for digits < len(address) && address[digits] >= '0' && address[digits] <= '9' {
+			digits += 1
+		}
+		n, parsed := strconv.parse_int(address[:digits], 10)
+		if !parsed || n < 1 {
+			return {}, false
+		}
+		tag.line = n - 1
+		fields = address[digits:]
+	}
+	fields = strings.trim_prefix(fields, ";\"")
+	for field in strings.split_iterator(&fields, "\t") {
+		key, colon, value := strings.partition(field, ":")
+		switch {
+		case field == "":
+		case colon == "":
+			tag.kind = field
+		case key == "kind":
+			tag.kind = value
+		case key == "line":
+			if n, parsed := strconv.parse_int(value, 10); parsed && n >= 1 {
+				tag.line = n - 1
+			}
+		}
+	}
+	return tag, true
+}
@@ -0,0 +1,184 @@
+package editor
+
+import "core:mem"
+import "core:os"
+import "core:strconv"
+import "core:strings"
+import "core:sync"
+import "core:thread"
+
+// A command running in the background with its stdout and stderr collected
+// into one stream as they arrive.
+Task_Run :: struct {
+	process:   os.Process,
+	pipe:      ^os.File, // read end of the output
+	mutex:     sync.Mutex,
+	output:    [dynamic]u8, // guarded by mutex, emptied by take_task_output
+	exit_code: int,
+	finished:  bool, // atomic
+	thread:    ^thread.Thread,
+	allocator: mem.Allocator,
+}
+
+// Starts command (program and arguments) in dir.  The command reads stdin
+// when it is given and nothing otherwise.  Its error output goes to stderr
+// when that is given, and into the collected stream otherwise.
+start_task :: proc(
+	command: []string,
+	dir: string,
+	stdin: ^os.File = nil,
+	stderr: ^os.File = nil,
+	allocator: mem.Allocator = context.allocator,
+) -> (
+	run: ^Task_Run,
+	err: os.Error,
+) {
+	r, w := os.pipe() or_return
+	desc := os.Process_Desc {
+		working_dir = dir,
+		command     = command,
+		stdin       = stdin,
+		stdout      = w,
+		stderr      = stderr if stderr != nil else w,
+	}
+	process, perr := os.process_start(desc)
+	os.close(w) // the child has its own copy
+	if perr != nil {
+		os.close(r)
+		return nil, perr
+	}
+
+	run = new(Task_Run, allocator)
+	run.process = process
+	run.pipe = r
+	run.output = make([dynamic]u8, allocator)
+	run.allocator = allocator
+	run.thread = thread.create_and_start_with_poly_data(run, read_task_output)
+	return run, nil
+}
+
+is_task_finished :: proc(run: ^Task_Run) -> bool {
+	return sync.atomic_load(&run.finished)
+}
+
+// Appends the output that arrived since the last call to out.
+take_task_output :: proc(run: ^Task_Run, out: ^strings.Builder) {
+	sync.guard(&run.mutex)
+	strings.write_bytes(out, run.output[:])
+	clear(&run.output)
+}
+
+cancel_task :: proc(run: ^Task_Run) {
+	if !is_task_finished(run) {
+		_ = os.process_kill(run.process)
+	}
+}
+
+// Kills the command if it still runs, waits for it and frees everything.
+destroy_task :: proc(run: ^Task_Run) {
+	cancel_task(run)
+	thread.join(run.thread)
+	thread.destroy(run.thread)
+	delete(run.output)
+	free(run, run.allocator)
+}
+
+@(private = "file")
+read_task_output :: proc(run: ^Task_Run) {
+	buf: [4096]u8
+	for {
+		n, err := os.read(run.pipe, buf[:])
+		if n > 0 {
+			sync.guard(&run.mutex)
+			append(&run.output, ..buf[:n])
+		}
+		if err != nil || n == 0 {
+			break
+		}
+	}
+	state, _ := os.process_wait(run.process)
+	run.exit_code = state.exit_code
+	_ = os.process_close(run.process)
+	os.close(run.pipe)
+	sync.atomic_store(&run.finished, true)
+}
+
+// A location found in tool output by an error format.
+Error_Match :: struct {
+	file:    string,
+	line:    int, // 1 based, 0 when the format has none
+	col:     int,
+	message: string,
+	kind:    u8, // from %t: 'e', 'w', ... or 0
+}
+
+// Matches line against error formats in the style of vim's errorformat and
+// returns the first match.  A format is literal text with these
+// placeholders:
+//
+//	%f  file name
+//	%l  line number
+//	%c  column number
+//	%m  message
+//	%t  one character error type, e.g. 'e' or 'w'
+//	%%  a literal '%'
+//
+// %f and %m take as little text as lets the rest of the format match.
+match_error_formats :: proc(formats: []string, line: string) -> (m: Error_Match, ok: bool) {
+	for f in formats {
+		m = {}
+		if match_error_format(f, line, &m) && m.file != "" {
+			return m, true
+		}
+	}
+	return {}, false
+}
+
+@(private = "file")
+match_error_format :: proc(f, s: string, m: ^Error_Match) -> bool {
+	if f == "" {
+		return s == ""
+	}
+	if f[0] != '%' || len(f) < 2 {
+		return s != "" && s[0] == f[0] && match_error_format(f[1:], s[1:], m)
+	}
+	rest := f[2:]
+	switch f[1] {
+	case 'l', 'c':
+		n := 0
+		for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+			n += 1
+		}
+		if n == 0 || !match_error_format(rest, s[n:], m) {
+			return false
+		}
+		value, _ := strconv.parse_int(s[:n], 10)
+		if f[1] == 'l' {
+			m.line = value
+		} else {
+			m.col = value
+		}
+		return true
+	case 't':
+		if s == "" || !match_error_format(rest, s[1:], m) {
+			return false
+		}
+		m.kind = s[0] | 0x20 // lower case
+		return true
+	case 'f', 'm':
+		for k in (1 if f[1] == 'f' else 0) ..= len(s) {
+			if match_error_format(rest, s[k:], m) {
+				if f[1] == 'f' {
+					m.file = s[:k]
+				} else {
+					m.message = s[:k]
+				}
+				return true
+			}
+		}
+		return false
+	case '%':
+		return s != "" && s[0] == '%' && match_error_format(rest, s[1:], m)
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package editor
+
+import "core:mem"
+import "core:strings"
+
+// Finds the lines that may start a test, in every language test discovery
+// knows, for a project search; test_name_at then has the final say.  Search
+// with one line of context: Odin and Rust put the attribute on a line of its
+// own, above the procedure.
+TEST_SEARCH_PATTERN :: `@\(test|#\[test\]|func Test|def test_`
+
+// A test found in a file.
+Test_Case :: struct {
+	name: string,
+	line: int, // 0 based, the line naming the test
+}
+
+// Returns the test that line declares, looking at the line after it for the
+// procedure when line only carries the attribute:
+//
+//	odin    @(test) followed by `name :: proc(t: ^testing.T)`
+//	rust    #[test] followed by `fn name()`
+//	go      func TestName(t *testing.T)
+//	python  def test_name(...), also as a method
+//
+// The name is a slice of line or next.
+test_name_at :: proc(language_id, line, next: string) -> (name: string, ok: bool) {
+	s := strings.trim_space(line)
+	switch language_id {
+	case "odin":
+		if !strings.has_prefix(s, "@(test") {
+			return "", false
+		}
+		rest := strings.trim_space(s[strings.index_byte(s, ')') + 1:])
+		if rest == "" {
+			rest = strings.trim_space(next)
+		}
+		name = leading_identifier(rest)
+		return name, name != "" && strings.contains(rest, "proc")
+	case "rust":
+		if !strings.has_prefix(s, "#[test]") {
+			return "", false
+		}
+		rest := strings.trim_space(s[len("#[test]"):])
+		if rest == "" {
+			rest = strings.trim_space(next)
+		}
+		for prefix in ([]string{"pub ", "async ", "fn "}) {
+			rest = strings.trim_prefix(rest, prefix)
+		}
+		name = leading_identifier(rest)
+		return name, name != ""
+	case "go":
+		if !strings.has_prefix(s, "func Test") {
+			return "", false
+		}
+		name = leading_identifier(s[len("func "):])
+		return name, name != ""
+	case "python":
+		s = strings.trim_prefix(s, "async ")
+		if !strings.has_prefix(s, "def test_") {
+			return "", false
+		}
+		name = leading_identifier(s[len("def "):])
+		return name, name != ""
+	}
+	return "", false
+}
+
+// The tests in text, a file of the given language, in order.  Names are
+// slices of text.
+find_tests :: proc(
+	language_id, text: string,
+	allocator: mem.Allocator = context.allocator,
+) -> []Test_Case {
+	tests := make([dynamic]Test_Case, allocator)
+	lines := strings.split_lines(text, context.temp_allocator)
+	for line, i in lines {
+		next := lines[i + 1] if i + 1 < len(lines) else ""
+		if name, ok := test_name_at(language_id, line, next); ok {
+			append(&tests, Test_Case{name, i})
+		}
+	}
+	return tests[:]
+}
+
+// Reports whether text mentions name as a whole identifier.
+mentions_identifier :: proc(text, name: string) -> bool {
+	from := 0
+	for name != "" {
+		i := strings.index(text[from:], name)
+		if i < 0 {
+			break
+		}
+		start := from + i
+		end := start + len(name)
+		before := start == 0 || !is_word_byte(text[start - 1])
+		after := end == len(text) || !is_word_byte(text[end])
+		if before && after {
+			return true
+		}
+		from = start + 1
+	}
+	return false
+}
+
+@(private = "file")
+leading_identifier :: proc(s: string) -> string {
+	n := 0
+	for n < len(s) && is_word_byte(s[n]) {
+		n += 1
+	}
+	return s[:n]
+}
@@ -0,0 +1,263 @@
+package editor
+
+import "core:encoding/json"
+import "core:strings"
+
+// Structural selection without a parser.  The ranges around a selection are
+// the word, the inside and then the whole of the string literal and of the
+// enclosing bracket pair, the lines, the indentation block, the block with
+// its header and closing lines, and the whole text.  Growing a selection
+// picks the smallest of them that is larger, so repeated growth walks word,
+// string, expression, block and function in that order.  Syntax tree nodes
+// should replace the guesses once there is a parser; until then ranges that
+// know the syntax, a language server's selection ranges, join the guesses.
+
+// Returns the smallest range around [start, end) that is larger, false when
+// the range already covers the whole text.  extra are more candidates, e.g.
+// from selection_range_chain.
+expand_range :: proc(
+	text: string,
+	start, end: int,
+	extra: [][2]int = nil,
+) -> (
+	new_start, new_end: int,
+	ok: bool,
+) {
+	candidates := make([dynamic][2]int, context.temp_allocator)
+	append(&candidates, ..extra)
+	word_range(text, start, end, &candidates)
+	string_ranges(text, start, end, &candidates)
+	bracket_ranges(text, start, end, &candidates)
+	line_ranges(text, start, end, &candidates)
+	append(&candidates, [2]int{0, len(text)})
+
+	best := [2]int{-1, -1}
+	for c in candidates {
+		if c[0] > start || c[1] < end || c[1] - c[0] <= end - start {
+			continue
+		}
+		if best[0] < 0 || c[1] - c[0] < best[1] - best[0] {
+			best = c
+		}
+	}
+	return best[0], best[1], best[0] >= 0
+}
+
+// The byte ranges of an LSP SelectionRange and its parents, innermost first,
+// in text.  reply is the JSON of one, or of the list textDocument/
+// selectionRange answers with, whose first is taken.  Ranges that do not fit
+// the text are left out.  Temp allocated.
+selection_range_chain :: proc(
+	text: string,
+	reply: string,
+	encoding := Position_Encoding.Utf16,
+) -> [][2]int {
+	chain := make([dynamic][2]int, context.temp_allocator)
+	value, err := json.parse_string(reply, allocator = context.temp_allocator)
+	if err != nil {
+		return nil
+	}
+	if list, is_list := value.(json.Array); is_list {
+		if len(list) == 0 {
+			return nil
+		}
+		value = list[0]
+	}
+	// Parents should only grow; a bound guards against a cycle.
+	for node := value; node != nil && len(chain) < 256; node = json_field(node, "parent") {
+		r := json_field(node, "range")
+		start, end := json_field(r, "start"), json_field(r, "end")
+		from := Lsp_Position{json_int(start, "line"), json_int(start, "character")}
+		to := Lsp_Position{json_int(end, "line"), json_int(end, "character")}
+		a, a_ok := lsp_offset(text, from, encoding)
+		b, b_ok := lsp_offset(text, to, encoding)
+		if a_ok && b_ok && a <= b {
+			append(&chain, [2]int{a, b})
+		}
+	}
+	return chain[:]
+}
+
+// The identifier the range is part of.
+@(private = "file")
+word_range :: proc(text: string, start, end: int, out: ^[dynamic][2]int) {
+	for i in start ..< end {
+		if !is_word_byte(text[i]) {
+			return
+		}
+	}
+	s, e := start, end
+	for s > 0 && is_word_byte(text[s - 1]) {
+		s -= 1
+	}
+	for e < len(text) && is_word_byte(text[e]) {
+		e += 1
+	}
+	append(out, [2]int{s, e})
+}
+
+// The quoted strings of the line the range starts on, without and with their
+// quotes.  A string does not span lines.
+@(private = "file")
+string_ranges :: proc(text: string, start, end: int, out: ^[dynamic][2]int) {
+	first := line_start_at(text, start)
+	last := line_end_at(text, start)
+	for i := first; i < last; {
+		q := text[i]
+		if q != '"' && q != '\'' && q != '`' {
+			i += 1
+			continue
+		}
+		j := i + 1
+		for j < last && text[j] != q {
+			if text[j] == '\\' {
+				j += 1
+			}
+			j += 1
+		}
+		if j >= last {
+			return // unterminated, e.g. an apostrophe in a comment
+		}
+		if i < start && j >= end {
+			append(out, [2]int{i + 1, j}, [2]int{i, j + 1})
+		}
+		i = j + 1
+	}
+}
+
+// The inside and the whole of the nearest bracket pair around the range.
+// Brackets of every kind count alike.
+@(private = "file")
+bracket_ranges :: proc(text: string, start, end: int, out: ^[dynamic][2]int) {
+	depth := 0
+	for open := start - 1; open >= 0; open -= 1 {
+		switch text[open] {
+		case ')', ']', '}':
+			depth += 1
+			continue
+		case '(', '[', '{':
+			if depth > 0 {
+				depth -= 1
+				continue
+			}
+		case:
+			continue
+		}
+		close := matching_close(text, open)
+		if close < 0 {
+			return
+		}
+		if close >= end {
+			append(out, [2]int{open + 1, close}, [2]int{open, close + 1})
+			return
+		}
+	}
+}
+
+@(private = "file")
+matching_close :: proc(text: string, open: int) -> int {
+	depth := 0
+	for i in open + 1 ..< len(text) {
+		switch text[i] {
+		case '(', '[', '{':
+			depth += 1
+		case ')', ']', '}':
+			if depth == 0 {
+				return i
+			}
+			depth -= 1
+		}
+	}
+	return -1
+}
+
+// The whole lines of the range, the indentation block around them and that
+// block with the less indented lines that open and close it.
+@(private = "file")
+line_ranges :: proc(text: string, start, end: int, out: ^[dynamic][2]int) {
+	first := line_start_at(text, start)
+	last := line_end_at(text, max(end - 1, start))
+	append(out, [2]int{first, last})
+
+	indent := -1
+	for i := first; i < last; i = line_end_at(text, i) {
+		line := line_at(text, i)
+		if strings.trim_space(line) != "" {
+			n := leading_whitespace(line)
+			indent = n if indent < 0 else min(indent, n)
+		}
+	}
+	if indent <= 0 {
+		return
+	}
+
+	// Grow over lines indented at least as deep, and blank ones between.
+	block_first, block_last := first, last
+	header, footer := -1, -1
+	for block_first > 0 {
+		prev := line_start_at(text, block_first - 1)
+		line := line_at(text, prev)
+		if strings.trim_space(line) != "" && leading_whitespace(line) < indent {
+			header = prev
+			break
+		}
+		block_first = prev
+	}
+	for block_last < len(text) {
+		line := line_at(text, block_last)
+		if strings.trim_space(line) != "" && leading_whitespace(line) < indent {
+			footer = block_last
+			break
+		}
+		block_last = line_end_at(text, block_last)
+	}
+	for block_first < first && strings.trim_space(line_at(text, block_first)) == "" {
+		block_first = line_end_at(text, block_first)
+	}
+	for block_last > last {
+		prev := line_start_at(text, block_last - 1)
+		if strings.trim_space(line_at(text, prev)) != "" {
+			break
+		}
+		block_last = prev
+	}
+	append(out, [2]int{block_first, block_last})
+
+	if header < 0 {
+		return
+	}
+	outer_last := block_last
+	if footer >= 0 {
+		closing := strings.trim_space(line_at(text, footer))
+		if strings.has_prefix(closing, "}") ||
+		   strings.has_prefix(closing, ")") ||
+		   strings.has_prefix(closing, "]") ||
+		   strings.has_prefix(closing, "end") {
+			outer_last = line_end_at(text, footer)
+		}
+	}
+	append(out, [2]int{header, outer_last})
+}
+
+// The start of the line pos is on.
+@(private = "file")
+line_start_at :: proc(text: string, pos: int) -> int {
+	i := strings.last_index_byte(text[:pos], '\n')
+	return i + 1
+}
+
+// The end of the line pos is on, after its line break.
+@(private = "file")
+line_end_at :: proc(text: string, pos: int) -> int {
+	i := strings.index_byte(text[pos:], '\n')
+	if i < 0 {
+		return len(text)
+	}
+	return pos + i + 1
+}
+
+// The line starting at pos without its line break.
+@(private = "file")
+line_at :: proc(text: string, pos: int) -> string {
+	return strings.trim_right(text[pos:line_end_at(text, pos)], "\r\n")
+}
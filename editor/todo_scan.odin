@@ -0,0 +1,218 @@
+package editor
+
+import "core:mem"
+import "core:os"
+import "core:path/filepath"
+import "core:strings"
+import "core:sync"
+import "core:thread"
+
+// The words that mark work left to do in a comment.
+TODO_TAGS := [?]string{"TODO", "FIXME", "HACK", "XXX"}
+
+// Longest text kept of a line with a tag.
+TODO_MAX_TEXT :: 200
+
+Todo_Item :: struct {
+	path: string, // relative to the scan root, slash separated; absolute under an extra root
+	line: int, // 0 based
+	col:  int, // byte column of the tag
+	tag:  string, // one of TODO_TAGS
+	text: string, // the comment from the tag on
+}
+
+// A walk of the workspace on a thread that collects the tags in comments,
+// and in Markdown and other prose at the start of headings and list items.
+// Files are published whole, in the order they were walked.
+Todo_Scan :: struct {
+	root:        string,
+	extra_roots: []string,
+	ignore:      Ignore_Rules,
+	mutex:       sync.Mutex,
+	files:       [dynamic]string, // as walked, then scanned in this order
+	items:       [dynamic]Todo_Item, // guarded by mutex
+	scanned:     int, // atomic, files scanned
+	cancelled:   bool, // atomic
+	finished:    bool, // atomic
+	walker:      ^thread.Thread,
+	allocator:   mem.Allocator,
+}
+
+start_todo_scan :: proc(
+	root: string,
+	extra_roots: []string,
+	allocator: mem.Allocator = context.allocator,
+) -> ^Todo_Scan {
+	scan := new(Todo_Scan, allocator)
+	scan.root = strings.clone(root, allocator)
+	scan.extra_roots = make([]string, len(extra_roots), allocator)
+	for r, i in extra_roots {
+		scan.extra_roots[i] = strings.clone(r, allocator)
+	}
+	scan.ignore = init_ignore_rules(allocator)
+	scan.files = make([dynamic]string, allocator)
+	scan.items = make([dynamic]Todo_Item, allocator)
+	scan.allocator = allocator
+	scan.walker = thread.create_and_start_with_poly_data(scan, run_todo_scan)
+	return scan
+}
+
+is_todo_scan_finished :: proc(scan: ^Todo_Scan) -> bool {
+	return sync.atomic_load(&scan.finished)
+}
+
+// Moves every item found since the last call into out.  The caller owns them
+// and frees them with destroy_todo_item.
+take_todo_items :: proc(scan: ^Todo_Scan, out: ^[dynamic]Todo_Item) {
+	sync.guard(&scan.mutex)
+	append(out, ..scan.items[:])
+	clear(&scan.items)
+}
+
+destroy_todo_item :: proc(item: Todo_Item, allocator: mem.Allocator) {
+	delete(item.path, allocator)
+	delete(item.text, allocator)
+}
+
+// Cancels the walk if it still runs, waits for it and frees everything.
+destroy_todo_scan :: proc(scan: ^Todo_Scan) {
+	sync.atomic_store(&scan.cancelled, true)
+	thread.join(scan.walker)
+	thread.destroy(scan.walker)
+
+	allocator := scan.allocator
+	for item in scan.items {
+		destroy_todo_item(item, allocator)
+	}
+	delete(scan.items)
+	for f in scan.files {
+		delete(f, allocator)
+	}
+	delete(scan.files)
+	destroy_ignore_rules(&scan.ignore)
+	delete(scan.root, allocator)
+	for r in scan.extra_roots {
+		delete(r, allocator)
+	}
+	delete(scan.extra_roots, allocator)
+	free(scan, allocator)
+}
+
+// Where a tag starts in a line of lang, and which: in a comment, or for prose
+// as the first word of a heading or list item.  Tags are whole words.
+find_todo_tag :: proc(line: string, lang: ^Language) -> (col: int, tag: string, ok: bool) {
+	if lang == nil {
+		return 0, "", false
+	}
+	from := -1
+	if lang.prose {
+		i := len(parse_prose_prefix(line).text)
+		for i < len(line) && (line[i] == '#' || line[i] == '*') {
+			i += 1
+		}
+		for i < len(line) && line[i] == ' ' {
+			i += 1
+		}
+		for t in TODO_TAGS {
+			if is_tag_at(line, i, t) {
+				return i, t, true
+			}
+		}
+	}
+	if lang.line_comment != "" {
+		from = strings.index(line, lang.line_comment)
+	}
+	if open := lang.block_comment[0]; open != "" {
+		if i := strings.index(line, open); i >= 0 && (from < 0 || i < from) {
+			from = i
+		} else if from < 0 && strings.has_prefix(strings.trim_left_space(line), "*") {
+			from = 0 // inside a /* ... */ block, as its lines usually go
+		}
+	}
+	if from < 0 {
+		return 0, "", false
+	}
+	for i in from ..< len(line) {
+		for t in TODO_TAGS {
+			if is_tag_at(line, i, t) {
+				return i, t, true
+			}
+		}
+	}
+	return 0, "", false
+}
+
+@(private = "file")
+is_tag_at :: proc(line: string, i: int, tag: string) -> bool {
+	if !strings.has_prefix(line[i:], tag) {
+		return false
+	}
+	if i > 0 && is_word_byte(line[i - 1]) {
+		return false
+	}
+	end := i + len(tag)
+	return end >= len(line) || !is_word_byte(line[end])
+}
+
+@(private = "file")
+run_todo_scan :: proc(scan: ^Todo_Scan) {
+	walk_directory(&scan.ignore, &scan.cancelled, scan.root, "", scan, add_todo_file)
+	for r in scan.extra_roots {
+		walk_directory(&scan.ignore, &scan.cancelled, r, r, scan, add_todo_file)
+	}
+	free_all(context.temp_allocator)
+	for rel in scan.files {
+		if sync.atomic_load(&scan.cancelled) {
+			break
+		}
+		scan_todo_file(scan, rel)
+		free_all(context.temp_allocator)
+	}
+	sync.atomic_store(&scan.finished, true)
+}
+
+@(private = "file")
+add_todo_file :: proc(scan: ^Todo_Scan, rel: string) {
+	append(&scan.files, strings.clone(rel, scan.allocator))
+}
+
+@(private = "file")
+scan_todo_file :: proc(scan: ^Todo_Scan, rel: string) {
+	full := rel
+	if !filepath.is_abs(rel) {
+		full = filepath.join({scan.root, rel}, context.temp_allocator)
+	}
+	lang := detect_language_by_path(full)
+	if lang.line_comment == "" && lang.block_comment[0] == "" && !lang.prose {
+		return
+	}
+	data, err := os.read_entire_file_from_path(full, context.temp_allocator)
+	if err != nil || is_binary(data) {
+		return
+	}
+	sync.atomic_add(&scan.scanned, 1)
+
+	found := make([dynamic]Todo_Item, context.temp_allocator)
+	for line, ln in strings.split_lines(string(data), context.temp_allocator) {
+		col, tag, ok := find_todo_tag(line, lang)
+		if !ok {
+			continue
+		}
+		rest := strings.trim_space(line[col:])
+		if close := lang.block_comment[1]; close != "" {
+			rest = strings.trim_space(strings.trim_suffix(rest, close))
+		}
+		rest = rest[:min(len(rest), TODO_MAX_TEXT)]
+		append(&found, Todo_Item{line = ln, col = col, tag = tag, text = rest})
+	}
+	if len(found) == 0 {
+		return
+	}
+	sync.guard(&scan.mutex)
+	for item in found {
+		item := item
+		item.path = strings.clone(rel, scan.allocator)
+		item.text = strings.clone(item.text, scan.allocator)
+		append(&scan.items, item)
+	}
+}
@@ -0,0 +1,352 @@
+package editor
+
+import "core:fmt"
+import "core:mem"
+import "core:strconv"
+import "core:strings"
+
+// A TOML value.  Tables do not appear as values: parse_toml flattens them
+// into dotted keys.
+Toml_Value :: union {
+	string,
+	i64,
+	f64,
+	bool,
+	[]Toml_Value,
+}
+
+// One key = value of a TOML document, with the key made absolute: `b = 1`
+// under `[a]` and `a = {b = 1}` both give "a.b".  The tables of an array
+// are numbered from 0: `b = 1` under the second `[[a]]` gives "a.1.b".
+Toml_Entry :: struct {
+	key:   string,
+	value: Toml_Value,
+	line:  int, // 1 based
+}
+
+Toml_Error :: struct {
+	line:    int, // 1 based
+	message: string,
+}
+
+// Parses the subset of TOML configuration files need: tables, dotted and
+// quoted keys, basic and literal strings, integers, floats, booleans, arrays,
+// inline tables and arrays of tables.  Multi-line strings are reported as
+// errors.  Everything, including err.message, is allocated with
+// allocator, so parse into an arena or the temp allocator.
+parse_toml :: proc(
+	text: string,
+	allocator: mem.Allocator = context.temp_allocator,
+) -> (
+	entries: []Toml_Entry,
+	err: Toml_Error,
+	ok: bool,
+) {
+	p := Toml_Parser {
+		s         = text,
+		line      = 1,
+		entries   = make([dynamic]Toml_Entry, allocator),
+		arrays    = make(map[string]int, allocator),
+		allocator = allocator,
+	}
+	for parse_toml_line(&p) {
+	}
+	if p.err.message != "" {
+		return nil, p.err, false
+	}
+	return p.entries[:], {}, true
+}
+
+// Parses a lone value, e.g. the right-hand side of a `:set`.
+parse_toml_value :: proc(
+	text: string,
+	allocator: mem.Allocator = context.temp_allocator,
+) -> (
+	Toml_Value,
+	bool,
+) {
+	p := Toml_Parser {
+		s         = strings.trim_space(text),
+		line      = 1,
+		allocator = allocator,
+	}
+	value, ok := parse_value(&p)
+	return value, ok && p.i == len(p.s)
+}
+
+@(private = "file")
+Toml_Parser :: struct {
+	s:         string,
+	i:         int,
+	line:      int,
+	table:     string, // key prefix of the current [table], with its dot
+	entries:   [dynamic]Toml_Entry,
+	arrays:    map[string]int, // tables so far of each [[array]]
+	allocator: mem.Allocator,
+	err:       Toml_Error,
+}
+
+// Parses one line's worth of document.  Returns false at the end or on
+// the first error.
+@(private = "file")
+parse_toml_line :: proc(p: ^Toml_Parser) -> bool {
+	skip_blank(p, true)
+	if p.i >= len(p.s) {
+		return false
+	}
+	if p.s[p.i] == '[' {
+		p.i += 1
+		array := peek(p) == '['
+		if array {
+			p.i += 1
+		}
+		key := parse_key(p) or_return
+		skip_blank(p, false)
+		close := "]]" if array else "]"
+		if !strings.has_prefix(p.s[p.i:], close) {
+			message := fmt.aprintf(
+				"expected '%s' after the table name",
+				close,
+				allocator = p.allocator,
+			)
+			return fail(p, message)
+		}
+		p.i += len(close)
+		if array {
+			n := p.arrays[key]
+			p.arrays[key] = n + 1
+			key = fmt.aprintf("%s.%d", key, n, allocator = p.allocator)
+		}
+		p.table = strings.concatenate({key, "."}, p.allocator)
+	} else {
+		key := parse_key(p) or_return
+		parse_assignment(p, strings.concatenate({p.table, key}, p.allocator)) or_return
+	}
+	return end_of_line(p)
+}
+
+// Parses `= value` for key, flattening an inline table.
+@(private = "file")
+parse_assignment :: proc(p: ^Toml_Parser, key: string) -> bool {
+	skip_blank(p, false)
+	if peek(p) != '=' {
+		return fail(p, "expected '=' after the key")
+	}
+	p.i += 1
+	skip_blank(p, false)
+	if peek(p) == '{' {
+		p.i += 1
+		for {
+			skip_blank(p, false)
+			if peek(p) == '}' {
+				p.i += 1
+				return true
+			}
+			sub := parse_key(p) or_return
+			parse_assignment(p, strings.concatenate({key, ".", sub}, p.allocator)) or_return
+			skip_blank(p, false)
+			if peek(p) == ',' {
+				p.i += 1
+			} else if peek(p) != '}' {
+				return fail(p, "expected ',' or '}' in the inline table")
+			}
+		}
+	}
+	line := p.line
+	value := parse_value(p) or_return
+	for e in p.entries {
+		if e.key == key {
+			message := fmt.aprintf(
+				"%s is already set on line %d",
+				key,
+				e.line,
+				allocator = p.allocator,
+			)
+			return fail(p, message)
+		}
+	}
+	append(&p.entries, Toml_Entry{key, value, line})
+	return true
+}
+
+// A dotted key of bare and quoted parts, returned with the quotes removed.
+@(private = "file")
+parse_key :: proc(p: ^Toml_Parser) -> (key: string, ok: bool) {
+	parts := make([dynamic]string, p.allocator)
+	for {
+		skip_blank(p, false)
+		switch c := peek(p); {
+		case c == '"' || c == '\'':
+			part := parse_string(p) or_return
+			append(&parts, part)
+		case is_bare_key_byte(c):
+			start := p.i
+			for p.i < len(p.s) && is_bare_key_byte(p.s[p.i]) {
+				p.i += 1
+			}
+			append(&parts, p.s[start:p.i])
+		case:
+			return "", fail(p, "expected a key")
+		}
+		skip_blank(p, false)
+		if peek(p) != '.' {
+			break
+		}
+		p.i += 1
+	}
+	return strings.join(parts[:], ".", p.allocator), true
+}
+
+@(private = "file")
+parse_value :: proc(p: ^Toml_Parser) -> (value: Toml_Value, ok: bool) {
+	c := peek(p)
+	switch {
+	case c == '"' || c == '\'':
+		s := parse_string(p) or_return
+		return s, true
+	case c == '[':
+		p.i += 1
+		items := make([dynamic]Toml_Value, p.allocator)
+		for {
+			skip_blank(p, true)
+			if peek(p) == ']' {
+				p.i += 1
+				return items[:], true
+			}
+			item := parse_value(p) or_return
+			append(&items, item)
+			skip_blank(p, true)
+			if peek(p) == ',' {
+				p.i += 1
+			} else if peek(p) != ']' {
+				return nil, fail(p, "expected ',' or ']' in the array")
+			}
+		}
+	case c == '{':
+		return nil, fail(p, "inline tables only work as the value of a key")
+	}
+
+	start := p.i
+	// Numbers, true and false, and the inf and nan of floats.
+	VALUE_BYTES :: "+-_.0123456789abcdefinoxABCDEFXOlrstu"
+	for p.i < len(p.s) && strings.index_byte(VALUE_BYTES, p.s[p.i]) >= 0 {
+		p.i += 1
+	}
+	word := p.s[start:p.i]
+	switch word {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	case "":
+		return nil, fail(p, "expected a value")
+	}
+	digits, _ := strings.remove_all(word, "_", p.allocator)
+	if n, nok := strconv.parse_i64(digits); nok {
+		return n, true
+	}
+	if f, fok := strconv.parse_f64(digits); fok {
+		return f, true
+	}
+	message := fmt.aprintf("%q is not a value; strings need quotes", word, allocator = p.allocator)
+	return nil, fail(p, message)
+}
+
+@(private = "file")
+parse_string :: proc(p: ^Toml_Parser) -> (s: string, ok: bool) {
+	quote := p.s[p.i]
+	if strings.has_prefix(p.s[p.i:], `"""`) || strings.has_prefix(p.s[p.i:], `'''`) {
+		return "", fail(p, "multi-line strings are not supported")
+	}
+	p.i += 1
+	b := strings.builder_make(p.allocator)
+	for {
+		if p.i >= len(p.s) || p.s[p.i] == '\n' {
+			return "", fail(p, "unterminated string")
+		}
+		c := p.s[p.i]
+		p.i += 1
+		if c == quote {
+			return strings.to_string(b), true
+		}
+		if c != '\\' || quote == '\'' {
+			strings.write_byte(&b, c)
+			continue
+		}
+		e := peek(p)
+		p.i += 1
+		switch e {
+		case 'n':
+			strings.write_byte(&b, '\n')
+		case 't':
+			strings.write_byte(&b, '\t')
+		case 'r':
+			strings.write_byte(&b, '\r')
+		case '"', '\\':
+			strings.write_byte(&b, e)
+		case 'u', 'U':
+			n := 4 if e == 'u' else 8
+			if p.i + n > len(p.s) {
+				return "", fail(p, "short unicode escape")
+			}
+			r, rok := strconv.parse_u64_of_base(p.s[p.i:p.i + n], 16)
+			if !rok {
+				return "", fail(p, "bad unicode escape")
+			}
+			p.i += n
+			strings.write_rune(&b, rune(r))
+		case:
+			return "", fail(p, "unknown escape in string")
+		}
+	}
+}
+
+// Skips spaces, tabs and comments, and line breaks too when newlines is set.
+@(private = "file")
+skip_blank :: proc(p: ^Toml_Parser, newlines: bool) {
+	for p.i < len(p.s) {
+		switch p.s[p.i] {
+		case ' ', '\t', '\r':
+			p.i += 1
+		case '#':
+			for p.i < len(p.s) && p.s[p.i] != '\n' {
+				p.i += 1
+			}
+		case '\n':
+			if !newlines {
+				return
+			}
+			p.i += 1
+			p.line += 1
+		case:
+			return
+		}
+	}
+}
+
+@(private = "file")
+end_of_line :: proc(p: ^Toml_Parser) -> bool {
+	skip_blank(p, false)
+	if p.i < len(p.s) && p.s[p.i] != '\n' {
+		return fail(p, "unexpected text after the value")
+	}
+	return true
+}
+
+@(private = "file")
+peek :: proc(p: ^Toml_Parser) -> u8 {
+	return p.s[p.i] if p.i < len(p.s) else 0
+}
+
+@(private = "file")
+fail :: proc(p: ^Toml_Parser, message: string) -> bool {
+	if p.err.message == "" {
+		p.err = {p.line, message}
+	}
+	return false
+}
+
+@(private = "file")
+is_bare_key_byte :: proc(c: u8) -> bool {
+	return is_word_byte(c) || c == '-'
+}
@@ -0,0 +1,262 @@
+package editor
+
+import "core:mem"
+import "core:strings"
+
+// One primitive change: `removed` was replaced by `inserted` at pos.
+Undo_Edit :: struct {
+	pos:      int,
+	removed:  string,
+	inserted: string,
+}
+
+// Edits undone and redone as a single step.
+Undo_Group :: struct {
+	edits:         [dynamic]Undo_Edit,
+	cursor_before: int,
+	cursor_after:  int,
+	merge_key:     string, // groups with the same non-empty key (e.g. typing) coalesce
+}
+
+// Undo/redo stacks for one buffer.  Attach it with `gb.undo = &history`; the
+// gap buffer then records every insert and delete.  Edits made between
+// begin_undo_group and end_undo_group form one step; edits made outside a
+// group get a step each.
+Undo_History :: struct {
+	undo_stack:     [dynamic]Undo_Group,
+	redo_stack:     [dynamic]Undo_Group,
+	depth:          int, // begin_undo_group nesting
+	group_open:     bool, // the top of undo_stack belongs to the current group
+	pending_cursor: int,
+	pending_key:    string,
+	applying:       bool, // replaying an undo/redo, so edits are not recorded
+	save_point:     int, // len(undo_stack) when last saved, -1 once unreachable
+	version:        int, // bumped by every recorded edit, undo and redo
+	allocator:      mem.Allocator,
+}
+
+init_undo_history :: proc(allocator: mem.Allocator = context.allocator) -> Undo_History {
+	return Undo_History {
+		undo_stack = make([dynamic]Undo_Group, allocator),
+		redo_stack = make([dynamic]Undo_Group, allocator),
+		allocator = allocator,
+	}
+}
+
+destroy_undo_history :: proc(h: ^Undo_History) {
+	clear_undo_history(h)
+	delete(h.undo_stack)
+	delete(h.redo_stack)
+}
+
+// Forgets every step, e.g. after loading a different file into the buffer.
+clear_undo_history :: proc(h: ^Undo_History) {
+	for &g in h.undo_stack {
+		destroy_undo_group(&g, h.allocator)
+	}
+	clear(&h.undo_stack)
+	clear_redo(h)
+	h.group_open = false
+	h.save_point = 0
+}
+
+// Drops every step to free memory.  Unlike clear_undo_history the buffer
+// stays modified if it was.
+forget_undo_history :: proc(h: ^Undo_History) {
+	modified := is_modified(h)
+	clear_undo_history(h)
+	if modified {
+		mark_modified(h)
+	}
+}
+
+// Bytes held by the steps, roughly.
+undo_history_size :: proc(h: ^Undo_History) -> int {
+	size := 0
+	stacks := [2][]Undo_Group{h.undo_stack[:], h.redo_stack[:]}
+	for stack in stacks {
+		for g in stack {
+			size += size_of(Undo_Group) + cap(g.edits) * size_of(Undo_Edit)
+			for e in g.edits {
+				size += len(e.removed) + len(e.inserted)
+			}
+		}
+	}
+	return size
+}
+
+// Marks the current state as the one on disk.
+mark_saved :: proc(h: ^Undo_History) {
+	h.save_point = len(h.undo_stack)
+}
+
+// Makes the buffer count as modified until the next save, for changes kept
+// outside the text such as its line endings.
+mark_modified :: proc(h: ^Undo_History) {
+	h.save_point = -1
+}
+
+// Reports whether undo/redo has moved the buffer away from the saved state.
+is_modified :: proc(h: ^Undo_History) -> bool {
+	return h.save_point != len(h.undo_stack)
+}
+
+// Starts a step.  With a merge_key, the step joins the previous one when that
+// had the same key and ended where this one starts, so a run of typing undoes
+// at once.  Groups nest; only the outermost one counts.
+begin_undo_group :: proc(h: ^Undo_History, cursor: int, merge_key := "") {
+	h.depth += 1
+	if h.depth == 1 {
+		h.pending_cursor = cursor
+		h.pending_key = merge_key
+		h.group_open = false
+	}
+}
+
+end_undo_group :: proc(h: ^Undo_History, cursor: int) {
+	h.depth = max(h.depth - 1, 0)
+	if h.depth == 0 && h.group_open {
+		h.undo_stack[len(h.undo_stack) - 1].cursor_after = cursor
+		h.group_open = false
+	}
+}
+
+// Ends any open group at once, e.g. before the buffer is swapped out in the
+// middle of a command.
+close_undo_groups :: proc(h: ^Undo_History, cursor: int) {
+	if h.depth > 0 {
+		h.depth = 1
+		end_undo_group(h, cursor)
+	}
+}
+
+// Called by the gap buffer for every change.
+record_edit :: proc(h: ^Undo_History, pos: int, removed, inserted: string) {
+	if h == nil || h.applying || (removed == "" && inserted == "") {
+		return
+	}
+	clear_redo(h)
+	h.version += 1
+
+	if h.depth == 0 {
+		g := Undo_Group {
+			edits         = make([dynamic]Undo_Edit, h.allocator),
+			cursor_before = pos + len(removed),
+			cursor_after  = pos + len(inserted),
+		}
+		append(&g.edits, clone_edit(pos, removed, inserted, h.allocator))
+		append(&h.undo_stack, g)
+		return
+	}
+
+	if !h.group_open {
+		h.group_open = true
+		if !can_merge(h) {
+			append(
+				&h.undo_stack,
+				Undo_Group {
+					edits = make([dynamic]Undo_Edit, h.allocator),
+					cursor_before = h.pending_cursor,
+					merge_key = h.pending_key,
+				},
+			)
+		}
+	}
+
+	g := &h.undo_stack[len(h.undo_stack) - 1]
+	if n := len(g.edits); n > 0 {
+		// Extend a run of adjacent inserts instead of storing one per key.
+		last := &g.edits[n - 1]
+		if removed == "" && last.removed == "" && last.pos + len(last.inserted) == pos {
+			joined := strings.concatenate({last.inserted, inserted}, h.allocator)
+			delete(last.inserted, h.allocator)
+			last.inserted = joined
+			return
+		}
+	}
+	append(&g.edits, clone_edit(pos, removed, inserted, h.allocator))
+}
+
+can_undo :: proc(h: ^Undo_History) -> bool {
+	return h != nil && len(h.undo_stack) > 0
+}
+
+can_redo :: proc(h: ^Undo_History) -> bool {
+	return h != nil && len(h.redo_stack) > 0
+}
+
+// Reverts the latest step and returns where the cursor was before it.
+undo :: proc(gb: ^Gap_Buffer) -> (cursor: int, ok: bool) {
+	h := gb.undo
+	if !can_undo(h) {
+		return 0, false
+	}
+	g := pop(&h.undo_stack)
+	h.applying = true
+	#reverse for e in g.edits {
+		replace_bytes(gb, e.pos, len(e.inserted), transmute([]u8)e.removed)
+	}
+	h.applying = false
+	h.version += 1
+	g.merge_key = "" // never extend a step that was undone and redone
+	append(&h.redo_stack, g)
+	return g.cursor_before, true
+}
+
+// Re-applies the latest undone step and returns where the cursor was after it.
+redo :: proc(gb: ^Gap_Buffer) -> (cursor: int, ok: bool) {
+	h := gb.undo
+	if !can_redo(h) {
+		return 0, false
+	}
+	g := pop(&h.redo_stack)
+	h.applying = true
+	for e in g.edits {
+		replace_bytes(gb, e.pos, len(e.removed), transmute([]u8)e.inserted)
+	}
+	h.applying = false
+	h.version += 1
+	append(&h.undo_stack, g)
+	return g.cursor_after, true
+}
+
+@(private = "file")
+can_merge :: proc(h: ^Undo_History) -> bool {
+	if h.pending_key == "" || len(h.undo_stack) == 0 {
+		return false
+	}
+	if len(h.undo_stack) == h.save_point {
+		return false // the saved step must stay as it was
+	}
+	last := h.undo_stack[len(h.undo_stack) - 1]
+	return last.merge_key == h.pending_key && last.cursor_after == h.pending_cursor
+}
+
+@(private = "file")
+clear_redo :: proc(h: ^Undo_History) {
+	if h.save_point > len(h.undo_stack) {
+		h.save_point = -1
+	}
+	for &g in h.redo_stack {
+		destroy_undo_group(&g, h.allocator)
+	}
+	clear(&h.redo_stack)
+}
+
+@(private = "file")
+clone_edit :: proc(pos: int, removed, inserted: string, allocator: mem.Allocator) -> Undo_Edit {
+	return Undo_Edit {
+		pos = pos,
+		removed = strings.clone(removed, allocator),
+		inserted = strings.clone(inserted, allocator),
+	}
+}
+
+@(private = "file")
+destroy_undo_group :: proc(g: ^Undo_Group, allocator: mem.Allocator) {
+	for e in g.edits {
+		delete(e.removed, allocator)
+		delete(e.inserted, allocator)
+	}
+	delete(g.edits)
+}
@@ -0,0 +1,242 @@
+package editor
+
+import "core:unicode/utf8"
+
+// Display widths and grapheme clusters.  A cluster is what the user sees as
+// one character: a base with its combining marks, an emoji with its modifiers
+// and joined parts, or a pair of regional indicators (a flag).  The cursor
+// moves over whole clusters and a cluster takes one or two cells.
+
+@(private = "file")
+Rune_Range :: [2]rune
+
+// Combining marks, joiners, variation selectors and other code points that
+// attach to the preceding character and take no cell of their own.
+@(private = "file")
+ZERO_WIDTH := [?]Rune_Range {
+	{0x0300, 0x036f},
+	{0x0483, 0x0489},
+	{0x0591, 0x05bd},
+	{0x05bf, 0x05bf},
+	{0x05c1, 0x05c2},
+	{0x05c4, 0x05c5},
+	{0x05c7, 0x05c7},
+	{0x0610, 0x061a},
+	{0x064b, 0x065f},
+	{0x0670, 0x0670},
+	{0x06d6, 0x06dc},
+	{0x06df, 0x06e4},
+	{0x06e7, 0x06e8},
+	{0x06ea, 0x06ed},
+	{0x0900, 0x0903},
+	{0x093a, 0x094f},
+	{0x0951, 0x0957},
+	{0x0962, 0x0963},
+	{0x0981, 0x0983},
+	{0x09bc, 0x09bc},
+	{0x09be, 0x09cd},
+	{0x09d7, 0x09d7},
+	{0x0e31, 0x0e31},
+	{0x0e34, 0x0e3a},
+	{0x0e47, 0x0e4e},
+	{0x1160, 0x11ff}, // Hangul vowels and final consonants
+	{0x1ab0, 0x1aff},
+	{0x1dc0, 0x1dff},
+	{0x200b, 0x200f},
+	{0x2060, 0x2064},
+	{0x20d0, 0x20ff},
+	{0x302a, 0x302f},
+	{0x3099, 0x309a},
+	{0xfe00, 0xfe0f},
+	{0xfe20, 0xfe2f},
+	{0xfeff, 0xfeff},
+	{0x1f3fb, 0x1f3ff}, // emoji skin tones
+	{0xe0020, 0xe007f},
+	{0xe0100, 0xe01ef},
+}
+
+// East Asian wide and fullwidth characters and emoji shown as pictures.
+@(private = "file")
+DOUBLE_WIDTH := [?]Rune_Range {
+	{0x1100, 0x115f},
+	{0x231a, 0x231b},
+	{0x2329, 0x232a},
+	{0x23e9, 0x23ec},
+	{0x23f0, 0x23f0},
+	{0x23f3, 0x23f3},
+	{0x25fd, 0x25fe},
+	{0x2614, 0x2615},
+	{0x2648, 0x2653},
+	{0x267f, 0x267f},
+	{0x2693, 0x2693},
+	{0x26a1, 0x26a1},
+	{0x26aa, 0x26ab},
+	{0x26bd, 0x26be},
+	{0x26c4, 0x26c5},
+	{0x26ce, 0x26ce},
+	{0x26d4, 0x26d4},
+	{0x26ea, 0x26ea},
+	{0x26f2, 0x26f3},
+	{0x26f5, 0x26f5},
+	{0x26fa, 0x26fa},
+	{0x26fd, 0x26fd},
+	{0x2705, 0x2705},
+	{0x270a, 0x270b},
+	{0x2728, 0x2728},
+	{0x274c, 0x274c},
+	{0x274e, 0x274e},
+	{0x2753, 0x2755},
+	{0x2757, 0x2757},
+	{0x2795, 0x2797},
+	{0x27b0, 0x27b0},
+	{0x27bf, 0x27bf},
+	{0x2b1b, 0x2b1c},
+	{0x2b50, 0x2b50},
+	{0x2b55, 0x2b55},
+	{0x2e80, 0x303e},
+	{0x3041, 0x33ff},
+	{0x3400, 0x4dbf},
+	{0x4e00, 0x9fff},
+	{0xa000, 0xa4cf},
+	{0xa960, 0xa97f},
+	{0xac00, 0xd7a3},
+	{0xf900, 0xfaff},
+	{0xfe10, 0xfe19},
+	{0xfe30, 0xfe6f},
+	{0xff00, 0xff60},
+	{0xffe0, 0xffe6},
+	{0x1f004, 0x1f004},
+	{0x1f0cf, 0x1f0cf},
+	{0x1f18e, 0x1f18e},
+	{0x1f191, 0x1f19a},
+	{0x1f200, 0x1f251},
+	{0x1f300, 0x1f64f},
+	{0x1f680, 0x1f6ff},
+	{0x1f7e0, 0x1f7eb},
+	{0x1f90c, 0x1f9ff},
+	{0x1fa70, 0x1faff},
+	{0x20000, 0x2fffd},
+	{0x30000, 0x3fffd},
+}
+
+@(private = "file")
+ZWJ :: 0x200d
+
+@(private = "file")
+VS16 :: 0xfe0f // asks for the emoji (wide) presentation
+
+// Number of cells r takes on its own: 0, 1 or 2.  Control characters take 1
+// so that they stay visible and reachable.
+rune_width :: proc(r: rune) -> int {
+	if r < 0x300 {
+		return 1
+	}
+	if in_ranges(ZERO_WIDTH[:], r) {
+		return 0
+	}
+	if in_ranges(DOUBLE_WIDTH[:], r) {
+		return 2
+	}
+	return 1
+}
+
+// Byte index just past the cluster that starts at i.
+next_grapheme :: proc(s: string, i: int) -> int {
+	if i >= len(s) {
+		return len(s)
+	}
+	prev, size := utf8.decode_rune_in_string(s[i:])
+	j := i + size
+	if prev == '\r' && j < len(s) && s[j] == '\n' {
+		return j + 1
+	}
+	if prev == '\n' || prev == '\r' || prev == '\t' {
+		return j
+	}
+	regional := 1 if is_regional_indicator(prev) else 0
+	for j < len(s) {
+		r, n := utf8.decode_rune_in_string(s[j:])
+		switch {
+		case r == '\n' || r == '\r' || r == '\t':
+			return j
+		case rune_width(r) == 0:
+			// combining mark, joiner, selector or skin tone
+		case prev == ZWJ && is_pictographic(r):
+			// the next part of a joined emoji such as a family
+		case regional == 1 && is_regional_indicator(r):
+			regional = 2 // completes a flag
+		case:
+			return j
+		}
+		prev = r
+		j += n
+	}
+	return j
+}
+
+// Byte index of the start of the cluster that ends at i.
+prev_grapheme :: proc(s: string, i: int) -> int {
+	start := 0
+	for start < len(s) {
+		next := next_grapheme(s, start)
+		if next >= i {
+			return start
+		}
+		start = next
+	}
+	return start
+}
+
+// Cells taken by a cluster.  Anything joined to an emoji by a zero width
+// joiner is drawn as part of that emoji and takes no extra room.
+grapheme_width :: proc(cluster: string) -> int {
+	width := 0
+	joined := false
+	for r in cluster {
+		switch {
+		case r == VS16:
+			width = max(width, 2)
+		case r == ZWJ:
+			joined = true
+		case !joined:
+			width += rune_width(r)
+		}
+	}
+	return width
+}
+
+@(private = "file")
+in_ranges :: proc(ranges: []Rune_Range, r: rune) -> bool {
+	lo, hi := 0, len(ranges) - 1
+	for lo <= hi {
+		mid := (lo + hi) / 2
+		switch {
+		case r < ranges[mid][0]:
+			hi = mid - 1
+		case r > ranges[mid][1]:
+			lo = mid + 1
+		case:
+			return true
+		}
+	}
+	return false
+}
+
+@(private = "file")
+is_regional_indicator :: proc(r: rune) -> bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+// Close enough to Extended_Pictographic for joining emoji.
+@(private = "file")
+is_pictographic :: proc(r: rune) -> bool {
+	switch r {
+	case 0x00a9, 0x00ae, 0x203c, 0x2049, 0x2122, 0x2139, 0x3030, 0x303d, 0x3297, 0x3299:
+		return true
+	}
+	return (r >= 0x2190 && r <= 0x21ff) ||
+		(r >= 0x2300 && r <= 0x23ff) ||
+		(r >= 0x2600 && r <= 0x27bf) ||
+		(r >= 0x2b00 && r <= 0x2bff) ||
+		(r >= 0x1f000 && r <= 0x1faff)
+}
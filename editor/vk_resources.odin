@@ -256,8 +256,9 @@ upload_image_data :: proc(
 	img: ^GPU_Image,
 	pixels: []u8,
 	region_x, region_y, region_w, region_h: u32,
+	bytes_per_pixel: u32 = 1,
 ) {
-	staging_size := vk.DeviceSize(region_w * region_h)
+	staging_size := vk.DeviceSize(region_w * region_h * bytes_per_pixel)
 	staging, ok := create_gpu_buffer(
 		ctx,
 		staging_size,
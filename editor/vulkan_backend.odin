@@ -1,6 +1,7 @@
 package editor
 
 import "core:fmt"
+import "core:log"
 import "core:mem"
 import "vendor:glfw"
 import vk "vendor:vulkan"
@@ -61,7 +62,7 @@ init_vulkan :: proc(
 	// 1. Global Load
 	get_proc := glfw.GetInstanceProcAddress(nil, "vkGetInstanceProcAddr")
 	if get_proc == nil {
-		fmt.eprintln("Failed to find vkGetInstanceProcAddr")
+		log.error("Failed to find vkGetInstanceProcAddr")
 		return ctx, false
 	}
 	vk.load_proc_addresses_global(rawptr(get_proc))
@@ -88,7 +89,7 @@ init_vulkan :: proc(
 	}
 
 	if vk.CreateInstance(&instance_info, nil, &ctx.instance) != .SUCCESS {
-		fmt.eprintln("Failed to create Vulkan instance")
+		log.error("Failed to create Vulkan instance")
 		return ctx, false
 	}
 
@@ -96,17 +97,17 @@ init_vulkan :: proc(
 	vk.load_proc_addresses_instance(ctx.instance)
 
 	if glfw.CreateWindowSurface(ctx.instance, window, nil, &ctx.surface) != .SUCCESS {
-		fmt.eprintln("Failed to create window surface")
+		log.error("Failed to create window surface")
 		return ctx, false
 	}
 
 	if !pick_physical_device(&ctx) {
-		fmt.eprintln("Failed to find suitable GPU")
+		log.error("Failed to find suitable GPU")
 		return ctx, false
 	}
 
 	if !create_logical_device(&ctx) {
-		fmt.eprintln("Failed to create logical device")
+		log.error("Failed to create logical device")
 		return ctx, false
 	}
 
@@ -114,33 +115,33 @@ init_vulkan :: proc(
 
 	w, h := glfw.GetFramebufferSize(window)
 	if w == 0 || h == 0 {
-		fmt.eprintln("Window size is 0, cannot initialize swapchain")
+		log.error("Window size is 0, cannot initialize swapchain")
 		return ctx, false
 	}
 	ctx.viewport_size = {f32(w), f32(h)}
 
 	if !create_swapchain(&ctx, u32(w), u32(h)) {
-		fmt.eprintln("Failed to create swapchain")
+		log.error("Failed to create swapchain")
 		return ctx, false
 	}
 
 	if !create_render_pass(&ctx) {
-		fmt.eprintln("Failed to create render pass")
+		log.error("Failed to create render pass")
 		return ctx, false
 	}
 
 	if !create_framebuffers(&ctx) {
-		fmt.eprintln("Failed to create framebuffers")
+		log.error("Failed to create framebuffers")
 		return ctx, false
 	}
 
 	if !create_command_resources(&ctx) {
-		fmt.eprintln("Failed to create command resources")
+		log.error("Failed to create command resources")
 		return ctx, false
 	}
 
 	if !create_sync_objects(&ctx) {
-		fmt.eprintln("Failed to create sync objects")
+		log.error("Failed to create sync objects")
 		return ctx, false
 	}
 
@@ -200,7 +201,7 @@ pick_physical_device :: proc(ctx: ^Render_Context) -> bool {
 		return true
 	}
 
-	fmt.eprintln("No suitable physical device found ")
+	log.error("No suitable physical device found ")
 	return false
 }
 
@@ -0,0 +1,122 @@
+package editor
+
+import "core:mem"
+import "core:strings"
+
+Which_Key_Entry :: struct {
+	keys:    string,
+	command: string, // or "+3 more" for a key that leads on
+	prefix:  bool, // leads to longer bindings, drawn dimmed
+}
+
+// A box above the status line listing, in columns, the keys that may follow
+// a pending prefix and what they run.
+Which_Key_Layer_Data :: struct {
+	font:        ^Font_Handle,
+	title:       string, // the pending keys
+	entries:     [dynamic]Which_Key_Entry,
+	visible:     bool,
+	line_height: f32,
+	char_width:  f32,
+	key_color:   [4]f32,
+	fg_color:    [4]f32,
+	dim_color:   [4]f32,
+	bg_color:    [4]f32,
+	allocator:   mem.Allocator,
+}
+
+make_which_key_layer :: proc(
+	font: ^Font_Handle,
+	line_height: f32,
+	char_width: f32,
+	allocator: mem.Allocator = context.allocator,
+) -> Layer {
+	data := new(Which_Key_Layer_Data, allocator)
+	data.font = font
+	data.entries = make([dynamic]Which_Key_Entry, allocator)
+	data.line_height = line_height
+	data.char_width = char_width
+	data.key_color = {0.55, 0.75, 0.95, 1.0}
+	data.fg_color = {0.85, 0.85, 0.82, 1.0}
+	data.dim_color = {0.50, 0.50, 0.55, 1.0}
+	data.bg_color = {0.13, 0.13, 0.16, 1.0}
+	data.allocator = allocator
+
+	return Layer {
+		kind = .Overlay,
+		z_index = 190,
+		enabled = true,
+		name = "which_key",
+		user_data = data,
+		draw = proc(
+			layer: ^Layer,
+			br: ^Batch_Renderer,
+			atlas: ^Glyph_Atlas,
+			lctx: ^Layer_Context,
+		) {
+			d := cast(^Which_Key_Layer_Data)layer.user_data
+			if !d.visible || len(d.entries) == 0 {
+				return
+			}
+			key_w, command_w: f32
+			for e in d.entries {
+				key_w = max(key_w, measure_text(atlas, d.font, e.keys))
+				command_w = max(command_w, measure_text(atlas, d.font, e.command))
+			}
+			column_w := key_w + command_w + 4 * d.char_width
+			columns := max(int((lctx.viewport[0] - d.char_width) / column_w), 1)
+			rows := (len(d.entries) + columns - 1) / columns
+			// Room for the title, and the status line below.
+			max_rows := max(int(lctx.viewport[1] / d.line_height) - 3, 1)
+			rows = min(rows, max_rows)
+
+			h := f32(rows + 1) * d.line_height
+			y := lctx.viewport[1] - d.line_height - h
+			push_rect(br, 0, y, lctx.viewport[0], h, d.bg_color)
+			push_text(br, atlas, d.font, d.char_width, y, d.title, d.dim_color)
+			y += d.line_height
+			for e, i in d.entries {
+				column, row := i / rows, i % rows
+				if column >= columns {
+					break
+				}
+				x := d.char_width + f32(column) * column_w
+				ry := y + f32(row) * d.line_height
+				push_text(br, atlas, d.font, x, ry, e.keys, d.key_color)
+				color := d.dim_color if e.prefix else d.fg_color
+				push_text(br, atlas, d.font, x + key_w + 2 * d.char_width, ry, e.command, color)
+			}
+		},
+		on_destroy = proc(layer: ^Layer) {
+			d := cast(^Which_Key_Layer_Data)layer.user_data
+			which_key_clear(d)
+			delete(d.entries)
+		},
+	}
+}
+
+which_key_clear :: proc(d: ^Which_Key_Layer_Data) {
+	for e in d.entries {
+		delete(e.keys, d.allocator)
+		delete(e.command, d.allocator)
+	}
+	clear(&d.entries)
+	delete(d.title, d.allocator)
+	d.title = ""
+}
+
+// Replaces the entries with copies of entries.
+set_which_key_entries :: proc(d: ^Which_Key_Layer_Data, title: string, entries: []Which_Key_Entry) {
+	which_key_clear(d)
+	d.title = strings.clone(title, d.allocator)
+	for e in entries {
+		append(
+			&d.entries,
+			Which_Key_Entry {
+				keys = strings.clone(e.keys, d.allocator),
+				command = strings.clone(e.command, d.allocator),
+				prefix = e.prefix,
+			},
+		)
+	}
+}
@@ -0,0 +1,130 @@
+package editor
+
+import "core:strings"
+
+Char_Class :: enum u8 {
+	Space,
+	Word,
+	Punct,
+}
+
+// The class of a byte for word motions.  Letters, digits, _ and every byte
+// of a multi-byte character are words, and so are the bytes in extra, the
+// language's word characters such as "-" in CSS.
+char_class :: proc(b: u8, extra: string) -> Char_Class {
+	switch b {
+	case ' ', '\t', '\r', '\n':
+		return .Space
+	case 'a' ..= 'z', 'A' ..= 'Z', '0' ..= '9', '_', 0x80 ..= 0xff:
+		return .Word
+	}
+	if strings.index_byte(extra, b) >= 0 {
+		return .Word
+	}
+	return .Punct
+}
+
+// The column a word motion right from col stops at: past the blanks, then
+// the end of the run of one class after them.  With sub, a run of word
+// bytes also stops at each camelCase hump and snake_case part.  Returns
+// len(line) at the end of the line.
+next_word_col :: proc(line: string, col: int, extra: string, sub := false) -> int {
+	i := min(col, len(line))
+	for i < len(line) && char_class(line[i], extra) == .Space {
+		i += 1
+	}
+	if i == len(line) {
+		return i
+	}
+	class := char_class(line[i], extra)
+	i += 1
+	for i < len(line) && char_class(line[i], extra) == class {
+		if sub && class == .Word && is_subword_start(line, i) {
+			break
+		}
+		i += 1
+	}
+	return i
+}
+
+// The column a word motion left from col stops at: back over the blanks,
+// then to the start of the run before them.  With sub, as next_word_col.
+// Returns 0 at the start of the line.
+prev_word_col :: proc(line: string, col: int, extra: string, sub := false) -> int {
+	i := min(col, len(line))
+	for i > 0 && char_class(line[i - 1], extra) == .Space {
+		i -= 1
+	}
+	if i == 0 {
+		return 0
+	}
+	class := char_class(line[i - 1], extra)
+	i -= 1
+	for i > 0 && char_class(line[i - 1], extra) == class {
+		if sub && class == .Word && is_subword_start(line, i) {
+			break
+		}
+		i -= 1
+	}
+	return i
+}
+
+// The column of the first character that is not a blank, len(line) when
+// there is none.
+first_non_blank :: proc(line: string) -> int {
+	for i in 0 ..< len(line) {
+		if line[i] != ' ' && line[i] != '\t' {
+			return i
+		}
+	}
+	return len(line)
+}
+
+// The column after the last character that is not a blank, 0 when there is
+// none.
+last_non_blank :: proc(line: string) -> int {
+	i := len(line)
+	for i > 0 && (line[i - 1] == ' ' || line[i - 1] == '\t' || line[i - 1] == '\r') {
+		i -= 1
+	}
+	return i
+}
+
+// Whether a sub-word starts at i inside a word: after underscores
+// ("foo_|bar"), at a hump ("foo|Bar"), at the last capital of an acronym
+// ("HTTP|Server") or where digits start or end ("utf|8").
+@(private = "file")
+is_subword_start :: proc(line: string, i: int) -> bool {
+	prev, cur := line[i - 1], line[i]
+	if cur == '_' {
+		return prev != '_'
+	}
+	if prev == '_' {
+		return true
+	}
+	if is_digit(prev) != is_digit(cur) {
+		return true
+	}
+	if is_upper(cur) {
+		if is_lower(prev) {
+			return true
+		}
+		return is_upper(prev) && i + 1 < len(line) && is_lower(line[i + 1])
+	}
+	return false
+}
+
+@(private = "file")
+is_upper :: proc(b: u8) -> bool {
+	return b >= 'A' && b <= 'Z'
+}
+
+@(private = "file")
+is_lower :: proc(b: u8) -> bool {
+	return b >= 'a' && b <= 'z'
+}
+
+@(private = "file")
+is_digit :: proc(b: u8) -> bool {
+	return b >= '0' && b <= '9'
+}
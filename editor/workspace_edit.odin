@@ -0,0 +1,318 @@
+package editor
+
+import "core:encoding/json"
+import "core:fmt"
+import "core:mem"
+import "core:slice"
+import "core:strconv"
+import "core:strings"
+
+// A WorkspaceEdit of the language server protocol, as a rename or code action
+// sends: the changes to make, in order.  Both of its forms are read,
+// documentChanges with file operations and the older changes map of edits
+// by URI.  Paths are the file URIs' paths.
+Workspace_Edit :: struct {
+	operations:  []Workspace_Operation,
+	annotations: map[string]Change_Annotation, // by id
+}
+
+Workspace_Operation :: union {
+	Document_Edit,
+	Create_File,
+	Rename_File,
+	Delete_File,
+}
+
+Document_Edit :: struct {
+	path:    string,
+	version: Maybe(int), // of the document the edits were made for; nil for any
+	edits:   []Lsp_Text_Edit,
+}
+
+// A range of a document and the text to replace it with.
+Lsp_Text_Edit :: struct {
+	start:      Lsp_Position,
+	end:        Lsp_Position,
+	new_text:   string,
+	annotation: string, // a Change_Annotation id, "" for none
+}
+
+Create_File :: struct {
+	path:             string,
+	overwrite:        bool,
+	ignore_if_exists: bool,
+	annotation:       string,
+}
+
+Rename_File :: struct {
+	old_path:         string,
+	new_path:         string,
+	overwrite:        bool,
+	ignore_if_exists: bool,
+	annotation:       string,
+}
+
+Delete_File :: struct {
+	path:                 string,
+	recursive:            bool,
+	ignore_if_not_exists: bool,
+	annotation:           string,
+}
+
+// Describes some of an edit's changes; those needing confirmation are only
+// made once the user agreed.
+Change_Annotation :: struct {
+	label:              string,
+	needs_confirmation: bool,
+	description:        string,
+}
+
+// Reads a WorkspaceEdit.  err says what is wrong with it, "" when nothing is.
+// Everything is allocated with allocator, so parse into an arena or the temp
+// allocator.
+parse_workspace_edit :: proc(
+	text: string,
+	allocator: mem.Allocator = context.temp_allocator,
+) -> (
+	edit: Workspace_Edit,
+	err: string,
+) {
+	value, jerr := json.parse_string(text, allocator = allocator)
+	if jerr != nil {
+		return {}, fmt.aprintf("not JSON: %v", jerr, allocator = allocator)
+	}
+	if _, is_object := value.(json.Object); !is_object {
+		return {}, "not a WorkspaceEdit object"
+	}
+	edit.annotations = make(map[string]Change_Annotation, allocator = allocator)
+	if annotations, ok := json_field(value, "changeAnnotations").(json.Object); ok {
+		for id, a in annotations {
+			edit.annotations[id] = Change_Annotation {
+				label              = json_string(a, "label"),
+				needs_confirmation = edit_flag(a, "needsConfirmation"),
+				description        = json_string(a, "description"),
+			}
+		}
+	}
+
+	ops := make([dynamic]Workspace_Operation, allocator)
+	if changes, ok := json_field(value, "documentChanges").(json.Array); ok {
+		for change in changes {
+			options := json_field(change, "options")
+			annotation := json_string(change, "annotationId")
+			switch kind := json_string(change, "kind"); kind {
+			case "create":
+				op := Create_File {
+					overwrite        = edit_flag(options, "overwrite"),
+					ignore_if_exists = edit_flag(options, "ignoreIfExists"),
+					annotation       = annotation,
+				}
+				op.path = uri_path(json_string(change, "uri"), allocator)
+				if op.path == "" {
+					return {}, not_file_uri(json_string(change, "uri"), allocator)
+				}
+				append(&ops, op)
+			case "rename":
+				op := Rename_File {
+					overwrite        = edit_flag(options, "overwrite"),
+					ignore_if_exists = edit_flag(options, "ignoreIfExists"),
+					annotation       = annotation,
+				}
+				op.old_path = uri_path(json_string(change, "oldUri"), allocator)
+				op.new_path = uri_path(json_string(change, "newUri"), allocator)
+				if op.old_path == "" || op.new_path == "" {
+					uri := json_string(change, "oldUri" if op.old_path == "" else "newUri")
+					return {}, not_file_uri(uri, allocator)
+				}
+				append(&ops, op)
+			case "delete":
+				op := Delete_File {
+					recursive            = edit_flag(options, "recursive"),
+					ignore_if_not_exists = edit_flag(options, "ignoreIfNotExists"),
+					annotation           = annotation,
+				}
+				op.path = uri_path(json_string(change, "uri"), allocator)
+				if op.path == "" {
+					return {}, not_file_uri(json_string(change, "uri"), allocator)
+				}
+				append(&ops, op)
+			case "":
+				document := json_field(change, "textDocument")
+				op := Document_Edit {
+					path = uri_path(json_string(document, "uri"), allocator),
+				}
+				if op.path == "" {
+					return {}, not_file_uri(json_string(document, "uri"), allocator)
+				}
+				if version, is_int := json_field(document, "version").(json.Integer); is_int {
+					op.version = int(version)
+				}
+				ranges: bool
+				op.edits, ranges = parse_lsp_text_edits(json_field(change, "edits"), allocator)
+				if !ranges {
+					return {}, "a text edit without a range"
+				}
+				append(&ops, op)
+			case:
+				return {}, fmt.aprintf("unknown change kind %q", kind, allocator = allocator)
+			}
+		}
+	} else if changes, is_object := json_field(value, "changes").(json.Object); is_object {
+		// A map has no order; by URI the edit reads the same each time.
+		uris := make([dynamic]string, context.temp_allocator)
+		for uri in changes {
+			append(&uris, uri)
+		}
+		slice.sort(uris[:])
+		for uri in uris {
+			op := Document_Edit {
+				path = uri_path(uri, allocator),
+			}
+			if op.path == "" {
+				return {}, not_file_uri(uri, allocator)
+			}
+			ranges: bool
+			op.edits, ranges = parse_lsp_text_edits(changes[uri], allocator)
+			if !ranges {
+				return {}, "a text edit without a range"
+			}
+			append(&ops, op)
+		}
+	}
+	edit.operations = ops[:]
+	return edit, ""
+}
+
+@(private)
+parse_lsp_text_edits :: proc(
+	value: json.Value,
+	allocator: mem.Allocator,
+) -> (
+	edits: []Lsp_Text_Edit,
+	ok: bool,
+) {
+	list := make([dynamic]Lsp_Text_Edit, allocator)
+	for e in json_array(value) {
+		r := json_field(e, "range")
+		if r == nil {
+			return nil, false
+		}
+		start, end := json_field(r, "start"), json_field(r, "end")
+		edit := Lsp_Text_Edit {
+			start      = {json_int(start, "line"), json_int(start, "character")},
+			end        = {json_int(end, "line"), json_int(end, "character")},
+			new_text   = json_string(e, "newText"),
+			annotation = json_string(e, "annotationId"),
+		}
+		append(&list, edit)
+	}
+	return list[:], true
+}
+
+@(private = "file")
+edit_flag :: proc(options: json.Value, key: string) -> bool {
+	return json_field(options, key).(json.Boolean) or_else false
+}
+
+// The path of a file: URI, with its escapes decoded, or "" for another URI.
+// On Windows the drive letter's leading slash is dropped.
+@(private)
+uri_path :: proc(uri: string, allocator: mem.Allocator) -> string {
+	if !strings.has_prefix(uri, "file://") || len(uri) == len("file://") {
+		return ""
+	}
+	rest := uri[len("file://"):]
+	b := strings.builder_make(allocator)
+	for i := 0; i < len(rest); i += 1 {
+		if rest[i] == '%' && i + 2 < len(rest) {
+			if n, ok := strconv.parse_int(rest[i + 1:i + 3], 16); ok {
+				strings.write_byte(&b, u8(n))
+				i += 2
+				continue
+			}
+		}
+		strings.write_byte(&b, rest[i])
+	}
+	path := strings.to_string(b)
+	when ODIN_OS == .Windows {
+		if len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+			path = path[1:]
+		}
+	}
+	return path
+}
+
+@(private = "file")
+not_file_uri :: proc(uri: string, allocator: mem.Allocator) -> string {
+	return fmt.aprintf("not a file URI: %q", uri, allocator = allocator)
+}
+
+// Reads a list of TextEdits, as textDocument/formatting and its kin answer
+// with; null is none.  ok is false when reply is not JSON.  Temp allocated.
+parse_lsp_text_edit_list :: proc(reply: string) -> (edits: []Lsp_Text_Edit, ok: bool) {
+	value, err := json.parse_string(reply, allocator = context.temp_allocator)
+	if err != nil {
+		return nil, false
+	}
+	return parse_lsp_text_edits(value, context.temp_allocator)
+}
+
+// Applies a document's text edits, all made against text, and returns the
+// result.  Characters count in the server's encoding.  err names the first
+// edit outside the text or overlapping another.  Temp allocated.
+apply_lsp_text_edits :: proc(
+	text: string,
+	edits: []Lsp_Text_Edit,
+	encoding := Position_Encoding.Utf16,
+) -> (
+	result, err: string,
+) {
+	spans: []Text_Edit
+	if spans, err = lsp_byte_edits(text, edits, encoding); err != "" {
+		return "", err
+	}
+	b := strings.builder_make(context.temp_allocator)
+	at := 0
+	for s in spans {
+		strings.write_string(&b, text[at:s.pos])
+		strings.write_string(&b, s.text)
+		at = s.pos + s.count
+	}
+	strings.write_string(&b, text[at:])
+	return strings.to_string(b), ""
+}
+
+// A document's text edits as byte edits of text in order, for applying back
+// to front and map_position.  err as for apply_lsp_text_edits.  Temp
+// allocated.
+lsp_byte_edits :: proc(
+	text: string,
+	edits: []Lsp_Text_Edit,
+	encoding := Position_Encoding.Utf16,
+) -> (
+	result: []Text_Edit,
+	err: string,
+) {
+	spans := make([]Text_Edit, len(edits), context.temp_allocator)
+	for e, i in edits {
+		start, start_ok := lsp_offset(text, e.start, encoding)
+		end, end_ok := lsp_offset(text, e.end, encoding)
+		if !start_ok || !end_ok || end < start {
+			line := e.start.line + 1
+			return nil, fmt.tprintf("edit %d is outside the text (line %d)", i + 1, line)
+		}
+		spans[i] = {start, end - start, e.new_text}
+	}
+	// Inserts at the same place keep their order.
+	slice.stable_sort_by(spans, proc(a, b: Text_Edit) -> bool {
+		return a.pos < b.pos
+	})
+	at := 0
+	for s in spans {
+		if s.pos < at {
+			return nil, "edits overlap"
+		}
+		at = s.pos + s.count
+	}
+	return spans, ""
+}
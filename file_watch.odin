@@ -0,0 +1,121 @@
+package main
+
+import "core:strings"
+import "core:time"
+import editor "editor"
+
+DISK_CHECK_INTERVAL :: time.Second
+
+// Polls the open files for changes made by other programs.  Three versions
+// are compared: the file as we last saw it (Disk_State), the buffer, and the
+// file now.  Unmodified buffers simply follow the file; a buffer with unsaved
+// edits is only replaced after asking.
+check_disk_changes :: proc(state: ^Editor_State) {
+	if state.prompt.active || time.tick_since(state.last_disk_check) < DISK_CHECK_INTERVAL {
+		return
+	}
+	state.last_disk_check = time.tick_now()
+
+	for &d, i in state.documents {
+		if i == state.active || d.path == "" || d.preview {
+			continue
+		}
+		if editor.is_modified(&d.undo) {
+			continue // handled once shown
+		}
+		full := workspace_path(state, d.path)
+		if changed, theirs := disk_changed(&d.disk, full); changed {
+			d.buffer.undo = &d.undo
+			replace_buffer_text(&d.buffer, &d.undo, d.cursor_pos, theirs)
+			d.buffer.undo = nil
+			d.cursor_pos = min(d.cursor_pos, editor.current_length(&d.buffer))
+			set_disk_state(&d.disk, full, theirs)
+			editor.mark_saved(&d.undo)
+		}
+	}
+
+	if state.path == "" || state.preview {
+		return
+	}
+	full := workspace_path(state, state.path)
+	changed, theirs := disk_changed(&state.disk, full)
+	if !changed {
+		return
+	}
+	ours := editor.get_text(&state.buffer, context.temp_allocator)
+	switch {
+	case ours == theirs:
+		// Saved elsewhere with the same edits; nothing is lost either way.
+		set_disk_state(&state.disk, full, theirs)
+		editor.mark_saved(&state.undo)
+	case !editor.is_modified(&state.undo) && strings.has_prefix(theirs, ours):
+		// Only added to, as logs are: follow along without a word.
+		append_to_document(state, theirs)
+	case !editor.is_modified(&state.undo):
+		reload_document(state, theirs)
+		set_message(state, "Reloaded %s, it changed on disk", document_title(state.path))
+	case:
+		confirm(
+			state,
+			"File changed on disk; reload and drop unsaved edits? (y/n)",
+			proc(state: ^Editor_State) {
+				full := workspace_path(state, state.path)
+				if changed, theirs := disk_changed(&state.disk, full); changed {
+					reload_document(state, theirs)
+				}
+			},
+			proc(state: ^Editor_State) {
+				// Keep our edits; the next save overwrites the file without asking.
+				full := workspace_path(state, state.path)
+				if changed, theirs := disk_changed(&state.disk, full); changed {
+					set_disk_state(&state.disk, full, theirs)
+				}
+			},
+		)
+	}
+}
+
+// Replaces the active buffer with text as one undoable step and keeps the
+// cursor on the same line and column.
+reload_document :: proc(state: ^Editor_State, text: string) {
+	line, col := state.cursor_data.line, state.cursor_data.col
+	replace_buffer_text(&state.buffer, &state.undo, state.cursor_pos, text)
+	set_disk_state(&state.disk, workspace_path(state, state.path), text)
+	editor.mark_saved(&state.undo)
+	state.selection_anchor = -1
+	state.cursor_pos = editor.line_col_to_logical_pos(&state.buffer, line, col)
+	sync_cursor(state)
+	set_preferred_col(state)
+}
+
+// Adds the end of text, the file now, to the active buffer that holds its
+// start as one undoable step.  A cursor at the end stays there, as tail -f.
+@(private = "file")
+append_to_document :: proc(state: ^Editor_State, text: string) {
+	end := editor.current_length(&state.buffer)
+	at_end := state.cursor_pos == end
+	editor.begin_undo_group(&state.undo, state.cursor_pos)
+	editor.replace_bytes(&state.buffer, end, 0, transmute([]u8)text[end:])
+	if at_end {
+		state.cursor_pos = len(text)
+	}
+	editor.end_undo_group(&state.undo, state.cursor_pos)
+	set_disk_state(&state.disk, workspace_path(state, state.path), text)
+	editor.mark_saved(&state.undo)
+	if at_end {
+		sync_cursor(state)
+	}
+	request_redraw(state)
+}
+
+@(private = "file")
+replace_buffer_text :: proc(
+	gb: ^editor.Gap_Buffer,
+	h: ^editor.Undo_History,
+	cursor: int,
+	text: string,
+) {
+	editor.begin_undo_group(h, cursor)
+	editor.replace_bytes(gb, 0, editor.current_length(gb), transmute([]u8)text)
+	editor.end_undo_group(h, min(cursor, len(text)))
+}
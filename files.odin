@@ -0,0 +1,401 @@
+package main
+
+import "core:bytes"
+import "core:log"
+import "core:mem"
+import "core:os"
+import "core:strings"
+import "core:time"
+import editor "editor"
+
+// Files larger than this open as a read-only preview of their first
+// PREVIEW_SIZE bytes.
+HUGE_FILE_SIZE :: 64 * mem.Megabyte
+PREVIEW_SIZE :: 4 * mem.Megabyte
+
+// The file as last read or written.  It is the common base when telling our
+// unsaved edits apart from changes other programs made on disk.
+Disk_State :: struct {
+	text:        string, // decoded to UTF-8 with LF line endings
+	mtime:       time.Time,
+	size:        i64,
+	view:        Document_View, // how the buffer represents the file
+	encoding:    editor.Encoding, // what the file is read and saved as
+	line_ending: editor.Line_Ending, // what "\n" is saved as
+	source:      string, // a notebook's JSON, for what its cells' text leaves out
+}
+
+// Shows the file at path, switching to its document when it is already open.
+// An untouched unnamed buffer is reused, otherwise a new document is opened.
+open_file :: proc(state: ^Editor_State, path: string) -> bool {
+	full := workspace_path(state, path)
+	if is_open_file(state, full) {
+		return true
+	}
+	if i := find_document(state, full); i >= 0 {
+		switch_document(state, i)
+		note_recent_file(full)
+		return true
+	}
+
+	fi, serr := os.stat(path, context.temp_allocator)
+	if serr != nil {
+		log.warn("Failed to open file:", path, serr)
+		return false
+	}
+	preview := fi.size > HUGE_FILE_SIZE
+	data: []u8
+	err: os.Error
+	if preview {
+		data, err = read_file_prefix(path, PREVIEW_SIZE)
+	} else {
+		data, err = os.read_entire_file_from_path(path, context.temp_allocator)
+	}
+	if err != nil {
+		log.warn("Failed to open file:", path, err)
+		return false
+	}
+
+	view := Document_View.Text
+	text, source, kernel: string
+	encoding: editor.Encoding
+	line_ending: editor.Line_Ending
+	if editor.is_binary(data) {
+		view = .Hex
+		text = editor.format_hex_dump(data, allocator = context.temp_allocator)
+	} else {
+		if preview {
+			// Stop at a line break rather than in the middle of a character.
+			if i := bytes.last_index_byte(data, '\n'); i > 0 {
+				data = data[:i + 1]
+			}
+		}
+		text, encoding = editor.decode_file(data, context.temp_allocator)
+		line_ending = editor.detect_line_ending(text)
+		text = editor.normalize_line_endings(text, context.temp_allocator)
+		lower := strings.to_lower(path, context.temp_allocator)
+		if !preview && strings.has_suffix(lower, ".ipynb") {
+			if nb, ok := editor.parse_notebook(text); ok {
+				view = .Notebook
+				source, kernel = text, nb.language
+				text = editor.notebook_text(nb)
+			}
+		}
+	}
+
+	if !is_blank_document(state) {
+		new_document(state)
+	}
+	editor.gap_buffer_clear(&state.buffer)
+	editor.insert_bytes(&state.buffer, transmute([]u8)text)
+	editor.clear_undo_history(&state.undo)
+
+	delete(state.path)
+	state.path = strings.clone(path)
+	state.preview = preview
+	set_disk_state(&state.disk, full, text if !preview else "")
+	state.disk.view = view
+	state.disk.encoding = encoding
+	state.disk.line_ending = line_ending
+	delete(state.disk.source)
+	state.disk.source = strings.clone(source)
+	state.language = editor.detect_language(path, text).id
+	if view == .Hex {
+		state.language = editor.HEX_DUMP_LANGUAGE_ID
+	}
+	if view == .Notebook {
+		// The cells are in the kernel's language, Python when it says none.
+		if lang := editor.language_from_name(kernel if kernel != "" else "python"); lang != nil {
+			state.language = lang.id
+		}
+	}
+	state.cursor_pos = 0
+	state.selection_anchor = -1
+	jump_scroll(state, 0)
+	apply_config(state)
+	sync_cursor(state)
+	set_preferred_col(state)
+	refresh_bookmark_marks(state)
+	refresh_diagnostic_marks(state)
+	refresh_test_marks(state)
+	suggest_server_install(state)
+	if preview {
+		set_message(
+			state,
+			"%s is large; showing its first %d MB, read-only",
+			document_title(path),
+			PREVIEW_SIZE / mem.Megabyte,
+		)
+	}
+	note_recent_file(full)
+	fire_plugin_event(state, .Open, state.path)
+	return true
+}
+
+// Reports whether the buffer holds the file at `full` (an absolute path).
+is_open_file :: proc(state: ^Editor_State, full: string) -> bool {
+	return state.path != "" && workspace_path(state, state.path) == full
+}
+
+// Writes data to a temporary file next to path and renames it into place, so
+// the file is never seen half written.  An existing file keeps its owner and
+// permissions, and a symlink keeps pointing at the file it linked to.
+write_file_atomic :: proc(path: string, data: []u8) -> os.Error {
+	path := resolve_save_path(path)
+	tmp := strings.concatenate({path, ".rune-tmp"}, context.temp_allocator)
+	os.write_entire_file(tmp, data) or_return
+	if os.exists(path) {
+		copy_file_metadata(path, tmp)
+	}
+	if err := os.rename(tmp, path); err != nil {
+		os.remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// ctrl+s: writes the buffer to its file, asking for a path first when it has
+// none and for confirmation when the file changed on disk since it was read.
+// With format.on_save the buffer is formatted first, see format.odin.
+save_document :: proc(state: ^Editor_State) {
+	if state.preview {
+		set_message(state, "%s is read-only", document_title(state.path))
+		return
+	}
+	if state.path == "" {
+		open_prompt(state, "Save as:", proc(state: ^Editor_State, path: string) {
+			path := strings.trim_space(path)
+			if path == "" {
+				return
+			}
+			delete(state.path)
+			state.path = strings.clone(path)
+			// A scratch buffer keeps the language picked for it unless the
+			// name says otherwise.
+			lang := editor.detect_language_by_path(path).id
+			if lang != editor.PLAIN_TEXT_LANGUAGE_ID {
+				set_language(state, lang)
+			}
+			format_and_write(state)
+		})
+		return
+	}
+	if changed, _ := disk_changed(&state.disk, workspace_path(state, state.path)); changed {
+		confirm(state, "File changed on disk; overwrite it? (y/n)", proc(state: ^Editor_State) {
+			format_and_write(state)
+		})
+		return
+	}
+	format_and_write(state)
+}
+
+// Writes the active buffer to its file unconditionally.
+write_document :: proc(state: ^Editor_State) -> bool {
+	if state.disk.view == .Notebook {
+		name_notebook_cells(state)
+	}
+	full := workspace_path(state, state.path)
+	text := editor.get_text(&state.buffer, context.temp_allocator)
+	data, ok := buffer_to_file_data(&state.disk, text)
+	if !ok {
+		name := editor.ENCODING_NAMES[state.disk.encoding]
+		set_message(state, "%s has characters that %s cannot represent", state.path, name)
+		return false
+	}
+	if err := backup_file(state, full); err != nil {
+		if is_permission_error(err) {
+			offer_root_save(state)
+			return false
+		}
+		set_message(state, "Failed to back up %s: %v", state.path, err)
+		return false
+	}
+	if err := write_file_atomic(full, data); err != nil {
+		if is_permission_error(err) {
+			offer_root_save(state)
+			return false
+		}
+		set_message(state, "Failed to save %s: %v", state.path, err)
+		return false
+	}
+	set_disk_state(&state.disk, full, text)
+	if state.disk.view == .Notebook {
+		saved := editor.notebook_json(state.disk.source, text)
+		delete(state.disk.source)
+		state.disk.source = strings.clone(saved)
+	}
+	editor.mark_saved(&state.undo)
+	discard_recovery_snapshot(state, state.doc_id)
+	refresh_bookmark_marks(state)
+	refresh_diagnostic_marks(state)
+	refresh_test_marks(state)
+	set_message(state, "Saved %s", document_title(state.path))
+	if .Linters not_in state.degraded {
+		run_linters(state)
+		validate_json_on_save(state)
+	}
+	if .History not_in state.degraded {
+		record_file_history(state, text)
+	}
+	update_tags_after_save(state)
+	fire_plugin_event(state, .Save, state.path)
+	return true
+}
+
+// Converts buffer text to the bytes saved for it: the parsed hex dump, or the
+// text, or the notebook of a notebook's cells, in the file's encoding and line
+// ending.  Temp allocated.
+buffer_to_file_data :: proc(disk: ^Disk_State, text: string) -> (data: []u8, ok: bool) {
+	if disk.view == .Hex {
+		return editor.parse_hex_dump(text, context.temp_allocator), true
+	}
+	text := text
+	if disk.view == .Notebook {
+		text = editor.notebook_json(disk.source, text)
+	}
+	eol_text := editor.convert_line_endings(text, disk.line_ending, context.temp_allocator)
+	return editor.encode_text(eol_text, disk.encoding, context.temp_allocator)
+}
+
+// Converts file bytes to buffer text the way the buffer represents its file.
+// Text that is not valid in the file's encoding is detected anew.  Temp
+// allocated.
+file_data_to_buffer :: proc(disk: ^Disk_State, data: []u8) -> string {
+	if disk.view == .Hex {
+		return editor.format_hex_dump(data, allocator = context.temp_allocator)
+	}
+	text, ok := editor.decode_text(data, disk.encoding, context.temp_allocator)
+	if !ok {
+		text, disk.encoding = editor.decode_file(data, context.temp_allocator)
+	}
+	text = editor.normalize_line_endings(text, context.temp_allocator)
+	if disk.view == .Notebook {
+		// The notebook as read is what its cells save into from now on; a
+		// file that is no longer one is shown as it is.
+		delete(disk.source)
+		disk.source = ""
+		nb, is_notebook := editor.parse_notebook(text)
+		if !is_notebook {
+			disk.view = .Text
+			return text
+		}
+		disk.source = strings.clone(text)
+		return editor.notebook_text(nb)
+	}
+	return text
+}
+
+// Remembers text as the contents of the file at full.
+set_disk_state :: proc(disk: ^Disk_State, full, text: string) {
+	delete(disk.text)
+	disk.text = strings.clone(text)
+	disk.mtime, disk.size = {}, 0
+	if fi, err := os.stat(full, context.temp_allocator); err == nil {
+		disk.mtime = fi.modification_time
+		disk.size = fi.size
+	}
+}
+
+// Reports whether the file now holds something other than disk.text.  A
+// changed timestamp with the same contents only refreshes the timestamp.
+// `current` is the new contents as buffer text, temp allocated.
+disk_changed :: proc(disk: ^Disk_State, full: string) -> (changed: bool, current: string) {
+	fi, err := os.stat(full, context.temp_allocator)
+	if err != nil {
+		return false, "" // deleted or unreadable: nothing to reload
+	}
+	if fi.modification_time == disk.mtime && fi.size == disk.size {
+		return false, ""
+	}
+	data, rerr := os.read_entire_file_from_path(full, context.temp_allocator)
+	if rerr != nil {
+		return false, ""
+	}
+	text := file_data_to_buffer(disk, data)
+	if text == disk.text {
+		disk.mtime = fi.modification_time
+		disk.size = fi.size
+		return false, ""
+	}
+	return true, text
+}
+
+// Asks for an encoding and saves the buffer in it from now on.
+save_with_encoding :: proc(state: ^Editor_State) {
+	open_prompt(state, "Save with encoding:", proc(state: ^Editor_State, name: string) {
+		encoding, ok := editor.parse_encoding(strings.trim_space(name))
+		if !ok {
+			set_message(state, "Unknown encoding %q", name)
+			return
+		}
+		text := editor.get_text(&state.buffer, context.temp_allocator)
+		if _, ok = editor.encode_text(text, encoding, context.temp_allocator); !ok {
+			set_message(
+				state,
+				"The buffer has characters that %s cannot represent",
+				editor.ENCODING_NAMES[encoding],
+			)
+			return
+		}
+		state.disk.encoding = encoding
+		save_document(state)
+	})
+}
+
+// Asks for an encoding and reads the file again in it, for when detection
+// guessed wrong.
+reopen_with_encoding :: proc(state: ^Editor_State) {
+	if state.path == "" || is_read_only(state) || editor.is_modified(&state.undo) {
+		set_message(state, "Reopening needs a text file without unsaved edits")
+		return
+	}
+	open_prompt(state, "Reopen with encoding:", proc(state: ^Editor_State, name: string) {
+		encoding, ok := editor.parse_encoding(strings.trim_space(name))
+		if !ok {
+			set_message(state, "Unknown encoding %q", name)
+			return
+		}
+		full := workspace_path(state, state.path)
+		data, err := os.read_entire_file_from_path(full, context.temp_allocator)
+		if err != nil {
+			set_message(state, "Failed to read %s: %v", state.path, err)
+			return
+		}
+		text, tok := editor.decode_text(data, encoding, context.temp_allocator)
+		if !tok {
+			set_message(state, "%s is not valid %s", state.path, editor.ENCODING_NAMES[encoding])
+			return
+		}
+		state.disk.encoding = encoding
+		reload_document(state, editor.normalize_line_endings(text, context.temp_allocator))
+	})
+}
+
+// Changes the line ending the buffer is saved with.
+set_line_ending :: proc(state: ^Editor_State, ending: editor.Line_Ending) {
+	name := editor.LINE_ENDING_NAMES[ending]
+	if state.disk.line_ending == ending {
+		set_message(state, "Line endings are already %s", name)
+		return
+	}
+	state.disk.line_ending = ending
+	editor.mark_modified(&state.undo)
+	set_message(state, "Line endings set to %s; save to convert the file", name)
+}
+
+// Reads up to n bytes from the start of the file at path, temp allocated.
+@(private = "file")
+read_file_prefix :: proc(path: string, n: int) -> (data: []u8, err: os.Error) {
+	f := os.open(path) or_return
+	defer os.close(f)
+	buf := make([]u8, n, context.temp_allocator)
+	read := 0
+	for read < n {
+		k, rerr := os.read(f, buf[read:])
+		read += k
+		if rerr != nil || k == 0 {
+			break // end of file; a read error just ends the preview early
+		}
+	}
+	return buf[:read], nil
+}
@@ -0,0 +1,74 @@
+#+build !windows
+package main
+
+import "core:c/libc"
+import "core:os"
+import "core:path/filepath"
+import "core:strings"
+import "core:sys/posix"
+
+// The file a save should write: the target of path when it is a symlink, so
+// the rename replaces the file rather than the link.  Temp allocated.
+resolve_save_path :: proc(path: string) -> string {
+	real := posix.realpath(strings.clone_to_cstring(path, context.temp_allocator))
+	if real == nil {
+		return path // does not exist yet
+	}
+	defer libc.free(rawptr(real))
+	return strings.clone_from_cstring(real, context.temp_allocator)
+}
+
+// Gives dst the owner, group and permission bits of src.  Only root can give
+// a file away, so the owner is best effort; keeping the group usually works.
+copy_file_metadata :: proc(src, dst: string) {
+	st: posix.stat_t
+	if posix.stat(strings.clone_to_cstring(src, context.temp_allocator), &st) != .OK {
+		return
+	}
+	cdst := strings.clone_to_cstring(dst, context.temp_allocator)
+	if posix.chown(cdst, st.st_uid, st.st_gid) != .OK {
+		_ = posix.chown(cdst, ~posix.uid_t(0), st.st_gid)
+	}
+	// After chown, which may clear the setuid and setgid bits.
+	_ = posix.chmod(cdst, st.st_mode)
+}
+
+// The program and arguments that run cmd through the shell.  Temp allocated.
+shell_command :: proc(cmd: string) -> []string {
+	args := make([]string, 3, context.temp_allocator)
+	args[0], args[1], args[2] = "/bin/sh", "-c", cmd
+	return args
+}
+
+// s as one word for shell_command's shell, in single quotes.  Temp allocated.
+shell_quote :: proc(s: string) -> string {
+	quoted, _ := strings.replace_all(s, "'", `'\''`, context.temp_allocator)
+	return strings.concatenate({"'", quoted, "'"}, context.temp_allocator)
+}
+
+// The command that opens an address in the user's browser.  Temp allocated.
+open_url_command :: proc(url: string) -> []string {
+	args := make([]string, 2, context.temp_allocator)
+	args[0], args[1] = "open" if ODIN_OS == .Darwin else "xdg-open", url
+	return args
+}
+
+// The command that runs a program as root: configured when it is set, else
+// pkexec when installed, else sudo -A when $SUDO_ASKPASS can ask for the
+// password without a terminal.  Temp allocated.
+root_helper :: proc(configured: string) -> (command: []string, ok: bool) {
+	if configured != "" {
+		command = strings.fields(configured, context.temp_allocator)
+		return command, len(command) > 0
+	}
+	path := os.get_env("PATH", context.temp_allocator)
+	for dir in strings.split(path, ":", context.temp_allocator) {
+		if dir != "" && os.exists(filepath.join({dir, "pkexec"}, context.temp_allocator)) {
+			return strings.fields("pkexec", context.temp_allocator), true
+		}
+	}
+	if os.get_env("SUDO_ASKPASS", context.temp_allocator) != "" {
+		return strings.fields("sudo -A", context.temp_allocator), true
+	}
+	return nil, false
+}
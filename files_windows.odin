@@ -0,0 +1,35 @@
+package main
+
+import "core:strings"
+
+// Owners and mode bits are a POSIX notion; on Windows a save only replaces
+// the contents, and symlinks are rare enough to write over.
+resolve_save_path :: proc(path: string) -> string {
+	return path
+}
+
+copy_file_metadata :: proc(src, dst: string) {}
+
+// Elevating a single command needs UAC, which runs it apart from the editor;
+// saving as root is left to an editor run as administrator.
+root_helper :: proc(configured: string) -> (command: []string, ok: bool) {
+	return nil, false
+}
+
+shell_command :: proc(cmd: string) -> []string {
+	args := make([]string, 3, context.temp_allocator)
+	args[0], args[1], args[2] = "cmd.exe", "/C", cmd
+	return args
+}
+
+// s as one word for cmd.exe, in double quotes.  Temp allocated.
+shell_quote :: proc(s: string) -> string {
+	quoted, _ := strings.replace_all(s, `"`, `""`, context.temp_allocator)
+	return strings.concatenate({`"`, quoted, `"`}, context.temp_allocator)
+}
+
+open_url_command :: proc(url: string) -> []string {
+	args := make([]string, 3, context.temp_allocator)
+	args[0], args[1], args[2] = "rundll32", "url.dll,FileProtocolHandler", url
+	return args
+}
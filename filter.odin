@@ -0,0 +1,256 @@
+package main
+
+import "core:os"
+import "core:strings"
+import editor "editor"
+
+// Pipes the selection, or the whole buffer, through a shell command such as
+// `sort` or `jq .` and replaces it with what the command prints.  The
+// command runs in the background; its input and error output go through
+// temporary files so a large buffer cannot block on a full pipe.
+Filter_State :: struct {
+	purpose:   Filter_Purpose,
+	run:       ^editor.Task_Run,
+	input:     ^os.File, // temporary file the command reads
+	errors:    ^os.File, // temporary file its error output goes to
+	output:    strings.Builder,
+	doc_id:    int, // Editor_State.doc_id of the filtered buffer
+	start:     int, // byte position of the filtered text
+	original:  string, // the filtered text, to notice edits made meanwhile
+	selected:  bool, // the selection was filtered rather than the buffer
+	cancelled: bool,
+	command:   string, // the running or last run command
+	typed:     string, // the last command given to edit.filter, offered again
+}
+
+Filter_Purpose :: enum u8 {
+	Replace, // the output replaces the text
+	Format, // the output is the buffer formatted, see format.odin
+	Format_And_Save, // ... and the buffer is saved afterwards
+	Imports, // the output is the buffer with its imports organized, see imports.odin
+	Imports_And_Save, // ... and the buffer is formatted and saved afterwards
+}
+
+destroy_filter :: proc(state: ^Editor_State) {
+	f := &state.filter
+	if f.run != nil {
+		editor.destroy_task(f.run)
+		f.run = nil
+		close_filter_files(f)
+	}
+	strings.builder_destroy(&f.output)
+	delete(f.original)
+	delete(f.command)
+	delete(f.typed)
+}
+
+// Prompts for a command to pipe the selection, or the whole buffer, through.
+filter_selection :: proc(state: ^Editor_State) {
+	open_prompt(state, "Filter through:", proc(state: ^Editor_State, command: string) {
+		command := strings.trim_space(command)
+		if command == "" {
+			return
+		}
+		f := &state.filter
+		if command != f.typed {
+			delete(f.typed)
+			f.typed = strings.clone(command)
+		}
+		start_filter(state, command, .Replace)
+	}, initial = state.filter.typed)
+}
+
+cancel_filter :: proc(state: ^Editor_State) {
+	f := &state.filter
+	if f.run == nil {
+		set_message(state, "No filter is running")
+		return
+	}
+	f.cancelled = true
+	editor.cancel_task(f.run)
+}
+
+// Collects the filter's output and applies it once the command exits.
+// Called every frame.
+update_filter :: proc(state: ^Editor_State) {
+	f := &state.filter
+	if f.run == nil {
+		return
+	}
+	finished := editor.is_task_finished(f.run)
+	editor.take_task_output(f.run, &f.output)
+	if !finished {
+		return
+	}
+	code := f.run.exit_code
+	editor.destroy_task(f.run)
+	f.run = nil
+	errors, _ := os.read_entire_file_from_path(os.name(f.errors), context.temp_allocator)
+	close_filter_files(f)
+
+	applied := false
+	switch {
+	case f.cancelled:
+		set_message(state, "%s was cancelled", f.command)
+	case code != 0:
+		first, _, _ := strings.partition(strings.trim_space(string(errors)), "\n")
+		set_message(state, "%s failed with exit code %d: %s", f.command, code, first)
+	case f.doc_id != state.doc_id || !filter_text_unchanged(state):
+		set_message(state, "The buffer changed while %s ran; its output was dropped", f.command)
+	case f.purpose == .Replace:
+		apply_filter_output(state)
+	case f.purpose == .Imports || f.purpose == .Imports_And_Save:
+		applied = apply_format_output(
+			state,
+			strings.to_string(f.output),
+			f.original,
+			f.command,
+			"Organized imports",
+			"The imports are in order",
+		)
+	case:
+		applied = apply_format_output(state, strings.to_string(f.output), f.original, f.command)
+	}
+	if f.purpose == .Imports_And_Save && f.doc_id == state.doc_id {
+		// Format and save even when organizing failed, as below.
+		format_then_write(state)
+		return
+	}
+	if f.purpose == .Format_And_Save && f.doc_id == state.doc_id {
+		// Save even when formatting failed, keeping the reason in view.
+		problem := strings.clone(strings.to_string(state.message), context.temp_allocator)
+		if write_document(state) && !applied {
+			set_message(state, "Saved %s unformatted: %s", document_title(state.path), problem)
+		}
+	}
+}
+
+// Runs command in the background on the selection, or the whole buffer
+// when nothing is selected or the purpose is formatting.  Returns false,
+// having said why, when it cannot start.
+start_filter :: proc(state: ^Editor_State, command: string, purpose: Filter_Purpose) -> bool {
+	f := &state.filter
+	if f.run != nil {
+		set_message(state, "%s is still running; filter.cancel stops it", f.command)
+		return false
+	}
+	if is_read_only(state) {
+		set_message(state, "%s is read-only", document_title(state.path))
+		return false
+	}
+	start, end := 0, editor.current_length(&state.buffer)
+	f.selected = purpose == .Replace && has_selection(state)
+	if f.selected {
+		start, end = selection_range(state)
+	}
+	text := editor.get_text_segment(&state.buffer, start, end - start, context.temp_allocator)
+
+	err: os.Error
+	if f.input, err = write_filter_input(text); err != nil {
+		set_message(state, "Cannot write the filter's input: %v", err)
+		return false
+	}
+	if f.errors, err = os.create_temp_file("", "rune-filter-*"); err != nil {
+		close_filter_files(f)
+		set_message(state, "Cannot create a file for the filter's errors: %v", err)
+		return false
+	}
+	run, rerr := editor.start_task(
+		shell_command(command),
+		state.workspace_root,
+		stdin = f.input,
+		stderr = f.errors,
+	)
+	if rerr != nil {
+		close_filter_files(f)
+		set_message(state, "Cannot run %s: %v", command, rerr)
+		return false
+	}
+
+	f.run = run
+	f.purpose = purpose
+	f.doc_id = state.doc_id
+	f.start = start
+	f.cancelled = false
+	delete(f.original)
+	f.original = strings.clone(text)
+	delete(f.command)
+	f.command = strings.clone(command)
+	strings.builder_reset(&f.output)
+	set_message(state, "Running %s ...", command)
+	return true
+}
+
+// Replaces the filtered text with the output as one undo step.  A filtered
+// selection stays selected.
+@(private = "file")
+apply_filter_output :: proc(state: ^Editor_State) {
+	f := &state.filter
+	output := editor.normalize_line_endings(strings.to_string(f.output), context.temp_allocator)
+	// Most tools end their output with a line break even when the input had
+	// none, e.g. `sort` on part of a line.
+	if !strings.has_suffix(f.original, "\n") {
+		output = strings.trim_suffix(output, "\n")
+	}
+	if output == f.original {
+		set_message(state, "%s changed nothing", f.command)
+		return
+	}
+	editor.begin_undo_group(&state.undo, state.cursor_pos)
+	editor.replace_bytes(&state.buffer, f.start, len(f.original), transmute([]u8)output)
+	if f.selected {
+		state.selection_anchor = f.start
+		state.cursor_pos = f.start + len(output)
+	} else {
+		state.selection_anchor = -1
+		state.cursor_pos = min(state.cursor_pos, editor.current_length(&state.buffer))
+	}
+	editor.end_undo_group(&state.undo, state.cursor_pos)
+	sync_cursor(state)
+	set_preferred_col(state)
+	set_message(state, "Filtered through %s", f.command)
+}
+
+@(private = "file")
+filter_text_unchanged :: proc(state: ^Editor_State) -> bool {
+	f := &state.filter
+	if f.start + len(f.original) > editor.current_length(&state.buffer) {
+		return false
+	}
+	text := editor.get_text_segment(
+		&state.buffer,
+		f.start,
+		len(f.original),
+		context.temp_allocator,
+	)
+	return text == f.original
+}
+
+// A temporary file holding text, positioned at its start.
+@(private = "file")
+write_filter_input :: proc(text: string) -> (file: ^os.File, err: os.Error) {
+	file = os.create_temp_file("", "rune-filter-*") or_return
+	_, err = os.write_string(file, text)
+	if err == nil {
+		_, err = os.seek(file, 0, .Start)
+	}
+	if err != nil {
+		name := strings.clone(os.name(file), context.temp_allocator)
+		os.close(file)
+		os.remove(name)
+		return nil, err
+	}
+	return file, nil
+}
+
+@(private = "file")
+close_filter_files :: proc(f: ^Filter_State) {
+	for file in ([]^os.File{f.input, f.errors}) {
+		if file != nil {
+			name := strings.clone(os.name(file), context.temp_allocator)
+			os.close(file)
+			os.remove(name)
+		}
+	}
+	f.input, f.errors = nil, nil
+}
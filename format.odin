@@ -0,0 +1,153 @@
+package main
+
+import editor "editor"
+
+// Formats the buffer with an external command: the formatter reads the
+// buffer on stdin and prints it formatted on stdout.  format.command sets
+// one, e.g. per language under [language.python]; otherwise the language's
+// usual formatter is used.  {file} stands for the buffer's path relative to
+// the workspace root, quoted, for formatters that pick their settings by it.
+format_document :: proc(state: ^Editor_State) {
+	command, ok := formatter_command(state)
+	if !ok {
+		set_message(state, "No formatter for %s; set format.command", state.language)
+		return
+	}
+	start_filter(state, command, .Format)
+}
+
+// Saves the buffer, organizing its imports first when imports.on_save is
+// set and formatting it when format.on_save is and it has a formatter.  The
+// save then waits for them.
+format_and_write :: proc(state: ^Editor_State) {
+	if config_bool(state, "imports.on_save") && .Format_On_Save not_in state.degraded {
+		if organize_imports_before_save(state) {
+			return
+		}
+	}
+	format_then_write(state)
+}
+
+// format_and_write once the imports are organized.
+format_then_write :: proc(state: ^Editor_State) {
+	if config_bool(state, "format.on_save") && .Format_On_Save not_in state.degraded {
+		command, ok := formatter_command(state)
+		if ok && start_filter(state, command, .Format_And_Save) {
+			return
+		}
+	}
+	write_document(state)
+}
+
+// Reformats what was just typed when ch, the character before the cursor, is
+// one of a rune.on_type_format provider's triggers, by format.on_type.  The
+// edits join the typing's undo step.
+format_on_type :: proc(state: ^Editor_State, ch: rune) {
+	if !config_bool(state, "format.on_type") || len(state.plugins.formatters) == 0 {
+		return
+	}
+	if state.disk.view != .Text || is_read_only(state) {
+		return
+	}
+	line, col := editor.logical_pos_to_line_col(&state.buffer, state.cursor_pos)
+	reply, encoding, ok := plugin_on_type_format(state, line, col, ch)
+	if !ok {
+		return
+	}
+	lsp_edits, parsed := editor.parse_lsp_text_edit_list(reply)
+	if !parsed {
+		set_message(state, "On-type formatting answered with something other than JSON")
+		return
+	}
+	text := editor.get_text(&state.buffer, context.temp_allocator)
+	edits, err := editor.lsp_byte_edits(text, lsp_edits, encoding)
+	if err != "" {
+		set_message(state, "On-type formatting failed: %s", err)
+		return
+	}
+	editor.begin_undo_group(&state.undo, state.cursor_pos)
+	#reverse for e in edits {
+		editor.replace_bytes(&state.buffer, e.pos, e.count, transmute([]u8)e.text)
+	}
+	state.cursor_pos = editor.map_position(edits, state.cursor_pos)
+	editor.end_undo_group(&state.undo, state.cursor_pos)
+	sync_cursor(state)
+	set_preferred_col(state)
+}
+
+// Applies a formatter's output as the lines that changed, so the cursor,
+// selection and undo history stay put around untouched code.  done and
+// unchanged are the messages for when it changed something and nothing.
+// Returns false, having said why, when the output cannot be used.
+apply_format_output :: proc(
+	state: ^Editor_State,
+	output, original, command: string,
+	done := "Formatted",
+	unchanged := "Already formatted",
+) -> bool {
+	output := editor.normalize_line_endings(output, context.temp_allocator)
+	if output == "" && original != "" {
+		set_message(state, "%s printed nothing; formatters must print to stdout", command)
+		return false
+	}
+	if output == original {
+		set_message(state, "%s", unchanged)
+		return true
+	}
+	edits := editor.diff_text(original, output, context.temp_allocator)
+	editor.begin_undo_group(&state.undo, state.cursor_pos)
+	#reverse for e in edits {
+		editor.replace_bytes(&state.buffer, e.pos, e.count, transmute([]u8)e.text)
+	}
+	state.cursor_pos = editor.map_position(edits, state.cursor_pos)
+	if state.selection_anchor >= 0 {
+		state.selection_anchor = editor.map_position(edits, state.selection_anchor)
+	}
+	editor.end_undo_group(&state.undo, state.cursor_pos)
+	sync_cursor(state)
+	set_preferred_col(state)
+	set_message(state, "%s with %s, %d blocks changed", done, command, len(edits))
+	return true
+}
+
+// The formatter of the active buffer with {file} filled in.  Temp allocated.
+@(private = "file")
+formatter_command :: proc(state: ^Editor_State) -> (string, bool) {
+	command := config_value(state, "format.command").(string) or_else ""
+	if command == "" {
+		command = default_formatter(state.language)
+	}
+	if command == "" {
+		return "", false
+	}
+	file := bookmark_path(state)
+	if file == "" {
+		file = "untitled"
+	}
+	return fill_placeholder(command, "{file}", file), true
+}
+
+// The usual formatter for a language, "" when it has none.
+default_formatter :: proc(language_id: string) -> string {
+	switch language_id {
+	case "odin":
+		return "odinfmt -stdin"
+	case "go":
+		return "gofmt"
+	case "rust":
+		return "rustfmt --emit stdout"
+	case "python":
+		return "black --quiet -"
+	case "c", "cpp", "glsl":
+		return "clang-format --assume-filename={file}"
+	case "javascript", "typescript", "json", "css", "html", "markdown", "yaml":
+		return "prettier --stdin-filepath {file}"
+	case "lua":
+		return "stylua -"
+	case "shellscript":
+		return "shfmt"
+	case "toml":
+		return "taplo fmt -"
+	}
+	return ""
+}
@@ -0,0 +1,13 @@
+#+build !windows
+package main
+
+import "core:testing"
+
+@(test)
+test_formatter_file_is_one_word :: proc(t: ^testing.T) {
+	command := fill_placeholder(default_formatter("c"), "{file}", "src/my file;rm -rf ~.c")
+	testing.expect_value(t, command, `clang-format --assume-filename='src/my file;rm -rf ~.c'`)
+
+	command = fill_placeholder(default_formatter("json"), "{file}", "it's;here.json")
+	testing.expect_value(t, command, `prettier --stdin-filepath 'it'\''s;here.json'`)
+}
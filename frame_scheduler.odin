@@ -0,0 +1,117 @@
+package main
+
+import "core:time"
+import "vendor:glfw"
+
+// Background work waits while keys arrive faster than this apart, so typing
+// and key repeat are echoed on the next frame...
+INPUT_QUIET :: 80 * time.Millisecond
+// ...but never longer than this, so marks and results still show up.
+BACKGROUND_MAX_DELAY :: 500 * time.Millisecond
+// While nothing happens, how often background jobs are polled.
+IDLE_POLL :: 100 * time.Millisecond
+
+// Decides when the main loop draws.  Input queued between two frames is
+// handled together before the next one, frames come no faster than the
+// monitor refreshes, and nothing is drawn while nothing changed.
+Frame_Scheduler :: struct {
+	dirty:           bool, // the next frame has something new to show
+	frame_time:      time.Duration, // the monitor's refresh interval
+	last_frame:      time.Tick,
+	last_input:      time.Tick,
+	last_background: time.Tick,
+}
+
+init_frame_scheduler :: proc(state: ^Editor_State, window: glfw.WindowHandle) {
+	s := &state.frames
+	s.dirty = true
+	s.frame_time = time.Second / 60
+	monitor := glfw.GetWindowMonitor(window)
+	if monitor == nil {
+		monitor = glfw.GetPrimaryMonitor()
+	}
+	if monitor != nil {
+		if mode := glfw.GetVideoMode(monitor); mode != nil && mode.refresh_rate > 0 {
+			s.frame_time = time.Second / time.Duration(mode.refresh_rate)
+		}
+	}
+	glfw.SetWindowRefreshCallback(window, window_refresh_callback)
+}
+
+// Called by the input callbacks: the frame must show the keystroke, and
+// background work holds off for a moment.
+note_input :: proc(state: ^Editor_State) {
+	state.frames.dirty = true
+	state.frames.last_input = time.tick_now()
+}
+
+request_redraw :: proc(state: ^Editor_State) {
+	state.frames.dirty = true
+}
+
+// Handles the pending events, first waiting for some when there is nothing
+// to draw, or for the next frame slot when the last frame was too recent.
+wait_for_frame :: proc(state: ^Editor_State) {
+	s := &state.frames
+	if !s.dirty && !is_animating(state) {
+		glfw.WaitEventsTimeout(time.duration_seconds(IDLE_POLL))
+		return
+	}
+	if left := s.frame_time - time.tick_since(s.last_frame); left > 0 {
+		// Events arriving meanwhile are handled now and drawn together.
+		glfw.WaitEventsTimeout(time.duration_seconds(left))
+		if time.tick_since(s.last_frame) < s.frame_time {
+			return
+		}
+	}
+	glfw.PollEvents()
+}
+
+// Whether a frame is due: something changed and its frame slot has come.
+frame_due :: proc(state: ^Editor_State) -> bool {
+	s := &state.frames
+	if !s.dirty && !is_animating(state) {
+		return false
+	}
+	return time.tick_since(s.last_frame) >= s.frame_time
+}
+
+frame_drawn :: proc(state: ^Editor_State) {
+	state.frames.dirty = false
+	state.frames.last_frame = time.tick_now()
+}
+
+// Whether the jobs that only decorate the buffer (git marks, blame,
+// indexes, linters, ...) may run this tick: not while typing, unless they
+// have waited too long.
+background_due :: proc(state: ^Editor_State) -> bool {
+	s := &state.frames
+	if time.tick_since(s.last_background) < IDLE_POLL {
+		return false
+	}
+	typing := time.tick_since(s.last_input) < INPUT_QUIET
+	if typing && time.tick_since(s.last_background) < BACKGROUND_MAX_DELAY {
+		return false
+	}
+	s.last_background = time.tick_now()
+	s.dirty = true // what they found shows on the next frame
+	return true
+}
+
+// Something moves on its own and needs every frame: smooth scrolling, or
+// results streaming in from a search, a scan or other editors.
+@(private = "file")
+is_animating :: proc(state: ^Editor_State) -> bool {
+	return state.layer_ctx.scroll_y != state.scroll_target ||
+		state.search.running != nil ||
+		state.todos.scan != nil ||
+		state.collab.role != .None
+}
+
+@(private = "file")
+window_refresh_callback :: proc "c" (window: glfw.WindowHandle) {
+	state := cast(^Editor_State)glfw.GetWindowUserPointer(window)
+	if state != nil {
+		state.frames.dirty = true
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import "core:strings"
+import "core:time"
+import editor "editor"
+
+// The buffer is diffed at most this often while it changes.
+GIT_DIFF_INTERVAL :: 250 * time.Millisecond
+// Commits happen outside the editor, so HEAD is read again after this long.
+GIT_HEAD_INTERVAL :: 5 * time.Second
+
+GIT_ADDED_COLOR :: [4]f32{0.40, 0.75, 0.40, 1.0}
+GIT_MODIFIED_COLOR :: [4]f32{0.35, 0.60, 0.90, 1.0}
+GIT_REMOVED_COLOR :: [4]f32{0.90, 0.35, 0.35, 1.0}
+
+// Lines of the active document changed since the last commit, shown in the
+// gutter.  The committed text is cached per document and the buffer diffed
+// against it in the background whenever it changes.
+Git_Changes :: struct {
+	job:         ^editor.Git_Diff,
+	job_doc:     int, // document and undo version the running job diffs
+	job_version: int,
+	doc_id:      int, // document the fields below belong to
+	version:     int, // undo version hunks were computed for, -1 when stale
+	head:        string,
+	have_head:   bool,
+	tracked:     bool,
+	hunks:       [dynamic]editor.Diff_Hunk, // from head to the buffer
+	last_start:  time.Tick,
+	head_read:   time.Tick,
+}
+
+init_git_changes :: proc(state: ^Editor_State) {
+	state.git_changes.doc_id = -1
+	state.git_changes.version = -1
+}
+
+destroy_git_changes :: proc(state: ^Editor_State) {
+	gc := &state.git_changes
+	if gc.job != nil {
+		editor.destroy_git_diff(gc.job)
+		gc.job = nil
+	}
+	delete(gc.head)
+	delete(gc.hunks)
+}
+
+// Collects finished diffs and starts a new one when the buffer changed.
+// Called every frame.
+update_git_changes :: proc(state: ^Editor_State) {
+	gc := &state.git_changes
+	if gc.job != nil {
+		if !editor.is_git_diff_finished(gc.job) {
+			return
+		}
+		take_git_diff(state)
+	}
+
+	if gc.doc_id != state.doc_id {
+		forget_git_changes(state)
+		gc.doc_id = state.doc_id
+	}
+	if gc.have_head && time.tick_since(gc.head_read) >= GIT_HEAD_INTERVAL {
+		reload_git_head(state)
+	}
+	if state.path == "" || state.preview || state.disk.view != .Text ||
+	   .Git_Changes in state.degraded || !config_bool(state, "git.change_marks") {
+		if len(gc.hunks) > 0 {
+			forget_git_changes(state)
+		}
+		return
+	}
+	if gc.version == state.undo.version {
+		return
+	}
+	if gc.have_head && !gc.tracked {
+		gc.version = state.undo.version
+		return
+	}
+	if time.tick_since(gc.last_start) < GIT_DIFF_INTERVAL {
+		return
+	}
+
+	gc.job = editor.start_git_diff(
+		workspace_path(state, state.path),
+		editor.get_text(&state.buffer, context.temp_allocator),
+		state.disk.encoding,
+		gc.head,
+		gc.have_head,
+	)
+	gc.job_doc = state.doc_id
+	gc.job_version = state.undo.version
+	gc.last_start = time.tick_now()
+}
+
+// Reads HEAD again on the next frame, e.g. after a commit or pull.
+reload_git_head :: proc(state: ^Editor_State) {
+	gc := &state.git_changes
+	delete(gc.head)
+	gc.head = ""
+	gc.have_head = false
+	gc.version = -1
+}
+
+// Moves the cursor to the start of the next changed block.
+next_git_change :: proc(state: ^Editor_State) {
+	hunks := state.git_changes.hunks[:]
+	if len(hunks) == 0 {
+		set_message(state, "No changes")
+		return
+	}
+	line := state.cursor_data.line
+	for h in hunks {
+		if h.new_start > line {
+			goto_git_change(state, h)
+			return
+		}
+	}
+	goto_git_change(state, hunks[0])
+	set_message(state, "Wrapped to the first change")
+}
+
+prev_git_change :: proc(state: ^Editor_State) {
+	hunks := state.git_changes.hunks[:]
+	if len(hunks) == 0 {
+		set_message(state, "No changes")
+		return
+	}
+	line := state.cursor_data.line
+	#reverse for h in hunks {
+		if h.new_start < line {
+			goto_git_change(state, h)
+			return
+		}
+	}
+	goto_git_change(state, hunks[len(hunks) - 1])
+	set_message(state, "Wrapped to the last change")
+}
+
+// Puts the committed version of the changed block under the cursor back into
+// the buffer.
+revert_git_change :: proc(state: ^Editor_State) {
+	gc := &state.git_changes
+	if gc.version != state.undo.version {
+		set_message(state, "Changes are still being computed")
+		return
+	}
+	gb := &state.buffer
+	count := editor.get_line_count(gb)
+	h, ok := git_change_at_line(state, state.cursor_data.line)
+	if !ok {
+		set_message(state, "No change on this line")
+		return
+	}
+
+	old_lines := strings.split(gc.head, "\n", context.temp_allocator)
+	text := strings.join(old_lines[h.old_start:][:h.old_count], "\n", context.temp_allocator)
+	start, end: int
+	switch {
+	case h.new_count == 0 && h.new_start < count:
+		start = editor.line_col_to_logical_pos(gb, h.new_start, 0)
+		end = start
+		text = strings.concatenate({text, "\n"}, context.temp_allocator)
+	case h.new_count == 0:
+		// the removed lines were at the very end, after the last line break
+		start = editor.current_length(gb)
+		end = start
+		text = strings.concatenate({"\n", text}, context.temp_allocator)
+	case:
+		start = editor.line_col_to_logical_pos(gb, h.new_start, 0)
+		end = editor.current_length(gb)
+		if h.new_start + h.new_count < count {
+			end = editor.line_col_to_logical_pos(gb, h.new_start + h.new_count, 0)
+			if h.old_count > 0 {
+				text = strings.concatenate({text, "\n"}, context.temp_allocator)
+			}
+		} else if h.old_count == 0 && start > 0 {
+			start -= 1 // the last lines go; so does the line break before them
+		}
+	}
+	editor.replace_bytes(gb, start, end - start, transmute([]u8)text)
+	goto_line_col(state, min(h.new_start, editor.get_line_count(gb) - 1), 0)
+	set_message(state, "Change reverted")
+}
+
+@(private = "file")
+goto_git_change :: proc(state: ^Editor_State, h: editor.Diff_Hunk) {
+	record_jump(state)
+	goto_line_col(state, min(h.new_start, editor.get_line_count(&state.buffer) - 1), 0)
+}
+
+// The changed block covering line.  Removed lines belong to the line below
+// them, or to the last line when they were at the end.
+@(private = "file")
+git_change_at_line :: proc(state: ^Editor_State, line: int) -> (editor.Diff_Hunk, bool) {
+	count := editor.get_line_count(&state.buffer)
+	for h in state.git_changes.hunks {
+		if h.new_count > 0 && line >= h.new_start && line < h.new_start + h.new_count {
+			return h, true
+		}
+	}
+	for h in state.git_changes.hunks {
+		if h.new_count == 0 && line == min(h.new_start, count - 1) {
+			return h, true
+		}
+	}
+	return {}, false
+}
+
+@(private = "file")
+take_git_diff :: proc(state: ^Editor_State) {
+	gc := &state.git_changes
+	job := gc.job
+	gc.job = nil
+	defer editor.destroy_git_diff(job)
+	if gc.job_doc != gc.doc_id {
+		return
+	}
+	if !gc.have_head {
+		gc.head = strings.clone(job.head)
+		gc.have_head = true
+		gc.tracked = job.tracked
+		gc.head_read = time.tick_now()
+	}
+	clear(&gc.hunks)
+	append(&gc.hunks, ..job.hunks)
+	gc.version = gc.job_version
+	refresh_git_change_marks(state)
+}
+
+@(private = "file")
+forget_git_changes :: proc(state: ^Editor_State) {
+	gc := &state.git_changes
+	delete(gc.head)
+	gc.head = ""
+	gc.have_head = false
+	gc.tracked = false
+	gc.version = -1
+	clear(&gc.hunks)
+	refresh_git_change_marks(state)
+}
+
+@(private = "file")
+refresh_git_change_marks :: proc(state: ^Editor_State) {
+	marks := make([dynamic]editor.Gutter_Mark, context.temp_allocator)
+	for h in state.git_changes.hunks {
+		switch editor.hunk_kind(h) {
+		case .Added:
+			for line in h.new_start ..< h.new_start + h.new_count {
+				append(&marks, editor.Gutter_Mark{line = line, color = GIT_ADDED_COLOR})
+			}
+		case .Modified:
+			for line in h.new_start ..< h.new_start + h.new_count {
+				append(&marks, editor.Gutter_Mark{line = line, color = GIT_MODIFIED_COLOR})
+			}
+		case .Removed:
+			mark := editor.Gutter_Mark {
+				line    = h.new_start,
+				color   = GIT_REMOVED_COLOR,
+				between = true,
+			}
+			append(&marks, mark)
+		}
+	}
+	editor.set_gutter_marks(state.gutter_data, .Change, marks[:])
+}
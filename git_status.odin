@@ -0,0 +1,275 @@
+package main
+
+import "core:fmt"
+import "core:path/filepath"
+import "core:strings"
+import editor "editor"
+
+COMMIT_TEMPLATE :: `
+
+# Write the commit message above.  Lines starting with '#' are left out.
+# ctrl+enter commits the staged changes; close the buffer to abort.
+`
+
+// The git command running for the status panel, at most one at a time.
+Git_Operation :: enum {
+	None,
+	Status,
+	Stage,
+	Unstage,
+	Commit,
+	Push,
+	Pull,
+}
+
+// A changed file as `git status --porcelain` reports it.
+Git_File_Status :: struct {
+	index:    u8, // staged change: 'M', 'A', 'D', 'R', ... or ' '
+	worktree: u8, // unstaged change, '?' for untracked
+	path:     string, // relative to repo_root
+}
+
+Git_Status_State :: struct {
+	active:     bool, // the panel lists the status
+	repo_root:  string,
+	files:      [dynamic]Git_File_Status,
+	job:        ^editor.Git_Command,
+	operation:  Git_Operation,
+	commit_doc: int, // id of the commit message buffer, 0 for none
+}
+
+destroy_git_status :: proc(state: ^Editor_State) {
+	g := &state.git_status
+	if g.job != nil {
+		editor.destroy_git_command(g.job)
+	}
+	clear_git_files(g)
+	delete(g.files)
+	delete(g.repo_root)
+}
+
+// Lists the changed files of the workspace's repository in the panel, each
+// with a checkbox that stages or unstages it.
+show_git_status :: proc(state: ^Editor_State) {
+	g := &state.git_status
+	root, ok := editor.run_git(state.workspace_root, {"rev-parse", "--show-toplevel"})
+	if !ok {
+		set_message(state, "%s is not in a git repository", state.workspace_root)
+		return
+	}
+	delete(g.repo_root)
+	g.repo_root = strings.clone(strings.trim_space(root))
+	delete(root)
+
+	clear_replace(state)
+	release_panel(state)
+	g.active = true
+	editor.panel_clear(state.panel_data)
+	editor.panel_set_title(state.panel_data, "Git status ...")
+	show_panel(state)
+	start_git_operation(state, .Status, {"status", "--porcelain=v1", "-z"})
+}
+
+// Stages or unstages the selected file of the status panel.
+toggle_git_stage :: proc(state: ^Editor_State) {
+	g := &state.git_status
+	item := editor.panel_selected_item(state.panel_data)
+	if item == nil || item.check == .None || item.data >= len(g.files) {
+		return
+	}
+	if g.job != nil {
+		set_message(state, "git is busy")
+		return
+	}
+	editor.panel_toggle_selected(state.panel_data)
+	path := g.files[item.data].path
+	if item.check == .Checked {
+		start_git_operation(state, .Stage, {"add", "-A", "--", path})
+	} else {
+		start_git_operation(state, .Unstage, {"restore", "--staged", "--", path})
+	}
+}
+
+// Opens a buffer for the commit message.
+start_git_commit :: proc(state: ^Editor_State) {
+	dir := state.workspace_root
+	if _, ok := editor.run_git(dir, {"rev-parse", "--git-dir"}, context.temp_allocator); !ok {
+		set_message(state, "%s is not in a git repository", state.workspace_root)
+		return
+	}
+	new_document(state)
+	set_language(state, editor.GIT_COMMIT_LANGUAGE_ID)
+	gb := &state.buffer
+	gb.undo = nil
+	editor.replace_bytes(gb, 0, 0, transmute([]u8)string(COMMIT_TEMPLATE))
+	gb.undo = &state.undo
+	state.git_status.commit_doc = state.doc_id
+	set_message(state, "Write the commit message, then ctrl+enter")
+}
+
+// Commits the staged changes with the message in the commit buffer.
+finish_git_commit :: proc(state: ^Editor_State) {
+	g := &state.git_status
+	if g.commit_doc != state.doc_id {
+		set_message(state, "This is not a commit message buffer")
+		return
+	}
+	if g.job != nil {
+		set_message(state, "git is busy")
+		return
+	}
+	text := editor.get_text(&state.buffer, context.temp_allocator)
+	lines := make([dynamic]string, context.temp_allocator)
+	for line in strings.split_lines_iterator(&text) {
+		if !strings.has_prefix(line, "#") {
+			append(&lines, strings.trim_right_space(line))
+		}
+	}
+	message := strings.trim_space(strings.join(lines[:], "\n", context.temp_allocator))
+	if message == "" {
+		set_message(state, "The commit message is empty")
+		return
+	}
+	start_git_operation(state, .Commit, {"commit", "-m", message})
+}
+
+git_push :: proc(state: ^Editor_State) {
+	start_git_operation(state, .Push, {"push"})
+}
+
+git_pull :: proc(state: ^Editor_State) {
+	start_git_operation(state, .Pull, {"pull", "--ff-only"})
+}
+
+// Collects the result of the running git command.  Called every frame.
+update_git_status :: proc(state: ^Editor_State) {
+	g := &state.git_status
+	if g.job == nil || !editor.is_git_command_finished(g.job) {
+		return
+	}
+	job := g.job
+	operation := g.operation
+	g.job = nil
+	g.operation = .None
+	defer editor.destroy_git_command(job)
+
+	if !job.ok {
+		set_message(state, "git %s failed: %s", job.args[0], last_line(job.errors))
+		if operation == .Stage || operation == .Unstage {
+			refresh_git_status(state) // put the checkbox back
+		}
+		return
+	}
+	switch operation {
+	case .None:
+	case .Status:
+		parse_git_status(g, job.output)
+		list_git_status(state)
+	case .Stage, .Unstage:
+		refresh_git_status(state)
+	case .Commit:
+		set_message(state, "%s", first_line(job.output))
+		if state.doc_id == g.commit_doc {
+			close_document(state)
+		}
+		g.commit_doc = 0
+		reload_git_head(state)
+		refresh_git_status(state)
+	case .Push:
+		set_message(state, "Pushed: %s", last_line(job.errors))
+	case .Pull:
+		set_message(state, "Pulled: %s", first_line(job.output))
+		reload_git_head(state)
+		refresh_git_status(state)
+	}
+}
+
+@(private = "file")
+start_git_operation :: proc(state: ^Editor_State, operation: Git_Operation, args: []string) {
+	g := &state.git_status
+	if g.job != nil {
+		set_message(state, "git is busy")
+		return
+	}
+	dir := g.repo_root if g.repo_root != "" else state.workspace_root
+	g.job = editor.start_git_command(dir, args)
+	g.operation = operation
+	if operation != .Status {
+		set_message(state, "git %s ...", args[0])
+	}
+}
+
+// Lists the status again if the panel still shows it.
+@(private = "file")
+refresh_git_status :: proc(state: ^Editor_State) {
+	if state.git_status.active {
+		start_git_operation(state, .Status, {"status", "--porcelain=v1", "-z"})
+	}
+}
+
+// Reads `git status --porcelain=v1 -z`: NUL separated "XY path" records, a
+// rename or copy followed by a record with the original path.
+@(private = "file")
+parse_git_status :: proc(g: ^Git_Status_State, out: string) {
+	clear_git_files(g)
+	records := strings.split(out, "\x00", context.temp_allocator)
+	for i := 0; i < len(records); i += 1 {
+		r := records[i]
+		if len(r) < 4 {
+			continue
+		}
+		append(&g.files, Git_File_Status{r[0], r[1], strings.clone(r[3:])})
+		if r[0] == 'R' || r[0] == 'C' {
+			i += 1
+		}
+	}
+}
+
+// A file is checked when all of its changes are staged.
+@(private = "file")
+list_git_status :: proc(state: ^Editor_State) {
+	g := &state.git_status
+	panel := state.panel_data
+	selected := panel.selected
+	editor.panel_clear(panel)
+	editor.panel_set_title(
+		panel,
+		fmt.tprintf("Git status (%d changed), space stages or unstages", len(g.files)),
+	)
+	for f, i in g.files {
+		staged := f.index != ' ' && f.index != '?' && f.worktree == ' '
+		item := editor.Panel_Item {
+			text  = fmt.tprintf("%c%c %s", f.index, f.worktree, f.path),
+			path  = filepath.join({g.repo_root, f.path}, context.temp_allocator),
+			line  = -1,
+			check = .Checked if staged else .Unchecked,
+			data  = i,
+		}
+		editor.panel_add_item(panel, item)
+	}
+	panel.selected = clamp(selected, 0, max(len(panel.items) - 1, 0))
+	if len(g.files) == 0 {
+		editor.panel_set_title(panel, "Git status: nothing to commit")
+	}
+}
+
+@(private = "file")
+clear_git_files :: proc(g: ^Git_Status_State) {
+	for f in g.files {
+		delete(f.path)
+	}
+	clear(&g.files)
+}
+
+@(private = "file")
+first_line :: proc(s: string) -> string {
+	s := strings.trim_space(s)
+	line, _, _ := strings.partition(s, "\n")
+	return line
+}
+
+@(private = "file")
+last_line :: proc(s: string) -> string {
+	s := strings.trim_space(s)
+	return s[strings.last_index_byte(s, '\n') + 1:]
+}
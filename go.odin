@@ -0,0 +1,139 @@
+package main
+
+import "core:fmt"
+import "core:path/filepath"
+import "core:strings"
+import editor "editor"
+
+// Go's tools on Go buffers: go.gc_details lists what the compiler decided
+// about inlining, escapes and bounds checks with go build -gcflags=-m as a
+// task, and go.vulncheck runs govulncheck over the module.  go.add_import,
+// go.remove_import and go.list_imports manage the file's imports.
+
+// Where go build writes a binary nobody wants.
+when ODIN_OS == .Windows {
+	GO_NULL_OUTPUT :: "NUL"
+} else {
+	GO_NULL_OUTPUT :: "/dev/null"
+}
+
+// go.gc_details: the compiler's optimization decisions in the package.
+show_gc_details :: proc(state: ^Editor_State) {
+	dir, ok := go_package_dir(state)
+	if !ok {
+		return
+	}
+	command := fmt.tprintf("go build -gcflags=-m -o %s .", GO_NULL_OUTPUT)
+	start_task_run(state, "gc details", command, dir)
+}
+
+// go.vulncheck: the known vulnerabilities the module's code reaches.
+run_vulncheck :: proc(state: ^Editor_State) {
+	if !is_go_buffer(state) {
+		return
+	}
+	start_task_run(state, "govulncheck", "govulncheck ./...", state.workspace_root)
+}
+
+// go.list_imports: the file's imports, in the panel.
+list_go_imports :: proc(state: ^Editor_State) {
+	if !is_go_buffer(state) {
+		return
+	}
+	text := editor.get_text(&state.buffer, context.temp_allocator)
+	imports := editor.go_imports(text)
+	if len(imports) == 0 {
+		set_message(state, "%s imports nothing", document_title(state.path))
+		return
+	}
+	lines := make([]string, len(imports), context.temp_allocator)
+	for imp, i in imports {
+		lines[i] = imp.path if imp.name == "" else fmt.tprintf("%s (as %s)", imp.path, imp.name)
+	}
+	show_result_lines(state, fmt.tprintf("Imports of %s", document_title(state.path)), lines)
+}
+
+// go.add_import: asks for a package path and imports it.
+add_go_import :: proc(state: ^Editor_State) {
+	if !is_go_buffer(state) {
+		return
+	}
+	open_prompt(state, "Import:", proc(state: ^Editor_State, path: string) {
+		path := strings.trim(strings.trim_space(path), "\"")
+		if path == "" {
+			return
+		}
+		text := editor.get_text(&state.buffer, context.temp_allocator)
+		edit, ok := editor.go_add_import(text, path)
+		if !ok {
+			set_message(state, "%s already imports %s", document_title(state.path), path)
+			return
+		}
+		apply_import_edit(state, edit)
+	})
+}
+
+// go.remove_import: asks for an import, the one on the cursor's line first,
+// and drops it.
+remove_go_import :: proc(state: ^Editor_State) {
+	if !is_go_buffer(state) {
+		return
+	}
+	initial := ""
+	text := editor.get_text(&state.buffer, context.temp_allocator)
+	for imp in editor.go_imports(text) {
+		if imp.start <= state.cursor_pos && state.cursor_pos < imp.end {
+			initial = imp.path
+		}
+	}
+	open_prompt(
+		state,
+		"Remove import:",
+		proc(state: ^Editor_State, path: string) {
+			path := strings.trim(strings.trim_space(path), "\"")
+			text := editor.get_text(&state.buffer, context.temp_allocator)
+			edit, ok := editor.go_remove_import(text, path)
+			if !ok {
+				set_message(state, "%s does not import %s", document_title(state.path), path)
+				return
+			}
+			apply_import_edit(state, edit)
+		},
+		initial = initial,
+	)
+}
+
+@(private = "file")
+apply_import_edit :: proc(state: ^Editor_State, edit: editor.Text_Edit) {
+	if is_read_only(state) {
+		set_message(state, "%s is read-only", document_title(state.path))
+		return
+	}
+	editor.begin_undo_group(&state.undo, state.cursor_pos)
+	editor.replace_bytes(&state.buffer, edit.pos, edit.count, transmute([]u8)edit.text)
+	state.cursor_pos = editor.map_position([]editor.Text_Edit{edit}, state.cursor_pos)
+	editor.end_undo_group(&state.undo, state.cursor_pos)
+	sync_cursor(state)
+}
+
+@(private = "file")
+is_go_buffer :: proc(state: ^Editor_State) -> bool {
+	if state.language != "go" {
+		set_message(state, "Only for Go files")
+		return false
+	}
+	return true
+}
+
+// The directory of the buffer's Go file, its package's.
+@(private = "file")
+go_package_dir :: proc(state: ^Editor_State) -> (string, bool) {
+	if !is_go_buffer(state) {
+		return "", false
+	}
+	if state.path == "" {
+		set_message(state, "Save the file first")
+		return "", false
+	}
+	return filepath.dir(workspace_path(state, state.path), context.temp_allocator), true
+}
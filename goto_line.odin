@@ -0,0 +1,99 @@
+package main
+
+import "core:strconv"
+import "core:strings"
+import editor "editor"
+
+// Where the cursor was when the goto prompt opened, so the live preview can
+// be undone on cancel.
+Goto_State :: struct {
+	cursor:   int,
+	anchor:   int,
+	scroll_y: f32,
+}
+
+// ctrl+g: prompts for `line[:column]` or a relative `+n`/`-n` and previews the
+// target while typing.
+goto_line :: proc(state: ^Editor_State) {
+	state.goto_origin = Goto_State {
+		cursor   = state.cursor_pos,
+		anchor   = state.selection_anchor,
+		scroll_y = state.scroll_target,
+	}
+	open_prompt(
+		state,
+		"Go to line:",
+		on_submit = proc(state: ^Editor_State, text: string) {
+			restore_goto_origin(state)
+			line, col, ok := parse_goto_target(state, text)
+			if !ok {
+				if strings.trim_space(text) != "" {
+					set_message(state, "Invalid line %q", text)
+				}
+				return
+			}
+			record_jump(state)
+			goto_visual_line_col(state, line, col)
+		},
+		on_change = proc(state: ^Editor_State, text: string) {
+			if line, col, ok := parse_goto_target(state, text); ok {
+				goto_visual_line_col(state, line, col)
+			} else {
+				restore_goto_origin(state)
+			}
+		},
+		on_cancel = restore_goto_origin,
+	)
+}
+
+// Parses `line`, `line:col`, `+n` or `-n` (relative to the line the prompt
+// was opened on).  Lines and columns are 1 based in the text and returned 0
+// based, clamped to the buffer.
+parse_goto_target :: proc(state: ^Editor_State, text: string) -> (line, col: int, ok: bool) {
+	text := strings.trim_space(text)
+	if text == "" {
+		return
+	}
+
+	line_text, col_text := text, ""
+	if i := strings.index_byte(text, ':'); i >= 0 {
+		line_text, col_text = text[:i], text[i + 1:]
+	}
+
+	n := strconv.parse_int(line_text, 10) or_return
+	switch line_text[0] {
+	case '+', '-':
+		origin, _ := editor.logical_pos_to_line_col(&state.buffer, state.goto_origin.cursor)
+		line = origin + n
+	case:
+		line = n - 1
+	}
+	line = clamp(line, 0, editor.get_line_count(&state.buffer) - 1)
+
+	if col_text != "" {
+		c := strconv.parse_int(col_text, 10) or_return
+		col = max(c - 1, 0)
+	}
+	return line, col, true
+}
+
+// Moves to a line and visual column (tabs expanded), dropping the selection.
+goto_visual_line_col :: proc(state: ^Editor_State, line, visual_col: int) {
+	byte_col := editor.visual_col_to_byte_col(
+		&state.buffer,
+		line,
+		visual_col,
+		state.layer_ctx.tab_size,
+	)
+	goto_line_col(state, line, byte_col)
+}
+
+@(private = "file")
+restore_goto_origin :: proc(state: ^Editor_State) {
+	o := state.goto_origin
+	state.cursor_pos = min(o.cursor, editor.current_length(&state.buffer))
+	state.selection_anchor = o.anchor
+	jump_scroll(state, o.scroll_y)
+	sync_cursor(state)
+	set_preferred_col(state)
+}
@@ -0,0 +1,298 @@
+package main
+
+import "core:fmt"
+import "core:slice"
+import "core:strings"
+import editor "editor"
+
+// Help pages, written from the command, option and key tables so they never
+// fall behind them.  A page names others between bars, |edit.undo|; enter on
+// one opens it and backspace goes back.
+Help_State :: struct {
+	doc:     int, // Document.id of the help buffer, 0 before the first page
+	history: [dynamic]string, // the topics shown, the current one last
+}
+
+destroy_help :: proc(state: ^Editor_State) {
+	for topic in state.help.history {
+		delete(topic)
+	}
+	delete(state.help.history)
+}
+
+// help.topic: asks for a topic, a command, an option, a command group such
+// as "git", "commands", "options" or "keys"; empty shows the index.
+show_help :: proc(state: ^Editor_State) {
+	open_prompt(state, "Help on:", proc(state: ^Editor_State, text: string) {
+		open_help_topic(state, strings.trim_space(text))
+	})
+}
+
+// Enter in a help buffer: opens the topic between the bars at the cursor, or
+// the line's first one when the cursor is on none.
+follow_help_link :: proc(state: ^Editor_State) {
+	line := editor.get_line(&state.buffer, state.cursor_data.line, context.temp_allocator)
+	col := state.cursor_data.col
+	topic := ""
+	for i := 0; i < len(line); {
+		start := strings.index_byte(line[i:], '|')
+		if start < 0 {
+			break
+		}
+		start += i
+		end := strings.index_byte(line[start + 1:], '|')
+		if end < 0 {
+			break
+		}
+		end += start + 1
+		if topic == "" || start <= col && col <= end {
+			topic = line[start + 1:end]
+		}
+		if start <= col && col <= end {
+			break
+		}
+		i = end + 1
+	}
+	if topic == "" {
+		set_message(state, "No |link| on this line")
+		return
+	}
+	open_help_topic(state, topic)
+}
+
+// Backspace in a help buffer: shows the topic before this one.
+help_back :: proc(state: ^Editor_State) {
+	h := &state.help
+	if len(h.history) < 2 {
+		return
+	}
+	delete(pop(&h.history))
+	topic := pop(&h.history)
+	defer delete(topic)
+	open_help_topic(state, topic)
+}
+
+// Shows the page for topic in the help buffer.
+open_help_topic :: proc(state: ^Editor_State, topic: string) {
+	page, ok := help_page(state, topic)
+	if !ok {
+		set_message(state, "No help for %q; help.topic with nothing lists the topics", topic)
+		return
+	}
+	h := &state.help
+	if h.doc == 0 || !is_document_open(state, h.doc) {
+		if !is_blank_document(state) {
+			new_document(state)
+		}
+		set_language(state, editor.HELP_LANGUAGE_ID)
+		h.doc = state.doc_id
+	}
+	for d, i in state.documents {
+		if i != state.active && d.id == h.doc {
+			switch_document(state, i)
+			break
+		}
+	}
+	gb := &state.buffer
+	gb.undo = nil
+	editor.replace_bytes(gb, 0, editor.current_length(gb), transmute([]u8)page)
+	gb.undo = &state.undo
+	state.cursor_pos = 0
+	state.selection_anchor = -1
+	sync_cursor(state)
+	set_preferred_col(state)
+	if len(h.history) == 0 || h.history[len(h.history) - 1] != topic {
+		append(&h.history, strings.clone(topic))
+	}
+}
+
+// The text of a topic's page.  Temp allocated.
+@(private = "file")
+help_page :: proc(state: ^Editor_State, topic: string) -> (string, bool) {
+	b := strings.builder_make(context.temp_allocator)
+	switch topic {
+	case "", "index":
+		write_help_index(state, &b)
+	case "commands":
+		write_help_title(&b, "commands", "every command")
+		for name in sorted_command_names(state, "") {
+			write_command_line(state, &b, name)
+		}
+	case "options":
+		write_help_title(&b, "options", "every option")
+		for option in CONFIG_OPTIONS {
+			fmt.sbprintf(&b, "|%s|\n    %s\n", option.key, option.help)
+		}
+	case "keys":
+		write_help_keys(state, &b)
+	case:
+		if cmd, ok := state.commands[topic]; ok {
+			write_help_command(state, &b, cmd)
+		} else if option := find_config_option(topic); option != nil {
+			write_help_option(state, &b, option)
+		} else if names := sorted_command_names(state, topic); len(names) > 0 {
+			write_help_title(&b, topic, "command group")
+			for name in names {
+				write_command_line(state, &b, name)
+			}
+		} else {
+			return "", false
+		}
+	}
+	return strings.to_string(b), true
+}
+
+@(private = "file")
+write_help_index :: proc(state: ^Editor_State, b: ^strings.Builder) {
+	write_help_title(b, "index", "Rune help")
+	strings.write_string(
+		b,
+		"Move the cursor onto a |link| and press enter to open it; backspace\n" +
+		"goes back.  help.topic asks for any topic by name.\n\n" +
+		"|commands|   every command, with its keys\n" +
+		"|options|    every option of config.toml, with its default\n" +
+		"|keys|       every key binding\n\n" +
+		"Command groups\n",
+	)
+	groups := make([dynamic]string, context.temp_allocator)
+	for name in sorted_command_names(state, "") {
+		group, _, _ := strings.partition(name, ".")
+		if len(groups) == 0 || groups[len(groups) - 1] != group {
+			append(&groups, group)
+		}
+	}
+	column := 0
+	for group in groups {
+		link := fmt.tprintf("|%s|", group)
+		if column + len(link) + 2 > 76 {
+			strings.write_byte(b, '\n')
+			column = 0
+		}
+		fmt.sbprintf(b, "  %s", link)
+		column += len(link) + 2
+	}
+	strings.write_byte(b, '\n')
+}
+
+@(private = "file")
+write_help_command :: proc(state: ^Editor_State, b: ^strings.Builder, cmd: Command) {
+	write_help_title(b, cmd.name, "command")
+	fmt.sbprintf(b, "%s.\n\n", cmd.description)
+	keys := command_keys(state, cmd.name)
+	fmt.sbprintf(b, "Keys:  %s\n", keys if keys != "" else "none; bind some in keymap.json")
+	switch {
+	case cmd.script != 0:
+		strings.write_string(b, "From a plugin.\n")
+	case cmd.kind == .Edit:
+		strings.write_string(b, "Changes the buffer, so read-only buffers refuse it.\n")
+	case cmd.kind == .Select:
+		strings.write_string(b, "Moves the cursor, extending the selection.\n")
+	case cmd.kind == .Move:
+		strings.write_string(b, "Moves the cursor, dropping the selection.\n")
+	}
+	group, _, _ := strings.partition(cmd.name, ".")
+	fmt.sbprintf(b, "\nSee also |%s| and |commands|.\n", group)
+}
+
+@(private = "file")
+write_help_option :: proc(state: ^Editor_State, b: ^strings.Builder, option: ^Config_Option) {
+	write_help_title(b, option.key, "option")
+	fmt.sbprintf(b, "%s.\n\n", option.help)
+	switch option.kind {
+	case .Bool:
+		strings.write_string(b, "Type:     true or false\n")
+	case .Int:
+		fmt.sbprintf(b, "Type:     a number from %d to %d\n", option.min, option.max)
+	case .String:
+		strings.write_string(b, "Type:     a string\n")
+	case .Int_List:
+		fmt.sbprintf(b, "Type:     a list of numbers from %d to %d\n", option.min, option.max)
+	}
+	if len(option.choices) > 0 {
+		choices := strings.join(option.choices, ", ", context.temp_allocator)
+		fmt.sbprintf(b, "Choices:  %s\n", choices)
+	}
+	fmt.sbprintf(b, "Default:  %s\n", format_config_value(option.default))
+	fmt.sbprintf(b, "Now:      %s\n", format_config_value(config_value(state, option.key)))
+	strings.write_string(
+		b,
+		"\nSet it in config.toml, for the session with |config.set|, or with\n" +
+		"|config.settings|.  See also |options|.\n",
+	)
+}
+
+@(private = "file")
+write_help_keys :: proc(state: ^Editor_State, b: ^strings.Builder) {
+	write_help_title(b, "keys", "every key binding")
+	Line :: struct {
+		scope, keys, command: string,
+	}
+	lines := make([dynamic]Line, context.temp_allocator)
+	for name in sorted_command_names(state, "") {
+		for kb in editor.bindings_for_command(&state.keymap, name) {
+			scope := kb.mode if kb.mode != "" else "any mode"
+			if kb.language != "" {
+				scope = fmt.tprintf("%s, %s", scope, kb.language)
+			}
+			keys := editor.format_key_sequence(kb.sequence)
+			append(&lines, Line{scope, keys, name})
+		}
+	}
+	slice.stable_sort_by(lines[:], proc(a, b: Line) -> bool {
+		return a.scope < b.scope
+	})
+	scope := ""
+	for l in lines {
+		if l.scope != scope {
+			fmt.sbprintf(b, "\n%s\n", l.scope)
+			scope = l.scope
+		}
+		fmt.sbprintf(b, "    %-24s |%s|\n", l.keys, l.command)
+	}
+}
+
+@(private = "file")
+write_help_title :: proc(b: ^strings.Builder, topic, kind: string) {
+	gap := strings.repeat(" ", max(72 - len(topic) - len(kind), 1), context.temp_allocator)
+	fmt.sbprintf(b, "%s%s%s\n\n", topic, gap, kind)
+}
+
+@(private = "file")
+write_command_line :: proc(state: ^Editor_State, b: ^strings.Builder, name: string) {
+	cmd := state.commands[name]
+	fmt.sbprintf(b, "|%s|", name)
+	if keys := command_keys(state, name); keys != "" {
+		fmt.sbprintf(b, "  %s", keys)
+	}
+	fmt.sbprintf(b, "\n    %s\n", cmd.description)
+}
+
+// The keys that run a command, "ctrl+z, ctrl+y".  Temp allocated.
+@(private = "file")
+command_keys :: proc(state: ^Editor_State, name: string) -> string {
+	keys := make([dynamic]string, context.temp_allocator)
+	for kb in editor.bindings_for_command(&state.keymap, name) {
+		k := editor.format_key_sequence(kb.sequence)
+		if kb.mode != "" {
+			k = fmt.tprintf("%s (%s)", k, kb.mode)
+		}
+		if !slice.contains(keys[:], k) {
+			append(&keys, k)
+		}
+	}
+	return strings.join(keys[:], ", ", context.temp_allocator)
+}
+
+// The command names, sorted, of the group before the first dot; every one
+// for "".  Temp allocated.
+@(private = "file")
+sorted_command_names :: proc(state: ^Editor_State, group: string) -> []string {
+	names := make([dynamic]string, context.temp_allocator)
+	for name in state.commands {
+		if group == "" || strings.has_prefix(strings.trim_prefix(name, group), ".") {
+			append(&names, name)
+		}
+	}
+	slice.sort(names[:])
+	return names[:]
+}
@@ -0,0 +1,275 @@
+package main
+
+import "core:bytes"
+import "core:fmt"
+import "core:strings"
+import editor "editor"
+
+// Switches the active document between its text and a hex dump of the bytes
+// it would be saved as.  Unsaved edits carry over; the undo history does not,
+// since its positions only make sense in the old view.
+toggle_hex_view :: proc(state: ^Editor_State) {
+	if state.preview {
+		set_message(state, "%s is read-only", document_title(state.path))
+		return
+	}
+	gb := &state.buffer
+	disk := &state.disk
+	text := editor.get_text(gb, context.temp_allocator)
+	new_text, disk_text: string
+	new_pos := 0
+	switch disk.view {
+	case .Notebook:
+		set_message(state, "%s is a notebook; it has no hex view", document_title(state.path))
+		return
+	case .Text:
+		data, ok := buffer_to_file_data(disk, text)
+		if !ok {
+			name := editor.ENCODING_NAMES[disk.encoding]
+			set_message(state, "The buffer has characters that %s cannot represent", name)
+			return
+		}
+		saved, _ := buffer_to_file_data(disk, disk.text)
+		prefix, _ := buffer_to_file_data(disk, text[:state.cursor_pos])
+		disk.view = .Hex
+		new_text = editor.format_hex_dump(data, allocator = context.temp_allocator)
+		disk_text = editor.format_hex_dump(saved, allocator = context.temp_allocator)
+		new_pos = min(len(prefix), len(data)) // a byte index until the dump is in place
+	case .Hex:
+		data := editor.parse_hex_dump(text, context.temp_allocator)
+		cursor_byte := hex_cursor_byte(state)
+		disk.view = .Text
+		new_text = file_data_to_buffer(disk, data)
+		saved := editor.parse_hex_dump(disk.text, context.temp_allocator)
+		disk_text = file_data_to_buffer(disk, saved)
+		// Back off to the start of the character the cursor byte is in.
+		for k := cursor_byte; k >= max(cursor_byte - 3, 0); k -= 1 {
+			if s, ok := editor.decode_text(data[:k], disk.encoding, context.temp_allocator); ok {
+				new_pos = len(editor.normalize_line_endings(s, context.temp_allocator))
+				break
+			}
+		}
+	}
+
+	modified := editor.is_modified(&state.undo)
+	gb.undo = nil
+	editor.replace_bytes(gb, 0, editor.current_length(gb), transmute([]u8)new_text)
+	gb.undo = &state.undo
+	editor.clear_undo_history(&state.undo)
+	if modified {
+		editor.mark_modified(&state.undo)
+	}
+	// The disk text follows the view so that change detection still compares
+	// like with like.
+	delete(disk.text)
+	disk.text = strings.clone(disk_text)
+
+	if disk.view == .Hex {
+		set_language(state, editor.HEX_DUMP_LANGUAGE_ID)
+		new_pos = hex_byte_pos(gb, new_pos, .Hex)
+	} else {
+		set_language(state, editor.detect_language(state.path, new_text).id)
+	}
+	state.selection_anchor = -1
+	state.cursor_pos = min(new_pos, editor.current_length(gb))
+	sync_cursor(state)
+	set_preferred_col(state)
+}
+
+// Typing in hex view overwrites the byte under the cursor: a hex digit in the
+// hex column sets one half of it, a printable character in the ASCII column
+// the whole byte.  Typing past the last byte appends one.
+hex_type_rune :: proc(state: ^Editor_State, r: rune) {
+	if state.preview {
+		set_message(state, "%s is read-only", document_title(state.path))
+		return
+	}
+	gb := &state.buffer
+	line, col := editor.logical_pos_to_line_col(gb, state.cursor_pos)
+	column, index, nibble := editor.hex_locate(col)
+	total := hex_byte_count(gb)
+	b := min(line * editor.HEX_BYTES_PER_LINE + index, total)
+	if state.cursor_pos == editor.current_length(gb) {
+		b = total // at the very end, typing appends
+	}
+	old := hex_read_byte(gb, b) if b < total else 0
+
+	value: u8
+	switch column {
+	case .Offset:
+		set_message(state, "Type in the hex or the ASCII column")
+		return
+	case .Hex:
+		digit, ok := editor.hex_digit_value(u8(r))
+		if r >= 0x80 || !ok {
+			set_message(state, "Not a hex digit: %q", r)
+			return
+		}
+		if nibble == 0 {
+			value = u8(digit) << 4 | old & 0x0f
+		} else {
+			value = old & 0xf0 | u8(digit)
+		}
+	case .Ascii:
+		if r < 0x20 || r >= 0x7f {
+			set_message(state, "Only printable ASCII can be typed in the ASCII column")
+			return
+		}
+		value = u8(r)
+	}
+
+	if b == total {
+		text := editor.get_text(gb, context.temp_allocator)
+		data := editor.parse_hex_dump(text, context.temp_allocator)
+		new_data := make([dynamic]u8, 0, len(data) + 1, context.temp_allocator)
+		append(&new_data, ..data)
+		append(&new_data, value)
+		rewrite_hex_tail(state, new_data[:], b)
+	} else {
+		hex_write_byte(gb, b, value)
+	}
+
+	if column == .Hex && nibble == 0 {
+		state.cursor_pos = hex_byte_pos(gb, b, .Hex) + 1
+	} else {
+		state.cursor_pos = hex_byte_pos(gb, b + 1, column)
+	}
+	sync_cursor(state)
+	set_preferred_col(state)
+}
+
+// Deletes the byte before the cursor's byte.
+hex_delete_backward :: proc(state: ^Editor_State) {
+	b := hex_cursor_byte(state)
+	if b > 0 {
+		hex_delete_byte(state, b - 1)
+	}
+}
+
+// Deletes the byte under the cursor.
+hex_delete_forward :: proc(state: ^Editor_State) {
+	hex_delete_byte(state, hex_cursor_byte(state))
+}
+
+// Prompts for a byte pattern such as "de ad be ef" and moves to its next
+// occurrence after the cursor, wrapping around at the end.
+hex_search :: proc(state: ^Editor_State) {
+	if state.disk.view != .Hex {
+		set_message(state, "Hex search needs the hex view")
+		return
+	}
+	open_prompt(state, "Hex pattern:", proc(state: ^Editor_State, input: string) {
+		pattern, ok := editor.parse_hex_pattern(input, context.temp_allocator)
+		if !ok {
+			set_message(state, "Not a hex byte pattern: %q", input)
+			return
+		}
+		text := editor.get_text(&state.buffer, context.temp_allocator)
+		data := editor.parse_hex_dump(text, context.temp_allocator)
+		from := min(hex_cursor_byte(state) + 1, len(data))
+		found := bytes.index(data[from:], pattern)
+		if found >= 0 {
+			found += from
+		} else {
+			found = bytes.index(data[:from], pattern)
+		}
+		if found < 0 {
+			set_message(state, "Pattern not found")
+			return
+		}
+		record_jump(state)
+		state.selection_anchor = -1
+		state.cursor_pos = hex_byte_pos(&state.buffer, found, .Hex)
+		sync_cursor(state)
+		set_preferred_col(state)
+		set_message(state, "Found at offset 0x%x", found)
+	})
+}
+
+// Index of the byte under the cursor in hex view.
+hex_cursor_byte :: proc(state: ^Editor_State) -> int {
+	line, col := editor.logical_pos_to_line_col(&state.buffer, state.cursor_pos)
+	_, index, _ := editor.hex_locate(col)
+	return min(line * editor.HEX_BYTES_PER_LINE + index, hex_byte_count(&state.buffer))
+}
+
+// Buffer position of byte b in the hex or ASCII column.  The byte just past
+// the end is where the next one would go.
+@(private = "file")
+hex_byte_pos :: proc(gb: ^editor.Gap_Buffer, b: int, column: editor.Hex_Column) -> int {
+	line := b / editor.HEX_BYTES_PER_LINE
+	if line >= editor.get_line_count(gb) {
+		return editor.current_length(gb)
+	}
+	col := editor.hex_byte_col(b % editor.HEX_BYTES_PER_LINE, column)
+	return editor.line_col_to_logical_pos(gb, line, col)
+}
+
+// Number of bytes in the dump: full rows plus those of the last line.
+@(private = "file")
+hex_byte_count :: proc(gb: ^editor.Gap_Buffer) -> int {
+	lines := editor.get_line_count(gb)
+	_, n := editor.parse_hex_row(editor.get_line(gb, lines - 1, context.temp_allocator))
+	return (lines - 1) * editor.HEX_BYTES_PER_LINE + n
+}
+
+@(private = "file")
+hex_read_byte :: proc(gb: ^editor.Gap_Buffer, b: int) -> u8 {
+	pos := hex_byte_pos(gb, b, .Hex)
+	hi, _ := editor.hex_digit_value(editor.char_at(gb, pos))
+	lo, _ := editor.hex_digit_value(editor.char_at(gb, pos + 1))
+	return u8(hi << 4 | lo)
+}
+
+// Overwrites byte b in both the hex and the ASCII column.
+@(private = "file")
+hex_write_byte :: proc(gb: ^editor.Gap_Buffer, b: int, value: u8) {
+	digits := fmt.tprintf("%02x", value)
+	editor.replace_bytes(gb, hex_byte_pos(gb, b, .Hex), 2, transmute([]u8)digits)
+	c := [1]u8{value if value >= 0x20 && value < 0x7f else '.'}
+	editor.replace_bytes(gb, hex_byte_pos(gb, b, .Ascii), 1, c[:])
+}
+
+@(private = "file")
+hex_delete_byte :: proc(state: ^Editor_State, b: int) {
+	if state.preview {
+		set_message(state, "%s is read-only", document_title(state.path))
+		return
+	}
+	gb := &state.buffer
+	text := editor.get_text(gb, context.temp_allocator)
+	data := editor.parse_hex_dump(text, context.temp_allocator)
+	if b >= len(data) {
+		return
+	}
+	new_data := make([dynamic]u8, 0, len(data), context.temp_allocator)
+	append(&new_data, ..data[:b])
+	append(&new_data, ..data[b + 1:])
+	rewrite_hex_tail(state, new_data[:], b)
+	state.cursor_pos = hex_byte_pos(gb, min(b, len(new_data)), .Hex)
+	sync_cursor(state)
+	set_preferred_col(state)
+}
+
+// Re-formats the dump from the row holding byte b on, after bytes were
+// inserted or deleted there.
+@(private = "file")
+rewrite_hex_tail :: proc(state: ^Editor_State, data: []u8, b: int) {
+	gb := &state.buffer
+	row := b / editor.HEX_BYTES_PER_LINE
+	lines := editor.get_line_count(gb)
+	first := min(row * editor.HEX_BYTES_PER_LINE, len(data))
+	text := editor.format_hex_dump(data[first:], first, context.temp_allocator)
+
+	start := editor.current_length(gb)
+	if row < lines {
+		start = editor.line_col_to_logical_pos(gb, row, 0)
+	}
+	switch {
+	case row >= lines && text != "":
+		text = strings.concatenate({"\n", text}, context.temp_allocator)
+	case row < lines && row > 0 && text == "":
+		start -= 1 // the row emptied; drop the line break before it
+	}
+	editor.replace_bytes(gb, start, editor.current_length(gb) - start, transmute([]u8)text)
+}
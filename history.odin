@@ -0,0 +1,209 @@
+package main
+
+import "core:fmt"
+import "core:os"
+import "core:path/filepath"
+import "core:slice"
+import "core:strconv"
+import "core:strings"
+import "core:time"
+import editor "editor"
+
+// Every save of a file is kept as a snapshot under the workspace's state
+// directory, history/<path with '%' for separators>/<unix milliseconds>, so
+// older versions can be compared and brought back without git.  Snapshots
+// hold the buffer's text as saved; a save that changed nothing adds none.
+File_History_State :: struct {
+	active:    bool, // the panel lists the snapshots of a file
+	restoring: bool, // and enter restores one instead of diffing
+	snapshots: [dynamic]string, // listed, newest first; items' data index them
+}
+
+destroy_file_history :: proc(state: ^Editor_State) {
+	clear_history_snapshots(state)
+	delete(state.history.snapshots)
+}
+
+// After the active buffer was saved as text: keeps a snapshot of it, and as
+// many older ones as history.keep says.
+record_file_history :: proc(state: ^Editor_State, text: string) {
+	if !config_bool(state, "history.enabled") || state.disk.view != .Text {
+		return
+	}
+	dir, ok := history_dir(state)
+	if !ok {
+		return
+	}
+	snapshots := list_snapshots(dir)
+	if n := len(snapshots); n > 0 {
+		last, err := os.read_entire_file_from_path(snapshots[n - 1].path, context.temp_allocator)
+		if err == nil && string(last) == text {
+			return
+		}
+	}
+	if err := os.make_directory_all(dir); err != nil && !os.exists(dir) {
+		set_message(state, "Cannot keep the history of %s: %v", document_title(state.path), err)
+		return
+	}
+	stamp := time.to_unix_nanoseconds(time.now()) / 1_000_000
+	path := filepath.join({dir, fmt.tprintf("%d", stamp)}, context.temp_allocator)
+	if err := write_file_atomic(path, transmute([]u8)text); err != nil {
+		set_message(state, "Cannot keep the history of %s: %v", document_title(state.path), err)
+		return
+	}
+	keep := config_int(state, "history.keep")
+	for i := 0; i < len(snapshots) + 1 - keep; i += 1 {
+		os.remove(snapshots[i].path)
+	}
+}
+
+// history.list: lists the saved versions of the file; enter shows one
+// against the buffer.
+show_file_history :: proc(state: ^Editor_State) {
+	list_file_history(state, false)
+}
+
+// history.restore: lists the saved versions of the file; enter puts one in
+// the buffer, as an edit that can be undone and is not saved yet.
+restore_file_history :: proc(state: ^Editor_State) {
+	list_file_history(state, true)
+}
+
+// Enter in the history list.
+open_history_entry :: proc(state: ^Editor_State) {
+	h := &state.history
+	item := editor.panel_selected_item(state.panel_data)
+	if item == nil || item.data >= len(h.snapshots) {
+		return
+	}
+	data, err := os.read_entire_file_from_path(h.snapshots[item.data], context.temp_allocator)
+	if err != nil {
+		set_message(state, "Cannot read the snapshot: %v", err)
+		return
+	}
+	old := string(data)
+	stamp, _ := strconv.parse_i64_of_base(filepath.base(h.snapshots[item.data]), 10)
+	when_saved := format_age(stamp / 1000)
+	title := document_title(state.path)
+	if !h.restoring {
+		open_diff_view(
+			state,
+			fmt.tprintf("Saved %s: %s", when_saved, title),
+			fmt.tprintf("Buffer: %s", title),
+			old,
+			editor.get_text(&state.buffer, context.temp_allocator),
+		)
+		return
+	}
+	if is_read_only(state) {
+		set_message(state, "%s is read-only", title)
+		return
+	}
+	h.active = false
+	hide_panel(state)
+	line, col := state.cursor_data.line, state.cursor_data.col
+	editor.begin_undo_group(&state.undo, state.cursor_pos)
+	editor.replace_bytes(&state.buffer, 0, editor.current_length(&state.buffer), data)
+	state.selection_anchor = -1
+	line = min(line, editor.get_line_count(&state.buffer) - 1)
+	state.cursor_pos = editor.line_col_to_logical_pos(&state.buffer, line, col)
+	editor.end_undo_group(&state.undo, state.cursor_pos)
+	sync_cursor(state)
+	set_preferred_col(state)
+	set_message(state, "Restored %s as saved %s; save to keep it", title, when_saved)
+}
+
+@(private = "file")
+list_file_history :: proc(state: ^Editor_State, restoring: bool) {
+	if state.path == "" {
+		set_message(state, "The buffer has never been saved")
+		return
+	}
+	dir, _ := history_dir(state)
+	snapshots := list_snapshots(dir)
+	if len(snapshots) == 0 {
+		set_message(state, "No saved versions of %s yet", document_title(state.path))
+		return
+	}
+	clear_replace(state)
+	stop_project_search(state)
+	release_panel(state)
+	h := &state.history
+	h.active = true
+	h.restoring = restoring
+	clear_history_snapshots(state)
+	panel := state.panel_data
+	editor.panel_clear(panel)
+	action := "restores" if restoring else "diffs against the buffer"
+	editor.panel_set_title(panel, fmt.tprintf("Saved versions; enter %s", action))
+	#reverse for s in snapshots {
+		size := 0
+		if fi, err := os.stat(s.path, context.temp_allocator); err == nil {
+			size = int(fi.size)
+		}
+		saved := time.unix(s.stamp / 1000, 0)
+		y, mon, d := time.date(saved)
+		hh, mm, ss := time.clock(saved)
+		text := fmt.tprintf(
+			"%s  %04d-%02d-%02d %02d:%02d:%02d UTC  %s",
+			format_age(s.stamp / 1000),
+			y,
+			int(mon),
+			d,
+			hh,
+			mm,
+			ss,
+			format_size(size),
+		)
+		editor.panel_add_item(panel, {text = text, line = -1, data = len(h.snapshots)})
+		append(&h.snapshots, strings.clone(s.path))
+	}
+	show_panel(state)
+}
+
+@(private = "file")
+Snapshot :: struct {
+	path:  string,
+	stamp: i64, // unix milliseconds
+}
+
+// The snapshots in dir, oldest first.  Temp allocated.
+@(private = "file")
+list_snapshots :: proc(dir: string) -> []Snapshot {
+	snapshots := make([dynamic]Snapshot, context.temp_allocator)
+	entries, err := os.read_all_directory_by_path(dir, context.temp_allocator)
+	if err != nil {
+		return nil
+	}
+	for fi in entries {
+		if stamp, ok := strconv.parse_i64_of_base(fi.name, 10); ok && fi.type == .Regular {
+			append(&snapshots, Snapshot{fi.fullpath, stamp})
+		}
+	}
+	slice.sort_by(snapshots[:], proc(a, b: Snapshot) -> bool {
+		return a.stamp < b.stamp
+	})
+	return snapshots[:]
+}
+
+// Where the snapshots of the active file go.  Temp allocated.
+@(private = "file")
+history_dir :: proc(state: ^Editor_State) -> (string, bool) {
+	full := workspace_path(state, state.path)
+	rel, err := filepath.rel(state.workspace_root, full, context.temp_allocator)
+	if err != .None || strings.has_prefix(rel, "..") {
+		rel = full
+	}
+	name, _ := strings.replace_all(rel, "/", "%", context.temp_allocator)
+	name, _ = strings.replace_all(name, "\\", "%", context.temp_allocator)
+	name, _ = strings.replace_all(name, ":", "%", context.temp_allocator)
+	return workspace_state_path(state, filepath.join({"history", name}, context.temp_allocator))
+}
+
+@(private = "file")
+clear_history_snapshots :: proc(state: ^Editor_State) {
+	for path in state.history.snapshots {
+		delete(path)
+	}
+	clear(&state.history.snapshots)
+}
@@ -0,0 +1,178 @@
+package main
+
+import "core:fmt"
+import "core:os"
+import "core:strings"
+import editor "editor"
+
+// Each step of image.zoom_in and image.zoom_out.
+IMAGE_ZOOM_STEP :: 1.25
+
+// Images open as a picture over the hex dump of their bytes: PNG, JPEG, BMP,
+// QOI, TGA and Netpbm files are decoded from disk when their dump becomes the
+// active document, the image.zoom_* commands scale them, and
+// view.toggle_image shows the bytes instead.
+Image_State :: struct {
+	path:   string, // the document last looked at, "" for none
+	ok:     bool, // and whether it decoded into the image layer's texture
+	info:   editor.Image_Info,
+	hidden: bool, // view.toggle_image shows the hex dump
+}
+
+// Decodes the active document when it is a hex dump not looked at yet, and
+// shows the image layer while it is an image.  Called every frame.
+update_image_view :: proc(state: ^Editor_State) {
+	im := &state.image
+	d := state.image_data
+	is_dump := state.disk.view == .Hex && !state.preview && state.path != ""
+	if is_dump && state.path != im.path {
+		load_image_view(state)
+	}
+	visible := is_dump && im.ok && state.path == im.path && !im.hidden
+	if visible != d.visible {
+		d.visible = visible
+		request_redraw(state)
+	}
+	if !visible {
+		return
+	}
+	scale := editor.image_scale(d, image_area(state))
+	strings.builder_reset(&d.caption)
+	fmt.sbprintf(&d.caption, "%s  %d%%", image_summary(state), int(scale * 100 + 0.5))
+}
+
+destroy_image_view :: proc(state: ^Editor_State) {
+	editor.release_image_texture(&state.render_ctx, state.image_data)
+	delete(state.image.path)
+	state.image.path = ""
+}
+
+// image.zoom_in: shows the image larger.
+zoom_image_in :: proc(state: ^Editor_State) {
+	zoom_image(state, IMAGE_ZOOM_STEP)
+}
+
+// image.zoom_out: shows the image smaller.
+zoom_image_out :: proc(state: ^Editor_State) {
+	zoom_image(state, 1 / IMAGE_ZOOM_STEP)
+}
+
+// image.zoom_fit: shrinks the image to fit the window, as it opens.
+zoom_image_to_fit :: proc(state: ^Editor_State) {
+	if !is_image_shown(state) {
+		return
+	}
+	state.image_data.zoom = 0
+	jump_scroll(state, 0)
+	state.layer_ctx.scroll_x = 0
+	request_redraw(state)
+}
+
+// image.zoom_actual: one screen pixel per image pixel.
+zoom_image_to_actual :: proc(state: ^Editor_State) {
+	if !is_image_shown(state) {
+		return
+	}
+	state.image_data.zoom = 1
+	request_redraw(state)
+}
+
+// view.toggle_image: switches an image between the picture and its bytes.
+toggle_image_view :: proc(state: ^Editor_State) {
+	im := &state.image
+	if state.disk.view != .Hex || state.path != im.path || !im.ok {
+		set_message(state, "%s is not an image", document_title(state.path))
+		return
+	}
+	im.hidden = !im.hidden
+	request_redraw(state)
+}
+
+// image.info: reports the image's format, size and channels.
+show_image_info :: proc(state: ^Editor_State) {
+	im := &state.image
+	if state.disk.view != .Hex || state.path != im.path || !im.ok {
+		set_message(state, "%s is not an image", document_title(state.path))
+		return
+	}
+	bytes := "?"
+	if fi, err := os.stat(state.path, context.temp_allocator); err == nil {
+		bytes = fmt.tprintf("%d", fi.size)
+	}
+	set_message(state, "%s: %s, %s bytes", document_title(state.path), image_summary(state), bytes)
+}
+
+@(private = "file")
+load_image_view :: proc(state: ^Editor_State) {
+	im := &state.image
+	delete(im.path)
+	im.path = strings.clone(state.path)
+	im.ok = false
+	im.hidden = false
+	im.info = {}
+	state.image_data.zoom = 0
+
+	data, err := os.read_entire_file_from_path(state.path, context.allocator)
+	if err != nil {
+		return
+	}
+	defer delete(data)
+	pixels, info, ok := editor.decode_image(data)
+	im.info = info
+	if !ok {
+		if info.format != .Unknown {
+			set_message(state, "This %v image cannot be shown; these are its bytes", info.format)
+		}
+		return
+	}
+	defer delete(pixels)
+	d := state.image_data
+	w, h := info.width, info.height
+	if !editor.set_image_texture(&state.render_ctx, &state.batch, d, pixels, w, h) {
+		set_message(state, "No room on the GPU for a %d by %d image", w, h)
+		return
+	}
+	im.ok = true
+	set_message(state, "%s", image_summary(state))
+}
+
+// Where the image layer fits the image: the window less the status line and
+// the caption.
+@(private = "file")
+image_area :: proc(state: ^Editor_State) -> [2]f32 {
+	v := state.layer_ctx.viewport
+	return {v[0], v[1] - 2 * state.line_height}
+}
+
+// "PNG, 640 x 480, RGBA" and the depth when it is not 8 bits.
+@(private = "file")
+image_summary :: proc(state: ^Editor_State) -> string {
+	CHANNELS := [?]string{"gray", "gray and alpha", "RGB", "RGBA"}
+	info := state.image.info
+	channels := CHANNELS[clamp(info.channels, 1, 4) - 1]
+	summary := fmt.tprintf("%v, %d x %d, %s", info.format, info.width, info.height, channels)
+	if info.depth != 8 {
+		summary = fmt.tprintf("%s, %d bits a channel", summary, info.depth)
+	}
+	return summary
+}
+
+@(private = "file")
+is_image_shown :: proc(state: ^Editor_State) -> bool {
+	if !state.image_data.visible {
+		set_message(state, "No image is shown")
+		return false
+	}
+	return true
+}
+
+@(private = "file")
+zoom_image :: proc(state: ^Editor_State, factor: f32) {
+	if !is_image_shown(state) {
+		return
+	}
+	d := state.image_data
+	scale := editor.image_scale(d, image_area(state)) * factor
+	d.zoom = clamp(scale, editor.IMAGE_ZOOM_MIN, editor.IMAGE_ZOOM_MAX)
+	request_redraw(state)
+}
@@ -0,0 +1,54 @@
+package main
+
+// edit.organize_imports sorts the buffer's imports and drops the unused
+// ones: imports.command, or the language's usual tool, filters the buffer
+// the way a formatter does.  With imports.on_save it runs before every save,
+// ahead of format.on_save.
+organize_imports :: proc(state: ^Editor_State) {
+	if is_read_only(state) {
+		set_message(state, "%s is read-only", document_title(state.path))
+		return
+	}
+	command, ok := import_organizer_command(state)
+	if !ok {
+		set_message(state, "Nothing organizes %s imports; set imports.command", state.language)
+		return
+	}
+	start_filter(state, command, .Imports)
+}
+
+// format_and_write's first step.  True when the save waits for a filter.
+organize_imports_before_save :: proc(state: ^Editor_State) -> bool {
+	command, ok := import_organizer_command(state)
+	return ok && start_filter(state, command, .Imports_And_Save)
+}
+
+// The usual tool for a language, "" when it has none.  Rust's imports are
+// rustfmt's, see default_formatter.
+default_import_organizer :: proc(language_id: string) -> string {
+	switch language_id {
+	case "go":
+		return "goimports"
+	case "python":
+		return "isort --quiet -"
+	}
+	return ""
+}
+
+// imports.command or the language's usual tool, with {file} filled in as
+// for format.command.  Temp allocated.
+@(private = "file")
+import_organizer_command :: proc(state: ^Editor_State) -> (string, bool) {
+	command := config_value(state, "imports.command").(string) or_else ""
+	if command == "" {
+		command = default_import_organizer(state.language)
+	}
+	if command == "" {
+		return "", false
+	}
+	file := bookmark_path(state)
+	if file == "" {
+		file = "untitled"
+	}
+	return fill_placeholder(command, "{file}", file), true
+}
@@ -0,0 +1,141 @@
+package main
+
+import "core:strings"
+import "core:time"
+import editor "editor"
+
+// Ghost text: a suggestion for what comes after the cursor, e.g. from an AI
+// model, shown dim after it (editor.Ghost_Text_Layer_Data).  Plugins provide
+// suggestions with rune.inline_completion; they are asked once typing pauses
+// with the cursor at the end of a line, by completion.inline.  Typing what
+// the suggestion says keeps the rest of it; tab accepts all of it,
+// inline.accept_word and inline.accept_line part, and escape, moving away or
+// any other edit drops it.
+Inline_Completion_State :: struct {
+	text:   string, // what is left to accept, "" when nothing is shown
+	doc_id: int,
+	pos:    int, // where it goes
+	length: int, // of the buffer then
+	asked:  [3]int, // doc_id, undo version and cursor of the last request
+}
+
+destroy_inline_completion :: proc(state: ^Editor_State) {
+	delete(state.suggestion.text)
+}
+
+// Asks for a suggestion once typing pauses, and keeps the one shown in step
+// with the buffer.  Called every frame.
+update_inline_completion :: proc(state: ^Editor_State) {
+	s := &state.suggestion
+	if s.text != "" {
+		follow_suggestion(state)
+		return
+	}
+	if !config_bool(state, "completion.inline") || len(state.plugins.suggesters) == 0 {
+		return
+	}
+	if state.mode != "editor" || state.completion.active || has_selection(state) {
+		return
+	}
+	if is_read_only(state) || time.tick_since(state.frames.last_input) < INPUT_QUIET {
+		return
+	}
+	key := [3]int{state.doc_id, state.undo.version, state.cursor_pos}
+	if key == s.asked {
+		return
+	}
+	s.asked = key
+	line := editor.get_line(&state.buffer, state.cursor_data.line, context.temp_allocator)
+	col := min(state.cursor_data.col, len(line))
+	if strings.trim_space(line[col:]) != "" {
+		return // the suggestion would cover the rest of the line
+	}
+	text := plugin_inline_completion(state, state.cursor_data.line, col)
+	if text != "" {
+		set_suggestion(state, editor.normalize_line_endings(text, context.temp_allocator))
+	}
+}
+
+// Tab: accepts the whole suggestion when one is shown.
+accept_inline_completion :: proc(state: ^Editor_State) -> bool {
+	if state.suggestion.text == "" {
+		return false
+	}
+	accept_suggestion(state, .All)
+	return true
+}
+
+// inline.accept_word: types the suggestion's next word.
+accept_inline_word :: proc(state: ^Editor_State) {
+	accept_suggestion(state, .Word)
+}
+
+// inline.accept_line: types the rest of the suggestion's line.
+accept_inline_line :: proc(state: ^Editor_State) {
+	accept_suggestion(state, .Line)
+}
+
+// inline.dismiss: drops the suggestion.  It is not asked for again until the
+// cursor or the text changes.
+dismiss_inline_completion :: proc(state: ^Editor_State) {
+	if state.suggestion.text != "" {
+		set_suggestion(state, "")
+	}
+}
+
+@(private = "file")
+accept_suggestion :: proc(state: ^Editor_State, unit: editor.Ghost_Accept) {
+	s := &state.suggestion
+	if s.text == "" || !follow_suggestion(state) {
+		set_message(state, "No suggestion to accept")
+		return
+	}
+	n := editor.ghost_accept_length(s.text, unit)
+	part := strings.clone(s.text[:n], context.temp_allocator)
+	editor.begin_undo_group(&state.undo, state.cursor_pos)
+	insert_bytes_at_cursor(state, transmute([]u8)part)
+	editor.end_undo_group(&state.undo, state.cursor_pos)
+	follow_suggestion(state)
+}
+
+// Drops what was typed from the start of the suggestion, or the whole
+// suggestion once the cursor or the text went elsewhere.  False when it is
+// gone.
+@(private = "file")
+follow_suggestion :: proc(state: ^Editor_State) -> bool {
+	s := &state.suggestion
+	length := editor.current_length(&state.buffer)
+	if s.doc_id != state.doc_id || state.mode != "editor" || has_selection(state) {
+		set_suggestion(state, "")
+		return false
+	}
+	typed := state.cursor_pos - s.pos
+	if typed == 0 && length == s.length {
+		return true
+	}
+	if typed <= 0 || typed > len(s.text) || length != s.length + typed {
+		set_suggestion(state, "")
+		return false
+	}
+	typed_text := editor.get_text_segment(&state.buffer, s.pos, typed, context.temp_allocator)
+	if typed_text != s.text[:typed] {
+		set_suggestion(state, "")
+		return false
+	}
+	set_suggestion(state, strings.clone(s.text[typed:], context.temp_allocator))
+	return s.text != ""
+}
+
+// Shows text at the cursor, or nothing for "".
+@(private = "file")
+set_suggestion :: proc(state: ^Editor_State, text: string) {
+	s := &state.suggestion
+	delete(s.text)
+	s.text = strings.clone(text) if text != "" else ""
+	s.doc_id = state.doc_id
+	s.pos = state.cursor_pos
+	s.length = editor.current_length(&state.buffer)
+	s.asked = {state.doc_id, state.undo.version, state.cursor_pos}
+	editor.set_ghost_text(state.ghost_text, text)
+	request_redraw(state)
+}
@@ -1,6 +1,7 @@
 package main
 
-import "base:runtime"
+import "core:strings"
+import "core:time"
 import "core:unicode/utf8"
 import editor "editor"
 import "vendor:glfw"
@@ -22,6 +23,39 @@ sync_cursor :: proc(state: ^Editor_State) {
 		col,
 		state.layer_ctx.tab_size,
 	)
+	sync_selection(state)
+	scroll_to_cursor(state)
+	update_status_line(state)
+}
+
+// Height in pixels of the part of the window that shows buffer text.
+text_area_height :: proc(state: ^Editor_State) -> f32 {
+	status: f32 = 0 if state.zen.active else state.line_height
+	reserved := status + editor.panel_height(state.panel_data)
+	return state.layer_ctx.viewport[1] - state.cursor_data.padding[1] - reserved
+}
+
+// Adjusts the vertical scroll so the cursor line is fully visible, with
+// editor.scroll_margin lines around it where the buffer has them.
+scroll_to_cursor :: proc(state: ^Editor_State) {
+	lh := state.line_height
+	visible := text_area_height(state)
+	rows := int(visible / lh)
+	margin := f32(clamp(config_int(state, "editor.scroll_margin"), 0, max((rows - 1) / 2, 0))) * lh
+	top := f32(state.cursor_data.line) * lh
+	if top - margin < state.scroll_target {
+		scroll_to(state, top - margin)
+	} else if top + lh + margin > state.scroll_target + visible {
+		scroll_to(state, top + lh + margin - visible)
+	}
+}
+
+// Moves the cursor to a 0 based line and byte column, dropping the selection.
+goto_line_col :: proc(state: ^Editor_State, line, col: int) {
+	state.selection_anchor = -1
+	state.cursor_pos = editor.line_col_to_logical_pos(&state.buffer, line, col)
+	sync_cursor(state)
+	set_preferred_col(state)
 }
 
 // Call after any horizontal movement or edit to anchor preferred_col to the
@@ -31,13 +65,144 @@ set_preferred_col :: proc(state: ^Editor_State) {
 	state.preferred_col = state.cursor_data.visual_col
 }
 
+// ---------------------------------------------------------------------------
+// Selection
+// ---------------------------------------------------------------------------
+
+has_selection :: proc(state: ^Editor_State) -> bool {
+	return state.selection_anchor >= 0 && state.selection_anchor != state.cursor_pos
+}
+
+// Returns the selected byte range as [start, end).
+selection_range :: proc(state: ^Editor_State) -> (start, end: int) {
+	return min(state.selection_anchor, state.cursor_pos), max(state.selection_anchor, state.cursor_pos)
+}
+
+// Anchors a selection at the cursor unless one is already active.
+begin_selection :: proc(state: ^Editor_State) {
+	if state.selection_anchor < 0 {
+		state.selection_anchor = state.cursor_pos
+	}
+}
+
+clear_selection :: proc(state: ^Editor_State) {
+	state.selection_anchor = -1
+	sync_selection(state)
+}
+
+// Mirrors the selection into the selection layer in visual columns.
+sync_selection :: proc(state: ^Editor_State) {
+	if !has_selection(state) {
+		state.selection_data.selections = nil
+		return
+	}
+	start, end := selection_range(state)
+	sl, sc := editor.logical_pos_to_line_col(&state.buffer, start)
+	el, ec := editor.logical_pos_to_line_col(&state.buffer, end)
+	tab := state.layer_ctx.tab_size
+	state.selection_buf[0] = editor.Selection {
+		start_line = sl,
+		start_col  = editor.get_visual_col(&state.buffer, sl, sc, tab),
+		end_line   = el,
+		end_col    = editor.get_visual_col(&state.buffer, el, ec, tab),
+	}
+	state.selection_data.selections = state.selection_buf[:]
+}
+
+// select.expand: grows the selection to the next enclosing word, string,
+// bracket pair, block or function, see editor.expand_range.  Ranges from
+// rune.selection_range providers are candidates too.
+expand_selection :: proc(state: ^Editor_State) {
+	start, end := state.cursor_pos, state.cursor_pos
+	if has_selection(state) {
+		start, end = selection_range(state)
+	}
+	if !following_expansion(state) {
+		clear(&state.expansions)
+		append(&state.expansions, [2]int{start, end})
+	}
+	text := editor.get_text(&state.buffer, context.temp_allocator)
+	line, col := editor.logical_pos_to_line_col(&state.buffer, start)
+	extra := plugin_selection_ranges(state, text, line, col)
+	new_start, new_end, ok := editor.expand_range(text, start, end, extra)
+	if !ok {
+		return
+	}
+	append(&state.expansions, [2]int{new_start, new_end})
+	select_range(state, new_start, new_end)
+}
+
+// select.shrink: goes back to the selection before the last select.expand.
+shrink_selection :: proc(state: ^Editor_State) {
+	if !following_expansion(state) || len(state.expansions) < 2 {
+		return
+	}
+	pop(&state.expansions)
+	r := state.expansions[len(state.expansions) - 1]
+	select_range(state, r[0], r[1])
+}
+
+// Whether the selection is still the one select.expand made last.
+@(private = "file")
+following_expansion :: proc(state: ^Editor_State) -> bool {
+	if len(state.expansions) == 0 || !has_selection(state) {
+		return false
+	}
+	start, end := selection_range(state)
+	return state.expansions[len(state.expansions) - 1] == [2]int{start, end}
+}
+
+@(private = "file")
+select_range :: proc(state: ^Editor_State, start, end: int) {
+	state.selection_anchor = start if start < end else -1
+	state.cursor_pos = end
+	sync_cursor(state)
+	set_preferred_col(state)
+}
+
+// Deletes the selected text and leaves the cursor where it started.  Returns
+// false when nothing was selected.
+delete_selection :: proc(state: ^Editor_State) -> bool {
+	if !has_selection(state) {
+		state.selection_anchor = -1
+		return false
+	}
+	start, end := selection_range(state)
+	editor.delete_bytes_range(&state.buffer, start, end - start)
+	state.cursor_pos = start
+	state.selection_anchor = -1
+	sync_cursor(state)
+	set_preferred_col(state)
+	return true
+}
+
+// Returns the lines touched by the cursor or selection.  A selection ending at
+// column 0 does not include that last line.  Allocated with the temp allocator.
+selected_line_ranges :: proc(state: ^Editor_State) -> []editor.Line_Range {
+	ranges := make([dynamic]editor.Line_Range, context.temp_allocator)
+	if has_selection(state) {
+		start, end := selection_range(state)
+		first, _ := editor.logical_pos_to_line_col(&state.buffer, start)
+		last, end_col := editor.logical_pos_to_line_col(&state.buffer, end)
+		if end_col == 0 && last > first {
+			last -= 1
+		}
+		append(&ranges, editor.Line_Range{first, last})
+	} else {
+		line, _ := editor.logical_pos_to_line_col(&state.buffer, state.cursor_pos)
+		append(&ranges, editor.Line_Range{line, line})
+	}
+	return ranges[:]
+}
+
 // ---------------------------------------------------------------------------
 // Editing operations
 // ---------------------------------------------------------------------------
 
-// Insert raw bytes at the cursor and advance it.
+// Insert raw bytes at the cursor and advance it, replacing any selection.
 insert_bytes_at_cursor :: proc(state: ^Editor_State, data: []u8) {
 	if len(data) == 0 {return}
+	delete_selection(state)
 	editor.move_gap(&state.buffer, state.cursor_pos)
 	editor.insert_bytes(&state.buffer, data)
 	state.cursor_pos += len(data)
@@ -45,80 +210,251 @@ insert_bytes_at_cursor :: proc(state: ^Editor_State, data: []u8) {
 	set_preferred_col(state)
 }
 
-// Insert a single Unicode codepoint at the cursor.
+// Insert a single Unicode codepoint at the cursor.  Typing a closing token
+// such as '}' on an otherwise blank line re-indents that line.
 insert_rune_at_cursor :: proc(state: ^Editor_State, r: rune) {
 	buf, n := utf8.encode_rune(r)
 	insert_bytes_at_cursor(state, buf[:n])
+
+	lang := editor.find_language(state.language)
+	if lang == nil || n != 1 {return}
+	for tok in lang.indent.dedent_on {
+		if len(tok) == 1 && tok[0] == buf[0] {
+			line, _ := editor.logical_pos_to_line_col(&state.buffer, state.cursor_pos)
+			text := editor.get_line(&state.buffer, line, context.temp_allocator)
+			if strings.trim_space(text) == tok {
+				positions := [1]int{state.cursor_pos}
+				editor.reindent_lines(&state.buffer, lang, line, line, indent_style(state), positions[:])
+				apply_tracked_edit(state, positions[:])
+			}
+			return
+		}
+	}
+}
+
+// Indentation settings for the current buffer.
+indent_style :: proc(state: ^Editor_State) -> editor.Indent_Style {
+	style := editor.Indent_Style {
+		width = state.layer_ctx.tab_size,
+	}
+	if use_spaces, ok := config_value(state, "editor.insert_spaces").(bool); ok {
+		style.use_spaces = use_spaces
+	} else if lang := editor.find_language(state.language); lang != nil {
+		style.use_spaces = lang.indent.use_spaces
+	}
+	return style
+}
+
+// Breaks the line at the cursor and indents the new line for the language.
+// Pressing Enter between a bracket pair opens an indented empty line between
+// them.
+insert_newline :: proc(state: ^Editor_State) {
+	defer format_on_type(state, '\n')
+	if expand_abbreviation(state, '\n') || continue_prose_line(state) {
+		return
+	}
+	delete_selection(state)
+	gb := &state.buffer
+	lang := editor.find_language(state.language)
+	style := indent_style(state)
+
+	// Whitespace right after the cursor would end up before the new indent.
+	end := state.cursor_pos
+	for end < editor.current_length(gb) {
+		b := editor.char_at(gb, end)
+		if b != ' ' && b != '\t' {break}
+		end += 1
+	}
+	editor.delete_bytes_range(gb, state.cursor_pos, end - state.cursor_pos)
+
+	line, col := editor.logical_pos_to_line_col(gb, state.cursor_pos)
+	text := editor.get_line(gb, line, context.temp_allocator)
+	before, rest := text[:col], text[col:]
+
+	insert_bytes_at_cursor(state, []u8{'\n'})
+	indent := editor.compute_indent(gb, lang, line + 1, rest, style)
+
+	between_pair := false
+	if lang != nil && rest != "" {
+		opened := strings.trim_right_space(before)
+		for tok in lang.indent.indent_after {
+			if strings.has_suffix(opened, tok) {
+				between_pair = true
+				break
+			}
+		}
+		between_pair = between_pair && indent < editor.indent_columns(before, style.width) + style.width
+	}
+
+	if between_pair {
+		inner := editor.indent_columns(before, style.width) + style.width
+		insert_bytes_at_cursor(state, transmute([]u8)editor.indent_string(style, inner))
+		caret := state.cursor_pos
+		insert_bytes_at_cursor(state, []u8{'\n'})
+		insert_bytes_at_cursor(state, transmute([]u8)editor.indent_string(style, indent))
+		state.cursor_pos = caret
+		sync_cursor(state)
+		set_preferred_col(state)
+		return
+	}
+
+	insert_bytes_at_cursor(state, transmute([]u8)editor.indent_string(style, indent))
+}
+
+// Tab: indents the selected lines when the selection spans lines, otherwise
+// inserts one indent unit at the cursor.
+insert_tab :: proc(state: ^Editor_State) {
+	if jump_snippet_stop(state, 1) || accept_inline_completion(state) {
+		return
+	}
+	if has_selection(state) {
+		start, end := selection_range(state)
+		sl, _ := editor.logical_pos_to_line_col(&state.buffer, start)
+		el, _ := editor.logical_pos_to_line_col(&state.buffer, end)
+		if sl != el {
+			shift_selected_lines(state, 1)
+			return
+		}
+	}
+
+	style := indent_style(state)
+	if !style.use_spaces {
+		// Store a real '\t'; the text and cursor layers expand it visually.
+		insert_bytes_at_cursor(state, []u8{'\t'})
+		return
+	}
+	width := max(style.width, 1)
+	col := state.cursor_data.visual_col
+	pad := width - col % width
+	insert_bytes_at_cursor(state, transmute([]u8)strings.repeat(" ", pad, context.temp_allocator))
+}
+
+// Indents (levels > 0) or dedents (levels < 0) every line touched by the
+// cursor or selection.
+shift_selected_lines :: proc(state: ^Editor_State, levels: int) {
+	positions := [2]int{state.cursor_pos, state.selection_anchor}
+	tracked := positions[:2] if state.selection_anchor >= 0 else positions[:1]
+	style := indent_style(state)
+	for r in selected_line_ranges(state) {
+		editor.shift_lines(&state.buffer, r.first, r.last, levels, style, tracked)
+	}
+	apply_tracked_edit(state, tracked)
+}
+
+// Recomputes the indentation of every line touched by the cursor or selection.
+reindent_selection :: proc(state: ^Editor_State) {
+	lang := editor.find_language(state.language)
+	positions := [2]int{state.cursor_pos, state.selection_anchor}
+	tracked := positions[:2] if state.selection_anchor >= 0 else positions[:1]
+	style := indent_style(state)
+	for r in selected_line_ranges(state) {
+		editor.reindent_lines(&state.buffer, lang, r.first, r.last, style, tracked)
+	}
+	apply_tracked_edit(state, tracked)
+}
+
+undo_edit :: proc(state: ^Editor_State) {
+	if cursor, ok := editor.undo(&state.buffer); ok {
+		state.selection_anchor = -1
+		state.cursor_pos = min(cursor, editor.current_length(&state.buffer))
+		sync_cursor(state)
+		set_preferred_col(state)
+	} else {
+		set_message(state, "Nothing to undo")
+	}
 }
 
-// Backspace: delete the codepoint immediately before the cursor.
+redo_edit :: proc(state: ^Editor_State) {
+	if cursor, ok := editor.redo(&state.buffer); ok {
+		state.selection_anchor = -1
+		state.cursor_pos = min(cursor, editor.current_length(&state.buffer))
+		sync_cursor(state)
+		set_preferred_col(state)
+	} else {
+		set_message(state, "Nothing to redo")
+	}
+}
+
+// Backspace: delete the selection or the character (grapheme cluster)
+// immediately before the cursor.
 delete_before_cursor :: proc(state: ^Editor_State) {
+	if delete_selection(state) {return}
 	if state.cursor_pos == 0 {return}
-	// Walk back over any UTF-8 continuation bytes to find codepoint start.
-	pos := state.cursor_pos - 1
-	for pos > 0 && (editor.char_at(&state.buffer, pos) & 0xC0) == 0x80 {
-		pos -= 1
-	}
+	pos := editor.prev_grapheme_pos(&state.buffer, state.cursor_pos)
 	editor.delete_bytes_range(&state.buffer, pos, state.cursor_pos - pos)
 	state.cursor_pos = pos
 	sync_cursor(state)
 	set_preferred_col(state)
 }
 
-// Delete key: delete the codepoint immediately after the cursor.
+// Delete key: delete the selection or the character (grapheme cluster)
+// immediately after the cursor.
 delete_after_cursor :: proc(state: ^Editor_State) {
+	if delete_selection(state) {return}
 	total := editor.current_length(&state.buffer)
 	if state.cursor_pos >= total {return}
-	first := editor.char_at(&state.buffer, state.cursor_pos)
-	char_len: int
-	switch {
-	case first < 0x80:
-		char_len = 1
-	case first < 0xE0:
-		char_len = 2
-	case first < 0xF0:
-		char_len = 3
-	case:
-		char_len = 4
+	end := editor.next_grapheme_pos(&state.buffer, state.cursor_pos)
+	editor.delete_bytes_range(&state.buffer, state.cursor_pos, end - state.cursor_pos)
+	sync_cursor(state)
+	set_preferred_col(state)
+}
+
+// Applies an edit that reported moved positions back as [cursor, anchor] and
+// re-syncs the view.
+apply_tracked_edit :: proc(state: ^Editor_State, positions: []int) {
+	state.cursor_pos = positions[0]
+	if len(positions) > 1 {
+		state.selection_anchor = positions[1]
 	}
-	editor.delete_bytes_range(&state.buffer, state.cursor_pos, char_len)
 	sync_cursor(state)
 	set_preferred_col(state)
 }
 
+// Toggles line comments on every line touched by the cursor or selection.
+toggle_line_comment :: proc(state: ^Editor_State) {
+	lang := editor.find_language(state.language)
+	positions := [2]int{state.cursor_pos, state.selection_anchor}
+	tracked := positions[:2] if state.selection_anchor >= 0 else positions[:1]
+	if editor.toggle_line_comments(&state.buffer, lang, selected_line_ranges(state), tracked) {
+		apply_tracked_edit(state, tracked)
+	}
+}
+
+// Wraps the selection (or the current line) in a block comment, or unwraps it.
+toggle_block_comment :: proc(state: ^Editor_State) {
+	lang := editor.find_language(state.language)
+	start, end: int
+	if has_selection(state) {
+		start, end = selection_range(state)
+	} else {
+		line, _ := editor.logical_pos_to_line_col(&state.buffer, state.cursor_pos)
+		start = editor.line_col_to_logical_pos(&state.buffer, line, 0)
+		end = start + editor.get_line_length(&state.buffer, line)
+	}
+	positions := [2]int{state.cursor_pos, state.selection_anchor}
+	tracked := positions[:2] if state.selection_anchor >= 0 else positions[:1]
+	if editor.toggle_block_comment(&state.buffer, lang, start, end, tracked) {
+		apply_tracked_edit(state, tracked)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Cursor movement
 // ---------------------------------------------------------------------------
 
-// Move one codepoint to the left.
+// Move one character (grapheme cluster) to the left.
 move_cursor_left :: proc(state: ^Editor_State) {
 	if state.cursor_pos == 0 {return}
-	pos := state.cursor_pos - 1
-	for pos > 0 && (editor.char_at(&state.buffer, pos) & 0xC0) == 0x80 {
-		pos -= 1
-	}
-	state.cursor_pos = pos
+	state.cursor_pos = editor.prev_grapheme_pos(&state.buffer, state.cursor_pos)
 	sync_cursor(state)
 	set_preferred_col(state)
 }
 
-// Move one codepoint to the right.
+// Move one character (grapheme cluster) to the right.
 move_cursor_right :: proc(state: ^Editor_State) {
 	total := editor.current_length(&state.buffer)
 	if state.cursor_pos >= total {return}
-	first := editor.char_at(&state.buffer, state.cursor_pos)
-	char_len: int
-	switch {
-	case first < 0x80:
-		char_len = 1
-	case first < 0xE0:
-		char_len = 2
-	case first < 0xF0:
-		char_len = 3
-	case:
-		char_len = 4
-	}
-	state.cursor_pos = min(total, state.cursor_pos + char_len)
+	state.cursor_pos = editor.next_grapheme_pos(&state.buffer, state.cursor_pos)
 	sync_cursor(state)
 	set_preferred_col(state)
 }
@@ -157,23 +493,120 @@ move_cursor_down :: proc(state: ^Editor_State) {
 	// preferred_col intentionally NOT updated.
 }
 
-// Move to the first byte of the current line.
+// Move to the first non-blank of the current line, or to its first byte
+// when already there; only the latter without editor.smart_home.
 move_cursor_home :: proc(state: ^Editor_State) {
-	line, _ := editor.logical_pos_to_line_col(&state.buffer, state.cursor_pos)
-	state.cursor_pos = editor.line_col_to_logical_pos(&state.buffer, line, 0)
+	line, col := editor.logical_pos_to_line_col(&state.buffer, state.cursor_pos)
+	target := 0
+	if config_bool(state, "editor.smart_home") {
+		text := editor.get_line(&state.buffer, line, context.temp_allocator)
+		if first := editor.first_non_blank(text); col != first {
+			target = first
+		}
+	}
+	state.cursor_pos = editor.line_col_to_logical_pos(&state.buffer, line, target)
 	sync_cursor(state)
 	set_preferred_col(state)
 }
 
-// Move to the last byte of the current line.
+// Move after the last non-blank of the current line, or to its last byte
+// when already there; only the latter without editor.smart_home.
 move_cursor_end :: proc(state: ^Editor_State) {
-	line, _ := editor.logical_pos_to_line_col(&state.buffer, state.cursor_pos)
-	end_col := editor.get_line_length(&state.buffer, line)
-	state.cursor_pos = editor.line_col_to_logical_pos(&state.buffer, line, end_col)
+	line, col := editor.logical_pos_to_line_col(&state.buffer, state.cursor_pos)
+	target := editor.get_line_length(&state.buffer, line)
+	if config_bool(state, "editor.smart_home") {
+		text := editor.get_line(&state.buffer, line, context.temp_allocator)
+		if last := editor.last_non_blank(text); col != last {
+			target = last
+		}
+	}
+	state.cursor_pos = editor.line_col_to_logical_pos(&state.buffer, line, target)
+	sync_cursor(state)
+	set_preferred_col(state)
+}
+
+// Move to the end of the next word, by editor.word_chars; past the end of a
+// line to the next line's start.  With editor.subword_motion it stops at
+// sub-words too.
+move_cursor_word_right :: proc(state: ^Editor_State) {
+	move_by_word(state, 1, config_bool(state, "editor.subword_motion"))
+}
+
+// Move to the start of the previous word; see move_cursor_word_right.
+move_cursor_word_left :: proc(state: ^Editor_State) {
+	move_by_word(state, -1, config_bool(state, "editor.subword_motion"))
+}
+
+// Move to the end of the next camelCase hump or snake_case part.
+move_cursor_subword_right :: proc(state: ^Editor_State) {
+	move_by_word(state, 1, true)
+}
+
+// Move to the start of the previous camelCase hump or snake_case part.
+move_cursor_subword_left :: proc(state: ^Editor_State) {
+	move_by_word(state, -1, true)
+}
+
+// Move to the very start of the buffer.
+move_cursor_file_start :: proc(state: ^Editor_State) {
+	record_jump(state)
+	state.cursor_pos = 0
+	sync_cursor(state)
+	set_preferred_col(state)
+}
+
+// Move to the very end of the buffer.
+move_cursor_file_end :: proc(state: ^Editor_State) {
+	record_jump(state)
+	state.cursor_pos = editor.current_length(&state.buffer)
 	sync_cursor(state)
 	set_preferred_col(state)
 }
 
+@(private = "file")
+move_by_word :: proc(state: ^Editor_State, dir: int, sub: bool) {
+	line, col := editor.logical_pos_to_line_col(&state.buffer, state.cursor_pos)
+	text := editor.get_line(&state.buffer, line, context.temp_allocator)
+	text = strings.trim_right(text, "\r\n")
+	extra := word_chars(state)
+	if dir > 0 {
+		if col >= len(text) {
+			if line + 1 < editor.get_line_count(&state.buffer) {
+				line, col = line + 1, 0
+			}
+		} else {
+			col = editor.next_word_col(text, col, extra, sub)
+		}
+	} else {
+		if col == 0 {
+			if line > 0 {
+				line -= 1
+				prev := editor.get_line(&state.buffer, line, context.temp_allocator)
+				col = len(strings.trim_right(prev, "\r\n"))
+			}
+		} else {
+			col = editor.prev_word_col(text, col, extra, sub)
+		}
+	}
+	state.cursor_pos = editor.line_col_to_logical_pos(&state.buffer, line, col)
+	sync_cursor(state)
+	set_preferred_col(state)
+}
+
+// The buffer's word characters besides letters, digits and _:
+// editor.word_chars, which a [language.<id>] table may set, else the
+// language's own.
+@(private = "file")
+word_chars :: proc(state: ^Editor_State) -> string {
+	if chars, ok := config_value(state, "editor.word_chars").(string); ok {
+		return chars
+	}
+	if lang := editor.find_language(state.language); lang != nil {
+		return lang.word_chars
+	}
+	return ""
+}
+
 // ---------------------------------------------------------------------------
 // GLFW callbacks
 // ---------------------------------------------------------------------------
@@ -181,66 +614,85 @@ move_cursor_end :: proc(state: ^Editor_State) {
 // Fires for every printable Unicode character typed.
 // GLFW does not fire this for Tab, so Tab is handled in key_callback.
 char_callback :: proc "c" (window: glfw.WindowHandle, codepoint: rune) {
-	context = runtime.default_context()
+	context = callback_context()
 	state := cast(^Editor_State)glfw.GetWindowUserPointer(window)
 	if state == nil {return}
-	insert_rune_at_cursor(state, codepoint)
+	note_input(state)
+	defer record_metric(state, .Input, time.tick_now())
+	// The key event for this character was already consumed by the keymap.
+	if state.suppress_char {
+		state.suppress_char = false
+		return
+	}
+	switch state.mode {
+	case "prompt":
+		prompt_insert_rune(state, codepoint)
+	case "peek":
+		peek_insert_rune(state, codepoint)
+	case "panel", "diff", "csv", "log", "docs":
+	// The panel, the diff, CSV and log views and the documentation pane are
+	// navigated with keys only.
+	case:
+		if state.disk.view == .Hex {
+			editor.begin_undo_group(&state.undo, state.cursor_pos, "typing")
+			hex_type_rune(state, codepoint)
+			editor.end_undo_group(&state.undo, state.cursor_pos)
+			return
+		}
+		if is_read_only(state) {
+			set_message(state, "%s is read-only", document_title(state.path))
+			return
+		}
+		// Consecutive typing is undone as one step.
+		editor.begin_undo_group(&state.undo, state.cursor_pos, "typing")
+		if !expand_abbreviation(state, codepoint) {
+			insert_rune_at_cursor(state, codepoint)
+			wrap_prose_line(state, codepoint)
+			align_table_as_typed(state, codepoint)
+			format_on_type(state, codepoint)
+		}
+		editor.end_undo_group(&state.undo, state.cursor_pos)
+		refresh_completion(state, .Typed)
+	}
 }
 
-// Fires for special keys (and repeats while held).
+// Fires for special keys (and repeats while held).  Every key goes through the
+// keymap first; unbound printable keys fall through to char_callback.
 key_callback :: proc "c" (window: glfw.WindowHandle, key, scancode, action, mods: i32) {
-	context = runtime.default_context()
+	context = callback_context()
 	if action != glfw.PRESS && action != glfw.REPEAT {return}
 
 	state := cast(^Editor_State)glfw.GetWindowUserPointer(window)
 	if state == nil {return}
+	note_input(state)
+	defer record_metric(state, .Input, time.tick_now())
 
-	ctrl := (mods & glfw.MOD_CONTROL) != 0
-
-	switch key {
-	case glfw.KEY_BACKSPACE:
-		delete_before_cursor(state)
+	// A char event always follows its own key event, so any stale suppression
+	// from a chord that produced no character is dropped here.
+	state.suppress_char = false
+	if editor.is_modifier_key(key) {return}
+	strings.builder_reset(&state.message)
 
-	case glfw.KEY_DELETE:
-		delete_after_cursor(state)
-
-	case glfw.KEY_ENTER, glfw.KEY_KP_ENTER:
-		insert_bytes_at_cursor(state, []u8{'\n'})
-
-	case glfw.KEY_TAB:
-		// Store a real '\t'; the text and cursor layers expand it visually.
-		insert_bytes_at_cursor(state, []u8{'\t'})
-
-	case glfw.KEY_LEFT:
-		move_cursor_left(state)
-
-	case glfw.KEY_RIGHT:
-		move_cursor_right(state)
-
-	case glfw.KEY_UP:
-		move_cursor_up(state)
-
-	case glfw.KEY_DOWN:
-		move_cursor_down(state)
-
-	case glfw.KEY_HOME:
-		if ctrl {
-			// Ctrl+Home → top of file
-			state.cursor_pos = 0
-			sync_cursor(state)
-			set_preferred_col(state)
-		} else {
-			move_cursor_home(state)
-		}
+	had_pending := editor.has_pending_keys(&state.keymap)
+	chord := editor.Key_Chord {
+		key  = key,
+		mods = mods & editor.KEY_MOD_MASK,
+	}
 
-	case glfw.KEY_END:
-		if ctrl {
-			// Ctrl+End → end of file
-			state.cursor_pos = editor.current_length(&state.buffer)
-			sync_cursor(state)
-			set_preferred_col(state)
-		} else {
-			move_cursor_end(state)
+	result, command := editor.feed_key(&state.keymap, chord, state.mode, state.language)
+	switch result {
+	case .Matched:
+		state.suppress_char = true
+		run_command(state, command)
+		if !strings.has_prefix(command, "completion.") {
+			refresh_completion(state, .Refresh)
 		}
+	case .Pending:
+		state.suppress_char = true
+	case .Unbound:
+		// Swallow the tail of an unknown sequence instead of typing it.
+		state.suppress_char = had_pending
 	}
+
+	update_status_line(state)
 }
@@ -0,0 +1,229 @@
+package main
+
+import "core:encoding/json"
+import "core:os"
+import "core:path/filepath"
+import "core:strings"
+import editor "editor"
+
+// Longest path the status line shows; longer ones lose their start.
+BREADCRUMB_MAX :: 60
+
+// JSON and YAML buffers show the path of the cursor in the status line,
+// worked out again when the cursor moves or the buffer changes.
+Json_Tools_State :: struct {
+	breadcrumb: strings.Builder,
+	computed:   bool, // for this document, undo version and cursor:
+	doc_id:     int,
+	version:    int,
+	pos:        int,
+}
+
+destroy_json_tools :: proc(state: ^Editor_State) {
+	strings.builder_destroy(&state.json_tools.breadcrumb)
+}
+
+// json.format: indents the JSON in the buffer one level per nesting, with
+// the buffer's indentation settings.
+format_json_document :: proc(state: ^Editor_State) {
+	style := indent_style(state)
+	rewrite_json(state, editor.indent_string(style, style.width), "json.format")
+}
+
+// json.minify: puts the JSON in the buffer on one line without blanks.
+minify_json_document :: proc(state: ^Editor_State) {
+	rewrite_json(state, "", "json.minify")
+}
+
+// json.validate: checks the buffer is JSON and, when a schema applies to it,
+// that it follows the schema.  The schema is json.schema or else the file's
+// own "$schema"; the problems become diagnostics.
+validate_json_document :: proc(state: ^Editor_State) {
+	if state.path == "" {
+		set_message(state, "Save the buffer before validating it")
+		return
+	}
+	n, ok := check_json_document(state)
+	switch {
+	case !ok:
+	// check_json_document said what is wrong
+	case n < 0:
+		set_message(state, "Valid JSON; no schema to check it against, set json.schema")
+	case n == 0:
+		set_message(state, "Valid JSON that follows its schema")
+	case:
+		set_message(state, "%d problems against the schema; see diagnostics.list", n)
+	}
+}
+
+// After a JSON file is saved: checks it against its schema, if it has one,
+// leaving the message as it is.
+validate_json_on_save :: proc(state: ^Editor_State) {
+	if state.language != "json" || state.path == "" || json_schema_source(state) == "" {
+		return
+	}
+	message := strings.clone(strings.to_string(state.message), context.temp_allocator)
+	check_json_document(state)
+	set_message(state, "%s", message)
+}
+
+// json.copy_path: copies the JSONPath or YAML path of the cursor.
+copy_json_path :: proc(state: ^Editor_State) {
+	path := cursor_data_path(state)
+	if path == "" {
+		set_message(state, "No path here")
+		return
+	}
+	set_clipboard(state, path, false)
+	set_message(state, "Copied %s", path)
+}
+
+// For the status line: the path of the cursor in a JSON or YAML buffer, by
+// json.breadcrumbs.  Temp allocated.
+breadcrumb_label :: proc(state: ^Editor_State) -> string {
+	if !config_bool(state, "json.breadcrumbs") || .Breadcrumbs in state.degraded {
+		return ""
+	}
+	if state.language != "json" && state.language != "yaml" {
+		return ""
+	}
+	j := &state.json_tools
+	stale := !j.computed || j.doc_id != state.doc_id || j.version != state.undo.version
+	if stale || j.pos != state.cursor_pos {
+		strings.builder_reset(&j.breadcrumb)
+		strings.write_string(&j.breadcrumb, cursor_data_path(state))
+		j.computed = true
+		j.doc_id, j.version, j.pos = state.doc_id, state.undo.version, state.cursor_pos
+	}
+	path := strings.to_string(j.breadcrumb)
+	if path == "" || path == "$" {
+		return ""
+	}
+	if len(path) > BREADCRUMB_MAX {
+		cut := len(path) - BREADCRUMB_MAX
+		for cut < len(path) && path[cut] != '.' && path[cut] != '[' {
+			cut += 1
+		}
+		path = strings.concatenate({"…", path[cut:]}, context.temp_allocator)
+	}
+	return strings.concatenate({"  ", path}, context.temp_allocator)
+}
+
+// The path of the cursor from the text before it.  Temp allocated.
+@(private = "file")
+cursor_data_path :: proc(state: ^Editor_State) -> string {
+	switch state.language {
+	case "json":
+		text := editor.get_text_segment(&state.buffer, 0, state.cursor_pos, context.temp_allocator)
+		return editor.json_path_at(text, len(text))
+	case "yaml":
+		line := state.cursor_data.line
+		end := editor.line_col_to_logical_pos(&state.buffer, line + 1, 0)
+		if line + 1 >= editor.get_line_count(&state.buffer) {
+			end = editor.current_length(&state.buffer)
+		}
+		text := editor.get_text_segment(&state.buffer, 0, end, context.temp_allocator)
+		lines := strings.split_lines(text, context.temp_allocator)
+		return editor.yaml_path_at(lines, line)
+	}
+	return ""
+}
+
+// Replaces the buffer with its JSON written out with indent, "" for one
+// line.  Syntax errors are reported instead.
+@(private = "file")
+rewrite_json :: proc(state: ^Editor_State, indent, command: string) {
+	text := editor.get_text(&state.buffer, context.temp_allocator)
+	root, err, ok := editor.parse_json_tree(text)
+	if !ok {
+		report_json_syntax_error(state, err)
+		return
+	}
+	apply_format_output(state, editor.format_json_tree(text, root, indent), text, command)
+}
+
+// Checks the buffer's JSON, publishing what is wrong with it as diagnostics.
+// Returns the number of schema problems, -1 when there is no schema, and
+// false after a syntax error or when the schema cannot be read.
+@(private = "file")
+check_json_document :: proc(state: ^Editor_State) -> (problems: int, ok: bool) {
+	full := workspace_path(state, state.path)
+	text := editor.get_text(&state.buffer, context.temp_allocator)
+	root, err, parsed := editor.parse_json_tree(text)
+	if !parsed {
+		pos := min(err.pos, len(text))
+		line, col := editor.logical_pos_to_line_col(&state.buffer, pos)
+		d := Diagnostic{full, line, col, .Error, err.message, "json"}
+		publish_diagnostics(state, "json", full, {d})
+		publish_diagnostics(state, "json-schema", full, nil)
+		report_json_syntax_error(state, err)
+		return 0, false
+	}
+	publish_diagnostics(state, "json", full, nil)
+	source := json_schema_source(state)
+	if source == "" {
+		publish_diagnostics(state, "json-schema", full, nil)
+		return -1, true
+	}
+	schema := load_json_schema(state, source) or_return
+	diags := make([dynamic]Diagnostic, context.temp_allocator)
+	for p in editor.validate_json_schema(text, root, schema) {
+		line, col := editor.logical_pos_to_line_col(&state.buffer, p.pos)
+		append(&diags, Diagnostic{full, line, col, .Warning, p.message, "json-schema"})
+	}
+	publish_diagnostics(state, "json-schema", full, diags[:])
+	return len(diags), true
+}
+
+// Where the buffer's schema is: json.schema, or the "$schema" at the top of
+// the buffer.  "" for none.
+@(private = "file")
+json_schema_source :: proc(state: ^Editor_State) -> string {
+	if path := config_value(state, "json.schema").(string) or_else ""; path != "" {
+		return workspace_path(state, path)
+	}
+	text := editor.get_text(&state.buffer, context.temp_allocator)
+	root, _, ok := editor.parse_json_tree(text)
+	if !ok || root.kind != .Object {
+		return ""
+	}
+	for member in root.children {
+		if editor.json_node_key(text, member) == "$schema" && member.kind == .String {
+			return editor.json_node_string(text, member)
+		}
+	}
+	return ""
+}
+
+// Reads the schema at source, a path relative to the buffer's file unless
+// absolute.  Schemas on the web are not downloaded.  Temp allocated.
+@(private = "file")
+load_json_schema :: proc(state: ^Editor_State, source: string) -> (json.Value, bool) {
+	if strings.has_prefix(source, "http://") || strings.has_prefix(source, "https://") {
+		set_message(state, "Schemas are not downloaded; set json.schema to a copy of %s", source)
+		return nil, false
+	}
+	path := strings.trim_prefix(source, "file://")
+	if !filepath.is_abs(path) {
+		dir := filepath.dir(workspace_path(state, state.path), context.temp_allocator)
+		path = filepath.join({dir, path}, context.temp_allocator)
+	}
+	data, err := os.read_entire_file_from_path(path, context.temp_allocator)
+	if err != nil {
+		set_message(state, "Cannot read the schema %s: %v", path, err)
+		return nil, false
+	}
+	schema, perr := json.parse(data, allocator = context.temp_allocator)
+	if perr != nil {
+		set_message(state, "The schema %s is not JSON: %v", path, perr)
+		return nil, false
+	}
+	return schema, true
+}
+
+@(private = "file")
+report_json_syntax_error :: proc(state: ^Editor_State, err: editor.Json_Syntax_Error) {
+	pos := min(err.pos, editor.current_length(&state.buffer))
+	line, col := editor.logical_pos_to_line_col(&state.buffer, pos)
+	set_message(state, "Not JSON: %s at line %d, column %d", err.message, line + 1, col + 1)
+}
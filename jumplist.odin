@@ -0,0 +1,110 @@
+package main
+
+import "core:strings"
+
+JUMP_LIST_MAX :: 100
+
+Jump :: struct {
+	path: string, // empty for the unnamed buffer
+	line: int,
+	col:  int,
+}
+
+// Locations left by large motions (search results, goto line, file start
+// and end), walked with jump_back/jump_forward like ctrl+o/ctrl+i in vim.
+Jump_List :: struct {
+	entries: [dynamic]Jump,
+	index:   int, // current place while walking; len(entries) when not walking
+}
+
+destroy_jump_list :: proc(jumps: ^Jump_List) {
+	for j in jumps.entries {
+		delete(j.path)
+	}
+	delete(jumps.entries)
+}
+
+// Remembers the cursor position before a large motion.  Entries ahead of the
+// current place are dropped, as in a browser history.
+record_jump :: proc(state: ^Editor_State) {
+	jumps := &state.jumps
+	for j in jumps.entries[jumps.index:] {
+		delete(j.path)
+	}
+	resize(&jumps.entries, jumps.index)
+	push_current_location(state)
+	jumps.index = len(jumps.entries)
+}
+
+// ctrl+o: back to where the last large motion started.
+jump_back :: proc(state: ^Editor_State) {
+	jumps := &state.jumps
+	if jumps.index >= len(jumps.entries) {
+		// Keep the current position so jump_forward can return to it.
+		push_current_location(state)
+		jumps.index = len(jumps.entries) - 1
+	}
+	for jumps.index > 0 {
+		jumps.index -= 1
+		if goto_jump(state, jumps.index) {
+			return
+		}
+	}
+	set_message(state, "Start of jump list")
+}
+
+// ctrl+i: undoes a jump_back.
+jump_forward :: proc(state: ^Editor_State) {
+	jumps := &state.jumps
+	for jumps.index + 1 < len(jumps.entries) {
+		jumps.index += 1
+		if goto_jump(state, jumps.index) {
+			return
+		}
+	}
+	set_message(state, "End of jump list")
+}
+
+// Appends the cursor position, replacing an older entry for the same line so
+// bouncing between two places does not flood the list.
+@(private = "file")
+push_current_location :: proc(state: ^Editor_State) {
+	jumps := &state.jumps
+	here := Jump {
+		path = state.path,
+		line = state.cursor_data.line,
+		col  = state.cursor_data.col,
+	}
+	for j, i in jumps.entries {
+		if j.path == here.path && j.line == here.line {
+			delete(j.path)
+			ordered_remove(&jumps.entries, i)
+			break
+		}
+	}
+	if len(jumps.entries) >= JUMP_LIST_MAX {
+		delete(jumps.entries[0].path)
+		ordered_remove(&jumps.entries, 0)
+	}
+	here.path = strings.clone(here.path)
+	append(&jumps.entries, here)
+}
+
+// Moves to entry i without recording a jump.  Entries whose file can no
+// longer be opened are skipped.
+@(private = "file")
+goto_jump :: proc(state: ^Editor_State, i: int) -> bool {
+	j := state.jumps.entries[i]
+	if j.path == state.path {
+		if j.line == state.cursor_data.line {
+			return false // already here, keep walking
+		}
+		goto_line_col(state, j.line, j.col)
+		return true
+	}
+	if j.path == "" || !open_file(state, j.path) {
+		return false
+	}
+	goto_line_col(state, j.line, j.col)
+	return true
+}
@@ -0,0 +1,89 @@
+package main
+
+import "core:fmt"
+import "core:mem"
+import "core:strings"
+import editor "editor"
+
+// Features that cost time in proportion to the buffer size, and are turned
+// off for large buffers so typing stays fast.  Each tier keeps the features
+// of the one below off.
+Degraded_Feature :: enum u8 {
+	Git_Changes, // gutter marks, which diff the buffer on every edit
+	Blame, // inline blame, which reruns git blame on every edit
+	Completion, // while typing; completion.trigger still works
+	Linters, // on save; lint.run still works
+	Format_On_Save,
+	Recovery, // crash snapshots, which write out the whole buffer
+	Breadcrumbs, // the JSON or YAML path and the symbol bar, which reread the text
+	History, // a snapshot of the file on every save
+}
+
+Degraded_Features :: bit_set[Degraded_Feature]
+
+LARGE_FILE_FEATURES :: Degraded_Features{.Git_Changes, .Blame, .Completion, .Breadcrumbs}
+HUGE_FILE_FEATURES :: LARGE_FILE_FEATURES + {.Linters, .Format_On_Save, .Recovery, .History}
+
+DEGRADED_FEATURE_NAMES := [Degraded_Feature]string {
+	.Git_Changes    = "git marks",
+	.Blame          = "blame",
+	.Completion     = "completion",
+	.Linters        = "lint",
+	.Format_On_Save = "format",
+	.Recovery       = "recovery",
+	.Breadcrumbs    = "breadcrumbs",
+	.History        = "history",
+}
+
+// The features off for a buffer of size bytes, by large_file.size and
+// large_file.huge_size.
+degraded_features :: proc(state: ^Editor_State, size: int) -> Degraded_Features {
+	switch {
+	case size >= config_int(state, "large_file.huge_size") * mem.Megabyte:
+		return HUGE_FILE_FEATURES
+	case size >= config_int(state, "large_file.size") * mem.Megabyte:
+		return LARGE_FILE_FEATURES
+	}
+	return {}
+}
+
+// Follows the active buffer across tiers as it is opened, switched to or
+// grows and shrinks.  Called every frame.
+update_degradation :: proc(state: ^Editor_State) {
+	size := editor.current_length(&state.buffer)
+	features := degraded_features(state, size)
+	if features == state.degraded {
+		return
+	}
+	// Say so when a tier is entered, not when one is left.
+	if features > state.degraded {
+		set_message(
+			state,
+			"%s is large (%d MB); turned off %s",
+			document_title(state.path),
+			size / mem.Megabyte,
+			degraded_feature_list(features),
+		)
+	}
+	state.degraded = features
+}
+
+// For the status line: what the active buffer runs without.  Temp allocated.
+degradation_label :: proc(state: ^Editor_State) -> string {
+	switch {
+	case state.degraded == {}:
+		return ""
+	case state.degraded >= HUGE_FILE_FEATURES:
+		return fmt.tprintf("  [huge file: no %s]", degraded_feature_list(state.degraded))
+	}
+	return fmt.tprintf("  [large file: no %s]", degraded_feature_list(state.degraded))
+}
+
+@(private = "file")
+degraded_feature_list :: proc(features: Degraded_Features) -> string {
+	names := make([dynamic]string, context.temp_allocator)
+	for f in features {
+		append(&names, DEGRADED_FEATURE_NAMES[f])
+	}
+	return strings.join(names[:], ", ", context.temp_allocator)
+}
@@ -0,0 +1,197 @@
+package main
+
+import "core:path/filepath"
+import "core:slice"
+import "core:strings"
+import editor "editor"
+
+// A linter a project runs on every save, a [[linters]] table of its
+// workspace config file:
+//
+//	[[linters]]
+//	name = "ruff"
+//	command = "ruff check --output-format json {file}"
+//	languages = ["python"]
+//	parser = "ruff"
+//
+// Its problems become diagnostics with the linter's name as their source.
+// Linters run only once the user trusts the workspace, see workspace.trust.
+Linter_Config :: struct {
+	name:        string,
+	command:     string, // run by the shell in the workspace root; {file} is the file, quoted
+	languages:   []string, // language ids it checks, every file when empty
+	parser:      string, // a JSON report, see editor.LINT_JSON_PARSERS
+	errorformat: []string, // for text output, when there is no parser; defaults when empty
+}
+
+// A linter checking one file in the background.
+Lint_Run :: struct {
+	linter: int, // index into state.project.linters
+	path:   string, // absolute
+	run:    ^editor.Task_Run,
+	output: strings.Builder,
+}
+
+Lint_State :: struct {
+	runs: [dynamic]Lint_Run,
+}
+
+destroy_lint :: proc(state: ^Editor_State) {
+	for &r in state.lint.runs {
+		destroy_lint_run(&r)
+	}
+	delete(state.lint.runs)
+}
+
+destroy_linter_config :: proc(linter: Linter_Config) {
+	delete(linter.name)
+	delete(linter.command)
+	for id in linter.languages {
+		delete(id)
+	}
+	delete(linter.languages)
+	delete(linter.parser)
+	for f in linter.errorformat {
+		delete(f)
+	}
+	delete(linter.errorformat)
+}
+
+// lint.run: checks the active buffer's file with its linters now.
+lint_document :: proc(state: ^Editor_State) {
+	if state.path == "" {
+		set_message(state, "Save the buffer before linting it")
+		return
+	}
+	if len(state.project.linters) > 0 && !workspace_trusted(state) {
+		set_message(state, "Linters run once the workspace is trusted, see workspace.trust")
+		return
+	}
+	if run_linters(state) == 0 {
+		set_message(state, "No linters for %s files in %s", state.language, WORKSPACE_CONFIG_FILE)
+	}
+}
+
+// Starts the linters of the active buffer's language on its file, as saved.
+// A run still checking the file is replaced.  Returns how many started.
+run_linters :: proc(state: ^Editor_State) -> (started: int) {
+	if state.path == "" || len(state.project.linters) == 0 || !workspace_trusted(state) {
+		return 0
+	}
+	full := strings.clone(workspace_path(state, state.path))
+	defer delete(full)
+	file := bookmark_path(state)
+	for linter, i in state.project.linters {
+		if len(linter.languages) > 0 && !slice.contains(linter.languages, state.language) {
+			continue
+		}
+		for &r, j in state.lint.runs {
+			if r.linter == i && r.path == full {
+				destroy_lint_run(&r)
+				ordered_remove(&state.lint.runs, j)
+				break
+			}
+		}
+		command := fill_placeholder(linter.command, "{file}", file)
+		run, err := editor.start_task(shell_command(command), state.workspace_root)
+		if err != nil {
+			set_message(state, "Cannot run the %s linter: %v", linter.name, err)
+			continue
+		}
+		append(&state.lint.runs, Lint_Run{linter = i, path = strings.clone(full), run = run})
+		started += 1
+	}
+	return started
+}
+
+// Publishes the problems of linters that finished.  Called every frame.
+update_linters :: proc(state: ^Editor_State) {
+	for i := 0; i < len(state.lint.runs); {
+		r := &state.lint.runs[i]
+		finished := editor.is_task_finished(r.run)
+		editor.take_task_output(r.run, &r.output)
+		if !finished {
+			i += 1
+			continue
+		}
+		publish_lint_problems(state, r)
+		destroy_lint_run(r)
+		ordered_remove(&state.lint.runs, i)
+	}
+}
+
+@(private = "file")
+publish_lint_problems :: proc(state: ^Editor_State, r: ^Lint_Run) {
+	linter := &state.project.linters[r.linter]
+	output := strings.to_string(r.output)
+	problems := make([dynamic]editor.Lint_Problem, context.temp_allocator)
+	if linter.parser != "" {
+		parsed, ok := editor.parse_lint_json(linter.parser, output)
+		if !ok {
+			set_message(state, "Cannot read the %s report as %s", linter.name, linter.parser)
+			return
+		}
+		append(&problems, ..parsed)
+	} else {
+		formats := linter.errorformat if len(linter.errorformat) > 0 else DEFAULT_ERROR_FORMATS[:]
+		for line in strings.split_lines(output, context.temp_allocator) {
+			m, ok := editor.match_error_formats(formats, strings.trim_right(line, "\r"))
+			if !ok {
+				continue
+			}
+			level := editor.Lint_Level.Error
+			switch {
+			case m.kind == 'w' || strings.has_prefix(m.message, "warning"):
+				level = .Warning
+			case m.kind == 'i' || m.kind == 'n' || strings.has_prefix(m.message, "note"):
+				level = .Info
+			}
+			append(&problems, editor.Lint_Problem{m.file, m.line, m.col, m.message, level})
+		}
+	}
+	// Linters exit non-zero when they find something, so only a failure that
+	// reported nothing is one of the linter itself.
+	if len(problems) == 0 && r.run.exit_code != 0 {
+		first, _, _ := strings.partition(strings.trim_space(output), "\n")
+		set_message(state, "%s failed with exit code %d: %s", linter.name, r.run.exit_code, first)
+	}
+
+	diags := make([dynamic]Diagnostic, context.temp_allocator)
+	for p in problems {
+		path := p.file
+		if path == "" {
+			path = r.path
+		} else if !filepath.is_abs(path) {
+			path = filepath.join({state.workspace_root, path}, context.temp_allocator)
+		}
+		if path != r.path {
+			continue // only the linted file's diagnostics are replaced
+		}
+		severity: Diagnostic_Severity
+		switch p.level {
+		case .Error:
+			severity = .Error
+		case .Warning:
+			severity = .Warning
+		case .Info:
+			severity = .Info
+		}
+		d := Diagnostic {
+			path     = path,
+			line     = max(p.line - 1, 0),
+			col      = max(p.col - 1, 0),
+			severity = severity,
+			message  = p.message,
+			source   = linter.name,
+		}
+		append(&diags, d)
+	}
+	publish_diagnostics(state, linter.name, r.path, diags[:])
+}
+
+@(private = "file")
+destroy_lint_run :: proc(r: ^Lint_Run) {
+	editor.destroy_task(r.run)
+	strings.builder_destroy(&r.output)
+	delete(r.path)
+}
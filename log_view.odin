@@ -0,0 +1,178 @@
+package main
+
+import "core:strings"
+import editor "editor"
+
+// State of the log view: view.log covers the editor with the buffer's lines,
+// their timestamps and levels coloured, and the keymap switches to "log" mode
+// until it is closed.  Lines appended to the file come in through the
+// buffer, which follows a file that only grows without asking (see
+// check_disk_changes), and the view follows them while its cursor is on
+// the last line.
+Log_View_State :: struct {
+	prev_mode:   string,
+	doc_id:      int, // the document shown
+	version:     int, // and its undo version then
+	prev_filter: string, // restored when log.filter is cancelled
+	prev_invert: bool,
+}
+
+destroy_log_view :: proc(state: ^Editor_State) {
+	delete(state.log_view.prev_filter)
+}
+
+// view.log: shows the buffer as a log, the cursor on the line it is on.
+open_log_view :: proc(state: ^Editor_State) {
+	if state.disk.view != .Text {
+		set_message(state, "The log view needs the text view of a file")
+		return
+	}
+	d := state.log_data
+	if !d.visible {
+		state.log_view.prev_mode = "panel" if state.mode == "panel" else "editor"
+	}
+	text := editor.get_text(&state.buffer, context.temp_allocator)
+	editor.set_log_view(d, document_title(state.path), text, state.cursor_pos)
+	state.log_view.doc_id = state.doc_id
+	state.log_view.version = state.undo.version
+	state.mode = "log"
+	set_message(state, "/ filters lines, n and p go to problems, f follows the end, escape closes")
+}
+
+// log.close: back to the buffer as it was.
+close_log_view :: proc(state: ^Editor_State) {
+	editor.clear_log_view(state.log_data)
+	state.mode = state.log_view.prev_mode
+	request_redraw(state)
+}
+
+// Brings the view up to date with its buffer: lines added at the end are
+// appended, other edits show the buffer again.  Switching to another
+// document closes it.  Called every frame.
+update_log_view :: proc(state: ^Editor_State) {
+	d := state.log_data
+	lv := &state.log_view
+	if !d.visible {
+		return
+	}
+	if state.doc_id != lv.doc_id {
+		close_log_view(state)
+		return
+	}
+	if state.undo.version == lv.version {
+		return
+	}
+	lv.version = state.undo.version
+	text := editor.get_text(&state.buffer, context.temp_allocator)
+	shown := strings.to_string(d.text)
+	if strings.has_prefix(text, shown) {
+		editor.append_log_text(d, text[len(shown):])
+	} else {
+		line := editor.log_view_line(d)
+		editor.set_log_view(d, d.title, text, editor.log_view_line_start(d, line))
+	}
+	request_redraw(state)
+}
+
+// log.filter: folds the lines that do not match a regex as it is typed; one
+// starting with ! folds the lines that match instead.  Empty shows them all.
+filter_log_prompt :: proc(state: ^Editor_State) {
+	d := state.log_data
+	lv := &state.log_view
+	delete(lv.prev_filter)
+	lv.prev_filter = strings.clone(d.filter)
+	lv.prev_invert = d.invert
+	initial := strings.concatenate({"!" if d.invert else "", d.filter}, context.temp_allocator)
+	open_prompt(
+		state,
+		"Filter lines (!hides):",
+		proc(state: ^Editor_State, text: string) {
+			if !apply_log_filter(state, text) {
+				set_message(state, "Not a valid regex: %s", text)
+				return
+			}
+			shown, total := editor.log_view_counts(state.log_data)
+			set_message(state, "%d of %d lines shown", shown, total)
+		},
+		proc(state: ^Editor_State, text: string) {
+			apply_log_filter(state, text)
+		},
+		proc(state: ^Editor_State) {
+			lv := &state.log_view
+			editor.set_log_filter(state.log_data, lv.prev_filter, lv.prev_invert)
+		},
+		initial,
+	)
+}
+
+// log.follow: goes to the last line and stays there as lines are added.
+follow_log :: proc(state: ^Editor_State) {
+	d := state.log_data
+	editor.log_view_move(d, len(d.rows))
+	set_message(state, "Following the end of the log")
+}
+
+// log.open_line: unfolds the folded lines at the cursor, or closes the view
+// with the cursor on the line.
+open_log_line :: proc(state: ^Editor_State) {
+	d := state.log_data
+	if editor.unfold_log_row(d) {
+		return
+	}
+	pos := editor.log_view_line_start(d, editor.log_view_line(d))
+	close_log_view(state)
+	state.selection_anchor = -1
+	state.cursor_pos = min(pos, editor.current_length(&state.buffer))
+	sync_cursor(state)
+	set_preferred_col(state)
+}
+
+// log.next_problem: the next warning, error or worse.
+log_next_problem :: proc(state: ^Editor_State) {
+	if !editor.log_view_next_problem(state.log_data, false) {
+		set_message(state, "No warnings or errors below")
+	}
+}
+
+// log.prev_problem: the previous warning, error or worse.
+log_prev_problem :: proc(state: ^Editor_State) {
+	if !editor.log_view_next_problem(state.log_data, true) {
+		set_message(state, "No warnings or errors above")
+	}
+}
+
+log_up :: proc(state: ^Editor_State) {
+	editor.log_view_move(state.log_data, -1)
+}
+
+log_down :: proc(state: ^Editor_State) {
+	editor.log_view_move(state.log_data, 1)
+}
+
+log_page_up :: proc(state: ^Editor_State) {
+	editor.log_view_move(state.log_data, -state.log_data.page_rows)
+}
+
+log_page_down :: proc(state: ^Editor_State) {
+	editor.log_view_move(state.log_data, state.log_data.page_rows)
+}
+
+log_first_line :: proc(state: ^Editor_State) {
+	editor.log_view_move(state.log_data, -len(state.log_data.rows))
+}
+
+log_scroll_left :: proc(state: ^Editor_State) {
+	editor.log_view_scroll(state.log_data, -8)
+}
+
+log_scroll_right :: proc(state: ^Editor_State) {
+	editor.log_view_scroll(state.log_data, 8)
+}
+
+@(private = "file")
+apply_log_filter :: proc(state: ^Editor_State, text: string) -> bool {
+	invert := strings.has_prefix(text, "!")
+	ok := editor.set_log_filter(state.log_data, strings.trim_prefix(text, "!"), invert)
+	request_redraw(state)
+	return ok
+}
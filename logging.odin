@@ -0,0 +1,167 @@
+package main
+
+import "core:fmt"
+import "core:log"
+import "core:os"
+import "core:path/filepath"
+import "core:slice"
+import "core:strings"
+import "core:sync"
+import "core:time"
+
+// Sessions whose logs are kept; older ones are deleted at startup.
+LOG_FILES_KEPT :: 10
+
+LOG_LEVEL_NAMES := #sparse[log.Level]string {
+	.Debug   = "debug",
+	.Info    = "info",
+	.Warning = "warning",
+	.Error   = "error",
+	.Fatal   = "fatal",
+}
+
+// Where context.logger writes: one file a session under
+// <user cache dir>/rune/logs, a line per message with its time, level and
+// source, "12:00:01.250 warning session.odin:119 session: ...".  Warnings and
+// errors go to stderr as well.  Messages start with the part of the editor
+// they come from, as "config:" or "servers:".
+Log_State :: struct {
+	level: log.Level, // lower levels are dropped
+	path:  string,
+	file:  ^os.File,
+	lock:  sync.Mutex, // background threads log too
+}
+
+// The GLFW callbacks start from a fresh context, see callback_context.
+@(private = "file")
+session_log: Log_State
+
+// Opens this session's log file.  Messages logged before still reach stderr.
+init_logging :: proc(level: log.Level) {
+	l := &session_log
+	l.level = level
+	cache, err := os.user_cache_dir(context.temp_allocator)
+	if err != nil {
+		return
+	}
+	dir := filepath.join({cache, "rune", "logs"}, context.temp_allocator)
+	_ = os.make_directory_all(dir)
+	prune_logs(dir)
+	// Zero padded so that the names sort by age.
+	name := fmt.tprintf("%012d-%d.log", time.to_unix_seconds(time.now()), os.get_pid())
+	l.path = filepath.join({dir, name})
+	file, ferr := os.create(l.path)
+	if ferr != nil {
+		fmt.eprintfln("log: %s: %v", l.path, ferr)
+		return
+	}
+	l.file = file
+}
+
+close_logging :: proc() {
+	l := &session_log
+	if l.file != nil {
+		os.close(l.file)
+	}
+	delete(l.path)
+	l^ = {}
+}
+
+// The logger for context.logger; the level is checked when writing, so
+// log.level takes effect in every context at once.
+session_logger :: proc "contextless" () -> log.Logger {
+	return {procedure = write_log_line, data = &session_log, lowest_level = .Debug}
+}
+
+// A log level by name, for --log-level and log.level.
+parse_log_level :: proc(name: string) -> (log.Level, bool) {
+	for level in log.Level {
+		if strings.equal_fold(LOG_LEVEL_NAMES[level], name) {
+			return level, true
+		}
+	}
+	return {}, false
+}
+
+// log.level: changes which messages are logged for the rest of the session.
+set_log_level :: proc(state: ^Editor_State) {
+	open_prompt(
+		state,
+		"Log level (debug, info, warning, error):",
+		on_submit = proc(state: ^Editor_State, text: string) {
+			level, ok := parse_log_level(strings.trim_space(text))
+			if !ok {
+				set_message(state, "Unknown log level %q", text)
+				return
+			}
+			sync.guard(&session_log.lock)
+			session_log.level = level
+			set_message(state, "Logging %s and above", LOG_LEVEL_NAMES[level])
+		},
+		initial = LOG_LEVEL_NAMES[session_log.level],
+	)
+}
+
+// log.open: opens this session's log file.
+open_log :: proc(state: ^Editor_State) {
+	if session_log.file == nil {
+		set_message(state, "There is no log file for this session")
+		return
+	}
+	open_file(state, session_log.path)
+}
+
+@(private = "file")
+write_log_line :: proc(
+	data: rawptr,
+	level: log.Level,
+	text: string,
+	options: log.Options,
+	location := #caller_location,
+) {
+	l := cast(^Log_State)data
+	sync.guard(&l.lock)
+	if level < l.level {
+		return
+	}
+	if level >= .Warning {
+		fmt.eprintln(text)
+	}
+	if l.file == nil {
+		return
+	}
+	now := time.now()
+	hour, minute, second := time.clock(now)
+	millis := time.to_unix_nanoseconds(now) / 1e6 % 1000
+	line := fmt.tprintf(
+		"%02d:%02d:%02d.%03d %-7s %s:%d %s\n",
+		hour,
+		minute,
+		second,
+		millis,
+		LOG_LEVEL_NAMES[level],
+		filepath.base(location.file_path),
+		location.line,
+		text,
+	)
+	os.write_string(l.file, line)
+}
+
+// Deletes all but the newest logs, leaving room for this session's.
+@(private = "file")
+prune_logs :: proc(dir: string) {
+	entries, err := os.read_all_directory_by_path(dir, context.temp_allocator)
+	if err != nil {
+		return
+	}
+	logs := make([dynamic]string, context.temp_allocator)
+	for fi in entries {
+		if fi.type == .Regular && strings.has_suffix(fi.name, ".log") {
+			append(&logs, fi.fullpath)
+		}
+	}
+	slice.sort(logs[:])
+	for path in logs[:max(len(logs) - (LOG_FILES_KEPT - 1), 0)] {
+		os.remove(path)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import "core:os"
+import "core:path/filepath"
+import "core:strings"
+import "core:time"
+import editor "editor"
+
+// Navigation from LSIF dumps, what a language server answered ahead of time,
+// for when none is running.  symbol.definition asks the workspace's dump
+// (index.file) first; a name it only imports is looked up by its moniker in
+// the dumps of other repositories (index.external).  Dumps are read when
+// first needed and again when they change.
+Lsif_State :: struct {
+	dumps: [dynamic]Lsif_Dump,
+}
+
+Lsif_Dump :: struct {
+	path:     string, // absolute
+	modified: time.Time,
+	index:    ^editor.Lsif_Index,
+}
+
+destroy_lsif :: proc(state: ^Editor_State) {
+	for d in state.lsif.dumps {
+		delete(d.path)
+		editor.destroy_lsif(d.index)
+	}
+	delete(state.lsif.dumps)
+}
+
+// symbol.definition's first try: true when a dump knows the name at the
+// cursor, whether or not it could go there.
+goto_lsif_definition :: proc(state: ^Editor_State) -> bool {
+	dumps := load_lsif_dumps(state)
+	r, dump, found := lsif_range_at_cursor(state, dumps)
+	if !found {
+		return false
+	}
+	if len(r.definitions) > 0 {
+		if !jump_to_lsif_location(state, dump, r.definitions[0]) {
+			return true
+		}
+		if len(r.definitions) > 1 {
+			set_message(state, "Definition 1 of %d", len(r.definitions))
+		}
+		return true
+	}
+	if r.moniker.identifier == "" {
+		return false
+	}
+	for &d in dumps {
+		if exported := editor.lsif_exported(d.index, r.moniker); len(exported) > 0 {
+			jump_to_lsif_location(state, &d, exported[0])
+			return true
+		}
+	}
+	set_message(state, "No dump in index.external exports %s", r.moniker.identifier)
+	return true
+}
+
+// symbol.moniker: says what the name at the cursor is called across
+// repositories, by the dumps.
+show_moniker :: proc(state: ^Editor_State) {
+	r, _, found := lsif_range_at_cursor(state, load_lsif_dumps(state))
+	if !found || r.moniker.identifier == "" {
+		set_message(state, "No moniker for the name at the cursor")
+		return
+	}
+	m := r.moniker
+	set_message(state, "%s:%s (%s)", m.scheme, m.identifier, m.kind if m.kind != "" else "local")
+}
+
+// Where the dumps say the name at the cursor is defined, or used with
+// references set, for peeking.  Temp allocated; none when no dump knows it.
+lsif_locations :: proc(state: ^Editor_State, references: bool) -> []Location {
+	dumps := load_lsif_dumps(state)
+	r, dump, found := lsif_range_at_cursor(state, dumps)
+	if !found {
+		return nil
+	}
+	from := dump
+	locations := r.references if references else r.definitions
+	if !references && len(locations) == 0 && r.moniker.identifier != "" {
+		for &d in dumps {
+			if exported := editor.lsif_exported(d.index, r.moniker); len(exported) > 0 {
+				from, locations = &d, exported
+				break
+			}
+		}
+	}
+	out := make([dynamic]Location, context.temp_allocator)
+	for loc in locations {
+		path := workspace_path(state, local_path(from, loc.path))
+		col := 0
+		if text, _, ok := definition_source(state, path); ok {
+			lines := strings.split_lines(text, context.temp_allocator)
+			if loc.start.line < len(lines) {
+				line := lines[loc.start.line]
+				col = editor.lsp_column(line, loc.start.character, from.index.encoding)
+			}
+		}
+		append(&out, Location{path, loc.start.line, col})
+	}
+	return out[:]
+}
+
+// The innermost range at the cursor, in the first dump that indexes the
+// buffer's file.
+@(private = "file")
+lsif_range_at_cursor :: proc(
+	state: ^Editor_State,
+	dumps: []Lsif_Dump,
+) -> (
+	r: editor.Lsif_Range,
+	dump: ^Lsif_Dump,
+	ok: bool,
+) {
+	if state.path == "" {
+		return {}, nil, false
+	}
+	full := workspace_path(state, state.path)
+	line := editor.get_line(&state.buffer, state.cursor_data.line, context.temp_allocator)
+	for &d in dumps {
+		character := editor.lsp_character(line, state.cursor_data.col, d.index.encoding)
+		pos := editor.Lsp_Position{state.cursor_data.line, character}
+		if r, ok = editor.lsif_range_at(d.index, dump_path(&d, full), pos); ok {
+			return r, &d, true
+		}
+	}
+	return {}, nil, false
+}
+
+@(private = "file")
+jump_to_lsif_location :: proc(
+	state: ^Editor_State,
+	dump: ^Lsif_Dump,
+	loc: editor.Lsif_Location,
+) -> bool {
+	if !jump_to_location(state, local_path(dump, loc.path), loc.start.line, 0) {
+		return false
+	}
+	line := editor.get_line(&state.buffer, state.cursor_data.line, context.temp_allocator)
+	col := editor.lsp_column(line, loc.start.character, dump.index.encoding)
+	goto_line_col(state, state.cursor_data.line, col)
+	return true
+}
+
+// A dump names files under the directory it was made in, its project root,
+// which need not be where the repository is checked out here: that is taken
+// to be the dump's own directory.  Temp allocated.
+@(private = "file")
+local_path :: proc(dump: ^Lsif_Dump, path: string) -> string {
+	root := dump.index.root
+	if root == "" || !strings.has_prefix(path, root) {
+		return path
+	}
+	rest := path[len(root):]
+	if !strings.has_prefix(rest, "/") && !strings.has_suffix(root, "/") {
+		return path // a sibling whose name starts with the root's
+	}
+	rest = strings.trim_prefix(rest, "/")
+	dir := filepath.dir(dump.path, context.temp_allocator)
+	return filepath.join({dir, rest}, context.temp_allocator)
+}
+
+// The reverse of local_path.  Temp allocated.
+@(private = "file")
+dump_path :: proc(dump: ^Lsif_Dump, full: string) -> string {
+	root := dump.index.root
+	dir := filepath.dir(dump.path, context.temp_allocator)
+	rel, err := filepath.rel(dir, full, context.temp_allocator)
+	if root == "" || err != nil || strings.has_prefix(rel, "..") {
+		return full
+	}
+	return strings.concatenate({strings.trim_suffix(root, "/"), "/", rel}, context.temp_allocator)
+}
+
+// The workspace's dump then the external ones, those that can be read.
+@(private = "file")
+load_lsif_dumps :: proc(state: ^Editor_State) -> []Lsif_Dump {
+	paths := make([dynamic]string, context.temp_allocator)
+	name := config_value(state, "index.file").(string) or_else ""
+	if name != "" {
+		append(&paths, workspace_path(state, name))
+	}
+	external := config_value(state, "index.external").(string) or_else ""
+	for p in strings.split(external, ";", context.temp_allocator) {
+		if trimmed := strings.trim_space(p); trimmed != "" {
+			append(&paths, workspace_path(state, trimmed))
+		}
+	}
+
+	l := &state.lsif
+	kept := make([dynamic]Lsif_Dump, 0, len(paths))
+	for path in paths {
+		fi, err := os.stat(path, context.temp_allocator)
+		if err != nil {
+			continue
+		}
+		at := -1
+		for d, i in l.dumps {
+			if d.path == path {
+				at = i
+			}
+		}
+		if at >= 0 && l.dumps[at].modified == fi.modification_time {
+			append(&kept, l.dumps[at])
+			unordered_remove(&l.dumps, at)
+			continue
+		}
+		data, rerr := os.read_entire_file_from_path(path, context.allocator)
+		if rerr != nil {
+			set_message(state, "Cannot read %s: %v", path, rerr)
+			continue
+		}
+		index := editor.parse_lsif(string(data))
+		delete(data)
+		append(&kept, Lsif_Dump{strings.clone(path), fi.modification_time, index})
+	}
+	// What is left was removed, changed or is no longer configured.
+	destroy_lsif(state)
+	l.dumps = kept
+	return l.dumps[:]
+}
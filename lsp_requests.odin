@@ -0,0 +1,28 @@
+package main
+
+import "core:encoding/json"
+import "core:fmt"
+import "core:strings"
+
+// A server gets lsp.initialization_options, from its [server.<name>] table,
+// as the initializationOptions of its initialize request.
+
+// The JSON a server is initialized with, "" for none, or why it cannot be
+// used.  Temp allocated.
+server_initialization_options :: proc(
+	state: ^Editor_State,
+	name: string,
+) -> (
+	options: string,
+	err: string,
+) {
+	text := server_config_value(state, name, "lsp.initialization_options").(string) or_else ""
+	text = strings.trim_space(text)
+	if text == "" {
+		return "", ""
+	}
+	if _, perr := json.parse_string(text, allocator = context.temp_allocator); perr != nil {
+		return "", fmt.tprintf("%s: lsp.initialization_options is not JSON: %v", name, perr)
+	}
+	return text, ""
+}
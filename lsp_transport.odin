@@ -0,0 +1,115 @@
+package main
+
+import "core:fmt"
+import "core:net"
+import "core:strings"
+
+// How a language server is reached, lsp.transport, which a [server.<name>]
+// table sets for one server.  Over stdio the server is the editor's child
+// and talks through its stdin and stdout.  tcp and pipe connect to a server
+// that listens already, e.g. in a remote container, at lsp.address.
+// lsp.check_connection tries the settings; the client is still to come.
+Server_Transport :: enum u8 {
+	Stdio,
+	Tcp,
+	Pipe, // a unix socket, or a named pipe on Windows
+}
+
+Server_Endpoint :: struct {
+	transport: Server_Transport,
+	address:   string, // host:port or the pipe's path, "" for stdio
+}
+
+// A connection to a server over tcp or pipe.
+Server_Connection :: struct {
+	transport: Server_Transport,
+	socket:    net.TCP_Socket, // Tcp
+	pipe:      Server_Pipe, // Pipe
+}
+
+// Where the settings say server name is.  Temp allocated.
+server_endpoint :: proc(state: ^Editor_State, name: string) -> (ep: Server_Endpoint, err: string) {
+	transport := server_config_value(state, name, "lsp.transport").(string) or_else "stdio"
+	address := server_config_value(state, name, "lsp.address").(string) or_else ""
+	switch transport {
+	case "tcp":
+		ep.transport = .Tcp
+		if address == "" {
+			return {}, fmt.tprintf("%s: tcp needs lsp.address", name)
+		}
+	case "pipe":
+		ep.transport = .Pipe
+		if address == "" {
+			return {}, fmt.tprintf("%s: pipe needs lsp.address", name)
+		}
+	case:
+		return {transport = .Stdio}, ""
+	}
+	ep.address = strings.clone(address, context.temp_allocator)
+	return ep, ""
+}
+
+// Connects to a server listening at ep; stdio servers are started instead.
+connect_server :: proc(ep: Server_Endpoint) -> (conn: Server_Connection, err: string) {
+	conn.transport = ep.transport
+	switch ep.transport {
+	case .Tcp:
+		socket, derr := net.dial_tcp_from_hostname_and_port_string(ep.address)
+		if derr != nil {
+			return {}, fmt.tprintf("%v", derr)
+		}
+		conn.socket = socket
+	case .Pipe:
+		conn.pipe, err = open_server_pipe(ep.address)
+		if err != "" {
+			return {}, err
+		}
+	case .Stdio:
+		return {}, "a stdio server is started, not connected to"
+	}
+	return conn, ""
+}
+
+close_server_connection :: proc(conn: ^Server_Connection) {
+	switch conn.transport {
+	case .Tcp:
+		net.close(conn.socket)
+	case .Pipe:
+		close_server_pipe(conn.pipe)
+	case .Stdio:
+	}
+	conn^ = {}
+}
+
+// lsp.check_connection: asks for a server's name and tries to reach it the
+// way its settings say, to check them before it is needed.
+check_server_connection :: proc(state: ^Editor_State) {
+	open_prompt(state, "Check server:", proc(state: ^Editor_State, name: string) {
+		name := strings.trim_space(name)
+		if name == "" {
+			return
+		}
+		ep, err := server_endpoint(state, name)
+		if err != "" {
+			set_message(state, "%s", err)
+			return
+		}
+		switch ep.transport {
+		case .Stdio:
+			if path, ok := server_binary(name); ok {
+				set_message(state, "%s runs over stdio as %s", name, path)
+			} else {
+				set_message(state, "%s runs over stdio but is not installed or on PATH", name)
+			}
+			return
+		case .Tcp, .Pipe:
+		}
+		conn, cerr := connect_server(ep)
+		if cerr != "" {
+			set_message(state, "Cannot reach %s at %s: %s", name, ep.address, cerr)
+			return
+		}
+		close_server_connection(&conn)
+		set_message(state, "%s is listening at %s", name, ep.address)
+	})
+}
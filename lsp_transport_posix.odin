@@ -0,0 +1,31 @@
+#+build !windows
+package main
+
+import "core:strings"
+import "core:sys/posix"
+
+// A unix socket.
+Server_Pipe :: posix.FD
+
+open_server_pipe :: proc(path: string) -> (Server_Pipe, string) {
+	addr: posix.sockaddr_un
+	if len(path) >= len(addr.sun_path) {
+		return -1, "the socket's path is too long"
+	}
+	addr.sun_family = .UNIX
+	copy(addr.sun_path[:], path)
+	fd := posix.socket(.UNIX, .STREAM)
+	if fd < 0 {
+		return -1, string(posix.strerror(posix.errno()))
+	}
+	if posix.connect(fd, (^posix.sockaddr)(&addr), size_of(addr)) != .OK {
+		err := strings.clone(string(posix.strerror(posix.errno())), context.temp_allocator)
+		posix.close(fd)
+		return -1, err
+	}
+	return fd, ""
+}
+
+close_server_pipe :: proc(p: Server_Pipe) {
+	posix.close(p)
+}
@@ -0,0 +1,19 @@
+package main
+
+import "core:fmt"
+import "core:os"
+
+// A named pipe, \\.\pipe\<name>, which opens like a file.
+Server_Pipe :: ^os.File
+
+open_server_pipe :: proc(path: string) -> (Server_Pipe, string) {
+	f, err := os.open(path, {.Read, .Write})
+	if err != nil {
+		return nil, fmt.tprintf("%v", err)
+	}
+	return f, ""
+}
+
+close_server_pipe :: proc(p: Server_Pipe) {
+	os.close(p)
+}
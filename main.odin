@@ -1,23 +1,194 @@
 package main
 
 import "core:fmt"
+import "core:hash"
+import "core:log"
 import "core:mem"
+import "core:os"
+import "core:path/filepath"
+import "core:strings"
+import "core:time"
 import editor "editor"
 import "vendor:glfw"
 import vk "vendor:vulkan"
 
 Editor_State :: struct {
-	render_ctx:     editor.Render_Context,
-	font:           editor.Font_Handle,
-	atlas:          editor.Glyph_Atlas,
-	batch:          editor.Batch_Renderer,
-	buffer:         editor.Gap_Buffer,
-	compositor:     editor.Compositer,
-	layer_ctx:      editor.Layer_Context,
-	cursor_data:    ^editor.Cursor_Layer_Data,
-	selection_data: ^editor.Selection_Layer_Data,
-	cursor_pos:     int,
-	preferred_col:  int, // sticky visual column for up/down movement
+	window:           glfw.WindowHandle,
+	render_ctx:       editor.Render_Context,
+	font:             editor.Font_Handle,
+	atlas:            editor.Glyph_Atlas,
+	batch:            editor.Batch_Renderer,
+	buffer:           editor.Gap_Buffer, // active document, see Document
+	undo:             editor.Undo_History, // edit history of buffer; buffer.undo points here
+	disk:             Disk_State, // buffer's file as last read or written
+	documents:        [dynamic]Document,
+	active:           int, // index of the active document's slot
+	doc_id:           int, // Document.id of the active document
+	next_doc_id:      int,
+	compositor:       editor.Compositer,
+	layer_ctx:        editor.Layer_Context,
+	cursor_data:      ^editor.Cursor_Layer_Data,
+	cursor_line_data: ^editor.Cursor_Line_Layer_Data,
+	selection_data:   ^editor.Selection_Layer_Data,
+	text_data:        ^editor.Text_Layer_Data,
+	ruler_data:       ^editor.Ruler_Layer_Data,
+	gutter_data:      ^editor.Line_Number_Layer_Data,
+	virtual_text:     ^editor.Virtual_Text_Layer_Data,
+	ghost_text:       ^editor.Ghost_Text_Layer_Data,
+	status_data:      ^editor.Status_Line_Data,
+	panel_data:       ^editor.Panel_Layer_Data,
+	completion_data:  ^editor.Completion_Layer_Data,
+	which_key_data:   ^editor.Which_Key_Layer_Data,
+	swatch_data:      ^editor.Color_Swatch_Layer_Data,
+	breadcrumb_data:  ^editor.Breadcrumb_Bar_Data,
+	image_data:       ^editor.Image_Layer_Data,
+	peer_cursor_data: ^editor.Peer_Cursor_Layer_Data,
+	diff_data:        ^editor.Diff_View_Data,
+	csv_data:         ^editor.Csv_View_Data,
+	log_data:         ^editor.Log_View_Data,
+	docs_data:        ^editor.Docs_View_Data,
+	peek_data:        ^editor.Peek_View_Data,
+	line_height:      f32,
+	cursor_pos:       int,
+	preferred_col:    int, // sticky visual column for up/down movement
+	scroll_target:    f32, // where smooth scrolling heads; layer_ctx.scroll_y once there
+	scroll_tick:      time.Tick, // the last smooth scrolling step
+	selection_anchor: int, // byte position where the selection started, -1 for none
+	selection_buf:    [1]editor.Selection, // backing store for selection_data.selections
+	expansions:       [dynamic][2]int, // select.expand's ranges, the current one last
+	path:             string, // file backing the buffer, empty for an unnamed buffer
+	keymap:           editor.Keymap,
+	commands:         map[string]Command,
+	mode:             string, // keymap mode, e.g. "editor"
+	language:         string, // language id, see editor.LANGUAGES
+	preview:          bool, // only the start of a huge file was loaded, see open_file
+	scratch:          bool, // an untitled buffer kept in the session, see scratch.odin
+	degraded:         Degraded_Features, // off for the active buffer's size
+	suppress_char:    bool, // set when the last key event was consumed by the keymap
+	prompt:           Prompt,
+	message:          strings.Builder, // transient status line message
+	workspace_root:   string, // the project root, see find_project_root
+	search:           Search_State,
+	replace:          Replace_State,
+	jumps:            Jump_List,
+	bookmarks:        [dynamic]Bookmark,
+	goto_origin:      Goto_State,
+	last_disk_check:  time.Tick,
+	recovery:         Recovery_State,
+	project:          Project_Settings,
+	git_changes:      Git_Changes,
+	blame:            Blame_State,
+	git_status:       Git_Status_State,
+	tasks:            Task_State,
+	test_explorer:    Test_Explorer_State,
+	config:           Config_State,
+	plugins:          Plugin_State,
+	servers:          Server_Install_State,
+	filter:           Filter_State,
+	diagnostics:      Diagnostics_State,
+	lint:             Lint_State,
+	clipboard:        Clipboard_State,
+	registers:        Register_State,
+	projects:         Project_State,
+	language_list:    Language_List_State,
+	index:            Index_State,
+	startup:          Startup_State,
+	frames:           Frame_Scheduler,
+	memory:           Memory_State,
+	metrics:          Metrics_State,
+	crash:            Crash_State,
+	help:             Help_State,
+	which_key:        Which_Key_State,
+	unicode:          Unicode_State,
+	zen:              Zen_State,
+	todos:            Todo_State,
+	colors:           Color_State,
+	image:            Image_State,
+	completion:       Completion_State,
+	mouse:            Mouse_State,
+	remote:           Remote_State,
+	collab:           Collab_State,
+	abbreviations:    [dynamic]Abbreviation, // the user's, see abbreviations.odin
+	diff:             Diff_State,
+	csv_view:         Csv_View_State,
+	log_view:         Log_View_State,
+	history:          File_History_State,
+	root_save:        Root_Save_State,
+	tags:             Tags_State,
+	quickfix:         Quickfix_State,
+	snippet:          Snippet_State,
+	docs:             Docs_State,
+	json_tools:       Json_Tools_State,
+	workspace_edit:   Workspace_Edit_State,
+	suggestion:       Inline_Completion_State,
+	lsif:             Lsif_State,
+	notebook:         Notebook_State,
+	breadcrumbs:      Breadcrumb_State,
+	peek:             Peek_State,
+	wait_docs:        [dynamic]int, // opened with --wait, see check_wait
+}
+
+// Returns <user config dir>/rune/<name>, allocated with the temp allocator.
+user_config_path :: proc(name: string) -> (path: string, ok: bool) {
+	dir, err := os.user_config_dir(context.temp_allocator)
+	if err != nil {
+		return "", false
+	}
+	return filepath.join({dir, "rune", name}, context.temp_allocator), true
+}
+
+// Returns <user config dir>/rune/workspaces/<hash of the workspace root>/<name>
+// for state kept per project, allocated with the temp allocator.
+workspace_state_path :: proc(state: ^Editor_State, name: string) -> (path: string, ok: bool) {
+	dir, err := os.user_config_dir(context.temp_allocator)
+	if err != nil {
+		return "", false
+	}
+	key := fmt.tprintf("%016x", hash.fnv64a(transmute([]u8)state.workspace_root))
+	return filepath.join({dir, "rune", "workspaces", key, name}, context.temp_allocator), true
+}
+
+// Shows a message in the status line until the next key press.
+set_message :: proc(state: ^Editor_State, format: string, args: ..any) {
+	strings.builder_reset(&state.message)
+	fmt.sbprintf(&state.message, format, ..args)
+	update_status_line(state)
+}
+
+update_status_line :: proc(state: ^Editor_State) {
+	request_redraw(state)
+	left := strings.to_string(state.message)
+	if state.prompt.active {
+		left = prompt_status(state)
+	} else if editor.has_pending_keys(&state.keymap) {
+		left = fmt.tprintf("%s -", editor.format_key_sequence(state.keymap.pending))
+	}
+	right := fmt.tprintf(
+		"%s%s%s%s%s  %s  %s  Ln %d, Col %d",
+		document_title(state.path),
+		" +" if editor.is_modified(&state.undo) else "",
+		view_label(state),
+		degradation_label(state),
+		breadcrumb_label(state),
+		editor.ENCODING_NAMES[state.disk.encoding],
+		editor.LINE_ENDING_NAMES[state.disk.line_ending],
+		state.cursor_data.line + 1,
+		state.cursor_data.col + 1,
+	)
+	editor.set_status_line(state.status_data, left, right)
+}
+
+@(private = "file")
+view_label :: proc(state: ^Editor_State) -> string {
+	switch {
+	case state.image_data.visible:
+		return "  [image]"
+	case state.disk.view == .Hex:
+		return "  [hex]" if !state.preview else "  [hex preview]"
+	case state.preview:
+		return "  [preview]"
+	}
+	return ""
 }
 
 init_editor :: proc(
@@ -27,30 +198,33 @@ init_editor :: proc(
 	font_size: f32,
 	allocator: mem.Allocator = context.allocator,
 ) -> bool {
+	state.window = window
 	ok: bool
 	state.render_ctx, ok = editor.init_vulkan(window, allocator)
 	if !ok {
-		fmt.eprintln("Failed to init Vulkan")
+		log.error("Failed to init Vulkan")
 		return false
 	}
+	startup_phase(state, "vulkan")
 
 	state.font, ok = editor.load_font(font_path, font_size, allocator)
 	if !ok {
-		fmt.eprintln("Failed to load font:", font_path)
+		log.error("Failed to load font:", font_path)
 		return false
 	}
 
 	state.atlas, ok = editor.init_glyph_atlas(&state.render_ctx, allocator)
 	if !ok {
-		fmt.eprintln("Failed to init glyph atlas")
+		log.error("Failed to init glyph atlas")
 		return false
 	}
 	editor.precache_ascii(&state.atlas, &state.font)
 	editor.flush_atlas(&state.render_ctx, &state.atlas)
+	startup_phase(state, "font and glyph atlas")
 
 	state.batch, ok = editor.init_batch_renderer(&state.render_ctx, allocator)
 	if !ok {
-		fmt.eprintln("Failed to init renderer")
+		log.error("Failed to init renderer")
 		return false
 	}
 
@@ -60,8 +234,6 @@ init_editor :: proc(
 		&state.atlas.image,
 	)
 
-	state.buffer = editor.init_gap_buffer(allocator)
-
 	w, h := glfw.GetFramebufferSize(window)
 	state.layer_ctx = editor.Layer_Context {
 		viewport = {f32(w), f32(h)},
@@ -80,6 +252,12 @@ init_editor :: proc(
 
 	editor.add_layer(c, editor.make_background_layer({0.12, 0.12, 0.14, 1.0}, allocator))
 
+	rulers := editor.add_layer(
+		c,
+		editor.make_ruler_layer(char_width, text_padding, {0.20, 0.20, 0.23, 1.0}, allocator),
+	)
+	state.ruler_data = cast(^editor.Ruler_Layer_Data)rulers.user_data
+
 	sel := editor.add_layer(
 		c,
 		editor.make_selection_layer(
@@ -92,7 +270,7 @@ init_editor :: proc(
 	)
 	state.selection_data = cast(^editor.Selection_Layer_Data)sel.user_data
 
-	editor.add_layer(
+	text := editor.add_layer(
 		c,
 		editor.make_text_layer(
 			&state.buffer,
@@ -103,6 +281,21 @@ init_editor :: proc(
 			allocator,
 		),
 	)
+	state.text_data = cast(^editor.Text_Layer_Data)text.user_data
+	state.text_data.dim_color = {0.42, 0.42, 0.45, 1.0}
+
+	virtual := editor.add_layer(
+		c,
+		editor.make_virtual_text_layer(
+			&state.buffer,
+			&state.font,
+			{0.50, 0.50, 0.55, 1.0},
+			line_height,
+			text_padding,
+			allocator,
+		),
+	)
+	state.virtual_text = cast(^editor.Virtual_Text_Layer_Data)virtual.user_data
 
 	cur := editor.add_layer(
 		c,
@@ -117,7 +310,47 @@ init_editor :: proc(
 	)
 	state.cursor_data = cast(^editor.Cursor_Layer_Data)cur.user_data
 
-	editor.add_layer(
+	cursor_line := editor.add_layer(
+		c,
+		editor.make_cursor_line_layer(
+			state.cursor_data,
+			{0.15, 0.15, 0.18, 1.0},
+			{0.15, 0.15, 0.18, 1.0},
+			allocator,
+		),
+	)
+	state.cursor_line_data = cast(^editor.Cursor_Line_Layer_Data)cursor_line.user_data
+
+	swatches := editor.add_layer(
+		c,
+		editor.make_color_swatch_layer(&state.buffer, state.cursor_data, allocator),
+	)
+	state.swatch_data = cast(^editor.Color_Swatch_Layer_Data)swatches.user_data
+
+	ghost := editor.add_layer(
+		c,
+		editor.make_ghost_text_layer(
+			state.cursor_data,
+			&state.font,
+			{0.50, 0.50, 0.55, 1.0},
+			allocator,
+		),
+	)
+	state.ghost_text = cast(^editor.Ghost_Text_Layer_Data)ghost.user_data
+
+	peers := editor.add_layer(
+		c,
+		editor.make_peer_cursor_layer(
+			&state.font,
+			line_height,
+			char_width,
+			text_padding,
+			allocator,
+		),
+	)
+	state.peer_cursor_data = cast(^editor.Peer_Cursor_Layer_Data)peers.user_data
+
+	gutter := editor.add_layer(
 		c,
 		editor.make_line_number_layer(
 			&state.buffer,
@@ -130,20 +363,238 @@ init_editor :: proc(
 			allocator,
 		),
 	)
+	state.gutter_data = cast(^editor.Line_Number_Layer_Data)gutter.user_data
+	state.gutter_data.cursor = state.cursor_data
+	state.gutter_data.current_color = {0.80, 0.80, 0.78, 1.0}
+
+	status := editor.add_layer(
+		c,
+		editor.make_status_line_layer(
+			&state.font,
+			line_height,
+			8,
+			{0.75, 0.75, 0.78, 1.0},
+			{0.16, 0.16, 0.19, 1.0},
+			allocator,
+		),
+	)
+	state.status_data = cast(^editor.Status_Line_Data)status.user_data
+
+	breadcrumbs := editor.add_layer(
+		c,
+		editor.make_breadcrumb_layer(
+			&state.font,
+			line_height,
+			8,
+			{0.75, 0.75, 0.78, 1.0},
+			{0.50, 0.50, 0.55, 1.0},
+			{0.14, 0.14, 0.17, 1.0},
+			allocator,
+		),
+	)
+	state.breadcrumb_data = cast(^editor.Breadcrumb_Bar_Data)breadcrumbs.user_data
+
+	panel := editor.add_layer(
+		c,
+		editor.make_panel_layer(&state.font, line_height, line_height, 12, allocator),
+	)
+	state.panel_data = cast(^editor.Panel_Layer_Data)panel.user_data
+
+	completion := editor.add_layer(
+		c,
+		editor.make_completion_layer(
+			&state.font,
+			line_height,
+			char_width,
+			text_padding,
+			10,
+			allocator,
+		),
+	)
+	state.completion_data = cast(^editor.Completion_Layer_Data)completion.user_data
+
+	which_key := editor.add_layer(
+		c,
+		editor.make_which_key_layer(&state.font, line_height, char_width, allocator),
+	)
+	state.which_key_data = cast(^editor.Which_Key_Layer_Data)which_key.user_data
+
+	diff := editor.add_layer(
+		c,
+		editor.make_diff_view_layer(&state.font, line_height, line_height, allocator),
+	)
+	state.diff_data = cast(^editor.Diff_View_Data)diff.user_data
+
+	csv := editor.add_layer(
+		c,
+		editor.make_csv_view_layer(&state.font, line_height, line_height, allocator),
+	)
+	state.csv_data = cast(^editor.Csv_View_Data)csv.user_data
+
+	log_view := editor.add_layer(
+		c,
+		editor.make_log_view_layer(&state.font, line_height, line_height, allocator),
+	)
+	state.log_data = cast(^editor.Log_View_Data)log_view.user_data
+
+	docs := editor.add_layer(
+		c,
+		editor.make_docs_view_layer(&state.font, line_height, line_height, allocator),
+	)
+	state.docs_data = cast(^editor.Docs_View_Data)docs.user_data
+
+	peek := editor.add_layer(
+		c,
+		editor.make_peek_view_layer(&state.font, state.cursor_data, line_height, allocator),
+	)
+	state.peek_data = cast(^editor.Peek_View_Data)peek.user_data
+
+	image := editor.add_layer(
+		c,
+		editor.make_image_layer(&state.font, line_height, line_height, allocator),
+	)
+	state.image_data = cast(^editor.Image_Layer_Data)image.user_data
+	state.line_height = line_height
+	startup_phase(state, "renderer and layers")
+
+	if cwd, err := os.get_working_directory(allocator); err == nil {
+		state.workspace_root = cwd
+	} else {
+		state.workspace_root = strings.clone(".", allocator)
+	}
+	state.prompt.input = strings.builder_make(allocator)
+	state.message = strings.builder_make(allocator)
+
+	state.mode = "editor"
+	state.commands = make(map[string]Command, allocator = allocator)
+	state.keymap = editor.init_keymap(allocator)
+	register_builtin_commands(state)
+	load_keymaps(state)
+	init_clipboard(state, window)
+	init_documents(state)
+	startup_phase(state, "commands and keymaps")
+	load_project_settings(state)
+	load_config(state)
+	load_abbreviations(state)
+	load_server_records(state)
+	load_bookmarks(state)
+	init_recovery(state)
+	init_git_changes(state)
+	startup_phase(state, "settings")
+	// Plugins load after the first frame, see finish_startup.
 
 	return true
 }
 
 destroy_editor :: proc(state: ^Editor_State) {
 	vk.DeviceWaitIdle(state.render_ctx.device)
+	close_remote(state)
+	destroy_collab(state)
+	destroy_plugins(state)
+	stop_project_search(state)
+	stop_todo_scan(state)
+	destroy_git_changes(state)
+	destroy_blame(state)
+	destroy_git_status(state)
+	destroy_tasks(state)
+	destroy_filter(state)
+	destroy_lint(state)
+	destroy_clipboard(state)
+	destroy_registers(state)
+	destroy_abbreviations(state)
+	destroy_diagnostics(state)
+	destroy_test_explorer(state)
+	destroy_diff(state)
+	destroy_replace(state)
+	destroy_indexes(state)
+	destroy_metrics(state)
+	destroy_crash_handler(state)
+	destroy_help(state)
+	destroy_unicode(state)
+	destroy_jump_list(&state.jumps)
+	destroy_bookmarks(state)
+	destroy_recovery(state)
+	destroy_project_settings(state)
+	destroy_config(state)
+	destroy_server_records(state)
+	delete(state.search.pattern)
+	delete(state.workspace_root)
+	destroy_workspace_folders(state)
+	delete(state.projects.folders)
+	delete(state.wait_docs)
+	strings.builder_destroy(&state.prompt.input)
+	strings.builder_destroy(&state.message)
+	destroy_image_view(state)
+	destroy_json_tools(state)
+	destroy_log_view(state)
+	destroy_file_history(state)
+	destroy_root_save(state)
+	destroy_tags(state)
+	destroy_quickfix(state)
+	destroy_docs(state)
+	destroy_peek(state)
+	destroy_snippet(state)
+	destroy_workspace_edit(state)
+	destroy_inline_completion(state)
+	destroy_lsif(state)
+	destroy_notebook(state)
+	destroy_breadcrumbs(state)
 	editor.destroy_compositor(&state.compositor)
-	editor.destroy_gap_buffer(&state.buffer)
+	editor.destroy_keymap(&state.keymap)
+	delete(state.commands)
+	delete(state.expansions)
+	destroy_documents(state)
 	editor.destroy_batch_renderer(&state.render_ctx, &state.batch)
 	//editor.destroy_glyph_atlas(&state.render_ctx, &state.atlas)
 	editor.destroy_font(&state.font)
 	editor.destroy_vulkan(&state.render_ctx)
 }
 
+// Per-frame housekeeping for background work.
+tick_editor :: proc(state: ^Editor_State) {
+	start := time.tick_now()
+	poll_project_search(state)
+	poll_todo_scan(state)
+	update_degradation(state)
+	update_smooth_scroll(state)
+	poll_remote(state)
+	poll_collab(state)
+	check_wait(state)
+	update_which_key(state)
+	update_zen_mode(state)
+	update_color_swatches(state)
+	update_image_view(state)
+	update_log_view(state)
+	update_docs(state)
+	update_peek(state)
+	update_snippet(state)
+	update_inline_completion(state)
+	update_notebook(state)
+	update_breadcrumbs(state)
+	update_metrics_endpoint(state)
+	record_metric(state, .Tick, start)
+	// The rest holds off while keys arrive, see background_due.
+	if !state.startup.ready || !background_due(state) {
+		return
+	}
+	start = time.tick_now()
+	defer record_metric(state, .Background, start)
+	check_disk_changes(state)
+	check_config_changes(state)
+	write_recovery_snapshots(state)
+	update_indexes(state)
+	update_git_changes(state)
+	update_blame(state)
+	update_git_status(state)
+	update_tasks(state)
+	update_filter(state)
+	update_root_save(state)
+	update_tags(state)
+	update_linters(state)
+	update_test_explorer(state)
+	enforce_memory_budget(state)
+}
+
 draw_frame :: proc(state: ^Editor_State) -> bool {
 	ctx := &state.render_ctx
 	fi := ctx.frame_index
@@ -239,8 +690,67 @@ draw_frame :: proc(state: ^Editor_State) -> bool {
 }
 
 main :: proc() {
+	// rune [--gui] [--restore] [--remote] [--wait] [--profile-startup]
+	//      [--log-level=LEVEL] [files...].
+	// rune --batch=SCRIPT [--batch-command=LINE]... files...
+	// Without files the workspace's previous session comes back; --restore
+	// brings back the last session anywhere.  --remote hands the files to a
+	// running instance when there is one.  --wait does too, and returns once
+	// their buffers are closed there, or closes its own window then, for
+	// $GIT_EDITOR.  Files may name a position, see parse_open_targets, and
+	// "-" reads stdin.  --profile-startup prints how long each part of
+	// startup took.  --log-level is debug, info (the default), warning or
+	// error.  The window is the only frontend, so --gui changes nothing.
+	// --batch edits the files without a window, see run_batch.
+	began := time.tick_now()
+	restore, remote, wait, profile := false, false, false, false
+	log_level := log.Level.Info
+	batch, batch_script := false, ""
+	batch_commands := make([dynamic]string, context.temp_allocator)
+	files := make([dynamic]string, context.temp_allocator)
+	for arg in os.args[1:] {
+		switch {
+		case arg == "--gui":
+		case arg == "--restore":
+			restore = true
+		case arg == "--remote":
+			remote = true
+		case arg == "--wait":
+			wait = true
+		case arg == "--profile-startup":
+			profile = true
+		case strings.has_prefix(arg, "--log-level="):
+			name := strings.trim_prefix(arg, "--log-level=")
+			level, ok := parse_log_level(name)
+			if !ok {
+				fmt.eprintfln("rune: unknown log level %q", name)
+				return
+			}
+			log_level = level
+		case strings.has_prefix(arg, "--batch="):
+			batch, batch_script = true, strings.trim_prefix(arg, "--batch=")
+		case strings.has_prefix(arg, "--batch-command="):
+			batch = true
+			append(&batch_commands, strings.trim_prefix(arg, "--batch-command="))
+		case:
+			append(&files, arg)
+		}
+	}
+	targets := parse_open_targets(files[:])
+	if (remote || wait) && send_remote(targets, wait) {
+		return
+	}
+	init_logging(log_level)
+	defer close_logging()
+	context.logger = session_logger()
+	if batch {
+		status := run_batch(batch_script, batch_commands[:], files[:])
+		close_logging()
+		os.exit(status)
+	}
+
 	if !glfw.Init() {
-		fmt.eprintln("Failed to init GLFW")
+		log.error("Failed to init GLFW")
 		return
 	}
 	defer glfw.Terminate()
@@ -248,36 +758,96 @@ main :: proc() {
 	glfw.WindowHint(glfw.CLIENT_API, glfw.NO_API)
 	window := glfw.CreateWindow(1280, 800, "Editor", nil, nil)
 	if window == nil {
-		fmt.eprintln("Failed to create window")
+		log.error("Failed to create window")
 		return
 	}
 	defer glfw.DestroyWindow(window)
 
+	// The font is rasterized in framebuffer pixels, so it follows the
+	// monitor's scale, fractional ones included.
+	scale, _ := glfw.GetWindowContentScale(window)
 	state: Editor_State
-	if !init_editor(&state, window, "assets/fonts/ComicMono.ttf", 16) {return}
+	begin_startup(&state, began, profile)
+	init_metrics(&state)
+	init_crash_handler(&state)
+	context.assertion_failure_proc = crash_assertion_failure
+	startup_phase(&state, "window")
+	if !init_editor(&state, window, "assets/fonts/ComicMono.ttf", 16 * max(scale, 1)) {return}
 	defer destroy_editor(&state)
 
-	// Seed some initial content and place the cursor at the end of it.
-	hello := "Hello, Editor!\nType something here.\n"
-	editor.insert_bytes(&state.buffer, transmute([]u8)string(hello))
-	state.cursor_pos = editor.current_length(&state.buffer)
-	sync_cursor(&state)
+	// Files are opened from the project root, found from the first file or
+	// the working directory.
+	for &t in targets {
+		if t.path == "-" {
+			continue
+		}
+		if full, err := filepath.abs(t.path, context.temp_allocator); err == nil {
+			t.path = full
+		}
+	}
+	first := targets[0].path if len(targets) > 0 else "."
+	root := find_project_root(first if first != "-" else ".")
+	if root != "" && root != state.workspace_root {
+		set_workspace(&state, root)
+	} else {
+		note_recent_project(&state)
+	}
+	startup_phase(&state, "workspace")
+
+	restored := false
+	if restore {
+		restored = restore_last_session(&state, window)
+	} else if len(files) == 0 {
+		restored = restore_workspace_session(&state, window)
+	}
+	for t in targets {
+		if open_target(&state, t) && wait {
+			append(&state.wait_docs, state.doc_id)
+		}
+	}
+	if !restored && len(files) == 0 {
+		// Seed some initial content and place the cursor at the end of it.
+		hello := "Hello, Editor!\nType something here.\n"
+		editor.insert_bytes(&state.buffer, transmute([]u8)string(hello))
+		editor.clear_undo_history(&state.undo)
+		state.cursor_pos = editor.current_length(&state.buffer)
+		sync_cursor(&state)
+		offer_recent_files(&state)
+	}
+	offer_recovery(&state)
+	startup_phase(&state, "session and files")
 
 	// Register input callbacks; the state pointer is retrieved inside each callback.
 	glfw.SetWindowUserPointer(window, &state)
 	glfw.SetCharCallback(window, char_callback)
 	glfw.SetKeyCallback(window, key_callback)
+	init_mouse(window)
+	init_frame_scheduler(&state, window)
+	listen_remote(&state)
 
 	for !glfw.WindowShouldClose(window) {
-		glfw.PollEvents()
+		wait_for_frame(&state)
+		defer free_all(context.temp_allocator)
+		tick_editor(&state)
+		if !frame_due(&state) {
+			continue
+		}
 
-		if !draw_frame(&state) {
+		drawing := time.tick_now()
+		if draw_frame(&state) {
+			record_metric(&state, .Render, drawing)
+			frame_drawn(&state)
+		} else {
 			w, h := glfw.GetFramebufferSize(window)
 			editor.recreate_swapchain(&state.render_ctx, u32(w), u32(h))
 			state.layer_ctx.viewport = {f32(w), f32(h)}
 			editor.notify_resize(&state.compositor, state.layer_ctx.viewport)
 		}
+		if !state.startup.ready {
+			finish_startup(&state)
+		}
 	}
 
+	save_session(&state, window)
 	vk.DeviceWaitIdle(state.render_ctx.device)
 }
@@ -0,0 +1,159 @@
+package main
+
+import "core:fmt"
+import "core:mem"
+import "core:slice"
+import "core:time"
+import editor "editor"
+
+MEMORY_CHECK_INTERVAL :: time.Second
+
+// What the open buffers hold, checked against memory.budget.  Over it, the
+// buffers not shown longest give up their word indexes, which are rebuilt
+// when they are shown again, and then their undo history.
+Memory_State :: struct {
+	last_check: time.Tick,
+}
+
+// One buffer's share.
+Buffer_Memory :: struct {
+	doc:    int, // index into state.documents
+	title:  string,
+	text:   int,
+	undo:   int,
+	index:  int,
+	parked: time.Tick, // when it was last shown; zero for the active one
+}
+
+// The buffers' memory, largest first.  Temp allocated.
+buffer_memory :: proc(state: ^Editor_State) -> []Buffer_Memory {
+	list := make([dynamic]Buffer_Memory, context.temp_allocator)
+	for &d, i in state.documents {
+		m := Buffer_Memory{doc = i}
+		id := d.id
+		if i == state.active {
+			id = state.doc_id
+			m.title = document_title(state.path)
+			m.text = editor.gap_buffer_size(&state.buffer) + len(state.disk.text)
+			m.undo = editor.undo_history_size(&state.undo)
+		} else {
+			m.title = document_title(d.path)
+			m.text = editor.gap_buffer_size(&d.buffer) + len(d.disk.text)
+			m.undo = editor.undo_history_size(&d.undo)
+			m.parked = d.parked
+		}
+		if di, ok := state.index.docs[id]; ok {
+			if di.ready != nil {
+				m.index += editor.buffer_index_size(di.ready)
+			}
+			if di.job != nil {
+				m.index += editor.buffer_index_size(di.job)
+			}
+		}
+		append(&list, m)
+	}
+	slice.sort_by(list[:], proc(a, b: Buffer_Memory) -> bool {
+		return a.text + a.undo + a.index > b.text + b.undo + b.index
+	})
+	return list[:]
+}
+
+// Frees what the background buffers can spare while the total is over
+// memory.budget.  Called from the background ticks.
+enforce_memory_budget :: proc(state: ^Editor_State) {
+	m := &state.memory
+	if time.tick_since(m.last_check) < MEMORY_CHECK_INTERVAL {
+		return
+	}
+	m.last_check = time.tick_now()
+	budget := config_int(state, "memory.budget") * mem.Megabyte
+	list := buffer_memory(state)
+	total := 0
+	for b in list {
+		total += b.text + b.undo + b.index
+	}
+	if total <= budget {
+		return
+	}
+
+	// Least recently shown first; the active buffer keeps everything.
+	slice.sort_by(list, proc(a, b: Buffer_Memory) -> bool {
+		return time.tick_diff(a.parked, b.parked) > 0
+	})
+	freed := 0
+	for pass in 0 ..< 2 {
+		for b in list {
+			if total <= budget {
+				break
+			}
+			if b.doc == state.active {
+				continue
+			}
+			d := &state.documents[b.doc]
+			if pass == 0 && b.index > 0 {
+				evict_index(state, d.id)
+				total -= b.index
+				freed += b.index
+			} else if pass == 1 && b.undo > 0 {
+				editor.forget_undo_history(&d.undo)
+				total -= b.undo
+				freed += b.undo
+			}
+		}
+	}
+	if freed > 0 {
+		set_message(
+			state,
+			"Over the %d MB memory budget; freed %s from background buffers",
+			budget / mem.Megabyte,
+			format_size(freed),
+		)
+	}
+}
+
+// memory.show: lists what each buffer holds against memory.budget.
+show_memory :: proc(state: ^Editor_State) {
+	list := buffer_memory(state)
+	total := 0
+	for b in list {
+		total += b.text + b.undo + b.index
+	}
+	clear_replace(state)
+	stop_project_search(state)
+	release_panel(state)
+	panel := state.panel_data
+	editor.panel_clear(panel)
+	title := fmt.tprintf(
+		"Buffers hold %s of the %d MB budget",
+		format_size(total),
+		config_int(state, "memory.budget"),
+	)
+	editor.panel_set_title(panel, title)
+	for b in list {
+		marker := "*" if b.doc == state.active else " "
+		text := fmt.tprintf(
+			"%s %-24s %10s  text %10s  undo %10s  index %10s",
+			marker,
+			b.title,
+			format_size(b.text + b.undo + b.index),
+			format_size(b.text),
+			format_size(b.undo),
+			format_size(b.index),
+		)
+		editor.panel_add_item(panel, {text = text, line = -1})
+	}
+	show_panel(state)
+}
+
+// Bytes for people: 512 B, 3.4 KB, 12.0 MB.  Temp allocated.
+format_size :: proc(bytes: int) -> string {
+	switch {
+	case bytes >= mem.Gigabyte:
+		return fmt.tprintf("%.1f GB", f64(bytes) / mem.Gigabyte)
+	case bytes >= mem.Megabyte:
+		return fmt.tprintf("%.1f MB", f64(bytes) / mem.Megabyte)
+	case bytes >= mem.Kilobyte:
+		return fmt.tprintf("%.1f KB", f64(bytes) / mem.Kilobyte)
+	}
+	return fmt.tprintf("%d B", bytes)
+}
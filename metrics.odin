@@ -0,0 +1,243 @@
+package main
+
+import "core:fmt"
+import "core:net"
+import "core:slice"
+import "core:strings"
+import "core:time"
+import editor "editor"
+
+// The latest timings of each kind kept for the percentiles.
+METRIC_SAMPLES :: 256
+// Commands listed in the report, slowest in total first.
+METRIC_TOP_COMMANDS :: 20
+
+Metric :: enum u8 {
+	Render, // recording and submitting a frame
+	Tick, // the per-frame housekeeping
+	Background, // the background jobs, when they run
+	Input, // handling one key or character event
+}
+
+METRIC_NAMES := [Metric]string {
+	.Render     = "render",
+	.Tick       = "tick",
+	.Background = "background",
+	.Input      = "input",
+}
+
+Metric_Stats :: struct {
+	count:  int,
+	total:  time.Duration,
+	max:    time.Duration,
+	recent: [METRIC_SAMPLES]time.Duration, // ring, count % METRIC_SAMPLES is next
+}
+
+// How long the editor spends drawing, on housekeeping, on input and in each
+// command, for metrics.show and, when metrics.port is set, for
+// http://127.0.0.1:<port>/ so a slow session can be reported with numbers.
+Metrics_State :: struct {
+	stats:     [Metric]Metric_Stats,
+	commands:  map[string]Metric_Stats, // by command name
+	started:   time.Tick,
+	port:      int, // metrics.port as last seen
+	listening: bool,
+	listener:  net.TCP_Socket,
+	clients:   [dynamic]Metrics_Client,
+}
+
+// A connection to the endpoint, answered once its request has arrived.
+Metrics_Client :: struct {
+	socket:  net.TCP_Socket,
+	request: [dynamic]u8,
+}
+
+init_metrics :: proc(state: ^Editor_State) {
+	state.metrics.started = time.tick_now()
+}
+
+destroy_metrics :: proc(state: ^Editor_State) {
+	m := &state.metrics
+	close_metrics_endpoint(m)
+	for name in m.commands {
+		delete(name)
+	}
+	delete(m.commands)
+	delete(m.clients)
+}
+
+// Records how long since start something of kind took.
+record_metric :: proc(state: ^Editor_State, kind: Metric, start: time.Tick) {
+	add_sample(&state.metrics.stats[kind], time.tick_since(start))
+}
+
+record_command_metric :: proc(state: ^Editor_State, name: string, start: time.Tick) {
+	m := &state.metrics
+	if name not_in m.commands {
+		m.commands[strings.clone(name)] = {}
+	}
+	add_sample(&m.commands[name], time.tick_since(start))
+}
+
+// Opens or closes the endpoint to follow metrics.port and answers the
+// requests that arrived.  Called every frame.
+update_metrics_endpoint :: proc(state: ^Editor_State) {
+	m := &state.metrics
+	port := config_int(state, "metrics.port")
+	if port != m.port {
+		close_metrics_endpoint(m)
+		m.port = port
+		if port != 0 {
+			// Only this machine may look: the report names the open files.
+			endpoint := net.Endpoint {
+				address = net.IP4_Loopback,
+				port    = port,
+			}
+			listener, err := net.listen_tcp(endpoint)
+			if err != nil {
+				set_message(state, "Cannot serve metrics on port %d: %v", port, err)
+				return
+			}
+			net.set_blocking(listener, false)
+			m.listener = listener
+			m.listening = true
+		}
+	}
+	if !m.listening {
+		return
+	}
+	for {
+		client, _, err := net.accept_tcp(m.listener)
+		if err != .None {
+			break // none waiting
+		}
+		net.set_blocking(client, false)
+		append(&m.clients, Metrics_Client{socket = client})
+	}
+	for i := len(m.clients) - 1; i >= 0; i -= 1 {
+		c := &m.clients[i]
+		buf: [1024]u8
+		n, err := net.recv_tcp(c.socket, buf[:])
+		if err == .Would_Block {
+			continue
+		}
+		append(&c.request, ..buf[:n])
+		if err == .None && n > 0 && !strings.contains(string(c.request[:]), "\r\n\r\n") {
+			continue
+		}
+		if err == .None && n > 0 {
+			// Whatever the path, the answer is the report; it is small enough
+			// for the socket's buffer.
+			report := metrics_report(state)
+			header := fmt.tprintf(
+				"HTTP/1.0 200 OK\r\nContent-Type: text/plain; charset=utf-8\r\n" +
+				"Content-Length: %d\r\nConnection: close\r\n\r\n",
+				len(report),
+			)
+			net.set_blocking(c.socket, true)
+			net.send_tcp(c.socket, transmute([]u8)header)
+			net.send_tcp(c.socket, transmute([]u8)report)
+		}
+		net.close(c.socket)
+		delete(c.request)
+		unordered_remove(&m.clients, i)
+	}
+}
+
+// metrics.show: opens a buffer with the timings, to paste into a report.
+show_metrics :: proc(state: ^Editor_State) {
+	report := metrics_report(state)
+	new_document(state)
+	gb := &state.buffer
+	gb.undo = nil
+	editor.replace_bytes(gb, 0, 0, transmute([]u8)report)
+	gb.undo = &state.undo
+	state.cursor_pos = 0
+	sync_cursor(state)
+}
+
+// The timings as text.  Temp allocated.
+metrics_report :: proc(state: ^Editor_State) -> string {
+	m := &state.metrics
+	b := strings.builder_make(context.temp_allocator)
+	uptime := time.duration_seconds(time.tick_since(m.started))
+	fmt.sbprintf(&b, "Rune metrics after %.0f s, times in ms\n\n", uptime)
+	memory := 0
+	for bm in buffer_memory(state) {
+		memory += bm.text + bm.undo + bm.index
+	}
+	fmt.sbprintf(&b, "%d buffers holding %s\n", len(state.documents), format_size(memory))
+	fmt.sbprintf(&b, "frame interval %.2f\n\n", millis(state.frames.frame_time))
+
+	fmt.sbprintf(&b, "%-32s %8s %10s %10s %10s %10s\n", "", "count", "mean", "p50", "p95", "max")
+	for kind in Metric {
+		write_stats(&b, METRIC_NAMES[kind], &m.stats[kind])
+	}
+
+	Named :: struct {
+		name:  string,
+		stats: ^Metric_Stats,
+	}
+	commands := make([dynamic]Named, context.temp_allocator)
+	for name, &s in m.commands {
+		append(&commands, Named{name, &s})
+	}
+	slice.sort_by(commands[:], proc(a, b: Named) -> bool {
+		return a.stats.total > b.stats.total
+	})
+	if len(commands) > 0 {
+		fmt.sbprintf(&b, "\ncommands, slowest in total first\n")
+	}
+	for c in commands[:min(len(commands), METRIC_TOP_COMMANDS)] {
+		write_stats(&b, c.name, c.stats)
+	}
+	return strings.to_string(b)
+}
+
+@(private = "file")
+add_sample :: proc(s: ^Metric_Stats, d: time.Duration) {
+	s.recent[s.count % METRIC_SAMPLES] = d
+	s.count += 1
+	s.total += d
+	s.max = max(s.max, d)
+}
+
+@(private = "file")
+write_stats :: proc(b: ^strings.Builder, name: string, s: ^Metric_Stats) {
+	if s.count == 0 {
+		fmt.sbprintf(b, "%-32s %8d\n", name, 0)
+		return
+	}
+	recent := make([]time.Duration, min(s.count, METRIC_SAMPLES), context.temp_allocator)
+	copy(recent, s.recent[:len(recent)])
+	slice.sort(recent)
+	fmt.sbprintf(
+		b,
+		"%-32s %8d %10.2f %10.2f %10.2f %10.2f\n",
+		name,
+		s.count,
+		millis(s.total / time.Duration(s.count)),
+		millis(recent[len(recent) / 2]),
+		millis(recent[len(recent) * 95 / 100]),
+		millis(s.max),
+	)
+}
+
+@(private = "file")
+millis :: proc(d: time.Duration) -> f64 {
+	return time.duration_milliseconds(d)
+}
+
+@(private = "file")
+close_metrics_endpoint :: proc(m: ^Metrics_State) {
+	if !m.listening {
+		return
+	}
+	for c in m.clients {
+		net.close(c.socket)
+		delete(c.request)
+	}
+	clear(&m.clients)
+	net.close(m.listener)
+	m.listening = false
+}
@@ -0,0 +1,260 @@
+package main
+
+import "core:strings"
+import editor "editor"
+import "vendor:glfw"
+
+MULTI_CLICK_SECONDS :: 0.4 // between the clicks of a double or triple click
+WHEEL_LINES :: 3 // per notch of the wheel
+PANEL_MIN_ROWS :: 3
+PANEL_BORDER_GRAB :: 4 // pixels around the panel's top edge that start a resize
+
+Mouse_Drag :: enum u8 {
+	None,
+	Text, // extending the selection
+	Panel_Border, // resizing the bottom panel
+}
+
+Mouse_State :: struct {
+	drag:       Mouse_Drag,
+	last_click: f64, // glfw time of the last press
+	clicks:     int, // 1, 2 or 3 for the press within MULTI_CLICK_SECONDS of the last
+	click_pos:  [2]f32,
+	anchor:     [2]int, // the word or line a double or triple click selected
+}
+
+init_mouse :: proc(window: glfw.WindowHandle) {
+	glfw.SetMouseButtonCallback(window, mouse_button_callback)
+	glfw.SetCursorPosCallback(window, cursor_pos_callback)
+	glfw.SetScrollCallback(window, scroll_callback)
+}
+
+// Click to move the cursor, shift+click to extend the selection, double and
+// triple click to select a word or line, and drag to select more of them.
+// In the panel a click selects an item and a double click opens it; its top
+// edge drags to resize it.
+mouse_button_callback :: proc "c" (window: glfw.WindowHandle, button, action, mods: i32) {
+	context = callback_context()
+	state := cast(^Editor_State)glfw.GetWindowUserPointer(window)
+	if state == nil || button != glfw.MOUSE_BUTTON_LEFT {
+		return
+	}
+	note_input(state)
+	m := &state.mouse
+	if action == glfw.RELEASE {
+		m.drag = .None
+		return
+	}
+	switch state.mode {
+	case "prompt", "diff", "csv", "log", "docs", "peek":
+		return
+	}
+	p := mouse_position(window)
+	now := glfw.GetTime()
+	near := abs(p.x - m.click_pos.x) < 4 && abs(p.y - m.click_pos.y) < 4
+	if near && now - m.last_click < MULTI_CLICK_SECONDS {
+		m.clicks = m.clicks % 3 + 1
+	} else {
+		m.clicks = 1
+	}
+	m.last_click = now
+	m.click_pos = p
+	close_completion(state)
+	strings.builder_reset(&state.message)
+
+	top := panel_top(state)
+	switch {
+	case state.panel_data.visible && abs(p.y - top) <= PANEL_BORDER_GRAB:
+		m.drag = .Panel_Border
+	case state.panel_data.visible && p.y > top:
+		click_panel(state, p.y - top, m.clicks == 2)
+	case state.breadcrumbs.shown && p.y < state.line_height:
+		click_breadcrumb(state, p.x)
+	case p.y < top:
+		if state.mode == "panel" {
+			state.mode = "editor"
+		}
+		click_text(state, p, mods & glfw.MOD_SHIFT != 0)
+		m.drag = .Text
+	}
+	update_status_line(state)
+}
+
+cursor_pos_callback :: proc "c" (window: glfw.WindowHandle, x, y: f64) {
+	context = callback_context()
+	state := cast(^Editor_State)glfw.GetWindowUserPointer(window)
+	if state == nil {
+		return
+	}
+	p := mouse_position(window)
+	if state.mouse.drag != .None {
+		note_input(state)
+	}
+	switch state.mouse.drag {
+	case .None:
+	case .Text:
+		drag_selection(state, p)
+	case .Panel_Border:
+		// Rows below the pointer, less the status line and the panel title,
+		// leaving a few lines of text.
+		lh := state.line_height
+		rows := int((state.layer_ctx.viewport[1] - p.y) / lh) - 2
+		most := int(state.layer_ctx.viewport[1] / lh) - 2 - PANEL_MIN_ROWS
+		state.panel_data.max_rows = clamp(rows, PANEL_MIN_ROWS, max(most, PANEL_MIN_ROWS))
+		scroll_to_cursor(state)
+	}
+}
+
+// The wheel scrolls the view; the cursor stays where it is.
+scroll_callback :: proc "c" (window: glfw.WindowHandle, x, y: f64) {
+	context = callback_context()
+	state := cast(^Editor_State)glfw.GetWindowUserPointer(window)
+	if state == nil || state.mode == "diff" || state.mode == "csv" || state.mode == "log" {
+		return
+	}
+	note_input(state)
+	p := mouse_position(window)
+	if state.panel_data.visible && p.y > panel_top(state) {
+		editor.panel_move_selection(state.panel_data, -int(y) * WHEEL_LINES)
+		return
+	}
+	if state.docs_data.visible && p.x >= state.docs_data.left {
+		editor.docs_view_scroll(state.docs_data, -int(y) * WHEEL_LINES)
+		request_redraw(state)
+		return
+	}
+	scroll_to(state, state.scroll_target - f32(y) * WHEEL_LINES * state.line_height)
+}
+
+// The y of the panel's top edge, the bottom of the text when it is hidden.
+@(private = "file")
+panel_top :: proc(state: ^Editor_State) -> f32 {
+	bottom := state.layer_ctx.viewport[1] - state.line_height // the status line
+	return bottom - editor.panel_height(state.panel_data)
+}
+
+// The mouse position in framebuffer pixels, the units the layers draw in.
+@(private = "file")
+mouse_position :: proc(window: glfw.WindowHandle) -> [2]f32 {
+	x, y := glfw.GetCursorPos(window)
+	w, h := glfw.GetWindowSize(window)
+	fw, fh := glfw.GetFramebufferSize(window)
+	if w == 0 || h == 0 {
+		return {f32(x), f32(y)}
+	}
+	return {f32(x) * f32(fw) / f32(w), f32(y) * f32(fh) / f32(h)}
+}
+
+// The buffer position under p, at the nearest character boundary.
+@(private = "file")
+position_at :: proc(state: ^Editor_State, p: [2]f32) -> int {
+	c := state.cursor_data
+	last := editor.get_line_count(&state.buffer) - 1
+	line := int((p.y - c.padding[1] + state.layer_ctx.scroll_y) / state.line_height)
+	line = clamp(line, 0, max(last, 0))
+	visual := int((p.x - c.padding[0] + state.layer_ctx.scroll_x) / c.char_width + 0.5)
+	byte_col := editor.visual_col_to_byte_col(
+		&state.buffer,
+		line,
+		max(visual, 0),
+		state.layer_ctx.tab_size,
+	)
+	return editor.line_col_to_logical_pos(&state.buffer, line, byte_col)
+}
+
+@(private = "file")
+click_text :: proc(state: ^Editor_State, p: [2]f32, extend: bool) {
+	pos := position_at(state, p)
+	m := &state.mouse
+	switch {
+	case extend:
+		begin_selection(state)
+		state.cursor_pos = pos
+	case m.clicks == 1:
+		record_jump(state)
+		state.selection_anchor = -1
+		state.cursor_pos = pos
+	case:
+		m.anchor = unit_at(state, pos, m.clicks)
+		state.selection_anchor = m.anchor[0]
+		state.cursor_pos = m.anchor[1]
+	}
+	sync_cursor(state)
+	set_preferred_col(state)
+}
+
+// Extends the selection to p, by words or lines after a double or triple
+// click.
+@(private = "file")
+drag_selection :: proc(state: ^Editor_State, p: [2]f32) {
+	pos := position_at(state, p)
+	m := &state.mouse
+	if m.clicks == 1 {
+		begin_selection(state)
+		state.cursor_pos = pos
+	} else {
+		unit := unit_at(state, pos, m.clicks)
+		if pos < m.anchor[0] {
+			state.selection_anchor = m.anchor[1]
+			state.cursor_pos = unit[0]
+		} else {
+			state.selection_anchor = m.anchor[0]
+			state.cursor_pos = max(unit[1], m.anchor[1])
+		}
+	}
+	sync_cursor(state)
+	set_preferred_col(state)
+}
+
+// The word (clicks == 2) or line with its line break (3) at pos.
+@(private = "file")
+unit_at :: proc(state: ^Editor_State, pos, clicks: int) -> [2]int {
+	line, col := editor.logical_pos_to_line_col(&state.buffer, pos)
+	start := pos - col
+	text := editor.get_line(&state.buffer, line, context.temp_allocator)
+	if clicks >= 3 {
+		end := start + len(text)
+		if line + 1 < editor.get_line_count(&state.buffer) {
+			end = editor.line_col_to_logical_pos(&state.buffer, line + 1, 0)
+		}
+		return {start, end}
+	}
+	s, e := col, col
+	for s > 0 && is_click_word_byte(text[s - 1]) {
+		s -= 1
+	}
+	for e < len(text) && is_click_word_byte(text[e]) {
+		e += 1
+	}
+	if s == e && e < len(text) {
+		e += 1 // a lone space or symbol
+	}
+	return {start + s, start + e}
+}
+
+@(private = "file")
+is_click_word_byte :: proc(b: u8) -> bool {
+	switch b {
+	case 'a' ..= 'z', 'A' ..= 'Z', '0' ..= '9', '_', 0x80 ..= 0xff:
+		return true
+	}
+	return false
+}
+
+// y is below the panel's top edge.
+@(private = "file")
+click_panel :: proc(state: ^Editor_State, y: f32, open: bool) {
+	panel := state.panel_data
+	row := int(y / state.line_height) - 1 // the title row
+	i := panel.scroll + row
+	if row < 0 || i >= len(panel.items) {
+		return
+	}
+	panel.selected = i
+	if state.mode == "editor" {
+		state.mode = "panel"
+	}
+	if open {
+		run_command(state, "panel.accept")
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import "core:fmt"
+import "core:strings"
+import "core:time"
+import editor "editor"
+
+// Jupyter notebooks open as the text of their cells (editor.notebook_text)
+// and save back into the file they came from, which keeps the outputs.
+// While one is shown, each cell's marker line ends with what its outputs
+// show, from the file as last read or saved; notebook.outputs lists them.
+// Cells are not run here: that takes a Jupyter kernel, and a notebook it
+// saves is read again with the new outputs.
+Notebook_State :: struct {
+	shown:     bool, // the virtual text holds a notebook's outputs
+	doc_id:    int, // for this document and undo version
+	version:   int,
+	mtime:     time.Time, // and the file then
+	size:      i64,
+	summaries: map[string]string, // the file's cells' outputs by cell id
+}
+
+destroy_notebook :: proc(state: ^Editor_State) {
+	clear_notebook_summaries(&state.notebook)
+	delete(state.notebook.summaries)
+}
+
+// Keeps the outputs at the cells' marker lines as the buffer is edited.
+// Called every frame.
+update_notebook :: proc(state: ^Editor_State) {
+	n := &state.notebook
+	if state.disk.view != .Notebook {
+		if n.shown {
+			editor.set_virtual_text(state.virtual_text, {})
+			n.shown = false
+		}
+		return
+	}
+	disk := &state.disk
+	file_changed :=
+		!n.shown || n.doc_id != state.doc_id || n.mtime != disk.mtime || n.size != disk.size
+	if !file_changed && n.version == state.undo.version {
+		return
+	}
+	if file_changed {
+		clear_notebook_summaries(n)
+		if nb, ok := editor.parse_notebook(disk.source); ok {
+			for cell in nb.cells {
+				if summary := editor.notebook_output_summary(cell); summary != "" {
+					n.summaries[strings.clone(cell.id)] = strings.clone(summary)
+				}
+			}
+		}
+	}
+	items := make([dynamic]editor.Virtual_Text, context.temp_allocator)
+	text := editor.get_text(&state.buffer, context.temp_allocator)
+	for cell in editor.parse_notebook_text(text) {
+		if summary, ok := n.summaries[cell.id]; ok && cell.line >= 0 {
+			append(&items, editor.Virtual_Text{cell.line, summary})
+		}
+	}
+	editor.set_virtual_text(state.virtual_text, items[:])
+	n.shown = true
+	n.doc_id = state.doc_id
+	n.version = state.undo.version
+	n.mtime, n.size = disk.mtime, disk.size
+	request_redraw(state)
+}
+
+// Gives the cells that have no id in the buffer one, so that the file's
+// cells and the buffer's stay paired after saving.  Text before the first
+// marker gets a marker of its own.
+name_notebook_cells :: proc(state: ^Editor_State) {
+	text := editor.get_text(&state.buffer, context.temp_allocator)
+	cells := editor.parse_notebook_text(text)
+	editor.begin_undo_group(&state.undo, state.cursor_pos)
+	defer editor.end_undo_group(&state.undo, state.cursor_pos)
+	// Backwards, so that the positions of the cells before stay put.
+	#reverse for cell, i in cells {
+		if cell.id != "" && !is_repeated_cell_id(cells, i) {
+			continue
+		}
+		id := editor.new_notebook_cell_id()
+		if cell.line < 0 {
+			marker := fmt.tprintf("%s id=%s\n", editor.NOTEBOOK_MARKER, id)
+			insert_notebook_text(state, 0, marker)
+			continue
+		}
+		marker := editor.get_line(&state.buffer, cell.line, context.temp_allocator)
+		start := editor.line_col_to_logical_pos(&state.buffer, cell.line, 0)
+		if cell.id == "" {
+			insert_notebook_text(state, start + len(marker), fmt.tprintf(" id=%s", id))
+			continue
+		}
+		// A copy of another cell's marker takes an id of its own.
+		at := start + strings.index(marker, "id=") + len("id=")
+		editor.replace_bytes(&state.buffer, at, len(cell.id), transmute([]u8)id)
+		if state.cursor_pos > at {
+			state.cursor_pos = max(state.cursor_pos + len(id) - len(cell.id), at)
+		}
+	}
+	sync_cursor(state)
+}
+
+// notebook.next_cell: to the start of the next cell's source.
+goto_next_cell :: proc(state: ^Editor_State) {
+	cells, ok := notebook_cells(state)
+	if !ok {
+		return
+	}
+	for cell in cells {
+		if cell.line > state.cursor_data.line {
+			goto_line_col(state, cell.line + 1, 0)
+			return
+		}
+	}
+	set_message(state, "No cell after this one")
+}
+
+// notebook.previous_cell: to the start of the previous cell's source.
+goto_previous_cell :: proc(state: ^Editor_State) {
+	cells, ok := notebook_cells(state)
+	if !ok {
+		return
+	}
+	current := cell_at_cursor(state, cells)
+	if current <= 0 || cells[current - 1].line < 0 {
+		set_message(state, "No cell before this one")
+		return
+	}
+	goto_line_col(state, cells[current - 1].line + 1, 0)
+}
+
+// notebook.insert_cell: starts a code cell after the one the cursor is in.
+insert_notebook_cell :: proc(state: ^Editor_State) {
+	cells, ok := notebook_cells(state)
+	if !ok {
+		return
+	}
+	current := cell_at_cursor(state, cells)
+	if current + 1 < len(cells) {
+		next := cells[current + 1].line
+		pos := editor.line_col_to_logical_pos(&state.buffer, next, 0)
+		insert_notebook_text(state, pos, editor.NOTEBOOK_MARKER + "\n\n\n")
+		goto_line_col(state, next + 1, 0)
+		return
+	}
+	end := editor.current_length(&state.buffer)
+	text := editor.NOTEBOOK_MARKER + "\n"
+	if end > 0 {
+		last := editor.get_text_segment(&state.buffer, end - 1, 1, context.temp_allocator)
+		text = strings.concatenate({"\n\n" if last != "\n" else "\n", text}, context.temp_allocator)
+	}
+	insert_notebook_text(state, end, text)
+	goto_line_col(state, editor.get_line_count(&state.buffer) - 1, 0)
+}
+
+// notebook.outputs: lists what the cell at the cursor output when it last
+// ran, as the file has it.
+show_notebook_outputs :: proc(state: ^Editor_State) {
+	cells, ok := notebook_cells(state)
+	if !ok {
+		return
+	}
+	current := cell_at_cursor(state, cells)
+	nb, parsed := editor.parse_notebook(state.disk.source)
+	found: editor.Notebook_Cell
+	has := false
+	if current >= 0 && parsed {
+		for cell in nb.cells {
+			if cell.id == cells[current].id {
+				found, has = cell, true
+			}
+		}
+	}
+	if !has || len(found.outputs) == 0 {
+		set_message(state, "The cell has no outputs saved")
+		return
+	}
+	clear_replace(state)
+	stop_project_search(state)
+	release_panel(state)
+	panel := state.panel_data
+	editor.panel_clear(panel)
+	title := "Outputs of the cell"
+	if found.count >= 0 {
+		title = fmt.tprintf("Outputs of the cell, run %d", found.count)
+	}
+	editor.panel_set_title(panel, title)
+	for out in found.outputs {
+		style := editor.Panel_Item_Style.Dim if out.kind == "stream" else .Normal
+		text := strings.trim_right(out.text, "\n")
+		for line in strings.split_lines(text, context.temp_allocator) {
+			editor.panel_add_item(panel, {text = line, line = -1, style = style})
+		}
+	}
+	show_panel(state)
+}
+
+// The active document's cells, or a message when it is not a notebook.
+@(private = "file")
+notebook_cells :: proc(state: ^Editor_State) -> ([]editor.Notebook_Cell, bool) {
+	if state.disk.view != .Notebook {
+		set_message(state, "%s is not a notebook", document_title(state.path))
+		return nil, false
+	}
+	text := editor.get_text(&state.buffer, context.temp_allocator)
+	return editor.parse_notebook_text(text), true
+}
+
+// The index of the cell the cursor is in, -1 before the first.
+@(private = "file")
+cell_at_cursor :: proc(state: ^Editor_State, cells: []editor.Notebook_Cell) -> int {
+	current := -1
+	for cell, i in cells {
+		if cell.line <= state.cursor_data.line {
+			current = i
+		}
+	}
+	return current
+}
+
+@(private = "file")
+is_repeated_cell_id :: proc(cells: []editor.Notebook_Cell, i: int) -> bool {
+	for cell in cells[:i] {
+		if cell.id == cells[i].id {
+			return true
+		}
+	}
+	return false
+}
+
+// Inserts text at pos, keeping the cursor on the text it was on.
+@(private = "file")
+insert_notebook_text :: proc(state: ^Editor_State, pos: int, text: string) {
+	editor.replace_bytes(&state.buffer, pos, 0, transmute([]u8)text)
+	if state.cursor_pos >= pos {
+		state.cursor_pos += len(text)
+	}
+	request_redraw(state)
+}
+
+@(private = "file")
+clear_notebook_summaries :: proc(n: ^Notebook_State) {
+	for id, summary in n.summaries {
+		delete(id)
+		delete(summary)
+	}
+	clear(&n.summaries)
+}
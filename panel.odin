@@ -0,0 +1,136 @@
+package main
+
+import editor "editor"
+
+// Shows the bottom panel and gives it keyboard focus.
+show_panel :: proc(state: ^Editor_State) {
+	state.panel_data.visible = true
+	if state.mode != "prompt" {
+		state.mode = "panel"
+	}
+	scroll_to_cursor(state)
+}
+
+// Tells the features that fill the panel and keep reacting to it that
+// something else is about to list there.  Each only touches the panel while
+// its active flag is set.
+release_panel :: proc(state: ^Editor_State) {
+	state.git_status.active = false
+	state.tasks.active = false
+	state.test_explorer.active = false
+	state.config.active = false
+	state.diagnostics.active = false
+	state.clipboard.active = false
+	state.registers.active = false
+	state.projects.active = false
+	state.projects.removing = false
+	state.language_list.active = false
+	state.index.listing = false
+	state.unicode.active = false
+	state.todos.active = false
+	state.history.active = false
+	state.tags.active = false
+	state.quickfix.active = false
+}
+
+// Lists lines in the panel under title, read-only.
+show_result_lines :: proc(state: ^Editor_State, title: string, lines: []string) {
+	clear_replace(state)
+	stop_project_search(state)
+	release_panel(state)
+	panel := state.panel_data
+	editor.panel_clear(panel)
+	editor.panel_set_title(panel, title)
+	for line in lines {
+		editor.panel_add_item(panel, {text = line, line = -1})
+	}
+	show_panel(state)
+}
+
+hide_panel :: proc(state: ^Editor_State) {
+	state.panel_data.visible = false
+	if state.mode == "panel" {
+		state.mode = "editor"
+	}
+}
+
+// ctrl+j: focus the panel, or hide it when it already has focus.
+toggle_panel :: proc(state: ^Editor_State) {
+	if state.panel_data.visible && state.mode == "panel" {
+		hide_panel(state)
+	} else {
+		show_panel(state)
+	}
+}
+
+panel_next :: proc(state: ^Editor_State) {
+	editor.panel_move_selection(state.panel_data, 1)
+}
+
+panel_prev :: proc(state: ^Editor_State) {
+	editor.panel_move_selection(state.panel_data, -1)
+}
+
+panel_page_down :: proc(state: ^Editor_State) {
+	editor.panel_move_selection(state.panel_data, editor.panel_rows(state.panel_data))
+}
+
+panel_page_up :: proc(state: ^Editor_State) {
+	editor.panel_move_selection(state.panel_data, -editor.panel_rows(state.panel_data))
+}
+
+// Jumps to the selected item and returns focus to the buffer, leaving the
+// panel open so the next result is one keypress away.
+panel_accept :: proc(state: ^Editor_State) {
+	if state.config.active {
+		edit_setting(state)
+		return
+	}
+	if state.clipboard.active {
+		paste_history_entry(state)
+		return
+	}
+	if state.registers.active {
+		paste_register_entry(state)
+		return
+	}
+	if state.projects.active {
+		open_recent_project(state)
+		return
+	}
+	if state.projects.removing {
+		remove_workspace_folder(state)
+		return
+	}
+	if state.language_list.active {
+		set_language_entry(state)
+		return
+	}
+	if state.index.listing {
+		goto_symbol_entry(state)
+		return
+	}
+	if state.unicode.active {
+		insert_character_entry(state)
+		return
+	}
+	if state.history.active {
+		open_history_entry(state)
+		return
+	}
+	if state.tags.active {
+		open_tag_entry(state)
+		return
+	}
+	if state.quickfix.active {
+		open_quickfix_entry(state)
+		return
+	}
+	item := editor.panel_selected_item(state.panel_data)
+	if item == nil || item.path == "" {
+		return
+	}
+	if jump_to_location(state, item.path, item.line, item.col) {
+		state.mode = "editor"
+	}
+}
@@ -0,0 +1,558 @@
+package main
+
+import "core:fmt"
+import "core:path/filepath"
+import "core:strings"
+import "core:unicode/utf8"
+import editor "editor"
+
+// A place in a file: its absolute path, 0 based line and byte column.
+Location :: struct {
+	path: string,
+	line: int,
+	col:  int,
+}
+
+// Peeking (editor.Peek_View_Data): symbol.peek_definition and
+// symbol.peek_references show where the name at the cursor is defined or
+// used in a box under the cursor's line, without leaving the buffer.  The
+// box has the keyboard, in "peek" mode, and edits the file it shows in
+// place: through the file's document, opened in the background on the
+// first edit, so the change is saved and undone like any other.  alt+down
+// and alt+up go through the places, ctrl+enter opens the one shown and
+// escape closes the box.
+Peek_State :: struct {
+	name:      string, // peeked at
+	locations: [dynamic]Location, // paths owned
+	current:   int,
+	caret:     int, // byte offset in the file shown
+	first:     int, // its first line in the box
+	disk_text: string, // the file as read, while no document holds it
+	version:   int, // of the document's history as shown, -1 for disk_text
+}
+
+destroy_peek :: proc(state: ^Editor_State) {
+	clear_peek(&state.peek)
+	delete(state.peek.locations)
+}
+
+// symbol.peek_definition: where the name at the cursor is defined, by the
+// LSIF dumps, the tags file or the buffer's index, as symbol.definition
+// looks.
+peek_definition :: proc(state: ^Editor_State) {
+	name := word_at_cursor(state)
+	if name == "" {
+		set_message(state, "No name at the cursor")
+		return
+	}
+	locations := make([dynamic]Location, context.temp_allocator)
+	append(&locations, ..lsif_locations(state, false))
+	if len(locations) == 0 {
+		for tag in tag_definitions(state, name) {
+			path := tag_path(state, tag)
+			text, _, ok := definition_source(state, path)
+			if !ok {
+				continue
+			}
+			line := tag.line
+			if line < 0 {
+				line = editor.find_tag_pattern(text, tag.pattern)
+			}
+			if line >= 0 {
+				append(&locations, Location{path, line, name_column(text, line, name)})
+			}
+		}
+	}
+	size := editor.current_length(&state.buffer)
+	index := usable_index(state, state.doc_id, &state.undo, size)
+	if len(locations) == 0 && index != nil && state.path != "" {
+		for s in editor.index_symbols_with_prefix(index, name) {
+			if s.name == name {
+				append(&locations, Location{workspace_path(state, state.path), s.line, s.col})
+			}
+		}
+	}
+	if len(locations) == 0 {
+		set_message(state, "No definition of %s", name)
+		return
+	}
+	show_peek(state, name, locations[:])
+}
+
+// symbol.peek_references: where the name at the cursor is used, by the LSIF
+// dumps, else wherever it stands as a word in the open buffers.
+peek_references :: proc(state: ^Editor_State) {
+	name := word_at_cursor(state)
+	if name == "" {
+		set_message(state, "No name at the cursor")
+		return
+	}
+	locations := make([dynamic]Location, context.temp_allocator)
+	append(&locations, ..lsif_locations(state, true))
+	if len(locations) == 0 {
+		if state.path != "" {
+			text := editor.get_text(&state.buffer, context.temp_allocator)
+			add_word_locations(&locations, workspace_path(state, state.path), text, name)
+		}
+		for &d, i in state.documents {
+			if i != state.active && d.path != "" {
+				text := editor.get_text(&d.buffer, context.temp_allocator)
+				add_word_locations(&locations, workspace_path(state, d.path), text, name)
+			}
+		}
+	}
+	if len(locations) == 0 {
+		set_message(state, "No references to %s", name)
+		return
+	}
+	show_peek(state, name, locations[:])
+}
+
+// peek.next: the next place, wrapping around.
+peek_next :: proc(state: ^Editor_State) {
+	p := &state.peek
+	if len(p.locations) > 1 {
+		show_peek_location(state, (p.current + 1) % len(p.locations))
+	}
+}
+
+// peek.previous: the place before, wrapping around.
+peek_previous :: proc(state: ^Editor_State) {
+	p := &state.peek
+	n := len(p.locations)
+	if n > 1 {
+		show_peek_location(state, (p.current + n - 1) % n)
+	}
+}
+
+// peek.open: closes the box and goes to where its caret is.
+goto_peek :: proc(state: ^Editor_State) {
+	p := &state.peek
+	if len(p.locations) == 0 {
+		return
+	}
+	text, _ := peek_text(state)
+	pos := editor.lsp_position(text, p.caret, .Utf8)
+	path := strings.clone(p.locations[p.current].path, context.temp_allocator)
+	close_peek(state)
+	jump_to_location(state, path, pos.line, pos.character)
+}
+
+// peek.save: saves the file shown, whichever document holds it.
+save_peek :: proc(state: ^Editor_State) {
+	_, _, doc, ok := peek_document(state)
+	if !ok {
+		set_message(state, "No changes to save")
+		return
+	}
+	if doc == nil {
+		save_document(state)
+		return
+	}
+	id := state.doc_id
+	switch_document(state, find_document(state, state.peek.locations[state.peek.current].path))
+	save_document(state)
+	for d, i in state.documents {
+		if i != state.active && d.id == id {
+			switch_document(state, i)
+			break
+		}
+	}
+}
+
+// peek.close: hides the box and gives the keyboard back.
+close_peek :: proc(state: ^Editor_State) {
+	clear_peek(&state.peek)
+	editor.clear_peek_view(state.peek_data)
+	if state.mode == "peek" {
+		state.mode = "editor"
+	}
+	request_redraw(state)
+}
+
+// Keeps the box on the cursor's line and on the file as it changes, e.g. by
+// an undo in its own buffer.  Called every frame.
+update_peek :: proc(state: ^Editor_State) {
+	d := state.peek_data
+	if !d.visible {
+		return
+	}
+	state.peek_data.rows = config_int(state, "peek.height")
+	version := -1
+	if _, undo, _, ok := peek_document(state); ok {
+		version = undo.version
+	}
+	if version != state.peek.version || d.anchor != state.cursor_data.line {
+		refresh_peek(state)
+	}
+}
+
+peek_left :: proc(state: ^Editor_State) {
+	text, _ := peek_text(state)
+	set_peek_caret(state, editor.prev_grapheme(text, state.peek.caret))
+}
+
+peek_right :: proc(state: ^Editor_State) {
+	text, _ := peek_text(state)
+	set_peek_caret(state, editor.next_grapheme(text, state.peek.caret))
+}
+
+peek_up :: proc(state: ^Editor_State) {
+	move_peek_caret_lines(state, -1)
+}
+
+peek_down :: proc(state: ^Editor_State) {
+	move_peek_caret_lines(state, 1)
+}
+
+peek_line_start :: proc(state: ^Editor_State) {
+	text, _ := peek_text(state)
+	set_peek_caret(state, strings.last_index_byte(text[:state.peek.caret], '\n') + 1)
+}
+
+peek_line_end :: proc(state: ^Editor_State) {
+	text, _ := peek_text(state)
+	caret := state.peek.caret
+	end := strings.index_byte(text[caret:], '\n')
+	set_peek_caret(state, len(text) if end < 0 else caret + end)
+}
+
+// Typing while the box has the keyboard.
+peek_insert_rune :: proc(state: ^Editor_State, r: rune) {
+	buf, n := utf8.encode_rune(r)
+	edit_peek(state, 0, string(buf[:n]), "typing")
+}
+
+peek_delete_backward :: proc(state: ^Editor_State) {
+	text, _ := peek_text(state)
+	caret := state.peek.caret
+	if caret > 0 {
+		start := editor.prev_grapheme(text, caret)
+		state.peek.caret = start
+		edit_peek(state, caret - start, "")
+	}
+}
+
+peek_delete_forward :: proc(state: ^Editor_State) {
+	text, _ := peek_text(state)
+	caret := state.peek.caret
+	if caret < len(text) {
+		edit_peek(state, editor.next_grapheme(text, caret) - caret, "")
+	}
+}
+
+// peek.newline: breaks the line, keeping its indentation.
+peek_newline :: proc(state: ^Editor_State) {
+	text, _ := peek_text(state)
+	caret := state.peek.caret
+	line := text[strings.last_index_byte(text[:caret], '\n') + 1:caret]
+	indent := line[:len(line) - len(strings.trim_left(line, " \t"))]
+	edit_peek(state, 0, strings.concatenate({"\n", indent}, context.temp_allocator))
+}
+
+// peek.tab: a tab, or spaces to the next stop with editor.insert_spaces.
+peek_tab :: proc(state: ^Editor_State) {
+	if !config_bool(state, "editor.insert_spaces") {
+		edit_peek(state, 0, "\t")
+		return
+	}
+	text, _ := peek_text(state)
+	caret := state.peek.caret
+	line := text[strings.last_index_byte(text[:caret], '\n') + 1:caret]
+	size := max(state.layer_ctx.tab_size, 1)
+	col := editor.line_visual_col(line, len(line), size)
+	edit_peek(state, 0, strings.repeat(" ", size - col % size, context.temp_allocator))
+}
+
+// peek.paste: the clipboard at the caret.
+peek_paste :: proc(state: ^Editor_State) {
+	text := get_clipboard(state)
+	if text == "" {
+		set_message(state, "The clipboard is empty")
+		return
+	}
+	edit_peek(state, 0, editor.normalize_line_endings(text, context.temp_allocator))
+}
+
+peek_undo :: proc(state: ^Editor_State) {
+	step_peek_history(state, false)
+}
+
+peek_redo :: proc(state: ^Editor_State) {
+	step_peek_history(state, true)
+}
+
+// Opens the box on the first of locations, with the keyboard.
+@(private = "file")
+show_peek :: proc(state: ^Editor_State, name: string, locations: []Location) {
+	p := &state.peek
+	clear_peek(p)
+	p.name = strings.clone(name)
+	for loc in locations {
+		append(&p.locations, Location{strings.clone(loc.path), loc.line, loc.col})
+	}
+	close_completion(state)
+	state.peek_data.rows = config_int(state, "peek.height")
+	show_peek_location(state, 0)
+	if !state.peek_data.visible {
+		return // the file could not be read
+	}
+	state.peek_data.focused = true
+	state.mode = "peek"
+	if len(locations) > 1 {
+		set_message(state, "%d places; alt+down and alt+up go through them", len(locations))
+	}
+}
+
+@(private = "file")
+show_peek_location :: proc(state: ^Editor_State, i: int) {
+	p := &state.peek
+	p.current = i
+	delete(p.disk_text)
+	p.disk_text = ""
+	loc := p.locations[i]
+	text, _ := peek_text(state)
+	offset, ok := editor.lsp_offset(text, {loc.line, loc.col}, .Utf8)
+	p.caret = offset if ok else 0
+	p.first = max(loc.line - state.peek_data.rows / 3, 0)
+	refresh_peek(state)
+}
+
+// The file shown: the text of its document, or as read from disk when it
+// has none.  Temp allocated, but for disk_text.
+@(private = "file")
+peek_text :: proc(state: ^Editor_State) -> (text: string, ok: bool) {
+	p := &state.peek
+	if len(p.locations) == 0 {
+		return "", false
+	}
+	if buffer, _, _, open := peek_document(state); open {
+		return editor.get_text(buffer, context.temp_allocator), true
+	}
+	if p.disk_text == "" {
+		source, _, read := definition_source(state, p.locations[p.current].path)
+		if !read {
+			return "", false
+		}
+		p.disk_text = strings.clone(source)
+	}
+	return p.disk_text, true
+}
+
+// The document holding the file shown, with its history; doc is nil for
+// the active one.  With open set a file no document holds is opened in the
+// background first.
+@(private = "file")
+peek_document :: proc(
+	state: ^Editor_State,
+	open := false,
+) -> (
+	buffer: ^editor.Gap_Buffer,
+	undo: ^editor.Undo_History,
+	doc: ^Document,
+	ok: bool,
+) {
+	p := &state.peek
+	if len(p.locations) == 0 {
+		return nil, nil, nil, false
+	}
+	path := p.locations[p.current].path
+	if is_open_file(state, path) {
+		return &state.buffer, &state.undo, nil, true
+	}
+	i := find_document(state, path)
+	if i < 0 && open {
+		id := state.doc_id
+		if !open_file(state, path) {
+			set_message(state, "Cannot open %s", path)
+			return nil, nil, nil, false
+		}
+		for d, j in state.documents {
+			if j != state.active && d.id == id {
+				switch_document(state, j)
+				break
+			}
+		}
+		if is_open_file(state, path) {
+			return &state.buffer, &state.undo, nil, true // the blank buffer was reused
+		}
+		i = find_document(state, path)
+	}
+	if i < 0 {
+		return nil, nil, nil, false
+	}
+	d := &state.documents[i]
+	return &d.buffer, &d.undo, d, true
+}
+
+// Replaces count bytes at the caret with text in the file shown, through
+// its document, and puts the caret after it.
+@(private = "file")
+edit_peek :: proc(state: ^Editor_State, count: int, text: string, merge_key := "") {
+	p := &state.peek
+	buffer, undo, doc, ok := peek_document(state, open = true)
+	if !ok {
+		return
+	}
+	read_only := is_read_only(state)
+	if doc != nil {
+		read_only = doc.preview || doc.disk.view == .Hex || doc.language == editor.HELP_LANGUAGE_ID
+	}
+	if read_only {
+		set_message(state, "%s is read-only", document_title(p.locations[p.current].path))
+		return
+	}
+	pos := p.caret
+	attached := buffer.undo
+	buffer.undo = undo // a parked buffer has its history detached
+	editor.begin_undo_group(undo, pos, merge_key)
+	editor.replace_bytes(buffer, pos, count, transmute([]u8)text)
+	editor.end_undo_group(undo, pos + len(text))
+	buffer.undo = attached
+	p.caret = pos + len(text)
+
+	// The document's own cursor stays on its text.
+	cursor := &state.cursor_pos if doc == nil else &doc.cursor_pos
+	if cursor^ >= pos + count {
+		cursor^ += len(text) - count
+	} else if cursor^ > pos {
+		cursor^ = pos
+	}
+	if doc == nil {
+		sync_cursor(state)
+	}
+	refresh_peek(state)
+}
+
+@(private = "file")
+step_peek_history :: proc(state: ^Editor_State, redo: bool) {
+	buffer, undo, doc, ok := peek_document(state)
+	if !ok {
+		set_message(state, "Nothing to redo" if redo else "Nothing to undo")
+		return
+	}
+	attached := buffer.undo
+	buffer.undo = undo
+	cursor, stepped := editor.redo(buffer) if redo else editor.undo(buffer)
+	buffer.undo = attached
+	if !stepped {
+		set_message(state, "Nothing to redo" if redo else "Nothing to undo")
+		return
+	}
+	state.peek.caret = min(cursor, editor.current_length(buffer))
+	if doc == nil {
+		state.cursor_pos = min(state.cursor_pos, editor.current_length(buffer))
+		sync_cursor(state)
+	} else {
+		doc.cursor_pos = min(doc.cursor_pos, editor.current_length(buffer))
+	}
+	refresh_peek(state)
+}
+
+@(private = "file")
+set_peek_caret :: proc(state: ^Editor_State, caret: int) {
+	state.peek.caret = caret
+	refresh_peek(state)
+}
+
+// Moves the caret delta lines, keeping its visual column where it can.
+@(private = "file")
+move_peek_caret_lines :: proc(state: ^Editor_State, delta: int) {
+	text, _ := peek_text(state)
+	pos := editor.lsp_position(text, state.peek.caret, .Utf8)
+	lines := strings.split_lines(text, context.temp_allocator)
+	target := pos.line + delta
+	if target < 0 || target >= len(lines) {
+		return
+	}
+	size := state.layer_ctx.tab_size
+	visual := editor.line_visual_col(lines[pos.line], pos.character, size)
+	col := editor.line_byte_col(lines[target], visual, size)
+	offset, _ := editor.lsp_offset(text, {target, col}, .Utf8)
+	set_peek_caret(state, offset)
+}
+
+// Draws the box again from the file, scrolled to keep the caret in it.
+@(private = "file")
+refresh_peek :: proc(state: ^Editor_State) {
+	p := &state.peek
+	d := state.peek_data
+	text, ok := peek_text(state)
+	if !ok {
+		set_message(state, "Cannot read %s", p.locations[p.current].path)
+		close_peek(state)
+		return
+	}
+	p.version = -1
+	if _, undo, _, open := peek_document(state); open {
+		p.version = undo.version
+	}
+	p.caret = clamp(p.caret, 0, len(text))
+	caret := editor.lsp_position(text, p.caret, .Utf8)
+	lines := strings.split_lines(text, context.temp_allocator)
+	rows := max(d.rows, 1)
+	if caret.line < p.first {
+		p.first = caret.line
+	} else if caret.line >= p.first + rows {
+		p.first = caret.line - rows + 1
+	}
+	p.first = clamp(p.first, 0, max(len(lines) - rows, 0))
+
+	loc := p.locations[p.current]
+	shown := loc.path
+	rel, err := filepath.rel(state.workspace_root, loc.path, context.temp_allocator)
+	if err == .None && !strings.has_prefix(rel, "..") {
+		shown = rel
+	}
+	title := fmt.tprintf("%s  %s:%d", p.name, shown, loc.line + 1)
+	if len(p.locations) > 1 {
+		title = fmt.tprintf("%s  (%d of %d)", title, p.current + 1, len(p.locations))
+	}
+	end := min(p.first + rows, len(lines))
+	editor.set_peek_view(d, title, lines[p.first:end], p.first, loc.line)
+	d.anchor = state.cursor_data.line
+	d.caret_line = caret.line
+	size := state.layer_ctx.tab_size
+	d.caret_col = editor.line_visual_col(lines[caret.line], caret.character, size)
+	request_redraw(state)
+}
+
+// Where name stands as a word in text, in file order.
+@(private = "file")
+add_word_locations :: proc(out: ^[dynamic]Location, path, text, name: string) {
+	line, line_start, at := 0, 0, 0
+	for offset in editor.find_whole_word(text, name) {
+		for at < offset {
+			if text[at] == '\n' {
+				line += 1
+				line_start = at + 1
+			}
+			at += 1
+		}
+		append(out, Location{path, line, offset - line_start})
+	}
+}
+
+// The byte column of name on line of text, 0 when it is not there.
+@(private = "file")
+name_column :: proc(text: string, line: int, name: string) -> int {
+	start, ok := editor.lsp_offset(text, {line, 0}, .Utf8)
+	if !ok {
+		return 0
+	}
+	end := strings.index_byte(text[start:], '\n')
+	found := strings.index(text[start:] if end < 0 else text[start:start + end], name)
+	return max(found, 0)
+}
+
+@(private = "file")
+clear_peek :: proc(p: ^Peek_State) {
+	for loc in p.locations {
+		delete(loc.path)
+	}
+	clear(&p.locations)
+	delete(p.name)
+	delete(p.disk_text)
+	p.name, p.disk_text = "", ""
+	p.current, p.caret, p.first = 0, 0, 0
+}
@@ -0,0 +1,843 @@
+package main
+
+import "core:c"
+import "core:log"
+import "core:os"
+import "core:path/filepath"
+import "core:slice"
+import "core:strings"
+import "core:unicode/utf8"
+import editor "editor"
+import lua "vendor:lua/5.4"
+
+// Bumped when a change to the rune table could break existing plugins.
+PLUGIN_API_VERSION :: 1
+
+INIT_SCRIPT :: "init.lua" // in the user config dir, see user_config_path
+PLUGINS_DIR :: "plugins"
+
+// Events plugins can subscribe to with rune.on.  Handlers get the path of
+// the buffer, empty for an unnamed one.
+Plugin_Event :: enum u8 {
+	Open, // a file was read into a new buffer
+	Save, // the buffer was written to its file
+	Enter, // a buffer became the active one
+}
+
+PLUGIN_EVENT_NAMES := [Plugin_Event]string {
+	.Open  = "open",
+	.Save  = "save",
+	.Enter = "enter",
+}
+
+// A rune.on_type_format provider and the characters it formats after.
+Type_Formatter :: struct {
+	triggers: string,
+	ref:      c.int,
+}
+
+// A rune.document_color provider: its function that finds the colors and
+// the one that writes a picked color, lua.NOREF when it has none.
+Color_Provider :: struct {
+	colors:  c.int,
+	present: c.int,
+}
+
+// A command a plugin registered.  Command borrows the strings from here.
+Plugin_Command :: struct {
+	name:        string,
+	description: string,
+}
+
+Plugin_State :: struct {
+	lua:          ^lua.State, // nil when Lua failed to start
+	commands:     [dynamic]^Plugin_Command,
+	handlers:     [Plugin_Event][dynamic]c.int, // references to Lua functions
+	suggesters:   [dynamic]c.int, // rune.inline_completion providers
+	selectors:    [dynamic]c.int, // rune.selection_range providers
+	formatters:   [dynamic]Type_Formatter, // rune.on_type_format providers
+	colorers:     [dynamic]Color_Provider, // rune.document_color providers
+	symbolers:    [dynamic]c.int, // rune.document_symbol providers
+	prompt_label: string, // of the prompt a plugin opened
+	prompt_ref:   c.int, // its callback, or lua.NOREF
+}
+
+// Starts Lua and runs init.lua and then plugins/*.lua, and
+// plugins/<name>/init.lua, from the user config dir, in name order.  A
+// failing script is reported and skipped.
+load_plugins :: proc(state: ^Editor_State) {
+	p := &state.plugins
+	p.prompt_ref = lua.NOREF
+	L := lua.L_newstate()
+	if L == nil {
+		log.error("plugins: cannot start Lua")
+		return
+	}
+	p.lua = L
+	lua.L_openlibs(L)
+	push_plugin_api(state)
+	lua.setglobal(L, "rune")
+
+	scripts := make([dynamic]string, context.temp_allocator)
+	if path, ok := user_config_path(INIT_SCRIPT); ok && os.exists(path) {
+		append(&scripts, path)
+	}
+	if dir, ok := user_config_path(PLUGINS_DIR); ok {
+		append(&scripts, ..plugin_files(dir))
+	}
+	failed := 0
+	for path in scripts {
+		if !run_plugin_file(state, path) {
+			failed += 1
+		}
+	}
+	if failed > 0 {
+		set_message(state, "%d plugins failed to load, see log.open", failed)
+	}
+}
+
+destroy_plugins :: proc(state: ^Editor_State) {
+	p := &state.plugins
+	if p.lua != nil {
+		lua.close(p.lua)
+	}
+	for pc in p.commands {
+		delete(pc.name)
+		delete(pc.description)
+		free(pc)
+	}
+	delete(p.commands)
+	for refs in p.handlers {
+		delete(refs)
+	}
+	delete(p.suggesters)
+	delete(p.selectors)
+	for f in p.formatters {
+		delete(f.triggers)
+	}
+	delete(p.formatters)
+	delete(p.colorers)
+	delete(p.symbolers)
+	delete(p.prompt_label)
+}
+
+// Calls the handlers plugins registered for event with path.
+fire_plugin_event :: proc(state: ^Editor_State, event: Plugin_Event, path: string) {
+	p := &state.plugins
+	if p.lua == nil || len(p.handlers[event]) == 0 {
+		return
+	}
+	// A handler may register more handlers; call the ones there are now.
+	refs := slice.clone(p.handlers[event][:], context.temp_allocator)
+	for ref in refs {
+		lua.rawgeti(p.lua, lua.REGISTRYINDEX, lua.Integer(ref))
+		push_lua_string(p.lua, path)
+		call_plugin(state, 1)
+	}
+}
+
+// Asks the rune.inline_completion providers, in the order they were added,
+// for a suggestion at line and col; the first one's is taken.  "" when none
+// has one.  Temp allocated.
+plugin_inline_completion :: proc(state: ^Editor_State, line, col: int) -> string {
+	p := &state.plugins
+	if p.lua == nil {
+		return ""
+	}
+	L := p.lua
+	refs := slice.clone(p.suggesters[:], context.temp_allocator)
+	for ref in refs {
+		lua.rawgeti(L, lua.REGISTRYINDEX, lua.Integer(ref))
+		push_lua_string(L, state.path)
+		lua.pushinteger(L, lua.Integer(line + 1))
+		lua.pushinteger(L, lua.Integer(col + 1))
+		if lua.pcall(L, 3, 1, 0) != .OK {
+			message := lua_string(L, -1)
+			log.warn("plugins:", message)
+			set_message(state, "Plugin error: %s", message)
+			lua.pop(L, 1)
+			continue
+		}
+		text := ""
+		if lua.type(L, -1) == .STRING {
+			text = strings.clone(lua_string(L, -1), context.temp_allocator)
+		}
+		lua.pop(L, 1)
+		if text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// Asks every rune.selection_range provider for the ranges around line and
+// col, the byte ranges of text they answer with together.  Temp allocated.
+plugin_selection_ranges :: proc(state: ^Editor_State, text: string, line, col: int) -> [][2]int {
+	p := &state.plugins
+	if p.lua == nil || len(p.selectors) == 0 {
+		return nil
+	}
+	L := p.lua
+	found := make([dynamic][2]int, context.temp_allocator)
+	refs := slice.clone(p.selectors[:], context.temp_allocator)
+	for ref in refs {
+		lua.rawgeti(L, lua.REGISTRYINDEX, lua.Integer(ref))
+		push_lua_string(L, state.path)
+		lua.pushinteger(L, lua.Integer(line + 1))
+		lua.pushinteger(L, lua.Integer(col + 1))
+		if lua.pcall(L, 3, 2, 0) != .OK {
+			message := lua_string(L, -1)
+			log.warn("plugins:", message)
+			set_message(state, "Plugin error: %s", message)
+			lua.pop(L, 1)
+			continue
+		}
+		reply := strings.clone(lua_string(L, -2), context.temp_allocator)
+		encoding, known := editor.parse_position_encoding(lua_string(L, -1))
+		if !known {
+			encoding = .Utf16
+		}
+		lua.pop(L, 2)
+		if reply != "" {
+			append(&found, ..editor.selection_range_chain(text, reply, encoding))
+		}
+	}
+	return found[:]
+}
+
+// Asks the rune.on_type_format providers whose triggers have ch, in the
+// order they were added, to format after it was typed at line and col; the
+// first answer is taken.  ok is false when none answers.  Temp allocated.
+plugin_on_type_format :: proc(
+	state: ^Editor_State,
+	line, col: int,
+	ch: rune,
+) -> (
+	reply: string,
+	encoding: editor.Position_Encoding,
+	ok: bool,
+) {
+	p := &state.plugins
+	if p.lua == nil {
+		return "", .Utf16, false
+	}
+	L := p.lua
+	buf, n := utf8.encode_rune(ch)
+	typed := string(buf[:n])
+	formatters := slice.clone(p.formatters[:], context.temp_allocator)
+	for f in formatters {
+		if !strings.contains_rune(f.triggers, ch) {
+			continue
+		}
+		lua.rawgeti(L, lua.REGISTRYINDEX, lua.Integer(f.ref))
+		push_lua_string(L, state.path)
+		lua.pushinteger(L, lua.Integer(line + 1))
+		lua.pushinteger(L, lua.Integer(col + 1))
+		push_lua_string(L, typed)
+		if lua.pcall(L, 4, 2, 0) != .OK {
+			message := lua_string(L, -1)
+			log.warn("plugins:", message)
+			set_message(state, "Plugin error: %s", message)
+			lua.pop(L, 1)
+			continue
+		}
+		reply = strings.clone(lua_string(L, -2), context.temp_allocator)
+		known: bool
+		if encoding, known = editor.parse_position_encoding(lua_string(L, -1)); !known {
+			encoding = .Utf16
+		}
+		lua.pop(L, 2)
+		if reply != "" {
+			return reply, encoding, true
+		}
+	}
+	return "", .Utf16, false
+}
+
+// Asks the rune.document_color providers, in the order they were added, for
+// the colors of the buffer; the first to answer wins.  Its reply is the JSON
+// of LSP ColorInformation items.  Temp allocated.
+plugin_document_colors :: proc(
+	state: ^Editor_State,
+) -> (
+	reply: string,
+	provider: Color_Provider,
+	encoding: editor.Position_Encoding,
+) {
+	p := &state.plugins
+	if p.lua == nil {
+		return "", {}, .Utf16
+	}
+	L := p.lua
+	providers := slice.clone(p.colorers[:], context.temp_allocator)
+	for cp in providers {
+		lua.rawgeti(L, lua.REGISTRYINDEX, lua.Integer(cp.colors))
+		push_lua_string(L, state.path)
+		if lua.pcall(L, 1, 2, 0) != .OK {
+			message := lua_string(L, -1)
+			log.warn("plugins:", message)
+			set_message(state, "Plugin error: %s", message)
+			lua.pop(L, 1)
+			continue
+		}
+		reply = strings.clone(lua_string(L, -2), context.temp_allocator)
+		known: bool
+		if encoding, known = editor.parse_position_encoding(lua_string(L, -1)); !known {
+			encoding = .Utf16
+		}
+		lua.pop(L, 2)
+		if reply != "" {
+			return reply, cp, encoding
+		}
+	}
+	return "", {}, .Utf16
+}
+
+// Asks the rune.document_symbol providers, in the order they were added,
+// for the symbols of the buffer; the first to answer wins.  Its reply is the
+// JSON of LSP DocumentSymbol or SymbolInformation items.  Temp allocated.
+plugin_document_symbols :: proc(
+	state: ^Editor_State,
+) -> (
+	reply: string,
+	encoding: editor.Position_Encoding,
+) {
+	p := &state.plugins
+	if p.lua == nil {
+		return "", .Utf16
+	}
+	L := p.lua
+	providers := slice.clone(p.symbolers[:], context.temp_allocator)
+	for ref in providers {
+		lua.rawgeti(L, lua.REGISTRYINDEX, lua.Integer(ref))
+		push_lua_string(L, state.path)
+		if lua.pcall(L, 1, 2, 0) != .OK {
+			message := lua_string(L, -1)
+			log.warn("plugins:", message)
+			set_message(state, "Plugin error: %s", message)
+			lua.pop(L, 1)
+			continue
+		}
+		reply = strings.clone(lua_string(L, -2), context.temp_allocator)
+		known: bool
+		if encoding, known = editor.parse_position_encoding(lua_string(L, -1)); !known {
+			encoding = .Utf16
+		}
+		lua.pop(L, 2)
+		if reply != "" {
+			return reply, encoding
+		}
+	}
+	return "", .Utf16
+}
+
+// Asks provider how to write a color, params being the JSON of LSP
+// ColorPresentationParams without the document; "" when it cannot.  Temp
+// allocated.
+plugin_color_presentation :: proc(
+	state: ^Editor_State,
+	provider: Color_Provider,
+	params: string,
+) -> string {
+	p := &state.plugins
+	if p.lua == nil || provider.present == lua.NOREF {
+		return ""
+	}
+	L := p.lua
+	lua.rawgeti(L, lua.REGISTRYINDEX, lua.Integer(provider.present))
+	push_lua_string(L, state.path)
+	push_lua_string(L, params)
+	if lua.pcall(L, 2, 1, 0) != .OK {
+		message := lua_string(L, -1)
+		log.warn("plugins:", message)
+		set_message(state, "Plugin error: %s", message)
+		lua.pop(L, 1)
+		return ""
+	}
+	reply := strings.clone(lua_string(L, -1), context.temp_allocator)
+	lua.pop(L, 1)
+	return reply
+}
+
+// Runs the Lua function of a command a plugin registered.
+run_plugin_command :: proc(state: ^Editor_State, ref: c.int) {
+	p := &state.plugins
+	if p.lua == nil {
+		return
+	}
+	lua.rawgeti(p.lua, lua.REGISTRYINDEX, lua.Integer(ref))
+	call_plugin(state, 0)
+}
+
+// Calls the function below nargs arguments on the Lua stack, reporting an
+// error in the status line and the log.
+@(private = "file")
+call_plugin :: proc(state: ^Editor_State, nargs: c.int) -> bool {
+	L := state.plugins.lua
+	if lua.pcall(L, nargs, 0, 0) != .OK {
+		message := lua_string(L, -1)
+		log.warn("plugins:", message)
+		set_message(state, "Plugin error: %s", message)
+		lua.pop(L, 1)
+		return false
+	}
+	return true
+}
+
+@(private = "file")
+run_plugin_file :: proc(state: ^Editor_State, path: string) -> bool {
+	L := state.plugins.lua
+	cpath := strings.clone_to_cstring(path, context.temp_allocator)
+	if lua.L_loadfile(L, cpath) != .OK {
+		log.warn("plugins:", lua_string(L, -1))
+		lua.pop(L, 1)
+		return false
+	}
+	return call_plugin(state, 0)
+}
+
+// The scripts under the plugins dir, sorted.  Temp allocated.
+@(private = "file")
+plugin_files :: proc(dir: string) -> []string {
+	entries, err := os.read_all_directory_by_path(dir, context.temp_allocator)
+	if err != nil {
+		return nil
+	}
+	files := make([dynamic]string, context.temp_allocator)
+	for e in entries {
+		if e.type == .Directory {
+			init := filepath.join({e.fullpath, INIT_SCRIPT}, context.temp_allocator)
+			if os.exists(init) {
+				append(&files, init)
+			}
+		} else if filepath.ext(e.name) == ".lua" {
+			append(&files, e.fullpath)
+		}
+	}
+	slice.sort(files[:])
+	return files[:]
+}
+
+// Pushes the rune table.  Positions are 1 based lines and byte columns, as
+// Lua strings index bytes from 1.
+@(private = "file")
+push_plugin_api :: proc(state: ^Editor_State) {
+	L := state.plugins.lua
+	API :: [?]struct {
+		name: cstring,
+		fn:   lua.CFunction,
+	} {
+		{"command", lua_command},
+		{"bind", lua_bind},
+		{"run", lua_run},
+		{"on", lua_on},
+		{"prompt", lua_prompt},
+		{"message", lua_message},
+		{"text", lua_text},
+		{"line", lua_line},
+		{"line_count", lua_line_count},
+		{"selection", lua_selection},
+		{"insert", lua_insert},
+		{"cursor", lua_cursor},
+		{"set_cursor", lua_set_cursor},
+		{"path", lua_path},
+		{"language", lua_language},
+		{"apply_edit", lua_apply_edit},
+		{"inline_completion", lua_inline_completion},
+		{"selection_range", lua_selection_range},
+		{"on_type_format", lua_on_type_format},
+		{"document_color", lua_document_color},
+		{"document_symbol", lua_document_symbol},
+		{"server_options", lua_server_options},
+	}
+	lua.createtable(L, 0, len(API) + 1)
+	for f in API {
+		lua.pushlightuserdata(L, state)
+		lua.pushcclosure(L, f.fn, 1)
+		lua.setfield(L, -2, f.name)
+	}
+	lua.pushinteger(L, PLUGIN_API_VERSION)
+	lua.setfield(L, -2, "api_version")
+}
+
+// The editor of a rune function, kept as its upvalue.
+@(private = "file")
+lua_editor :: proc "contextless" (L: ^lua.State) -> ^Editor_State {
+	return cast(^Editor_State)lua.touserdata(L, lua.upvalueindex(1))
+}
+
+// The string at index i, borrowed from Lua.
+@(private = "file")
+lua_string :: proc "contextless" (L: ^lua.State, i: c.int) -> string {
+	n: c.size_t
+	s := lua.tolstring(L, i, &n)
+	if s == nil {
+		return ""
+	}
+	return string((cast([^]u8)s)[:n])
+}
+
+@(private = "file")
+check_lua_string :: proc "contextless" (L: ^lua.State, i: c.int) -> string {
+	n: c.size_t
+	s := lua.L_checklstring(L, i, &n)
+	return string((cast([^]u8)s)[:n])
+}
+
+@(private = "file")
+push_lua_string :: proc "contextless" (L: ^lua.State, s: string) {
+	lua.pushlstring(L, cstring(raw_data(s)), c.size_t(len(s)))
+}
+
+// rune.command(name, description, fn) adds fn as a command, which key
+// bindings and rune.run can then run.  A plugin may replace its own
+// commands but not the built-in ones.
+@(private = "file")
+lua_command :: proc "c" (L: ^lua.State) -> c.int {
+	context = callback_context()
+	state := lua_editor(L)
+	name := check_lua_string(L, 1)
+	description := check_lua_string(L, 2)
+	lua.L_checktype(L, 3, .FUNCTION)
+	pc: ^Plugin_Command
+	if old, ok := state.commands[name]; ok {
+		if old.script == 0 {
+			return lua.L_error(L, "%s is a built-in command", lua.tostring(L, 1))
+		}
+		lua.L_unref(L, lua.REGISTRYINDEX, old.script)
+		for other in state.plugins.commands {
+			if other.name == name {
+				pc = other
+			}
+		}
+		delete(pc.description)
+	} else {
+		pc = new(Plugin_Command)
+		pc.name = strings.clone(name)
+		append(&state.plugins.commands, pc)
+	}
+	pc.description = strings.clone(description)
+	lua.pushvalue(L, 3)
+	state.commands[pc.name] = Command {
+		name        = pc.name,
+		description = pc.description,
+		kind        = .Action,
+		script      = lua.L_ref(L, lua.REGISTRYINDEX),
+	}
+	return 0
+}
+
+// rune.bind(keys, command [, mode [, language]]) binds a key sequence, e.g.
+// "ctrl+k x", like an entry of keymap.json.
+@(private = "file")
+lua_bind :: proc "c" (L: ^lua.State) -> c.int {
+	context = callback_context()
+	state := lua_editor(L)
+	keys := check_lua_string(L, 1)
+	command := check_lua_string(L, 2)
+	mode := lua_string(L, 3)
+	language := lua_string(L, 4)
+	if !editor.bind_key(&state.keymap, keys, command, mode, language) {
+		return lua.L_error(L, "cannot parse the keys %s", lua.tostring(L, 1))
+	}
+	return 0
+}
+
+// rune.run(name) runs a command and returns whether it exists.
+@(private = "file")
+lua_run :: proc "c" (L: ^lua.State) -> c.int {
+	context = callback_context()
+	state := lua_editor(L)
+	lua.pushboolean(L, b32(run_command(state, check_lua_string(L, 1))))
+	return 1
+}
+
+// rune.on(event, fn) calls fn(path) on an event of Plugin_Event, named in
+// lower case.
+@(private = "file")
+lua_on :: proc "c" (L: ^lua.State) -> c.int {
+	context = callback_context()
+	state := lua_editor(L)
+	name := check_lua_string(L, 1)
+	lua.L_checktype(L, 2, .FUNCTION)
+	for event_name, event in PLUGIN_EVENT_NAMES {
+		if event_name == name {
+			lua.pushvalue(L, 2)
+			append(&state.plugins.handlers[event], lua.L_ref(L, lua.REGISTRYINDEX))
+			return 0
+		}
+	}
+	return lua.L_error(L, "unknown event %s", lua.tostring(L, 1))
+}
+
+// rune.prompt(label, fn [, initial]) asks for a line of text and calls
+// fn(text) when it is submitted.
+@(private = "file")
+lua_prompt :: proc "c" (L: ^lua.State) -> c.int {
+	context = callback_context()
+	state := lua_editor(L)
+	label := check_lua_string(L, 1)
+	lua.L_checktype(L, 2, .FUNCTION)
+	initial := lua_string(L, 3)
+	p := &state.plugins
+	if p.prompt_ref != lua.NOREF {
+		lua.L_unref(L, lua.REGISTRYINDEX, p.prompt_ref)
+	}
+	lua.pushvalue(L, 2)
+	p.prompt_ref = lua.L_ref(L, lua.REGISTRYINDEX)
+	// The prompt borrows its label, so keep it until the next one.
+	delete(p.prompt_label)
+	p.prompt_label = strings.clone(label)
+	open_prompt(state, p.prompt_label, proc(state: ^Editor_State, text: string) {
+		p := &state.plugins
+		ref := p.prompt_ref
+		p.prompt_ref = lua.NOREF
+		lua.rawgeti(p.lua, lua.REGISTRYINDEX, lua.Integer(ref))
+		lua.L_unref(p.lua, lua.REGISTRYINDEX, ref)
+		push_lua_string(p.lua, text)
+		editor.begin_undo_group(&state.undo, state.cursor_pos)
+		call_plugin(state, 1)
+		editor.end_undo_group(&state.undo, state.cursor_pos)
+	}, initial = initial)
+	return 0
+}
+
+// rune.message(text) shows text in the status line.
+@(private = "file")
+lua_message :: proc "c" (L: ^lua.State) -> c.int {
+	context = callback_context()
+	set_message(lua_editor(L), "%s", check_lua_string(L, 1))
+	return 0
+}
+
+// rune.text() returns the whole buffer.
+@(private = "file")
+lua_text :: proc "c" (L: ^lua.State) -> c.int {
+	context = callback_context()
+	state := lua_editor(L)
+	push_lua_string(L, editor.get_text(&state.buffer, context.temp_allocator))
+	return 1
+}
+
+// rune.line(n) returns line n without its line break, or nil past the end.
+@(private = "file")
+lua_line :: proc "c" (L: ^lua.State) -> c.int {
+	context = callback_context()
+	state := lua_editor(L)
+	n := int(lua.L_checkinteger(L, 1))
+	if n < 1 || n > editor.get_line_count(&state.buffer) {
+		lua.pushnil(L)
+		return 1
+	}
+	push_lua_string(L, editor.get_line(&state.buffer, n - 1, context.temp_allocator))
+	return 1
+}
+
+@(private = "file")
+lua_line_count :: proc "c" (L: ^lua.State) -> c.int {
+	context = callback_context()
+	lua.pushinteger(L, lua.Integer(editor.get_line_count(&lua_editor(L).buffer)))
+	return 1
+}
+
+// rune.selection() returns the selected text, or nil.
+@(private = "file")
+lua_selection :: proc "c" (L: ^lua.State) -> c.int {
+	context = callback_context()
+	state := lua_editor(L)
+	if !has_selection(state) {
+		lua.pushnil(L)
+		return 1
+	}
+	start, end := selection_range(state)
+	push_lua_string(
+		L,
+		editor.get_text_segment(&state.buffer, start, end - start, context.temp_allocator),
+	)
+	return 1
+}
+
+// rune.insert(text) types text at the cursor, replacing the selection.
+@(private = "file")
+lua_insert :: proc "c" (L: ^lua.State) -> c.int {
+	context = callback_context()
+	state := lua_editor(L)
+	text := check_lua_string(L, 1)
+	if is_read_only(state) {
+		return lua.L_error(L, "the buffer is read-only")
+	}
+	insert_bytes_at_cursor(state, transmute([]u8)text)
+	return 0
+}
+
+// rune.cursor() returns the cursor's line and column.
+@(private = "file")
+lua_cursor :: proc "c" (L: ^lua.State) -> c.int {
+	context = callback_context()
+	state := lua_editor(L)
+	lua.pushinteger(L, lua.Integer(state.cursor_data.line + 1))
+	lua.pushinteger(L, lua.Integer(state.cursor_data.col + 1))
+	return 2
+}
+
+// rune.set_cursor(line, col) moves the cursor, dropping the selection.
+@(private = "file")
+lua_set_cursor :: proc "c" (L: ^lua.State) -> c.int {
+	context = callback_context()
+	state := lua_editor(L)
+	line := int(lua.L_checkinteger(L, 1)) - 1
+	col := int(lua.L_optinteger(L, 2, 1)) - 1
+	line = clamp(line, 0, editor.get_line_count(&state.buffer) - 1)
+	col = clamp(col, 0, editor.get_line_length(&state.buffer, line))
+	goto_line_col(state, line, col)
+	return 0
+}
+
+// rune.path() returns the buffer's file, or nil for an unnamed buffer.
+@(private = "file")
+lua_path :: proc "c" (L: ^lua.State) -> c.int {
+	context = callback_context()
+	state := lua_editor(L)
+	if state.path == "" {
+		lua.pushnil(L)
+	} else {
+		push_lua_string(L, state.path)
+	}
+	return 1
+}
+
+// rune.apply_edit(json, label, encoding) applies a language server
+// WorkspaceEdit, see workspace_edit.odin, and returns true, or false and why
+// it was not.  When the user has to confirm it first, true means they were
+// asked.  encoding is how positions count characters, "utf-16" unless given;
+// "utf-8" counts bytes like the rest of the rune table.
+@(private = "file")
+lua_apply_edit :: proc "c" (L: ^lua.State) -> c.int {
+	context = callback_context()
+	state := lua_editor(L)
+	text := check_lua_string(L, 1)
+	label := lua_string(L, 2)
+	encoding := editor.Position_Encoding.Utf16
+	if name := lua_string(L, 3); name != "" {
+		known: bool
+		if encoding, known = editor.parse_position_encoding(name); !known {
+			return lua.L_error(L, "unknown position encoding %s", lua.tostring(L, 3))
+		}
+	}
+	ok, err := apply_workspace_edit(state, text, label if label != "" else "Edit", encoding)
+	lua.pushboolean(L, b32(ok))
+	if ok {
+		return 1
+	}
+	push_lua_string(L, err)
+	return 2
+}
+
+// rune.inline_completion(fn) adds a provider of suggestions shown after the
+// cursor: fn(path, line, col) returns the text to suggest, or nil.  See
+// inline_completion.odin.
+@(private = "file")
+lua_inline_completion :: proc "c" (L: ^lua.State) -> c.int {
+	context = callback_context()
+	state := lua_editor(L)
+	lua.L_checktype(L, 1, .FUNCTION)
+	lua.pushvalue(L, 1)
+	append(&state.plugins.suggesters, lua.L_ref(L, lua.REGISTRYINDEX))
+	return 0
+}
+
+// rune.selection_range(fn) adds a provider of ranges for select.expand to
+// grow through: fn(path, line, col) returns the JSON of an LSP SelectionRange
+// at that place, as a server answers, or nil, and may return its position
+// encoding after it, "utf-16" by default.
+@(private = "file")
+lua_selection_range :: proc "c" (L: ^lua.State) -> c.int {
+	context = callback_context()
+	state := lua_editor(L)
+	lua.L_checktype(L, 1, .FUNCTION)
+	lua.pushvalue(L, 1)
+	append(&state.plugins.selectors, lua.L_ref(L, lua.REGISTRYINDEX))
+	return 0
+}
+
+// rune.on_type_format(triggers, fn) adds a formatter run as one of the
+// characters of the string triggers is typed, "\n" for a line break:
+// fn(path, line, col, ch), with the cursor after ch, returns the JSON of the
+// TextEdits a server answers textDocument/onTypeFormatting with, or nil, and
+// may return their position encoding after it, "utf-16" by default.
+@(private = "file")
+lua_on_type_format :: proc "c" (L: ^lua.State) -> c.int {
+	context = callback_context()
+	state := lua_editor(L)
+	triggers := check_lua_string(L, 1)
+	lua.L_checktype(L, 2, .FUNCTION)
+	lua.pushvalue(L, 2)
+	ref := lua.L_ref(L, lua.REGISTRYINDEX)
+	append(&state.plugins.formatters, Type_Formatter{strings.clone(triggers), ref})
+	return 0
+}
+
+// rune.document_color(fn, present) adds a provider of the colors in the
+// buffer, for swatches and color.edit: fn(path) returns the JSON of the LSP
+// ColorInformation items a server answers textDocument/documentColor with,
+// or nil, and may return their position encoding after it, "utf-16" by
+// default.  present(path, params), optional, returns the JSON of the
+// ColorPresentation items for params, those of textDocument/colorPresentation
+// without textDocument; without it a picked color is written as #RRGGBB.
+@(private = "file")
+lua_document_color :: proc "c" (L: ^lua.State) -> c.int {
+	context = callback_context()
+	state := lua_editor(L)
+	lua.L_checktype(L, 1, .FUNCTION)
+	present: c.int = lua.NOREF
+	if !lua.isnoneornil(L, 2) {
+		lua.L_checktype(L, 2, .FUNCTION)
+		lua.pushvalue(L, 2)
+		present = lua.L_ref(L, lua.REGISTRYINDEX)
+	}
+	lua.pushvalue(L, 1)
+	colors := lua.L_ref(L, lua.REGISTRYINDEX)
+	append(&state.plugins.colorers, Color_Provider{colors, present})
+	return 0
+}
+
+// rune.document_symbol(fn) adds a provider of the symbols in the buffer, for
+// the breadcrumbs: fn(path) returns the JSON of the LSP DocumentSymbol or
+// SymbolInformation items a server answers textDocument/documentSymbol with,
+// or nil, and may return their position encoding after it, "utf-16" by
+// default.
+@(private = "file")
+lua_document_symbol :: proc "c" (L: ^lua.State) -> c.int {
+	context = callback_context()
+	state := lua_editor(L)
+	lua.L_checktype(L, 1, .FUNCTION)
+	lua.pushvalue(L, 1)
+	append(&state.plugins.symbolers, lua.L_ref(L, lua.REGISTRYINDEX))
+	return 0
+}
+
+// rune.server_options(name) returns the JSON server name is initialized
+// with, lsp.initialization_options in its [server.<name>] table, or nil.
+@(private = "file")
+lua_server_options :: proc "c" (L: ^lua.State) -> c.int {
+	context = callback_context()
+	options, err := server_initialization_options(lua_editor(L), check_lua_string(L, 1))
+	if options == "" {
+		lua.pushnil(L)
+		if err != "" {
+			push_lua_string(L, err)
+			return 2
+		}
+		return 1
+	}
+	push_lua_string(L, options)
+	return 1
+}
+
+// rune.language() returns the buffer's language id, e.g. "odin".
+@(private = "file")
+lua_language :: proc "c" (L: ^lua.State) -> c.int {
+	context = callback_context()
+	push_lua_string(L, lua_editor(L).language)
+	return 1
+}
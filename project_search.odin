@@ -0,0 +1,178 @@
+package main
+
+import "core:fmt"
+import "core:path/filepath"
+import "core:strings"
+import editor "editor"
+
+SEARCH_CONTEXT_LINES :: 1
+SEARCH_MAX_MATCHES :: 10_000
+
+Search_State :: struct {
+	running:  ^editor.Project_Search,
+	pattern:  string,
+	files:    int,
+	matches:  int,
+	quickfix: int, // the quickfix list of the matches, 0 for a replace preview
+}
+
+// Prompts for a regex and searches the workspace with it.
+search_project :: proc(state: ^Editor_State) {
+	open_prompt(state, "Search:", start_project_search, initial = state.search.pattern)
+}
+
+start_project_search :: proc(state: ^Editor_State, pattern: string) {
+	if pattern == "" {
+		return
+	}
+	clear_replace(state)
+	if begin_project_search(state, pattern, SEARCH_CONTEXT_LINES) {
+		editor.panel_set_title(state.panel_data, fmt.tprintf("Searching for %q ...", pattern))
+		state.search.quickfix = begin_quickfix(state, fmt.tprintf("Search %q", pattern), "")
+	}
+}
+
+// Starts a background search and clears the panel for its results.
+begin_project_search :: proc(state: ^Editor_State, pattern: string, context_lines: int) -> bool {
+	stop_project_search(state)
+
+	running, err := editor.start_project_search(
+		{
+			pattern = pattern,
+			root = state.workspace_root,
+			extra_roots = state.projects.folders[:],
+			context_lines = context_lines,
+			max_matches = config_int(state, "search.max_matches"),
+		},
+	)
+	if err != nil {
+		set_message(state, "Invalid search pattern: %v", err)
+		return false
+	}
+
+	release_panel(state)
+	delete(state.search.pattern)
+	state.search.pattern = strings.clone(pattern)
+	state.search.running = running
+	state.search.files = 0
+	state.search.matches = 0
+	state.search.quickfix = 0
+
+	editor.panel_clear(state.panel_data)
+	show_panel(state)
+	return true
+}
+
+stop_project_search :: proc(state: ^Editor_State) {
+	if state.search.running != nil {
+		editor.destroy_project_search(state.search.running)
+		state.search.running = nil
+	}
+}
+
+// Drains results finished since the last frame into the panel, grouped by
+// file with context lines around every match.
+poll_project_search :: proc(state: ^Editor_State) {
+	running := state.search.running
+	if running == nil {
+		return
+	}
+
+	results := make([dynamic]editor.Search_File_Result, context.temp_allocator)
+	editor.take_search_results(running, &results)
+	for r in results {
+		if state.replace.active {
+			add_replace_preview(state, r)
+		} else {
+			add_search_result(state, r)
+		}
+		editor.destroy_search_file_result(r, running.allocator)
+	}
+
+	if editor.is_search_finished(running) {
+		stop_project_search(state)
+		if state.replace.active {
+			set_replace_title(state)
+			return
+		}
+		title := fmt.tprintf(
+			"%q: %d matches in %d files",
+			state.search.pattern,
+			state.search.matches,
+			state.search.files,
+		)
+		editor.panel_set_title(state.panel_data, title)
+		set_quickfix_title(state, state.search.quickfix, title)
+	}
+}
+
+@(private = "file")
+add_search_result :: proc(state: ^Editor_State, r: editor.Search_File_Result) {
+	panel := state.panel_data
+	state.search.files += 1
+	state.search.matches += len(r.matches)
+
+	editor.panel_add_item(
+		panel,
+		{
+			text = fmt.tprintf("%s (%d)", display_path(state, r.path), len(r.matches)),
+			style = .Header,
+		},
+	)
+
+	last_line := -1 // last line already listed, so context never repeats
+	for m in r.matches {
+		text := strings.trim_space(m.text)
+		add_quickfix(state, state.search.quickfix, r.path, m.line, m.col, text)
+		for text, i in m.before {
+			ln := m.line - len(m.before) + i
+			if ln > last_line {
+				editor.panel_add_item(panel, {text = fmt.tprintf("%6d- %s", ln + 1, text), style = .Dim})
+				last_line = ln
+			}
+		}
+		if m.line > last_line {
+			editor.panel_add_item(
+				panel,
+				{
+					text = fmt.tprintf("%6d: %s", m.line + 1, m.text),
+					path = r.path,
+					line = m.line,
+					col = m.col,
+				},
+			)
+			last_line = m.line
+		}
+		for text, i in m.after {
+			ln := m.line + 1 + i
+			if ln > last_line {
+				editor.panel_add_item(panel, {text = fmt.tprintf("%6d- %s", ln + 1, text), style = .Dim})
+				last_line = ln
+			}
+		}
+	}
+}
+
+// Resolves a path relative to the workspace root.  Temp allocated.
+workspace_path :: proc(state: ^Editor_State, path: string) -> string {
+	if filepath.is_abs(path) {
+		return path
+	}
+	return filepath.join({state.workspace_root, path}, context.temp_allocator)
+}
+
+// Opens `path` (relative paths resolve against the workspace root) and moves
+// the cursor to line/col.  A negative line keeps the document's own cursor.
+jump_to_location :: proc(state: ^Editor_State, path: string, line, col: int) -> bool {
+	record_jump(state)
+	full := workspace_path(state, path)
+	if !is_open_file(state, full) {
+		if !open_file(state, full) {
+			return false
+		}
+	}
+	if line >= 0 {
+		goto_line_col(state, line, col)
+	}
+	return true
+}
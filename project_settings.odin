@@ -0,0 +1,231 @@
+package main
+
+import "core:log"
+import "core:os"
+import "core:path/filepath"
+import "core:strconv"
+import "core:strings"
+import editor "editor"
+
+// What a project defines besides options, as tables of its workspace config
+// file (WORKSPACE_CONFIG_FILE), read when the workspace opens:
+//
+//	[[tasks]]
+//	name = "build"
+//	command = "odin build ."
+//
+//	[test_runners.go]
+//	file = "go test ./{dir}"
+//
+// Everything is optional; a missing file means none.  Tasks, test runners
+// and linters run only once the user trusts the workspace, as do the config
+// options that run commands; see workspace_trusted.
+Project_Settings :: struct {
+	tasks:         [dynamic]Task_Config, // [[tasks]], see tasks.odin
+	test_runners:  map[string]Test_Runner, // [test_runners.<id>], see test_explorer.odin
+	linters:       [dynamic]Linter_Config, // [[linters]], run on save, see lint.odin
+	abbreviations: [dynamic]Abbreviation, // [[abbreviations]], see abbreviations.odin
+}
+
+// The top-level tables of the workspace config file that Project_Settings
+// holds; the config layer skips them.
+PROJECT_TABLES := [?]string{"tasks", "test_runners", "linters", "abbreviations"}
+
+TRUST_FILE :: "trusted" // per workspace, see workspace_state_path
+
+load_project_settings :: proc(state: ^Editor_State) {
+	state.project = {}
+	path := workspace_path(state, WORKSPACE_CONFIG_FILE)
+	data, err := os.read_entire_file_from_path(path, context.temp_allocator)
+	if err != nil {
+		return
+	}
+	entries, _, ok := editor.parse_toml(string(data))
+	if !ok {
+		return // read_config_layer logs it
+	}
+	p := &state.project
+	for e in entries {
+		table, _, rest := strings.partition(e.key, ".")
+		name, _, field := strings.partition(rest, ".")
+		fits := false
+		switch table {
+		case "tasks":
+			if task := table_element(&p.tasks, name); task != nil {
+				switch field {
+				case "name":
+					fits = set_toml_string(&task.name, e.value)
+				case "command":
+					fits = set_toml_string(&task.command, e.value)
+				case "cwd":
+					fits = set_toml_string(&task.cwd, e.value)
+				case "errorformat":
+					fits = set_toml_strings(&task.errorformat, e.value)
+				}
+			}
+		case "test_runners":
+			if name == "" {
+				break
+			}
+			if name not_in p.test_runners {
+				p.test_runners[strings.clone(name)] = {}
+			}
+			runner := &p.test_runners[name]
+			switch field {
+			case "file":
+				fits = set_toml_string(&runner.file, e.value)
+			case "test":
+				fits = set_toml_string(&runner.test, e.value)
+			}
+		case "linters":
+			if linter := table_element(&p.linters, name); linter != nil {
+				switch field {
+				case "name":
+					fits = set_toml_string(&linter.name, e.value)
+				case "command":
+					fits = set_toml_string(&linter.command, e.value)
+				case "languages":
+					fits = set_toml_strings(&linter.languages, e.value)
+				case "parser":
+					fits = set_toml_string(&linter.parser, e.value)
+				case "errorformat":
+					fits = set_toml_strings(&linter.errorformat, e.value)
+				}
+			}
+		case "abbreviations":
+			if a := table_element(&p.abbreviations, name); a != nil {
+				switch field {
+				case "trigger":
+					fits = set_toml_string(&a.trigger, e.value)
+				case "expansion":
+					fits = set_toml_string(&a.expansion, e.value)
+				case "language":
+					fits = set_toml_string(&a.language, e.value)
+				}
+			}
+		case:
+			continue
+		}
+		if !fits {
+			log.warnf("config: %s:%d: cannot use %s = %v", path, e.line, e.key, e.value)
+		}
+	}
+	commands := len(p.tasks) + len(p.test_runners) + len(p.linters)
+	if commands > 0 && !workspace_trusted(state) {
+		set_message(state, "%s defines commands; workspace.trust runs them", path)
+	}
+}
+
+// Whether the user trusts the workspace's config file to run commands.  A
+// cloned repository could otherwise run anything it likes on open or save.
+workspace_trusted :: proc(state: ^Editor_State) -> bool {
+	path, ok := workspace_state_path(state, TRUST_FILE)
+	return ok && os.exists(path)
+}
+
+// workspace.trust: lets the workspace's config file run commands from now on.
+trust_workspace :: proc(state: ^Editor_State) {
+	if err := set_workspace_trust(state, true); err != nil {
+		set_message(state, "Cannot record the trust in %s: %v", state.workspace_root, err)
+		return
+	}
+	apply_config(state)
+	set_message(state, "Trusting %s", state.workspace_root)
+}
+
+// workspace.untrust: stops the workspace's config file running commands.
+untrust_workspace :: proc(state: ^Editor_State) {
+	if err := set_workspace_trust(state, false); err != nil {
+		set_message(state, "Cannot drop the trust in %s: %v", state.workspace_root, err)
+		return
+	}
+	apply_config(state)
+	set_message(state, "Not trusting %s", state.workspace_root)
+}
+
+// Records whether the user trusts the workspace and reads its config file
+// again, so that the options that run commands take effect, or stop, at
+// once rather than on the next start.
+set_workspace_trust :: proc(state: ^Editor_State, trusted: bool) -> os.Error {
+	path, ok := workspace_state_path(state, TRUST_FILE)
+	if !ok {
+		return os.General_Error.Not_Exist
+	}
+	if trusted {
+		_ = os.make_directory_all(filepath.dir(path, context.temp_allocator))
+		write_file_atomic(path, nil) or_return
+	} else if os.exists(path) {
+		os.remove(path) or_return
+	}
+	read_workspace_config(state)
+	return nil
+}
+
+destroy_project_settings :: proc(state: ^Editor_State) {
+	for task in state.project.tasks {
+		destroy_task_config(task)
+	}
+	delete(state.project.tasks)
+	for id, runner in state.project.test_runners {
+		delete(id)
+		destroy_test_runner(runner)
+	}
+	delete(state.project.test_runners)
+	for linter in state.project.linters {
+		destroy_linter_config(linter)
+	}
+	delete(state.project.linters)
+	for a in state.project.abbreviations {
+		destroy_abbreviation(a)
+	}
+	delete(state.project.abbreviations)
+	state.project = {}
+}
+
+// The element of an array of tables that index, as parse_toml numbers it,
+// names: the next one is added, any other that is missing is nil.
+@(private = "file")
+table_element :: proc(list: ^[dynamic]$T, index: string) -> ^T {
+	i, ok := strconv.parse_int(index, 10)
+	if !ok || i < 0 || i > len(list^) {
+		return nil
+	}
+	if i == len(list^) {
+		append(list, T{})
+	}
+	return &list^[i]
+}
+
+@(private = "file")
+set_toml_string :: proc(s: ^string, value: editor.Toml_Value) -> bool {
+	v, ok := value.(string)
+	if !ok {
+		return false
+	}
+	delete(s^)
+	s^ = strings.clone(v)
+	return true
+}
+
+@(private = "file")
+set_toml_strings :: proc(list: ^[]string, value: editor.Toml_Value) -> bool {
+	items, ok := value.([]editor.Toml_Value)
+	if !ok {
+		return false
+	}
+	for item in items {
+		if _, is_string := item.(string); !is_string {
+			return false
+		}
+	}
+	for s in list^ {
+		delete(s)
+	}
+	delete(list^)
+	strs := make([]string, len(items))
+	for item, i in items {
+		strs[i] = strings.clone(item.(string))
+	}
+	list^ = strs
+	return true
+}
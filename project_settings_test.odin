@@ -0,0 +1,33 @@
+package main
+
+import "core:os"
+import "core:path/filepath"
+import "core:testing"
+
+@(test)
+test_trust_rereads_workspace_config :: proc(t: ^testing.T) {
+	root, err := os.make_directory_temp("", "rune-trust-*", context.allocator)
+	if !testing.expect_value(t, err, nil) {
+		return
+	}
+	defer delete(root)
+	defer os.remove_all(root)
+	_ = os.make_directory_all(filepath.join({root, ".rune"}, context.temp_allocator))
+	config := filepath.join({root, WORKSPACE_CONFIG_FILE}, context.temp_allocator)
+	text := "format.command = \"fmt-it\"\ntags.auto = false\n"
+	testing.expect_value(t, os.write_entire_file(config, transmute([]u8)text), nil)
+
+	state: Editor_State
+	state.workspace_root = root
+	defer destroy_config(&state)
+	read_workspace_config(&state)
+	testing.expect_value(t, config_value(&state, "format.command").(string) or_else "", "")
+
+	testing.expect_value(t, set_workspace_trust(&state, true), nil)
+	testing.expect_value(t, config_value(&state, "format.command").(string) or_else "", "fmt-it")
+	testing.expect_value(t, config_bool(&state, "tags.auto"), false)
+
+	testing.expect_value(t, set_workspace_trust(&state, false), nil)
+	testing.expect_value(t, config_value(&state, "format.command").(string) or_else "", "")
+	testing.expect_value(t, config_bool(&state, "tags.auto"), true)
+}
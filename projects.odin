@@ -0,0 +1,278 @@
+package main
+
+import "core:encoding/json"
+import "core:fmt"
+import "core:log"
+import "core:os"
+import "core:path/filepath"
+import "core:strings"
+import editor "editor"
+
+PROJECTS_FILE :: "projects.json" // in the user config dir, most recent first
+PROJECTS_MAX :: 30
+
+// Entries that mark a project root, looked for from a file's directory
+// upwards; the nearest directory holding one is the root.
+PROJECT_MARKERS := [?]string{".rune", ".git", "go.mod", "Cargo.toml"}
+
+Project_State :: struct {
+	folders:  [dynamic]string, // added with workspace.add_folder, besides the root
+	active:   bool, // the panel lists the recent projects
+	removing: bool, // the panel lists the folders for workspace.remove_folder
+}
+
+// The root of the project holding path, a file or directory, or "" when no
+// directory above it has a marker.  Temp allocated.
+find_project_root :: proc(path: string) -> string {
+	dir, err := filepath.abs(path, context.temp_allocator)
+	if err != nil {
+		return ""
+	}
+	if !os.is_dir(dir) {
+		dir = filepath.dir(dir, context.temp_allocator)
+	}
+	for {
+		for marker in PROJECT_MARKERS {
+			if os.exists(filepath.join({dir, marker}, context.temp_allocator)) {
+				return dir
+			}
+		}
+		parent := filepath.dir(dir, context.temp_allocator)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// Puts the workspace at the top of the recent projects.
+note_recent_project :: proc(state: ^Editor_State) {
+	path, ok := user_config_path(PROJECTS_FILE)
+	if !ok {
+		return
+	}
+	recent := make([dynamic]string, context.temp_allocator)
+	append(&recent, state.workspace_root)
+	for dir in recent_projects() {
+		if dir != state.workspace_root && len(recent) < PROJECTS_MAX {
+			append(&recent, dir)
+		}
+	}
+	data, err := json.marshal(recent[:], {pretty = true}, context.temp_allocator)
+	if err != nil {
+		return
+	}
+	_ = os.make_directory_all(filepath.dir(path, context.temp_allocator))
+	if werr := write_file_atomic(path, data); werr != nil {
+		log.warnf("projects: %s: %v", path, werr)
+	}
+}
+
+// project.open: lists the recent projects; enter switches to one.
+show_recent_projects :: proc(state: ^Editor_State) {
+	clear_replace(state)
+	stop_project_search(state)
+	release_panel(state)
+	state.projects.active = true
+	panel := state.panel_data
+	editor.panel_clear(panel)
+	editor.panel_set_title(panel, "Recent projects; enter opens")
+	for dir in recent_projects() {
+		if dir == state.workspace_root || !os.is_dir(dir) {
+			continue
+		}
+		text := fmt.tprintf("%-24s %s", filepath.base(dir), dir)
+		editor.panel_add_item(panel, {text = text, path = dir, line = -1})
+	}
+	if len(panel.items) == 0 {
+		editor.panel_set_title(panel, "No other recent projects")
+	}
+	show_panel(state)
+}
+
+// project.open_folder: opens the project holding a directory.
+open_project_prompt :: proc(state: ^Editor_State) {
+	open_prompt(state, "Open project:", proc(state: ^Editor_State, dir: string) {
+		dir := strings.trim_space(dir)
+		if dir == "" {
+			return
+		}
+		if strings.has_prefix(dir, "~/") {
+			home := os.get_env("HOME", context.temp_allocator)
+			dir = filepath.join({home, dir[2:]}, context.temp_allocator)
+		}
+		if !os.is_dir(dir) {
+			set_message(state, "%s is not a directory", dir)
+			return
+		}
+		root := find_project_root(dir)
+		if root == "" {
+			root, _ = filepath.abs(dir, context.temp_allocator)
+		}
+		switch_project(state, root)
+	})
+}
+
+open_recent_project :: proc(state: ^Editor_State) {
+	item := editor.panel_selected_item(state.panel_data)
+	if item == nil || item.path == "" {
+		return
+	}
+	switch_project(state, strings.clone(item.path, context.temp_allocator))
+}
+
+// Saves the session, closes every buffer and brings back the session of the
+// project at dir.  Refuses while a buffer has unsaved edits.
+switch_project :: proc(state: ^Editor_State, dir: string) {
+	if dir == state.workspace_root {
+		set_message(state, "Already in %s", filepath.base(dir))
+		return
+	}
+	modified := editor.is_modified(&state.undo)
+	for &d, i in state.documents {
+		if i != state.active && editor.is_modified(&d.undo) {
+			modified = true
+		}
+	}
+	if modified {
+		set_message(state, "Save or close the modified buffers first")
+		return
+	}
+	save_session(state, state.window)
+	release_panel(state)
+	hide_panel(state)
+	close_all_documents(state)
+	if !set_workspace(state, dir) {
+		set_message(state, "Cannot open %s", dir)
+		return
+	}
+	restore_workspace_session(state, state.window)
+	set_message(state, "Project %s", filepath.base(dir))
+}
+
+destroy_workspace_folders :: proc(state: ^Editor_State) {
+	for dir in state.projects.folders {
+		delete(dir)
+	}
+	clear(&state.projects.folders)
+}
+
+// workspace.add_folder: adds a folder to the workspace, searched along with
+// the root.
+add_folder_prompt :: proc(state: ^Editor_State) {
+	open_prompt(state, "Add folder:", proc(state: ^Editor_State, dir: string) {
+		dir := strings.trim_space(dir)
+		if dir == "" {
+			return
+		}
+		if strings.has_prefix(dir, "~/") {
+			home := os.get_env("HOME", context.temp_allocator)
+			dir = filepath.join({home, dir[2:]}, context.temp_allocator)
+		}
+		full, err := filepath.abs(dir, context.temp_allocator)
+		if err != nil || !os.is_dir(full) {
+			set_message(state, "%s is not a directory", dir)
+			return
+		}
+		if add_workspace_folder(state, full) {
+			set_message(state, "Added %s", filepath.base(full))
+		} else {
+			set_message(state, "%s is already in the workspace", filepath.base(full))
+		}
+	})
+}
+
+// Adds dir, an absolute path, unless it is already a folder of the
+// workspace.
+add_workspace_folder :: proc(state: ^Editor_State, dir: string) -> bool {
+	if dir == state.workspace_root {
+		return false
+	}
+	for f in state.projects.folders {
+		if f == dir {
+			return false
+		}
+	}
+	append(&state.projects.folders, strings.clone(dir))
+	return true
+}
+
+// workspace.remove_folder: lists the added folders; enter removes one.
+show_workspace_folders :: proc(state: ^Editor_State) {
+	if len(state.projects.folders) == 0 {
+		set_message(state, "No folders besides %s", filepath.base(state.workspace_root))
+		return
+	}
+	clear_replace(state)
+	stop_project_search(state)
+	release_panel(state)
+	state.projects.removing = true
+	panel := state.panel_data
+	editor.panel_clear(panel)
+	editor.panel_set_title(panel, "Workspace folders; enter removes")
+	for dir in state.projects.folders {
+		text := fmt.tprintf("%-24s %s", filepath.base(dir), dir)
+		editor.panel_add_item(panel, {text = text, path = dir, line = -1})
+	}
+	show_panel(state)
+}
+
+remove_workspace_folder :: proc(state: ^Editor_State) {
+	item := editor.panel_selected_item(state.panel_data)
+	if item == nil {
+		return
+	}
+	for dir, i in state.projects.folders {
+		if dir == item.path {
+			set_message(state, "Removed %s", filepath.base(dir))
+			delete(dir)
+			ordered_remove(&state.projects.folders, i)
+			break
+		}
+	}
+	show_workspace_folders(state)
+	if len(state.projects.folders) == 0 {
+		hide_panel(state)
+		release_panel(state)
+	}
+}
+
+// A path for display: relative to the root, or to the folder holding it as
+// <folder name>/<path>.  Temp allocated.
+display_path :: proc(state: ^Editor_State, path: string) -> string {
+	if !filepath.is_abs(path) {
+		return path
+	}
+	if rel, err := filepath.rel(state.workspace_root, path, context.temp_allocator); err == nil {
+		if !strings.has_prefix(rel, "..") {
+			return rel
+		}
+	}
+	for dir in state.projects.folders {
+		if rel, err := filepath.rel(dir, path, context.temp_allocator); err == nil {
+			if !strings.has_prefix(rel, "..") {
+				return filepath.join({filepath.base(dir), rel}, context.temp_allocator)
+			}
+		}
+	}
+	return path
+}
+
+// The recent project roots, most recent first.  Temp allocated.
+@(private = "file")
+recent_projects :: proc() -> []string {
+	path, ok := user_config_path(PROJECTS_FILE)
+	if !ok {
+		return nil
+	}
+	data, err := os.read_entire_file_from_path(path, context.temp_allocator)
+	if err != nil {
+		return nil
+	}
+	recent: []string
+	if uerr := json.unmarshal(data, &recent, allocator = context.temp_allocator); uerr != nil {
+		log.warnf("projects: %s: %v", path, uerr)
+		return nil
+	}
+	return recent
+}
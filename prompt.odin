@@ -0,0 +1,136 @@
+package main
+
+import "core:fmt"
+import "core:strings"
+import "core:unicode/utf8"
+
+// Called with the prompt text on submit, and on every edit for live previews.
+Prompt_Proc :: #type proc(state: ^Editor_State, text: string)
+
+// A single line input shown in the status line.  While a prompt is open the
+// keymap runs in "prompt" mode and typed characters go to the prompt.
+Prompt :: struct {
+	active:    bool,
+	label:     string,
+	input:     strings.Builder,
+	on_submit: Prompt_Proc,
+	on_change: Prompt_Proc, // optional
+	on_cancel: Command_Proc, // optional
+	prev_mode: string,
+	on_yes:    Command_Proc, // confirm only
+	on_no:     Command_Proc,
+}
+
+open_prompt :: proc(
+	state: ^Editor_State,
+	label: string,
+	on_submit: Prompt_Proc,
+	on_change: Prompt_Proc = nil,
+	on_cancel: Command_Proc = nil,
+	initial := "",
+) {
+	p := &state.prompt
+	if p.active {
+		close_prompt(state)
+	}
+	close_completion(state)
+	p.active = true
+	p.label = label
+	p.on_submit = on_submit
+	p.on_change = on_change
+	p.on_cancel = on_cancel
+	p.prev_mode = state.mode
+	strings.builder_reset(&p.input)
+	strings.write_string(&p.input, initial)
+	state.mode = "prompt"
+	update_status_line(state)
+}
+
+close_prompt :: proc(state: ^Editor_State) {
+	p := &state.prompt
+	if !p.active {
+		return
+	}
+	p.active = false
+	state.mode = p.prev_mode
+	update_status_line(state)
+}
+
+prompt_text :: proc(state: ^Editor_State) -> string {
+	return strings.to_string(state.prompt.input)
+}
+
+// Status line text for an open prompt.
+prompt_status :: proc(state: ^Editor_State) -> string {
+	return fmt.tprintf("%s %s_", state.prompt.label, prompt_text(state))
+}
+
+prompt_insert_rune :: proc(state: ^Editor_State, r: rune) {
+	strings.write_rune(&state.prompt.input, r)
+	prompt_changed(state)
+}
+
+prompt_delete_backward :: proc(state: ^Editor_State) {
+	text := prompt_text(state)
+	if len(text) == 0 {
+		return
+	}
+	_, size := utf8.decode_last_rune_in_string(text)
+	for _ in 0 ..< size {
+		strings.pop_byte(&state.prompt.input)
+	}
+	prompt_changed(state)
+}
+
+// Runs on_change after the input was edited.
+prompt_changed :: proc(state: ^Editor_State) {
+	if state.prompt.on_change != nil {
+		state.prompt.on_change(state, prompt_text(state))
+	}
+	update_status_line(state)
+}
+
+// Closes the prompt, then hands its text to on_submit.  The text is copied to
+// the temp allocator so on_submit may open another prompt.
+prompt_submit :: proc(state: ^Editor_State) {
+	on_submit := state.prompt.on_submit
+	text := strings.clone(prompt_text(state), context.temp_allocator)
+	close_prompt(state)
+	if on_submit != nil {
+		on_submit(state, text)
+	}
+}
+
+// Asks a yes/no question.  An answer starting with 'y' runs on_yes; anything
+// else, or cancelling, runs on_no.
+confirm :: proc(
+	state: ^Editor_State,
+	question: string,
+	on_yes: Command_Proc,
+	on_no: Command_Proc = nil,
+) {
+	open_prompt(
+		state,
+		question,
+		proc(state: ^Editor_State, answer: string) {
+			p := &state.prompt
+			answer := strings.trim_space(answer)
+			if len(answer) > 0 && (answer[0] == 'y' || answer[0] == 'Y') {
+				p.on_yes(state)
+			} else if p.on_no != nil {
+				p.on_no(state)
+			}
+		},
+		on_cancel = on_no,
+	)
+	state.prompt.on_yes = on_yes
+	state.prompt.on_no = on_no
+}
+
+prompt_cancel :: proc(state: ^Editor_State) {
+	on_cancel := state.prompt.on_cancel
+	close_prompt(state)
+	if on_cancel != nil {
+		on_cancel(state)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import "core:strings"
+import editor "editor"
+
+// Aids for the languages written in sentences (Language.prose: Markdown,
+// plain text and commit messages).  Enter continues a list or quote, and
+// ends it on an empty item; with prose.hard_wrap typing past
+// prose.fill_column breaks the line; prose.reflow fills the paragraph again;
+// the sentence and paragraph motions work in any buffer.
+
+// Bytes looked at either side of the cursor for the sentence motions.
+SENTENCE_REACH :: 16 * 1024
+
+// Enter on a list item or in a quote: starts the next item, or ends the list
+// when the item is empty.  Returns false to leave the line break to
+// insert_newline.
+continue_prose_line :: proc(state: ^Editor_State) -> bool {
+	lang := editor.find_language(state.language)
+	if lang == nil || !lang.prose || has_selection(state) {
+		return false
+	}
+	gb := &state.buffer
+	line, col := editor.logical_pos_to_line_col(gb, state.cursor_pos)
+	text := editor.get_line(gb, line, context.temp_allocator)
+	p := editor.parse_prose_prefix(text)
+	if strings.trim_space(p.text) == "" || col < len(p.text) {
+		return false
+	}
+	if strings.trim_space(text[len(p.text):]) == "" {
+		start := editor.line_col_to_logical_pos(gb, line, 0)
+		editor.delete_bytes_range(gb, start, len(text))
+		state.cursor_pos = start
+		sync_cursor(state)
+		set_preferred_col(state)
+		return true
+	}
+	// Like insert_newline, blanks after the cursor are dropped.
+	end := state.cursor_pos
+	for end < editor.current_length(gb) {
+		b := editor.char_at(gb, end)
+		if b != ' ' && b != '\t' {
+			break
+		}
+		end += 1
+	}
+	editor.delete_bytes_range(gb, state.cursor_pos, end - state.cursor_pos)
+	next := strings.concatenate({"\n", p.next}, context.temp_allocator)
+	insert_bytes_at_cursor(state, transmute([]u8)next)
+	return true
+}
+
+// Breaks the cursor's line at the last blank within prose.fill_column once
+// typing went past it.  Called after a character was typed.
+wrap_prose_line :: proc(state: ^Editor_State, typed: rune) {
+	lang := editor.find_language(state.language)
+	if lang == nil || !lang.prose || typed == ' ' || !config_bool(state, "prose.hard_wrap") {
+		return
+	}
+	fill := config_int(state, "prose.fill_column")
+	if state.cursor_data.visual_col <= fill {
+		return
+	}
+	gb := &state.buffer
+	tab := state.layer_ctx.tab_size
+	line, col := editor.logical_pos_to_line_col(gb, state.cursor_pos)
+	text := editor.get_line(gb, line, context.temp_allocator)
+	p := editor.parse_prose_prefix(text)
+	brk := -1
+	for i in len(p.text) ..< col {
+		if text[i] == ' ' && editor.text_columns(text[:i], tab) <= fill {
+			brk = i
+		}
+	}
+	if brk < 0 {
+		return // one long word
+	}
+	start := brk
+	for start > len(p.text) && text[start - 1] == ' ' {
+		start -= 1
+	}
+	if start == len(p.text) {
+		return
+	}
+	line_start := editor.line_col_to_logical_pos(gb, line, 0)
+	inserted := strings.concatenate({"\n", p.hanging}, context.temp_allocator)
+	editor.replace_bytes(gb, line_start + start, brk + 1 - start, transmute([]u8)inserted)
+	state.cursor_pos += len(inserted) - (brk + 1 - start)
+	sync_cursor(state)
+	set_preferred_col(state)
+}
+
+// prose.reflow: fills the paragraph at the cursor to prose.fill_column,
+// keeping its list marker or quote and the cursor on the same word.
+reflow_paragraph :: proc(state: ^Editor_State) {
+	gb := &state.buffer
+	first, last, ok := prose_paragraph(gb, state.cursor_data.line)
+	if !ok {
+		set_message(state, "No paragraph at the cursor")
+		return
+	}
+	p := editor.parse_prose_prefix(editor.get_line(gb, first, context.temp_allocator))
+	words := make([dynamic]string, context.temp_allocator)
+	for l in first ..= last {
+		text := editor.get_line(gb, l, context.temp_allocator)
+		body := text[len(editor.parse_prose_prefix(text).text):]
+		append(&words, ..strings.fields(body, context.temp_allocator))
+	}
+	start := editor.line_col_to_logical_pos(gb, first, 0)
+	end := editor.line_col_to_logical_pos(gb, last, editor.get_line_length(gb, last))
+	old := editor.get_text_segment(gb, start, end - start, context.temp_allocator)
+	fill := config_int(state, "prose.fill_column")
+	filled := editor.fill_words(words[:], p.text, p.hanging, fill, state.layer_ctx.tab_size)
+	if filled == old {
+		return
+	}
+	// The cursor stays after as many non-blank bytes as it was.
+	kept := 0
+	for i in 0 ..< clamp(state.cursor_pos - start, 0, len(old)) {
+		if !strings.is_space(rune(old[i])) {
+			kept += 1
+		}
+	}
+	editor.replace_bytes(gb, start, end - start, transmute([]u8)filled)
+	pos := 0
+	for pos < len(filled) && kept > 0 {
+		if !strings.is_space(rune(filled[pos])) {
+			kept -= 1
+		}
+		pos += 1
+	}
+	state.selection_anchor = -1
+	state.cursor_pos = start + pos
+	sync_cursor(state)
+	set_preferred_col(state)
+}
+
+move_sentence_next :: proc(state: ^Editor_State) {
+	move_sentence(state, true)
+}
+
+move_sentence_prev :: proc(state: ^Editor_State) {
+	move_sentence(state, false)
+}
+
+// To the blank line after the paragraph, or the end of the buffer.
+move_paragraph_next :: proc(state: ^Editor_State) {
+	gb := &state.buffer
+	count := editor.get_line_count(gb)
+	l := state.cursor_data.line + 1
+	for l < count && is_blank_line(gb, l) {
+		l += 1
+	}
+	for l < count && !is_blank_line(gb, l) {
+		l += 1
+	}
+	if l >= count {
+		state.cursor_pos = editor.current_length(gb)
+	} else {
+		state.cursor_pos = editor.line_col_to_logical_pos(gb, l, 0)
+	}
+	sync_cursor(state)
+	set_preferred_col(state)
+}
+
+// To the blank line before the paragraph, or the start of the buffer.
+move_paragraph_prev :: proc(state: ^Editor_State) {
+	gb := &state.buffer
+	l := state.cursor_data.line - 1
+	for l > 0 && is_blank_line(gb, l) {
+		l -= 1
+	}
+	for l > 0 && !is_blank_line(gb, l) {
+		l -= 1
+	}
+	state.cursor_pos = editor.line_col_to_logical_pos(gb, max(l, 0), 0)
+	sync_cursor(state)
+	set_preferred_col(state)
+}
+
+@(private = "file")
+move_sentence :: proc(state: ^Editor_State, forward: bool) {
+	gb := &state.buffer
+	lo := max(state.cursor_pos - SENTENCE_REACH, 0)
+	hi := min(state.cursor_pos + SENTENCE_REACH, editor.current_length(gb))
+	text := editor.get_text_segment(gb, lo, hi - lo, context.temp_allocator)
+	pos := state.cursor_pos - lo
+	target := len(text) if forward else 0
+	starts := editor.sentence_starts(text)
+	if forward {
+		for s in starts {
+			if s > pos {
+				target = s
+				break
+			}
+		}
+	} else {
+		#reverse for s in starts {
+			if s < pos {
+				target = s
+				break
+			}
+		}
+	}
+	state.cursor_pos = lo + target
+	sync_cursor(state)
+	set_preferred_col(state)
+}
+
+// The lines of the paragraph at line: up to blank lines, headings, fences
+// and tables, and a list item's own lines only.
+@(private = "file")
+prose_paragraph :: proc(gb: ^editor.Gap_Buffer, line: int) -> (first, last: int, ok: bool) {
+	ends_paragraph :: proc(gb: ^editor.Gap_Buffer, line: int) -> bool {
+		text := strings.trim_left_space(editor.get_line(gb, line, context.temp_allocator))
+		return text == "" ||
+			strings.has_prefix(text, "#") ||
+			strings.has_prefix(text, "```") ||
+			strings.has_prefix(text, "~~~") ||
+			strings.has_prefix(text, "|")
+	}
+	starts_item :: proc(gb: ^editor.Gap_Buffer, line: int) -> bool {
+		return editor.parse_prose_prefix(editor.get_line(gb, line, context.temp_allocator)).list
+	}
+	if ends_paragraph(gb, line) {
+		return 0, 0, false
+	}
+	first, last = line, line
+	for first > 0 && !starts_item(gb, first) && !ends_paragraph(gb, first - 1) {
+		first -= 1
+	}
+	count := editor.get_line_count(gb)
+	for last + 1 < count && !ends_paragraph(gb, last + 1) && !starts_item(gb, last + 1) {
+		last += 1
+	}
+	return first, last, true
+}
+
+@(private = "file")
+is_blank_line :: proc(gb: ^editor.Gap_Buffer, line: int) -> bool {
+	return strings.trim_space(editor.get_line(gb, line, context.temp_allocator)) == ""
+}
@@ -0,0 +1,421 @@
+package main
+
+import "core:fmt"
+import "core:os"
+import "core:slice"
+import "core:strings"
+import editor "editor"
+
+// Lists kept for quickfix.older and quickfix.newer, the oldest dropped first.
+QUICKFIX_HISTORY :: 10
+
+// The quickfix list: the places a workspace search or the diagnostics, a
+// task's compiler errors and the linters' problems among them, found last,
+// walked with quickfix.next and quickfix.previous without the panel open.
+// Earlier lists stay in a history.  quickfix.replace previews a regex
+// replacement on every entry's line, and quickfix.do runs a command at every
+// entry, e.g. a plugin's fix.
+Quickfix_State :: struct {
+	lists:   [dynamic]Quickfix_List, // oldest first
+	current: int, // the list walked
+	next_id: int,
+	active:  bool, // the panel lists it
+}
+
+Quickfix_List :: struct {
+	id:      int, // what feeders add to it by, as its place shifts
+	title:   string,
+	key:     string, // refilled in place under it, "" for a list of its own
+	entries: [dynamic]Quickfix_Entry,
+	current: int, // the entry last gone to, -1 before the first
+}
+
+Quickfix_Entry :: struct {
+	location: Location,
+	text:     string, // what was found there
+}
+
+destroy_quickfix :: proc(state: ^Editor_State) {
+	for &list in state.quickfix.lists {
+		destroy_quickfix_list(&list)
+	}
+	delete(state.quickfix.lists)
+}
+
+// Starts a list and makes it the one walked; key names a list that is
+// refilled instead of added again, e.g. "diagnostics", or "" for a new one.
+// Returns the id to add entries by.
+begin_quickfix :: proc(state: ^Editor_State, title, key: string) -> int {
+	q := &state.quickfix
+	if i := find_quickfix(state, key); i >= 0 {
+		list := &q.lists[i]
+		clear_quickfix_entries(list)
+		delete(list.title)
+		list.title = strings.clone(title)
+		q.current = i
+		return list.id
+	}
+	if len(q.lists) == QUICKFIX_HISTORY {
+		destroy_quickfix_list(&q.lists[0])
+		ordered_remove(&q.lists, 0)
+	}
+	q.next_id += 1
+	list := Quickfix_List {
+		id      = q.next_id,
+		title   = strings.clone(title),
+		key     = strings.clone(key),
+		current = -1,
+	}
+	append(&q.lists, list)
+	q.current = len(q.lists) - 1
+	return list.id
+}
+
+// Adds an entry to the list id, unless it has left the history.  path is
+// made absolute.
+add_quickfix :: proc(state: ^Editor_State, id: int, path: string, line, col: int, text: string) {
+	list := quickfix_by_id(state, id)
+	if list == nil {
+		return
+	}
+	full := strings.clone(workspace_path(state, path))
+	append(&list.entries, Quickfix_Entry{Location{full, line, col}, strings.clone(text)})
+}
+
+// Renames the list id, e.g. once its search finished.
+set_quickfix_title :: proc(state: ^Editor_State, id: int, title: string) {
+	if list := quickfix_by_id(state, id); list != nil {
+		delete(list.title)
+		list.title = strings.clone(title)
+	}
+}
+
+// Fills the "diagnostics" list with every diagnostic, by file and line, as
+// it changes.  It becomes the list walked only when it is new, or with
+// make_current, as when a task finishes.
+quickfix_diagnostics :: proc(state: ^Editor_State, make_current := false) {
+	q := &state.quickfix
+	was := q.current
+	existed := find_quickfix(state, "diagnostics") >= 0
+	sorted := slice.clone(state.diagnostics.items[:], context.temp_allocator)
+	slice.sort_by(sorted, proc(a, b: Diagnostic) -> bool {
+		if a.path != b.path {
+			return a.path < b.path
+		}
+		if a.line != b.line {
+			return a.line < b.line
+		}
+		return a.severity < b.severity
+	})
+	if !existed && len(sorted) == 0 {
+		return
+	}
+	id := begin_quickfix(state, fmt.tprintf("Diagnostics (%d)", len(sorted)), "diagnostics")
+	for d in sorted {
+		severity := DIAGNOSTIC_SEVERITY_NAMES[d.severity]
+		text := fmt.tprintf("%s  %s  (%s)", severity, d.message, d.source)
+		add_quickfix(state, id, d.path, d.line, d.col, text)
+	}
+	if existed && !make_current {
+		q.current = was
+	}
+	if q.active {
+		list_quickfix(state)
+	}
+}
+
+// quickfix.next: goes to the list's next entry.
+quickfix_next :: proc(state: ^Editor_State) {
+	step_quickfix(state, 1)
+}
+
+// quickfix.previous: goes to the list's previous entry.
+quickfix_previous :: proc(state: ^Editor_State) {
+	step_quickfix(state, -1)
+}
+
+// quickfix.older: walks the list before the current one instead.
+quickfix_older :: proc(state: ^Editor_State) {
+	switch_quickfix(state, -1)
+}
+
+// quickfix.newer: walks the list after the current one instead.
+quickfix_newer :: proc(state: ^Editor_State) {
+	switch_quickfix(state, 1)
+}
+
+// quickfix.list: lists the current list's entries in the panel under a
+// header per file; enter goes to one.
+show_quickfix :: proc(state: ^Editor_State) {
+	if quickfix_list(state) == nil {
+		set_message(state, "No quickfix list; a search or a task makes one")
+		return
+	}
+	clear_replace(state)
+	stop_project_search(state)
+	release_panel(state)
+	state.quickfix.active = true
+	list_quickfix(state)
+	show_panel(state)
+}
+
+// enter in the panel while it lists the quickfix list.
+open_quickfix_entry :: proc(state: ^Editor_State) {
+	list := quickfix_list(state)
+	item := editor.panel_selected_item(state.panel_data)
+	if list == nil || item == nil || item.path == "" {
+		return
+	}
+	goto_quickfix_entry(state, item.data)
+	state.mode = "editor"
+}
+
+// quickfix.replace: prompts for a regex and its replacement and previews
+// the change on every entry's line, to apply as search.replace's are.
+quickfix_replace :: proc(state: ^Editor_State) {
+	if quickfix_list(state) == nil {
+		set_message(state, "No quickfix list to replace in")
+		return
+	}
+	open_prompt(
+		state,
+		"Replace in the entries:",
+		proc(state: ^Editor_State, pattern: string) {
+			if pattern == "" {
+				return
+			}
+			delete(state.replace.pattern)
+			state.replace.pattern = strings.clone(pattern)
+			open_prompt(
+				state,
+				"Replace with:",
+				proc(state: ^Editor_State, replacement: string) {
+					preview_replace_lines(state, replacement, quickfix_lines(state))
+				},
+				initial = state.replace.replacement,
+			)
+		},
+		initial = state.replace.pattern,
+	)
+}
+
+// quickfix.do: prompts for a command and runs it at every entry, the last
+// first so that edits do not move the entries before them.
+quickfix_do :: proc(state: ^Editor_State) {
+	if quickfix_list(state) == nil {
+		set_message(state, "No quickfix list to run a command on")
+		return
+	}
+	open_prompt(state, "Run at every entry:", proc(state: ^Editor_State, name: string) {
+		name := strings.trim_space(name)
+		if _, ok := state.commands[name]; !ok {
+			set_message(state, "No command %s", name)
+			return
+		}
+		// A copy, as the command's edits may refill the list, the diagnostics.
+		locations := make([dynamic]Location, context.temp_allocator)
+		for entry in quickfix_list(state).entries {
+			loc := entry.location
+			path := strings.clone(loc.path, context.temp_allocator)
+			append(&locations, Location{path, loc.line, loc.col})
+		}
+		ran := 0
+		#reverse for loc in locations {
+			if jump_to_location(state, loc.path, loc.line, loc.col) && run_command(state, name) {
+				ran += 1
+			}
+		}
+		set_message(state, "Ran %s at %d of %d entries", name, ran, len(locations))
+	})
+}
+
+// The list walked, nil when there is none.
+@(private = "file")
+quickfix_list :: proc(state: ^Editor_State) -> ^Quickfix_List {
+	q := &state.quickfix
+	if q.current < 0 || q.current >= len(q.lists) {
+		return nil
+	}
+	return &q.lists[q.current]
+}
+
+@(private = "file")
+quickfix_by_id :: proc(state: ^Editor_State, id: int) -> ^Quickfix_List {
+	for &list in state.quickfix.lists {
+		if list.id == id {
+			return &list
+		}
+	}
+	return nil
+}
+
+@(private = "file")
+find_quickfix :: proc(state: ^Editor_State, key: string) -> int {
+	if key == "" {
+		return -1
+	}
+	for list, i in state.quickfix.lists {
+		if list.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+@(private = "file")
+step_quickfix :: proc(state: ^Editor_State, delta: int) {
+	list := quickfix_list(state)
+	if list == nil || len(list.entries) == 0 {
+		set_message(state, "The quickfix list is empty")
+		return
+	}
+	next := list.current + delta
+	if list.current < 0 && delta < 0 {
+		next = len(list.entries) - 1
+	}
+	if next < 0 || next >= len(list.entries) {
+		set_message(state, "No more entries in %s", list.title)
+		return
+	}
+	goto_quickfix_entry(state, next)
+}
+
+@(private = "file")
+goto_quickfix_entry :: proc(state: ^Editor_State, i: int) {
+	list := quickfix_list(state)
+	list.current = i
+	entry := list.entries[i]
+	loc := entry.location
+	if !jump_to_location(state, loc.path, loc.line, loc.col) {
+		set_message(state, "Cannot open %s", display_path(state, loc.path))
+		return
+	}
+	set_message(state, "(%d of %d) %s", i + 1, len(list.entries), entry.text)
+}
+
+@(private = "file")
+switch_quickfix :: proc(state: ^Editor_State, delta: int) {
+	q := &state.quickfix
+	next := q.current + delta
+	if len(q.lists) == 0 || next < 0 || next >= len(q.lists) {
+		set_message(state, "No %s quickfix list", "older" if delta < 0 else "newer")
+		return
+	}
+	q.current = next
+	list := &q.lists[next]
+	set_message(
+		state,
+		"Quickfix list %d of %d: %s, %d entries",
+		next + 1,
+		len(q.lists),
+		list.title,
+		len(list.entries),
+	)
+	if q.active {
+		list_quickfix(state)
+	}
+}
+
+@(private = "file")
+list_quickfix :: proc(state: ^Editor_State) {
+	list := quickfix_list(state)
+	panel := state.panel_data
+	editor.panel_clear(panel)
+	if list == nil {
+		return
+	}
+	editor.panel_set_title(panel, fmt.tprintf("%s: %d entries", list.title, len(list.entries)))
+	for entry, i in list.entries {
+		loc := entry.location
+		if i == 0 || list.entries[i - 1].location.path != loc.path {
+			editor.panel_add_item(
+				panel,
+				{text = display_path(state, loc.path), line = -1, style = .Header},
+			)
+		}
+		item := editor.Panel_Item {
+			text = fmt.tprintf("  %d:%d  %s", loc.line + 1, loc.col + 1, entry.text),
+			path = loc.path,
+			line = loc.line,
+			col  = loc.col,
+			data = i,
+		}
+		editor.panel_add_item(panel, item)
+	}
+	panel.selected = 0
+	for item, k in panel.items {
+		if item.path != "" && item.data == max(list.current, 0) && item.line >= 0 {
+			panel.selected = k
+			break
+		}
+	}
+}
+
+// The current list's lines as search results, one per line with an entry,
+// by file, as a replace preview takes them.  Temp allocated.
+@(private = "file")
+quickfix_lines :: proc(state: ^Editor_State) -> []editor.Search_File_Result {
+	list := quickfix_list(state)
+	entries := slice.clone(list.entries[:], context.temp_allocator)
+	slice.sort_by(entries, proc(a, b: Quickfix_Entry) -> bool {
+		if a.location.path != b.location.path {
+			return a.location.path < b.location.path
+		}
+		return a.location.line < b.location.line
+	})
+	results := make([dynamic]editor.Search_File_Result, context.temp_allocator)
+	matches := make([dynamic]editor.Search_Match, context.temp_allocator)
+	lines: []string
+	for entry, i in entries {
+		loc := entry.location
+		if i == 0 || entries[i - 1].location.path != loc.path {
+			lines = file_lines(state, loc.path)
+		}
+		seen := len(matches) > 0 && matches[len(matches) - 1].line == loc.line
+		if loc.line < len(lines) && !seen {
+			append(&matches, editor.Search_Match{line = loc.line, text = lines[loc.line]})
+		}
+		if i == len(entries) - 1 || entries[i + 1].location.path != loc.path {
+			if len(matches) > 0 {
+				append(&results, editor.Search_File_Result{loc.path, matches[:]})
+			}
+			matches = make([dynamic]editor.Search_Match, context.temp_allocator)
+		}
+	}
+	return results[:]
+}
+
+// The lines of the file at path, from its buffer when it is open, without
+// their line endings.  Temp allocated.
+@(private = "file")
+file_lines :: proc(state: ^Editor_State, path: string) -> []string {
+	text: string
+	if is_open_file(state, path) {
+		text = editor.get_text(&state.buffer, context.temp_allocator)
+	} else if data, err := os.read_entire_file_from_path(path, context.temp_allocator); err == nil {
+		text = string(data)
+	}
+	lines := strings.split_lines(text, context.temp_allocator)
+	for &line in lines {
+		line = strings.trim_suffix(line, "\r")
+	}
+	return lines
+}
+
+@(private = "file")
+clear_quickfix_entries :: proc(list: ^Quickfix_List) {
+	for entry in list.entries {
+		delete(entry.location.path)
+		delete(entry.text)
+	}
+	clear(&list.entries)
+	list.current = -1
+}
+
+@(private = "file")
+destroy_quickfix_list :: proc(list: ^Quickfix_List) {
+	clear_quickfix_entries(list)
+	delete(list.entries)
+	delete(list.title)
+	delete(list.key)
+}
@@ -0,0 +1,141 @@
+package main
+
+import "core:encoding/json"
+import "core:fmt"
+import "core:log"
+import "core:os"
+import "core:path/filepath"
+import "core:slice"
+import "core:time"
+import editor "editor"
+
+RECENT_FILES_FILE :: "recent-files.json" // in the user config dir
+RECENT_FILES_MAX :: 200
+
+// A file opened before: how often and when last.  Files are ranked by
+// frecency, their visits weighted by how recent the last one was.
+Recent_File :: struct {
+	path:   string, // absolute
+	visits: int,
+	last:   i64, // unix seconds
+}
+
+// Counts a visit to the file at full, an absolute path.
+note_recent_file :: proc(full: string) {
+	path, ok := user_config_path(RECENT_FILES_FILE)
+	if !ok {
+		return
+	}
+	files := make([dynamic]Recent_File, context.temp_allocator)
+	append(&files, Recent_File{path = full, visits = 1, last = time.time_to_unix(time.now())})
+	for f in read_recent_files() {
+		if f.path == full {
+			files[0].visits += f.visits
+		} else {
+			append(&files, f)
+		}
+	}
+	// Past the limit the lowest ranked go, never the file just opened.
+	sort_recent_files(files[1:])
+	resize(&files, min(len(files), RECENT_FILES_MAX))
+	data, err := json.marshal(files[:], {pretty = true}, context.temp_allocator)
+	if err != nil {
+		return
+	}
+	_ = os.make_directory_all(filepath.dir(path, context.temp_allocator))
+	if werr := write_file_atomic(path, data); werr != nil {
+		log.warnf("recent files: %s: %v", path, werr)
+	}
+}
+
+// file.recent: lists the recent files that still exist, highest frecency
+// first; enter opens one.
+show_recent_files :: proc(state: ^Editor_State) {
+	if !list_recent_files(state) {
+		editor.panel_set_title(state.panel_data, "No recent files")
+	}
+	show_panel(state)
+}
+
+// At startup with nothing restored and no files given, the recent files are
+// a keypress away.
+offer_recent_files :: proc(state: ^Editor_State) {
+	if list_recent_files(state) {
+		show_panel(state)
+	}
+}
+
+// The files ranked by frecency; a visit today counts ten times one three
+// months ago.
+sort_recent_files :: proc(files: []Recent_File) {
+	Ranked :: struct {
+		file:  Recent_File,
+		score: int,
+	}
+	now := time.time_to_unix(time.now())
+	ranked := make([]Ranked, len(files), context.temp_allocator)
+	for f, i in files {
+		days := (now - f.last) / (24 * 60 * 60)
+		weight := 10
+		switch {
+		case days < 4:
+			weight = 100
+		case days < 14:
+			weight = 70
+		case days < 31:
+			weight = 50
+		case days < 90:
+			weight = 30
+		}
+		ranked[i] = {f, f.visits * weight}
+	}
+	slice.sort_by(ranked, proc(a, b: Ranked) -> bool {
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		return a.file.last > b.file.last
+	})
+	for r, i in ranked {
+		files[i] = r.file
+	}
+}
+
+// Fills the panel; false when there is nothing to list.
+@(private = "file")
+list_recent_files :: proc(state: ^Editor_State) -> bool {
+	files := read_recent_files()
+	sort_recent_files(files)
+	clear_replace(state)
+	stop_project_search(state)
+	release_panel(state)
+	panel := state.panel_data
+	editor.panel_clear(panel)
+	editor.panel_set_title(panel, "Recent files; enter opens")
+	for f in files {
+		if !os.exists(f.path) || is_open_file(state, f.path) {
+			continue
+		}
+		text := fmt.tprintf("%-24s %s", document_title(f.path), display_path(state, f.path))
+		editor.panel_add_item(panel, {text = text, path = f.path, line = -1})
+	}
+	return len(panel.items) > 0
+}
+
+// Temp allocated.
+@(private = "file")
+read_recent_files :: proc() -> []Recent_File {
+	path, ok := user_config_path(RECENT_FILES_FILE)
+	if !ok {
+		return nil
+	}
+	data, err := os.read_entire_file_from_path(path, context.temp_allocator)
+	if err != nil {
+		return nil
+	}
+	files: []Recent_File
+	if uerr := json.unmarshal(data, &files, allocator = context.temp_allocator); uerr != nil {
+		log.warnf("recent files: %s: %v", path, uerr)
+		return nil
+	}
+	return files
+}
@@ -0,0 +1,200 @@
+package main
+
+import "core:encoding/json"
+import "core:fmt"
+import "core:log"
+import "core:os"
+import "core:path/filepath"
+import "core:strings"
+import "core:time"
+import editor "editor"
+
+RECOVERY_INTERVAL :: 10 * time.Second
+
+// Contents of a modified buffer, written to the cache directory every few
+// seconds.  A clean exit deletes them, so any found at startup were left by a
+// crash.
+Recovery_Snapshot :: struct {
+	path: string, // empty for an unnamed buffer
+	text: string,
+}
+
+Recovery_State :: struct {
+	dir:        string, // <user cache dir>/rune/recovery
+	last_write: time.Tick,
+	written:    map[int]int, // document id -> undo version in its snapshot
+}
+
+init_recovery :: proc(state: ^Editor_State) {
+	r := &state.recovery
+	r.written = make(map[int]int)
+	cache, err := os.user_cache_dir(context.temp_allocator)
+	if err != nil {
+		return
+	}
+	r.dir = filepath.join({cache, "rune", "recovery"})
+	_ = os.make_directory_all(r.dir)
+}
+
+// Clean exit: the snapshots of this run are no longer needed.
+destroy_recovery :: proc(state: ^Editor_State) {
+	r := &state.recovery
+	for id in r.written {
+		os.remove(snapshot_path(state, id))
+	}
+	delete(r.written)
+	delete(r.dir)
+}
+
+// Writes snapshots of buffers edited since their last snapshot and drops the
+// ones of buffers that are no longer modified.
+write_recovery_snapshots :: proc(state: ^Editor_State) {
+	r := &state.recovery
+	if r.dir == "" || time.tick_since(r.last_write) < RECOVERY_INTERVAL {
+		return
+	}
+	r.last_write = time.tick_now()
+	snapshot_all_documents(state)
+}
+
+// Writes the snapshots now, before they are due; the last act of a panic.
+// Returns how many buffers have one.
+snapshot_all_documents :: proc(state: ^Editor_State) -> int {
+	if state.recovery.dir == "" {
+		return 0
+	}
+	snapshot_document(state, state.doc_id, state.path, &state.buffer, &state.undo)
+	for &d, i in state.documents {
+		if i != state.active {
+			snapshot_document(state, d.id, d.path, &d.buffer, &d.undo)
+		}
+	}
+	return len(state.recovery.written)
+}
+
+// Forgets the snapshot of a document that was saved or closed.
+discard_recovery_snapshot :: proc(state: ^Editor_State, id: int) {
+	if id in state.recovery.written {
+		os.remove(snapshot_path(state, id))
+		delete_key(&state.recovery.written, id)
+	}
+}
+
+// Looks for snapshots left by a crashed run and offers to restore them.
+offer_recovery :: proc(state: ^Editor_State) {
+	if len(list_recovery_files(state)) == 0 {
+		return
+	}
+	confirm(
+		state,
+		"Recover unsaved buffers from the last crash? (y/n)",
+		recover_snapshots,
+		proc(state: ^Editor_State) {
+			for path in list_recovery_files(state) {
+				os.remove(path)
+			}
+		},
+	)
+}
+
+// Reopens every snapshot.  The recovered text replaces the file contents as an
+// unsaved, undoable edit, so the file on disk is untouched until saved.
+recover_snapshots :: proc(state: ^Editor_State) {
+	recovered := 0
+	for file in list_recovery_files(state) {
+		data, err := os.read_entire_file_from_path(file, context.temp_allocator)
+		if err != nil {
+			continue
+		}
+		snap: Recovery_Snapshot
+		if json.unmarshal(data, &snap, allocator = context.temp_allocator) != nil {
+			continue
+		}
+
+		if snap.path != "" && os.exists(snap.path) {
+			if !open_file(state, snap.path) {
+				continue
+			}
+		} else {
+			if !is_blank_document(state) {
+				new_document(state)
+			}
+			delete(state.path)
+			state.path = strings.clone(snap.path)
+			set_language(state, editor.detect_language(snap.path, snap.text).id)
+		}
+
+		gb := &state.buffer
+		editor.begin_undo_group(&state.undo, state.cursor_pos)
+		editor.replace_bytes(gb, 0, editor.current_length(gb), transmute([]u8)snap.text)
+		editor.end_undo_group(&state.undo, 0)
+		state.cursor_pos = min(state.cursor_pos, editor.current_length(gb))
+		sync_cursor(state)
+		set_preferred_col(state)
+
+		os.remove(file)
+		recovered += 1
+	}
+	set_message(state, "Recovered %d buffers; save them to keep the changes", recovered)
+}
+
+@(private = "file")
+snapshot_document :: proc(
+	state: ^Editor_State,
+	id: int,
+	path: string,
+	gb: ^editor.Gap_Buffer,
+	h: ^editor.Undo_History,
+) {
+	r := &state.recovery
+	if .Recovery in degraded_features(state, editor.current_length(gb)) {
+		return
+	}
+	if !editor.is_modified(h) {
+		discard_recovery_snapshot(state, id)
+		return
+	}
+	if version, ok := r.written[id]; ok && version == h.version {
+		return
+	}
+
+	snap := Recovery_Snapshot {
+		path = workspace_path(state, path) if path != "" else "",
+		text = editor.get_text(gb, context.temp_allocator),
+	}
+	data, merr := json.marshal(snap, allocator = context.temp_allocator)
+	if merr != nil {
+		return
+	}
+	if err := write_file_atomic(snapshot_path(state, id), data); err != nil {
+		log.warn("recovery: failed to write snapshot:", err)
+		return
+	}
+	r.written[id] = h.version
+}
+
+// Snapshots are named <pid>-<document id>.json so that two running editors
+// never overwrite each other's.
+@(private = "file")
+snapshot_path :: proc(state: ^Editor_State, id: int) -> string {
+	name := fmt.tprintf("%d-%d.json", os.get_pid(), id)
+	return filepath.join({state.recovery.dir, name}, context.temp_allocator)
+}
+
+@(private = "file")
+list_recovery_files :: proc(state: ^Editor_State) -> []string {
+	files := make([dynamic]string, context.temp_allocator)
+	if state.recovery.dir == "" {
+		return nil
+	}
+	entries, err := os.read_all_directory_by_path(state.recovery.dir, context.temp_allocator)
+	if err != nil {
+		return nil
+	}
+	for fi in entries {
+		if fi.type == .Regular && strings.has_suffix(fi.name, ".json") {
+			append(&files, fi.fullpath)
+		}
+	}
+	return files[:]
+}
@@ -0,0 +1,198 @@
+package main
+
+import "core:fmt"
+import "core:strings"
+import "core:unicode/utf8"
+import editor "editor"
+
+// Registers, as in vim.  A copy or cut goes to the register picked just
+// before it, and a paste comes from it; without one they use the clipboard.
+// register.select reads the register as the next typed character:
+//
+//	a-z   named registers
+//	A-Z   append to the named register instead of replacing it
+//	0     the last copy
+//	1-9   the last cuts, newest in 1
+//	+ *   the clipboard
+//	_     discards what is cut; nothing is pasted from it
+//
+// The commands take no arguments, so any keymap can drive them: the default
+// one binds register.select to ctrl+k ', and a vim style one would bind it to
+// " next to edit.copy and edit.paste on y and p.
+Register_State :: struct {
+	contents: [128]Clipboard_Entry, // by name; empty text when unset
+	pending:  u8, // picked for the next copy, cut or paste, 0 for none
+	active:   bool, // the panel lists the registers
+}
+
+destroy_registers :: proc(state: ^Editor_State) {
+	for e in state.registers.contents {
+		delete(e.text)
+	}
+}
+
+// register.select: picks the register of the next copy, cut or paste.
+select_register :: proc(state: ^Editor_State) {
+	open_prompt(state, "Register:", pick_register, on_change = pick_register)
+}
+
+// Drops a register picked with register.select.
+cancel_register :: proc(state: ^Editor_State) {
+	state.registers.pending = 0
+}
+
+// register.list: shows what the registers hold; enter pastes one.
+show_registers :: proc(state: ^Editor_State) {
+	clear_replace(state)
+	stop_project_search(state)
+	release_panel(state)
+	state.registers.active = true
+	panel := state.panel_data
+	editor.panel_clear(panel)
+	editor.panel_set_title(panel, "Registers; enter pastes")
+	if clipboard := get_clipboard(state); clipboard != "" {
+		item := editor.Panel_Item {
+			text = fmt.tprintf("\"+  %s", summarize_text(clipboard)),
+			line = -1,
+			data = '+',
+		}
+		editor.panel_add_item(panel, item)
+	}
+	for name in "0123456789abcdefghijklmnopqrstuvwxyz" {
+		e := state.registers.contents[name]
+		if e.text == "" {
+			continue
+		}
+		item := editor.Panel_Item {
+			text = fmt.tprintf("\"%c  %s", name, summarize_text(e.text)),
+			line = -1,
+			data = int(name),
+		}
+		editor.panel_add_item(panel, item)
+	}
+	if len(panel.items) == 0 {
+		editor.panel_set_title(panel, "Registers; all empty")
+	}
+	show_panel(state)
+}
+
+// Enter in the register list.
+paste_register_entry :: proc(state: ^Editor_State) {
+	item := editor.panel_selected_item(state.panel_data)
+	if item == nil {
+		return
+	}
+	state.registers.active = false
+	hide_panel(state)
+	if is_read_only(state) {
+		set_message(state, "%s is read-only", document_title(state.path))
+		return
+	}
+	state.registers.pending = u8(item.data)
+	if !paste_register(state) {
+		paste_clipboard(state)
+	}
+}
+
+// Stores a copy or cut in the picked register.  Returns false when it goes
+// to the clipboard, having kept it in the numbered registers.
+copy_to_register :: proc(
+	state: ^Editor_State,
+	text: string,
+	linewise: bool,
+	cut: bool,
+) -> (
+	taken: bool,
+) {
+	name := state.registers.pending
+	state.registers.pending = 0
+	switch name {
+	case 0, '+', '*':
+		if !cut {
+			set_register(state, '0', text, linewise)
+			return false
+		}
+		regs := &state.registers.contents
+		delete(regs['9'].text)
+		for r := u8('9'); r > '1'; r -= 1 {
+			regs[r] = regs[r - 1]
+		}
+		regs['1'] = {}
+		set_register(state, '1', text, linewise)
+		return false
+	case '_':
+		return true
+	case 'A' ..= 'Z':
+		lower := name - 'A' + 'a'
+		old := state.registers.contents[lower]
+		appended := text
+		if old.text != "" {
+			sep := "\n" if linewise && !old.linewise else ""
+			appended = strings.concatenate({old.text, sep, text}, context.temp_allocator)
+		}
+		if old.linewise && !linewise {
+			appended = strings.concatenate({appended, "\n"}, context.temp_allocator)
+		}
+		set_register(state, lower, appended, old.linewise || linewise)
+		set_message(state, "Appended %s to register %c", describe_text(text), lower)
+	case:
+		set_register(state, name, text, linewise)
+		set_message(state, "Copied %s to register %c", describe_text(text), name)
+	}
+	return true
+}
+
+// Pastes the picked register.  Returns false when none was picked or it is
+// the clipboard.
+paste_register :: proc(state: ^Editor_State) -> bool {
+	name := state.registers.pending
+	state.registers.pending = 0
+	switch name {
+	case 0, '+', '*':
+		return false
+	case 'A' ..= 'Z':
+		name = name - 'A' + 'a'
+	}
+	e := state.registers.contents[name]
+	if e.text == "" {
+		set_message(state, "Register %c is empty", name)
+		return true
+	}
+	paste_text(state, e.text, e.linewise)
+	return true
+}
+
+// The first typed character names the register.
+@(private = "file")
+pick_register :: proc(state: ^Editor_State, text: string) {
+	close_prompt(state)
+	if text == "" {
+		return
+	}
+	r, _ := utf8.decode_rune_in_string(text)
+	if !is_register_name(r) {
+		set_message(state, "No register %c; use a-z, A-Z, 0-9, +, * or _", r)
+		return
+	}
+	state.registers.pending = u8(r)
+	set_message(state, "Register \"%c", r)
+}
+
+@(private = "file")
+set_register :: proc(state: ^Editor_State, name: u8, text: string, linewise: bool) {
+	e := &state.registers.contents[name]
+	delete(e.text)
+	e^ = {
+		text     = strings.clone(text),
+		linewise = linewise,
+	}
+}
+
+@(private = "file")
+is_register_name :: proc(r: rune) -> bool {
+	switch r {
+	case 'a' ..= 'z', 'A' ..= 'Z', '0' ..= '9', '+', '*', '_':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package main
+
+import "core:fmt"
+import "core:path/filepath"
+import "core:strconv"
+import "core:strings"
+import "vendor:glfw"
+
+// rune --remote [files...] opens files in a running instance rather than a
+// new window.  The first instance listens on a local socket
+// (remote_posix.odin) for request lines
+//
+//	RUNE/1 open <absolute path>
+//	RUNE/1 goto <line> <col>     1 based, in the file opened last
+//	RUNE/1 focus
+//	RUNE/1 wait                  until the files opened are closed, for --wait
+//
+// and answers each with "RUNE/1 ok" or "RUNE/1 error <reason>", wait only
+// once it is over.  The version goes up when a request changes meaning; an
+// instance refuses versions it does not speak.
+REMOTE_VERSION :: "RUNE/1"
+
+// The request lines for rune --remote with files, made absolute since the
+// instance may run in another directory.  Temp allocated.
+remote_requests :: proc(targets: []Open_Target, wait: bool) -> string {
+	b := strings.builder_make(context.temp_allocator)
+	for t in targets {
+		full, err := filepath.abs(t.path, context.temp_allocator)
+		if err != nil {
+			full = t.path
+		}
+		fmt.sbprintf(&b, REMOTE_VERSION + " open %s\n", full)
+		if t.line > 0 {
+			fmt.sbprintf(&b, REMOTE_VERSION + " goto %d %d\n", t.line, max(t.col, 1))
+		}
+	}
+	strings.write_string(&b, REMOTE_VERSION + " focus\n")
+	if wait {
+		strings.write_string(&b, REMOTE_VERSION + " wait\n")
+	}
+	return strings.to_string(b)
+}
+
+// Runs the request lines of one client and returns the replies.  After a
+// wait request, waiting holds the documents to wait for, and the reply to it
+// is left to remote_wait_reply.  Temp allocated.
+serve_remote_requests :: proc(
+	state: ^Editor_State,
+	requests: string,
+) -> (
+	replies: string,
+	waiting: []int,
+) {
+	b := strings.builder_make(context.temp_allocator)
+	opened := make([dynamic]int, context.temp_allocator)
+	requests := requests
+	for line in strings.split_lines_iterator(&requests) {
+		line := strings.trim_right(line, "\r")
+		if line == "" {
+			continue
+		}
+		if line == REMOTE_VERSION + " wait" && len(opened) > 0 {
+			waiting = opened[:]
+			continue
+		}
+		strings.write_string(&b, REMOTE_VERSION)
+		if reason := remote_request(state, line, &opened); reason != "" {
+			strings.write_string(&b, " error ")
+			strings.write_string(&b, reason)
+		} else {
+			strings.write_string(&b, " ok")
+		}
+		strings.write_byte(&b, '\n')
+	}
+	return strings.to_string(b), waiting
+}
+
+// The reply to a wait request once none of the documents in waiting is open,
+// or "".
+remote_wait_reply :: proc(state: ^Editor_State, waiting: []int) -> string {
+	for id in waiting {
+		if is_document_open(state, id) {
+			return ""
+		}
+	}
+	return REMOTE_VERSION + " ok\n"
+}
+
+// Runs one request, returning why it failed or "".
+@(private = "file")
+remote_request :: proc(state: ^Editor_State, line: string, opened: ^[dynamic]int) -> string {
+	version, _, request := strings.partition(line, " ")
+	if version != REMOTE_VERSION {
+		return "unsupported protocol version"
+	}
+	verb, _, arg := strings.partition(request, " ")
+	switch verb {
+	case "open":
+		if !filepath.is_abs(arg) {
+			return "path must be absolute"
+		}
+		if !open_file(state, arg) {
+			return "cannot open file"
+		}
+		append(opened, state.doc_id)
+		update_status_line(state)
+	case "goto":
+		row_text, _, col_text := strings.partition(arg, " ")
+		row, rok := strconv.parse_int(row_text, 10)
+		col, cok := strconv.parse_int(col_text, 10)
+		if !rok || !cok || row < 1 || col < 1 {
+			return "expected goto <line> <col>"
+		}
+		goto_line_col(state, row - 1, col - 1)
+	case "focus":
+		glfw.FocusWindow(state.window)
+	case "wait":
+		// Nothing was opened, so there is nothing to wait for.
+	case:
+		return "unknown request"
+	}
+	return ""
+}
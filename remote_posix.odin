@@ -0,0 +1,187 @@
+#+build !windows
+package main
+
+import "core:fmt"
+import "core:os"
+import "core:path/filepath"
+import "core:slice"
+import "core:strings"
+import "core:sys/posix"
+
+REMOTE_READ_TIMEOUT :: 200 // milliseconds a client gets to send its requests
+
+Remote_State :: struct {
+	listener: posix.FD, // -1 when another instance listens
+	path:     string, // the socket, removed on exit
+	waiters:  [dynamic]Remote_Waiter,
+}
+
+// A client of rune --wait, answered once its files are closed.
+Remote_Waiter :: struct {
+	fd:   posix.FD,
+	docs: []int, // Document.id
+}
+
+// Listens for rune --remote unless another instance already does.
+listen_remote :: proc(state: ^Editor_State) {
+	r := &state.remote
+	r.listener = -1
+	path := remote_socket_path()
+	if fd := connect_remote(path); fd >= 0 {
+		posix.close(fd)
+		return
+	}
+	cpath := strings.clone_to_cstring(path, context.temp_allocator)
+	posix.unlink(cpath) // left by an instance that crashed
+	addr, ok := remote_address(path)
+	if !ok {
+		return
+	}
+	fd := posix.socket(.UNIX, .STREAM)
+	if fd < 0 {
+		return
+	}
+	if posix.bind(fd, (^posix.sockaddr)(&addr), size_of(addr)) != .OK ||
+	   posix.listen(fd, 8) != .OK {
+		posix.close(fd)
+		return
+	}
+	r.listener = fd
+	r.path = strings.clone(path)
+}
+
+close_remote :: proc(state: ^Editor_State) {
+	r := &state.remote
+	// The files are closed along with the editor.
+	for w in r.waiters {
+		reply := REMOTE_VERSION + " ok\n"
+		posix.write(w.fd, raw_data(reply), uint(len(reply)))
+		posix.close(w.fd)
+		delete(w.docs)
+	}
+	delete(r.waiters)
+	if r.listener < 0 {
+		return
+	}
+	posix.close(r.listener)
+	posix.unlink(strings.clone_to_cstring(r.path, context.temp_allocator))
+	delete(r.path)
+	r.listener = -1
+}
+
+// Serves the clients waiting on the socket and answers the waiting ones whose
+// files were closed.  Called every frame.
+poll_remote :: proc(state: ^Editor_State) {
+	r := &state.remote
+	for i := len(r.waiters) - 1; i >= 0; i -= 1 {
+		w := r.waiters[i]
+		if reply := remote_wait_reply(state, w.docs); reply != "" {
+			posix.write(w.fd, raw_data(reply), uint(len(reply)))
+			posix.close(w.fd)
+			delete(w.docs)
+			unordered_remove(&r.waiters, i)
+		}
+	}
+	for r.listener >= 0 {
+		fds := [1]posix.pollfd{{fd = r.listener, events = {.IN}}}
+		if posix.poll(&fds[0], 1, 0) <= 0 {
+			return
+		}
+		client := posix.accept(r.listener, nil, nil)
+		if client < 0 {
+			return
+		}
+		requests := read_until_closed(client, REMOTE_READ_TIMEOUT)
+		replies, waiting := serve_remote_requests(state, requests)
+		posix.write(client, raw_data(replies), uint(len(replies)))
+		if waiting != nil {
+			append(&r.waiters, Remote_Waiter{client, slice.clone(waiting)})
+			continue
+		}
+		posix.close(client)
+	}
+}
+
+// Sends files to a running instance and prints its errors, with wait only
+// returning once they are closed there.  Returns false when no instance
+// listens, or stdin is to be read, so the caller opens a window itself.
+send_remote :: proc(targets: []Open_Target, wait: bool) -> bool {
+	for t in targets {
+		if t.path == "-" {
+			return false
+		}
+	}
+	fd := connect_remote(remote_socket_path())
+	if fd < 0 {
+		return false
+	}
+	defer posix.close(fd)
+	requests := remote_requests(targets, wait)
+	posix.write(fd, raw_data(requests), uint(len(requests)))
+	posix.shutdown(fd, .WR)
+	replies := read_until_closed(fd, -1)
+	for line in strings.split_lines_iterator(&replies) {
+		_, _, reply := strings.partition(line, " ")
+		if strings.has_prefix(reply, "error ") {
+			fmt.eprintln("rune:", reply[len("error "):])
+		}
+	}
+	return true
+}
+
+// $XDG_RUNTIME_DIR/rune.sock, or one per user in /tmp.  Temp allocated.
+@(private = "file")
+remote_socket_path :: proc() -> string {
+	if dir := os.get_env("XDG_RUNTIME_DIR", context.temp_allocator); dir != "" {
+		return filepath.join({dir, "rune.sock"}, context.temp_allocator)
+	}
+	return fmt.tprintf("/tmp/rune-%d.sock", posix.getuid())
+}
+
+@(private = "file")
+remote_address :: proc(path: string) -> (addr: posix.sockaddr_un, ok: bool) {
+	if len(path) >= len(addr.sun_path) {
+		return {}, false
+	}
+	addr.sun_family = .UNIX
+	copy(addr.sun_path[:], path)
+	return addr, true
+}
+
+// A socket connected to the instance listening at path, or -1.
+@(private = "file")
+connect_remote :: proc(path: string) -> posix.FD {
+	addr, ok := remote_address(path)
+	if !ok {
+		return -1
+	}
+	fd := posix.socket(.UNIX, .STREAM)
+	if fd < 0 {
+		return -1
+	}
+	if posix.connect(fd, (^posix.sockaddr)(&addr), size_of(addr)) != .OK {
+		posix.close(fd)
+		return -1
+	}
+	return fd
+}
+
+// Reads fd until the other end stops writing, or until nothing arrives for
+// timeout milliseconds (-1 waits forever).  Temp allocated.
+@(private = "file")
+read_until_closed :: proc(fd: posix.FD, timeout: i32) -> string {
+	b := strings.builder_make(context.temp_allocator)
+	buf: [4096]u8
+	for {
+		fds := [1]posix.pollfd{{fd = fd, events = {.IN}}}
+		if posix.poll(&fds[0], 1, timeout) <= 0 {
+			break
+		}
+		n := posix.read(fd, &buf[0], uint(len(buf)))
+		if n <= 0 {
+			break
+		}
+		strings.write_bytes(&b, buf[:n])
+	}
+	return strings.to_string(b)
+}
@@ -0,0 +1,15 @@
+package main
+
+// There is no socket to listen on yet; a named pipe would do.  Until then
+// rune --remote always opens a new window.
+Remote_State :: struct {}
+
+listen_remote :: proc(state: ^Editor_State) {}
+
+close_remote :: proc(state: ^Editor_State) {}
+
+poll_remote :: proc(state: ^Editor_State) {}
+
+send_remote :: proc(targets: []Open_Target, wait: bool) -> bool {
+	return false
+}
@@ -0,0 +1,383 @@
+package main
+
+import "core:fmt"
+import "core:os"
+import "core:strings"
+import "core:text/regex"
+import editor "editor"
+
+// One line the preview proposes to change.
+Replace_Entry :: struct {
+	path:     string, // relative to the workspace root
+	line:     int,
+	old_text: string,
+	new_text: string,
+}
+
+// Multi-file replace runs a project search and lists every changed line as a
+// diff in the panel.  Each line has a checkbox; the checked ones are applied
+// together once the preview is confirmed.
+Replace_State :: struct {
+	active:      bool, // the panel holds a replace preview
+	pattern:     string,
+	replacement: string,
+	re:          regex.Regular_Expression,
+	capture:     regex.Capture,
+	entries:     [dynamic]Replace_Entry, // indexed by Panel_Item.data
+}
+
+// Edits accepted for one file, in line order.
+@(private = "file")
+Replace_File :: struct {
+	path:     string,
+	entries:  [dynamic]Replace_Entry,
+	contents: []u8, // new contents for files that are not open
+}
+
+// Prompts for a regex and its replacement, then previews the substitutions.
+search_replace :: proc(state: ^Editor_State) {
+	open_prompt(
+		state,
+		"Replace:",
+		proc(state: ^Editor_State, pattern: string) {
+			if pattern == "" {
+				return
+			}
+			delete(state.replace.pattern)
+			state.replace.pattern = strings.clone(pattern)
+			open_prompt(
+				state,
+				"Replace with:",
+				start_replace_preview,
+				initial = state.replace.replacement,
+			)
+		},
+		initial = state.replace.pattern if state.replace.pattern != "" else state.search.pattern,
+	)
+}
+
+start_replace_preview :: proc(state: ^Editor_State, replacement: string) {
+	r := &state.replace
+	clear_replace(state)
+
+	re, err := regex.create(r.pattern, {})
+	if err != nil {
+		set_message(state, "Invalid search pattern: %v", err)
+		return
+	}
+	if !begin_project_search(state, r.pattern, 0) {
+		regex.destroy(re)
+		return
+	}
+
+	delete(r.replacement)
+	r.replacement = strings.clone(replacement)
+	r.re = re
+	r.capture = regex.preallocate_capture()
+	r.active = true
+	editor.panel_set_title(
+		state.panel_data,
+		fmt.tprintf("Previewing %q -> %q ...", r.pattern, r.replacement),
+	)
+}
+
+// Previews the replacement of state.replace.pattern on lines already found,
+// the quickfix list's, without searching the workspace.
+preview_replace_lines :: proc(
+	state: ^Editor_State,
+	replacement: string,
+	results: []editor.Search_File_Result,
+) {
+	r := &state.replace
+	clear_replace(state)
+
+	re, err := regex.create(r.pattern, {})
+	if err != nil {
+		set_message(state, "Invalid search pattern: %v", err)
+		return
+	}
+	stop_project_search(state)
+	release_panel(state)
+	editor.panel_clear(state.panel_data)
+
+	delete(r.replacement)
+	r.replacement = strings.clone(replacement)
+	r.re = re
+	r.capture = regex.preallocate_capture()
+	r.active = true
+	for result in results {
+		add_replace_preview(state, result)
+	}
+	set_replace_title(state)
+	show_panel(state)
+}
+
+// Drops the current preview, if any.
+clear_replace :: proc(state: ^Editor_State) {
+	r := &state.replace
+	if !r.active {
+		return
+	}
+	for e in r.entries {
+		delete(e.path)
+		delete(e.old_text)
+		delete(e.new_text)
+	}
+	clear(&r.entries)
+	regex.destroy(r.capture)
+	regex.destroy(r.re)
+	r.active = false
+}
+
+destroy_replace :: proc(state: ^Editor_State) {
+	clear_replace(state)
+	delete(state.replace.entries)
+	delete(state.replace.pattern)
+	delete(state.replace.replacement)
+}
+
+// Lists the lines of one search result that the replacement would change.
+add_replace_preview :: proc(state: ^Editor_State, result: editor.Search_File_Result) {
+	r := &state.replace
+	panel := state.panel_data
+	header := false
+
+	for m in result.matches {
+		new_text, n := editor.regex_replace_all(
+			r.re,
+			m.text,
+			r.replacement,
+			&r.capture,
+			context.temp_allocator,
+		)
+		if n == 0 || new_text == m.text {
+			continue
+		}
+		if !header {
+			editor.panel_add_item(panel, {text = display_path(state, result.path), style = .Header})
+			header = true
+		}
+
+		append(
+			&r.entries,
+			Replace_Entry {
+				path = strings.clone(result.path),
+				line = m.line,
+				old_text = strings.clone(m.text),
+				new_text = strings.clone(new_text),
+			},
+		)
+		editor.panel_add_item(
+			panel,
+			{
+				text = fmt.tprintf("%6d - %s", m.line + 1, m.text),
+				path = result.path,
+				line = m.line,
+				col = m.col,
+				style = .Removed,
+				check = .Checked,
+				data = len(r.entries) - 1,
+			},
+		)
+		// Indented past the checkbox so both halves of the diff line up.
+		added := fmt.tprintf("    %6s + %s", "", new_text)
+		editor.panel_add_item(panel, {text = added, style = .Added})
+	}
+}
+
+set_replace_title :: proc(state: ^Editor_State) {
+	checked := 0
+	for item in state.panel_data.items {
+		if item.check == .Checked {
+			checked += 1
+		}
+	}
+	editor.panel_set_title(
+		state.panel_data,
+		fmt.tprintf(
+			"%q -> %q: %d of %d lines selected (space toggles, ctrl+enter applies)",
+			state.replace.pattern,
+			state.replace.replacement,
+			checked,
+			len(state.replace.entries),
+		),
+	)
+}
+
+// space in the panel: include or exclude the selected line.
+panel_toggle_item :: proc(state: ^Editor_State) {
+	if state.git_status.active {
+		toggle_git_stage(state)
+		return
+	}
+	if editor.panel_toggle_selected(state.panel_data) && state.replace.active {
+		set_replace_title(state)
+	}
+}
+
+// Applies every checked line of the preview.  All files are read and checked
+// before anything is written; each file is then replaced in one atomic write,
+// and each open buffer, parked or not, is edited as a single undo step.
+// Lines that changed since the preview was built are skipped.
+apply_replacements :: proc(state: ^Editor_State) {
+	r := &state.replace
+	if !r.active {
+		set_message(state, "No replace preview to apply")
+		return
+	}
+	if state.search.running != nil {
+		set_message(state, "The replace preview is still being built")
+		return
+	}
+
+	files := make([dynamic]Replace_File, context.temp_allocator)
+	for item in state.panel_data.items {
+		if item.check != .Checked {
+			continue
+		}
+		e := r.entries[item.data]
+		if len(files) == 0 || files[len(files) - 1].path != e.path {
+			entries := make([dynamic]Replace_Entry, context.temp_allocator)
+			append(&files, Replace_File{path = e.path, entries = entries})
+		}
+		append(&files[len(files) - 1].entries, e)
+	}
+
+	applied, skipped := 0, 0
+	for &f in files {
+		full := workspace_path(state, f.path)
+		if is_open_file(state, full) || find_document(state, full) >= 0 {
+			continue
+		}
+		n, stale, ok := replace_in_file(full, &f)
+		if !ok {
+			set_message(state, "Replace aborted: cannot read %s", f.path)
+			return
+		}
+		applied += n
+		skipped += stale
+	}
+
+	changed_files := 0
+	for &f in files {
+		full := workspace_path(state, f.path)
+		if n, stale, open := replace_in_document(state, full, f.entries[:]); open {
+			applied += n
+			skipped += stale
+			changed_files += 1 if n > 0 else 0
+		} else if f.contents != nil {
+			if err := write_file_atomic(full, f.contents); err != nil {
+				set_message(state, "Failed to write %s: %v", f.path, err)
+				return
+			}
+			changed_files += 1
+		}
+	}
+
+	clear_replace(state)
+	editor.panel_clear(state.panel_data)
+	hide_panel(state)
+	if skipped > 0 {
+		set_message(
+			state,
+			"Replaced %d lines in %d files, skipped %d changed lines",
+			applied,
+			changed_files,
+			skipped,
+		)
+	} else {
+		set_message(state, "Replaced %d lines in %d files", applied, changed_files)
+	}
+}
+
+// Builds the new contents of a file that is not open.  Line endings are kept.
+@(private = "file")
+replace_in_file :: proc(full: string, f: ^Replace_File) -> (applied, stale: int, ok: bool) {
+	data, err := os.read_entire_file_from_path(full, context.temp_allocator)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	b := strings.builder_make(context.temp_allocator)
+	next := 0
+	for line, i in strings.split_lines_after(string(data), context.temp_allocator) {
+		if next >= len(f.entries) || f.entries[next].line != i {
+			strings.write_string(&b, line)
+			continue
+		}
+		e := f.entries[next]
+		next += 1
+
+		content := strings.trim_suffix(line, "\n")
+		content = strings.trim_suffix(content, "\r")
+		if content != e.old_text {
+			strings.write_string(&b, line)
+			stale += 1
+			continue
+		}
+		strings.write_string(&b, e.new_text)
+		strings.write_string(&b, line[len(content):])
+		applied += 1
+	}
+	stale += len(f.entries) - next // lines past the end of the file
+
+	if applied > 0 {
+		f.contents = b.buf[:]
+	}
+	return applied, stale, true
+}
+
+// Applies entries, all of the file at full, to its buffer when that is open,
+// the active one or a parked one, so that the buffer's next save keeps them.
+// open is false when no buffer holds the file.
+replace_in_document :: proc(
+	state: ^Editor_State,
+	full: string,
+	entries: []Replace_Entry,
+) -> (
+	applied, stale: int,
+	open: bool,
+) {
+	if is_open_file(state, full) {
+		applied, stale = replace_in_buffer(&state.buffer, &state.undo, &state.cursor_pos, entries)
+		state.selection_anchor = -1
+		sync_cursor(state)
+		set_preferred_col(state)
+		return applied, stale, true
+	}
+	i := find_document(state, full)
+	if i < 0 {
+		return 0, 0, false
+	}
+	d := &state.documents[i]
+	d.buffer.undo = &d.undo
+	applied, stale = replace_in_buffer(&d.buffer, &d.undo, &d.cursor_pos, entries)
+	d.buffer.undo = nil // reattached on activation, see park_active_document
+	d.selection_anchor = -1
+	return applied, stale, true
+}
+
+@(private = "file")
+replace_in_buffer :: proc(
+	gb: ^editor.Gap_Buffer,
+	undo: ^editor.Undo_History,
+	cursor: ^int,
+	entries: []Replace_Entry,
+) -> (
+	applied, stale: int,
+) {
+	editor.begin_undo_group(undo, cursor^)
+	for e in entries {
+		line := editor.get_line(gb, e.line, context.temp_allocator)
+		if e.line >= editor.get_line_count(gb) || strings.trim_suffix(line, "\r") != e.old_text {
+			stale += 1
+			continue
+		}
+		pos := editor.line_col_to_logical_pos(gb, e.line, 0)
+		editor.replace_bytes(gb, pos, len(e.old_text), transmute([]u8)e.new_text)
+		applied += 1
+	}
+	cursor^ = min(cursor^, editor.current_length(gb))
+	editor.end_undo_group(undo, cursor^)
+	return applied, stale
+}
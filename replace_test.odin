@@ -0,0 +1,54 @@
+package main
+
+import "core:strings"
+import "core:testing"
+import editor "editor"
+
+@(test)
+test_replace_edits_parked_documents :: proc(t: ^testing.T) {
+	state: Editor_State
+	state.workspace_root = "/work"
+	append(&state.documents, Document{}) // the active slot, an unnamed buffer
+	append(&state.documents, test_document("a.txt", "one\nold two\n"))
+	append(&state.documents, test_document("b.txt", "old three\nfour\n"))
+	defer {
+		for &d in state.documents {
+			editor.destroy_gap_buffer(&d.buffer)
+			editor.destroy_undo_history(&d.undo)
+			delete(d.path)
+		}
+		delete(state.documents)
+	}
+
+	entries := []Replace_Entry {
+		{path = "b.txt", line = 0, old_text = "old three", new_text = "new three"},
+		{path = "b.txt", line = 1, old_text = "gone", new_text = "five"},
+	}
+	applied, stale, open := replace_in_document(&state, workspace_path(&state, "b.txt"), entries)
+	testing.expect_value(t, open, true)
+	testing.expect_value(t, applied, 1)
+	testing.expect_value(t, stale, 1)
+
+	a, b := &state.documents[1], &state.documents[2]
+	testing.expect_value(t, editor.get_text(&a.buffer, context.temp_allocator), "one\nold two\n")
+	testing.expect_value(t, editor.get_text(&b.buffer, context.temp_allocator), "new three\nfour\n")
+	testing.expect_value(t, editor.is_modified(&a.undo), false)
+	testing.expect_value(t, editor.is_modified(&b.undo), true)
+	testing.expect(t, b.buffer.undo == nil, "a parked buffer stays detached from its history")
+
+	_, _, open = replace_in_document(&state, workspace_path(&state, "c.txt"), entries)
+	testing.expect_value(t, open, false)
+}
+
+@(private = "file")
+test_document :: proc(path, text: string) -> Document {
+	d := Document {
+		id     = len(path), // any id but the active one's
+		path   = strings.clone(path),
+		buffer = editor.init_gap_buffer(),
+		undo   = editor.init_undo_history(),
+	}
+	editor.insert_bytes(&d.buffer, transmute([]u8)text)
+	editor.mark_saved(&d.undo)
+	return d
+}
@@ -0,0 +1,168 @@
+package main
+
+import "core:os"
+import "core:strings"
+import editor "editor"
+
+// Saving a file the user may not write, such as one under /etc, like vim's
+// :w !sudo tee %.  The buffer goes to a temporary file, and a helper running
+// as root copies it over the file, so the file keeps its owner and mode.
+// pkexec asks for the password in a polkit dialog and sudo -A through
+// $SUDO_ASKPASS; file.root_helper, which only the user config sets, names
+// another, such as "doas".
+Root_Save_State :: struct {
+	run:     ^editor.Task_Run,
+	tmp:     string, // the file the helper copies
+	path:    string, // absolute
+	text:    string, // the buffer text it holds
+	doc_id:  int,
+	version: int, // undo version of the buffer then
+	output:  strings.Builder,
+}
+
+destroy_root_save :: proc(state: ^Editor_State) {
+	rs := &state.root_save
+	if rs.run != nil {
+		editor.destroy_task(rs.run)
+		rs.run = nil
+	}
+	finish_root_save(rs)
+	strings.builder_destroy(&rs.output)
+}
+
+// file.save_as_root: writes the buffer to its file with root's rights.
+save_as_root :: proc(state: ^Editor_State) {
+	rs := &state.root_save
+	if rs.run != nil {
+		set_message(state, "Already saving %s as root", document_title(rs.path))
+		return
+	}
+	if state.path == "" || state.preview {
+		set_message(state, "Saving as root needs a file to save")
+		return
+	}
+	helper, ok := root_helper(config_value(state, "file.root_helper").(string) or_else "")
+	if !ok {
+		set_message(state, "No way to write as root here; set file.root_helper")
+		return
+	}
+	full := workspace_path(state, state.path)
+	text := editor.get_text(&state.buffer, context.temp_allocator)
+	data, encoded := buffer_to_file_data(&state.disk, text)
+	if !encoded {
+		name := editor.ENCODING_NAMES[state.disk.encoding]
+		set_message(state, "%s has characters that %s cannot represent", state.path, name)
+		return
+	}
+	tmp, err := os.create_temp_file("", "rune-root-save-*")
+	if err != nil {
+		set_message(state, "Cannot write a temporary file: %v", err)
+		return
+	}
+	rs.tmp = strings.clone(os.name(tmp))
+	_, err = os.write(tmp, data)
+	os.close(tmp)
+	if err != nil {
+		set_message(state, "Cannot write a temporary file: %v", err)
+		finish_root_save(rs)
+		return
+	}
+	command := make([dynamic]string, context.temp_allocator)
+	append(&command, ..helper)
+	append(&command, "cp", "--", rs.tmp, resolve_save_path(full))
+	run, rerr := editor.start_task(command[:], state.workspace_root)
+	if rerr != nil {
+		set_message(state, "Cannot run %s: %v", helper[0], rerr)
+		finish_root_save(rs)
+		return
+	}
+	rs.run = run
+	rs.path = strings.clone(full)
+	rs.text = strings.clone(text)
+	rs.doc_id = state.doc_id
+	rs.version = state.undo.version
+	strings.builder_reset(&rs.output)
+	set_message(state, "Saving %s as root with %s ...", document_title(state.path), helper[0])
+}
+
+// Called after a save failed for want of permission: offers to save as root.
+offer_root_save :: proc(state: ^Editor_State) {
+	if _, ok := root_helper(config_value(state, "file.root_helper").(string) or_else ""); !ok {
+		set_message(state, "No permission to write %s", document_title(state.path))
+		return
+	}
+	confirm(
+		state,
+		"No permission to write the file; save it as root? (y/n)",
+		proc(state: ^Editor_State) {
+			save_as_root(state)
+		},
+	)
+}
+
+// Reports how a save as root went once the helper exits.  Called every
+// frame.
+update_root_save :: proc(state: ^Editor_State) {
+	rs := &state.root_save
+	if rs.run == nil {
+		return
+	}
+	finished := editor.is_task_finished(rs.run)
+	editor.take_task_output(rs.run, &rs.output)
+	if !finished {
+		return
+	}
+	code := rs.run.exit_code
+	editor.destroy_task(rs.run)
+	rs.run = nil
+	title := document_title(rs.path)
+	switch {
+	case code != 0:
+		first, _, _ := strings.partition(strings.trim_space(strings.to_string(rs.output)), "\n")
+		set_message(state, "Saving %s as root failed with exit code %d: %s", title, code, first)
+	case rs.doc_id != state.doc_id:
+		// Parked meanwhile: its slot holds the disk state and undo history.
+		for &d, i in state.documents {
+			if i == state.active || d.id != rs.doc_id {
+				continue
+			}
+			set_disk_state(&d.disk, rs.path, rs.text)
+			if d.undo.version == rs.version {
+				editor.mark_saved(&d.undo)
+				discard_recovery_snapshot(state, d.id)
+			}
+			fire_plugin_event(state, .Save, d.path)
+			break
+		}
+		set_message(state, "Saved %s as root", title)
+	case:
+		set_disk_state(&state.disk, rs.path, rs.text)
+		if state.undo.version == rs.version {
+			editor.mark_saved(&state.undo)
+			discard_recovery_snapshot(state, state.doc_id)
+		}
+		set_message(state, "Saved %s as root", title)
+		if .History not_in state.degraded {
+			record_file_history(state, rs.text)
+		}
+		fire_plugin_event(state, .Save, state.path)
+	}
+	finish_root_save(rs)
+}
+
+@(private = "file")
+finish_root_save :: proc(rs: ^Root_Save_State) {
+	if rs.tmp != "" {
+		os.remove(rs.tmp)
+	}
+	delete(rs.tmp)
+	delete(rs.path)
+	delete(rs.text)
+	rs.tmp, rs.path, rs.text = "", "", ""
+}
+
+// Whether err is what writing a file or directory without permission gives.
+is_permission_error :: proc(err: os.Error) -> bool {
+	e, ok := err.(os.General_Error)
+	return ok && e == .Permission_Denied
+}
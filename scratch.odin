@@ -0,0 +1,84 @@
+package main
+
+import "core:fmt"
+import editor "editor"
+
+// Scratch buffers are untitled buffers for notes and snippets.  They are kept
+// in the session with their text and language, and only get a file when
+// saved with ctrl+s, which asks for a path.
+Language_List_State :: struct {
+	active: bool, // the panel lists the languages
+}
+
+// scratch.new: opens an empty scratch buffer.
+new_scratch_buffer :: proc(state: ^Editor_State) {
+	new_document(state)
+	state.scratch = true
+	set_message(state, "Scratch buffer; kept in the session until saved")
+}
+
+// Opens a scratch buffer saved in the session.
+restore_scratch_buffer :: proc(state: ^Editor_State, text, language: string) {
+	if !is_blank_document(state) {
+		new_document(state)
+	}
+	editor.gap_buffer_clear(&state.buffer)
+	editor.insert_bytes(&state.buffer, transmute([]u8)text)
+	editor.clear_undo_history(&state.undo)
+	state.scratch = true
+	if editor.find_language(language) != nil {
+		set_language(state, language)
+	}
+}
+
+// language.select: lists the languages; enter switches the buffer to one.
+show_languages :: proc(state: ^Editor_State) {
+	clear_replace(state)
+	stop_project_search(state)
+	release_panel(state)
+	state.language_list.active = true
+	panel := state.panel_data
+	editor.panel_clear(panel)
+	editor.panel_set_title(panel, "Languages; enter sets the buffer's")
+	for lang, i in editor.LANGUAGES {
+		marker := "*" if lang.id == state.language else " "
+		text := fmt.tprintf("%s %-16s %s", marker, lang.name, lang.id)
+		editor.panel_add_item(panel, {text = text, line = -1, data = i})
+		if lang.id == state.language {
+			panel.selected = i
+		}
+	}
+	show_panel(state)
+}
+
+// Enter in the language list.
+set_language_entry :: proc(state: ^Editor_State) {
+	item := editor.panel_selected_item(state.panel_data)
+	if item == nil {
+		return
+	}
+	state.language_list.active = false
+	hide_panel(state)
+	lang := editor.LANGUAGES[item.data]
+	set_language(state, lang.id)
+	set_message(state, "Language: %s", lang.name)
+}
+
+// language.detect: picks the buffer's language again from its name and text,
+// after a #! line or modeline was typed, say.
+detect_buffer_language :: proc(state: ^Editor_State) {
+	if state.disk.view == .Hex {
+		set_message(state, "A hex dump has no language to detect")
+		return
+	}
+	text := editor.get_text(&state.buffer, context.temp_allocator)
+	lang := editor.detect_language(state.path, text)
+	set_language(state, lang.id)
+	set_message(state, "Language: %s", lang.name)
+}
+
+// Whether an untitled buffer is kept in the session: one made with
+// scratch.new or restored from the session, or any that was typed in.
+is_scratch :: proc(path: string, scratch: bool, undo: ^editor.Undo_History) -> bool {
+	return path == "" && (scratch || editor.is_modified(undo))
+}
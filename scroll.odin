@@ -0,0 +1,82 @@
+package main
+
+import "core:time"
+import editor "editor"
+
+SMOOTH_SCROLL_RATE :: 18 // share of the remaining distance covered per second
+
+// Scrolls the view so y is its top, gliding there when editor.smooth_scroll
+// is on.
+scroll_to :: proc(state: ^Editor_State, y: f32) {
+	lines := f32(editor.get_line_count(&state.buffer))
+	state.scroll_target = clamp(y, 0, max(lines * state.line_height - state.line_height, 0))
+	if !config_bool(state, "editor.smooth_scroll") {
+		state.layer_ctx.scroll_y = state.scroll_target
+	}
+}
+
+// Puts the view's top at y at once, for a new document or a restored view.
+jump_scroll :: proc(state: ^Editor_State, y: f32) {
+	state.scroll_target = y
+	state.layer_ctx.scroll_y = y
+}
+
+// Moves the view towards its target.  Called every frame.
+update_smooth_scroll :: proc(state: ^Editor_State) {
+	now := time.tick_now()
+	dt := f32(time.duration_seconds(time.tick_diff(state.scroll_tick, now)))
+	state.scroll_tick = now
+	y := &state.layer_ctx.scroll_y
+	remaining := state.scroll_target - y^
+	if abs(remaining) < 0.5 {
+		y^ = state.scroll_target
+		return
+	}
+	y^ += remaining * min(dt * SMOOTH_SCROLL_RATE, 1)
+}
+
+scroll_page_down :: proc(state: ^Editor_State) {
+	scroll_lines(state, page_lines(state))
+}
+
+scroll_page_up :: proc(state: ^Editor_State) {
+	scroll_lines(state, -page_lines(state))
+}
+
+scroll_half_page_down :: proc(state: ^Editor_State) {
+	scroll_lines(state, max(visible_lines(state) / 2, 1))
+}
+
+scroll_half_page_up :: proc(state: ^Editor_State) {
+	scroll_lines(state, -max(visible_lines(state) / 2, 1))
+}
+
+// Scrolls the view by n lines and moves the cursor as many, so it stays on
+// the same row of the window.  At either end of the buffer, where the view
+// cannot move as far, the cursor still does.
+@(private = "file")
+scroll_lines :: proc(state: ^Editor_State, n: int) {
+	scroll_to(state, state.scroll_target + f32(n) * state.line_height)
+	last := editor.get_line_count(&state.buffer) - 1
+	line := clamp(state.cursor_data.line + n, 0, max(last, 0))
+	byte_col := editor.visual_col_to_byte_col(
+		&state.buffer,
+		line,
+		state.preferred_col,
+		state.layer_ctx.tab_size,
+	)
+	state.cursor_pos = editor.line_col_to_logical_pos(&state.buffer, line, byte_col)
+	sync_cursor(state)
+	// preferred_col stays, as for up and down.
+}
+
+@(private = "file")
+visible_lines :: proc(state: ^Editor_State) -> int {
+	return max(int(text_area_height(state) / state.line_height), 1)
+}
+
+// A page keeps one line of the previous one in view.
+@(private = "file")
+page_lines :: proc(state: ^Editor_State) -> int {
+	return max(visible_lines(state) - 1, 1)
+}
@@ -0,0 +1,435 @@
+package main
+
+import "core:encoding/json"
+import "core:fmt"
+import "core:log"
+import "core:os"
+import "core:path/filepath"
+import "core:slice"
+import "core:strings"
+import "core:time"
+
+SERVERS_DIR :: "servers" // in the user cache dir, one directory per server
+SERVERS_MANIFEST :: "servers.json" // in SERVERS_DIR
+
+// Release names of the prebuilt servers for this machine.
+when ODIN_OS == .Darwin {
+	RUST_ANALYZER_OS :: "apple-darwin"
+	CLANGD_OS :: "mac"
+} else when ODIN_OS == .Windows {
+	RUST_ANALYZER_OS :: "pc-windows-msvc"
+	CLANGD_OS :: "windows"
+} else {
+	RUST_ANALYZER_OS :: "unknown-linux-gnu"
+	CLANGD_OS :: "linux"
+}
+when ODIN_ARCH == .arm64 {
+	RUST_ANALYZER_TARGET :: "aarch64-" + RUST_ANALYZER_OS
+} else {
+	RUST_ANALYZER_TARGET :: "x86_64-" + RUST_ANALYZER_OS
+}
+
+// Checks the file "$1" against $SHA256 before anything runs it.
+VERIFY_SHA256 ::
+	`verify() { if command -v sha256sum >/dev/null; then echo "$SHA256  $1" | sha256sum -c -; ` +
+	`else echo "$SHA256  $1" | shasum -a 256 -c -; fi; }; `
+
+// A language server lsp.install knows how to fetch.  The scripts run in a
+// POSIX shell with $DIR set to the server's directory; install also gets
+// the version latest printed as $V.  A server that is downloaded rather
+// than built by its package manager has no latest: it installs the release
+// SERVER_PINS names for target, with $SHA256 set to check it by.
+Server_Spec :: struct {
+	name:      string,
+	languages: []string,
+	binary:    string, // relative to $DIR
+	latest:    string, // prints the newest version
+	target:    string, // the download for this machine, see Server_Pin
+	install:   string,
+}
+
+// A release of a downloaded server and the SHA-256 of its file for one
+// target, as its release page lists them.  Updating a server means adding
+// its new release here.
+Server_Pin :: struct {
+	server:  string,
+	version: string,
+	target:  string,
+	sha256:  string,
+}
+
+// Newest last.  A server without a pin for this machine is not installed.
+SERVER_PINS := [?]Server_Pin{}
+
+SERVER_SPECS := [?]Server_Spec {
+	{
+		name = "gopls",
+		languages = {"go"},
+		binary = "bin/gopls",
+		latest = "go list -m -f '{{.Version}}' golang.org/x/tools/gopls@latest",
+		install = `GOBIN="$DIR/bin" go install "golang.org/x/tools/gopls@$V"`,
+	},
+	{
+		name = "rust-analyzer",
+		languages = {"rust"},
+		binary = "bin/rust-analyzer",
+		target = RUST_ANALYZER_TARGET,
+		install = VERIFY_SHA256 + `mkdir -p "$DIR/bin" && curl -fsSL -o "$DIR/download.gz" ` +
+			`"https://github.com/rust-lang/rust-analyzer/releases/download/$V/` +
+			`rust-analyzer-` + RUST_ANALYZER_TARGET + `.gz" && verify "$DIR/download.gz"` +
+			` && gunzip -c "$DIR/download.gz" > "$DIR/bin/rust-analyzer"` +
+			` && rm "$DIR/download.gz" && chmod +x "$DIR/bin/rust-analyzer"`,
+	},
+	{
+		name = "pyright",
+		languages = {"python"},
+		binary = "node_modules/.bin/pyright-langserver",
+		latest = "npm view pyright version",
+		install = `npm install --silent --prefix "$DIR" "pyright@$V"`,
+	},
+	{
+		name = "clangd",
+		languages = {"c", "cpp"},
+		binary = "bin/clangd",
+		target = CLANGD_OS,
+		install = VERIFY_SHA256 + `curl -fsSL -o "$DIR/clangd.zip" ` +
+			`"https://github.com/clangd/clangd/releases/download/$V/clangd-` + CLANGD_OS +
+			`-$V.zip" && verify "$DIR/clangd.zip"` +
+			` && unzip -o -q "$DIR/clangd.zip" -d "$DIR" && rm "$DIR/clangd.zip"` +
+			` && mkdir -p "$DIR/bin" && ln -sf "$DIR/clangd_$V/bin/clangd" "$DIR/bin/clangd"`,
+	},
+}
+
+// What servers.json remembers of an installed server.
+Server_Record :: struct {
+	version:   string,
+	installed: string, // date, YYYY-MM-DD
+}
+
+Server_Install_State :: struct {
+	records:    map[string]Server_Record, // servers.json, by server name
+	installing: int, // index into SERVER_SPECS, -1 for none
+	version:    string, // the version the install script reported
+	outdated:   [dynamic]string, // found by lsp.check_updates, as messages
+	suggested:  [len(SERVER_SPECS)]bool, // install already suggested this run
+}
+
+load_server_records :: proc(state: ^Editor_State) {
+	s := &state.servers
+	s.installing = -1
+	path, ok := servers_path(SERVERS_MANIFEST)
+	if !ok {
+		return
+	}
+	data, err := os.read_entire_file_from_path(path, context.temp_allocator)
+	if err != nil {
+		return
+	}
+	if uerr := json.unmarshal(data, &s.records); uerr != nil {
+		log.warnf("servers: %s: %v", path, uerr)
+	}
+}
+
+destroy_server_records :: proc(state: ^Editor_State) {
+	s := &state.servers
+	for name, r in s.records {
+		delete(name)
+		delete(r.version)
+		delete(r.installed)
+	}
+	delete(s.records)
+	delete(s.version)
+	clear_outdated(s)
+	delete(s.outdated)
+}
+
+// The program to start for a server: the installed copy, else the one on
+// PATH.  Temp allocated.
+server_binary :: proc(name: string) -> (path: string, ok: bool) {
+	for spec in SERVER_SPECS {
+		if spec.name != name {
+			continue
+		}
+		if dir, dok := servers_path(name); dok {
+			installed := filepath.join({dir, spec.binary}, context.temp_allocator)
+			if os.exists(installed) {
+				return installed, true
+			}
+		}
+		return find_in_path(filepath.base(spec.binary))
+	}
+	return find_in_path(name)
+}
+
+// `lsp install <server>`: asks which server to install or update.
+install_server_prompt :: proc(state: ^Editor_State) {
+	names := make([]string, len(SERVER_SPECS), context.temp_allocator)
+	for spec, i in SERVER_SPECS {
+		names[i] = spec.name
+	}
+	open_prompt(state, "Install server:", proc(state: ^Editor_State, name: string) {
+		install_server(state, strings.trim_space(name))
+	})
+	set_message(state, "Servers: %s", strings.join(names, ", ", context.temp_allocator))
+}
+
+// Downloads the newest version of a server into its directory under the
+// user cache dir, with the output in the task panel.
+install_server :: proc(state: ^Editor_State, name: string) {
+	when ODIN_OS == .Windows {
+		set_message(state, "Installing servers needs a POSIX shell; install %s by hand", name)
+		return
+	}
+	index := find_server_spec(name)
+	if index < 0 {
+		set_message(state, "No server %q to install", name)
+		return
+	}
+	spec := &SERVER_SPECS[index]
+	latest, pinned := server_latest(spec)
+	if !pinned {
+		set_message(state, "No release of %s is pinned for this machine", spec.name)
+		return
+	}
+	dir, ok := servers_path(spec.name)
+	if !ok {
+		set_message(state, "There is no user cache directory")
+		return
+	}
+	if err := os.make_directory_all(dir); err != nil {
+		set_message(state, "Cannot create %s: %v", dir, err)
+		return
+	}
+	// The version goes first, on a line of its own, for install_output_line.
+	script := fmt.tprintf(
+		"set -e; DIR=%s; V=$(%s); test -n \"$V\"; echo \"rune-version: $V\"; %s",
+		shell_quote(dir),
+		latest,
+		spec.install,
+	)
+	if pin, found := server_pin(spec); found {
+		script = fmt.tprintf("SHA256=%s; %s", shell_quote(pin.sha256), script)
+	}
+	if !start_task_run(state, fmt.tprintf("install %s", spec.name), script, dir) {
+		return
+	}
+	s := &state.servers
+	s.installing = index
+	delete(s.version)
+	s.version = ""
+	state.tasks.on_line = install_output_line
+	state.tasks.on_finish = install_finished
+}
+
+// Finds the newest version of every installed server and lists those that
+// are behind.
+check_server_updates :: proc(state: ^Editor_State) {
+	when ODIN_OS == .Windows {
+		set_message(state, "Checking for server updates needs a POSIX shell")
+		return
+	}
+	b := strings.builder_make(context.temp_allocator)
+	for &spec in SERVER_SPECS {
+		latest, pinned := server_latest(&spec)
+		if pinned && spec.name in state.servers.records {
+			fmt.sbprintf(&b, "echo \"rune-latest: %s $(%s)\"; ", spec.name, latest)
+		}
+	}
+	if strings.builder_len(b) == 0 {
+		set_message(state, "No servers are installed; lsp.install installs one")
+		return
+	}
+	if !start_task_run(state, "server updates", strings.to_string(b), state.workspace_root) {
+		return
+	}
+	clear_outdated(&state.servers)
+	state.tasks.on_line = update_check_line
+	state.tasks.on_finish = update_check_finished
+}
+
+// Suggests installing the server of the active buffer's language when
+// neither an installed copy nor one on PATH exists.  Once per server and run.
+suggest_server_install :: proc(state: ^Editor_State) {
+	for spec, i in SERVER_SPECS {
+		if state.servers.suggested[i] || !slice.contains(spec.languages, state.language) {
+			continue
+		}
+		state.servers.suggested[i] = true
+		if _, ok := server_binary(spec.name); !ok {
+			set_message(state, "%s is not installed; lsp.install installs it", spec.name)
+		}
+		return
+	}
+}
+
+@(private = "file")
+install_output_line :: proc(state: ^Editor_State, line: string) {
+	log.debugf("servers: install: %s", line)
+	if strings.has_prefix(line, "rune-version: ") {
+		delete(state.servers.version)
+		state.servers.version = strings.clone(strings.trim_space(line[len("rune-version: "):]))
+	}
+}
+
+@(private = "file")
+install_finished :: proc(state: ^Editor_State, exit_code: int) {
+	s := &state.servers
+	if s.installing < 0 {
+		return
+	}
+	spec := &SERVER_SPECS[s.installing]
+	s.installing = -1
+	if exit_code != 0 || s.version == "" {
+		if exit_code >= 0 {
+			log.warnf("servers: installing %s failed with exit code %d", spec.name, exit_code)
+			set_message(state, "Installing %s failed, see the task output", spec.name)
+		}
+		return
+	}
+	if old_name, old, found := delete_record(s, spec.name); found {
+		delete(old_name)
+		delete(old.version)
+		delete(old.installed)
+	}
+	date := time.now()
+	y, m, d := time.date(date)
+	s.records[strings.clone(spec.name)] = Server_Record {
+		version   = strings.clone(s.version),
+		installed = fmt.aprintf("%04d-%02d-%02d", y, int(m), d),
+	}
+	save_server_records(state)
+	set_message(state, "Installed %s %s", spec.name, s.version)
+}
+
+@(private = "file")
+update_check_line :: proc(state: ^Editor_State, line: string) {
+	if !strings.has_prefix(line, "rune-latest: ") {
+		return
+	}
+	name, _, latest := strings.partition(line[len("rune-latest: "):], " ")
+	latest = strings.trim_space(latest)
+	r, ok := state.servers.records[name]
+	if !ok || latest == "" || latest == r.version {
+		return
+	}
+	message := fmt.aprintf("%s %s (installed %s)", name, latest, r.version)
+	append(&state.servers.outdated, message)
+}
+
+@(private = "file")
+update_check_finished :: proc(state: ^Editor_State, exit_code: int) {
+	s := &state.servers
+	if exit_code < 0 {
+		return
+	}
+	if len(s.outdated) == 0 {
+		set_message(state, "Every installed server is up to date")
+		return
+	}
+	set_message(
+		state,
+		"Updates: %s; lsp.install updates a server",
+		strings.join(s.outdated[:], ", ", context.temp_allocator),
+	)
+}
+
+@(private = "file")
+save_server_records :: proc(state: ^Editor_State) {
+	path, ok := servers_path(SERVERS_MANIFEST)
+	if !ok {
+		return
+	}
+	data, merr := json.marshal(state.servers.records, {pretty = true}, context.temp_allocator)
+	if merr != nil {
+		return
+	}
+	if err := write_file_atomic(path, data); err != nil {
+		log.warnf("servers: %s: %v", path, err)
+	}
+}
+
+// <user cache dir>/rune/servers/<name>.  Temp allocated.
+@(private = "file")
+servers_path :: proc(name: string) -> (path: string, ok: bool) {
+	cache, err := os.user_cache_dir(context.temp_allocator)
+	if err != nil {
+		return "", false
+	}
+	return filepath.join({cache, "rune", SERVERS_DIR, name}, context.temp_allocator), true
+}
+
+// The command that prints the version of spec to install: its latest, or
+// for a download the newest release pinned for this machine.  Temp allocated.
+@(private = "file")
+server_latest :: proc(spec: ^Server_Spec) -> (command: string, ok: bool) {
+	if spec.target == "" {
+		return spec.latest, true
+	}
+	pin := server_pin(spec) or_return
+	return fmt.tprintf("echo %s", shell_quote(pin.version)), true
+}
+
+@(private = "file")
+server_pin :: proc(spec: ^Server_Spec) -> (pin: Server_Pin, ok: bool) {
+	#reverse for p in SERVER_PINS {
+		if p.server == spec.name && p.target == spec.target {
+			return p, true
+		}
+	}
+	return {}, false
+}
+
+// Looks for an executable called name in the directories of PATH.  Temp
+// allocated.
+@(private = "file")
+find_in_path :: proc(name: string) -> (path: string, ok: bool) {
+	LIST_SEPARATOR :: ";" when ODIN_OS == .Windows else ":"
+	dirs := os.get_env("PATH", context.temp_allocator)
+	for dir in strings.split(dirs, LIST_SEPARATOR, context.temp_allocator) {
+		if dir == "" {
+			continue
+		}
+		candidate := filepath.join({dir, name}, context.temp_allocator)
+		when ODIN_OS == .Windows {
+			candidate = strings.concatenate({candidate, ".exe"}, context.temp_allocator)
+		}
+		if os.exists(candidate) {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+@(private = "file")
+find_server_spec :: proc(name: string) -> int {
+	for spec, i in SERVER_SPECS {
+		if spec.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+@(private = "file")
+delete_record :: proc(
+	s: ^Server_Install_State,
+	name: string,
+) -> (
+	key: string,
+	r: Server_Record,
+	found: bool,
+) {
+	if name not_in s.records {
+		return
+	}
+	key, r = delete_key(&s.records, name)
+	return key, r, true
+}
+
+@(private = "file")
+clear_outdated :: proc(s: ^Server_Install_State) {
+	for message in s.outdated {
+		delete(message)
+	}
+	clear(&s.outdated)
+}
@@ -0,0 +1,183 @@
+package main
+
+import "core:encoding/json"
+import "core:log"
+import "core:os"
+import "core:path/filepath"
+import "core:strings"
+import editor "editor"
+import "vendor:glfw"
+
+SESSION_FILE :: "session.json"
+LAST_SESSION_FILE :: "last-session.json"
+
+Session_Document :: struct {
+	path:     string, // absolute; empty for a scratch buffer
+	line:     int,
+	col:      int,
+	scroll_y: f32,
+	text:     string, // a scratch buffer's, see scratch.odin
+	language: string, // a scratch buffer's
+}
+
+// What is restored on the next start: the open files with their cursors, the
+// scratch buffers, the window geometry and the workspace.  Saved per workspace on exit, and also
+// as the global last session used by `rune --restore`.
+Session :: struct {
+	workspace: string,
+	folders:   []string, // added to the workspace, see workspace.add_folder
+	documents: []Session_Document,
+	active:    int,
+	window:    [4]i32, // x, y, width, height
+}
+
+save_session :: proc(state: ^Editor_State, window: glfw.WindowHandle) {
+	docs := make([dynamic]Session_Document, context.temp_allocator)
+	active := 0
+	for &d, i in state.documents {
+		doc: Session_Document
+		scratch: bool
+		if i == state.active {
+			active = len(docs)
+			doc = {
+				path     = state.path,
+				line     = state.cursor_data.line,
+				col      = state.cursor_data.col,
+				scroll_y = state.scroll_target,
+			}
+			if is_scratch(state.path, state.scratch, &state.undo) {
+				scratch = true
+				doc.text = editor.get_text(&state.buffer, context.temp_allocator)
+				doc.language = state.language
+			}
+		} else {
+			line, col := editor.logical_pos_to_line_col(&d.buffer, d.cursor_pos)
+			doc = {
+				path     = d.path,
+				line     = line,
+				col      = col,
+				scroll_y = d.scroll_y,
+			}
+			if is_scratch(d.path, d.scratch, &d.undo) {
+				scratch = true
+				doc.text = editor.get_text(&d.buffer, context.temp_allocator)
+				doc.language = d.language
+			}
+		}
+		if doc.path != "" {
+			doc.path = workspace_path(state, doc.path)
+			append(&docs, doc)
+		} else if scratch {
+			append(&docs, doc)
+		}
+	}
+
+	s := Session {
+		workspace = state.workspace_root,
+		folders   = state.projects.folders[:],
+		documents = docs[:],
+		active    = active,
+	}
+	s.window[0], s.window[1] = glfw.GetWindowPos(window)
+	s.window[2], s.window[3] = glfw.GetWindowSize(window)
+
+	data, err := json.marshal(s, {pretty = true}, context.temp_allocator)
+	if err != nil {
+		log.warn("session: failed to encode:", err)
+		return
+	}
+	if path, ok := workspace_state_path(state, SESSION_FILE); ok {
+		write_session_file(path, data)
+	}
+	if path, ok := user_config_path(LAST_SESSION_FILE); ok {
+		write_session_file(path, data)
+	}
+}
+
+// Restores the session of the current workspace, if it has one.
+restore_workspace_session :: proc(state: ^Editor_State, window: glfw.WindowHandle) -> bool {
+	path := workspace_state_path(state, SESSION_FILE) or_return
+	return restore_session(state, window, path)
+}
+
+// `rune --restore`: restores the last session of any workspace.
+restore_last_session :: proc(state: ^Editor_State, window: glfw.WindowHandle) -> bool {
+	path := user_config_path(LAST_SESSION_FILE) or_return
+	return restore_session(state, window, path)
+}
+
+// Switches to the session's workspace and reopens its files and scratch
+// buffers.  Files that no longer exist are skipped.  Returns false when
+// nothing was restored.
+restore_session :: proc(state: ^Editor_State, window: glfw.WindowHandle, path: string) -> bool {
+	data, err := os.read_entire_file_from_path(path, context.temp_allocator)
+	if err != nil {
+		return false
+	}
+	s: Session
+	if uerr := json.unmarshal(data, &s, allocator = context.temp_allocator); uerr != nil {
+		log.warnf("session: %s: %v", path, uerr)
+		return false
+	}
+
+	if s.workspace != "" && s.workspace != state.workspace_root {
+		set_workspace(state, s.workspace)
+	}
+	for dir in s.folders {
+		if os.is_dir(dir) {
+			add_workspace_folder(state, dir)
+		}
+	}
+	if s.window[2] > 0 && s.window[3] > 0 {
+		glfw.SetWindowPos(window, s.window[0], s.window[1])
+		glfw.SetWindowSize(window, s.window[2], s.window[3])
+	}
+
+	restored := false
+	active := -1
+	for doc, i in s.documents {
+		if doc.path == "" {
+			restore_scratch_buffer(state, doc.text, doc.language)
+		} else if !os.exists(doc.path) || !open_file(state, doc.path) {
+			continue
+		}
+		goto_line_col(state, doc.line, doc.col)
+		jump_scroll(state, doc.scroll_y)
+		restored = true
+		if i == s.active {
+			active = state.active
+		}
+	}
+	if active >= 0 {
+		switch_document(state, active)
+	}
+	return restored
+}
+
+// Makes dir the workspace root: the working directory, project search root
+// the key for per-workspace state and the source of project settings and
+// config.
+set_workspace :: proc(state: ^Editor_State, dir: string) -> bool {
+	if err := os.set_working_directory(dir); err != nil {
+		log.warn("session: cannot enter workspace", dir, err)
+		return false
+	}
+	delete(state.workspace_root)
+	state.workspace_root = strings.clone(dir)
+	destroy_workspace_folders(state) // the old workspace's
+	destroy_project_settings(state)
+	load_project_settings(state)
+	destroy_bookmarks(state)
+	load_bookmarks(state)
+	reload_workspace_config(state)
+	note_recent_project(state)
+	return true
+}
+
+@(private = "file")
+write_session_file :: proc(path: string, data: []u8) {
+	_ = os.make_directory_all(filepath.dir(path, context.temp_allocator))
+	if err := write_file_atomic(path, data); err != nil {
+		log.warnf("session: %s: %v", path, err)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import "core:strings"
+import editor "editor"
+
+// Tab stops left in the buffer by an inserted snippet, such as a call's
+// parameters: tab selects the next, shift+tab the one before, and the
+// session ends at the last stop, on escape, or once the cursor or an edit
+// leaves the stop it is in.  Stops are byte ranges kept up to date by
+// assuming each edit happened in the current one.
+Snippet_State :: struct {
+	active:  bool,
+	doc_id:  int,
+	stops:   [dynamic][2]int, // in the order they are visited
+	current: int,
+	length:  int, // of the buffer when stops were last brought up to date
+}
+
+destroy_snippet :: proc(state: ^Editor_State) {
+	delete(state.snippet.stops)
+}
+
+// Replaces start..end of the buffer with snippet (see editor.parse_snippet)
+// and selects its first stop.
+insert_snippet :: proc(state: ^Editor_State, start, end: int, snippet: string) {
+	text, stops := editor.parse_snippet(snippet)
+	editor.replace_bytes(&state.buffer, start, end - start, transmute([]u8)text)
+	s := &state.snippet
+	clear(&s.stops)
+	for stop in stops {
+		append(&s.stops, [2]int{start + stop[0], start + stop[1]})
+	}
+	s.active = true
+	s.doc_id = state.doc_id
+	s.length = editor.current_length(&state.buffer)
+	s.current = -1
+	jump_snippet_stop(state, 1)
+}
+
+// snippet.exit: forgets the stops, leaving the text as it is.
+end_snippet :: proc(state: ^Editor_State) {
+	state.snippet.active = false
+	clear(&state.snippet.stops)
+}
+
+// Moves to the stop dir away from the current one, selecting its text.
+// False when no session is on, so tab keeps its usual meaning.
+jump_snippet_stop :: proc(state: ^Editor_State, dir: int) -> bool {
+	s := &state.snippet
+	if !sync_snippet(state) {
+		return false
+	}
+	next := clamp(s.current + dir, 0, len(s.stops) - 1)
+	s.current = next
+	stop := s.stops[next]
+	state.cursor_pos = stop[1]
+	state.selection_anchor = stop[0] if stop[0] != stop[1] else -1
+	sync_cursor(state)
+	sync_selection(state)
+	set_preferred_col(state)
+	if next == len(s.stops) - 1 {
+		end_snippet(state)
+	}
+	return true
+}
+
+// Brings the stops up to date with the buffer, ending the session when the
+// cursor is no longer where an edit in the current stop leaves it.  Called
+// every frame.
+update_snippet :: proc(state: ^Editor_State) {
+	sync_snippet(state)
+}
+
+@(private = "file")
+sync_snippet :: proc(state: ^Editor_State) -> bool {
+	s := &state.snippet
+	if !s.active {
+		return false
+	}
+	if s.doc_id != state.doc_id {
+		end_snippet(state)
+		return false
+	}
+	if s.current < 0 {
+		return true // being inserted
+	}
+	length := editor.current_length(&state.buffer)
+	delta := length - s.length
+	cur := &s.stops[s.current]
+	lo, hi := cur[0], cur[1] + delta
+	if hi < lo || state.cursor_pos < lo || state.cursor_pos > hi {
+		end_snippet(state)
+		return false
+	}
+	if delta != 0 {
+		cur[1] = hi
+		for &stop in s.stops[s.current + 1:] {
+			stop[0] += delta
+			stop[1] += delta
+		}
+		s.length = length
+	}
+	return true
+}
+
+// After name was completed before the cursor: when it is a function's,
+// adds its call with the parameters from its definition as stops, by
+// completion.call_placeholders, unless a "(" follows already.
+complete_call :: proc(state: ^Editor_State, name: string) {
+	if !config_bool(state, "completion.call_placeholders") {
+		return
+	}
+	line := editor.get_line(&state.buffer, state.cursor_data.line, context.temp_allocator)
+	if after := line[min(state.cursor_data.col, len(line)):]; strings.has_prefix(after, "(") {
+		return
+	}
+	path, def_line, ok := find_definition(state, name)
+	if !ok || def_line < 0 {
+		return
+	}
+	text, lang, read := definition_source(state, path)
+	if !read {
+		return
+	}
+	code, _ := editor.definition_docs(text, def_line, lang)
+	params, callable := editor.call_parameters(code, name)
+	if !callable {
+		return
+	}
+	b := strings.builder_make(context.temp_allocator)
+	strings.write_byte(&b, '(')
+	for p, i in params {
+		if i > 0 {
+			strings.write_string(&b, ", ")
+		}
+		strings.write_string(&b, "${")
+		strings.write_int(&b, i + 1)
+		strings.write_byte(&b, ':')
+		strings.write_string(&b, editor.escape_snippet(p))
+		strings.write_byte(&b, '}')
+	}
+	strings.write_string(&b, ")$0")
+	insert_snippet(state, state.cursor_pos, state.cursor_pos, strings.to_string(b))
+}
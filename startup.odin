@@ -0,0 +1,74 @@
+package main
+
+import "core:fmt"
+import "core:time"
+
+// Startup opens the window and shows the buffer first.  Subsystems the first
+// frame does not need wait until it is on screen: the plugin host, and the
+// git and indexing work the first ticks would otherwise start.
+Startup_State :: struct {
+	ready:   bool, // the deferred subsystems are up, see finish_startup
+	profile: bool, // --profile-startup
+	began:   time.Tick,
+	mark:    time.Tick, // end of the last phase
+	phases:  [dynamic]Startup_Phase,
+}
+
+Startup_Phase :: struct {
+	name:     string,
+	duration: time.Duration,
+}
+
+// began is when main started, before the window.
+begin_startup :: proc(state: ^Editor_State, began: time.Tick, profile: bool) {
+	s := &state.startup
+	s.began = began
+	s.mark = began
+	s.profile = profile
+}
+
+// Ends the phase called name, timed from the end of the one before.
+startup_phase :: proc(state: ^Editor_State, name: string) {
+	s := &state.startup
+	if !s.profile || s.ready {
+		return
+	}
+	now := time.tick_now()
+	append(&s.phases, Startup_Phase{name, time.tick_diff(s.mark, now)})
+	s.mark = now
+}
+
+// Called once the first frame was drawn: brings up the deferred subsystems
+// and, with --profile-startup, prints where the time went.
+finish_startup :: proc(state: ^Editor_State) {
+	startup_phase(state, "first frame")
+	first_frame := time.tick_diff(state.startup.began, time.tick_now())
+
+	load_plugins(state)
+	// Plugins missed the buffers opened so far.
+	for d, i in state.documents {
+		path := state.path if i == state.active else d.path
+		if path != "" {
+			fire_plugin_event(state, .Open, path)
+		}
+	}
+	fire_plugin_event(state, .Enter, state.path)
+	startup_phase(state, "plugins")
+
+	s := &state.startup
+	if s.profile {
+		for p in s.phases {
+			fmt.eprintfln("startup: %-24s %8.2f ms", p.name, time.duration_milliseconds(p.duration))
+		}
+		total := time.tick_diff(s.began, time.tick_now())
+		fmt.eprintfln(
+			"startup: %-24s %8.2f ms, first frame at %.2f ms",
+			"total",
+			time.duration_milliseconds(total),
+			time.duration_milliseconds(first_frame),
+		)
+	}
+	delete(s.phases)
+	s.phases = nil
+	s.ready = true
+}
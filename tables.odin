@@ -0,0 +1,240 @@
+package main
+
+import "core:strings"
+import editor "editor"
+
+// Markdown tables: with table.auto_align the columns of the table the cursor
+// is in line up again after each character typed, tab and shift+tab move
+// between cells, and the table.* commands add and remove rows and columns or
+// make a table of comma separated values.
+@(private = "file")
+Table_At_Cursor :: struct {
+	table:       editor.Markdown_Table,
+	first, last: int, // its lines
+	row, cell:   int, // where the cursor is
+	offset:      int, // bytes into the cell's trimmed text
+}
+
+// Lines up the table after a character was typed in it.  A blank is left
+// alone, or the cell's trailing blank would go before the next word is typed.
+align_table_as_typed :: proc(state: ^Editor_State, typed: rune) {
+	if state.language != "markdown" || typed == ' ' || !config_bool(state, "table.auto_align") {
+		return
+	}
+	t, ok := table_at_cursor(state)
+	if ok && len(t.table.rows) > 1 {
+		write_table(state, &t)
+	}
+}
+
+// table.align: lines up the table at the cursor, adding a delimiter row under
+// its header when it has none.
+align_table :: proc(state: ^Editor_State) {
+	t, ok := table_at_cursor(state)
+	if !ok {
+		set_message(state, "No table at the cursor")
+		return
+	}
+	if t.table.delimiter < 0 && t.row > 0 {
+		t.row += 1
+	}
+	editor.ensure_table_delimiter(&t.table)
+	write_table(state, &t)
+}
+
+// table.next_cell: to the next cell, past the delimiter row, adding a row
+// after the last.  Outside a table it is edit.tab.
+table_next_cell :: proc(state: ^Editor_State) {
+	t, ok := table_at_cursor(state)
+	if !ok || has_selection(state) {
+		insert_tab(state)
+		return
+	}
+	t.cell += 1
+	if t.cell >= editor.table_columns(&t.table) {
+		t.cell = 0
+		t.row += 1
+		if t.row == t.table.delimiter {
+			t.row += 1
+		}
+		if t.row >= len(t.table.rows) {
+			append(&t.table.rows, make([dynamic]string, context.temp_allocator))
+		}
+	}
+	t.offset = max(int)
+	write_table(state, &t)
+}
+
+// table.prev_cell: to the previous cell.  Outside a table it dedents.
+table_prev_cell :: proc(state: ^Editor_State) {
+	t, ok := table_at_cursor(state)
+	if !ok || has_selection(state) {
+		shift_selected_lines(state, -1)
+		return
+	}
+	if t.cell > 0 {
+		t.cell -= 1
+	} else if t.row > 0 {
+		t.row -= 1
+		if t.row == t.table.delimiter {
+			t.row -= 1
+		}
+		t.cell = editor.table_columns(&t.table) - 1
+	}
+	t.offset = max(int)
+	write_table(state, &t)
+}
+
+// table.row_add: an empty row under the cursor's, or under the delimiter row
+// on the header.
+table_add_row :: proc(state: ^Editor_State) {
+	t, ok := table_at_cursor(state)
+	if !ok {
+		set_message(state, "No table at the cursor")
+		return
+	}
+	t.row += 1
+	if t.row == t.table.delimiter {
+		t.row += 1
+	}
+	inject_at(&t.table.rows, t.row, make([dynamic]string, context.temp_allocator))
+	t.offset = 0
+	write_table(state, &t)
+}
+
+// table.row_delete: removes the cursor's row, but not the header or the
+// delimiter row.
+table_delete_row :: proc(state: ^Editor_State) {
+	t, ok := table_at_cursor(state)
+	if !ok {
+		set_message(state, "No table at the cursor")
+		return
+	}
+	if len(t.table.rows) == 1 || t.table.delimiter >= 0 && t.row <= t.table.delimiter {
+		set_message(state, "The header and delimiter rows stay; delete the lines instead")
+		return
+	}
+	ordered_remove(&t.table.rows, t.row)
+	if t.row >= len(t.table.rows) {
+		t.row -= 1
+	}
+	write_table(state, &t)
+}
+
+// table.column_add: an empty column after the cursor's.
+table_add_column :: proc(state: ^Editor_State) {
+	t, ok := table_at_cursor(state)
+	if !ok {
+		set_message(state, "No table at the cursor")
+		return
+	}
+	t.cell += 1
+	for &row, r in t.table.rows {
+		for len(row) < t.cell {
+			append(&row, "---" if r == t.table.delimiter else "")
+		}
+		inject_at(&row, t.cell, "---" if r == t.table.delimiter else "")
+	}
+	t.offset = 0
+	write_table(state, &t)
+}
+
+// table.column_delete: removes the cursor's column, unless it is the only one.
+table_delete_column :: proc(state: ^Editor_State) {
+	t, ok := table_at_cursor(state)
+	if !ok {
+		set_message(state, "No table at the cursor")
+		return
+	}
+	if editor.table_columns(&t.table) <= 1 {
+		set_message(state, "The table has only this column")
+		return
+	}
+	for &row in t.table.rows {
+		if t.cell < len(row) {
+			ordered_remove(&row, t.cell)
+		}
+	}
+	t.cell = min(t.cell, editor.table_columns(&t.table) - 1)
+	t.offset = 0
+	write_table(state, &t)
+}
+
+// table.from_csv: turns the selected lines of comma, semicolon or tab
+// separated values into a table whose header is the first of them.
+table_from_csv :: proc(state: ^Editor_State) {
+	if !has_selection(state) {
+		set_message(state, "Select the values to make a table of")
+		return
+	}
+	gb := &state.buffer
+	start, end := selection_range(state)
+	first, _ := editor.logical_pos_to_line_col(gb, start)
+	last, last_col := editor.logical_pos_to_line_col(gb, end)
+	if last > first && last_col == 0 {
+		last -= 1
+	}
+	start = editor.line_col_to_logical_pos(gb, first, 0)
+	end = editor.line_col_to_logical_pos(gb, last, editor.get_line_length(gb, last))
+	text := editor.get_text_segment(gb, start, end - start, context.temp_allocator)
+	table := editor.csv_to_table(text)
+	lines := editor.format_table(&table, state.layer_ctx.tab_size)
+	joined := strings.join(lines, "\n", context.temp_allocator)
+	editor.replace_bytes(gb, start, end - start, transmute([]u8)joined)
+	state.selection_anchor = -1
+	state.cursor_pos = start + editor.table_cell_pos(lines[0], 0, 0)
+	sync_cursor(state)
+	set_preferred_col(state)
+	set_message(state, "Table of %d rows under a header", max(len(lines) - 2, 0))
+}
+
+// The table around the cursor line.
+@(private = "file")
+table_at_cursor :: proc(state: ^Editor_State) -> (t: Table_At_Cursor, ok: bool) {
+	is_row :: proc(gb: ^editor.Gap_Buffer, line: int) -> bool {
+		return editor.is_table_line(editor.get_line(gb, line, context.temp_allocator))
+	}
+	gb := &state.buffer
+	line, col := editor.logical_pos_to_line_col(gb, state.cursor_pos)
+	if !is_row(gb, line) {
+		return {}, false
+	}
+	t.first, t.last = line, line
+	for t.first > 0 && is_row(gb, t.first - 1) {
+		t.first -= 1
+	}
+	count := editor.get_line_count(gb)
+	for t.last + 1 < count && is_row(gb, t.last + 1) {
+		t.last += 1
+	}
+	lines := make([]string, t.last - t.first + 1, context.temp_allocator)
+	for &l, i in lines {
+		l = editor.get_line(gb, t.first + i, context.temp_allocator)
+	}
+	t.table = editor.parse_table(lines)
+	t.row = line - t.first
+	t.cell, t.offset = editor.table_cell_at(lines[t.row], col)
+	return t, true
+}
+
+// Writes the table laid out again over its lines and puts the cursor in its
+// cell.
+@(private = "file")
+write_table :: proc(state: ^Editor_State, t: ^Table_At_Cursor) {
+	gb := &state.buffer
+	lines := editor.format_table(&t.table, state.layer_ctx.tab_size)
+	start := editor.line_col_to_logical_pos(gb, t.first, 0)
+	end := editor.line_col_to_logical_pos(gb, t.last, editor.get_line_length(gb, t.last))
+	text := strings.join(lines, "\n", context.temp_allocator)
+	if text != editor.get_text_segment(gb, start, end - start, context.temp_allocator) {
+		editor.replace_bytes(gb, start, end - start, transmute([]u8)text)
+	}
+	pos := start
+	for l in lines[:t.row] {
+		pos += len(l) + 1
+	}
+	state.selection_anchor = -1
+	state.cursor_pos = pos + editor.table_cell_pos(lines[t.row], t.cell, t.offset)
+	sync_cursor(state)
+	set_preferred_col(state)
+}
@@ -0,0 +1,324 @@
+package main
+
+import "core:fmt"
+import "core:os"
+import "core:path/filepath"
+import "core:strings"
+import "core:time"
+import editor "editor"
+
+// symbol.search lists at most this many tags; typing narrows them.
+TAGS_LISTED_MAX :: 500
+
+// Navigation from a ctags file, for languages no server understands:
+// symbol.definition and symbol.search read the workspace's tags file
+// (tags.file), loading it again when it changes.  tags.generate writes it
+// with ctags, and like gutentags an existing one is written again after
+// each save, by tags.auto.
+Tags_State :: struct {
+	file:     ^editor.Tags_File, // nil until a command needs it
+	path:     string, // absolute path file was read from
+	modified: time.Time, // and its modification time then
+	active:   bool, // the panel lists tags
+	found:    [dynamic]editor.Tag, // listed; items' data index them
+	run:      ^editor.Task_Run, // ctags, writing to the path plus ".new"
+	target:   string, // the tags file it replaces
+	again:    bool, // a save came while it ran
+	quiet:    bool, // started by a save, so only failures are reported
+	output:   strings.Builder,
+}
+
+destroy_tags :: proc(state: ^Editor_State) {
+	t := &state.tags
+	if t.run != nil {
+		editor.destroy_task(t.run)
+	}
+	if t.file != nil {
+		editor.destroy_tags(t.file)
+	}
+	delete(t.path)
+	delete(t.target)
+	delete(t.found)
+	strings.builder_destroy(&t.output)
+}
+
+// tags.generate: runs tags.command over the workspace to write its tags
+// file.
+generate_tags :: proc(state: ^Editor_State) {
+	start_tags_run(state, false)
+}
+
+// symbol.definition: goes to the definition of the word at the cursor, by
+// the LSIF dumps, the tags file or else the buffer's own index; several
+// tags are listed.
+goto_definition :: proc(state: ^Editor_State) {
+	name := word_at_cursor(state)
+	if name == "" {
+		set_message(state, "No name at the cursor")
+		return
+	}
+	if goto_lsif_definition(state) {
+		return
+	}
+	if file := load_tags(state); file != nil {
+		found := editor.find_tags(file, name)
+		if len(found) == 1 {
+			jump_to_tag(state, found[0])
+			return
+		}
+		if len(found) > 1 {
+			list_tags(state, fmt.tprintf("Definitions of %s (%d)", name, len(found)), found)
+			return
+		}
+	}
+	size := editor.current_length(&state.buffer)
+	if index := usable_index(state, state.doc_id, &state.undo, size); index != nil {
+		for s in editor.index_symbols_with_prefix(index, name) {
+			if s.name == name {
+				record_jump(state)
+				goto_line_col(state, s.line, s.col)
+				return
+			}
+		}
+	}
+	if state.tags.file == nil {
+		set_message(state, "No definition of %s here; tags.generate indexes the rest", name)
+	} else {
+		set_message(state, "No definition of %s", name)
+	}
+}
+
+// The workspace's tags named name, for other features to look names up in.
+// Temp allocated; none when there is no tags file.
+tag_definitions :: proc(state: ^Editor_State, name: string) -> []editor.Tag {
+	file := load_tags(state, quiet = true)
+	if file == nil {
+		return nil
+	}
+	return editor.find_tags(file, name)
+}
+
+// The absolute path of a tag's file.  Temp allocated.
+tag_path :: proc(state: ^Editor_State, tag: editor.Tag) -> string {
+	if filepath.is_abs(tag.path) {
+		return tag.path
+	}
+	dir := filepath.dir(state.tags.path, context.temp_allocator)
+	return filepath.join({dir, tag.path}, context.temp_allocator)
+}
+
+// symbol.search: lists the workspace's tags whose names start with what is
+// typed; enter jumps to the one picked.
+search_tags :: proc(state: ^Editor_State) {
+	if load_tags(state) == nil {
+		return
+	}
+	open_prompt(
+		state,
+		"Search symbols:",
+		on_submit = proc(state: ^Editor_State, text: string) {
+			if state.tags.active {
+				open_tag_entry(state)
+			}
+		},
+		on_change = proc(state: ^Editor_State, text: string) {
+			if state.tags.file != nil {
+				found := editor.tags_with_prefix(state.tags.file, text)
+				list_tags(state, fmt.tprintf("Symbols (%d)", len(found)), found)
+			}
+		},
+	)
+	found := editor.tags_with_prefix(state.tags.file, "")
+	list_tags(state, fmt.tprintf("Symbols (%d)", len(found)), found)
+}
+
+// Enter in the tags list.
+open_tag_entry :: proc(state: ^Editor_State) {
+	item := editor.panel_selected_item(state.panel_data)
+	if item == nil || item.data >= len(state.tags.found) {
+		return
+	}
+	if jump_to_tag(state, state.tags.found[item.data]) {
+		state.mode = "editor"
+	}
+}
+
+// After a save: writes an existing tags file again, by tags.auto.  A save
+// while ctags runs has it run once more after.
+update_tags_after_save :: proc(state: ^Editor_State) {
+	if !config_bool(state, "tags.auto") || !os.exists(tags_file_path(state)) {
+		return
+	}
+	if state.tags.run != nil {
+		state.tags.again = true
+		return
+	}
+	start_tags_run(state, true)
+}
+
+// Puts the tags file ctags wrote in place once it exits.  Called every
+// frame.
+update_tags :: proc(state: ^Editor_State) {
+	t := &state.tags
+	if t.run == nil {
+		return
+	}
+	finished := editor.is_task_finished(t.run)
+	editor.take_task_output(t.run, &t.output)
+	if !finished {
+		return
+	}
+	code := t.run.exit_code
+	editor.destroy_task(t.run)
+	t.run = nil
+	written := strings.concatenate({t.target, ".new"}, context.temp_allocator)
+	if code != 0 {
+		os.remove(written)
+		first, _, _ := strings.partition(strings.trim_space(strings.to_string(t.output)), "\n")
+		set_message(state, "ctags failed with exit code %d: %s", code, first)
+		t.again = false
+		return
+	}
+	if err := os.rename(written, t.target); err != nil {
+		set_message(state, "Cannot replace %s: %v", t.target, err)
+		t.again = false
+		return
+	}
+	if !t.quiet {
+		set_message(state, "Wrote %s", t.target)
+	}
+	if t.again {
+		t.again = false
+		start_tags_run(state, true)
+	}
+}
+
+@(private = "file")
+start_tags_run :: proc(state: ^Editor_State, quiet: bool) {
+	t := &state.tags
+	if t.run != nil {
+		set_message(state, "ctags is running already")
+		return
+	}
+	command := config_value(state, "tags.command").(string) or_else ""
+	if strings.trim_space(command) == "" {
+		set_message(state, "Set tags.command to run ctags")
+		return
+	}
+	target := tags_file_path(state)
+	args := make([dynamic]string, context.temp_allocator)
+	append(&args, ..strings.fields(command, context.temp_allocator))
+	append(&args, "-f", strings.concatenate({target, ".new"}, context.temp_allocator), ".")
+	run, err := editor.start_task(args[:], state.workspace_root)
+	if err != nil {
+		set_message(state, "Cannot run %s: %v", args[0], err)
+		return
+	}
+	t.run = run
+	t.quiet = quiet
+	delete(t.target)
+	t.target = strings.clone(target)
+	strings.builder_reset(&t.output)
+	if !quiet {
+		set_message(state, "Running %s ...", args[0])
+	}
+}
+
+// The workspace's tags, read again when the file changed.  Nil, with a
+// message unless quiet, when there are none.
+@(private = "file")
+load_tags :: proc(state: ^Editor_State, quiet := false) -> ^editor.Tags_File {
+	t := &state.tags
+	path := tags_file_path(state)
+	fi, err := os.stat(path, context.temp_allocator)
+	if err != nil {
+		if !quiet {
+			set_message(state, "No tags file at %s; tags.generate writes one", path)
+		}
+		return nil
+	}
+	if t.file != nil && t.path == path && t.modified == fi.modification_time {
+		return t.file
+	}
+	data, rerr := os.read_entire_file_from_path(path, context.allocator)
+	if rerr != nil {
+		set_message(state, "Cannot read %s: %v", path, rerr)
+		return nil
+	}
+	if t.file != nil {
+		editor.destroy_tags(t.file)
+	}
+	// The panel's tags point into the old file.
+	if t.active {
+		release_panel(state)
+		hide_panel(state)
+	}
+	t.file = editor.parse_tags(string(data))
+	delete(t.path)
+	t.path = strings.clone(path)
+	t.modified = fi.modification_time
+	return t.file
+}
+
+@(private = "file")
+list_tags :: proc(state: ^Editor_State, title: string, tags: []editor.Tag) {
+	clear_replace(state)
+	stop_project_search(state)
+	release_panel(state)
+	t := &state.tags
+	t.active = true
+	clear(&t.found)
+	panel := state.panel_data
+	editor.panel_clear(panel)
+	editor.panel_set_title(panel, title)
+	for tag in tags[:min(len(tags), TAGS_LISTED_MAX)] {
+		place := tag.path if tag.line < 0 else fmt.tprintf("%s:%d", tag.path, tag.line + 1)
+		text := fmt.tprintf("%-32s %-10s %s", tag.name, tag.kind, place)
+		editor.panel_add_item(panel, {text = text, line = -1, data = len(t.found)})
+		append(&t.found, tag)
+	}
+	show_panel(state)
+}
+
+// Opens a tag's file at its line, or where its pattern is found.
+@(private = "file")
+jump_to_tag :: proc(state: ^Editor_State, tag: editor.Tag) -> bool {
+	if !jump_to_location(state, tag_path(state, tag), tag.line, 0) {
+		return false
+	}
+	if tag.line < 0 && tag.pattern != "" {
+		text := editor.get_text(&state.buffer, context.temp_allocator)
+		line := editor.find_tag_pattern(text, tag.pattern)
+		if line < 0 {
+			set_message(state, "%s is no longer where the tags file says; tags.generate", tag.name)
+			return true
+		}
+		goto_line_col(state, line, 0)
+	}
+	line := editor.get_line(&state.buffer, state.cursor_data.line, context.temp_allocator)
+	if col := strings.index(line, tag.name); col >= 0 {
+		goto_line_col(state, state.cursor_data.line, col)
+	}
+	return true
+}
+
+// The name under or just before the cursor.  Temp allocated.
+word_at_cursor :: proc(state: ^Editor_State) -> string {
+	line := editor.get_line(&state.buffer, state.cursor_data.line, context.temp_allocator)
+	col := min(state.cursor_data.col, len(line))
+	start, end := col, col
+	for start > 0 && is_identifier_byte(line[start - 1]) {
+		start -= 1
+	}
+	for end < len(line) && is_identifier_byte(line[end]) {
+		end += 1
+	}
+	return line[start:end]
+}
+
+// Absolute.  Temp allocated.
+@(private = "file")
+tags_file_path :: proc(state: ^Editor_State) -> string {
+	name := config_value(state, "tags.file").(string) or_else ""
+	return workspace_path(state, name if name != "" else "tags")
+}
@@ -0,0 +1,273 @@
+package main
+
+import "core:fmt"
+import "core:path/filepath"
+import "core:strings"
+import "core:time"
+import editor "editor"
+
+// The source of the problems found in task output, see Diagnostic.
+TASK_DIAGNOSTIC_SOURCE :: "task"
+
+// Used when a task sets no errorformat: GCC and Clang style, then the
+// `file(line:col) message` of the Odin and MSVC compilers.
+DEFAULT_ERROR_FORMATS := [?]string {
+	"%f:%l:%c: %m",
+	"%f:%l: %m",
+	"%f(%l:%c) %m",
+	"%f(%l): %m",
+}
+
+// A command a project defines as a [[tasks]] table of its workspace config
+// file:
+//
+//	[[tasks]]
+//	name = "build"
+//	command = "odin build ."
+//	errorformat = ["%f(%l:%c) %m"]
+Task_Config :: struct {
+	name:        string,
+	command:     string, // run by the shell
+	cwd:         string, // relative to the workspace root, empty for the root
+	errorformat: []string, // see editor.match_error_formats
+}
+
+// Lets another feature follow a run it started, e.g. the test explorer.
+Task_Line_Proc :: proc(state: ^Editor_State, line: string)
+Task_Finish_Proc :: proc(state: ^Editor_State, exit_code: int) // -1 when replaced
+
+Task_State :: struct {
+	active:    bool, // the panel shows the output
+	run:       ^editor.Task_Run,
+	name:      string,
+	dir:       string, // where paths in the output are relative to
+	formats:   []string, // borrowed from the task's config or the defaults
+	pending:   strings.Builder, // output after the last complete line
+	started:   time.Tick,
+	on_line:   Task_Line_Proc,
+	on_finish: Task_Finish_Proc,
+}
+
+destroy_tasks :: proc(state: ^Editor_State) {
+	t := &state.tasks
+	if t.run != nil {
+		editor.destroy_task(t.run)
+	}
+	delete(t.name)
+	delete(t.dir)
+	strings.builder_destroy(&t.pending)
+}
+
+destroy_task_config :: proc(task: Task_Config) {
+	delete(task.name)
+	delete(task.command)
+	delete(task.cwd)
+	for f in task.errorformat {
+		delete(f)
+	}
+	delete(task.errorformat)
+}
+
+// Prompts for the name of a project task and runs it.
+run_task_prompt :: proc(state: ^Editor_State) {
+	tasks := state.project.tasks
+	if len(tasks) == 0 {
+		set_message(state, "No tasks in %s", WORKSPACE_CONFIG_FILE)
+		return
+	}
+	names := make([]string, len(tasks), context.temp_allocator)
+	for task, i in tasks {
+		names[i] = task.name
+	}
+	open_prompt(state, "Task:", proc(state: ^Editor_State, name: string) {
+		run_task(state, strings.trim_space(name))
+	})
+	set_message(state, "Tasks: %s", strings.join(names, ", ", context.temp_allocator))
+}
+
+run_build_task :: proc(state: ^Editor_State) {
+	run_task(state, "build")
+}
+
+run_test_task :: proc(state: ^Editor_State) {
+	run_task(state, "test")
+}
+
+run_lint_task :: proc(state: ^Editor_State) {
+	run_task(state, "lint")
+}
+
+// Runs the project task called name.
+run_task :: proc(state: ^Editor_State, name: string) {
+	if len(state.project.tasks) > 0 && !workspace_trusted(state) {
+		set_message(state, "Tasks run once the workspace is trusted, see workspace.trust")
+		return
+	}
+	for &task in state.project.tasks {
+		if task.name == name {
+			dir := workspace_path(state, task.cwd) if task.cwd != "" else state.workspace_root
+			start_task_run(state, name, task.command, dir, task.errorformat)
+			return
+		}
+	}
+	set_message(state, "No task %q in %s", name, WORKSPACE_CONFIG_FILE)
+}
+
+// command with every placeholder, such as {file}, replaced by value as one
+// shell word, so that a file or test name cannot end the argument or start
+// another command.  Temp allocated.
+fill_placeholder :: proc(command, placeholder, value: string) -> string {
+	quoted := shell_quote(value)
+	filled, _ := strings.replace_all(command, placeholder, quoted, context.temp_allocator)
+	return filled
+}
+
+// Runs command through the shell in dir, replacing a run that is still
+// going, with its output in the panel.  No formats means the defaults.
+start_task_run :: proc(
+	state: ^Editor_State,
+	name, command, dir: string,
+	formats: []string = nil,
+) -> bool {
+	t := &state.tasks
+	if t.run != nil {
+		editor.destroy_task(t.run)
+		t.run = nil
+		if t.on_finish != nil {
+			t.on_finish(state, -1)
+		}
+	}
+	t.on_line = nil
+	t.on_finish = nil
+	run, err := editor.start_task(shell_command(command), dir)
+	if err != nil {
+		set_message(state, "Cannot run %q: %v", command, err)
+		return false
+	}
+	t.run = run
+	delete(t.name)
+	t.name = strings.clone(name)
+	delete(t.dir)
+	t.dir = strings.clone(dir)
+	t.formats = formats if len(formats) > 0 else DEFAULT_ERROR_FORMATS[:]
+	t.started = time.tick_now()
+	strings.builder_reset(&t.pending)
+	publish_diagnostics(state, TASK_DIAGNOSTIC_SOURCE, "", nil)
+
+	stop_project_search(state)
+	release_panel(state)
+	clear_replace(state)
+	t.active = true
+	editor.panel_clear(state.panel_data)
+	editor.panel_set_title(state.panel_data, fmt.tprintf("Task %s: running %s", name, command))
+	state.panel_data.visible = true
+	return true
+}
+
+cancel_running_task :: proc(state: ^Editor_State) {
+	if state.tasks.run == nil {
+		set_message(state, "No task is running")
+		return
+	}
+	editor.cancel_task(state.tasks.run)
+}
+
+// Moves new task output into the panel.  Called every frame.
+update_tasks :: proc(state: ^Editor_State) {
+	t := &state.tasks
+	if t.run == nil {
+		return
+	}
+	finished := editor.is_task_finished(t.run)
+	editor.take_task_output(t.run, &t.pending)
+
+	text := strings.to_string(t.pending)
+	end := strings.last_index_byte(text, '\n') + 1
+	if finished {
+		end = len(text)
+	}
+	lines := text[:end]
+	added_errors := false
+	for line in strings.split_lines_iterator(&lines) {
+		line := strings.trim_right(line, "\r")
+		added_errors |= add_task_output_line(state, line)
+		if t.on_line != nil {
+			t.on_line(state, line)
+		}
+	}
+	rest := strings.clone(text[end:], context.temp_allocator)
+	strings.builder_reset(&t.pending)
+	strings.write_string(&t.pending, rest)
+	if added_errors {
+		refresh_diagnostics(state)
+	}
+
+	if !finished {
+		return
+	}
+	code := t.run.exit_code
+	editor.destroy_task(t.run)
+	t.run = nil
+	elapsed := time.duration_seconds(time.tick_since(t.started))
+	summary := fmt.tprintf(
+		"Task %s: exit %d, %d problems, %.1fs",
+		t.name,
+		code,
+		count_diagnostics(state, TASK_DIAGNOSTIC_SOURCE),
+		elapsed,
+	)
+	if t.active {
+		editor.panel_set_title(state.panel_data, summary)
+	}
+	set_message(state, "%s", summary)
+	if count_diagnostics(state, TASK_DIAGNOSTIC_SOURCE) > 0 {
+		// quickfix.next walks the compiler's errors from here.
+		quickfix_diagnostics(state, make_current = true)
+	}
+	if t.on_finish != nil {
+		on_finish := t.on_finish
+		t.on_line = nil
+		t.on_finish = nil
+		on_finish(state, code)
+	}
+}
+
+// Adds one line of output to the panel, as a location when an error format
+// matches it.  Returns true for a location.
+@(private = "file")
+add_task_output_line :: proc(state: ^Editor_State, line: string) -> bool {
+	t := &state.tasks
+	m, ok := editor.match_error_formats(t.formats, line)
+	if !ok {
+		if t.active {
+			editor.panel_add_item(state.panel_data, {text = line, style = .Dim})
+		}
+		return false
+	}
+	path := m.file
+	if !filepath.is_abs(path) {
+		path = filepath.join({t.dir, path}, context.temp_allocator)
+	}
+	path, _ = filepath.abs(path, context.temp_allocator)
+	warning := m.kind == 'w' || strings.has_prefix(m.message, "warning")
+	d := Diagnostic {
+		path     = path,
+		line     = max(m.line - 1, 0),
+		col      = max(m.col - 1, 0),
+		severity = .Warning if warning else .Error,
+		message  = m.message,
+		source   = TASK_DIAGNOSTIC_SOURCE,
+	}
+	add_diagnostic(state, d)
+	if !t.active {
+		return true
+	}
+	item := editor.Panel_Item {
+		text = line,
+		path = path,
+		line = d.line,
+		col  = d.col,
+	}
+	editor.panel_add_item(state.panel_data, item)
+	return true
+}
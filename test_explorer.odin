@@ -0,0 +1,387 @@
+package main
+
+import "core:fmt"
+import "core:path/filepath"
+import "core:slice"
+import "core:strings"
+import editor "editor"
+
+TEST_PASSED_COLOR :: [4]f32{0.40, 0.75, 0.40, 1.0}
+TEST_FAILED_COLOR :: [4]f32{0.90, 0.35, 0.35, 1.0}
+TEST_RUNNING_COLOR :: [4]f32{0.55, 0.55, 0.60, 1.0}
+
+// Commands that run tests, by language id.  {file} is the file's path and
+// {dir} its directory, both relative to the workspace root; {package} is the
+// directory's name and {name} the test's, each filled in as one shell word.
+// Projects can replace them under [test_runners.<id>] in their workspace
+// config file, once it is trusted.
+Test_Runner :: struct {
+	file: string, // runs every test of a file
+	test: string, // runs the test called {name}
+}
+
+Test_Status :: enum u8 {
+	Unknown,
+	Running,
+	Passed,
+	Failed,
+}
+
+Test_Entry :: struct {
+	path:   string, // relative to the workspace root
+	name:   string,
+	line:   int,
+	status: Test_Status,
+}
+
+Test_Explorer_State :: struct {
+	active:        bool, // the panel lists the tests
+	search:        ^editor.Project_Search, // discovery in progress
+	tests:         [dynamic]Test_Entry, // sorted by path, then line
+	last_path:     string, // what test.rerun runs again
+	last_name:     string, // empty for the whole file
+	failure_found: bool, // the running tests' output named a failing test
+}
+
+destroy_test_explorer :: proc(state: ^Editor_State) {
+	x := &state.test_explorer
+	if x.search != nil {
+		editor.destroy_project_search(x.search)
+	}
+	for e in x.tests {
+		delete(e.path)
+		delete(e.name)
+	}
+	delete(x.tests)
+	delete(x.last_path)
+	delete(x.last_name)
+}
+
+destroy_test_runner :: proc(runner: Test_Runner) {
+	delete(runner.file)
+	delete(runner.test)
+}
+
+// Looks for tests in the whole workspace and lists them in the panel by
+// file.
+show_test_explorer :: proc(state: ^Editor_State) {
+	x := &state.test_explorer
+	if x.search != nil {
+		editor.destroy_project_search(x.search)
+	}
+	search, err := editor.start_project_search(
+		{pattern = editor.TEST_SEARCH_PATTERN, root = state.workspace_root, context_lines = 1},
+	)
+	if err != nil {
+		set_message(state, "Cannot search for tests: %v", err)
+		return
+	}
+	x.search = search
+
+	clear_replace(state)
+	stop_project_search(state)
+	release_panel(state)
+	x.active = true
+	editor.panel_clear(state.panel_data)
+	editor.panel_set_title(state.panel_data, "Tests: searching ...")
+	show_panel(state)
+}
+
+// Runs the test the cursor is in, i.e. the last one declared above it.
+run_nearest_test :: proc(state: ^Editor_State) {
+	tests := buffer_tests(state)
+	if len(tests) == 0 {
+		return
+	}
+	nearest := tests[0]
+	for t in tests {
+		if t.line <= state.cursor_data.line {
+			nearest = t
+		}
+	}
+	run_tests(state, bookmark_path(state), nearest.name)
+}
+
+run_file_tests :: proc(state: ^Editor_State) {
+	if len(buffer_tests(state)) > 0 {
+		run_tests(state, bookmark_path(state), "")
+	}
+}
+
+rerun_tests :: proc(state: ^Editor_State) {
+	x := &state.test_explorer
+	if x.last_path == "" {
+		set_message(state, "No tests have run yet")
+		return
+	}
+	run_tests(state, x.last_path, x.last_name)
+}
+
+// Runs the selected test of the explorer, or every test of the selected
+// file.
+run_selected_test :: proc(state: ^Editor_State) {
+	x := &state.test_explorer
+	item := editor.panel_selected_item(state.panel_data)
+	if !x.active || item == nil || item.data >= len(x.tests) {
+		return
+	}
+	e := x.tests[item.data]
+	run_tests(state, e.path, "" if item.style == .Header else e.name)
+}
+
+// Collects discovered tests and the end of discovery.  Called every frame.
+update_test_explorer :: proc(state: ^Editor_State) {
+	x := &state.test_explorer
+	if x.search == nil {
+		return
+	}
+	finished := editor.is_search_finished(x.search)
+	results := make([dynamic]editor.Search_File_Result, context.temp_allocator)
+	editor.take_search_results(x.search, &results)
+	for r in results {
+		lang := editor.detect_language_by_path(r.path)
+		found := make([dynamic]editor.Test_Case, context.temp_allocator)
+		for m in r.matches {
+			next := m.after[0] if len(m.after) > 0 else ""
+			if name, ok := editor.test_name_at(lang.id, m.text, next); ok {
+				append(&found, editor.Test_Case{name, m.line})
+			}
+		}
+		set_file_tests(state, r.path, found[:])
+		editor.destroy_search_file_result(r, x.search.allocator)
+	}
+	if finished {
+		editor.destroy_project_search(x.search)
+		x.search = nil
+		list_tests(state)
+	}
+}
+
+// Puts the status of the active document's tests into the gutter.
+refresh_test_marks :: proc(state: ^Editor_State) {
+	path := bookmark_path(state)
+	marks := make([dynamic]editor.Gutter_Mark, context.temp_allocator)
+	for e in state.test_explorer.tests {
+		if path == "" || e.path != path {
+			continue
+		}
+		color: [4]f32
+		switch e.status {
+		case .Unknown:
+			continue
+		case .Running:
+			color = TEST_RUNNING_COLOR
+		case .Passed:
+			color = TEST_PASSED_COLOR
+		case .Failed:
+			color = TEST_FAILED_COLOR
+		}
+		append(&marks, editor.Gutter_Mark{line = e.line, color = color})
+	}
+	editor.set_gutter_marks(state.gutter_data, .Test, marks[:])
+}
+
+// Finds the tests of the active buffer and records them, reporting when
+// there are none.  Paths are those of bookmarks, relative to the workspace
+// root.  Temp allocated.
+@(private = "file")
+buffer_tests :: proc(state: ^Editor_State) -> []editor.Test_Case {
+	if state.path == "" {
+		set_message(state, "Save the buffer before running its tests")
+		return nil
+	}
+	text := editor.get_text(&state.buffer, context.temp_allocator)
+	tests := editor.find_tests(state.language, text, context.temp_allocator)
+	if len(tests) == 0 {
+		set_message(state, "No tests in %s", document_title(state.path))
+		return nil
+	}
+	set_file_tests(state, bookmark_path(state), tests)
+	return tests
+}
+
+// Runs one test of path, or all of them when name is empty, with the
+// output in the panel.
+@(private = "file")
+run_tests :: proc(state: ^Editor_State, path, name: string) {
+	x := &state.test_explorer
+	lang := editor.detect_language_by_path(path)
+	runner, ok := test_runner(state, lang.id)
+	if !ok {
+		set_message(state, "No test runner for %s files", lang.name)
+		return
+	}
+	dir := filepath.dir(path, context.temp_allocator)
+	template := runner.file if name == "" else runner.test
+	full_dir := filepath.join({state.workspace_root, dir}, context.temp_allocator)
+	command := fill_placeholder(template, "{file}", path)
+	command = fill_placeholder(command, "{dir}", dir)
+	command = fill_placeholder(command, "{package}", filepath.base(full_dir))
+	command = fill_placeholder(command, "{name}", name)
+
+	label := fmt.tprintf("test %s", name if name != "" else path)
+	if !start_task_run(state, label, command, state.workspace_root) {
+		return
+	}
+	if path != x.last_path {
+		delete(x.last_path)
+		x.last_path = strings.clone(path)
+	}
+	if name != x.last_name {
+		delete(x.last_name)
+		x.last_name = strings.clone(name)
+	}
+	x.failure_found = false
+	for &e in x.tests {
+		if e.path == path && (name == "" || e.name == name) {
+			e.status = .Running
+		}
+	}
+	state.tasks.on_line = test_output_line
+	state.tasks.on_finish = tests_finished
+	refresh_test_marks(state)
+}
+
+// A running test fails when the output names it on a line that talks about
+// failure, which covers go test, pytest, cargo test and odin test.
+@(private = "file")
+test_output_line :: proc(state: ^Editor_State, line: string) {
+	lower := strings.to_lower(line, context.temp_allocator)
+	if !strings.contains(lower, "fail") && !strings.contains(lower, "error") {
+		return
+	}
+	x := &state.test_explorer
+	for &e in x.tests {
+		if e.status == .Running && editor.mentions_identifier(line, e.name) {
+			e.status = .Failed
+			x.failure_found = true
+		}
+	}
+}
+
+// The tests the output did not blame passed when the run did.  A failed
+// run that names no test, e.g. a build error, fails them all.
+@(private = "file")
+tests_finished :: proc(state: ^Editor_State, exit_code: int) {
+	x := &state.test_explorer
+	for &e in x.tests {
+		if e.status != .Running {
+			continue
+		}
+		switch {
+		case exit_code < 0:
+			e.status = .Unknown
+		case exit_code == 0 || x.failure_found:
+			e.status = .Passed
+		case:
+			e.status = .Failed
+		}
+	}
+	refresh_test_marks(state)
+	if x.active {
+		list_tests(state)
+	}
+}
+
+// Replaces the tests recorded for path, keeping the status of those that
+// are still there.
+@(private = "file")
+set_file_tests :: proc(state: ^Editor_State, path: string, found: []editor.Test_Case) {
+	x := &state.test_explorer
+	old := make(map[string]Test_Status, context.temp_allocator)
+	kept := 0
+	for e in x.tests {
+		if e.path == path {
+			old[strings.clone(e.name, context.temp_allocator)] = e.status
+			delete(e.path)
+			delete(e.name)
+		} else {
+			x.tests[kept] = e
+			kept += 1
+		}
+	}
+	resize(&x.tests, kept)
+	for t in found {
+		e := Test_Entry {
+			path   = strings.clone(path),
+			name   = strings.clone(t.name),
+			line   = t.line,
+			status = old[t.name],
+		}
+		append(&x.tests, e)
+	}
+	slice.sort_by(x.tests[:], proc(a, b: Test_Entry) -> bool {
+		if a.path != b.path {
+			return a.path < b.path
+		}
+		return a.line < b.line
+	})
+	refresh_test_marks(state)
+}
+
+// Lists the tests under a header per file.  Enter jumps to one, r runs it
+// or, on a header, the file.
+@(private = "file")
+list_tests :: proc(state: ^Editor_State) {
+	x := &state.test_explorer
+	panel := state.panel_data
+	selected := panel.selected
+	editor.panel_clear(panel)
+	passed, failed := 0, 0
+	for e, i in x.tests {
+		if i == 0 || x.tests[i - 1].path != e.path {
+			header := editor.Panel_Item {
+				text  = e.path,
+				path  = e.path,
+				line  = -1,
+				style = .Header,
+				data  = i,
+			}
+			editor.panel_add_item(panel, header)
+		}
+		mark := "     "
+		style := editor.Panel_Item_Style.Normal
+		switch e.status {
+		case .Unknown:
+		case .Running:
+			mark, style = " ... ", .Dim
+		case .Passed:
+			mark, style = " ok  ", .Added
+			passed += 1
+		case .Failed:
+			mark, style = " FAIL", .Removed
+			failed += 1
+		}
+		item := editor.Panel_Item {
+			text  = fmt.tprintf("%s %s", mark, e.name),
+			path  = e.path,
+			line  = e.line,
+			style = style,
+			data  = i,
+		}
+		editor.panel_add_item(panel, item)
+	}
+	panel.selected = clamp(selected, 0, max(len(panel.items) - 1, 0))
+	editor.panel_set_title(
+		panel,
+		fmt.tprintf("Tests: %d, %d passed, %d failed; r runs", len(x.tests), passed, failed),
+	)
+}
+
+@(private = "file")
+test_runner :: proc(state: ^Editor_State, language_id: string) -> (Test_Runner, bool) {
+	if runner, ok := state.project.test_runners[language_id]; ok && workspace_trusted(state) {
+		return runner, true
+	}
+	switch language_id {
+	case "odin":
+		return {"odin test {dir}", "odin test {dir} -define:ODIN_TEST_NAMES={package}.{name}"}, true
+	case "go":
+		return {"go test ./{dir}", "go test ./{dir} -run ^{name}$"}, true
+	case "rust":
+		return {"cargo test", "cargo test {name}"}, true
+	case "python":
+		return {"python -m pytest {file}", "python -m pytest {file}::{name}"}, true
+	}
+	return {}, false
+}
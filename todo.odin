@@ -0,0 +1,202 @@
+package main
+
+import "core:fmt"
+import "core:strings"
+import editor "editor"
+
+// Work left to do: todo.toggle_checkbox ticks "- [ ]" list items,
+// todo.cycle steps a heading, list item or comment through todo.states, and
+// todo.list gathers the TODO, FIXME, HACK and XXX comments of the workspace
+// into the panel as a background scan finds them.
+Todo_State :: struct {
+	scan:      ^editor.Todo_Scan,
+	active:    bool, // the panel lists the scan's items
+	last_path: string, // of the item listed last, for the file headers
+	counts:    [len(editor.TODO_TAGS)]int,
+	files:     int,
+}
+
+stop_todo_scan :: proc(state: ^Editor_State) {
+	t := &state.todos
+	if t.scan != nil {
+		editor.destroy_todo_scan(t.scan)
+		t.scan = nil
+	}
+	delete(t.last_path)
+	t.last_path = ""
+}
+
+// todo.list: scans the workspace for tags in comments.
+list_project_todos :: proc(state: ^Editor_State) {
+	clear_replace(state)
+	stop_project_search(state)
+	release_panel(state)
+	stop_todo_scan(state)
+	t := &state.todos
+	t.active = true
+	t.counts = {}
+	t.files = 0
+	t.scan = editor.start_todo_scan(state.workspace_root, state.projects.folders[:])
+	editor.panel_clear(state.panel_data)
+	editor.panel_set_title(state.panel_data, "Scanning comments for TODO ...")
+	show_panel(state)
+}
+
+// Moves what the scan found since the last frame into the panel, under a
+// header per file.
+poll_todo_scan :: proc(state: ^Editor_State) {
+	t := &state.todos
+	if t.scan == nil {
+		return
+	}
+	if !t.active {
+		stop_todo_scan(state)
+		return
+	}
+	items := make([dynamic]editor.Todo_Item, context.temp_allocator)
+	editor.take_todo_items(t.scan, &items)
+	panel := state.panel_data
+	for item in items {
+		if item.path != t.last_path {
+			delete(t.last_path)
+			t.last_path = strings.clone(item.path)
+			t.files += 1
+			editor.panel_add_item(panel, {text = display_path(state, item.path), style = .Header})
+		}
+		for tag, i in editor.TODO_TAGS {
+			if tag == item.tag {
+				t.counts[i] += 1
+			}
+		}
+		editor.panel_add_item(
+			panel,
+			{
+				text = fmt.tprintf("%6d: %s", item.line + 1, item.text),
+				path = item.path,
+				line = item.line,
+				col = item.col,
+			},
+		)
+		editor.destroy_todo_item(item, t.scan.allocator)
+	}
+	if !editor.is_todo_scan_finished(t.scan) {
+		return
+	}
+	stop_todo_scan(state)
+	b := strings.builder_make(context.temp_allocator)
+	for tag, i in editor.TODO_TAGS {
+		if t.counts[i] == 0 {
+			continue
+		}
+		if strings.builder_len(b) > 0 {
+			strings.write_string(&b, ", ")
+		}
+		fmt.sbprintf(&b, "%d %s", t.counts[i], tag)
+	}
+	if strings.builder_len(b) == 0 {
+		editor.panel_set_title(panel, "No TODO, FIXME, HACK or XXX comments")
+		return
+	}
+	editor.panel_set_title(panel, fmt.tprintf("%s in %d files", strings.to_string(b), t.files))
+}
+
+// todo.toggle_checkbox: checks or unchecks the "[ ]" of each list item in the
+// selection, and gives the items without one an empty box.
+toggle_checkbox :: proc(state: ^Editor_State) {
+	gb := &state.buffer
+	positions := [2]int{state.cursor_pos, state.selection_anchor}
+	tracked := positions[:2] if state.selection_anchor >= 0 else positions[:1]
+	changed := false
+	for r in selected_line_ranges(state) {
+		for line := r.last; line >= r.first; line -= 1 {
+			text := editor.get_line(gb, line, context.temp_allocator)
+			p := editor.parse_prose_prefix(text)
+			if !p.list {
+				continue
+			}
+			start := editor.line_col_to_logical_pos(gb, line, 0)
+			n := len(p.text)
+			if n >= 4 && p.text[n - 4] == '[' && p.text[n - 2] == ']' {
+				mark := "x" if p.text[n - 3] == ' ' else " "
+				editor.edit_tracking_positions(gb, start + n - 3, 1, mark, tracked)
+			} else {
+				editor.edit_tracking_positions(gb, start + n, 0, "[ ] ", tracked)
+			}
+			changed = true
+		}
+	}
+	if !changed {
+		set_message(state, "No list item to check")
+		return
+	}
+	apply_tracked_edit(state, tracked)
+}
+
+// todo.cycle: moves the first word of each line in the selection to the next
+// of todo.states, "TODO DONE" by default, then back to no state.
+cycle_todo_state :: proc(state: ^Editor_State) {
+	words := config_value(state, "todo.states").(string) or_else ""
+	states := strings.fields(words, context.temp_allocator)
+	if len(states) == 0 {
+		set_message(state, "todo.states is empty")
+		return
+	}
+	gb := &state.buffer
+	lang := editor.find_language(state.language)
+	positions := [2]int{state.cursor_pos, state.selection_anchor}
+	tracked := positions[:2] if state.selection_anchor >= 0 else positions[:1]
+	for r in selected_line_ranges(state) {
+		for line := r.last; line >= r.first; line -= 1 {
+			text := editor.get_line(gb, line, context.temp_allocator)
+			if strings.trim_space(text) == "" {
+				continue
+			}
+			col := state_word_start(text, lang)
+			word := text[col:]
+			if end := strings.index_byte(word, ' '); end >= 0 {
+				word = word[:end]
+			}
+			pos := editor.line_col_to_logical_pos(gb, line, col)
+			k := -1
+			for s, i in states {
+				if s == word {
+					k = i
+				}
+			}
+			switch {
+			case k < 0:
+				first := strings.concatenate({states[0], " "}, context.temp_allocator)
+				editor.edit_tracking_positions(gb, pos, 0, first, tracked)
+			case k + 1 < len(states):
+				editor.edit_tracking_positions(gb, pos, len(word), states[k + 1], tracked)
+			case:
+				blank := 1 if col + len(word) < len(text) else 0
+				editor.edit_tracking_positions(gb, pos, len(word) + blank, "", tracked)
+			}
+		}
+	}
+	apply_tracked_edit(state, tracked)
+}
+
+// Where the state word of a line goes: past a list marker and its box, a
+// heading's marks, or a line comment's token.
+@(private = "file")
+state_word_start :: proc(line: string, lang: ^editor.Language) -> int {
+	i := len(editor.parse_prose_prefix(line).text)
+	if lang != nil && !lang.prose && lang.line_comment != "" {
+		if strings.has_prefix(line[i:], lang.line_comment) {
+			i += len(lang.line_comment)
+		}
+	}
+	marks := i
+	for marks < len(line) && (line[marks] == '#' || line[marks] == '*') {
+		marks += 1
+	}
+	if marks > i && marks < len(line) && line[marks] == ' ' {
+		i = marks
+	}
+	for i < len(line) && line[i] == ' ' {
+		i += 1
+	}
+	return i
+}
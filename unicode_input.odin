@@ -0,0 +1,322 @@
+package main
+
+import "core:fmt"
+import "core:log"
+import "core:os"
+import "core:strconv"
+import "core:strings"
+import "core:unicode/utf8"
+import editor "editor"
+
+// Characters listed by unicode.insert at most.
+UNICODE_MAX_LISTED :: 200
+
+// Where UnicodeData.txt is usually installed, for the character names.
+UNICODE_DATA_PATHS := [?]string {
+	"/usr/share/unicode/UnicodeData.txt",
+	"/usr/share/unicode-data/UnicodeData.txt",
+	"/usr/share/unicode/ucd/UnicodeData.txt",
+	"/usr/local/share/unicode/UnicodeData.txt",
+}
+
+// Typing characters that are not on the keyboard: unicode.insert picks one
+// by name or code point, unicode.digraph takes two keys as in Vim, and
+// unicode.describe tells what the character at the cursor is.  The names come
+// from UnicodeData.txt when there is one, else only the digraphs have names.
+Unicode_State :: struct {
+	names:  [dynamic]Unicode_Name, // in code point order
+	data:   []u8, // the file the names point into
+	loaded: bool,
+	active: bool, // the panel lists characters
+}
+
+Unicode_Name :: struct {
+	r:    rune,
+	name: string,
+}
+
+destroy_unicode :: proc(state: ^Editor_State) {
+	delete(state.unicode.names)
+	delete(state.unicode.data)
+}
+
+// unicode.insert: lists the characters whose names hold every word typed, or
+// the one at a code point typed as U+00E9, 0xe9 or e9, and the one of a
+// digraph; enter inserts the first, or the one picked in the panel.
+show_unicode_picker :: proc(state: ^Editor_State) {
+	load_unicode_names(state)
+	open_prompt(
+		state,
+		"Insert character (name or U+code):",
+		on_submit = proc(state: ^Editor_State, text: string) {
+			item := editor.panel_selected_item(state.panel_data)
+			if !state.unicode.active || item == nil {
+				return
+			}
+			hide_unicode_picker(state)
+			insert_character(state, rune(item.data))
+		},
+		on_change = list_characters,
+		on_cancel = hide_unicode_picker,
+	)
+	list_characters(state, "")
+}
+
+// Enter in the character list.
+insert_character_entry :: proc(state: ^Editor_State) {
+	item := editor.panel_selected_item(state.panel_data)
+	if item == nil {
+		return
+	}
+	hide_unicode_picker(state)
+	insert_character(state, rune(item.data))
+}
+
+// unicode.digraph: inserts the character of the next two keys, "e'" for é.
+insert_digraph :: proc(state: ^Editor_State) {
+	open_prompt(
+		state,
+		"Digraph:",
+		on_submit = proc(state: ^Editor_State, text: string) {
+			if text != "" {
+				set_message(state, "A digraph is two characters, such as e' or a*")
+			}
+		},
+		on_change = proc(state: ^Editor_State, text: string) {
+			if len(text) < 2 {
+				return
+			}
+			close_prompt(state)
+			if r, ok := editor.find_digraph(text); ok {
+				insert_character(state, r)
+			} else {
+				set_message(state, "No digraph %q", text)
+			}
+		},
+	)
+}
+
+// unicode.describe: shows the code point, UTF-8 bytes and name of the
+// character at the cursor, and the code points of its grapheme cluster when
+// it has several.
+describe_character :: proc(state: ^Editor_State) {
+	gb := &state.buffer
+	pos := state.cursor_pos
+	end := editor.next_grapheme_pos(gb, pos)
+	if end <= pos {
+		set_message(state, "End of the buffer")
+		return
+	}
+	load_unicode_names(state)
+	cluster := editor.get_text_segment(gb, pos, end - pos, context.temp_allocator)
+	r, size := utf8.decode_rune_in_string(cluster)
+	b := strings.builder_make(context.temp_allocator)
+	fmt.sbprintf(&b, "%s U+%04X %s, UTF-8", shown_character(r), r, character_name(state, r))
+	for i in 0 ..< size {
+		fmt.sbprintf(&b, " %02x", cluster[i])
+	}
+	if keys := editor.digraph_of(r); keys != "" {
+		fmt.sbprintf(&b, ", digraph %s", keys)
+	}
+	if n := utf8.rune_count_in_string(cluster); n > 1 {
+		fmt.sbprintf(&b, "; a grapheme of %d code points:", n)
+		for c in cluster {
+			fmt.sbprintf(&b, " U+%04X", c)
+		}
+		fmt.sbprintf(&b, ", %d cells wide", editor.grapheme_width(cluster))
+	}
+	set_message(state, "%s", strings.to_string(b))
+}
+
+@(private = "file")
+hide_unicode_picker :: proc(state: ^Editor_State) {
+	if state.unicode.active {
+		state.unicode.active = false
+		hide_panel(state)
+	}
+}
+
+// Inserts r at the cursor as one undo step.
+@(private = "file")
+insert_character :: proc(state: ^Editor_State, r: rune) {
+	if is_read_only(state) {
+		set_message(state, "%s is read-only", document_title(state.path))
+		return
+	}
+	editor.begin_undo_group(&state.undo, state.cursor_pos)
+	insert_rune_at_cursor(state, r)
+	editor.end_undo_group(&state.undo, state.cursor_pos)
+	set_message(state, "Inserted U+%04X %s", r, character_name(state, r))
+}
+
+@(private = "file")
+list_characters :: proc(state: ^Editor_State, query: string) {
+	clear_replace(state)
+	stop_project_search(state)
+	release_panel(state)
+	state.unicode.active = true
+	panel := state.panel_data
+	editor.panel_clear(panel)
+
+	query := strings.trim_space(query)
+	words := strings.fields(strings.to_upper(query, context.temp_allocator), context.temp_allocator)
+	found := make([dynamic]Unicode_Name, context.temp_allocator)
+	if r, ok := parse_code_point(query); ok {
+		append(&found, Unicode_Name{r, character_name(state, r)})
+	}
+	if r, ok := editor.find_digraph(query); ok {
+		append(&found, Unicode_Name{r, character_name(state, r)})
+	}
+	if len(words) == 0 {
+		for d in editor.DIGRAPHS {
+			append(&found, Unicode_Name{d.r, d.name})
+		}
+	} else {
+		names := state.unicode.names[:]
+		digraph_names := make([dynamic]Unicode_Name, context.temp_allocator)
+		if len(names) == 0 {
+			for d in editor.DIGRAPHS {
+				append(&digraph_names, Unicode_Name{d.r, d.name})
+			}
+			names = digraph_names[:]
+		}
+		for n in names {
+			if len(found) >= UNICODE_MAX_LISTED {
+				break
+			}
+			if has_all_words(n.name, words) {
+				append(&found, n)
+			}
+		}
+	}
+
+	editor.panel_set_title(panel, fmt.tprintf("Characters (%d); enter inserts", len(found)))
+	for n in found {
+		text := fmt.tprintf(
+			"%-3s U+%04X  %-56s %s",
+			shown_character(n.r),
+			n.r,
+			n.name,
+			editor.digraph_of(n.r),
+		)
+		editor.panel_add_item(panel, {text = text, line = -1, data = int(n.r)})
+	}
+	show_panel(state)
+}
+
+@(private = "file")
+has_all_words :: proc(name: string, words: []string) -> bool {
+	for w in words {
+		if !strings.contains(name, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// The character as listed: combining marks on a dotted circle, and nothing
+// for controls.
+@(private = "file")
+shown_character :: proc(r: rune) -> string {
+	switch {
+	case r < 0x20 || (r >= 0x7f && r < 0xa0):
+		return "  "
+	case editor.rune_width(r) == 0:
+		return fmt.tprintf("◌%c", r)
+	}
+	return fmt.tprintf("%c", r)
+}
+
+// U+00E9, u+e9, 0xe9, or hex digits with a decimal one among them, so that
+// "face" stays a word.
+@(private = "file")
+parse_code_point :: proc(text: string) -> (rune, bool) {
+	digits := strings.trim_space(text)
+	switch {
+	case strings.has_prefix(digits, "U+") || strings.has_prefix(digits, "u+"):
+		digits = digits[2:]
+	case strings.has_prefix(digits, "0x") || strings.has_prefix(digits, "0X"):
+		digits = digits[2:]
+	case len(digits) < 2 || !strings.contains_any(digits, "0123456789"):
+		return 0, false
+	}
+	v, ok := strconv.parse_u64_of_base(digits, 16)
+	if !ok || v > u64(utf8.MAX_RUNE) || (v >= 0xd800 && v < 0xe000) {
+		return 0, false
+	}
+	return rune(v), true
+}
+
+@(private = "file")
+character_name :: proc(state: ^Editor_State, r: rune) -> string {
+	names := state.unicode.names[:]
+	lo, hi := 0, len(names)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if names[mid].r < r {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	if lo < len(names) && names[lo].r == r {
+		return names[lo].name
+	}
+	for d in editor.DIGRAPHS {
+		if d.r == r {
+			return d.name
+		}
+	}
+	switch {
+	case 0x3400 <= r && r <= 0x9fff, 0x20000 <= r && r <= 0x3134f:
+		return fmt.tprintf("CJK UNIFIED IDEOGRAPH-%04X", r)
+	case 0xac00 <= r && r <= 0xd7a3:
+		return "HANGUL SYLLABLE"
+	}
+	return "(no name known)"
+}
+
+// Reads the names from unicode.data_file or the first UnicodeData.txt found,
+// once.  Lines are "00E9;LATIN SMALL LETTER E WITH ACUTE;Ll;...;"; controls
+// are named in the tenth field and the ranges of ideographs not at all.
+@(private = "file")
+load_unicode_names :: proc(state: ^Editor_State) {
+	u := &state.unicode
+	if u.loaded {
+		return
+	}
+	u.loaded = true
+	paths := make([dynamic]string, context.temp_allocator)
+	if path, ok := config_value(state, "unicode.data_file").(string); ok {
+		append(&paths, path)
+	}
+	append(&paths, ..UNICODE_DATA_PATHS[:])
+	for path in paths {
+		data, err := os.read_entire_file_from_path(path, context.allocator)
+		if err != nil {
+			continue
+		}
+		u.data = data
+		text := string(data)
+		for line in strings.split_lines_iterator(&text) {
+			fields := strings.split_n(line, ";", 12, context.temp_allocator)
+			if len(fields) < 11 {
+				continue
+			}
+			v, ok := strconv.parse_u64_of_base(fields[0], 16)
+			if !ok {
+				continue
+			}
+			name := fields[1]
+			if strings.has_prefix(name, "<") {
+				name = fields[10]
+			}
+			if name != "" {
+				append(&u.names, Unicode_Name{rune(v), name})
+			}
+		}
+		log.debugf("unicode: %d names from %s", len(u.names), path)
+		return
+	}
+	log.debugf("unicode: no UnicodeData.txt, only the digraphs have names")
+}
@@ -0,0 +1,62 @@
+package main
+
+import "core:fmt"
+import "core:slice"
+import "core:time"
+import editor "editor"
+
+// The popup that lists, after a prefix key such as ctrl+k, the keys that may
+// follow and the commands they run.  It is built from the keymaps in scope,
+// so user bindings and language bindings show up as they are.
+Which_Key_State :: struct {
+	pending: editor.Key_Sequence, // the prefix being waited on
+	since:   time.Tick, // its last key
+	shown:   bool, // the popup lists pending's keys
+}
+
+// Shows the popup once a prefix has waited keys.which_key_delay, and hides it
+// when the sequence ends.  Called every frame.
+update_which_key :: proc(state: ^Editor_State) {
+	wk := &state.which_key
+	d := state.which_key_data
+	km := &state.keymap
+	if !editor.has_pending_keys(km) || !config_bool(state, "keys.which_key") {
+		if d.visible {
+			d.visible = false
+			request_redraw(state)
+		}
+		wk^ = {}
+		return
+	}
+	if km.pending != wk.pending {
+		wk.pending = km.pending
+		wk.since = time.tick_now()
+		wk.shown = false
+	}
+	// Once open, the popup follows the next prefix at once.
+	delay := time.Duration(config_int(state, "keys.which_key_delay")) * time.Millisecond
+	if wk.shown || !d.visible && time.tick_since(wk.since) < delay {
+		return
+	}
+	wk.shown = true
+
+	entries := make([dynamic]editor.Which_Key_Entry, context.temp_allocator)
+	for k in editor.pending_continuations(km, state.mode, state.language) {
+		e := editor.Which_Key_Entry {
+			keys    = editor.format_key_chord(k.chord),
+			command = k.command,
+		}
+		if k.command == "" {
+			e.command = fmt.tprintf("+%d more", k.more)
+			e.prefix = true
+		}
+		append(&entries, e)
+	}
+	slice.sort_by(entries[:], proc(a, b: editor.Which_Key_Entry) -> bool {
+		return a.keys < b.keys
+	})
+	title := fmt.tprintf("%s -", editor.format_key_sequence(km.pending))
+	editor.set_which_key_entries(d, title, entries[:])
+	d.visible = len(entries) > 0
+	request_redraw(state)
+}
@@ -0,0 +1,581 @@
+package main
+
+import "core:fmt"
+import "core:os"
+import "core:path/filepath"
+import "core:slice"
+import "core:strings"
+import editor "editor"
+
+// Applying a WorkspaceEdit (editor.Workspace_Edit), such as a server's rename
+// sends or a plugin passes to rune.apply_edit.  The whole edit is first tried
+// on the files as each change leaves them: open documents must still be at
+// the version the edits were made for, ranges must be in the text, and files
+// must exist or not as each operation needs.  Then files are created,
+// renamed, deleted and written in order, each step noted so that when one
+// fails the steps before it are undone.  Open documents change last, each as
+// one undo step, and are not saved.  An edit with changes whose annotations
+// need confirmation is asked about first.
+Workspace_Edit_State :: struct {
+	pending:  string, // the JSON of an edit waiting for confirmation
+	label:    string, // and what it is called
+	encoding: editor.Position_Encoding, // and how its characters count
+}
+
+destroy_workspace_edit :: proc(state: ^Editor_State) {
+	delete(state.workspace_edit.pending)
+	delete(state.workspace_edit.label)
+}
+
+// Applies the WorkspaceEdit in text, called label in messages, whose
+// positions count characters in encoding.  False with the reason when it is
+// not applied; true once it is, or once the user is asked to confirm it.
+apply_workspace_edit :: proc(
+	state: ^Editor_State,
+	text, label: string,
+	encoding := editor.Position_Encoding.Utf16,
+) -> (
+	ok: bool,
+	err: string,
+) {
+	edit, perr := editor.parse_workspace_edit(text)
+	if perr != "" {
+		return false, perr
+	}
+	question := confirmation_question(edit, label)
+	if question == "" {
+		return run_workspace_edit(state, edit, label, encoding)
+	}
+	we := &state.workspace_edit
+	delete(we.pending)
+	delete(we.label)
+	we.pending = strings.clone(text)
+	we.label = strings.clone(label)
+	we.encoding = encoding
+	confirm(state, question, apply_pending_edit, drop_pending_edit)
+	return true, ""
+}
+
+@(private = "file")
+apply_pending_edit :: proc(state: ^Editor_State) {
+	we := &state.workspace_edit
+	text := strings.clone(we.pending, context.temp_allocator)
+	label := strings.clone(we.label, context.temp_allocator)
+	drop_pending_edit(state)
+	edit, _ := editor.parse_workspace_edit(text)
+	if ok, err := run_workspace_edit(state, edit, label, we.encoding); !ok {
+		set_message(state, "%s not applied: %s", label, err)
+	}
+}
+
+@(private = "file")
+drop_pending_edit :: proc(state: ^Editor_State) {
+	we := &state.workspace_edit
+	delete(we.pending)
+	delete(we.label)
+	we.pending, we.label = "", ""
+}
+
+// "" when no change needs confirmation.  Temp allocated.
+@(private = "file")
+confirmation_question :: proc(edit: editor.Workspace_Edit, label: string) -> string {
+	labels := make([dynamic]string, context.temp_allocator)
+	note := proc(edit: editor.Workspace_Edit, labels: ^[dynamic]string, id: string) {
+		a, found := edit.annotations[id]
+		if found && a.needs_confirmation && !slice.contains(labels[:], a.label) {
+			append(labels, a.label)
+		}
+	}
+	for op in edit.operations {
+		switch o in op {
+		case editor.Document_Edit:
+			for e in o.edits {
+				note(edit, &labels, e.annotation)
+			}
+		case editor.Create_File:
+			note(edit, &labels, o.annotation)
+		case editor.Rename_File:
+			note(edit, &labels, o.annotation)
+		case editor.Delete_File:
+			note(edit, &labels, o.annotation)
+		}
+	}
+	if len(labels) == 0 {
+		return ""
+	}
+	list := strings.join(labels[:], "; ", context.temp_allocator)
+	return fmt.tprintf("%s: %s? (y/n)", label, list)
+}
+
+// A file as the edit has left it so far.
+@(private = "file")
+Planned_File :: struct {
+	exists:  bool,
+	is_dir:  bool,
+	origin:  string, // where it is before the edit, "" when it was not there
+	doc:     int, // index of the document holding it, or -1
+	text:    string, // its contents, once read
+	read:    bool,
+	changed: bool, // text is to be written, or put in the document
+	touched: bool,
+}
+
+@(private = "file")
+Edit_Step_Kind :: enum {
+	Create,
+	Rename,
+	Delete,
+}
+
+// A change to the file system; Rename moves path to to.
+@(private = "file")
+Edit_Step :: struct {
+	kind: Edit_Step_Kind,
+	path: string,
+	to:   string,
+}
+
+@(private = "file")
+Edit_Plan :: struct {
+	files: map[string]Planned_File, // by current path
+	steps: [dynamic]Edit_Step,
+}
+
+// How to undo a step that was taken: Remove a path, Move from back to path,
+// or Restore path's contents.
+@(private = "file")
+Journal_Entry :: struct {
+	kind: enum {
+		Remove,
+		Move,
+		Restore,
+	},
+	path: string,
+	from: string,
+	data: []u8,
+}
+
+@(private = "file")
+run_workspace_edit :: proc(
+	state: ^Editor_State,
+	edit: editor.Workspace_Edit,
+	label: string,
+	encoding: editor.Position_Encoding,
+) -> (
+	ok: bool,
+	err: string,
+) {
+	plan := Edit_Plan {
+		files = make(map[string]Planned_File, allocator = context.temp_allocator),
+		steps = make([dynamic]Edit_Step, context.temp_allocator),
+	}
+	for op in edit.operations {
+		if err = plan_operation(state, &plan, op, encoding); err != "" {
+			return false, err
+		}
+	}
+
+	journal := make([dynamic]Journal_Entry, context.temp_allocator)
+	backups := make([dynamic]string, context.temp_allocator)
+	for step in plan.steps {
+		if err = take_edit_step(step, &journal, &backups); err != "" {
+			return false, roll_back(journal[:], err)
+		}
+	}
+	for path, f in plan.files {
+		if !f.changed || f.doc >= 0 || !f.exists {
+			continue
+		}
+		old, rerr := os.read_entire_file_from_path(path, context.temp_allocator)
+		if rerr != nil {
+			err = fmt.tprintf("cannot read %s: %v", path, rerr)
+			return false, roll_back(journal[:], err)
+		}
+		append(&journal, Journal_Entry{kind = .Restore, path = path, data = old})
+		if werr := write_file_atomic(path, transmute([]u8)f.text); werr != nil {
+			err = fmt.tprintf("cannot write %s: %v", path, werr)
+			return false, roll_back(journal[:], err)
+		}
+	}
+	for b in backups {
+		_ = os.remove_all(b)
+	}
+
+	for _, f in plan.files {
+		if f.changed && f.doc >= 0 {
+			set_document_text(state, f.doc, f.text)
+		}
+	}
+	for step in plan.steps {
+		if step.kind == .Rename {
+			move_document_paths(state, step.path, step.to)
+		}
+	}
+	touched := 0
+	for _, f in plan.files {
+		touched += 1 if f.touched else 0
+	}
+	set_message(state, "%s: changed %d files", label, touched)
+	request_redraw(state)
+	return true, ""
+}
+
+// Tries op on the plan.  Returns why it cannot be made, or "".
+@(private = "file")
+plan_operation :: proc(
+	state: ^Editor_State,
+	plan: ^Edit_Plan,
+	op: editor.Workspace_Operation,
+	encoding: editor.Position_Encoding,
+) -> string {
+	switch o in op {
+	case editor.Document_Edit:
+		f := planned_file(state, plan, o.path)
+		if !f.exists && f.doc < 0 {
+			return fmt.tprintf("%s does not exist", o.path)
+		}
+		if f.is_dir {
+			return fmt.tprintf("%s is a directory", o.path)
+		}
+		if version, has := o.version.?; has {
+			if f.doc < 0 {
+				return fmt.tprintf("%s is not open; the edit is for version %d", o.path, version)
+			}
+			if current := document_version(state, f.doc); current != version {
+				return fmt.tprintf("%s changed since the edit was made", o.path)
+			}
+		}
+		if f.doc >= 0 && !is_document_editable(state, f.doc) {
+			return fmt.tprintf("%s is read-only", o.path)
+		}
+		if !f.read {
+			data, err := os.read_entire_file_from_path(f.origin, context.temp_allocator)
+			if err != nil {
+				return fmt.tprintf("cannot read %s: %v", o.path, err)
+			}
+			f.text, f.read = string(data), true
+		}
+		text, err := editor.apply_lsp_text_edits(f.text, o.edits, encoding)
+		if err != "" {
+			return fmt.tprintf("%s: %s", o.path, err)
+		}
+		f.text, f.changed, f.touched = text, true, true
+	case editor.Create_File:
+		f := planned_file(state, plan, o.path)
+		if f.exists && !o.overwrite {
+			if o.ignore_if_exists {
+				return ""
+			}
+			return fmt.tprintf("%s exists already", o.path)
+		}
+		append(&plan.steps, Edit_Step{kind = .Create, path = o.path})
+		f.exists, f.is_dir, f.touched = true, false, true
+		f.text, f.read = "", true
+		f.changed = f.doc >= 0 // an open document is emptied
+	case editor.Rename_File:
+		from := planned_file(state, plan, o.old_path)
+		if !from.exists {
+			return fmt.tprintf("%s does not exist", o.old_path)
+		}
+		to := planned_file(state, plan, o.new_path)
+		if to.exists && !o.overwrite {
+			if o.ignore_if_exists {
+				return ""
+			}
+			return fmt.tprintf("%s exists already", o.new_path)
+		}
+		append(&plan.steps, Edit_Step{kind = .Rename, path = o.old_path, to = o.new_path})
+		// The file, and what a directory holds, are now found under the new path.
+		forget_planned(plan, o.new_path)
+		moved := make([dynamic]string, context.temp_allocator)
+		for path in plan.files {
+			if is_within(path, o.old_path) {
+				append(&moved, path)
+			}
+		}
+		for path in moved {
+			f := plan.files[path]
+			f.touched = true
+			rest := path[len(o.old_path):]
+			plan.files[strings.concatenate({o.new_path, rest}, context.temp_allocator)] = f
+			plan.files[path] = Planned_File {
+				doc     = -1,
+				read    = true,
+				touched = true,
+			}
+		}
+	case editor.Delete_File:
+		f := planned_file(state, plan, o.path)
+		if !f.exists {
+			if o.ignore_if_not_exists {
+				return ""
+			}
+			return fmt.tprintf("%s does not exist", o.path)
+		}
+		if f.is_dir && !o.recursive {
+			entries, _ := os.read_all_directory_by_path(f.origin, context.temp_allocator)
+			if len(entries) > 0 {
+				return fmt.tprintf("%s is a directory that is not empty", o.path)
+			}
+		}
+		append(&plan.steps, Edit_Step{kind = .Delete, path = o.path})
+		forget_planned(plan, o.path)
+		plan.files[o.path] = Planned_File {
+			doc     = -1,
+			read    = true,
+			touched = true,
+		}
+	}
+	return ""
+}
+
+// The plan's entry for path, made from the file system and the open
+// documents as they were before the steps planned so far.
+@(private = "file")
+planned_file :: proc(state: ^Editor_State, plan: ^Edit_Plan, path: string) -> ^Planned_File {
+	if f, found := &plan.files[path]; found {
+		return f
+	}
+	// Follow the planned steps back to where the file was before them.
+	origin := path
+	#reverse for step in plan.steps {
+		switch step.kind {
+		case .Rename:
+			if is_within(origin, step.to) {
+				rest := origin[len(step.to):]
+				origin = strings.concatenate({step.path, rest}, context.temp_allocator)
+			} else if is_within(origin, step.path) {
+				origin = "" // moved away
+			}
+		case .Create, .Delete:
+			if is_within(origin, step.path) {
+				origin = "" // in a file made or a directory removed
+			}
+		}
+		if origin == "" {
+			break
+		}
+	}
+	f := Planned_File {
+		doc = -1,
+	}
+	if origin != "" {
+		f.origin = origin
+		f.exists = os.exists(origin)
+		f.is_dir = os.is_dir(origin)
+		if is_open_file(state, origin) {
+			f.doc = state.active
+		} else {
+			f.doc = find_document(state, origin)
+		}
+		if f.doc >= 0 {
+			f.text = document_text(state, f.doc)
+			f.read = true
+		}
+	}
+	plan.files[path] = f
+	return &plan.files[path]
+}
+
+// Drops the entries of path and what it holds, which a step replaces.
+@(private = "file")
+forget_planned :: proc(plan: ^Edit_Plan, path: string) {
+	gone := make([dynamic]string, context.temp_allocator)
+	for p in plan.files {
+		if is_within(p, path) {
+			append(&gone, p)
+		}
+	}
+	for p in gone {
+		delete_key(&plan.files, p)
+	}
+}
+
+// Whether path is dir or inside it.
+@(private = "file")
+is_within :: proc(path, dir: string) -> bool {
+	if !strings.has_prefix(path, dir) {
+		return false
+	}
+	return len(path) == len(dir) || path[len(dir)] == '/' || path[len(dir)] == filepath.SEPARATOR
+}
+
+// Takes a step, noting in the journal how to undo it.  Returns why it failed,
+// or "".
+@(private = "file")
+take_edit_step :: proc(
+	step: Edit_Step,
+	journal: ^[dynamic]Journal_Entry,
+	backups: ^[dynamic]string,
+) -> string {
+	target := step.to if step.kind == .Rename else step.path
+	// A file in the way is kept aside until the whole edit is done.
+	if step.kind != .Delete && os.exists(target) {
+		backup := backup_path(target)
+		if err := os.rename(target, backup); err != nil {
+			return fmt.tprintf("cannot replace %s: %v", target, err)
+		}
+		append(journal, Journal_Entry{kind = .Move, path = target, from = backup})
+		append(backups, backup)
+	}
+	if step.kind != .Delete {
+		dir := filepath.dir(target, context.temp_allocator)
+		if missing := first_missing_dir(dir); missing != "" {
+			if err := os.make_directory_all(dir); err != nil {
+				return fmt.tprintf("cannot make %s: %v", dir, err)
+			}
+			append(journal, Journal_Entry{kind = .Remove, path = missing})
+		}
+	}
+	switch step.kind {
+	case .Create:
+		if err := os.write_entire_file(step.path, nil); err != nil {
+			return fmt.tprintf("cannot create %s: %v", step.path, err)
+		}
+		append(journal, Journal_Entry{kind = .Remove, path = step.path})
+	case .Rename:
+		if err := os.rename(step.path, step.to); err != nil {
+			return fmt.tprintf("cannot rename %s to %s: %v", step.path, step.to, err)
+		}
+		append(journal, Journal_Entry{kind = .Move, path = step.path, from = step.to})
+	case .Delete:
+		backup := backup_path(step.path)
+		if err := os.rename(step.path, backup); err != nil {
+			return fmt.tprintf("cannot delete %s: %v", step.path, err)
+		}
+		append(journal, Journal_Entry{kind = .Move, path = step.path, from = backup})
+		append(backups, backup)
+	}
+	return ""
+}
+
+// Undoes the journal's steps, last first, and returns err, with the paths
+// that could not be put back.
+@(private = "file")
+roll_back :: proc(journal: []Journal_Entry, err: string) -> string {
+	failed := make([dynamic]string, context.temp_allocator)
+	#reverse for j in journal {
+		e: os.Error
+		switch j.kind {
+		case .Remove:
+			e = os.remove_all(j.path)
+		case .Move:
+			e = os.rename(j.from, j.path)
+		case .Restore:
+			e = write_file_atomic(j.path, j.data)
+		}
+		if e != nil {
+			append(&failed, j.path)
+		}
+	}
+	if len(failed) > 0 {
+		list := strings.join(failed[:], ", ", context.temp_allocator)
+		return fmt.tprintf("%s; could not undo the changes to %s", err, list)
+	}
+	return fmt.tprintf("%s; nothing was changed", err)
+}
+
+// A free name next to path.  Temp allocated.
+@(private = "file")
+backup_path :: proc(path: string) -> string {
+	backup := fmt.tprintf("%s.rune-edit", path)
+	for n := 1; os.exists(backup); n += 1 {
+		backup = fmt.tprintf("%s.rune-edit-%d", path, n)
+	}
+	return backup
+}
+
+// The outermost directory of dir's that does not exist, or "".
+@(private = "file")
+first_missing_dir :: proc(dir: string) -> string {
+	missing, d := "", dir
+	for d != "" && !os.exists(d) {
+		missing = d
+		parent := filepath.dir(d, context.temp_allocator)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+	return missing
+}
+
+// Replaces the text of document i, as one undo step.
+@(private = "file")
+set_document_text :: proc(state: ^Editor_State, i: int, text: string) {
+	if i == state.active {
+		anchor := &state.selection_anchor
+		replace_document_text(&state.buffer, &state.undo, &state.cursor_pos, anchor, text)
+		sync_cursor(state)
+		set_preferred_col(state)
+		return
+	}
+	d := &state.documents[i]
+	d.buffer.undo = &d.undo
+	replace_document_text(&d.buffer, &d.undo, &d.cursor_pos, &d.selection_anchor, text)
+	d.buffer.undo = nil
+}
+
+@(private = "file")
+replace_document_text :: proc(
+	buffer: ^editor.Gap_Buffer,
+	undo: ^editor.Undo_History,
+	cursor, anchor: ^int,
+	text: string,
+) {
+	old := editor.get_text(buffer, context.temp_allocator)
+	edits := editor.diff_text(old, text, context.temp_allocator)
+	editor.begin_undo_group(undo, cursor^)
+	#reverse for e in edits {
+		editor.replace_bytes(buffer, e.pos, e.count, transmute([]u8)e.text)
+	}
+	cursor^ = editor.map_position(edits, cursor^)
+	if anchor^ >= 0 {
+		anchor^ = editor.map_position(edits, anchor^)
+	}
+	editor.end_undo_group(undo, cursor^)
+}
+
+// Points the documents of files at or under from at their new path.
+@(private = "file")
+move_document_paths :: proc(state: ^Editor_State, from, to: string) {
+	move :: proc(state: ^Editor_State, path: ^string, from, to: string) {
+		if path^ == "" {
+			return
+		}
+		full := workspace_path(state, path^)
+		if is_within(full, from) {
+			moved := strings.concatenate({to, full[len(from):]})
+			delete(path^)
+			path^ = moved
+		}
+	}
+	move(state, &state.path, from, to)
+	for &d, i in state.documents {
+		if i != state.active {
+			move(state, &d.path, from, to)
+		}
+	}
+}
+
+@(private = "file")
+document_text :: proc(state: ^Editor_State, i: int) -> string {
+	if i == state.active {
+		return editor.get_text(&state.buffer, context.temp_allocator)
+	}
+	return editor.get_text(&state.documents[i].buffer, context.temp_allocator)
+}
+
+@(private = "file")
+document_version :: proc(state: ^Editor_State, i: int) -> int {
+	return state.undo.version if i == state.active else state.documents[i].undo.version
+}
+
+@(private = "file")
+is_document_editable :: proc(state: ^Editor_State, i: int) -> bool {
+	if i == state.active {
+		return !state.preview && state.disk.view == .Text
+	}
+	d := &state.documents[i]
+	return !d.preview && d.disk.view == .Text
+}
@@ -0,0 +1,99 @@
+package main
+
+import "core:strings"
+import editor "editor"
+
+// Lines looked at either side of the cursor for the ends of its paragraph.
+ZEN_PARAGRAPH_REACH :: 500
+
+// Distraction-free writing: view.zen centers zen.width columns of text in the
+// window and hides the gutter and the status line; with zen.dim_paragraphs
+// the paragraphs around the cursor's are dimmed.  Messages still arrive but
+// are not shown until it is turned off.
+Zen_State :: struct {
+	active:      bool,
+	normal_left: f32, // where the text starts outside zen mode
+	focus_line:  int, // the cursor line the focus paragraph was found for
+	focus_undo:  int, // and the undo version; -1 when it must be found again
+}
+
+// view.zen: turns zen mode on or off.
+toggle_zen_mode :: proc(state: ^Editor_State) {
+	z := &state.zen
+	if !z.active {
+		z.normal_left = state.cursor_data.padding[0]
+	}
+	z.active = !z.active
+	z.focus_undo = -1
+	editor.set_layer_enabled(&state.compositor, "line_numbers", !z.active)
+	editor.set_layer_enabled(&state.compositor, "status_line", !z.active)
+	if !z.active {
+		set_text_left(state, z.normal_left)
+		state.text_data.dim = false
+		set_message(state, "Zen mode off")
+	}
+	update_zen_mode(state)
+	scroll_to_cursor(state)
+	request_redraw(state)
+}
+
+// Keeps the text centered as the window or zen.width changes, and the focus
+// paragraph on the cursor's.  Called every frame.
+update_zen_mode :: proc(state: ^Editor_State) {
+	z := &state.zen
+	if !z.active {
+		return
+	}
+	width := f32(config_int(state, "zen.width")) * state.cursor_data.char_width
+	left := max((state.layer_ctx.viewport[0] - width) / 2, 8)
+	if left != state.cursor_data.padding[0] {
+		set_text_left(state, left)
+		request_redraw(state)
+	}
+
+	text := state.text_data
+	dim := config_bool(state, "zen.dim_paragraphs")
+	line := state.cursor_data.line
+	if dim == text.dim && line == z.focus_line && state.undo.version == z.focus_undo {
+		return
+	}
+	text.dim = dim
+	z.focus_line = line
+	z.focus_undo = state.undo.version
+	text.focus = paragraph_lines(&state.buffer, line)
+	request_redraw(state)
+}
+
+// Moves the left edge of the text in every layer that draws on its grid.
+@(private = "file")
+set_text_left :: proc(state: ^Editor_State, left: f32) {
+	state.text_data.padding[0] = left
+	state.selection_data.padding[0] = left
+	state.virtual_text.padding[0] = left
+	state.cursor_data.padding[0] = left
+	state.ruler_data.padding[0] = left
+	state.peer_cursor_data.padding[0] = left
+	state.completion_data.padding[0] = left
+}
+
+// The first and last line of the paragraph around line, which ends at blank
+// lines.  A blank line is a paragraph of its own.
+@(private = "file")
+paragraph_lines :: proc(gb: ^editor.Gap_Buffer, line: int) -> [2]int {
+	is_blank :: proc(gb: ^editor.Gap_Buffer, line: int) -> bool {
+		text := editor.get_line(gb, line, context.temp_allocator)
+		return strings.trim_space(text) == ""
+	}
+	if is_blank(gb, line) {
+		return {line, line}
+	}
+	first, last := line, line
+	for first > max(line - ZEN_PARAGRAPH_REACH, 0) && !is_blank(gb, first - 1) {
+		first -= 1
+	}
+	end := min(line + ZEN_PARAGRAPH_REACH, editor.get_line_count(gb) - 1)
+	for last < end && !is_blank(gb, last + 1) {
+		last += 1
+	}
+	return {first, last}
+}